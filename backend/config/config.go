@@ -16,9 +16,14 @@ type ServerConfig struct {
 }
 
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	App      AppConfig
+	Server    ServerConfig
+	Database  DatabaseConfig
+	App       AppConfig
+	LinkCheck LinkCheckConfig
+	FeedPoll  FeedPollConfig
+	Digest    ReadingDigestConfig
+	Reminders ReminderConfig
+	Collab    CollabConfig
 }
 
 type DatabaseConfig struct {
@@ -38,6 +43,45 @@ type AppConfig struct {
 	CorsOrigins string
 }
 
+// LinkCheckConfig controls the background bookmark link checker
+type LinkCheckConfig struct {
+	Enabled      bool
+	Interval     time.Duration
+	RecheckAfter time.Duration
+	BatchSize    int
+	Concurrency  int
+	HostDelay    time.Duration
+}
+
+// FeedPollConfig controls the background feed poller
+type FeedPollConfig struct {
+	Enabled         bool
+	Interval        time.Duration
+	RefreshInterval time.Duration
+	BatchSize       int
+	Concurrency     int
+}
+
+// ReadingDigestConfig controls the reading queue email digest scheduler
+type ReadingDigestConfig struct {
+	Enabled  bool
+	Interval time.Duration
+}
+
+// ReminderConfig controls the event, task and habit reminder scheduler
+type ReminderConfig struct {
+	Enabled  bool
+	Interval time.Duration
+	Horizon  time.Duration
+	Grace    time.Duration
+}
+
+// CollabConfig controls collaborative editing of notes and wiki pages
+type CollabConfig struct {
+	SnapshotInterval time.Duration
+	HistoryLimit     int
+}
+
 func Load() *Config {
 	return &Config{
 		Server: ServerConfig{
@@ -62,6 +106,35 @@ func Load() *Config {
 			JWTSecret:   os.Getenv("JWT_SECRET"),
 			CorsOrigins: getEnvWithDefault("CORS_ALLOWED_ORIGINS", ""),
 		},
+		LinkCheck: LinkCheckConfig{
+			Enabled:      getBoolEnv("LINK_CHECK_ENABLED", true),
+			Interval:     getDurationEnv("LINK_CHECK_INTERVAL", time.Hour),
+			RecheckAfter: getDurationEnv("LINK_CHECK_RECHECK_AFTER", 7*24*time.Hour),
+			BatchSize:    getIntEnv("LINK_CHECK_BATCH_SIZE", 200),
+			Concurrency:  getIntEnv("LINK_CHECK_CONCURRENCY", 8),
+			HostDelay:    getDurationEnv("LINK_CHECK_HOST_DELAY", 2*time.Second),
+		},
+		FeedPoll: FeedPollConfig{
+			Enabled:         getBoolEnv("FEED_POLL_ENABLED", true),
+			Interval:        getDurationEnv("FEED_POLL_INTERVAL", 5*time.Minute),
+			RefreshInterval: getDurationEnv("FEED_REFRESH_INTERVAL", time.Hour),
+			BatchSize:       getIntEnv("FEED_POLL_BATCH_SIZE", 100),
+			Concurrency:     getIntEnv("FEED_POLL_CONCURRENCY", 4),
+		},
+		Digest: ReadingDigestConfig{
+			Enabled:  getBoolEnv("READING_DIGEST_ENABLED", true),
+			Interval: getDurationEnv("READING_DIGEST_INTERVAL", 15*time.Minute),
+		},
+		Reminders: ReminderConfig{
+			Enabled:  getBoolEnv("REMINDERS_ENABLED", true),
+			Interval: getDurationEnv("REMINDER_INTERVAL", time.Minute),
+			Horizon:  getDurationEnv("REMINDER_HORIZON", 24*time.Hour),
+			Grace:    getDurationEnv("REMINDER_GRACE", time.Hour),
+		},
+		Collab: CollabConfig{
+			SnapshotInterval: getDurationEnv("COLLAB_SNAPSHOT_INTERVAL", 10*time.Second),
+			HistoryLimit:     getIntEnv("COLLAB_HISTORY_LIMIT", 500),
+		},
 	}
 }
 
@@ -116,3 +189,19 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 
 	return time.Duration(seconds) * time.Second
 }
+
+func getIntEnv(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+func getBoolEnv(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"net/smtp"
 	"os"
 	"os/exec"
 	"runtime"
@@ -19,6 +18,7 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/trackeep/backend/config"
 	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
@@ -169,6 +169,9 @@ func hasAPIKeyPermission(permissions []string, required string) bool {
 		if required == "files:share" && permission == "files:write" {
 			return true
 		}
+		if required == "highlights:write" && permission == "bookmarks:write" {
+			return true
+		}
 	}
 
 	return false
@@ -179,6 +182,10 @@ func requiredAPIKeyPermission(method, path string) (string, bool) {
 		return "", true
 	}
 
+	if strings.Contains(path, "/api/v1/browser-extension/highlights") {
+		return "highlights:write", true
+	}
+
 	if !strings.Contains(path, "/api/v1/files") {
 		return "", false
 	}
@@ -594,21 +601,6 @@ func generateResetCode() (string, error) {
 
 // sendResetEmail sends a password reset email
 func sendResetEmail(email, code string) error {
-	smtpHost := os.Getenv("SMTP_HOST")
-	smtpPort := os.Getenv("SMTP_PORT")
-	smtpUsername := os.Getenv("SMTP_USERNAME")
-	smtpPassword := os.Getenv("SMTP_PASSWORD")
-	fromEmail := os.Getenv("SMTP_FROM_EMAIL")
-	fromName := os.Getenv("SMTP_FROM_NAME")
-
-	if smtpHost == "" || smtpUsername == "" || smtpPassword == "" || fromEmail == "" {
-		return errors.New("SMTP configuration not complete")
-	}
-
-	// Create auth
-	auth := smtp.PlainAuth("", smtpUsername, smtpPassword, smtpHost)
-
-	// Compose message
 	subject := "Password Reset - Trackeep"
 	body := fmt.Sprintf(`
 Hello,
@@ -623,14 +615,9 @@ If you didn't request this, please ignore this email.
 
 Best regards,
 %s
-`, code, fromName)
+`, code, services.SMTPConfigFromEnv().FromName)
 
-	msg := fmt.Sprintf("From: %s <%s>\r\nTo: %s\r\nSubject: %s\r\n\r\n%s",
-		fromName, fromEmail, email, subject, body)
-
-	// Send email
-	addr := fmt.Sprintf("%s:%s", smtpHost, smtpPort)
-	return smtp.SendMail(addr, auth, fromEmail, []string{email}, []byte(msg))
+	return services.SendMail(email, subject, body)
 }
 
 // RequestPasswordReset handles password reset requests
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/services"
+	"gorm.io/gorm"
+)
+
+// GetBoards handles GET /api/v1/boards and lists personal and team boards
+func GetBoards(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	boards, err := services.NewBoardService(config.GetDB()).List(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch boards"})
+		return
+	}
+
+	c.JSON(http.StatusOK, boards)
+}
+
+// CreateBoard handles POST /api/v1/boards
+func CreateBoard(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var input services.BoardInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	board, err := services.NewBoardService(config.GetDB()).Create(userID, input)
+	if err != nil {
+		writeBoardError(c, err, "Failed to create board")
+		return
+	}
+
+	c.JSON(http.StatusCreated, board)
+}
+
+// GetBoard handles GET /api/v1/boards/:id and returns the board with its
+// cards laid out in columns and swimlanes
+func GetBoard(c *gin.Context) {
+	userID, boardID, ok := boardParams(c)
+	if !ok {
+		return
+	}
+
+	view, err := services.NewBoardService(config.GetDB()).View(userID, boardID)
+	if err != nil {
+		writeBoardError(c, err, "Failed to fetch board")
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
+
+// UpdateBoard handles PUT /api/v1/boards/:id
+func UpdateBoard(c *gin.Context) {
+	userID, boardID, ok := boardParams(c)
+	if !ok {
+		return
+	}
+
+	var input services.BoardInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	board, err := services.NewBoardService(config.GetDB()).Update(userID, boardID, input)
+	if err != nil {
+		writeBoardError(c, err, "Failed to update board")
+		return
+	}
+
+	services.GetMessagesHub().BroadcastBoard(boardID, "board.updated", board)
+	c.JSON(http.StatusOK, board)
+}
+
+// DeleteBoard handles DELETE /api/v1/boards/:id
+func DeleteBoard(c *gin.Context) {
+	userID, boardID, ok := boardParams(c)
+	if !ok {
+		return
+	}
+
+	if err := services.NewBoardService(config.GetDB()).Delete(userID, boardID); err != nil {
+		writeBoardError(c, err, "Failed to delete board")
+		return
+	}
+
+	services.GetMessagesHub().BroadcastBoard(boardID, "board.deleted", gin.H{"board_id": boardID})
+	c.JSON(http.StatusOK, gin.H{"message": "Board deleted successfully"})
+}
+
+// AddBoardColumn handles POST /api/v1/boards/:id/columns
+func AddBoardColumn(c *gin.Context) {
+	userID, boardID, ok := boardParams(c)
+	if !ok {
+		return
+	}
+
+	var input services.BoardColumnInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	column, err := services.NewBoardService(config.GetDB()).AddColumn(userID, boardID, input)
+	if err != nil {
+		writeBoardError(c, err, "Failed to add column")
+		return
+	}
+
+	services.GetMessagesHub().BroadcastBoard(boardID, "board.column_added", column)
+	c.JSON(http.StatusCreated, column)
+}
+
+// UpdateBoardColumn handles PUT /api/v1/boards/:id/columns/:column_id
+func UpdateBoardColumn(c *gin.Context) {
+	userID, boardID, ok := boardParams(c)
+	if !ok {
+		return
+	}
+	columnID, ok := boardColumnParam(c)
+	if !ok {
+		return
+	}
+
+	var input services.BoardColumnInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	column, err := services.NewBoardService(config.GetDB()).UpdateColumn(userID, boardID, columnID, input)
+	if err != nil {
+		writeBoardError(c, err, "Failed to update column")
+		return
+	}
+
+	services.GetMessagesHub().BroadcastBoard(boardID, "board.column_updated", column)
+	c.JSON(http.StatusOK, column)
+}
+
+// DeleteBoardColumn handles DELETE /api/v1/boards/:id/columns/:column_id
+func DeleteBoardColumn(c *gin.Context) {
+	userID, boardID, ok := boardParams(c)
+	if !ok {
+		return
+	}
+	columnID, ok := boardColumnParam(c)
+	if !ok {
+		return
+	}
+
+	if err := services.NewBoardService(config.GetDB()).DeleteColumn(userID, boardID, columnID); err != nil {
+		writeBoardError(c, err, "Failed to delete column")
+		return
+	}
+
+	services.GetMessagesHub().BroadcastBoard(boardID, "board.column_deleted", gin.H{"column_id": columnID})
+	c.JSON(http.StatusOK, gin.H{"message": "Column deleted successfully"})
+}
+
+// ReorderBoardColumns handles PUT /api/v1/boards/:id/columns/order
+func ReorderBoardColumns(c *gin.Context) {
+	userID, boardID, ok := boardParams(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ColumnIDs []uint `json:"column_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	board, err := services.NewBoardService(config.GetDB()).ReorderColumns(userID, boardID, req.ColumnIDs)
+	if err != nil {
+		writeBoardError(c, err, "Failed to reorder columns")
+		return
+	}
+
+	services.GetMessagesHub().BroadcastBoard(boardID, "board.updated", board)
+	c.JSON(http.StatusOK, board)
+}
+
+// MoveBoardCard handles POST /api/v1/boards/:id/cards/:task_id/move. The
+// task's column, position, status and swimlane change together, and viewers
+// of the board get a board.card_moved event.
+func MoveBoardCard(c *gin.Context) {
+	userID, boardID, ok := boardParams(c)
+	if !ok {
+		return
+	}
+	taskID, err := strconv.ParseUint(c.Param("task_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var input services.MoveCardInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := services.NewBoardService(config.GetDB()).Move(userID, boardID, uint(taskID), input, time.Now())
+	if err != nil {
+		writeBoardError(c, err, "Failed to move card")
+		return
+	}
+
+	services.GetMessagesHub().BroadcastBoard(boardID, "board.card_moved", result)
+	c.JSON(http.StatusOK, result)
+}
+
+func boardParams(c *gin.Context) (uint, uint, bool) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return 0, 0, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid board ID"})
+		return 0, 0, false
+	}
+	return userID, uint(id), true
+}
+
+func boardColumnParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("column_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid column ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func writeBoardError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Board not found"})
+	case errors.Is(err, services.ErrBoardForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidBoard), errors.Is(err, services.ErrTaskNotOnBoard):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrWIPLimitReached), errors.Is(err, services.ErrTaskBlocked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"os"
@@ -67,8 +68,14 @@ func GetBookmarks(c *gin.Context) {
 		return
 	}
 
+	// ?tag= also matches bookmarks tagged below the given tag
+	query, ok := filterByTag(c, db, db.Where("user_id = ?", userID), userID, "bookmark_tags", "bookmark_id")
+	if !ok {
+		return
+	}
+
 	// Preload tags for the bookmarks
-	if err := db.Where("user_id = ?", userID).Preload("Tags").Find(&bookmarks).Error; err != nil {
+	if err := query.Preload("Tags").Find(&bookmarks).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmarks"})
 		return
 	}
@@ -94,6 +101,24 @@ func CreateBookmark(c *gin.Context) {
 	}
 	bookmark.UserID = userID
 
+	// Bookmarks that point at the same page are reported with the new one;
+	// ?reject_duplicates=true refuses to create it instead
+	dedupService := services.NewBookmarkDedupService(db)
+	bookmark.CanonicalKey = dedupService.CanonicalKey(userID, bookmark.URL)
+	duplicates, err := dedupService.FindDuplicates(userID, bookmark.CanonicalKey, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for duplicates"})
+		return
+	}
+	if len(duplicates) > 0 && c.Query("reject_duplicates") == "true" {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":         "Bookmark already exists",
+			"canonical_key": bookmark.CanonicalKey,
+			"duplicates":    duplicates,
+		})
+		return
+	}
+
 	// Fetch website metadata if URL is provided
 	if bookmark.URL != "" {
 		// Use basic metadata fetching
@@ -126,9 +151,34 @@ func CreateBookmark(c *gin.Context) {
 		return
 	}
 
+	// Extract the reader-mode article in the background unless the client sent content
+	if bookmark.URL != "" && bookmark.Content == "" {
+		go func(saved models.Bookmark) {
+			if _, err := services.NewBookmarkContentService(db).ExtractBookmarkContent(context.Background(), &saved); err != nil {
+				log.Printf("Failed to extract content for bookmark %d: %v", saved.ID, err)
+			}
+		}(bookmark)
+	}
+
+	// Archive an offline copy of the page when requested
+	if c.Query("snapshot") == "true" && bookmark.URL != "" {
+		if _, err := newBookmarkSnapshotService().StartSnapshot(&bookmark, c.Query("warc") == "true"); err != nil {
+			log.Printf("Failed to start snapshot for bookmark %d: %v", bookmark.ID, err)
+		}
+	}
+
 	// Preload tags for response
 	db.Preload("Tags").First(&bookmark, bookmark.ID)
 
+	if len(duplicates) > 0 {
+		c.JSON(http.StatusCreated, struct {
+			models.Bookmark
+			Warning    string            `json:"warning"`
+			Duplicates []models.Bookmark `json:"duplicates"`
+		}{bookmark, "Bookmark already exists", duplicates})
+		return
+	}
+
 	c.JSON(http.StatusCreated, bookmark)
 }
 
@@ -186,12 +236,34 @@ func UpdateBookmark(c *gin.Context) {
 		return
 	}
 
+	// Keep the canonical key in sync with the URL
+	updateData.CanonicalKey = ""
+	if updateData.URL != "" && updateData.URL != bookmark.URL {
+		updateData.CanonicalKey = services.NewBookmarkDedupService(db).CanonicalKey(userID, updateData.URL)
+	}
+
 	// Update bookmark
+	previousContent := bookmark.Content
+	wasRead := bookmark.IsRead
 	if err := db.Model(&bookmark).Updates(updateData).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update bookmark"})
 		return
 	}
 
+	// Edited content moves the highlights anchored in it
+	if updateData.Content != "" && updateData.Content != previousContent {
+		if _, err := services.NewBookmarkHighlightService(db).Reanchor(bookmark.ID, updateData.Content); err != nil {
+			log.Printf("Failed to re-anchor highlights for bookmark %d: %v", bookmark.ID, err)
+		}
+	}
+
+	// Reading it sets ReadAt, leaves the reading queue and counts in analytics
+	if updateData.IsRead && !wasRead {
+		if _, err := services.NewReadingQueueService(db).MarkRead(userID, bookmark.ID, 0, time.Now()); err != nil {
+			log.Printf("Failed to record read of bookmark %d: %v", bookmark.ID, err)
+		}
+	}
+
 	// Get updated bookmark with tags
 	db.Preload("Tags").First(&bookmark, bookmark.ID)
 
@@ -271,6 +343,11 @@ func RefreshBookmarkMetadata(c *gin.Context) {
 			return
 		}
 
+		// Re-extract the article; the metadata refresh still succeeds if this fails
+		if _, err := services.NewBookmarkContentService(db).ExtractBookmarkContent(c.Request.Context(), &bookmark); err != nil {
+			log.Printf("Failed to extract content for bookmark %d: %v", bookmark.ID, err)
+		}
+
 		// Get updated bookmark with tags
 		db.Preload("Tags").First(&bookmark, bookmark.ID)
 
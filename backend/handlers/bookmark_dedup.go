@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
+	"gorm.io/gorm"
+)
+
+// GetDuplicateBookmarks handles GET /api/v1/bookmarks/duplicates
+// With a url query parameter it returns the bookmarks that URL would duplicate.
+func GetDuplicateBookmarks(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	dedupService := services.NewBookmarkDedupService(config.GetDB())
+
+	if rawURL := c.Query("url"); rawURL != "" {
+		key := dedupService.CanonicalKey(userID, rawURL)
+		duplicates, err := dedupService.FindDuplicates(userID, key, 0)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check for duplicates"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"canonical_key": key,
+			"duplicates":    duplicates,
+		})
+		return
+	}
+
+	groups, err := dedupService.DuplicateGroups(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch duplicate bookmarks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"groups": groups})
+}
+
+// MergeBookmarks handles POST /api/v1/bookmarks/merge
+func MergeBookmarks(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var request struct {
+		PrimaryID    uint   `json:"primary_id" binding:"required"`
+		DuplicateIDs []uint `json:"duplicate_ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	bookmark, err := services.NewBookmarkDedupService(config.GetDB()).Merge(userID, request.PrimaryID, request.DuplicateIDs)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge bookmarks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Bookmarks merged successfully",
+		"bookmark": bookmark,
+	})
+}
+
+// canonicalRuleRequest is the payload for creating or updating a canonical URL rule
+type canonicalRuleRequest struct {
+	Domain       string   `json:"domain" binding:"required"`
+	KeepFragment bool     `json:"keep_fragment"`
+	DropQuery    bool     `json:"drop_query"`
+	KeepParams   []string `json:"keep_params"`
+	StripParams  []string `json:"strip_params"`
+}
+
+// normalizeRuleDomain reduces user input such as "https://www.Example.com/" to "example.com"
+func normalizeRuleDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if i := strings.Index(domain, "://"); i >= 0 {
+		domain = domain[i+3:]
+	}
+	if i := strings.IndexAny(domain, "/?#"); i >= 0 {
+		domain = domain[:i]
+	}
+	return strings.TrimPrefix(strings.TrimSuffix(domain, "."), "www.")
+}
+
+// GetCanonicalURLRules handles GET /api/v1/bookmarks/canonical-rules
+func GetCanonicalURLRules(c *gin.Context) {
+	db := config.GetDB()
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var rules []models.CanonicalURLRule
+	if err := db.Where("user_id = ?", userID).Order("domain ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"rules":    rules,
+		"defaults": services.DefaultCanonicalRules,
+	})
+}
+
+// CreateCanonicalURLRule handles POST /api/v1/bookmarks/canonical-rules
+func CreateCanonicalURLRule(c *gin.Context) {
+	db := config.GetDB()
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var request canonicalRuleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := models.CanonicalURLRule{
+		UserID:       userID,
+		Domain:       normalizeRuleDomain(request.Domain),
+		KeepFragment: request.KeepFragment,
+		DropQuery:    request.DropQuery,
+		KeepParams:   request.KeepParams,
+		StripParams:  request.StripParams,
+	}
+	if rule.Domain == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain"})
+		return
+	}
+
+	var count int64
+	db.Model(&models.CanonicalURLRule{}).Where("user_id = ? AND domain = ?", userID, rule.Domain).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A rule for this domain already exists"})
+		return
+	}
+
+	if err := db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create rule"})
+		return
+	}
+
+	updated, _ := services.NewBookmarkDedupService(db).RecomputeKeys(userID, false)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"rule":              rule,
+		"bookmarks_updated": updated,
+	})
+}
+
+// UpdateCanonicalURLRule handles PUT /api/v1/bookmarks/canonical-rules/:id
+func UpdateCanonicalURLRule(c *gin.Context) {
+	db := config.GetDB()
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var rule models.CanonicalURLRule
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&rule).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+		return
+	}
+
+	var request canonicalRuleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule.Domain = normalizeRuleDomain(request.Domain)
+	rule.KeepFragment = request.KeepFragment
+	rule.DropQuery = request.DropQuery
+	rule.KeepParams = request.KeepParams
+	rule.StripParams = request.StripParams
+	if rule.Domain == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid domain"})
+		return
+	}
+
+	var count int64
+	db.Model(&models.CanonicalURLRule{}).Where("user_id = ? AND domain = ? AND id <> ?", userID, rule.Domain, rule.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A rule for this domain already exists"})
+		return
+	}
+
+	if err := db.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rule"})
+		return
+	}
+
+	updated, _ := services.NewBookmarkDedupService(db).RecomputeKeys(userID, false)
+
+	c.JSON(http.StatusOK, gin.H{
+		"rule":              rule,
+		"bookmarks_updated": updated,
+	})
+}
+
+// DeleteCanonicalURLRule handles DELETE /api/v1/bookmarks/canonical-rules/:id
+func DeleteCanonicalURLRule(c *gin.Context) {
+	db := config.GetDB()
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var rule models.CanonicalURLRule
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&rule).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+		return
+	}
+
+	// Hard delete so the domain can be configured again
+	if err := db.Unscoped().Delete(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rule"})
+		return
+	}
+
+	updated, _ := services.NewBookmarkDedupService(db).RecomputeKeys(userID, false)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":           "Rule deleted successfully",
+		"bookmarks_updated": updated,
+	})
+}
@@ -0,0 +1,168 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
+)
+
+// GetBookmarksHealth handles GET /api/v1/bookmarks/health
+func GetBookmarksHealth(c *gin.Context) {
+	db := config.GetDB()
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit := 50
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 200 {
+			limit = parsed
+		}
+	}
+	offset := 0
+	if o := c.Query("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil && parsed >= 0 {
+			offset = parsed
+		}
+	}
+
+	query := db.Model(&models.Bookmark{}).Where("user_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		if status == "unchecked" {
+			query = query.Where("link_checked_at IS NULL")
+		} else {
+			query = query.Where("link_status = ?", status)
+		}
+	} else {
+		// By default only list bookmarks that need attention
+		query = query.Where("link_status IN ?", []models.LinkStatus{
+			models.LinkStatusBroken,
+			models.LinkStatusMoved,
+			models.LinkStatusRedirected,
+			models.LinkStatusError,
+		})
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmark health"})
+		return
+	}
+
+	var bookmarks []models.Bookmark
+	if err := query.Order("link_checked_at DESC").Limit(limit).Offset(offset).Find(&bookmarks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmark health"})
+		return
+	}
+
+	// Count bookmarks per link status for a summary
+	var rows []struct {
+		LinkStatus models.LinkStatus
+		Count      int64
+	}
+	if err := db.Model(&models.Bookmark{}).Select("link_status, COUNT(*) AS count").
+		Where("user_id = ?", userID).Group("link_status").Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmark health"})
+		return
+	}
+	summary := gin.H{}
+	for _, row := range rows {
+		key := string(row.LinkStatus)
+		if row.LinkStatus == models.LinkStatusUnchecked {
+			key = "unchecked"
+		}
+		summary[key] = row.Count
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bookmarks": bookmarks,
+		"summary":   summary,
+		"total":     total,
+		"limit":     limit,
+		"offset":    offset,
+	})
+}
+
+// GetBookmarkHealth handles GET /api/v1/bookmarks/:id/health
+func GetBookmarkHealth(c *gin.Context) {
+	db := config.GetDB()
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var bookmark models.Bookmark
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&bookmark).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+		return
+	}
+
+	var history []models.BookmarkLinkCheck
+	if err := db.Where("bookmark_id = ?", bookmark.ID).Order("checked_at DESC").Find(&history).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch link history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bookmark_id":        bookmark.ID,
+		"url":                bookmark.URL,
+		"link_status":        bookmark.LinkStatus,
+		"link_status_code":   bookmark.LinkStatusCode,
+		"redirect_url":       bookmark.RedirectURL,
+		"link_failure_count": bookmark.LinkFailureCount,
+		"link_checked_at":    bookmark.LinkCheckedAt,
+		"history":            history,
+	})
+}
+
+// CheckBookmarkLink handles POST /api/v1/bookmarks/:id/check
+func CheckBookmarkLink(c *gin.Context) {
+	db := config.GetDB()
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var bookmark models.Bookmark
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&bookmark).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	checker := services.NewLinkChecker(db, services.LinkCheckerOptions{})
+	check, err := checker.CheckBookmark(ctx, &bookmark)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check link"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"bookmark": bookmark,
+		"check":    check,
+	})
+}
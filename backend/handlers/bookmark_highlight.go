@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
+	"gorm.io/gorm"
+)
+
+// highlightRequest is the payload for creating a highlight
+type highlightRequest struct {
+	Exact       string `json:"exact" binding:"required"`
+	Prefix      string `json:"prefix"`
+	Suffix      string `json:"suffix"`
+	StartOffset int    `json:"start_offset"`
+	EndOffset   int    `json:"end_offset"`
+	Note        string `json:"note"`
+	Color       string `json:"color"`
+	PageURL     string `json:"page_url"`
+}
+
+func (r highlightRequest) input() services.HighlightInput {
+	return services.HighlightInput{
+		Exact:       r.Exact,
+		Prefix:      r.Prefix,
+		Suffix:      r.Suffix,
+		StartOffset: r.StartOffset,
+		EndOffset:   r.EndOffset,
+		Note:        r.Note,
+		Color:       r.Color,
+		PageURL:     r.PageURL,
+	}
+}
+
+// GetBookmarkHighlights handles GET /api/v1/bookmarks/:id/highlights
+func GetBookmarkHighlights(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	highlights, err := services.NewBookmarkHighlightService(config.GetDB()).List(userID, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch highlights"})
+		return
+	}
+
+	c.JSON(http.StatusOK, highlights)
+}
+
+// CreateBookmarkHighlight handles POST /api/v1/bookmarks/:id/highlights
+func CreateBookmarkHighlight(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var request highlightRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	highlight, err := services.NewBookmarkHighlightService(config.GetDB()).Create(userID, uint(id), request.input())
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+		case errors.Is(err, services.ErrEmptyHighlight):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create highlight"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, highlight)
+}
+
+// findBookmarkHighlight loads the highlight named in the URL, writing the
+// error response itself when it cannot
+func findBookmarkHighlight(c *gin.Context) (*models.BookmarkHighlight, bool) {
+	bookmarkID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return nil, false
+	}
+	highlightID, err := strconv.ParseUint(c.Param("highlightId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid highlight ID"})
+		return nil, false
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	var highlight models.BookmarkHighlight
+	if err := config.GetDB().Where("id = ? AND bookmark_id = ? AND user_id = ?", highlightID, bookmarkID, userID).
+		First(&highlight).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Highlight not found"})
+		return nil, false
+	}
+
+	return &highlight, true
+}
+
+// UpdateBookmarkHighlight handles PUT /api/v1/bookmarks/:id/highlights/:highlightId
+// Only the note and color can change; the anchor is fixed once created.
+func UpdateBookmarkHighlight(c *gin.Context) {
+	highlight, ok := findBookmarkHighlight(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Note  *string `json:"note"`
+		Color *string `json:"color"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if request.Note != nil {
+		updates["note"] = *request.Note
+	}
+	if request.Color != nil {
+		color := strings.TrimSpace(*request.Color)
+		if color == "" {
+			color = "yellow"
+		}
+		updates["color"] = color
+	}
+
+	if len(updates) > 0 {
+		if err := config.GetDB().Model(highlight).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update highlight"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, highlight)
+}
+
+// DeleteBookmarkHighlight handles DELETE /api/v1/bookmarks/:id/highlights/:highlightId
+func DeleteBookmarkHighlight(c *gin.Context) {
+	highlight, ok := findBookmarkHighlight(c)
+	if !ok {
+		return
+	}
+
+	if err := config.GetDB().Delete(highlight).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete highlight"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Highlight deleted successfully"})
+}
+
+// ExportBookmarkHighlights handles GET /api/v1/bookmarks/:id/highlights/export
+func ExportBookmarkHighlights(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	data, err := services.NewBookmarkHighlightService(config.GetDB()).ExportBookmark(userID, uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export highlights"})
+		return
+	}
+
+	writeHighlightsExport(c, fmt.Sprintf("bookmark-%d", id), data)
+}
+
+// ExportTagHighlights handles GET /api/v1/bookmarks/highlights/export?tag=name
+func ExportTagHighlights(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	tag := strings.TrimSpace(c.Query("tag"))
+	if tag == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tag is required"})
+		return
+	}
+
+	data, err := services.NewBookmarkHighlightService(config.GetDB()).ExportTag(userID, tag)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export highlights"})
+		return
+	}
+
+	slug := generateSlug(tag)
+	if slug == "" {
+		slug = "export"
+	}
+	writeHighlightsExport(c, "tag-"+slug, data)
+}
+
+func writeHighlightsExport(c *gin.Context, name string, data []byte) {
+	filename := fmt.Sprintf("trackeep-highlights-%s-%s.md", name, time.Now().Format("2006-01-02"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", data)
+}
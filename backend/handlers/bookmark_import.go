@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
+)
+
+// maxBookmarkImportSize limits uploaded bookmark export files
+const maxBookmarkImportSize = 50 << 20 // 50MB
+
+// ImportBookmarks handles POST /api/v1/bookmarks/import
+func ImportBookmarks(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	if fileHeader.Size > maxBookmarkImportSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Import file is too large"})
+		return
+	}
+
+	format := c.DefaultPostForm("format", services.ImportFormatNetscape)
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	items, err := services.ParseBookmarkExport(format, file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No bookmarks found in file"})
+		return
+	}
+
+	importService := services.NewBookmarkImportService(config.GetDB())
+	job, err := importService.StartImport(userID, format, fileHeader.Filename, items)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start import"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetBookmarkImports handles GET /api/v1/bookmarks/imports
+func GetBookmarkImports(c *gin.Context) {
+	db := config.GetDB()
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit := 20
+	if l := c.Query("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil && parsed > 0 && parsed <= 100 {
+			limit = parsed
+		}
+	}
+
+	var imports []models.BookmarkImport
+	if err := db.Where("user_id = ?", userID).Order("created_at DESC").Limit(limit).Find(&imports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch imports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"imports": imports,
+		"limit":   limit,
+	})
+}
+
+// GetBookmarkImport handles GET /api/v1/bookmarks/imports/:id
+func GetBookmarkImport(c *gin.Context) {
+	db := config.GetDB()
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import ID"})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var job models.BookmarkImport
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&job).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// ExportBookmarks handles GET /api/v1/bookmarks/export
+func ExportBookmarks(c *gin.Context) {
+	db := config.GetDB()
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var bookmarks []models.Bookmark
+	if err := db.Where("user_id = ?", userID).Preload("Tags").Order("created_at ASC").Find(&bookmarks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bookmarks"})
+		return
+	}
+
+	filename := fmt.Sprintf("trackeep-bookmarks-%s.html", time.Now().Format("2006-01-02"))
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Status(http.StatusOK)
+
+	if err := services.WriteNetscapeBookmarks(c.Writer, bookmarks); err != nil {
+		c.Error(err)
+	}
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
+)
+
+// snapshotStorageDir is where page snapshots are stored
+var snapshotStorageDir = filepath.Join("uploads", "snapshots")
+
+// snapshotViewerPolicy sandboxes archived pages: no scripts, no network
+// access, only inlined styles, images, fonts and media
+const snapshotViewerPolicy = "sandbox allow-popups allow-popups-to-escape-sandbox; default-src 'none'; " +
+	"img-src data:; style-src 'unsafe-inline' data:; font-src data:; media-src data:"
+
+func newBookmarkSnapshotService() *services.BookmarkSnapshotService {
+	return services.NewBookmarkSnapshotService(config.GetDB(), snapshotStorageDir)
+}
+
+// CreateBookmarkSnapshot handles POST /api/v1/bookmarks/:id/snapshots
+func CreateBookmarkSnapshot(c *gin.Context) {
+	db := config.GetDB()
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var request struct {
+		IncludeWARC bool `json:"include_warc"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var bookmark models.Bookmark
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&bookmark).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+		return
+	}
+
+	snapshot, err := newBookmarkSnapshotService().StartSnapshot(&bookmark, request.IncludeWARC)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start snapshot"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, snapshot)
+}
+
+// GetBookmarkSnapshots handles GET /api/v1/bookmarks/:id/snapshots
+func GetBookmarkSnapshots(c *gin.Context) {
+	db := config.GetDB()
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var snapshots []models.BookmarkSnapshot
+	if err := db.Where("bookmark_id = ? AND user_id = ?", id, userID).Order("version DESC").Find(&snapshots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch snapshots"})
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshots)
+}
+
+// GetBookmarkSnapshot handles GET /api/v1/bookmarks/:id/snapshots/:snapshotId
+func GetBookmarkSnapshot(c *gin.Context) {
+	snapshot, ok := findBookmarkSnapshot(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
+
+// DownloadBookmarkSnapshot handles GET /api/v1/bookmarks/:id/snapshots/:snapshotId/download
+func DownloadBookmarkSnapshot(c *gin.Context) {
+	snapshot, ok := findBookmarkSnapshot(c)
+	if !ok {
+		return
+	}
+	if snapshot.Status != models.BookmarkSnapshotCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Snapshot is not ready"})
+		return
+	}
+
+	path, contentType, ext := snapshot.FilePath, "text/html; charset=utf-8", "html"
+	if c.Query("format") == "warc" {
+		if snapshot.WARCPath == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Snapshot has no WARC record"})
+			return
+		}
+		path, contentType, ext = snapshot.WARCPath, "application/warc", "warc.gz"
+	}
+
+	filename := fmt.Sprintf("bookmark-%d-snapshot-v%d.%s", snapshot.BookmarkID, snapshot.Version, ext)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.File(path)
+}
+
+// ViewBookmarkSnapshot handles GET /api/v1/bookmarks/:id/snapshots/:snapshotId/view
+func ViewBookmarkSnapshot(c *gin.Context) {
+	snapshot, ok := findBookmarkSnapshot(c)
+	if !ok {
+		return
+	}
+	if snapshot.Status != models.BookmarkSnapshotCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Snapshot is not ready"})
+		return
+	}
+
+	c.Header("Content-Security-Policy", snapshotViewerPolicy)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.File(snapshot.FilePath)
+}
+
+// DeleteBookmarkSnapshot handles DELETE /api/v1/bookmarks/:id/snapshots/:snapshotId
+func DeleteBookmarkSnapshot(c *gin.Context) {
+	snapshot, ok := findBookmarkSnapshot(c)
+	if !ok {
+		return
+	}
+
+	if err := newBookmarkSnapshotService().DeleteSnapshot(snapshot); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete snapshot"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Snapshot deleted successfully"})
+}
+
+// findBookmarkSnapshot loads the snapshot named in the route for the current
+// user, writing an error response when it cannot be found
+func findBookmarkSnapshot(c *gin.Context) (*models.BookmarkSnapshot, bool) {
+	db := config.GetDB()
+	bookmarkID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return nil, false
+	}
+	snapshotID, err := strconv.ParseUint(c.Param("snapshotId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid snapshot ID"})
+		return nil, false
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	var snapshot models.BookmarkSnapshot
+	if err := db.Where("id = ? AND bookmark_id = ? AND user_id = ?", snapshotID, bookmarkID, userID).First(&snapshot).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snapshot not found"})
+		return nil, false
+	}
+
+	return &snapshot, true
+}
@@ -2,9 +2,12 @@ package handlers
 
 import (
 	"archive/zip"
+	"context"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
@@ -14,6 +17,7 @@ import (
 
 	"github.com/trackeep/backend/config"
 	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
 )
 
 // CreateAPIKeyRequest represents a request to create an API key
@@ -51,15 +55,16 @@ func GenerateAPIKey(c *gin.Context) {
 
 	// Validate permissions
 	validPermissions := map[string]bool{
-		"bookmarks:read":  true,
-		"bookmarks:write": true,
-		"files:read":      true,
-		"files:write":     true,
-		"files:share":     true,
-		"notes:read":      true,
-		"notes:write":     true,
-		"tasks:read":      true,
-		"tasks:write":     true,
+		"bookmarks:read":   true,
+		"bookmarks:write":  true,
+		"highlights:write": true,
+		"files:read":       true,
+		"files:write":      true,
+		"files:share":      true,
+		"notes:read":       true,
+		"notes:write":      true,
+		"tasks:read":       true,
+		"tasks:write":      true,
 	}
 
 	for _, perm := range req.Permissions {
@@ -272,8 +277,71 @@ func RegisterBrowserExtension(c *gin.Context) {
 	}
 
 	c.JSON(201, gin.H{
-		"message":      "Extension registered successfully",
-		"extension_id": extAuth.ExtensionID,
+		"message":        "Extension registered successfully",
+		"extension_id":   extAuth.ExtensionID,
+		"highlights_url": "/api/v1/browser-extension/highlights",
+	})
+}
+
+// PushExtensionHighlights stores highlights made on a live page by a
+// registered extension. The page is saved as a bookmark if needed.
+func PushExtensionHighlights(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(401, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	currentUser := user.(models.User)
+
+	var req struct {
+		ExtensionID string             `json:"extension_id" binding:"required"`
+		URL         string             `json:"url" binding:"required"`
+		Title       string             `json:"title"`
+		Highlights  []highlightRequest `json:"highlights" binding:"required,min=1,dive"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.GetDB()
+	var extAuth models.BrowserExtension
+	if err := db.Where("user_id = ? AND extension_id = ? AND is_active = ?", currentUser.ID, req.ExtensionID, true).First(&extAuth).Error; err != nil {
+		c.JSON(403, gin.H{"error": "Extension not registered"})
+		return
+	}
+	db.Model(&extAuth).Update("last_seen", time.Now())
+
+	inputs := make([]services.HighlightInput, 0, len(req.Highlights))
+	for _, highlight := range req.Highlights {
+		inputs = append(inputs, highlight.input())
+	}
+
+	bookmark, highlights, created, err := services.NewBookmarkHighlightService(db).PushPageHighlights(currentUser.ID, req.URL, req.Title, inputs)
+	if err != nil {
+		if errors.Is(err, services.ErrEmptyHighlight) {
+			c.JSON(400, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(500, gin.H{"error": "Failed to save highlights"})
+		return
+	}
+
+	// Pull the article so the highlights can be anchored in reader mode
+	if created {
+		go func(saved models.Bookmark) {
+			if _, err := services.NewBookmarkContentService(db).ExtractBookmarkContent(context.Background(), &saved); err != nil {
+				log.Printf("Failed to extract content for bookmark %d: %v", saved.ID, err)
+			}
+		}(*bookmark)
+	}
+
+	c.JSON(201, gin.H{
+		"bookmark":         bookmark,
+		"bookmark_created": created,
+		"highlights":       highlights,
 	})
 }
 
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/middleware"
+	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
+)
+
+// bulkApplyFunc runs a bulk request against one resource
+type bulkApplyFunc func(s *services.BulkService, userID uint, req services.BulkRequest, audit *models.AuditLog) (*services.BulkResult, error)
+
+// BulkUpdateBookmarks handles POST /api/v1/bookmarks/bulk
+func BulkUpdateBookmarks(c *gin.Context) {
+	runBulkRequest(c, (*services.BulkService).Bookmarks)
+}
+
+// BulkUpdateTasks handles POST /api/v1/tasks/bulk
+func BulkUpdateTasks(c *gin.Context) {
+	runBulkRequest(c, (*services.BulkService).Tasks)
+}
+
+// BulkUpdateNotes handles POST /api/v1/notes/bulk
+func BulkUpdateNotes(c *gin.Context) {
+	runBulkRequest(c, (*services.BulkService).Notes)
+}
+
+// BulkUpdateFiles handles POST /api/v1/files/bulk
+func BulkUpdateFiles(c *gin.Context) {
+	runBulkRequest(c, (*services.BulkService).Files)
+}
+
+// runBulkRequest binds the request, applies it and records one audit entry
+// for the whole batch in place of the per-request entry
+func runBulkRequest(c *gin.Context, apply bulkApplyFunc) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var request services.BulkRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	audit := middleware.NewRequestAuditLog(c)
+	result, err := apply(services.NewBulkService(config.GetDB()), userID, request, audit)
+	if err != nil {
+		if errors.Is(err, services.ErrUnsupportedBulkAction) || errors.Is(err, services.ErrInvalidBulkRequest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply bulk action"})
+		return
+	}
+	middleware.MarkAuditRecorded(c)
+
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/services"
+	"gorm.io/gorm"
+)
+
+// handleCollabEvent handles the collaborative editing messages of the
+// messages websocket. Each names a document with doc_type ("note" or
+// "wiki") and doc_id:
+//
+//   - collab.join with an optional client_id and, when reconnecting, the
+//     last version seen
+//   - collab.operation with the version it was made on and an ot.js
+//     operation
+//   - collab.cursor with a cursor of anchor and head
+//   - collab.leave
+//
+// Failures come back as collab.error; code "stale" means the client should
+// join again.
+func handleCollabEvent(hub *services.MessagesHub, client *services.MessagesWSClient, eventType string, incoming map[string]interface{}) {
+	docType, _ := incoming["doc_type"].(string)
+	docID := parseUintAny(incoming["doc_id"])
+	document := services.CollabDocumentKey(docType, docID)
+
+	collab := services.GetCollabService()
+	if collab == nil {
+		hub.SendToClient(client, document, "collab.error", gin.H{"code": "unavailable", "error": "Collaborative editing is not available"})
+		return
+	}
+
+	var err error
+	switch eventType {
+	case "collab.join":
+		clientID, _ := incoming["client_id"].(string)
+		var since *int
+		if version, ok := incoming["version"].(float64); ok {
+			v := int(version)
+			since = &v
+		}
+		err = collab.Join(client, docType, docID, clientID, since)
+	case "collab.operation":
+		version, ok := incoming["version"].(float64)
+		if !ok {
+			err = services.ErrInvalidOperation
+			break
+		}
+		var op services.TextOperation
+		if err = remarshal(incoming["operation"], &op); err == nil {
+			err = collab.Apply(client, docType, docID, int(version), &op)
+		}
+	case "collab.cursor":
+		var cursor services.CollabCursor
+		if err = remarshal(incoming["cursor"], &cursor); err == nil {
+			err = collab.Cursor(client, docType, docID, cursor)
+		}
+	case "collab.leave":
+		err = collab.Leave(client, docType, docID)
+	}
+
+	if err != nil {
+		code, message := collabErrorCode(err)
+		hub.SendToClient(client, document, "collab.error", gin.H{"code": code, "error": message, "request": eventType})
+	}
+}
+
+// remarshal decodes a field of a websocket message into a typed value
+func remarshal(value interface{}, target interface{}) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw, target); err != nil {
+		return services.ErrInvalidOperation
+	}
+	return nil
+}
+
+func collabErrorCode(err error) (string, string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return "not_found", "Document not found"
+	case errors.Is(err, services.ErrCollabStale):
+		return "stale", err.Error()
+	case errors.Is(err, services.ErrCollabForbidden), errors.Is(err, services.ErrCollabEncrypted):
+		return "forbidden", err.Error()
+	case errors.Is(err, services.ErrCollabNotJoined):
+		return "not_joined", err.Error()
+	case errors.Is(err, services.ErrCollabDocument), errors.Is(err, services.ErrInvalidOperation):
+		return "invalid", err.Error()
+	default:
+		log.Printf("Collaborative editing failed: %v", err)
+		return "internal", "Failed to process the edit"
+	}
+}
+
+// notifyCollabEdit passes content saved through the API on to anyone
+// editing the document live
+func notifyCollabEdit(docType string, docID uint, content string, userID uint) {
+	collab := services.GetCollabService()
+	if collab == nil {
+		return
+	}
+	if err := collab.ExternalEdit(docType, docID, content, userID); err != nil {
+		log.Printf("Failed to pass edit of %s on to live editors: %v", services.CollabDocumentKey(docType, docID), err)
+	}
+}
@@ -0,0 +1,522 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
+	"gorm.io/gorm"
+)
+
+// collectionRequest is the payload for creating or updating a collection
+type collectionRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	CoverImage  *string `json:"cover_image"`
+	Color       *string `json:"color"`
+	ParentID    *uint   `json:"parent_id"`
+}
+
+// parseCollectionID reads the :id parameter, writing a 400 response when invalid
+func parseCollectionID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// GetCollections handles GET /api/v1/collections
+func GetCollections(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	collections, err := services.NewCollectionService(config.GetDB()).Tree(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collections"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collections": collections})
+}
+
+// CreateCollection handles POST /api/v1/collections
+func CreateCollection(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var request collectionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if request.Name == nil || strings.TrimSpace(*request.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Collection name is required"})
+		return
+	}
+
+	collection := models.Collection{
+		UserID:   userID,
+		ParentID: request.ParentID,
+		Name:     strings.TrimSpace(*request.Name),
+	}
+	if request.Description != nil {
+		collection.Description = *request.Description
+	}
+	if request.CoverImage != nil {
+		collection.CoverImage = *request.CoverImage
+	}
+	if request.Color != nil {
+		collection.Color = *request.Color
+	}
+
+	if err := services.NewCollectionService(config.GetDB()).Create(&collection); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parent collection not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create collection"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, collection)
+}
+
+// GetCollection handles GET /api/v1/collections/:id
+func GetCollection(c *gin.Context) {
+	id, ok := parseCollectionID(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	collectionService := services.NewCollectionService(config.GetDB())
+	collection, err := collectionService.Get(userID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+
+	entries, err := collectionService.Bookmarks(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collection bookmarks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"collection": collection,
+		"bookmarks":  entries,
+	})
+}
+
+// UpdateCollection handles PUT /api/v1/collections/:id
+// Only the fields present in the request are changed; use the move endpoint
+// to change the parent.
+func UpdateCollection(c *gin.Context) {
+	db := config.GetDB()
+	id, ok := parseCollectionID(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var collection models.Collection
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&collection).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+
+	var request collectionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if request.Name != nil {
+		name := strings.TrimSpace(*request.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Collection name is required"})
+			return
+		}
+		updates["name"] = name
+	}
+	if request.Description != nil {
+		updates["description"] = *request.Description
+	}
+	if request.CoverImage != nil {
+		updates["cover_image"] = *request.CoverImage
+	}
+	if request.Color != nil {
+		updates["color"] = *request.Color
+	}
+
+	if len(updates) > 0 {
+		if err := db.Model(&collection).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update collection"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, collection)
+}
+
+// MoveCollection handles POST /api/v1/collections/:id/move
+// A null parent_id moves the collection to the top level.
+func MoveCollection(c *gin.Context) {
+	id, ok := parseCollectionID(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var request struct {
+		ParentID *uint `json:"parent_id"`
+		Position *int  `json:"position"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Without a position the collection goes to the end
+	position := -1
+	if request.Position != nil {
+		position = *request.Position
+	}
+
+	collectionService := services.NewCollectionService(config.GetDB())
+	if err := collectionService.Move(userID, id, request.ParentID, position); err != nil {
+		switch {
+		case errors.Is(err, services.ErrCollectionCycle):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move collection"})
+		}
+		return
+	}
+
+	collection, err := collectionService.Get(userID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, collection)
+}
+
+// DeleteCollection handles DELETE /api/v1/collections/:id
+// Bookmarks are kept. Sub-collections move up a level unless ?recursive=true.
+func DeleteCollection(c *gin.Context) {
+	id, ok := parseCollectionID(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := services.NewCollectionService(config.GetDB()).Delete(userID, id, c.Query("recursive") == "true"); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete collection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collection deleted successfully"})
+}
+
+// AddCollectionBookmarks handles POST /api/v1/collections/:id/bookmarks
+func AddCollectionBookmarks(c *gin.Context) {
+	id, ok := parseCollectionID(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var request struct {
+		BookmarkIDs []uint `json:"bookmark_ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	added, err := services.NewCollectionService(config.GetDB()).AddBookmarks(userID, id, request.BookmarkIDs)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Collection or bookmark not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add bookmarks to collection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Bookmarks added to collection",
+		"added":   added,
+	})
+}
+
+// RemoveCollectionBookmark handles DELETE /api/v1/collections/:id/bookmarks/:bookmarkId
+func RemoveCollectionBookmark(c *gin.Context) {
+	id, ok := parseCollectionID(c)
+	if !ok {
+		return
+	}
+	bookmarkID, err := strconv.ParseUint(c.Param("bookmarkId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	if err := services.NewCollectionService(config.GetDB()).RemoveBookmark(userID, id, uint(bookmarkID)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found in collection"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove bookmark from collection"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Bookmark removed from collection"})
+}
+
+// ReorderCollectionBookmarks handles PUT /api/v1/collections/:id/bookmarks/order
+func ReorderCollectionBookmarks(c *gin.Context) {
+	id, ok := parseCollectionID(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var request struct {
+		BookmarkIDs []uint `json:"bookmark_ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	collectionService := services.NewCollectionService(config.GetDB())
+	if err := collectionService.ReorderBookmarks(userID, id, request.BookmarkIDs); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Collection or bookmark not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reorder bookmarks"})
+		return
+	}
+
+	entries, err := collectionService.Bookmarks(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collection bookmarks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bookmarks": entries})
+}
+
+// GetBookmarkCollections handles GET /api/v1/bookmarks/:id/collections
+func GetBookmarkCollections(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	collections, err := services.NewCollectionService(config.GetDB()).CollectionsForBookmark(userID, uint(id))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collections"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"collections": collections})
+}
+
+// ShareCollection handles POST /api/v1/collections/:id/share
+// The collection is published through a content share token and served at
+// GET /api/v1/shared/:token.
+func ShareCollection(c *gin.Context) {
+	db := config.GetDB()
+	id, ok := parseCollectionID(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var collection models.Collection
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&collection).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+		return
+	}
+
+	var request struct {
+		Title       string     `json:"title"`
+		Description string     `json:"description"`
+		ExpiresAt   *time.Time `json:"expires_at"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	shareToken, err := generateSecureShareToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate share token"})
+		return
+	}
+
+	title := strings.TrimSpace(request.Title)
+	if title == "" {
+		title = collection.Name
+	}
+	description := strings.TrimSpace(request.Description)
+	if description == "" {
+		description = collection.Description
+	}
+
+	share := models.ContentShare{
+		OwnerID:       userID,
+		ContentType:   "collection",
+		ContentID:     collection.ID,
+		ShareToken:    shareToken,
+		ShareURL:      "/api/v1/shared/" + shareToken,
+		Title:         title,
+		Description:   description,
+		ExpiresAt:     request.ExpiresAt,
+		AllowDownload: false,
+		AllowComment:  false,
+		AllowEdit:     false,
+		IsActive:      true,
+	}
+
+	if err := db.Create(&share).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to share collection"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, mapFileShareResponse(c, share))
+}
+
+// GetCollectionShares handles GET /api/v1/collections/:id/shares
+func GetCollectionShares(c *gin.Context) {
+	db := config.GetDB()
+	id, ok := parseCollectionID(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var shares []models.ContentShare
+	if err := db.Where("owner_id = ? AND content_type = ? AND content_id = ?", userID, "collection", id).
+		Order("created_at DESC").Find(&shares).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch collection shares"})
+		return
+	}
+
+	result := make([]fileShareResponse, 0, len(shares))
+	for _, share := range shares {
+		result = append(result, mapFileShareResponse(c, share))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shares": result})
+}
+
+// DeleteCollectionShare handles DELETE /api/v1/collections/:id/shares/:shareId
+func DeleteCollectionShare(c *gin.Context) {
+	db := config.GetDB()
+	id, ok := parseCollectionID(c)
+	if !ok {
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var share models.ContentShare
+	if err := db.Where("id = ? AND owner_id = ? AND content_type = ? AND content_id = ?", c.Param("shareId"), userID, "collection", id).
+		First(&share).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection share not found"})
+		return
+	}
+
+	if err := db.Delete(&share).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete collection share"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Collection share deleted successfully"})
+}
@@ -7,12 +7,14 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
 	"github.com/trackeep/backend/config"
 	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
 	"github.com/trackeep/backend/utils"
 )
 
@@ -135,19 +137,24 @@ func CreateEncryptedNote(c *gin.Context) {
 		c.JSON(500, gin.H{"error": "Failed to create note"})
 		return
 	}
+	if _, err := services.NewNoteRevisionService(db).Record(&note, currentUser.ID, time.Now()); err != nil {
+		c.JSON(500, gin.H{"error": "Failed to record revision"})
+		return
+	}
+	if err := services.NewNoteLinkService(db).Reindex(&note); err != nil {
+		c.JSON(500, gin.H{"error": "Failed to index links"})
+		return
+	}
 
 	// Handle tags if provided
 	if len(req.Tags) > 0 {
 		var tags []models.Tag
 		for _, tagName := range req.Tags {
-			var tag models.Tag
-			if err := db.Where("name = ?", tagName).First(&tag).Error; err != nil {
-				if err == gorm.ErrRecordNotFound {
-					tag = models.Tag{Name: tagName}
-					db.Create(&tag)
-				}
+			tag, err := services.FindOrCreateTag(db, currentUser.ID, tagName)
+			if err != nil {
+				continue
 			}
-			tags = append(tags, tag)
+			tags = append(tags, *tag)
 		}
 		db.Model(&note).Association("Tags").Append(tags)
 	}
@@ -303,14 +310,11 @@ func UploadEncryptedFile(c *gin.Context) {
 	if len(tags) > 0 {
 		var tagModels []models.Tag
 		for _, tagName := range tags {
-			var tag models.Tag
-			if err := db.Where("name = ?", tagName).First(&tag).Error; err != nil {
-				if err == gorm.ErrRecordNotFound {
-					tag = models.Tag{Name: tagName}
-					db.Create(&tag)
-				}
+			tag, err := services.FindOrCreateTag(db, currentUser.ID, tagName)
+			if err != nil {
+				continue
 			}
-			tagModels = append(tagModels, tag)
+			tagModels = append(tagModels, *tag)
 		}
 		db.Model(&fileRecord).Association("Tags").Append(tagModels)
 	}
@@ -0,0 +1,550 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
+	"gorm.io/gorm"
+)
+
+// maxOPMLImportSize limits uploaded OPML files
+const maxOPMLImportSize = 5 << 20 // 5MB
+
+// feedFetchTimeout bounds subscribing to or refreshing a feed on request
+const feedFetchTimeout = 30 * time.Second
+
+// GetFeeds handles GET /api/v1/feeds
+func GetFeeds(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	feeds, err := services.NewFeedService(config.GetDB()).Feeds(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch feeds"})
+		return
+	}
+
+	var unread int64
+	for _, feed := range feeds {
+		unread += feed.UnreadCount
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"feeds":        feeds,
+		"unread_total": unread,
+	})
+}
+
+// SubscribeFeed handles POST /api/v1/feeds
+// The URL may be the feed itself or a page that links to it.
+func SubscribeFeed(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var request struct {
+		URL      string `json:"url" binding:"required"`
+		Category string `json:"category"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), feedFetchTimeout)
+	defer cancel()
+
+	feed, err := services.NewFeedService(config.GetDB()).Subscribe(ctx, userID, request.URL, request.Category)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrFeedExists):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrNoFeedFound):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to subscribe: %s", err.Error())})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, feed)
+}
+
+// findFeed loads the :id feed of the current user, writing the error response on failure
+func findFeed(c *gin.Context) (*models.Feed, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid feed ID"})
+		return nil, false
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	var feed models.Feed
+	if err := config.GetDB().Where("id = ? AND user_id = ?", id, userID).First(&feed).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Feed not found"})
+		return nil, false
+	}
+	return &feed, true
+}
+
+// GetFeed handles GET /api/v1/feeds/:id
+func GetFeed(c *gin.Context) {
+	feed, ok := findFeed(c)
+	if !ok {
+		return
+	}
+
+	counts, err := services.NewFeedService(config.GetDB()).UnreadCounts(feed.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count unread items"})
+		return
+	}
+	feed.UnreadCount = counts[feed.ID]
+
+	c.JSON(http.StatusOK, feed)
+}
+
+// UpdateFeed handles PUT /api/v1/feeds/:id
+func UpdateFeed(c *gin.Context) {
+	feed, ok := findFeed(c)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Title    *string `json:"title"`
+		Category *string `json:"category"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if request.Title != nil && strings.TrimSpace(*request.Title) != "" {
+		updates["title"] = strings.TrimSpace(*request.Title)
+	}
+	if request.Category != nil {
+		updates["category"] = strings.TrimSpace(*request.Category)
+	}
+	if len(updates) > 0 {
+		if err := config.GetDB().Model(feed).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update feed"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, feed)
+}
+
+// DeleteFeed handles DELETE /api/v1/feeds/:id
+// Bookmarks saved from the feed are kept.
+func DeleteFeed(c *gin.Context) {
+	feed, ok := findFeed(c)
+	if !ok {
+		return
+	}
+
+	if err := services.NewFeedService(config.GetDB()).Unsubscribe(feed.UserID, feed.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unsubscribe"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Unsubscribed successfully"})
+}
+
+// RefreshFeed handles POST /api/v1/feeds/:id/refresh
+func RefreshFeed(c *gin.Context) {
+	feed, ok := findFeed(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), feedFetchTimeout)
+	defer cancel()
+
+	newItems, err := services.NewFeedService(config.GetDB()).Refresh(ctx, feed)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": fmt.Sprintf("Failed to refresh feed: %s", err.Error())})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"feed":      feed,
+		"new_items": newItems,
+	})
+}
+
+// MarkFeedRead handles POST /api/v1/feeds/:id/read
+func MarkFeedRead(c *gin.Context) {
+	feed, ok := findFeed(c)
+	if !ok {
+		return
+	}
+
+	updated, err := services.NewFeedService(config.GetDB()).MarkRead(feed.UserID, feed.ID, nil, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark feed as read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+// GetFeedItems handles GET /api/v1/feeds/items
+// Supports feed_id, unread=true, saved=true, limit and offset.
+func GetFeedItems(c *gin.Context) {
+	db := config.GetDB()
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	query := db.Model(&models.FeedItem{}).Where("user_id = ?", userID)
+	if feedID := c.Query("feed_id"); feedID != "" {
+		query = query.Where("feed_id = ?", feedID)
+	}
+	if c.Query("unread") == "true" {
+		query = query.Where("is_read = ?", false)
+	}
+	if c.Query("saved") == "true" {
+		query = query.Where("bookmark_id IS NOT NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch feed items"})
+		return
+	}
+
+	var items []models.FeedItem
+	if err := query.Order("COALESCE(published_at, created_at) DESC").Limit(limit).Offset(offset).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch feed items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items":  items,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// MarkFeedItemsRead handles POST /api/v1/feeds/items/read
+// Set "read": false to mark the items as unread again.
+func MarkFeedItemsRead(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var request struct {
+		ItemIDs []uint `json:"item_ids" binding:"required,min=1"`
+		Read    *bool  `json:"read"`
+	}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	read := true
+	if request.Read != nil {
+		read = *request.Read
+	}
+
+	updated, err := services.NewFeedService(config.GetDB()).MarkRead(userID, 0, request.ItemIDs, read)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update items"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+// SaveFeedItem handles POST /api/v1/feeds/items/:itemId/save
+func SaveFeedItem(c *gin.Context) {
+	db := config.GetDB()
+	itemID, err := strconv.ParseUint(c.Param("itemId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var request struct {
+		Tags []string `json:"tags"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	bookmark, created, err := services.NewFeedService(db).SaveItem(userID, uint(itemID), request.Tags)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Feed item not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save feed item"})
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+		go func(saved models.Bookmark) {
+			if _, err := services.NewBookmarkContentService(db).ExtractBookmarkContent(context.Background(), &saved); err != nil {
+				log.Printf("Failed to extract content for bookmark %d: %v", saved.ID, err)
+			}
+		}(*bookmark)
+	}
+
+	c.JSON(status, bookmark)
+}
+
+// ImportOPML handles POST /api/v1/feeds/import
+func ImportOPML(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	if fileHeader.Size > maxOPMLImportSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "OPML file is too large"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxOPMLImportSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+
+	added, skipped, err := services.NewFeedService(config.GetDB()).ImportOPML(userID, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"added":   added,
+		"skipped": skipped,
+	})
+}
+
+// ExportOPML handles GET /api/v1/feeds/export
+func ExportOPML(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	data, err := services.NewFeedService(config.GetDB()).ExportOPML(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export feeds"})
+		return
+	}
+
+	filename := fmt.Sprintf("trackeep-feeds-%s.opml", time.Now().Format("2006-01-02"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "text/x-opml; charset=utf-8", data)
+}
+
+// feedRuleRequest is the payload for creating or updating an auto-save rule
+type feedRuleRequest struct {
+	FeedID    *uint    `json:"feed_id"`
+	Name      string   `json:"name"`
+	Keywords  []string `json:"keywords"`
+	MatchTags []string `json:"match_tags"`
+	AddTags   []string `json:"add_tags"`
+	IsActive  *bool    `json:"is_active"`
+}
+
+// apply validates the request and copies it onto the rule
+func (r *feedRuleRequest) apply(c *gin.Context, rule *models.FeedRule) bool {
+	if len(r.Keywords) == 0 && len(r.MatchTags) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A rule needs keywords or match_tags"})
+		return false
+	}
+	if r.FeedID != nil {
+		var count int64
+		config.GetDB().Model(&models.Feed{}).Where("id = ? AND user_id = ?", *r.FeedID, rule.UserID).Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Feed not found"})
+			return false
+		}
+	}
+
+	rule.FeedID = r.FeedID
+	rule.Name = strings.TrimSpace(r.Name)
+	rule.Keywords = r.Keywords
+	rule.MatchTags = r.MatchTags
+	rule.AddTags = r.AddTags
+	if r.IsActive != nil {
+		rule.IsActive = *r.IsActive
+	}
+	return true
+}
+
+// GetFeedRules handles GET /api/v1/feeds/rules
+func GetFeedRules(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var rules []models.FeedRule
+	if err := config.GetDB().Where("user_id = ?", userID).Order("created_at ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// CreateFeedRule handles POST /api/v1/feeds/rules
+func CreateFeedRule(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var request feedRuleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := models.FeedRule{UserID: userID, IsActive: true}
+	if !request.apply(c, &rule) {
+		return
+	}
+
+	if err := config.GetDB().Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create rule"})
+		return
+	}
+	// is_active defaults to true in the database, so store an inactive rule explicitly
+	if !rule.IsActive {
+		config.GetDB().Model(&rule).UpdateColumn("is_active", false)
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// findFeedRule loads the :id rule of the current user, writing the error response on failure
+func findFeedRule(c *gin.Context) (*models.FeedRule, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid rule ID"})
+		return nil, false
+	}
+
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return nil, false
+	}
+
+	var rule models.FeedRule
+	if err := config.GetDB().Where("id = ? AND user_id = ?", id, userID).First(&rule).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rule not found"})
+		return nil, false
+	}
+	return &rule, true
+}
+
+// UpdateFeedRule handles PUT /api/v1/feeds/rules/:id
+func UpdateFeedRule(c *gin.Context) {
+	rule, ok := findFeedRule(c)
+	if !ok {
+		return
+	}
+
+	var request feedRuleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !request.apply(c, rule) {
+		return
+	}
+
+	if err := config.GetDB().Save(rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteFeedRule handles DELETE /api/v1/feeds/rules/:id
+func DeleteFeedRule(c *gin.Context) {
+	rule, ok := findFeedRule(c)
+	if !ok {
+		return
+	}
+
+	if err := config.GetDB().Delete(rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Rule deleted successfully"})
+}
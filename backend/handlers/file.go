@@ -120,6 +120,12 @@ func GetFiles(c *gin.Context) {
 		query = query.Where("LOWER(original_name) LIKE ? OR LOWER(description) LIKE ?", needle, needle)
 	}
 
+	// ?tag= also matches files tagged below the given tag
+	query, ok := filterByTag(c, models.DB, query, userID, "file_tags", "file_id")
+	if !ok {
+		return
+	}
+
 	limitApplied := false
 	if limitRaw := strings.TrimSpace(c.Query("limit")); limitRaw != "" {
 		limit, err := strconv.Atoi(limitRaw)
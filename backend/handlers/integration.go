@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"path/filepath"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
 	"gorm.io/gorm"
 )
 
@@ -69,6 +72,8 @@ func (h *IntegrationHandler) CreateIntegration(c *gin.Context) {
 		Name        string                   `json:"name" binding:"required"`
 		Description string                   `json:"description"`
 		Config      models.IntegrationConfig `json:"config"`
+		// APIToken is a personal token for services without OAuth, such as Todoist
+		APIToken string `json:"apiToken"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -86,6 +91,10 @@ func (h *IntegrationHandler) CreateIntegration(c *gin.Context) {
 		SyncEnabled:  true,
 		SyncInterval: 60, // Default 1 hour
 	}
+	if req.APIToken != "" {
+		integration.AccessToken = req.APIToken
+		integration.Status = models.StatusActive
+	}
 
 	if err := h.db.Create(&integration).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create integration"})
@@ -117,6 +126,7 @@ func (h *IntegrationHandler) UpdateIntegration(c *gin.Context) {
 		SyncEnabled  *bool                     `json:"syncEnabled"`
 		SyncInterval *int                      `json:"syncInterval"`
 		WebhookURL   *string                   `json:"webhookUrl"`
+		APIToken     *string                   `json:"apiToken"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -143,6 +153,12 @@ func (h *IntegrationHandler) UpdateIntegration(c *gin.Context) {
 	if req.WebhookURL != nil {
 		integration.WebhookURL = *req.WebhookURL
 	}
+	if req.APIToken != nil {
+		integration.AccessToken = *req.APIToken
+		if integration.AccessToken != "" {
+			integration.Status = models.StatusActive
+		}
+	}
 
 	if err := h.db.Save(&integration).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update integration"})
@@ -412,6 +428,10 @@ func (h *IntegrationHandler) performSync(integration models.Integration) {
 		itemsProcessed, itemsCreated, itemsUpdated, itemsDeleted, itemsSkipped, err = h.syncGoogle(integration)
 	case models.IntegrationGitHub:
 		itemsProcessed, itemsCreated, itemsUpdated, itemsDeleted, itemsSkipped, err = h.syncGitHub(integration)
+	case models.IntegrationTodoist:
+		itemsProcessed, itemsCreated, itemsUpdated, itemsDeleted, itemsSkipped, err = h.syncTodoist(integration)
+	case models.IntegrationObsidian:
+		itemsProcessed, itemsCreated, itemsUpdated, itemsDeleted, itemsSkipped, err = h.syncObsidian(integration)
 	default:
 		err = fmt.Errorf("unsupported integration type")
 	}
@@ -474,3 +494,52 @@ func (h *IntegrationHandler) syncGitHub(integration models.Integration) (int, in
 	// TODO: Implement actual GitHub sync
 	return 0, 0, 0, 0, 0, nil
 }
+
+// syncTodoist imports Todoist tasks with the integration's API token. Tasks
+// completed upstream are reported as deleted items.
+func (h *IntegrationHandler) syncTodoist(integration models.Integration) (int, int, int, int, int, error) {
+	userID, err := strconv.ParseUint(integration.UserID, 10, 32)
+	if err != nil {
+		return 0, 0, 0, 0, 0, fmt.Errorf("integration is not linked to a user")
+	}
+	if integration.AccessToken == "" {
+		return 0, 0, 0, 0, 0, fmt.Errorf("Todoist API token is missing")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	client := services.NewTodoistClient(integration.AccessToken)
+	result, err := services.NewTaskImportService(h.db).SyncTodoist(ctx, uint(userID), client, integration.Config.TodoistConfig, time.Now())
+	if err != nil {
+		return 0, 0, 0, 0, 0, err
+	}
+	return result.Processed, result.Created, result.Updated, result.Closed, result.Skipped, nil
+}
+
+// syncObsidian syncs notes with the integration's vault folder. Conflicts
+// are counted as updated items; the conflict copies are created as well.
+func (h *IntegrationHandler) syncObsidian(integration models.Integration) (int, int, int, int, int, error) {
+	userID, err := strconv.ParseUint(integration.UserID, 10, 32)
+	if err != nil {
+		return 0, 0, 0, 0, 0, fmt.Errorf("integration is not linked to a user")
+	}
+	obsidian := integration.Config.ObsidianConfig
+	if obsidian == nil || obsidian.VaultPath == "" {
+		return 0, 0, 0, 0, 0, fmt.Errorf("Obsidian vault path is missing")
+	}
+	dir, err := obsidianVaultDir(uint(userID), obsidian.VaultPath)
+	if err != nil {
+		return 0, 0, 0, 0, 0, err
+	}
+	vault := obsidian.VaultName
+	if vault == "" {
+		vault = filepath.Base(dir)
+	}
+
+	result, err := services.NewObsidianService(h.db, noteAttachmentDir).Sync(uint(userID), vault, dir, time.Now())
+	if err != nil {
+		return 0, 0, 0, 0, 0, err
+	}
+	return result.Processed, result.Created, result.Updated + result.Conflicts, result.Deleted, result.Skipped, nil
+}
@@ -262,6 +262,7 @@ func (h *KnowledgeBaseHandler) UpdateWikiPage(c *gin.Context) {
 	// Process backlinks if content changed
 	if req.Content != "" && req.Content != oldContent {
 		go h.processBacklinks(page.ID, req.Content)
+		notifyCollabEdit(models.CollabDocWiki, page.ID, page.Content, userID)
 	}
 
 	c.JSON(http.StatusOK, page)
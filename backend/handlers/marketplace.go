@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -8,6 +9,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
 	"gorm.io/gorm"
 )
 
@@ -144,6 +146,12 @@ func (h *MarketplaceHandler) CreateMarketplaceItem(c *gin.Context) {
 	item.SellerID = userID
 	item.Status = "draft" // Items start as draft and need approval
 
+	// Templates can only be listed by their owner
+	if item.ContentType == services.MarketplaceNoteTemplate && !h.ownsTemplate(userID, item.ContentID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "content_id must be one of your templates"})
+		return
+	}
+
 	if err := h.db.Create(&item).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create marketplace item"})
 		return
@@ -175,6 +183,11 @@ func (h *MarketplaceHandler) UpdateMarketplaceItem(c *gin.Context) {
 		return
 	}
 
+	if updateData.ContentType == services.MarketplaceNoteTemplate && !h.ownsTemplate(userID, item.ContentID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "content_id must be one of your templates"})
+		return
+	}
+
 	// Update allowed fields
 	item.Title = updateData.Title
 	item.Description = updateData.Description
@@ -226,6 +239,38 @@ func (h *MarketplaceHandler) DeleteMarketplaceItem(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Marketplace item deleted successfully"})
 }
 
+// InstallMarketplaceItem copies the content of an item into the user's
+// account. Only note templates can be installed so far.
+func (h *MarketplaceHandler) InstallMarketplaceItem(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	itemID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+		return
+	}
+
+	template, err := services.NewTemplateService(h.db).Install(userID, uint(itemID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Marketplace item not found"})
+			return
+		}
+		writeTemplateError(c, err, "Failed to install marketplace item")
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+func (h *MarketplaceHandler) ownsTemplate(userID uint, templateID *uint) bool {
+	if templateID == nil {
+		return false
+	}
+	var count int64
+	h.db.Model(&models.Template{}).Where("id = ? AND user_id = ?", *templateID, userID).Count(&count)
+	return count > 0
+}
+
 // GetMyMarketplaceItems returns current user's marketplace items
 func (h *MarketplaceHandler) GetMyMarketplaceItems(c *gin.Context) {
 	userID := c.GetUint("user_id")
@@ -368,6 +413,10 @@ func (h *MarketplaceHandler) GetContentShare(c *gin.Context) {
 		if err := h.db.Where("id = ? AND user_id = ?", share.ContentID, share.OwnerID).First(&file).Error; err == nil {
 			content = file
 		}
+	case "collection":
+		if collection, err := services.NewCollectionService(h.db).SharedView(share.OwnerID, share.ContentID); err == nil {
+			content = collection
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
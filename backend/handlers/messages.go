@@ -694,7 +694,8 @@ func CreateConversationMessage(c *gin.Context) {
 		})
 	}
 
-	suggestions, inferredAttachments, isSensitive := services.DetectMessageContent(trimmedBody)
+	// Dates in task and event suggestions are read in the sender's timezone
+	suggestions, inferredAttachments, isSensitive := services.DetectMessageContentAt(trimmedBody, time.Now(), services.UserLocation(models.DB, userID))
 	for _, inferred := range inferredAttachments {
 		if hasAttachment(attachmentRows, inferred.Kind, inferred.URL) {
 			continue
@@ -1573,6 +1574,7 @@ func MessagesWebSocket(c *gin.Context) {
 
 	hub := services.GetMessagesHub()
 	client := services.NewWSClient(userID, conn)
+	hub.AddClient(client)
 
 	// Subscribe to all conversations this user is a member of.
 	var memberships []models.ConversationMember
@@ -1622,6 +1624,15 @@ func MessagesWebSocket(c *gin.Context) {
 			if conversationID > 0 {
 				hub.RemoveClientFromConversation(client, conversationID)
 			}
+		case "board.subscribe":
+			boardID := parseUintAny(incoming["board_id"])
+			if boardID > 0 && services.NewBoardService(models.DB).CanView(userID, boardID) {
+				hub.AddClientToBoard(client, boardID)
+			}
+		case "board.unsubscribe":
+			if boardID := parseUintAny(incoming["board_id"]); boardID > 0 {
+				hub.RemoveClientFromBoard(client, boardID)
+			}
 		case "typing.started", "typing.stopped":
 			if conversationID > 0 && isConversationMember(models.DB, conversationID, userID) {
 				hub.Broadcast(conversationID, eventType, gin.H{
@@ -1675,9 +1686,14 @@ func MessagesWebSocket(c *gin.Context) {
 					"last_read_message_id": lastReadID,
 				})
 			}
+		case "collab.join", "collab.leave", "collab.operation", "collab.cursor":
+			handleCollabEvent(hub, client, eventType, incoming)
 		}
 	}
 
+	if collab := services.GetCollabService(); collab != nil {
+		collab.LeaveAll(client)
+	}
 	hub.RemoveClient(client)
 	_ = conn.Close()
 }
@@ -3,9 +3,12 @@ package handlers
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
+	"github.com/trackeep/backend/utils"
 	"gorm.io/gorm"
 )
 
@@ -16,7 +19,6 @@ func GetNotes(c *gin.Context) {
 	// TODO: Get user ID from authentication context
 	// Parse query parameters for filtering
 	search := c.Query("search")
-	tag := c.Query("tag")
 
 	userID := c.GetUint("userID")
 	if userID == 0 {
@@ -31,11 +33,10 @@ func GetNotes(c *gin.Context) {
 		query = query.Where("title ILIKE ? OR content ILIKE ?", "%"+search+"%", "%"+search+"%")
 	}
 
-	// Add tag filter
-	if tag != "" {
-		query = query.Joins("JOIN note_tags ON notes.id = note_tags.note_id").
-			Joins("JOIN tags ON note_tags.tag_id = tags.id").
-			Where("tags.name = ?", tag)
+	// Add tag filter; a parent tag also matches notes tagged below it
+	query, ok := filterByTag(c, models.DB, query, userID, "note_tags", "note_id")
+	if !ok {
+		return
 	}
 
 	if err := query.Find(&notes).Error; err != nil {
@@ -86,16 +87,16 @@ func CreateNote(c *gin.Context) {
 	// Add tags if provided
 	if len(input.Tags) > 0 {
 		for _, tagName := range input.Tags {
-			var tag models.Tag
 			// Find or create tag
-			if err := tx.Where("name = ?", tagName).FirstOrCreate(&tag, models.Tag{Name: tagName}).Error; err != nil {
+			tag, err := services.FindOrCreateTag(tx, note.UserID, tagName)
+			if err != nil {
 				tx.Rollback()
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tag"})
 				return
 			}
 
 			// Associate tag with note
-			if err := tx.Model(&note).Association("Tags").Append(&tag); err != nil {
+			if err := tx.Model(&note).Association("Tags").Append(tag); err != nil {
 				tx.Rollback()
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to associate tag"})
 				return
@@ -103,6 +104,17 @@ func CreateNote(c *gin.Context) {
 		}
 	}
 
+	if _, err := services.NewNoteRevisionService(tx).Record(&note, note.UserID, time.Now()); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record revision"})
+		return
+	}
+	if err := services.NewNoteLinkService(tx).Reindex(&note); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to index links"})
+		return
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create note"})
@@ -167,12 +179,31 @@ func UpdateNote(c *gin.Context) {
 	// Start transaction
 	tx := models.DB.Begin()
 
+	// Notes saved before revisions were kept get their current text
+	// recorded first, so the save can be undone
+	revisions := services.NewNoteRevisionService(tx)
+	if err := revisions.Baseline(&note); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record revision"})
+		return
+	}
+
 	// Update note fields
 	if input.Title != "" {
 		note.Title = input.Title
 	}
 	if input.Content != "" {
 		note.Content = input.Content
+		// Encrypted notes stay encrypted, along with their revisions
+		if note.IsEncrypted {
+			encrypted, err := utils.Encrypt(input.Content)
+			if err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to encrypt content"})
+				return
+			}
+			note.Content = encrypted
+		}
 	}
 	if input.Description != "" {
 		note.Description = input.Description
@@ -196,16 +227,16 @@ func UpdateNote(c *gin.Context) {
 
 		// Add new tags
 		for _, tagName := range input.Tags {
-			var tag models.Tag
 			// Find or create tag
-			if err := tx.Where("name = ?", tagName).FirstOrCreate(&tag, models.Tag{Name: tagName}).Error; err != nil {
+			tag, err := services.FindOrCreateTag(tx, note.UserID, tagName)
+			if err != nil {
 				tx.Rollback()
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tag"})
 				return
 			}
 
 			// Associate tag with note
-			if err := tx.Model(&note).Association("Tags").Append(&tag); err != nil {
+			if err := tx.Model(&note).Association("Tags").Append(tag); err != nil {
 				tx.Rollback()
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to associate tag"})
 				return
@@ -213,11 +244,25 @@ func UpdateNote(c *gin.Context) {
 		}
 	}
 
+	if _, err := revisions.Record(&note, c.GetUint("userID"), time.Now()); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record revision"})
+		return
+	}
+	if err := services.NewNoteLinkService(tx).Reindex(&note); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to index links"})
+		return
+	}
+
 	// Commit transaction
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note"})
 		return
 	}
+	if input.Content != "" && !note.IsEncrypted {
+		notifyCollabEdit(models.CollabDocNote, note.ID, note.Content, c.GetUint("userID"))
+	}
 
 	// Reload note with tags
 	models.DB.Preload("Tags").First(&note, note.ID)
@@ -241,7 +286,12 @@ func DeleteNote(c *gin.Context) {
 
 	// TODO: Check if user has permission to delete this note
 
-	if err := models.DB.Delete(&note).Error; err != nil {
+	if err := models.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&note).Error; err != nil {
+			return err
+		}
+		return services.NewNoteLinkService(tx).Forget(&note)
+	}); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete note"})
 		return
 	}
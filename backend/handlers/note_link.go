@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
+	"gorm.io/gorm"
+)
+
+// GetNoteLinks handles GET /api/v1/notes/:id/links and returns the
+// wikilinks written in the note
+func GetNoteLinks(c *gin.Context) {
+	userID, noteID, ok := noteRevisionParams(c)
+	if !ok {
+		return
+	}
+
+	links, err := services.NewNoteLinkService(config.GetDB()).Links(userID, noteID)
+	if err != nil {
+		writeNoteLinkError(c, err, "Failed to fetch links")
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
+
+// GetNoteBacklinks handles GET /api/v1/notes/:id/backlinks
+func GetNoteBacklinks(c *gin.Context) {
+	userID, noteID, ok := noteRevisionParams(c)
+	if !ok {
+		return
+	}
+
+	backlinks, err := services.NewNoteLinkService(config.GetDB()).Backlinks(userID, models.LinkTargetNote, noteID)
+	if err != nil {
+		writeNoteLinkError(c, err, "Failed to fetch backlinks")
+		return
+	}
+
+	c.JSON(http.StatusOK, backlinks)
+}
+
+// GetNoteUnlinkedMentions handles GET /api/v1/notes/:id/unlinked-mentions
+func GetNoteUnlinkedMentions(c *gin.Context) {
+	userID, noteID, ok := noteRevisionParams(c)
+	if !ok {
+		return
+	}
+
+	mentions, err := services.NewNoteLinkService(config.GetDB()).UnlinkedMentions(userID, models.LinkTargetNote, noteID)
+	if err != nil {
+		writeNoteLinkError(c, err, "Failed to find unlinked mentions")
+		return
+	}
+
+	c.JSON(http.StatusOK, mentions)
+}
+
+// GetBacklinks handles GET /api/v1/notes/backlinks?type=task&id=7 and
+// returns the notes linking to a note, wiki page, bookmark or task
+func GetBacklinks(c *gin.Context) {
+	userID, targetType, targetID, ok := linkTargetParams(c)
+	if !ok {
+		return
+	}
+
+	backlinks, err := services.NewNoteLinkService(config.GetDB()).Backlinks(userID, targetType, targetID)
+	if err != nil {
+		writeNoteLinkError(c, err, "Failed to fetch backlinks")
+		return
+	}
+
+	c.JSON(http.StatusOK, backlinks)
+}
+
+// GetUnlinkedMentions handles GET /api/v1/notes/unlinked-mentions?type=&id=
+func GetUnlinkedMentions(c *gin.Context) {
+	userID, targetType, targetID, ok := linkTargetParams(c)
+	if !ok {
+		return
+	}
+
+	mentions, err := services.NewNoteLinkService(config.GetDB()).UnlinkedMentions(userID, targetType, targetID)
+	if err != nil {
+		writeNoteLinkError(c, err, "Failed to find unlinked mentions")
+		return
+	}
+
+	c.JSON(http.StatusOK, mentions)
+}
+
+// GetKnowledgeGraph handles GET /api/v1/notes/graph. root_type and root_id
+// limit the graph to what is within depth links of the root.
+func GetKnowledgeGraph(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	query := services.KnowledgeGraphQuery{RootType: c.Query("root_type")}
+	if query.RootType != "" {
+		rootID, err := strconv.ParseUint(c.Query("root_id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid root ID"})
+			return
+		}
+		query.RootID = uint(rootID)
+	}
+	if raw := c.Query("depth"); raw != "" {
+		depth, err := strconv.Atoi(raw)
+		if err != nil || depth < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid depth"})
+			return
+		}
+		query.Depth = depth
+	}
+
+	graph, err := services.NewNoteLinkService(config.GetDB()).Graph(userID, query)
+	if err != nil {
+		writeNoteLinkError(c, err, "Failed to build knowledge graph")
+		return
+	}
+
+	c.JSON(http.StatusOK, graph)
+}
+
+// ReindexNoteLinks handles POST /api/v1/notes/links/reindex and rebuilds
+// the links of all the user's notes
+func ReindexNoteLinks(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	count, err := services.NewNoteLinkService(config.GetDB()).ReindexAll(userID)
+	if err != nil {
+		writeNoteLinkError(c, err, "Failed to reindex links")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"notes": count})
+}
+
+func linkTargetParams(c *gin.Context) (uint, string, uint, bool) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return 0, "", 0, false
+	}
+	targetID, err := strconv.ParseUint(c.Query("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid target ID"})
+		return 0, "", 0, false
+	}
+	return userID, c.Query("type"), uint(targetID), true
+}
+
+func writeNoteLinkError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	case errors.Is(err, services.ErrInvalidLinkTarget):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
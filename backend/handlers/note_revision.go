@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
+	"gorm.io/gorm"
+)
+
+// GetNoteRevisions handles GET /api/v1/notes/:id/revisions
+func GetNoteRevisions(c *gin.Context) {
+	userID, noteID, ok := noteRevisionParams(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := services.NewNoteRevisionService(config.GetDB()).List(userID, noteID)
+	if err != nil {
+		writeNoteRevisionError(c, err, "Failed to fetch revisions")
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// GetNoteRevision handles GET /api/v1/notes/:id/revisions/:number
+func GetNoteRevision(c *gin.Context) {
+	userID, noteID, ok := noteRevisionParams(c)
+	if !ok {
+		return
+	}
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision number"})
+		return
+	}
+
+	revision, err := services.NewNoteRevisionService(config.GetDB()).Get(userID, noteID, number)
+	if err != nil {
+		writeNoteRevisionError(c, err, "Failed to fetch revision")
+		return
+	}
+
+	c.JSON(http.StatusOK, revision)
+}
+
+// GetNoteDiff handles GET /api/v1/notes/:id/diff?from=1&to=3&mode=word.
+// Leaving out to compares with the current note; mode is line or word.
+func GetNoteDiff(c *gin.Context) {
+	userID, noteID, ok := noteRevisionParams(c)
+	if !ok {
+		return
+	}
+	from, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from revision"})
+		return
+	}
+	to := 0
+	if raw := c.Query("to"); raw != "" {
+		if to, err = strconv.Atoi(raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to revision"})
+			return
+		}
+	}
+
+	diff, err := services.NewNoteRevisionService(config.GetDB()).Diff(userID, noteID, from, to, c.Query("mode"))
+	if err != nil {
+		writeNoteRevisionError(c, err, "Failed to diff revisions")
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+// RestoreNoteRevision handles POST /api/v1/notes/:id/revisions/:number/restore
+func RestoreNoteRevision(c *gin.Context) {
+	userID, noteID, ok := noteRevisionParams(c)
+	if !ok {
+		return
+	}
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision number"})
+		return
+	}
+
+	note, err := services.NewNoteRevisionService(config.GetDB()).Restore(userID, noteID, number, time.Now())
+	if err != nil {
+		writeNoteRevisionError(c, err, "Failed to restore revision")
+		return
+	}
+	if !note.IsEncrypted {
+		notifyCollabEdit(models.CollabDocNote, note.ID, note.Content, userID)
+	}
+
+	c.JSON(http.StatusOK, note)
+}
+
+func noteRevisionParams(c *gin.Context) (uint, uint, bool) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return 0, 0, false
+	}
+	noteID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid note ID"})
+		return 0, 0, false
+	}
+	return userID, uint(noteID), true
+}
+
+func writeNoteRevisionError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Note or revision not found"})
+	case errors.Is(err, services.ErrInvalidNoteDiff):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNoteRevisionSame):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/services"
+)
+
+// maxVaultUploadSize limits uploaded vault archives
+const maxVaultUploadSize = 256 << 20 // 256MB
+
+// noteAttachmentDir is where images embedded in imported notes are stored
+var noteAttachmentDir = "uploads"
+
+// ImportObsidianVault handles POST /api/v1/notes/import/obsidian. The file
+// is a zipped vault; importing the same vault again updates its notes.
+func ImportObsidianVault(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	if fileHeader.Size > maxVaultUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Vault archive is too large"})
+		return
+	}
+	vault := strings.TrimSpace(c.PostForm("vault"))
+	if vault == "" {
+		vault = strings.TrimSuffix(filepath.Base(fileHeader.Filename), filepath.Ext(fileHeader.Filename))
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	fsys, err := services.OpenVaultZip(file, fileHeader.Size)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := services.NewObsidianService(config.GetDB(), noteAttachmentDir).Import(userID, vault, fsys, time.Now())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidVault) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import vault"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ExportObsidianVault handles GET /api/v1/notes/export/obsidian and returns
+// the user's notes as a zipped vault
+func ExportObsidianVault(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	data, err := services.NewObsidianService(config.GetDB(), noteAttachmentDir).Export(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export notes"})
+		return
+	}
+
+	filename := fmt.Sprintf("trackeep-vault-%s.zip", time.Now().Format("2006-01-02"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/zip", data)
+}
+
+// obsidianVaultDir resolves the vault folder of a user's integration. Folder
+// sync is only available under OBSIDIAN_VAULT_ROOT, where each user has a
+// folder of their own named by user ID, so users cannot point the server at
+// arbitrary paths or at another user's vault.
+func obsidianVaultDir(userID uint, vaultPath string) (string, error) {
+	root := os.Getenv("OBSIDIAN_VAULT_ROOT")
+	if root == "" {
+		return "", fmt.Errorf("Obsidian folder sync is not enabled on this server")
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(root, strconv.FormatUint(uint64(userID), 10), filepath.Clean("/"+vaultPath))
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return "", fmt.Errorf("vault folder %q does not exist", vaultPath)
+	}
+	return dir, nil
+}
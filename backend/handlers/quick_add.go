@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/services"
+)
+
+// PreviewQuickAdd handles POST /api/v1/quick-add/preview. It returns what a
+// quick-add line would create without saving it.
+func PreviewQuickAdd(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var input services.QuickAddInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parsed, err := services.NewQuickAddService(config.GetDB()).Preview(userID, input, time.Now())
+	if err != nil {
+		writeQuickAddError(c, err, "Failed to parse text")
+		return
+	}
+
+	c.JSON(http.StatusOK, parsed)
+}
+
+// QuickAdd handles POST /api/v1/quick-add and creates the task or calendar
+// event described by the text
+func QuickAdd(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var input services.QuickAddInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := services.NewQuickAddService(config.GetDB()).Create(userID, input, time.Now())
+	if err != nil {
+		writeQuickAddError(c, err, "Failed to create item")
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+func writeQuickAddError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidQuickAdd), errors.Is(err, services.ErrInvalidRRule):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
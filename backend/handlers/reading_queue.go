@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/services"
+	"gorm.io/gorm"
+)
+
+// GetReadingQueue handles GET /api/v1/reading-queue and returns today's queue
+func GetReadingQueue(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	entries, err := services.NewReadingQueueService(config.GetDB()).DailyQueue(userID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build reading queue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": entries})
+}
+
+// MarkReadingQueueShown handles POST /api/v1/reading-queue/shown. Clients
+// call it when they display the queue, so the bookmarks in it are scheduled
+// to resurface later if they stay unread.
+func MarkReadingQueueShown(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	entries, err := services.NewReadingQueueService(config.GetDB()).MarkQueueShown(userID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update reading queue"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": entries})
+}
+
+// GetReadingStats handles GET /api/v1/reading-queue/stats
+func GetReadingStats(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	stats, err := services.NewReadingQueueService(config.GetDB()).Stats(userID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reading stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetReadingQueuePreferences handles GET /api/v1/reading-queue/preferences
+func GetReadingQueuePreferences(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	pref, err := services.NewReadingQueueService(config.GetDB()).Preferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pref)
+}
+
+// UpdateReadingQueuePreferences handles PUT /api/v1/reading-queue/preferences
+func UpdateReadingQueuePreferences(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var input services.ReadingQueuePreferenceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pref, err := services.NewReadingQueueService(config.GetDB()).UpdatePreferences(userID, input)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidReadingQueuePreference) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pref)
+}
+
+// SnoozeReadingQueueItem handles POST /api/v1/reading-queue/:bookmarkId/snooze.
+// The body gives either an "until" timestamp or a number of "days".
+func SnoozeReadingQueueItem(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	bookmarkID, err := strconv.ParseUint(c.Param("bookmarkId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return
+	}
+
+	var req struct {
+		Until *time.Time `json:"until"`
+		Days  int        `json:"days"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	var until time.Time
+	switch {
+	case req.Until != nil:
+		until = *req.Until
+	case req.Days > 0:
+		until = now.AddDate(0, 0, req.Days)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either until or days is required"})
+		return
+	}
+
+	item, err := services.NewReadingQueueService(config.GetDB()).Snooze(userID, uint(bookmarkID), until, now)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+		case errors.Is(err, services.ErrInvalidSnooze):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to snooze bookmark"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, item)
+}
+
+// MarkReadingQueueItemRead handles POST /api/v1/reading-queue/:bookmarkId/read.
+// An optional "minutes" gives the time spent reading.
+func MarkReadingQueueItemRead(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	bookmarkID, err := strconv.ParseUint(c.Param("bookmarkId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bookmark ID"})
+		return
+	}
+
+	var req struct {
+		Minutes float64 `json:"minutes"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	bookmark, err := services.NewReadingQueueService(config.GetDB()).MarkRead(userID, uint(bookmarkID), req.Minutes, time.Now())
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Bookmark not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mark bookmark as read"})
+		return
+	}
+
+	c.JSON(http.StatusOK, bookmark)
+}
@@ -0,0 +1,243 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/services"
+	"gorm.io/gorm"
+)
+
+const defaultReminderSnoozeMinutes = 10
+
+// GetReminders handles GET /api/v1/reminders. ?status= filters by status.
+func GetReminders(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	reminders, err := services.NewReminderService(config.GetDB()).ListReminders(userID, c.Query("status"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch reminders"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reminders)
+}
+
+// SnoozeReminder handles POST /api/v1/reminders/:id/snooze. The body gives
+// either minutes (10 by default) or an until time.
+func SnoozeReminder(c *gin.Context) {
+	userID, reminderID, ok := reminderParams(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Minutes int        `json:"minutes"`
+		Until   *time.Time `json:"until"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	now := time.Now()
+	until := now.Add(defaultReminderSnoozeMinutes * time.Minute)
+	switch {
+	case req.Until != nil:
+		until = *req.Until
+	case req.Minutes != 0:
+		until = now.Add(time.Duration(req.Minutes) * time.Minute)
+	}
+
+	reminder, err := services.NewReminderService(config.GetDB()).Snooze(userID, reminderID, until, now)
+	if err != nil {
+		writeReminderError(c, err, "Failed to snooze reminder")
+		return
+	}
+
+	c.JSON(http.StatusOK, reminder)
+}
+
+// DismissReminder handles POST /api/v1/reminders/:id/dismiss
+func DismissReminder(c *gin.Context) {
+	userID, reminderID, ok := reminderParams(c)
+	if !ok {
+		return
+	}
+
+	reminder, err := services.NewReminderService(config.GetDB()).Dismiss(userID, reminderID, time.Now())
+	if err != nil {
+		writeReminderError(c, err, "Failed to dismiss reminder")
+		return
+	}
+
+	c.JSON(http.StatusOK, reminder)
+}
+
+// GetNotifications handles GET /api/v1/notifications. ?unread=true lists
+// only unread notifications.
+func GetNotifications(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	notifications, unread, err := services.NewReminderService(config.GetDB()).
+		ListNotifications(userID, c.Query("unread") == "true", limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": notifications,
+		"unread_count":  unread,
+	})
+}
+
+// MarkNotificationRead handles POST /api/v1/notifications/:id/read
+func MarkNotificationRead(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid notification ID"})
+		return
+	}
+
+	if err := services.NewReminderService(config.GetDB()).MarkNotificationRead(userID, uint(id), time.Now()); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notification marked as read"})
+}
+
+// MarkAllNotificationsRead handles POST /api/v1/notifications/read-all
+func MarkAllNotificationsRead(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	updated, err := services.NewReminderService(config.GetDB()).MarkAllNotificationsRead(userID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+// GetPushPublicKey handles GET /api/v1/notifications/push/public-key and
+// returns the VAPID key browsers subscribe with
+func GetPushPublicKey(c *gin.Context) {
+	cfg := services.WebPushConfigFromEnv()
+	if !cfg.Complete() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": services.ErrWebPushNotConfigured.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"public_key": cfg.PublicKey})
+}
+
+// SubscribePush handles POST /api/v1/notifications/push/subscriptions with
+// the JSON of a browser PushSubscription
+func SubscribePush(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var input services.PushSubscriptionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subscription, err := services.NewReminderService(config.GetDB()).Subscribe(userID, input, c.Request.UserAgent())
+	if err != nil {
+		writeReminderError(c, err, "Failed to save push subscription")
+		return
+	}
+
+	c.JSON(http.StatusCreated, subscription)
+}
+
+// UnsubscribePush handles DELETE /api/v1/notifications/push/subscriptions
+// with the endpoint to remove
+func UnsubscribePush(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req struct {
+		Endpoint string `json:"endpoint" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := services.NewReminderService(config.GetDB()).Unsubscribe(userID, req.Endpoint); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove push subscription"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Push subscription removed"})
+}
+
+func reminderParams(c *gin.Context) (uint, uint, bool) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return 0, 0, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reminder ID"})
+		return 0, 0, false
+	}
+	return userID, uint(id), true
+}
+
+func writeReminderError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reminder not found"})
+	case errors.Is(err, services.ErrInvalidSnooze), errors.Is(err, services.ErrInvalidPushSubscription):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/services"
+	"gorm.io/gorm"
+)
+
+// tagRequest is the body for creating and updating tags
+type tagRequest struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+	Color       *string `json:"color"`
+}
+
+// GetTags handles GET /api/v1/tags and returns the user's tag tree
+func GetTags(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	tags, err := services.NewTagService(config.GetDB()).Tree(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+// GetTag handles GET /api/v1/tags/:id
+func GetTag(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseTagID(c, "id")
+	if !ok {
+		return
+	}
+
+	tag, err := services.NewTagService(config.GetDB()).Get(userID, id)
+	if err != nil {
+		writeTagError(c, err, "Failed to fetch tag")
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// CreateTag handles POST /api/v1/tags. Path names such as "dev/go/testing"
+// create the missing parent tags.
+func CreateTag(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req tagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var description, color string
+	if req.Description != nil {
+		description = *req.Description
+	}
+	if req.Color != nil {
+		color = *req.Color
+	}
+
+	tag, err := services.NewTagService(config.GetDB()).Create(userID, req.Name, description, color)
+	if err != nil {
+		writeTagError(c, err, "Failed to create tag")
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+// UpdateTag handles PUT /api/v1/tags/:id. Changing the name moves the tag
+// and its descendants to the new path.
+func UpdateTag(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseTagID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req tagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	db := config.GetDB()
+	service := services.NewTagService(db)
+	tag, err := service.Get(userID, id)
+	if err != nil {
+		writeTagError(c, err, "Failed to update tag")
+		return
+	}
+
+	if req.Name != "" {
+		if tag, err = service.Rename(userID, id, req.Name); err != nil {
+			writeTagError(c, err, "Failed to update tag")
+			return
+		}
+	}
+
+	updates := map[string]interface{}{}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Color != nil {
+		updates["color"] = *req.Color
+	}
+	if len(updates) > 0 {
+		if err := db.Model(tag).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tag"})
+			return
+		}
+	}
+
+	tag, err = service.Get(userID, id)
+	if err != nil {
+		writeTagError(c, err, "Failed to update tag")
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag handles DELETE /api/v1/tags/:id. Descendant tags are removed
+// too; tagged items are kept.
+func DeleteTag(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseTagID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := services.NewTagService(config.GetDB()).Delete(userID, id); err != nil {
+		writeTagError(c, err, "Failed to delete tag")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag deleted successfully"})
+}
+
+// AddTagAlias handles POST /api/v1/tags/:id/aliases
+func AddTagAlias(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseTagID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Alias string `json:"alias" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	alias, err := services.NewTagService(config.GetDB()).AddAlias(userID, id, req.Alias)
+	if err != nil {
+		writeTagError(c, err, "Failed to add alias")
+		return
+	}
+
+	c.JSON(http.StatusCreated, alias)
+}
+
+// DeleteTagAlias handles DELETE /api/v1/tags/:id/aliases/:aliasId
+func DeleteTagAlias(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseTagID(c, "id")
+	if !ok {
+		return
+	}
+	aliasID, ok := parseTagID(c, "aliasId")
+	if !ok {
+		return
+	}
+
+	if err := services.NewTagService(config.GetDB()).RemoveAlias(userID, id, aliasID); err != nil {
+		writeTagError(c, err, "Failed to delete alias")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alias deleted successfully"})
+}
+
+// MergeTags handles POST /api/v1/tags/:id/merge and folds the listed tags
+// into the tag in the path
+func MergeTags(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	id, ok := parseTagID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		SourceIDs []uint `json:"source_ids" binding:"required,min=1"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag, err := services.NewTagService(config.GetDB()).Merge(userID, id, req.SourceIDs)
+	if err != nil {
+		writeTagError(c, err, "Failed to merge tags")
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// filterByTag narrows an item query to the ?tag= parameter, matching the
+// tag's descendants as well. It writes the error response itself.
+func filterByTag(c *gin.Context, db, query *gorm.DB, userID uint, joinTable, joinKey string) (*gorm.DB, bool) {
+	tag := c.Query("tag")
+	if tag == "" {
+		return query, true
+	}
+
+	tagIDs, err := services.NewTagService(db).FilterIDs(userID, []string{tag})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve tag filter"})
+		return nil, false
+	}
+	return query.Where("id IN (SELECT "+joinKey+" FROM "+joinTable+" WHERE tag_id IN ?)", tagIDs), true
+}
+
+func parseTagID(c *gin.Context, param string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(param), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func writeTagError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+	case errors.Is(err, services.ErrTagExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTagName), errors.Is(err, services.ErrTagCycle):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
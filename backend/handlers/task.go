@@ -9,6 +9,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/trackeep/backend/config"
 	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
+	"gorm.io/gorm"
 )
 
 // GetTasks handles GET /api/v1/tasks
@@ -95,7 +97,17 @@ func GetTasks(c *gin.Context) {
 		return
 	}
 
-	if err := db.Where("user_id = ?", userID).Preload("Tags").Find(&tasks).Error; err != nil {
+	// ?tag= also matches tasks tagged below the given tag
+	query, ok := filterByTag(c, db, db.Where("user_id = ?", userID), userID, "task_tags", "task_id")
+	if !ok {
+		return
+	}
+
+	if err := query.Preload("Tags").Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
+		return
+	}
+	if err := services.NewTaskDependencyService(db).MarkBlocked(tasks); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch tasks"})
 		return
 	}
@@ -106,12 +118,17 @@ func GetTasks(c *gin.Context) {
 // CreateTask handles POST /api/v1/tasks
 func CreateTask(c *gin.Context) {
 	db := config.GetDB()
-	var task models.Task
 
-	if err := c.ShouldBindJSON(&task); err != nil {
+	// An optional "recurrence" object makes the new task recurring
+	var req struct {
+		models.Task
+		Recurrence *services.TaskRecurrenceInput `json:"recurrence"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	task := req.Task
 
 	userID := c.GetUint("userID")
 	if userID == 0 {
@@ -119,15 +136,59 @@ func CreateTask(c *gin.Context) {
 		return
 	}
 	task.UserID = userID
+	task.RecurrenceID = nil
+	task.OccurrenceIndex = 0
+
+	// Links to external services are set by sync only
+	task.ExternalSource = ""
+	task.ExternalID = ""
+	if err := services.NewTaskActivityService(db).ValidateAssignee(userID, task.AssigneeID); err != nil {
+		writeTaskActivityError(c, err, "Failed to create task")
+		return
+	}
+
+	// Prerequisites are stored by ID once they have been checked, rather
+	// than saved as associations
+	dependencyIDs := taskIDs(task.Dependencies)
+	task.Dependencies = nil
+	dependencies := services.NewTaskDependencyService(db)
+	if err := dependencies.ValidateDependencies(userID, dependencyIDs); err != nil {
+		writeTaskDependencyError(c, err, "Failed to create task")
+		return
+	}
+
+	recurrences := services.NewTaskRecurrenceService(db)
+	if req.Recurrence != nil {
+		if err := recurrences.ValidateRecurrence(userID, *req.Recurrence); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
 	if err := db.Create(&task).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task"})
 		return
 	}
 
-	db.Preload("Tags").First(&task, task.ID)
+	if len(dependencyIDs) > 0 {
+		if _, err := dependencies.SetDependencies(userID, task.ID, dependencyIDs); err != nil {
+			writeTaskDependencyError(c, err, "Failed to set dependencies")
+			return
+		}
+	}
+
+	if req.Recurrence != nil {
+		if _, err := recurrences.SetRecurrence(userID, task.ID, *req.Recurrence, time.Now()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set task recurrence"})
+			return
+		}
+	}
 
-	c.JSON(http.StatusCreated, task)
+	db.Preload("Tags").Preload("Dependencies").Preload("Recurrence").First(&task, task.ID)
+	tasks := []models.Task{task}
+	dependencies.MarkBlocked(tasks)
+
+	c.JSON(http.StatusCreated, tasks[0])
 }
 
 // GetTask handles GET /api/v1/tasks/:id
@@ -146,15 +207,23 @@ func GetTask(c *gin.Context) {
 		return
 	}
 
-	if err := db.Where("id = ? AND user_id = ?", id, userID).Preload("Tags").First(&task).Error; err != nil {
+	if err := db.Where("id = ? AND user_id = ?", id, userID).Preload("Tags").Preload("Dependencies").Preload("Recurrence").First(&task).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
 		return
 	}
 
-	c.JSON(http.StatusOK, task)
+	tasks := []models.Task{task}
+	if err := services.NewTaskDependencyService(db).MarkBlocked(tasks); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch task"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tasks[0])
 }
 
-// UpdateTask handles PUT /api/v1/tasks/:id
+// UpdateTask handles PUT /api/v1/tasks/:id. For recurring tasks
+// ?scope=future also applies the edit to later occurrences; the default
+// scope=this changes only this occurrence.
 func UpdateTask(c *gin.Context) {
 	db := config.GetDB()
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
@@ -175,20 +244,82 @@ func UpdateTask(c *gin.Context) {
 		return
 	}
 
+	scope := c.DefaultQuery("scope", "this")
+	if scope != "this" && scope != "future" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be this or future"})
+		return
+	}
+
 	var updateData models.Task
 	if err := c.ShouldBindJSON(&updateData); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := db.Model(&task).Updates(updateData).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task"})
+	// Series membership is managed through the recurrence endpoints and
+	// links to external services by sync
+	updateData.RecurrenceID = nil
+	updateData.OccurrenceIndex = 0
+	updateData.ExternalSource = ""
+	updateData.ExternalID = ""
+
+	// A new assignee must be someone the user works with
+	if updateData.AssigneeID != nil && (task.AssigneeID == nil || *task.AssigneeID != *updateData.AssigneeID) {
+		if err := services.NewTaskActivityService(db).ValidateAssignee(userID, updateData.AssigneeID); err != nil {
+			writeTaskActivityError(c, err, "Failed to update task")
+			return
+		}
+	}
+
+	// A "dependencies" list replaces the prerequisites after the cycle
+	// check; leaving it out keeps them as they are. The prerequisites, the
+	// completion check and the update are applied together, so a rejected
+	// request changes nothing.
+	wasCompleted := task.Status == models.TaskStatusCompleted
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		dependencies := services.NewTaskDependencyService(tx)
+		if updateData.Dependencies != nil {
+			if _, err := dependencies.SetDependencies(userID, task.ID, taskIDs(updateData.Dependencies)); err != nil {
+				return err
+			}
+			updateData.Dependencies = nil
+		}
+
+		if updateData.Status == models.TaskStatusCompleted && !wasCompleted {
+			if err := dependencies.CheckCompletion(userID, task.ID); err != nil {
+				return err
+			}
+		}
+
+		return services.TrackTaskChanges(tx, userID, []uint{task.ID}, func() error {
+			return tx.Model(&task).Updates(updateData).Error
+		})
+	}); err != nil {
+		writeTaskDependencyError(c, err, "Failed to update task")
 		return
 	}
 
-	db.Preload("Tags").First(&task, task.ID)
+	recurrences := services.NewTaskRecurrenceService(db)
+	if scope == "future" && task.RecurrenceID != nil {
+		if err := recurrences.ApplyToFuture(userID, task.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update future occurrences"})
+			return
+		}
+	}
+
+	// Completing a recurring task schedules its next occurrence
+	if updateData.Status == models.TaskStatusCompleted && !wasCompleted {
+		if _, _, err := recurrences.Complete(userID, task.ID, time.Now()); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete task"})
+			return
+		}
+	}
+
+	db.Preload("Tags").Preload("Dependencies").Preload("Recurrence").First(&task, task.ID)
+	tasks := []models.Task{task}
+	services.NewTaskDependencyService(db).MarkBlocked(tasks)
 
-	c.JSON(http.StatusOK, task)
+	c.JSON(http.StatusOK, tasks[0])
 }
 
 // DeleteTask handles DELETE /api/v1/tasks/:id
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/services"
+	"gorm.io/gorm"
+)
+
+// GetTaskTimeline handles GET /api/v1/tasks/:id/timeline and returns the
+// comment threads, attachments and field changes of the task in order
+func GetTaskTimeline(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+
+	timeline, err := services.NewTaskActivityService(config.GetDB()).Timeline(userID, taskID)
+	if err != nil {
+		writeTaskActivityError(c, err, "Failed to fetch timeline")
+		return
+	}
+
+	c.JSON(http.StatusOK, timeline)
+}
+
+// GetTaskHistory handles GET /api/v1/tasks/:id/history
+func GetTaskHistory(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+
+	changes, err := services.NewTaskActivityService(config.GetDB()).History(userID, taskID)
+	if err != nil {
+		writeTaskActivityError(c, err, "Failed to fetch history")
+		return
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
+
+// GetTaskComments handles GET /api/v1/tasks/:id/comments
+func GetTaskComments(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+
+	comments, err := services.NewTaskActivityService(config.GetDB()).Comments(userID, taskID)
+	if err != nil {
+		writeTaskActivityError(c, err, "Failed to fetch comments")
+		return
+	}
+
+	c.JSON(http.StatusOK, comments)
+}
+
+// CreateTaskComment handles POST /api/v1/tasks/:id/comments
+func CreateTaskComment(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+
+	var input services.TaskCommentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comment, err := services.NewTaskActivityService(config.GetDB()).AddComment(userID, taskID, input)
+	if err != nil {
+		writeTaskActivityError(c, err, "Failed to add comment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, comment)
+}
+
+// UpdateTaskComment handles PUT /api/v1/tasks/:id/comments/:comment_id
+func UpdateTaskComment(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+	commentID, ok := taskActivityParam(c, "comment_id", "Invalid comment ID")
+	if !ok {
+		return
+	}
+
+	var input services.TaskCommentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comment, err := services.NewTaskActivityService(config.GetDB()).UpdateComment(userID, taskID, commentID, input, time.Now())
+	if err != nil {
+		writeTaskActivityError(c, err, "Failed to update comment")
+		return
+	}
+
+	c.JSON(http.StatusOK, comment)
+}
+
+// DeleteTaskComment handles DELETE /api/v1/tasks/:id/comments/:comment_id
+func DeleteTaskComment(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+	commentID, ok := taskActivityParam(c, "comment_id", "Invalid comment ID")
+	if !ok {
+		return
+	}
+
+	if err := services.NewTaskActivityService(config.GetDB()).DeleteComment(userID, taskID, commentID); err != nil {
+		writeTaskActivityError(c, err, "Failed to delete comment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+}
+
+// GetTaskAttachments handles GET /api/v1/tasks/:id/attachments
+func GetTaskAttachments(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+
+	attachments, err := services.NewTaskActivityService(config.GetDB()).Attachments(userID, taskID)
+	if err != nil {
+		writeTaskActivityError(c, err, "Failed to fetch attachments")
+		return
+	}
+
+	c.JSON(http.StatusOK, attachments)
+}
+
+// CreateTaskAttachment handles POST /api/v1/tasks/:id/attachments with the
+// ID of an uploaded file
+func CreateTaskAttachment(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		FileID uint `json:"file_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	attachment, err := services.NewTaskActivityService(config.GetDB()).Attach(userID, taskID, req.FileID)
+	if err != nil {
+		writeTaskActivityError(c, err, "Failed to attach file")
+		return
+	}
+
+	c.JSON(http.StatusCreated, attachment)
+}
+
+// DeleteTaskAttachment handles DELETE /api/v1/tasks/:id/attachments/:attachment_id.
+// The file itself is kept.
+func DeleteTaskAttachment(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+	attachmentID, ok := taskActivityParam(c, "attachment_id", "Invalid attachment ID")
+	if !ok {
+		return
+	}
+
+	if err := services.NewTaskActivityService(config.GetDB()).Detach(userID, taskID, attachmentID); err != nil {
+		writeTaskActivityError(c, err, "Failed to remove attachment")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Attachment removed successfully"})
+}
+
+func taskActivityParam(c *gin.Context, name, message string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func writeTaskActivityError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	case errors.Is(err, services.ErrTaskForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidTaskComment), errors.Is(err, services.ErrInvalidAssignee):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
+	"gorm.io/gorm"
+)
+
+// GetTaskDependencies handles GET /api/v1/tasks/:id/dependencies and returns
+// the task's prerequisites, its dependents and the prerequisites still open
+func GetTaskDependencies(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+
+	dependencies, err := services.NewTaskDependencyService(config.GetDB()).Dependencies(userID, taskID)
+	if err != nil {
+		writeTaskDependencyError(c, err, "Failed to fetch dependencies")
+		return
+	}
+
+	c.JSON(http.StatusOK, dependencies)
+}
+
+// SetTaskDependencies handles PUT /api/v1/tasks/:id/dependencies and
+// replaces the task's prerequisites
+func SetTaskDependencies(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		DependencyIDs []uint `json:"dependency_ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := services.NewTaskDependencyService(config.GetDB()).SetDependencies(userID, taskID, req.DependencyIDs)
+	if err != nil {
+		writeTaskDependencyError(c, err, "Failed to set dependencies")
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// AddTaskDependency handles POST /api/v1/tasks/:id/dependencies/:dependency_id
+func AddTaskDependency(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+	dependencyID, err := strconv.ParseUint(c.Param("dependency_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dependency ID"})
+		return
+	}
+
+	task, err := services.NewTaskDependencyService(config.GetDB()).AddDependency(userID, taskID, uint(dependencyID))
+	if err != nil {
+		writeTaskDependencyError(c, err, "Failed to add dependency")
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// RemoveTaskDependency handles DELETE /api/v1/tasks/:id/dependencies/:dependency_id
+func RemoveTaskDependency(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+	dependencyID, err := strconv.ParseUint(c.Param("dependency_id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid dependency ID"})
+		return
+	}
+
+	task, err := services.NewTaskDependencyService(config.GetDB()).RemoveDependency(userID, taskID, uint(dependencyID))
+	if err != nil {
+		writeTaskDependencyError(c, err, "Failed to remove dependency")
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// GetTaskGraph handles GET /api/v1/tasks/graph. ?tag= or ?parent_id=
+// narrow the graph to a tag or a task and its subtasks; completed tasks are
+// left out unless ?include_completed=true.
+func GetTaskGraph(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	scope := services.TaskGraphScope{
+		Tag:              c.Query("tag"),
+		IncludeCompleted: c.Query("include_completed") == "true",
+	}
+	if raw := c.Query("parent_id"); raw != "" {
+		parentID, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parent ID"})
+			return
+		}
+		id := uint(parentID)
+		scope.ParentID = &id
+	}
+
+	graph, err := services.NewTaskDependencyService(config.GetDB()).Graph(userID, scope, time.Now())
+	if err != nil {
+		writeTaskDependencyError(c, err, "Failed to build task graph")
+		return
+	}
+
+	c.JSON(http.StatusOK, graph)
+}
+
+// GetTaskPreferences handles GET /api/v1/tasks/preferences
+func GetTaskPreferences(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	pref, err := services.NewTaskDependencyService(config.GetDB()).Preferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch task preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pref)
+}
+
+// UpdateTaskPreferences handles PUT /api/v1/tasks/preferences
+func UpdateTaskPreferences(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var input services.TaskPreferenceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pref, err := services.NewTaskDependencyService(config.GetDB()).UpdatePreferences(userID, input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, pref)
+}
+
+// taskIDs collects the IDs of tasks given in a request body
+func taskIDs(tasks []models.Task) []uint {
+	ids := make([]uint, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	return ids
+}
+
+func writeTaskDependencyError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	case errors.Is(err, services.ErrSelfDependency), errors.Is(err, services.ErrInvalidDependency):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDependencyCycle), errors.Is(err, services.ErrTaskBlocked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/services"
+	"gorm.io/gorm"
+)
+
+// GetTaskActuals handles GET /api/v1/tasks/:id/actuals and compares the
+// task's estimate with the time logged on it and its subtasks
+func GetTaskActuals(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+
+	actuals, err := services.NewTaskEstimateService(config.GetDB()).Actuals(userID, taskID, time.Now())
+	if err != nil {
+		writeTaskEstimateError(c, err, "Failed to fetch actuals")
+		return
+	}
+
+	c.JSON(http.StatusOK, actuals)
+}
+
+// GetTaskBurndown handles GET /api/v1/tasks/burndown. The scope is set with
+// tag, parent_id and team_id; start_date and end_date default to the last
+// 30 days.
+func GetTaskBurndown(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	scope, dates, ok := taskEstimateQuery(c)
+	if !ok {
+		return
+	}
+
+	burndown, err := services.NewTaskEstimateService(config.GetDB()).Burndown(userID, scope, dates, time.Now())
+	if err != nil {
+		writeTaskEstimateError(c, err, "Failed to build burndown")
+		return
+	}
+
+	c.JSON(http.StatusOK, burndown)
+}
+
+// GetEstimateVariance handles GET /api/v1/tasks/estimates/variance. It takes
+// the same scope and dates as the burndown, plus group_by (tag or priority).
+func GetEstimateVariance(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	scope, dates, ok := taskEstimateQuery(c)
+	if !ok {
+		return
+	}
+
+	report, err := services.NewTaskEstimateService(config.GetDB()).Variance(userID, scope, c.Query("group_by"), dates, time.Now())
+	if err != nil {
+		writeTaskEstimateError(c, err, "Failed to build variance report")
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func taskEstimateQuery(c *gin.Context) (services.TaskEstimateScope, services.TaskEstimateRange, bool) {
+	scope := services.TaskEstimateScope{Tag: c.Query("tag")}
+	var dates services.TaskEstimateRange
+
+	for _, param := range []struct {
+		name    string
+		message string
+		target  **uint
+	}{
+		{"parent_id", "Invalid parent ID", &scope.ParentID},
+		{"team_id", "Invalid team ID", &scope.TeamID},
+	} {
+		raw := c.Query(param.name)
+		if raw == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": param.message})
+			return scope, dates, false
+		}
+		value := uint(id)
+		*param.target = &value
+	}
+
+	for _, param := range []struct {
+		name   string
+		target *time.Time
+	}{
+		{"start_date", &dates.From},
+		{"end_date", &dates.To},
+	} {
+		raw := c.Query(param.name)
+		if raw == "" {
+			continue
+		}
+		parsed, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + param.name + ", use YYYY-MM-DD"})
+			return scope, dates, false
+		}
+		*param.target = parsed
+	}
+	return scope, dates, true
+}
+
+func writeTaskEstimateError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task or team not found"})
+	case errors.Is(err, services.ErrInvalidEstimateRange), errors.Is(err, services.ErrInvalidVarianceGroup):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
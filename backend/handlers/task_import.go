@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/services"
+)
+
+// maxTaskImportSize limits uploaded task export files
+const maxTaskImportSize = 20 << 20 // 20MB
+
+// ImportTaskwarrior handles POST /api/v1/tasks/import/taskwarrior. The file
+// is the output of `task export`; importing it again updates the same tasks.
+func ImportTaskwarrior(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	if fileHeader.Size > maxTaskImportSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Import file is too large"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read uploaded file"})
+		return
+	}
+	defer file.Close()
+
+	items, err := services.ParseTaskwarriorExport(io.LimitReader(file, maxTaskImportSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No tasks found in file"})
+		return
+	}
+
+	result, err := services.NewTaskImportService(config.GetDB()).Import(userID, services.TaskSourceTaskwarrior, items, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import tasks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ExportTaskwarrior handles GET /api/v1/tasks/export/taskwarrior and returns
+// the user's tasks for `task import`
+func ExportTaskwarrior(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	data, err := services.NewTaskImportService(config.GetDB()).ExportTaskwarrior(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export tasks"})
+		return
+	}
+
+	filename := fmt.Sprintf("trackeep-tasks-%s.json", time.Now().Format("2006-01-02"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/services"
+	"gorm.io/gorm"
+)
+
+// CompleteTask handles POST /api/v1/tasks/:id/complete. For recurring
+// tasks the response includes the next occurrence. Tasks with open
+// prerequisites are rejected unless the user's preferences allow it.
+func CompleteTask(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+
+	db := config.GetDB()
+	if err := services.NewTaskDependencyService(db).CheckCompletion(userID, taskID); err != nil {
+		writeTaskDependencyError(c, err, "Failed to complete task")
+		return
+	}
+
+	task, next, err := services.NewTaskRecurrenceService(db).Complete(userID, taskID, time.Now())
+	if err != nil {
+		writeTaskRecurrenceError(c, err, "Failed to complete task")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"task": task, "next": next})
+}
+
+// SetTaskRecurrence handles PUT /api/v1/tasks/:id/recurrence. On a task
+// that already recurs the new rule applies from this occurrence on.
+func SetTaskRecurrence(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+
+	var input services.TaskRecurrenceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	task, err := services.NewTaskRecurrenceService(config.GetDB()).SetRecurrence(userID, taskID, input, time.Now())
+	if err != nil {
+		writeTaskRecurrenceError(c, err, "Failed to set task recurrence")
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// StopTaskRecurrence handles DELETE /api/v1/tasks/:id/recurrence. No
+// further occurrences are created; existing ones are kept.
+func StopTaskRecurrence(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+
+	if err := services.NewTaskRecurrenceService(config.GetDB()).StopRecurrence(userID, taskID, time.Now()); err != nil {
+		writeTaskRecurrenceError(c, err, "Failed to stop task recurrence")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Recurrence stopped"})
+}
+
+// GetTaskOccurrences handles GET /api/v1/tasks/:id/occurrences and returns
+// the series history plus the next ?upcoming= due dates (default 5)
+func GetTaskOccurrences(c *gin.Context) {
+	userID, taskID, ok := taskRecurrenceParams(c)
+	if !ok {
+		return
+	}
+
+	upcoming, err := strconv.Atoi(c.DefaultQuery("upcoming", "5"))
+	if err != nil || upcoming < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upcoming count"})
+		return
+	}
+
+	occurrences, err := services.NewTaskRecurrenceService(config.GetDB()).Occurrences(userID, taskID, upcoming)
+	if err != nil {
+		writeTaskRecurrenceError(c, err, "Failed to fetch occurrences")
+		return
+	}
+
+	c.JSON(http.StatusOK, occurrences)
+}
+
+func taskRecurrenceParams(c *gin.Context) (uint, uint, bool) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return 0, 0, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return 0, 0, false
+	}
+	return userID, uint(id), true
+}
+
+func writeTaskRecurrenceError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+	case errors.Is(err, services.ErrInvalidRRule), errors.Is(err, services.ErrInvalidRecurrenceMode),
+		errors.Is(err, services.ErrTaskNotRecurring):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
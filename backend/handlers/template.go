@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/trackeep/backend/config"
+	"github.com/trackeep/backend/services"
+	"gorm.io/gorm"
+)
+
+// GetTemplates handles GET /api/v1/templates. Public templates of other
+// users are included with ?public=true.
+func GetTemplates(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	templates, err := services.NewTemplateService(config.GetDB()).List(userID, c.Query("public") == "true", c.Query("category"))
+	if err != nil {
+		writeTemplateError(c, err, "Failed to fetch templates")
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+// GetTemplate handles GET /api/v1/templates/:id
+func GetTemplate(c *gin.Context) {
+	userID, templateID, ok := templateParams(c)
+	if !ok {
+		return
+	}
+
+	template, err := services.NewTemplateService(config.GetDB()).Get(userID, templateID)
+	if err != nil {
+		writeTemplateError(c, err, "Failed to fetch template")
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// CreateTemplate handles POST /api/v1/templates
+func CreateTemplate(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var input services.TemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := services.NewTemplateService(config.GetDB()).Create(userID, &input)
+	if err != nil {
+		writeTemplateError(c, err, "Failed to create template")
+		return
+	}
+
+	c.JSON(http.StatusCreated, template)
+}
+
+// UpdateTemplate handles PUT /api/v1/templates/:id. Variables are replaced
+// when given and kept when left out.
+func UpdateTemplate(c *gin.Context) {
+	userID, templateID, ok := templateParams(c)
+	if !ok {
+		return
+	}
+
+	var input services.TemplateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := services.NewTemplateService(config.GetDB()).Update(userID, templateID, &input)
+	if err != nil {
+		writeTemplateError(c, err, "Failed to update template")
+		return
+	}
+
+	c.JSON(http.StatusOK, template)
+}
+
+// DeleteTemplate handles DELETE /api/v1/templates/:id
+func DeleteTemplate(c *gin.Context) {
+	userID, templateID, ok := templateParams(c)
+	if !ok {
+		return
+	}
+
+	if err := services.NewTemplateService(config.GetDB()).Delete(userID, templateID); err != nil {
+		writeTemplateError(c, err, "Failed to delete template")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
+}
+
+// InstantiateTemplate handles POST /api/v1/templates/:id/instantiate and
+// creates a note, or a wiki page with "target": "wiki", from the template
+func InstantiateTemplate(c *gin.Context) {
+	userID, templateID, ok := templateParams(c)
+	if !ok {
+		return
+	}
+
+	var req services.TemplateInstantiation
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	instance, err := services.NewTemplateService(config.GetDB()).Instantiate(userID, templateID, &req, time.Now())
+	if err != nil {
+		writeTemplateError(c, err, "Failed to create from template")
+		return
+	}
+	if instance.WikiPage != nil {
+		go NewKnowledgeBaseHandler(config.GetDB()).processBacklinks(instance.WikiPage.ID, instance.WikiPage.Content)
+	}
+
+	c.JSON(http.StatusCreated, instance)
+}
+
+// PublishTemplate handles POST /api/v1/templates/:id/publish and lists the
+// template in the marketplace as a note_template item
+func PublishTemplate(c *gin.Context) {
+	userID, templateID, ok := templateParams(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Price float64 `json:"price"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	item, err := services.NewTemplateService(config.GetDB()).Publish(userID, templateID, req.Price)
+	if err != nil {
+		writeTemplateError(c, err, "Failed to publish template")
+		return
+	}
+
+	c.JSON(http.StatusCreated, item)
+}
+
+func templateParams(c *gin.Context) (uint, uint, bool) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return 0, 0, false
+	}
+	templateID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid template ID"})
+		return 0, 0, false
+	}
+	return userID, uint(templateID), true
+}
+
+func writeTemplateError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+	case errors.Is(err, services.ErrInvalidTemplate), errors.Is(err, services.ErrTemplateVariables),
+		errors.Is(err, services.ErrNotInstallable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPurchaseRequired):
+		c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
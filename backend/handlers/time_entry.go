@@ -7,6 +7,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
 	"gorm.io/gorm"
 )
 
@@ -134,12 +135,12 @@ func (h *TimeEntryHandler) CreateTimeEntry(c *gin.Context) {
 	if len(req.Tags) > 0 {
 		var tags []models.Tag
 		for _, tagName := range req.Tags {
-			var tag models.Tag
-			if err := h.db.Where("name = ?", tagName).FirstOrCreate(&tag, models.Tag{Name: tagName}).Error; err != nil {
+			tag, err := services.FindOrCreateTag(h.db, userID, tagName)
+			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tags"})
 				return
 			}
-			tags = append(tags, tag)
+			tags = append(tags, *tag)
 		}
 		timeEntry.Tags = tags
 	}
@@ -203,12 +204,12 @@ func (h *TimeEntryHandler) UpdateTimeEntry(c *gin.Context) {
 		// Add new tags
 		var tags []models.Tag
 		for _, tagName := range req.Tags {
-			var tag models.Tag
-			if err := h.db.Where("name = ?", tagName).FirstOrCreate(&tag, models.Tag{Name: tagName}).Error; err != nil {
+			tag, err := services.FindOrCreateTag(h.db, userID, tagName)
+			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tags"})
 				return
 			}
-			tags = append(tags, tag)
+			tags = append(tags, *tag)
 		}
 		timeEntry.Tags = tags
 	}
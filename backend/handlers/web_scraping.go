@@ -5,7 +5,6 @@ import (
 	"log"
 	"net/http"
 	"net/url"
-	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -13,6 +12,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gocolly/colly/v2"
 	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
 	"gorm.io/gorm"
 )
 
@@ -343,6 +343,7 @@ func (h *WebScrapingHandler) scrapeWebPage(pageURL string, job models.ScrapingJo
 	// Set up content extraction variables
 	var title, description, content string
 	var keywords []string
+	var article *services.Article
 	var images []models.ScrapedImage
 	var links []models.ScrapedLink
 	var videos []models.ScrapedVideo
@@ -372,6 +373,16 @@ func (h *WebScrapingHandler) scrapeWebPage(pageURL string, job models.ScrapingJo
 		}
 	})
 
+	// Extract the readable article from the raw page
+	c.OnResponse(func(r *colly.Response) {
+		extracted, err := services.ExtractArticle(r.Body, r.Headers.Get("Content-Type"), r.Request.URL.String())
+		if err != nil {
+			log.Printf("Failed to extract article from %s: %v", r.Request.URL, err)
+			return
+		}
+		article = extracted
+	})
+
 	// Extract main content
 	c.OnHTML("article, main, .content, .post-content, .entry-content", func(e *colly.HTMLElement) {
 		content = strings.TrimSpace(e.Text)
@@ -466,6 +477,23 @@ func (h *WebScrapingHandler) scrapeWebPage(pageURL string, job models.ScrapingJo
 
 	c.Wait()
 
+	// Prefer the reader-mode article over the selector-based text
+	var author string
+	var publishedDate *time.Time
+	if article != nil {
+		if article.TextContent != "" {
+			content = article.TextContent
+		}
+		if title == "" {
+			title = article.Title
+		}
+		if description == "" {
+			description = article.Excerpt
+		}
+		author = article.Byline
+		publishedDate = article.PublishedAt
+	}
+
 	// Clean and process content
 	if content == "" {
 		content = "No content could be extracted from this page."
@@ -485,19 +513,21 @@ func (h *WebScrapingHandler) scrapeWebPage(pageURL string, job models.ScrapingJo
 
 	// Create the scraped content
 	scrapedContent := models.ScrapedContent{
-		UserID:       job.UserID,
-		URL:          pageURL,
-		Domain:       parsedURL.Hostname(),
-		Title:        title,
-		Description:  description,
-		Content:      content,
-		Keywords:     keywords,
-		ContentType:  h.detectContentType(title, content),
-		WordCount:    len(strings.Fields(content)),
-		ReadingTime:  h.estimateReadingTime(len(strings.Fields(content))),
-		QualityScore: 0, // Will be calculated below
-		Status:       "completed",
-		LastScraped:  time.Now(),
+		UserID:        job.UserID,
+		URL:           pageURL,
+		Domain:        parsedURL.Hostname(),
+		Title:         title,
+		Description:   description,
+		Content:       content,
+		Author:        author,
+		PublishedDate: publishedDate,
+		Keywords:      keywords,
+		ContentType:   h.detectContentType(title, content),
+		WordCount:     len(strings.Fields(content)),
+		ReadingTime:   h.estimateReadingTime(len(strings.Fields(content))),
+		QualityScore:  0, // Will be calculated below
+		Status:        "completed",
+		LastScraped:   time.Now(),
 	}
 
 	// Generate summary if requested
@@ -539,17 +569,9 @@ func (h *WebScrapingHandler) scrapeWebPage(pageURL string, job models.ScrapingJo
 	return &scrapedContent, nil
 }
 
-// extractTextFromHTML extracts text content from HTML
+// extractTextFromHTML extracts the readable text content from HTML
 func (h *WebScrapingHandler) extractTextFromHTML(html string) string {
-	// Remove HTML tags
-	re := regexp.MustCompile(`<[^>]*>`)
-	text := re.ReplaceAllString(html, "")
-
-	// Clean up whitespace
-	text = strings.TrimSpace(text)
-	text = regexp.MustCompile(`\s+`).ReplaceAllString(text, " ")
-
-	return text
+	return services.HTMLToText(html)
 }
 
 // estimateReadingTime estimates reading time in minutes
@@ -16,6 +16,7 @@ import (
 	"github.com/trackeep/backend/handlers"
 	"github.com/trackeep/backend/middleware"
 	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/services"
 	"github.com/trackeep/backend/utils"
 )
 
@@ -114,6 +115,18 @@ func main() {
 		if err := models.AutoMigrate(); err != nil {
 			log.Fatal("Failed to auto-migrate database:", err)
 		}
+
+		// Move tags from the old global namespace to per-user tags
+		if err := services.NewTagService(config.GetDB()).MigrateLegacyTags(); err != nil {
+			log.Printf("Failed to migrate legacy tags: %v", err)
+		}
+
+		// Compute duplicate-detection keys for bookmarks saved before they existed
+		go func() {
+			if err := services.NewBookmarkDedupService(config.GetDB()).BackfillCanonicalKeys(); err != nil {
+				log.Printf("Failed to backfill bookmark canonical keys: %v", err)
+			}
+		}()
 	} else {
 		log.Println("Demo mode enabled, skipping database initialization")
 	}
@@ -145,6 +158,63 @@ func main() {
 		log.Println("Using in-memory cache fallback")
 	}
 
+	// Start background link checking for bookmarks
+	var linkChecker *services.LinkChecker
+	if !cfg.App.DemoMode && cfg.LinkCheck.Enabled {
+		linkChecker = services.NewLinkChecker(config.GetDB(), services.LinkCheckerOptions{
+			Interval:     cfg.LinkCheck.Interval,
+			RecheckAfter: cfg.LinkCheck.RecheckAfter,
+			BatchSize:    cfg.LinkCheck.BatchSize,
+			Concurrency:  cfg.LinkCheck.Concurrency,
+			HostDelay:    cfg.LinkCheck.HostDelay,
+		})
+		linkChecker.Start()
+		log.Println("Bookmark link checker started")
+	}
+
+	// Start background polling of feed subscriptions
+	var feedPoller *services.FeedPoller
+	if !cfg.App.DemoMode && cfg.FeedPoll.Enabled {
+		feedPoller = services.NewFeedPoller(config.GetDB(), services.FeedPollerOptions{
+			Interval:        cfg.FeedPoll.Interval,
+			RefreshInterval: cfg.FeedPoll.RefreshInterval,
+			BatchSize:       cfg.FeedPoll.BatchSize,
+			Concurrency:     cfg.FeedPoll.Concurrency,
+		})
+		feedPoller.Start()
+		log.Println("Feed poller started")
+	}
+
+	// Start the reading queue email digest when mail can be sent
+	var readingDigest *services.ReadingDigestScheduler
+	if !cfg.App.DemoMode && cfg.Digest.Enabled && services.SMTPConfigFromEnv().Complete() {
+		readingDigest = services.NewReadingDigestScheduler(config.GetDB(), services.ReadingDigestOptions{
+			Interval: cfg.Digest.Interval,
+		})
+		readingDigest.Start()
+		log.Println("Reading digest scheduler started")
+	}
+
+	// Start the reminder scheduler for events, task due dates and habits
+	var reminderScheduler *services.ReminderScheduler
+	if !cfg.App.DemoMode && cfg.Reminders.Enabled {
+		reminderScheduler = services.NewReminderScheduler(config.GetDB(), services.ReminderSchedulerOptions{
+			Interval: cfg.Reminders.Interval,
+			Horizon:  cfg.Reminders.Horizon,
+			Grace:    cfg.Reminders.Grace,
+		})
+		reminderScheduler.Start()
+		log.Println("Reminder scheduler started")
+	}
+
+	// Collaborative editing sessions for notes and wiki pages
+	collabService := services.NewCollabService(config.GetDB(), services.GetMessagesHub(), services.CollabOptions{
+		SnapshotInterval: cfg.Collab.SnapshotInterval,
+		HistoryLimit:     cfg.Collab.HistoryLimit,
+	})
+	services.SetCollabService(collabService)
+	collabService.Start()
+
 	// Seed demo data in background
 	// go func() {
 	//	SeedData()
@@ -318,9 +388,88 @@ func main() {
 			bookmarks.GET("/:id", handlers.GetBookmark)
 			bookmarks.PUT("/:id", handlers.UpdateBookmark)
 			bookmarks.DELETE("/:id", handlers.DeleteBookmark)
+			bookmarks.POST("/bulk", handlers.BulkUpdateBookmarks)
 			bookmarks.POST("/:id/refresh-metadata", handlers.RefreshBookmarkMetadata)
 			bookmarks.POST("/metadata", handlers.GetBookmarkMetadata)
 			bookmarks.POST("/content", handlers.GetBookmarkContent)
+
+			// Import and export
+			bookmarks.POST("/import", handlers.ImportBookmarks)
+			bookmarks.GET("/imports", handlers.GetBookmarkImports)
+			bookmarks.GET("/imports/:id", handlers.GetBookmarkImport)
+			bookmarks.GET("/export", handlers.ExportBookmarks)
+
+			// Duplicate detection
+			bookmarks.GET("/duplicates", handlers.GetDuplicateBookmarks)
+			bookmarks.POST("/merge", handlers.MergeBookmarks)
+			bookmarks.GET("/canonical-rules", handlers.GetCanonicalURLRules)
+			bookmarks.POST("/canonical-rules", handlers.CreateCanonicalURLRule)
+			bookmarks.PUT("/canonical-rules/:id", handlers.UpdateCanonicalURLRule)
+			bookmarks.DELETE("/canonical-rules/:id", handlers.DeleteCanonicalURLRule)
+
+			// Link health
+			bookmarks.GET("/health", handlers.GetBookmarksHealth)
+			bookmarks.GET("/:id/health", handlers.GetBookmarkHealth)
+			bookmarks.POST("/:id/check", handlers.CheckBookmarkLink)
+
+			// Offline snapshots
+			bookmarks.POST("/:id/snapshots", handlers.CreateBookmarkSnapshot)
+			bookmarks.GET("/:id/snapshots", handlers.GetBookmarkSnapshots)
+			bookmarks.GET("/:id/snapshots/:snapshotId", handlers.GetBookmarkSnapshot)
+			bookmarks.GET("/:id/snapshots/:snapshotId/download", handlers.DownloadBookmarkSnapshot)
+			bookmarks.GET("/:id/snapshots/:snapshotId/view", handlers.ViewBookmarkSnapshot)
+			bookmarks.DELETE("/:id/snapshots/:snapshotId", handlers.DeleteBookmarkSnapshot)
+			bookmarks.GET("/:id/collections", handlers.GetBookmarkCollections)
+
+			// Highlights and annotations
+			bookmarks.GET("/highlights/export", handlers.ExportTagHighlights)
+			bookmarks.GET("/:id/highlights", handlers.GetBookmarkHighlights)
+			bookmarks.POST("/:id/highlights", handlers.CreateBookmarkHighlight)
+			bookmarks.GET("/:id/highlights/export", handlers.ExportBookmarkHighlights)
+			bookmarks.PUT("/:id/highlights/:highlightId", handlers.UpdateBookmarkHighlight)
+			bookmarks.DELETE("/:id/highlights/:highlightId", handlers.DeleteBookmarkHighlight)
+		}
+
+		// Collection routes (protected)
+		collections := v1.Group("/collections")
+		collections.Use(handlers.AuthMiddleware())
+		collections.Use(middleware.DemoModeMiddleware())
+		{
+			collections.GET("", handlers.GetCollections)
+			collections.POST("", handlers.CreateCollection)
+			collections.GET("/:id", handlers.GetCollection)
+			collections.PUT("/:id", handlers.UpdateCollection)
+			collections.DELETE("/:id", handlers.DeleteCollection)
+			collections.POST("/:id/move", handlers.MoveCollection)
+			collections.POST("/:id/bookmarks", handlers.AddCollectionBookmarks)
+			collections.PUT("/:id/bookmarks/order", handlers.ReorderCollectionBookmarks)
+			collections.DELETE("/:id/bookmarks/:bookmarkId", handlers.RemoveCollectionBookmark)
+			collections.POST("/:id/share", handlers.ShareCollection)
+			collections.GET("/:id/shares", handlers.GetCollectionShares)
+			collections.DELETE("/:id/shares/:shareId", handlers.DeleteCollectionShare)
+		}
+
+		// Feed subscription routes (protected)
+		feeds := v1.Group("/feeds")
+		feeds.Use(handlers.AuthMiddleware())
+		feeds.Use(middleware.DemoModeMiddleware())
+		{
+			feeds.GET("", handlers.GetFeeds)
+			feeds.POST("", handlers.SubscribeFeed)
+			feeds.GET("/items", handlers.GetFeedItems)
+			feeds.POST("/items/read", handlers.MarkFeedItemsRead)
+			feeds.POST("/items/:itemId/save", handlers.SaveFeedItem)
+			feeds.GET("/rules", handlers.GetFeedRules)
+			feeds.POST("/rules", handlers.CreateFeedRule)
+			feeds.PUT("/rules/:id", handlers.UpdateFeedRule)
+			feeds.DELETE("/rules/:id", handlers.DeleteFeedRule)
+			feeds.POST("/import", handlers.ImportOPML)
+			feeds.GET("/export", handlers.ExportOPML)
+			feeds.GET("/:id", handlers.GetFeed)
+			feeds.PUT("/:id", handlers.UpdateFeed)
+			feeds.DELETE("/:id", handlers.DeleteFeed)
+			feeds.POST("/:id/refresh", handlers.RefreshFeed)
+			feeds.POST("/:id/read", handlers.MarkFeedRead)
 		}
 
 		// Task routes (protected)
@@ -333,6 +482,110 @@ func main() {
 			tasks.GET("/:id", handlers.GetTask)
 			tasks.PUT("/:id", handlers.UpdateTask)
 			tasks.DELETE("/:id", handlers.DeleteTask)
+			tasks.POST("/bulk", handlers.BulkUpdateTasks)
+			tasks.POST("/:id/complete", handlers.CompleteTask)
+			tasks.PUT("/:id/recurrence", handlers.SetTaskRecurrence)
+			tasks.DELETE("/:id/recurrence", handlers.StopTaskRecurrence)
+			tasks.GET("/:id/occurrences", handlers.GetTaskOccurrences)
+			tasks.POST("/import/taskwarrior", handlers.ImportTaskwarrior)
+			tasks.GET("/export/taskwarrior", handlers.ExportTaskwarrior)
+			tasks.GET("/graph", handlers.GetTaskGraph)
+			tasks.GET("/burndown", handlers.GetTaskBurndown)
+			tasks.GET("/estimates/variance", handlers.GetEstimateVariance)
+			tasks.GET("/preferences", handlers.GetTaskPreferences)
+			tasks.PUT("/preferences", handlers.UpdateTaskPreferences)
+			tasks.GET("/:id/dependencies", handlers.GetTaskDependencies)
+			tasks.PUT("/:id/dependencies", handlers.SetTaskDependencies)
+			tasks.POST("/:id/dependencies/:dependency_id", handlers.AddTaskDependency)
+			tasks.DELETE("/:id/dependencies/:dependency_id", handlers.RemoveTaskDependency)
+			tasks.GET("/:id/timeline", handlers.GetTaskTimeline)
+			tasks.GET("/:id/history", handlers.GetTaskHistory)
+			tasks.GET("/:id/actuals", handlers.GetTaskActuals)
+			tasks.GET("/:id/comments", handlers.GetTaskComments)
+			tasks.POST("/:id/comments", handlers.CreateTaskComment)
+			tasks.PUT("/:id/comments/:comment_id", handlers.UpdateTaskComment)
+			tasks.DELETE("/:id/comments/:comment_id", handlers.DeleteTaskComment)
+			tasks.GET("/:id/attachments", handlers.GetTaskAttachments)
+			tasks.POST("/:id/attachments", handlers.CreateTaskAttachment)
+			tasks.DELETE("/:id/attachments/:attachment_id", handlers.DeleteTaskAttachment)
+		}
+
+		// Kanban board routes (protected)
+		boards := v1.Group("/boards")
+		boards.Use(handlers.AuthMiddleware())
+		boards.Use(middleware.DemoModeMiddleware())
+		{
+			boards.GET("", handlers.GetBoards)
+			boards.POST("", handlers.CreateBoard)
+			boards.GET("/:id", handlers.GetBoard)
+			boards.PUT("/:id", handlers.UpdateBoard)
+			boards.DELETE("/:id", handlers.DeleteBoard)
+			boards.POST("/:id/columns", handlers.AddBoardColumn)
+			boards.PUT("/:id/columns/order", handlers.ReorderBoardColumns)
+			boards.PUT("/:id/columns/:column_id", handlers.UpdateBoardColumn)
+			boards.DELETE("/:id/columns/:column_id", handlers.DeleteBoardColumn)
+			boards.POST("/:id/cards/:task_id/move", handlers.MoveBoardCard)
+		}
+
+		// Quick-add routes (protected)
+		quickAdd := v1.Group("/quick-add")
+		quickAdd.Use(handlers.AuthMiddleware())
+		quickAdd.Use(middleware.DemoModeMiddleware())
+		{
+			quickAdd.POST("", handlers.QuickAdd)
+			quickAdd.POST("/preview", handlers.PreviewQuickAdd)
+		}
+
+		// Reminder routes (protected)
+		reminders := v1.Group("/reminders")
+		reminders.Use(handlers.AuthMiddleware())
+		reminders.Use(middleware.DemoModeMiddleware())
+		{
+			reminders.GET("", handlers.GetReminders)
+			reminders.POST("/:id/snooze", handlers.SnoozeReminder)
+			reminders.POST("/:id/dismiss", handlers.DismissReminder)
+		}
+
+		// Notification center routes (protected)
+		notifications := v1.Group("/notifications")
+		notifications.Use(handlers.AuthMiddleware())
+		notifications.Use(middleware.DemoModeMiddleware())
+		{
+			notifications.GET("", handlers.GetNotifications)
+			notifications.POST("/:id/read", handlers.MarkNotificationRead)
+			notifications.POST("/read-all", handlers.MarkAllNotificationsRead)
+			notifications.GET("/push/public-key", handlers.GetPushPublicKey)
+			notifications.POST("/push/subscriptions", handlers.SubscribePush)
+			notifications.DELETE("/push/subscriptions", handlers.UnsubscribePush)
+		}
+
+		// Reading queue routes (protected)
+		readingQueue := v1.Group("/reading-queue")
+		readingQueue.Use(handlers.AuthMiddleware())
+		readingQueue.Use(middleware.DemoModeMiddleware())
+		{
+			readingQueue.GET("", handlers.GetReadingQueue)
+			readingQueue.POST("/shown", handlers.MarkReadingQueueShown)
+			readingQueue.GET("/stats", handlers.GetReadingStats)
+			readingQueue.GET("/preferences", handlers.GetReadingQueuePreferences)
+			readingQueue.PUT("/preferences", handlers.UpdateReadingQueuePreferences)
+			readingQueue.POST("/:bookmarkId/snooze", handlers.SnoozeReadingQueueItem)
+			readingQueue.POST("/:bookmarkId/read", handlers.MarkReadingQueueItemRead)
+		}
+
+		// Tag routes (protected)
+		tags := v1.Group("/tags")
+		tags.Use(handlers.AuthMiddleware())
+		tags.Use(middleware.DemoModeMiddleware())
+		{
+			tags.GET("", handlers.GetTags)
+			tags.POST("", handlers.CreateTag)
+			tags.GET("/:id", handlers.GetTag)
+			tags.PUT("/:id", handlers.UpdateTag)
+			tags.DELETE("/:id", handlers.DeleteTag)
+			tags.POST("/:id/merge", handlers.MergeTags)
+			tags.POST("/:id/aliases", handlers.AddTagAlias)
+			tags.DELETE("/:id/aliases/:aliasId", handlers.DeleteTagAlias)
 		}
 
 		// File routes (protected)
@@ -348,6 +601,7 @@ func main() {
 			files.GET("/:id/shares", handlers.GetFileShares)
 			files.DELETE("/:id/shares/:shareId", handlers.DeleteFileShare)
 			files.DELETE("/:id", handlers.DeleteFile)
+			files.POST("/bulk", handlers.BulkUpdateFiles)
 
 			// Encrypted files
 			files.POST("/upload/encrypted", handlers.UploadEncryptedFile)
@@ -428,11 +682,45 @@ func main() {
 			notes.GET("/:id", handlers.GetNote)
 			notes.PUT("/:id", handlers.UpdateNote)
 			notes.DELETE("/:id", handlers.DeleteNote)
+			notes.POST("/bulk", handlers.BulkUpdateNotes)
 			notes.GET("/stats", handlers.GetNoteStats)
 
 			// Encrypted notes
 			notes.POST("/encrypted", handlers.CreateEncryptedNote)
 			notes.GET("/:id/encrypted", handlers.GetEncryptedNote)
+
+			// Revisions
+			notes.GET("/:id/revisions", handlers.GetNoteRevisions)
+			notes.GET("/:id/revisions/:number", handlers.GetNoteRevision)
+			notes.POST("/:id/revisions/:number/restore", handlers.RestoreNoteRevision)
+			notes.GET("/:id/diff", handlers.GetNoteDiff)
+
+			// Wikilinks and the knowledge graph
+			notes.GET("/graph", handlers.GetKnowledgeGraph)
+			notes.GET("/backlinks", handlers.GetBacklinks)
+			notes.GET("/unlinked-mentions", handlers.GetUnlinkedMentions)
+			notes.POST("/links/reindex", handlers.ReindexNoteLinks)
+			notes.GET("/:id/links", handlers.GetNoteLinks)
+			notes.GET("/:id/backlinks", handlers.GetNoteBacklinks)
+			notes.GET("/:id/unlinked-mentions", handlers.GetNoteUnlinkedMentions)
+
+			// Obsidian vaults; folder sync runs through the integration
+			notes.POST("/import/obsidian", handlers.ImportObsidianVault)
+			notes.GET("/export/obsidian", handlers.ExportObsidianVault)
+		}
+
+		// Note and wiki page templates
+		templates := v1.Group("/templates")
+		templates.Use(handlers.AuthMiddleware())
+		templates.Use(middleware.DemoModeMiddleware())
+		{
+			templates.GET("", handlers.GetTemplates)
+			templates.POST("", handlers.CreateTemplate)
+			templates.GET("/:id", handlers.GetTemplate)
+			templates.PUT("/:id", handlers.UpdateTemplate)
+			templates.DELETE("/:id", handlers.DeleteTemplate)
+			templates.POST("/:id/instantiate", handlers.InstantiateTemplate)
+			templates.POST("/:id/publish", handlers.PublishTemplate)
 		}
 
 		// Chat routes (protected)
@@ -770,6 +1058,7 @@ func main() {
 			marketplace.POST("/items", marketplaceHandler.CreateMarketplaceItem)
 			marketplace.PUT("/items/:id", marketplaceHandler.UpdateMarketplaceItem)
 			marketplace.DELETE("/items/:id", marketplaceHandler.DeleteMarketplaceItem)
+			marketplace.POST("/items/:id/install", marketplaceHandler.InstallMarketplaceItem)
 
 			// Reviews
 			marketplace.POST("/items/:id/reviews", marketplaceHandler.CreateMarketplaceReview)
@@ -831,6 +1120,7 @@ func main() {
 			browserExt.POST("/register", handlers.RegisterBrowserExtension)
 			browserExt.GET("/extensions", handlers.GetBrowserExtensions)
 			browserExt.DELETE("/extensions/:id", handlers.RevokeBrowserExtension)
+			browserExt.POST("/highlights", handlers.PushExtensionHighlights)
 
 			// Public endpoints (for extension validation)
 			browserExt.GET("/validate", handlers.ValidateAPIKey)
@@ -860,6 +1150,20 @@ func main() {
 	middleware.CleanupSessionsOnShutdown()
 	log.Println("Sessions cleaned up")
 
+	if linkChecker != nil {
+		linkChecker.Stop()
+	}
+	if feedPoller != nil {
+		feedPoller.Stop()
+	}
+	if readingDigest != nil {
+		readingDigest.Stop()
+	}
+	if reminderScheduler != nil {
+		reminderScheduler.Stop()
+	}
+	collabService.Stop()
+
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
@@ -35,6 +35,11 @@ func AuditMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Handlers that record their own entry mark the request
+		if c.GetBool(auditRecordedKey) {
+			return
+		}
+
 		// Create audit log entry with proper user data from session
 		userIDValue := GetUserIDFromSession(c)
 		userEmail := GetUserEmailFromSession(c)
@@ -74,6 +79,40 @@ func AuditMiddleware() gin.HandlerFunc {
 	}
 }
 
+// auditRecordedKey marks requests whose handler wrote its own audit entry
+const auditRecordedKey = "auditRecorded"
+
+// NewRequestAuditLog returns an audit entry filled in with the request's
+// user and client details, for handlers that record their own entries.
+// Call MarkAuditRecorded once it is saved.
+func NewRequestAuditLog(c *gin.Context) *models.AuditLog {
+	auditLog := &models.AuditLog{
+		UserID:    GetUserIDFromSession(c),
+		UserEmail: GetUserEmailFromSession(c),
+		UserIP:    c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		SessionID: getSessionID(c),
+		Country:   getCountryFromIP(c.ClientIP()),
+		Device:    getDeviceFromUserAgent(c.Request.UserAgent()),
+		Platform:  getPlatformFromUserAgent(c.Request.UserAgent()),
+		Browser:   getBrowserFromUserAgent(c.Request.UserAgent()),
+		Success:   true,
+	}
+
+	// AuthMiddleware stores the user rather than the email
+	if user, ok := c.Get("user"); ok && auditLog.UserEmail == "unknown" {
+		if u, ok := user.(models.User); ok && u.Email != "" {
+			auditLog.UserEmail = u.Email
+		}
+	}
+	return auditLog
+}
+
+// MarkAuditRecorded stops AuditMiddleware from logging the request again
+func MarkAuditRecorded(c *gin.Context) {
+	c.Set(auditRecordedKey, true)
+}
+
 // LogSecurityEvent logs security-related events
 func LogSecurityEvent(userID uint, userEmail, action, description, failureReason string, details map[string]interface{}) {
 	auditLog := &models.AuditLog{
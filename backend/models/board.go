@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BoardSwimlane decides how the cards of a board are split into rows
+type BoardSwimlane string
+
+const (
+	BoardSwimlaneNone     BoardSwimlane = "none"
+	BoardSwimlanePriority BoardSwimlane = "priority"
+	BoardSwimlaneAssignee BoardSwimlane = "assignee"
+)
+
+// WIPPolicy decides what happens when a move would exceed a column's limit
+type WIPPolicy string
+
+const (
+	WIPPolicyWarn   WIPPolicy = "warn"
+	WIPPolicyReject WIPPolicy = "reject"
+)
+
+// Board is a kanban view over tasks. A personal board shows the owner's
+// tasks, optionally only those under one tag; a team board shows the tasks
+// shared with the team.
+type Board struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	UserID uint  `json:"user_id" gorm:"not null;index"`
+	TeamID *uint `json:"team_id,omitempty" gorm:"index"`
+	TagID  *uint `json:"tag_id,omitempty"`
+
+	Name        string        `json:"name" gorm:"not null"`
+	Description string        `json:"description"`
+	Swimlane    BoardSwimlane `json:"swimlane" gorm:"default:none"`
+
+	Columns []BoardColumn `json:"columns,omitempty" gorm:"foreignKey:BoardID"`
+}
+
+// BoardColumn is a column of a board. Several columns may map to the same
+// status, e.g. "Doing" and "Review" both being in progress.
+type BoardColumn struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	BoardID  uint       `json:"board_id" gorm:"not null;index"`
+	Name     string     `json:"name" gorm:"not null"`
+	Status   TaskStatus `json:"status" gorm:"not null"`
+	Position int        `json:"position"`
+
+	// WIPLimit caps the cards in the column; 0 means no limit
+	WIPLimit  int       `json:"wip_limit" gorm:"column:wip_limit;default:0"`
+	WIPPolicy WIPPolicy `json:"wip_policy" gorm:"column:wip_policy;default:warn"`
+}
+
+// BoardCard records where a task sits on a board. Tasks without a card
+// are shown at the end of the first column matching their status.
+type BoardCard struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	BoardID  uint `json:"board_id" gorm:"not null;uniqueIndex:idx_board_card_task"`
+	TaskID   uint `json:"task_id" gorm:"not null;uniqueIndex:idx_board_card_task"`
+	ColumnID uint `json:"column_id" gorm:"not null;index"`
+	Position int  `json:"position"`
+}
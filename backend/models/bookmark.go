@@ -20,6 +20,9 @@ type Bookmark struct {
 	URL         string `json:"url" gorm:"not null"`
 	Description string `json:"description"`
 
+	// CanonicalKey is the normalized form of URL used to detect duplicates
+	CanonicalKey string `json:"canonical_key" gorm:"index"`
+
 	// Organization
 	Tags []Tag `json:"tags,omitempty" gorm:"many2many:bookmark_tags;"`
 
@@ -29,11 +32,23 @@ type Bookmark struct {
 	IsRead     bool   `json:"is_read" gorm:"default:false"`
 	IsFavorite bool   `json:"is_favorite" gorm:"default:false"`
 
-	// Content extraction
-	Content     string     `json:"content"`
-	Author      string     `json:"author"`
-	PublishedAt *time.Time `json:"published_at"`
+	// Content extraction. Content holds the reader-mode text and
+	// ContentHTML the same article as sanitized HTML.
+	Content            string     `json:"content"`
+	ContentHTML        string     `json:"content_html" gorm:"type:text"`
+	Author             string     `json:"author"`
+	PublishedAt        *time.Time `json:"published_at"`
+	WordCount          int        `json:"word_count" gorm:"default:0"`
+	ReadingTime        int        `json:"reading_time" gorm:"default:0"` // minutes
+	ContentExtractedAt *time.Time `json:"content_extracted_at"`
 
 	// Reading tracking
 	ReadAt *time.Time `json:"read_at"`
+
+	// Link health, maintained by the background link checker
+	LinkStatus       LinkStatus `json:"link_status" gorm:"index"`
+	LinkStatusCode   int        `json:"link_status_code"`
+	RedirectURL      string     `json:"redirect_url"`
+	LinkFailureCount int        `json:"link_failure_count" gorm:"default:0"`
+	LinkCheckedAt    *time.Time `json:"link_checked_at" gorm:"index"`
 }
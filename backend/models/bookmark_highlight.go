@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BookmarkHighlight is a highlighted passage of a bookmark's reader-mode
+// content, optionally with a note. The passage is anchored by the quoted
+// text and the text around it, so it can be found again after the content
+// is re-extracted; the offsets only speed up and disambiguate the lookup.
+type BookmarkHighlight struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	UserID     uint `json:"user_id" gorm:"not null;index"`
+	BookmarkID uint `json:"bookmark_id" gorm:"not null;index"`
+
+	// Text quote selector
+	Exact  string `json:"exact" gorm:"type:text;not null"`
+	Prefix string `json:"prefix"`
+	Suffix string `json:"suffix"`
+
+	// Text position selector, in characters of Bookmark.Content
+	StartOffset int `json:"start_offset"`
+	EndOffset   int `json:"end_offset"`
+
+	// IsOrphaned is set when the quote can no longer be found in the content
+	IsOrphaned bool `json:"is_orphaned" gorm:"default:false"`
+
+	Note  string `json:"note" gorm:"type:text"`
+	Color string `json:"color" gorm:"default:yellow"`
+
+	// Source is "app" or "extension"; PageURL is the live page the
+	// extension highlighted on
+	Source  string `json:"source" gorm:"default:app"`
+	PageURL string `json:"page_url"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BookmarkImportStatus represents the processing state of a bookmark import
+type BookmarkImportStatus string
+
+const (
+	BookmarkImportPending    BookmarkImportStatus = "pending"
+	BookmarkImportProcessing BookmarkImportStatus = "processing"
+	BookmarkImportCompleted  BookmarkImportStatus = "completed"
+	BookmarkImportFailed     BookmarkImportStatus = "failed"
+)
+
+// BookmarkImport tracks a background bookmark import and its outcome
+type BookmarkImport struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	UserID uint `json:"user_id" gorm:"not null;index"`
+	User   User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+
+	// Source information
+	Source   string `json:"source" gorm:"not null;index"` // netscape, pocket, pocket_csv, raindrop, pinboard, wallabag, linkwarden
+	FileName string `json:"file_name"`
+
+	// Processing status
+	Status       BookmarkImportStatus `json:"status" gorm:"default:pending;index"`
+	Progress     float64              `json:"progress" gorm:"default:0"` // 0-100
+	ErrorMessage string               `json:"error_message" gorm:"type:text"`
+
+	// Import statistics
+	TotalItems     int `json:"total_items"`
+	ProcessedItems int `json:"processed_items"`
+	CreatedCount   int `json:"created_count"`
+	DuplicateCount int `json:"duplicate_count"`
+	FailedCount    int `json:"failed_count"`
+
+	// Details about skipped and failed entries (capped)
+	Duplicates []string `json:"duplicates" gorm:"serializer:json"`
+	Failures   []string `json:"failures" gorm:"serializer:json"`
+
+	// Timing
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+func (i *BookmarkImport) BeforeCreate(tx *gorm.DB) error {
+	if i.Status == "" {
+		i.Status = BookmarkImportPending
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LinkStatus represents the health of a bookmarked URL
+type LinkStatus string
+
+const (
+	LinkStatusUnchecked  LinkStatus = ""
+	LinkStatusOK         LinkStatus = "ok"
+	LinkStatusRedirected LinkStatus = "redirected" // temporary redirect (302, 303, 307)
+	LinkStatusMoved      LinkStatus = "moved"      // permanent redirect (301, 308)
+	LinkStatusError      LinkStatus = "error"      // transient failure such as a timeout or 5xx
+	LinkStatusBroken     LinkStatus = "broken"     // 404/410, unresolvable host or repeated failures
+)
+
+// BookmarkLinkCheck records the outcome of a single link check
+type BookmarkLinkCheck struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	UserID     uint     `json:"user_id" gorm:"not null;index"`
+	BookmarkID uint     `json:"bookmark_id" gorm:"not null;index"`
+	Bookmark   Bookmark `json:"-" gorm:"foreignKey:BookmarkID"`
+
+	// Result
+	CheckedAt      time.Time  `json:"checked_at" gorm:"index"`
+	Status         LinkStatus `json:"status"`
+	StatusCode     int        `json:"status_code"`
+	FinalURL       string     `json:"final_url"`
+	RedirectCount  int        `json:"redirect_count"`
+	ResponseTimeMs int64      `json:"response_time_ms"`
+	ErrorMessage   string     `json:"error_message" gorm:"type:text"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// BookmarkSnapshotStatus represents the processing state of a page snapshot
+type BookmarkSnapshotStatus string
+
+const (
+	BookmarkSnapshotPending    BookmarkSnapshotStatus = "pending"
+	BookmarkSnapshotProcessing BookmarkSnapshotStatus = "processing"
+	BookmarkSnapshotCompleted  BookmarkSnapshotStatus = "completed"
+	BookmarkSnapshotFailed     BookmarkSnapshotStatus = "failed"
+)
+
+// BookmarkSnapshot is an offline, self-contained copy of a bookmarked page.
+// Each archive run creates a new version; older versions are kept.
+type BookmarkSnapshot struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	UserID     uint     `json:"user_id" gorm:"not null;index"`
+	BookmarkID uint     `json:"bookmark_id" gorm:"not null;uniqueIndex:idx_bookmark_snapshot_version"`
+	Bookmark   Bookmark `json:"-" gorm:"foreignKey:BookmarkID"`
+	Version    int      `json:"version" gorm:"not null;uniqueIndex:idx_bookmark_snapshot_version"`
+
+	// Processing status
+	Status       BookmarkSnapshotStatus `json:"status" gorm:"default:pending;index"`
+	ErrorMessage string                 `json:"error_message" gorm:"type:text"`
+
+	// Captured page
+	SourceURL  string     `json:"source_url" gorm:"not null"`
+	FinalURL   string     `json:"final_url"`
+	Title      string     `json:"title"`
+	CapturedAt *time.Time `json:"captured_at,omitempty"`
+
+	// Single-file HTML archive
+	FilePath    string `json:"-"`
+	FileSize    int64  `json:"file_size"`
+	ContentHash string `json:"content_hash"` // SHA-256 of the HTML archive
+
+	// Optional WARC record of the raw responses
+	IncludeWARC bool   `json:"include_warc" gorm:"default:false"`
+	WARCPath    string `json:"-"`
+	WARCSize    int64  `json:"warc_size"`
+
+	// Inlined resources
+	ResourceCount   int      `json:"resource_count"`
+	FailedResources []string `json:"failed_resources" gorm:"serializer:json"`
+}
+
+func (s *BookmarkSnapshot) BeforeCreate(tx *gorm.DB) error {
+	if s.Status == "" {
+		s.Status = BookmarkSnapshotPending
+	}
+	return nil
+}
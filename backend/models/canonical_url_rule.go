@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// CanonicalURLRule customizes how URLs on a domain (and its subdomains) are
+// normalized when detecting duplicate bookmarks
+type CanonicalURLRule struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	UserID uint `json:"user_id" gorm:"not null;uniqueIndex:idx_user_canonical_domain"`
+	User   User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+
+	Domain string `json:"domain" gorm:"not null;uniqueIndex:idx_user_canonical_domain"`
+
+	// Normalization options
+	KeepFragment bool     `json:"keep_fragment" gorm:"default:false"`
+	DropQuery    bool     `json:"drop_query" gorm:"default:false"`
+	KeepParams   []string `json:"keep_params" gorm:"serializer:json"`  // when set, only these query params are kept
+	StripParams  []string `json:"strip_params" gorm:"serializer:json"` // removed in addition to known tracking params
+}
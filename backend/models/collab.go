@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Document types that can be edited together
+const (
+	CollabDocNote = "note"
+	CollabDocWiki = "wiki"
+)
+
+// CollabDocument tracks the collaborative editing history of a note or wiki
+// page. Version counts the operations applied so far; SnapshotVersion is the
+// last one written to the document itself, whose content then hashed to
+// SnapshotHash. A different hash on load means the document was edited
+// outside a session and its history no longer applies.
+type CollabDocument struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	DocType string `json:"doc_type" gorm:"not null;uniqueIndex:idx_collab_document"`
+	DocID   uint   `json:"doc_id" gorm:"not null;uniqueIndex:idx_collab_document"`
+
+	Version         int        `json:"version"`
+	SnapshotVersion int        `json:"snapshot_version"`
+	SnapshotHash    string     `json:"snapshot_hash"`
+	SnapshotAt      *time.Time `json:"snapshot_at,omitempty"`
+}
+
+// CollabOperation is one edit made in a collaborative editing session,
+// stored so clients that reconnect can catch up on what they missed and
+// edits made since the last snapshot survive a restart
+type CollabOperation struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	DocType string `json:"doc_type" gorm:"not null;uniqueIndex:idx_collab_operation"`
+	DocID   uint   `json:"doc_id" gorm:"not null;uniqueIndex:idx_collab_operation"`
+	// Version is the document version the operation produced
+	Version int `json:"version" gorm:"not null;uniqueIndex:idx_collab_operation"`
+
+	UserID   uint   `json:"user_id"`
+	ClientID string `json:"client_id"`
+	// Operation is the edit in the JSON format of ot.js
+	Operation string `json:"operation" gorm:"type:text"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Collection is a curated, manually ordered list of bookmarks. Collections
+// can be nested, and a bookmark can belong to any number of them.
+type Collection struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	UserID uint `json:"user_id" gorm:"not null;index"`
+	User   User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+
+	// ParentID is nil for top-level collections
+	ParentID *uint `json:"parent_id" gorm:"index"`
+	Position int   `json:"position" gorm:"default:0"`
+
+	Name        string `json:"name" gorm:"not null"`
+	Description string `json:"description"`
+	CoverImage  string `json:"cover_image"`
+	Color       string `json:"color"`
+
+	// Populated by the collection service, not stored
+	Children      []Collection `json:"children,omitempty" gorm:"-"`
+	BookmarkCount int64        `json:"bookmark_count" gorm:"-"`
+}
+
+// CollectionBookmark places a bookmark in a collection. Rows are removed
+// together with the collection; the bookmark itself is never touched.
+type CollectionBookmark struct {
+	CollectionID uint      `json:"collection_id" gorm:"primaryKey;autoIncrement:false"`
+	BookmarkID   uint      `json:"bookmark_id" gorm:"primaryKey;autoIncrement:false;index"`
+	Position     int       `json:"position" gorm:"not null;default:0"`
+	Note         string    `json:"note"`
+	AddedAt      time.Time `json:"added_at"`
+
+	Bookmark Bookmark `json:"bookmark,omitempty" gorm:"foreignKey:BookmarkID"`
+}
@@ -0,0 +1,91 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Feed is a user's subscription to an RSS, Atom or JSON Feed
+type Feed struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	UserID uint `json:"user_id" gorm:"not null;index;uniqueIndex:idx_user_feed_url"`
+	User   User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+
+	URL         string `json:"url" gorm:"not null;uniqueIndex:idx_user_feed_url"`
+	SiteURL     string `json:"site_url"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Category    string `json:"category"` // folder, kept for OPML round trips
+	Format      string `json:"format"`   // rss, atom, json
+
+	// HTTP caching validators from the last successful fetch
+	ETag         string `json:"-" gorm:"column:etag"`
+	LastModified string `json:"-"`
+
+	// Polling state
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+	NextFetchAt   *time.Time `json:"next_fetch_at" gorm:"index"`
+	LastError     string     `json:"last_error"`
+	ErrorCount    int        `json:"error_count" gorm:"default:0"`
+
+	// Populated by the feed service, not stored
+	UnreadCount int64 `json:"unread_count" gorm:"-"`
+}
+
+// FeedItem is one entry of a feed
+type FeedItem struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID uint `json:"user_id" gorm:"not null;index"`
+	FeedID uint `json:"feed_id" gorm:"not null;index;uniqueIndex:idx_feed_item_guid"`
+	Feed   Feed `json:"feed,omitempty" gorm:"foreignKey:FeedID"`
+
+	// GUID identifies the item within its feed, falling back to the link
+	GUID string `json:"guid" gorm:"not null;uniqueIndex:idx_feed_item_guid"`
+
+	URL         string     `json:"url"`
+	Title       string     `json:"title"`
+	Author      string     `json:"author"`
+	Summary     string     `json:"summary" gorm:"type:text"`
+	Content     string     `json:"content" gorm:"type:text"`
+	Categories  []string   `json:"categories" gorm:"serializer:json"`
+	PublishedAt *time.Time `json:"published_at" gorm:"index"`
+
+	IsRead bool       `json:"is_read" gorm:"default:false;index"`
+	ReadAt *time.Time `json:"read_at"`
+
+	// BookmarkID is set once the item has been saved as a bookmark
+	BookmarkID *uint `json:"bookmark_id" gorm:"index"`
+}
+
+// FeedRule automatically saves matching feed items as bookmarks
+type FeedRule struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID uint `json:"user_id" gorm:"not null;index"`
+
+	// FeedID limits the rule to one feed; nil applies it to all feeds
+	FeedID *uint `json:"feed_id" gorm:"index"`
+
+	Name string `json:"name"`
+
+	// An item matches when its title or text contains any keyword, or when
+	// one of its categories equals one of MatchTags (case-insensitive)
+	Keywords  []string `json:"keywords" gorm:"serializer:json"`
+	MatchTags []string `json:"match_tags" gorm:"serializer:json"`
+
+	// AddTags are applied to the bookmarks created by the rule
+	AddTags []string `json:"add_tags" gorm:"serializer:json"`
+
+	IsActive   bool `json:"is_active" gorm:"default:true"`
+	MatchCount int  `json:"match_count" gorm:"default:0"`
+}
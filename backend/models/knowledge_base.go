@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"regexp"
 	"strings"
 	"time"
@@ -55,8 +56,7 @@ type WikiPage struct {
 	Versions    []WikiVersion    `json:"versions,omitempty" gorm:"foreignKey:WikiPageID"`
 	Backlinks   []WikiBacklink   `json:"backlinks,omitempty" gorm:"foreignKey:TargetPageID"`
 	Attachments []WikiAttachment `json:"attachments,omitempty" gorm:"foreignKey:WikiPageID"`
-	Bookmarks   []Bookmark       `json:"bookmarks,omitempty" gorm:"foreignKey:WikiPageID"`
-	Notes       []Note           `json:"notes,omitempty" gorm:"foreignKey:WikiPageID"`
+	// Notes link to pages with [[wiki:ID]] or [[Title]]; see NoteLink
 }
 
 // Category represents a category for organizing wiki pages
@@ -169,6 +169,9 @@ type Template struct {
 
 	// Template variables
 	Variables []TemplateVariable `json:"variables,omitempty" gorm:"foreignKey:TemplateID"`
+
+	// SourceItemID is the marketplace item a template was installed from
+	SourceItemID *uint `json:"source_item_id,omitempty" gorm:"index"`
 }
 
 // TemplateVariable represents variables in templates
@@ -177,15 +180,38 @@ type TemplateVariable struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 
-	TemplateID uint     `json:"template_id" gorm:"not null;index"`
-	Template   Template `json:"template,omitempty" gorm:"foreignKey:TemplateID"`
+	TemplateID uint `json:"template_id" gorm:"not null;index"`
 
-	Name         string `json:"name" gorm:"not null"`
-	Type         string `json:"type" gorm:"not null"` // text, number, date, select
-	DefaultValue string `json:"default_value"`
-	Required     bool   `json:"required" gorm:"default:false"`
-	Description  string `json:"description"`
-	Options      string `json:"options" gorm:"serializer:json"` // For select type
+	Name         string          `json:"name" gorm:"not null"`
+	Type         string          `json:"type" gorm:"not null"` // text, number, date, select
+	DefaultValue string          `json:"default_value"`
+	Required     bool            `json:"required" gorm:"default:false"`
+	Description  string          `json:"description"`
+	Options      TemplateOptions `json:"options,omitempty" gorm:"serializer:json"` // For select type
+}
+
+// TemplateOptions are the choices of a select variable. They were once
+// stored as a single string, which is still read as a comma or newline
+// separated list.
+type TemplateOptions []string
+
+func (o *TemplateOptions) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*o = list
+		return nil
+	}
+	var joined string
+	if err := json.Unmarshal(data, &joined); err != nil {
+		return err
+	}
+	*o = nil
+	for _, option := range strings.FieldsFunc(joined, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if option = strings.TrimSpace(option); option != "" {
+			*o = append(*o, option)
+		}
+	}
+	return nil
 }
 
 // BeforeCreate hooks
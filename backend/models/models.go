@@ -113,16 +113,53 @@ func AutoMigrate() error {
 	// allows startup migrations to create missing production tables reliably.
 	migrationDB := db.Session(&gorm.Session{PrepareStmt: true})
 
+	if err := dropGlobalTagNameIndex(migrationDB); err != nil {
+		return err
+	}
+
 	models := []struct {
 		name  string
 		model interface{}
 	}{
 		{name: "User", model: &User{}},
 		{name: "Tag", model: &Tag{}},
+		{name: "TagAlias", model: &TagAlias{}},
 		{name: "Bookmark", model: &Bookmark{}},
+		{name: "BookmarkHighlight", model: &BookmarkHighlight{}},
+		{name: "BookmarkImport", model: &BookmarkImport{}},
+		{name: "BookmarkLinkCheck", model: &BookmarkLinkCheck{}},
+		{name: "BookmarkSnapshot", model: &BookmarkSnapshot{}},
+		{name: "CanonicalURLRule", model: &CanonicalURLRule{}},
+		{name: "Collection", model: &Collection{}},
+		{name: "CollectionBookmark", model: &CollectionBookmark{}},
+		{name: "Feed", model: &Feed{}},
+		{name: "FeedItem", model: &FeedItem{}},
+		{name: "FeedRule", model: &FeedRule{}},
+		{name: "ReadingQueueItem", model: &ReadingQueueItem{}},
+		{name: "ReadingQueuePreference", model: &ReadingQueuePreference{}},
+		{name: "TaskRecurrence", model: &TaskRecurrence{}},
 		{name: "Task", model: &Task{}},
+		{name: "TaskPreference", model: &TaskPreference{}},
+		{name: "TaskComment", model: &TaskComment{}},
+		{name: "TaskAttachment", model: &TaskAttachment{}},
+		{name: "TaskChange", model: &TaskChange{}},
+		{name: "Board", model: &Board{}},
+		{name: "BoardColumn", model: &BoardColumn{}},
+		{name: "BoardCard", model: &BoardCard{}},
 		{name: "File", model: &File{}},
 		{name: "Note", model: &Note{}},
+		{name: "NoteRevision", model: &NoteRevision{}},
+		{name: "NoteLink", model: &NoteLink{}},
+		{name: "ObsidianFile", model: &ObsidianFile{}},
+		{name: "CollabDocument", model: &CollabDocument{}},
+		{name: "CollabOperation", model: &CollabOperation{}},
+		{name: "Category", model: &Category{}},
+		{name: "WikiPage", model: &WikiPage{}},
+		{name: "WikiVersion", model: &WikiVersion{}},
+		{name: "WikiBacklink", model: &WikiBacklink{}},
+		{name: "WikiAttachment", model: &WikiAttachment{}},
+		{name: "Template", model: &Template{}},
+		{name: "TemplateVariable", model: &TemplateVariable{}},
 		{name: "APIKey", model: &APIKey{}},
 		{name: "BrowserExtension", model: &BrowserExtension{}},
 		{name: "TimeEntry", model: &TimeEntry{}},
@@ -139,6 +176,9 @@ func AutoMigrate() error {
 		{name: "CalendarEvent", model: &CalendarEvent{}},
 		{name: "RecurrenceRule", model: &RecurrenceRule{}},
 		{name: "CalendarSettings", model: &CalendarSettings{}},
+		{name: "Reminder", model: &Reminder{}},
+		{name: "Notification", model: &Notification{}},
+		{name: "PushSubscription", model: &PushSubscription{}},
 		{name: "ContentEmbedding", model: &ContentEmbedding{}},
 		{name: "SavedSearch", model: &SavedSearch{}},
 		{name: "SavedSearchTag", model: &SavedSearchTag{}},
@@ -172,6 +212,8 @@ func AutoMigrate() error {
 		{name: "HabitAnalytics", model: &HabitAnalytics{}},
 		{name: "Goal", model: &Goal{}},
 		{name: "Milestone", model: &Milestone{}},
+		{name: "Habit", model: &Habit{}},
+		{name: "HabitEntry", model: &HabitEntry{}},
 		{name: "AnalyticsReport", model: &AnalyticsReport{}},
 		{name: "Skill", model: &Skill{}},
 		{name: "Project", model: &Project{}},
@@ -240,3 +282,15 @@ func AutoMigrate() error {
 
 	return nil
 }
+
+// dropGlobalTagNameIndex removes the unique index that made tag names global
+// across users; tag names are now unique per user (idx_user_tag_name)
+func dropGlobalTagNameIndex(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&Tag{}) || !migrator.HasIndex(&Tag{}, "idx_tags_name") {
+		return nil
+	}
+
+	log.Println("Dropping global unique index on tag names")
+	return migrator.DropIndex(&Tag{}, "idx_tags_name")
+}
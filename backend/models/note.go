@@ -30,6 +30,9 @@ type Note struct {
 	Description string `json:"description"`
 	IsPublic    bool   `json:"is_public" gorm:"default:false"`
 	IsPinned    bool   `json:"is_pinned" gorm:"default:false"`
+	// Properties holds front matter fields that have no column of their
+	// own, such as Obsidian aliases
+	Properties map[string]interface{} `json:"properties,omitempty" gorm:"serializer:json"`
 
 	// Formatting
 	ContentType string `json:"content_type" gorm:"default:markdown"` // markdown, html, plain
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Wikilink target types
+const (
+	LinkTargetNote     = "note"
+	LinkTargetWiki     = "wiki"
+	LinkTargetBookmark = "bookmark"
+	LinkTargetTask     = "task"
+)
+
+// NoteLink is a [[wikilink]] in a note's content. Links are rebuilt every
+// time the note is saved. A link whose target does not exist yet keeps its
+// TargetID unset and is resolved once a note with that title is saved.
+type NoteLink struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID uint `json:"user_id" gorm:"not null;index"`
+	// NoteID is the note the link is written in
+	NoteID uint `json:"note_id" gorm:"not null;index"`
+
+	// TargetType is note, wiki, bookmark or task; it is empty for an
+	// unresolved link written without a type
+	TargetType string `json:"target_type" gorm:"index:idx_note_link_target"`
+	TargetID   *uint  `json:"target_id,omitempty" gorm:"index:idx_note_link_target"`
+	// Target is the link as written, without alias or heading, e.g.
+	// "Weekly review" or "task:12"
+	Target string `json:"target" gorm:"not null"`
+	Alias  string `json:"alias,omitempty"`
+	// TargetTitle is filled in when links are listed
+	TargetTitle string `json:"target_title,omitempty" gorm:"-"`
+
+	// Context is the line of the first occurrence
+	Context     string `json:"context" gorm:"type:text"`
+	Occurrences int    `json:"occurrences" gorm:"default:1"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// NoteRevisionKind says how a note revision came about
+type NoteRevisionKind string
+
+const (
+	// NoteRevisionEdit is a save; rapid autosaves are coalesced into one
+	NoteRevisionEdit NoteRevisionKind = "edit"
+	// NoteRevisionBaseline is the state of a note from before revisions
+	// were kept, recorded on its first save
+	NoteRevisionBaseline NoteRevisionKind = "baseline"
+	// NoteRevisionRestore is a note brought back to an earlier revision
+	NoteRevisionRestore NoteRevisionKind = "restore"
+)
+
+// NoteRevision is a snapshot of a note after a save. Revisions of encrypted
+// notes hold the encrypted title and content, just like the note.
+type NoteRevision struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	NoteID uint `json:"note_id" gorm:"not null;uniqueIndex:idx_note_revision"`
+	Number int  `json:"number" gorm:"not null;uniqueIndex:idx_note_revision"`
+	// UserID is the author of the revision
+	UserID uint `json:"user_id" gorm:"not null;index"`
+
+	Kind NoteRevisionKind `json:"kind" gorm:"not null;default:edit"`
+	// RestoredFrom is the revision number a restore brought back
+	RestoredFrom *int `json:"restored_from,omitempty"`
+
+	Title       string `json:"title"`
+	Content     string `json:"content,omitempty" gorm:"type:text"`
+	Description string `json:"description"`
+	IsEncrypted bool   `json:"is_encrypted"`
+
+	// WordCount is left at zero for encrypted notes
+	WordCount int `json:"word_count"`
+	// SaveCount is the number of saves coalesced into the revision
+	SaveCount int `json:"save_count" gorm:"default:1"`
+}
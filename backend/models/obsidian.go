@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// ObsidianFile ties a file in an Obsidian vault to the note, folder note or
+// attachment it was imported as. Hash and NoteHash are the file and the
+// rendered note as of the last import, export or sync; a side whose hash no
+// longer matches has changed since.
+type ObsidianFile struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_obsidian_file"`
+	Vault  string `json:"vault" gorm:"not null;uniqueIndex:idx_obsidian_file"`
+	// Path is relative to the vault root with forward slashes. Folders end
+	// with a slash.
+	Path string `json:"path" gorm:"not null;uniqueIndex:idx_obsidian_file"`
+
+	NoteID *uint `json:"note_id,omitempty" gorm:"index"`
+	FileID *uint `json:"file_id,omitempty" gorm:"index"`
+
+	Hash     string    `json:"hash"`
+	NoteHash string    `json:"note_hash"`
+	ModTime  time.Time `json:"mod_time"`
+}
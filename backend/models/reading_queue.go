@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+// ReadingQueueItem holds the reading-queue state of an unread bookmark.
+// Rows are created the first time a bookmark is surfaced or snoozed.
+type ReadingQueueItem struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID     uint     `json:"user_id" gorm:"not null;index;uniqueIndex:idx_user_queue_bookmark"`
+	BookmarkID uint     `json:"bookmark_id" gorm:"not null;uniqueIndex:idx_user_queue_bookmark"`
+	Bookmark   Bookmark `json:"bookmark,omitempty" gorm:"foreignKey:BookmarkID"`
+
+	// SnoozedUntil hides the bookmark from the queue until the given time
+	SnoozedUntil *time.Time `json:"snoozed_until" gorm:"index"`
+
+	// Spaced resurfacing: each time an item is shown without being read
+	// the wait before it comes back grows
+	SurfaceCount   int        `json:"surface_count" gorm:"default:0"`
+	LastSurfacedAt *time.Time `json:"last_surfaced_at"`
+	NextSurfaceAt  *time.Time `json:"next_surface_at" gorm:"index"`
+}
+
+// ReadingQueuePreference holds a user's reading-queue settings
+type ReadingQueuePreference struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID uint `json:"user_id" gorm:"not null;uniqueIndex"`
+
+	// DailyCount is how many bookmarks make up the daily queue
+	DailyCount int `json:"daily_count" gorm:"default:5"`
+
+	// TagPriorities boosts (or, with negative values, buries) bookmarks by
+	// tag name. A tag's priority also applies to the tags below it.
+	TagPriorities map[string]int `json:"tag_priorities" gorm:"serializer:json"`
+
+	// Email digest of the daily queue, sent at DigestHour in the user's timezone
+	DigestEnabled    bool       `json:"digest_enabled" gorm:"default:false"`
+	DigestHour       int        `json:"digest_hour" gorm:"default:8"`
+	LastDigestSentAt *time.Time `json:"last_digest_sent_at"`
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+)
+
+// ReminderSource is what a reminder is about
+type ReminderSource string
+
+const (
+	ReminderSourceEvent ReminderSource = "event"
+	ReminderSourceTask  ReminderSource = "task"
+	ReminderSourceHabit ReminderSource = "habit"
+)
+
+// ReminderStatus is where a reminder is in its lifecycle
+type ReminderStatus string
+
+const (
+	ReminderStatusPending   ReminderStatus = "pending"   // waiting for RemindAt, also after a snooze
+	ReminderStatusSent      ReminderStatus = "sent"      // delivered
+	ReminderStatusDismissed ReminderStatus = "dismissed" // dismissed by the user
+	ReminderStatusCancelled ReminderStatus = "cancelled" // its event, task or habit changed before it fired
+	ReminderStatusExpired   ReminderStatus = "expired"   // missed by too much, e.g. while the server was down
+)
+
+// Reminder is one planned reminder for an occurrence of an event, a task's
+// due date or a habit. The occurrence is unique, so planning the same
+// window again after a restart never reminds twice.
+type Reminder struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID uint `json:"user_id" gorm:"not null;index"`
+
+	SourceType ReminderSource `json:"source_type" gorm:"not null;uniqueIndex:idx_reminder_occurrence"`
+	SourceID   uint           `json:"source_id" gorm:"not null;uniqueIndex:idx_reminder_occurrence"`
+	// OccursAt is when the event starts, the task is due or the habit is
+	// scheduled; RemindAt is when the reminder fires and moves on snooze
+	OccursAt time.Time `json:"occurs_at" gorm:"not null;uniqueIndex:idx_reminder_occurrence"`
+	RemindAt time.Time `json:"remind_at" gorm:"not null;index"`
+	Title    string    `json:"title"`
+
+	Status      ReminderStatus `json:"status" gorm:"default:pending;index"`
+	SentAt      *time.Time     `json:"sent_at"`
+	DismissedAt *time.Time     `json:"dismissed_at"`
+	SnoozeCount int            `json:"snooze_count" gorm:"default:0"`
+	Channels    string         `json:"channels"` // channels that delivered it, comma separated
+	LastError   string         `json:"last_error,omitempty"`
+}
+
+// Notification is an entry in the in-app notification center
+type Notification struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID uint `json:"user_id" gorm:"not null;index"`
+
+	Type       string     `json:"type" gorm:"not null"` // reminder
+	Title      string     `json:"title" gorm:"not null"`
+	Body       string     `json:"body"`
+	Link       string     `json:"link"`
+	ReminderID *uint      `json:"reminder_id,omitempty" gorm:"index"`
+	ReadAt     *time.Time `json:"read_at"`
+}
+
+// PushSubscription is a browser's Web Push endpoint for a user
+type PushSubscription struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID uint `json:"user_id" gorm:"not null;index"`
+
+	Endpoint   string     `json:"endpoint" gorm:"not null;uniqueIndex"`
+	P256dh     string     `json:"-" gorm:"not null"`
+	Auth       string     `json:"-" gorm:"not null"`
+	UserAgent  string     `json:"user_agent"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
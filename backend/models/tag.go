@@ -13,10 +13,13 @@ type Tag struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
 
-	UserID uint `json:"user_id" gorm:"not null;index"`
+	UserID uint `json:"user_id" gorm:"not null;index;uniqueIndex:idx_user_tag_name"`
 	User   User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 
-	Name        string `json:"name" gorm:"not null;uniqueIndex"`
+	// Name is the full path of the tag, with "/" separating levels
+	// (e.g. "dev/go/testing"). Names are unique per user.
+	Name        string `json:"name" gorm:"not null;uniqueIndex:idx_user_tag_name"`
+	ParentID    *uint  `json:"parent_id" gorm:"index"`
 	Description string `json:"description"`
 	Color       string `json:"color" gorm:"default:#39b9ff"` // Go-inspired blue
 	
@@ -28,4 +31,19 @@ type Tag struct {
 	Tasks     []Task     `json:"tasks,omitempty" gorm:"many2many:task_tags;"`
 	Files     []File     `json:"files,omitempty" gorm:"many2many:file_tags;"`
 	Notes     []Note     `json:"notes,omitempty" gorm:"many2many:note_tags;"`
+
+	Aliases  []TagAlias `json:"aliases,omitempty" gorm:"foreignKey:TagID"`
+	Children []Tag      `json:"children,omitempty" gorm:"-"`
+}
+
+// TagAlias is another name that resolves to a tag, e.g. "golang" for "go"
+type TagAlias struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID uint `json:"user_id" gorm:"not null;uniqueIndex:idx_user_tag_alias"`
+	TagID  uint `json:"tag_id" gorm:"not null;index"`
+
+	// Alias is stored lowercased
+	Alias string `json:"alias" gorm:"not null;uniqueIndex:idx_user_tag_alias"`
 }
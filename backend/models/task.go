@@ -43,10 +43,17 @@ type Task struct {
 	
 	// Organization
 	Tags []Tag `json:"tags,omitempty" gorm:"many2many:task_tags;"`
+
+	// Assignee is who works on the task, for tasks shared with a team
+	AssigneeID *uint `json:"assignee_id,omitempty" gorm:"index"`
+	Assignee   *User `json:"assignee,omitempty" gorm:"foreignKey:AssigneeID"`
 	
 	// Scheduling
 	DueDate     *time.Time `json:"due_date"`
 	CompletedAt *time.Time `json:"completed_at"`
+	// EstimatedMinutes is the expected effort, used for the critical path
+	// and compared with tracked time in estimate reports
+	EstimatedMinutes int `json:"estimated_minutes" gorm:"default:0"`
 	
 	// Progress tracking
 	Progress int `json:"progress" gorm:"default:0"` // 0-100 percentage
@@ -56,6 +63,71 @@ type Task struct {
 	ParentTask   *Task `json:"parent_task,omitempty" gorm:"foreignKey:ParentTaskID"`
 	Subtasks     []Task `json:"subtasks,omitempty" gorm:"foreignKey:ParentTaskID"`
 	
-	// Dependencies
+	// Dependencies are the prerequisites of this task. Blocked is derived:
+	// it is set when any prerequisite is still open.
 	Dependencies []Task `json:"dependencies,omitempty" gorm:"many2many:task_dependencies;"`
+	Blocked      bool   `json:"blocked" gorm:"-"`
+
+	// Recurrence: each occurrence of a recurring task is its own row, so
+	// completed occurrences stay behind as history
+	RecurrenceID    *uint           `json:"recurrence_id,omitempty" gorm:"index"`
+	Recurrence      *TaskRecurrence `json:"recurrence,omitempty" gorm:"foreignKey:RecurrenceID"`
+	OccurrenceIndex int             `json:"occurrence_index,omitempty"`
+
+	// Tasks imported from Todoist or Taskwarrior keep the source's ID, so
+	// importing again updates them instead of creating duplicates
+	ExternalSource string `json:"external_source,omitempty" gorm:"index:idx_task_external"`
+	ExternalID     string `json:"external_id,omitempty" gorm:"index:idx_task_external"`
+}
+
+// RecurrenceMode decides what the next occurrence of a task is scheduled from
+type RecurrenceMode string
+
+const (
+	// RecurrenceFromDueDate keeps to the rule's schedule however late an
+	// occurrence is completed
+	RecurrenceFromDueDate RecurrenceMode = "due_date"
+	// RecurrenceFromCompletion restarts the rule from the completion date
+	RecurrenceFromCompletion RecurrenceMode = "completion_date"
+)
+
+// TaskRecurrence is a series of recurring tasks. It holds the RRULE and the
+// template that new occurrences are created from.
+type TaskRecurrence struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	UserID uint `json:"user_id" gorm:"not null;index"`
+
+	// Rule is an RFC 5545 RRULE value such as "FREQ=WEEKLY;BYDAY=MO,TH"
+	Rule string         `json:"rule" gorm:"not null"`
+	Mode RecurrenceMode `json:"mode" gorm:"default:due_date"`
+
+	// StartAt is the DTSTART the rule is counted from; Generated is how many
+	// occurrences exist since then, for COUNT
+	StartAt   time.Time  `json:"start_at"`
+	Generated int        `json:"generated" gorm:"default:1"`
+	EndedAt   *time.Time `json:"ended_at"`
+
+	// Template for future occurrences
+	Title        string       `json:"title"`
+	Description  string       `json:"description"`
+	Priority     TaskPriority `json:"priority"`
+	ParentTaskID *uint        `json:"parent_task_id,omitempty"`
+	Tags         []Tag        `json:"tags,omitempty" gorm:"many2many:task_recurrence_tags;"`
+}
+
+// TaskPreference holds a user's task settings
+type TaskPreference struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID uint `json:"user_id" gorm:"not null;uniqueIndex"`
+
+	// AllowCompletingBlocked lets tasks be completed while their
+	// prerequisites are still open. By default that is rejected.
+	AllowCompletingBlocked bool `json:"allow_completing_blocked" gorm:"default:false"`
 }
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TaskComment is a markdown comment on a task. Replies point at the comment
+// that starts their thread, so threads are one level deep.
+type TaskComment struct {
+	ID        uint           `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+
+	TaskID uint `json:"task_id" gorm:"not null;index"`
+	UserID uint `json:"user_id" gorm:"not null;index"`
+	User   User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+
+	ParentID *uint  `json:"parent_id,omitempty" gorm:"index"`
+	Body     string `json:"body" gorm:"type:text;not null"`
+	// MentionIDs are the users @mentioned in the body who can see the task
+	MentionIDs []uint     `json:"mention_ids,omitempty" gorm:"serializer:json"`
+	EditedAt   *time.Time `json:"edited_at"`
+
+	Replies []TaskComment `json:"replies,omitempty" gorm:"-"`
+}
+
+// TaskAttachment links an uploaded file to a task
+type TaskAttachment struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at"`
+
+	TaskID uint `json:"task_id" gorm:"not null;uniqueIndex:idx_task_attachment"`
+	FileID uint `json:"file_id" gorm:"not null;uniqueIndex:idx_task_attachment"`
+	File   File `json:"file,omitempty" gorm:"foreignKey:FileID"`
+	UserID uint `json:"user_id" gorm:"not null;index"`
+	User   User `json:"user,omitempty" gorm:"foreignKey:UserID"`
+}
+
+// TaskChange records one field of a task changing. Values are stored as
+// text; an empty value means the field was unset.
+type TaskChange struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+
+	TaskID uint `json:"task_id" gorm:"not null;index"`
+	// ActorID is who made the change; nil for changes made by the system,
+	// such as syncs
+	ActorID *uint `json:"actor_id,omitempty"`
+	Actor   *User `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+
+	Field    string `json:"field" gorm:"not null"` // status, priority, due_date, assignee_id, progress, estimated_minutes
+	OldValue string `json:"old_value"`
+	NewValue string `json:"new_value"`
+}
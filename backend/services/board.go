@@ -0,0 +1,792 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidBoard    = errors.New("invalid board")
+	ErrBoardForbidden  = errors.New("not allowed to change this board")
+	ErrTaskNotOnBoard  = errors.New("task is not on this board")
+	ErrWIPLimitReached = errors.New("column is at its WIP limit")
+)
+
+// Lane keys for tasks without an assignee
+const unassignedLane = "unassigned"
+
+// BoardService manages kanban boards and moves cards between columns
+type BoardService struct {
+	db *gorm.DB
+}
+
+// NewBoardService creates a new board service
+func NewBoardService(db *gorm.DB) *BoardService {
+	return &BoardService{db: db}
+}
+
+// BoardInput creates or updates a board. Columns are only used on create;
+// without them a board starts with To do, In progress and Done.
+type BoardInput struct {
+	Name        *string               `json:"name"`
+	Description *string               `json:"description"`
+	TeamID      *uint                 `json:"team_id"`
+	Tag         *string               `json:"tag"`
+	Swimlane    *models.BoardSwimlane `json:"swimlane"`
+	Columns     []BoardColumnInput    `json:"columns"`
+}
+
+// BoardColumnInput creates or updates a column
+type BoardColumnInput struct {
+	Name      *string            `json:"name"`
+	Status    *models.TaskStatus `json:"status"`
+	WIPLimit  *int               `json:"wip_limit"`
+	WIPPolicy *models.WIPPolicy  `json:"wip_policy"`
+}
+
+// MoveCardInput places a task in a column at a position (0 is the top).
+// Lane, on boards with swimlanes, moves the card to another row, which
+// changes the task's priority or assignee.
+type MoveCardInput struct {
+	ColumnID uint    `json:"column_id" binding:"required"`
+	Position int     `json:"position"`
+	Lane     *string `json:"lane"`
+}
+
+// BoardView is a board with its cards laid out
+type BoardView struct {
+	Board    *models.Board     `json:"board"`
+	Lanes    []BoardLane       `json:"lanes"`
+	Columns  []BoardColumnView `json:"columns"`
+	Unmapped int               `json:"unmapped"`
+}
+
+// BoardLane is a swimlane row
+type BoardLane struct {
+	Key   string `json:"key"`
+	Label string `json:"label"`
+}
+
+// BoardColumnView is a column and its cards in order
+type BoardColumnView struct {
+	models.BoardColumn
+	Count     int             `json:"count"`
+	OverLimit bool            `json:"over_limit"`
+	Cards     []BoardCardView `json:"cards"`
+}
+
+// BoardCardView is a task on a board
+type BoardCardView struct {
+	Task     models.Task `json:"task"`
+	Position int         `json:"position"`
+	Lane     string      `json:"lane,omitempty"`
+}
+
+// BoardMoveResult describes a move. It is also the payload of the
+// board.card_moved event.
+type BoardMoveResult struct {
+	BoardID      uint              `json:"board_id"`
+	TaskID       uint              `json:"task_id"`
+	FromColumnID uint              `json:"from_column_id"`
+	ColumnID     uint              `json:"column_id"`
+	Position     int               `json:"position"`
+	Status       models.TaskStatus `json:"status"`
+	Lane         string            `json:"lane,omitempty"`
+	MovedBy      uint              `json:"moved_by"`
+	Warning      string            `json:"warning,omitempty"`
+	Task         *models.Task      `json:"task"`
+}
+
+// boardAccess is what a user may do on a board
+type boardAccess struct {
+	board *models.Board
+	view  bool
+	move  bool
+	edit  bool
+}
+
+// List returns the user's personal boards and the boards of their teams
+func (s *BoardService) List(userID uint) ([]models.Board, error) {
+	var teamIDs []uint
+	if err := s.db.Model(&models.TeamMember{}).Where("user_id = ?", userID).Pluck("team_id", &teamIDs).Error; err != nil {
+		return nil, err
+	}
+
+	query := s.db.Where("team_id IS NULL AND user_id = ?", userID)
+	if len(teamIDs) > 0 {
+		query = s.db.Where("(team_id IS NULL AND user_id = ?) OR team_id IN ?", userID, teamIDs)
+	}
+	var boards []models.Board
+	err := query.Preload("Columns", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		Order("name").Find(&boards).Error
+	return boards, err
+}
+
+// CanView reports whether the user may see the board
+func (s *BoardService) CanView(userID, boardID uint) bool {
+	access, err := s.access(s.db, userID, boardID)
+	return err == nil && access.view
+}
+
+// Create adds a board for the user or, with TeamID, for one of their teams
+func (s *BoardService) Create(userID uint, input BoardInput) (*models.Board, error) {
+	board := models.Board{UserID: userID, Swimlane: models.BoardSwimlaneNone}
+	if input.Name == nil {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidBoard)
+	}
+	if err := applyBoardInput(s.db, userID, &board, input); err != nil {
+		return nil, err
+	}
+
+	if board.TeamID != nil {
+		var member models.TeamMember
+		if err := s.db.Where("team_id = ? AND user_id = ?", *board.TeamID, userID).First(&member).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fmt.Errorf("%w: not a member of this team", ErrInvalidBoard)
+			}
+			return nil, err
+		}
+		if member.Role == "viewer" {
+			return nil, ErrBoardForbidden
+		}
+	}
+
+	columns := input.Columns
+	if len(columns) == 0 {
+		columns = defaultBoardColumns()
+	}
+	for i, columnInput := range columns {
+		column := models.BoardColumn{Position: i, WIPPolicy: models.WIPPolicyWarn}
+		if err := applyBoardColumnInput(&column, columnInput); err != nil {
+			return nil, err
+		}
+		if column.Name == "" || column.Status == "" {
+			return nil, fmt.Errorf("%w: columns need a name and a status", ErrInvalidBoard)
+		}
+		board.Columns = append(board.Columns, column)
+	}
+
+	if err := s.db.Create(&board).Error; err != nil {
+		return nil, err
+	}
+	return s.Get(userID, board.ID)
+}
+
+// Get returns the board and its columns
+func (s *BoardService) Get(userID, boardID uint) (*models.Board, error) {
+	access, err := s.access(s.db, userID, boardID)
+	if err != nil {
+		return nil, err
+	}
+	return access.board, nil
+}
+
+// Update changes the board's name, description, tag filter or swimlanes
+func (s *BoardService) Update(userID, boardID uint, input BoardInput) (*models.Board, error) {
+	access, err := s.access(s.db, userID, boardID)
+	if err != nil {
+		return nil, err
+	}
+	if !access.edit {
+		return nil, ErrBoardForbidden
+	}
+	if input.TeamID != nil {
+		return nil, fmt.Errorf("%w: a board cannot move between teams", ErrInvalidBoard)
+	}
+
+	board := access.board
+	if err := applyBoardInput(s.db, userID, board, input); err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&models.Board{}).Where("id = ?", board.ID).Updates(map[string]interface{}{
+		"name": board.Name, "description": board.Description, "tag_id": board.TagID, "swimlane": board.Swimlane,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return s.Get(userID, boardID)
+}
+
+// Delete removes the board with its columns and card positions. The tasks
+// themselves are not touched.
+func (s *BoardService) Delete(userID, boardID uint) error {
+	access, err := s.access(s.db, userID, boardID)
+	if err != nil {
+		return err
+	}
+	if !access.edit {
+		return ErrBoardForbidden
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("board_id = ?", boardID).Delete(&models.BoardCard{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("board_id = ?", boardID).Delete(&models.BoardColumn{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Board{}, boardID).Error
+	})
+}
+
+// AddColumn appends a column to the board
+func (s *BoardService) AddColumn(userID, boardID uint, input BoardColumnInput) (*models.BoardColumn, error) {
+	access, err := s.access(s.db, userID, boardID)
+	if err != nil {
+		return nil, err
+	}
+	if !access.edit {
+		return nil, ErrBoardForbidden
+	}
+
+	column := models.BoardColumn{BoardID: boardID, Position: len(access.board.Columns), WIPPolicy: models.WIPPolicyWarn}
+	if err := applyBoardColumnInput(&column, input); err != nil {
+		return nil, err
+	}
+	if column.Name == "" || column.Status == "" {
+		return nil, fmt.Errorf("%w: columns need a name and a status", ErrInvalidBoard)
+	}
+	if err := s.db.Create(&column).Error; err != nil {
+		return nil, err
+	}
+	return &column, nil
+}
+
+// UpdateColumn changes a column's name, status or WIP limit
+func (s *BoardService) UpdateColumn(userID, boardID, columnID uint, input BoardColumnInput) (*models.BoardColumn, error) {
+	column, err := s.editableColumn(userID, boardID, columnID)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyBoardColumnInput(column, input); err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&models.BoardColumn{}).Where("id = ?", column.ID).Updates(map[string]interface{}{
+		"name": column.Name, "status": column.Status, "wip_limit": column.WIPLimit, "wip_policy": column.WIPPolicy,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return column, nil
+}
+
+// DeleteColumn removes a column. Its cards fall back to the first other
+// column with their status. The last column cannot be removed.
+func (s *BoardService) DeleteColumn(userID, boardID, columnID uint) error {
+	column, err := s.editableColumn(userID, boardID, columnID)
+	if err != nil {
+		return err
+	}
+	var remaining int64
+	if err := s.db.Model(&models.BoardColumn{}).Where("board_id = ? AND id <> ?", boardID, column.ID).Count(&remaining).Error; err != nil {
+		return err
+	}
+	if remaining == 0 {
+		return fmt.Errorf("%w: a board needs at least one column", ErrInvalidBoard)
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("column_id = ?", column.ID).Delete(&models.BoardCard{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(column).Error
+	})
+}
+
+// ReorderColumns sets the column order. All of the board's columns must be
+// listed.
+func (s *BoardService) ReorderColumns(userID, boardID uint, columnIDs []uint) (*models.Board, error) {
+	access, err := s.access(s.db, userID, boardID)
+	if err != nil {
+		return nil, err
+	}
+	if !access.edit {
+		return nil, ErrBoardForbidden
+	}
+
+	columnIDs = uniqueIDs(columnIDs)
+	existing := make(map[uint]bool, len(access.board.Columns))
+	for _, column := range access.board.Columns {
+		existing[column.ID] = true
+	}
+	if len(columnIDs) != len(existing) {
+		return nil, fmt.Errorf("%w: every column must be listed once", ErrInvalidBoard)
+	}
+	for _, id := range columnIDs {
+		if !existing[id] {
+			return nil, fmt.Errorf("%w: column %d is not on this board", ErrInvalidBoard, id)
+		}
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for i, id := range columnIDs {
+			if err := tx.Model(&models.BoardColumn{}).Where("id = ?", id).Update("position", i).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(userID, boardID)
+}
+
+// View lays out the board's tasks in columns
+func (s *BoardService) View(userID, boardID uint) (*BoardView, error) {
+	access, err := s.access(s.db, userID, boardID)
+	if err != nil {
+		return nil, err
+	}
+	board := access.board
+
+	tasks, err := boardTasks(s.db, board, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := NewTaskDependencyService(s.db).MarkBlocked(tasks); err != nil {
+		return nil, err
+	}
+	columns, unmapped, err := layoutBoard(s.db, board, tasks)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &BoardView{Board: board, Lanes: []BoardLane{}, Columns: columns, Unmapped: unmapped}
+	switch board.Swimlane {
+	case models.BoardSwimlanePriority:
+		for _, priority := range []models.TaskPriority{models.TaskPriorityUrgent, models.TaskPriorityHigh, models.TaskPriorityMedium, models.TaskPriorityLow} {
+			view.Lanes = append(view.Lanes, BoardLane{Key: string(priority), Label: strings.ToUpper(string(priority[:1])) + string(priority[1:])})
+		}
+	case models.BoardSwimlaneAssignee:
+		seen := make(map[uint]bool)
+		for _, task := range tasks {
+			if task.Assignee != nil && !seen[task.Assignee.ID] {
+				seen[task.Assignee.ID] = true
+				view.Lanes = append(view.Lanes, BoardLane{Key: strconv.FormatUint(uint64(task.Assignee.ID), 10), Label: task.Assignee.Username})
+			}
+		}
+		sort.Slice(view.Lanes, func(i, j int) bool { return view.Lanes[i].Label < view.Lanes[j].Label })
+		view.Lanes = append(view.Lanes, BoardLane{Key: unassignedLane, Label: "Unassigned"})
+	}
+	return view, nil
+}
+
+// Move places a task in a column and position in one transaction. Moving
+// into a column with another status changes the task's status; a column
+// at its WIP limit rejects the move or lets it through with a warning,
+// depending on its policy.
+func (s *BoardService) Move(userID, boardID, taskID uint, input MoveCardInput, now time.Time) (*BoardMoveResult, error) {
+	result := &BoardMoveResult{BoardID: boardID, TaskID: taskID, ColumnID: input.ColumnID, MovedBy: userID}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		access, err := s.access(tx, userID, boardID)
+		if err != nil {
+			return err
+		}
+		if !access.move {
+			return ErrBoardForbidden
+		}
+		board := access.board
+
+		var column *models.BoardColumn
+		for i := range board.Columns {
+			if board.Columns[i].ID == input.ColumnID {
+				column = &board.Columns[i]
+			}
+		}
+		if column == nil {
+			return fmt.Errorf("%w: column %d is not on this board", ErrInvalidBoard, input.ColumnID)
+		}
+
+		onBoard, err := boardTasks(tx, board, []uint{taskID})
+		if err != nil {
+			return err
+		}
+		if len(onBoard) == 0 {
+			return ErrTaskNotOnBoard
+		}
+		task := onBoard[0]
+
+		tasks, err := boardTasks(tx, board, nil)
+		if err != nil {
+			return err
+		}
+		columns, _, err := layoutBoard(tx, board, tasks)
+		if err != nil {
+			return err
+		}
+		var target []uint
+		for _, view := range columns {
+			for _, card := range view.Cards {
+				if card.Task.ID == taskID {
+					result.FromColumnID = view.ID
+				}
+			}
+			if view.ID != column.ID {
+				continue
+			}
+			for _, card := range view.Cards {
+				if card.Task.ID != taskID {
+					target = append(target, card.Task.ID)
+				}
+			}
+		}
+
+		if result.FromColumnID != column.ID && column.WIPLimit > 0 && len(target) >= column.WIPLimit {
+			message := fmt.Sprintf("%s allows %d cards", column.Name, column.WIPLimit)
+			if column.WIPPolicy == models.WIPPolicyReject {
+				return fmt.Errorf("%w: %s", ErrWIPLimitReached, message)
+			}
+			result.Warning = fmt.Sprintf("%s: %s", ErrWIPLimitReached, message)
+		}
+
+		updates := map[string]interface{}{}
+		if task.Status != column.Status {
+			updates["status"] = column.Status
+			updates["completed_at"] = nil
+			if column.Status == models.TaskStatusCompleted {
+				if err := NewTaskDependencyService(tx).CheckCompletion(task.UserID, task.ID); err != nil {
+					return err
+				}
+				updates["completed_at"] = now
+				updates["progress"] = 100
+			}
+		}
+		if input.Lane != nil {
+			if err := laneUpdates(tx, board, *input.Lane, updates); err != nil {
+				return err
+			}
+		}
+		if len(updates) > 0 {
+			if err := TrackTaskChanges(tx, userID, []uint{task.ID}, func() error {
+				return tx.Model(&models.Task{}).Where("id = ?", task.ID).Updates(updates).Error
+			}); err != nil {
+				return err
+			}
+			if updates["status"] == models.TaskStatusCompleted {
+				if err := generateNextOccurrences(tx, task.UserID, []uint{task.ID}, now); err != nil {
+					return err
+				}
+			}
+		}
+
+		// Renumber the destination column with the card at its new place
+		position := input.Position
+		if position < 0 {
+			position = 0
+		}
+		if position > len(target) {
+			position = len(target)
+		}
+		order := append(append(append([]uint{}, target[:position]...), taskID), target[position:]...)
+		for i, id := range order {
+			card := models.BoardCard{BoardID: board.ID, TaskID: id}
+			if err := tx.Where("board_id = ? AND task_id = ?", board.ID, id).
+				Assign(map[string]interface{}{"column_id": column.ID, "position": i}).
+				FirstOrCreate(&card).Error; err != nil {
+				return err
+			}
+		}
+		result.Position = position
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var task models.Task
+	if err := s.db.Preload("Tags").Preload("Assignee").First(&task, taskID).Error; err != nil {
+		return nil, err
+	}
+	tasks := []models.Task{task}
+	if err := NewTaskDependencyService(s.db).MarkBlocked(tasks); err != nil {
+		return nil, err
+	}
+	board, err := s.Get(userID, boardID)
+	if err != nil {
+		return nil, err
+	}
+	result.Task = &tasks[0]
+	result.Status = task.Status
+	result.Lane = taskLane(board, &tasks[0])
+	return result, nil
+}
+
+// access loads the board with its columns and works out what the user may
+// do. Boards the user cannot see are reported as not found.
+func (s *BoardService) access(tx *gorm.DB, userID, boardID uint) (*boardAccess, error) {
+	var board models.Board
+	if err := tx.Preload("Columns", func(db *gorm.DB) *gorm.DB { return db.Order("position, id") }).
+		First(&board, boardID).Error; err != nil {
+		return nil, err
+	}
+
+	if board.TeamID == nil {
+		if board.UserID != userID {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return &boardAccess{board: &board, view: true, move: true, edit: true}, nil
+	}
+
+	var member models.TeamMember
+	if err := tx.Where("team_id = ? AND user_id = ?", *board.TeamID, userID).First(&member).Error; err != nil {
+		return nil, err
+	}
+	return &boardAccess{
+		board: &board,
+		view:  true,
+		move:  member.Role != "viewer",
+		edit:  board.UserID == userID || member.Role == "owner" || member.Role == "admin",
+	}, nil
+}
+
+func (s *BoardService) editableColumn(userID, boardID, columnID uint) (*models.BoardColumn, error) {
+	access, err := s.access(s.db, userID, boardID)
+	if err != nil {
+		return nil, err
+	}
+	if !access.edit {
+		return nil, ErrBoardForbidden
+	}
+	for i := range access.board.Columns {
+		if access.board.Columns[i].ID == columnID {
+			return &access.board.Columns[i], nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func defaultBoardColumns() []BoardColumnInput {
+	column := func(name string, status models.TaskStatus) BoardColumnInput {
+		return BoardColumnInput{Name: &name, Status: &status}
+	}
+	return []BoardColumnInput{
+		column("To do", models.TaskStatusPending),
+		column("In progress", models.TaskStatusInProgress),
+		column("Done", models.TaskStatusCompleted),
+	}
+}
+
+func applyBoardInput(tx *gorm.DB, userID uint, board *models.Board, input BoardInput) error {
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" {
+			return fmt.Errorf("%w: name is required", ErrInvalidBoard)
+		}
+		board.Name = name
+	}
+	if input.Description != nil {
+		board.Description = *input.Description
+	}
+	if input.TeamID != nil {
+		board.TeamID = input.TeamID
+	}
+	if input.Swimlane != nil {
+		switch *input.Swimlane {
+		case models.BoardSwimlaneNone, models.BoardSwimlanePriority, models.BoardSwimlaneAssignee:
+			board.Swimlane = *input.Swimlane
+		default:
+			return fmt.Errorf("%w: unknown swimlane %q", ErrInvalidBoard, *input.Swimlane)
+		}
+	}
+	if input.Tag != nil {
+		board.TagID = nil
+		if strings.TrimSpace(*input.Tag) != "" {
+			if board.TeamID != nil {
+				return fmt.Errorf("%w: team boards show the tasks shared with the team", ErrInvalidBoard)
+			}
+			tag, err := ResolveTag(tx, userID, *input.Tag)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, ErrInvalidTagName) {
+					return fmt.Errorf("%w: unknown tag %q", ErrInvalidBoard, *input.Tag)
+				}
+				return err
+			}
+			board.TagID = &tag.ID
+		}
+	}
+	return nil
+}
+
+func applyBoardColumnInput(column *models.BoardColumn, input BoardColumnInput) error {
+	if input.Name != nil {
+		column.Name = strings.TrimSpace(*input.Name)
+		if column.Name == "" {
+			return fmt.Errorf("%w: column name is required", ErrInvalidBoard)
+		}
+	}
+	if input.Status != nil {
+		switch *input.Status {
+		case models.TaskStatusPending, models.TaskStatusInProgress, models.TaskStatusCompleted, models.TaskStatusCancelled:
+			column.Status = *input.Status
+		default:
+			return fmt.Errorf("%w: unknown status %q", ErrInvalidBoard, *input.Status)
+		}
+	}
+	if input.WIPLimit != nil {
+		if *input.WIPLimit < 0 {
+			return fmt.Errorf("%w: wip_limit cannot be negative", ErrInvalidBoard)
+		}
+		column.WIPLimit = *input.WIPLimit
+	}
+	if input.WIPPolicy != nil {
+		switch *input.WIPPolicy {
+		case models.WIPPolicyWarn, models.WIPPolicyReject:
+			column.WIPPolicy = *input.WIPPolicy
+		default:
+			return fmt.Errorf("%w: unknown wip_policy %q", ErrInvalidBoard, *input.WIPPolicy)
+		}
+	}
+	return nil
+}
+
+// boardTasks loads the tasks shown on a board, optionally only some of them
+func boardTasks(tx *gorm.DB, board *models.Board, ids []uint) ([]models.Task, error) {
+	query := tx.Model(&models.Task{})
+	if board.TeamID != nil {
+		query = query.Where("id IN (SELECT task_id FROM team_tasks WHERE team_id = ? AND deleted_at IS NULL)", *board.TeamID)
+	} else {
+		query = query.Where("user_id = ?", board.UserID)
+		if board.TagID != nil {
+			var tag models.Tag
+			if err := tx.First(&tag, *board.TagID).Error; err != nil {
+				return nil, err
+			}
+			tagIDs, err := tagSubtreeIDs(tx, &tag)
+			if err != nil {
+				return nil, err
+			}
+			query = query.Where("id IN (SELECT task_id FROM task_tags WHERE tag_id IN ?)", tagIDs)
+		}
+	}
+	if ids != nil {
+		query = query.Where("id IN ?", ids)
+	}
+
+	var tasks []models.Task
+	err := query.Preload("Tags").Preload("Assignee").Order("id").Find(&tasks).Error
+	return tasks, err
+}
+
+// layoutBoard puts each task in its column: the column of its card if that
+// still matches the task's status, otherwise the first column with the
+// status. Placed cards come first in their saved order. It also returns how
+// many tasks have a status no column shows.
+func layoutBoard(tx *gorm.DB, board *models.Board, tasks []models.Task) ([]BoardColumnView, int, error) {
+	var cards []models.BoardCard
+	if err := tx.Where("board_id = ?", board.ID).Find(&cards).Error; err != nil {
+		return nil, 0, err
+	}
+	cardByTask := make(map[uint]models.BoardCard, len(cards))
+	for _, card := range cards {
+		cardByTask[card.TaskID] = card
+	}
+
+	columns := make([]BoardColumnView, len(board.Columns))
+	columnIndex := make(map[uint]int, len(board.Columns))
+	firstForStatus := make(map[models.TaskStatus]int)
+	for i, column := range board.Columns {
+		columns[i] = BoardColumnView{BoardColumn: column, Cards: []BoardCardView{}}
+		columnIndex[column.ID] = i
+		if _, ok := firstForStatus[column.Status]; !ok {
+			firstForStatus[column.Status] = i
+		}
+	}
+
+	type placed struct {
+		card   BoardCardView
+		saved  bool
+		column int
+	}
+	unmapped := 0
+	var placements []placed
+	for _, task := range tasks {
+		p := placed{card: BoardCardView{Task: task, Lane: taskLane(board, &task)}}
+		card, hasCard := cardByTask[task.ID]
+		if i, ok := columnIndex[card.ColumnID]; hasCard && ok && board.Columns[i].Status == task.Status {
+			p.column, p.saved, p.card.Position = i, true, card.Position
+		} else if i, ok := firstForStatus[task.Status]; ok {
+			p.column = i
+		} else {
+			unmapped++
+			continue
+		}
+		placements = append(placements, p)
+	}
+
+	sort.SliceStable(placements, func(i, j int) bool {
+		a, b := placements[i], placements[j]
+		if a.saved != b.saved {
+			return a.saved
+		}
+		if a.saved && a.card.Position != b.card.Position {
+			return a.card.Position < b.card.Position
+		}
+		return a.card.Task.ID < b.card.Task.ID
+	})
+	for _, p := range placements {
+		column := &columns[p.column]
+		p.card.Position = len(column.Cards)
+		column.Cards = append(column.Cards, p.card)
+	}
+	for i := range columns {
+		columns[i].Count = len(columns[i].Cards)
+		columns[i].OverLimit = columns[i].WIPLimit > 0 && columns[i].Count > columns[i].WIPLimit
+	}
+	return columns, unmapped, nil
+}
+
+func taskLane(board *models.Board, task *models.Task) string {
+	switch board.Swimlane {
+	case models.BoardSwimlanePriority:
+		if task.Priority == "" {
+			return string(models.TaskPriorityMedium)
+		}
+		return string(task.Priority)
+	case models.BoardSwimlaneAssignee:
+		if task.AssigneeID == nil {
+			return unassignedLane
+		}
+		return strconv.FormatUint(uint64(*task.AssigneeID), 10)
+	}
+	return ""
+}
+
+// laneUpdates turns a move to another swimlane into task field changes
+func laneUpdates(tx *gorm.DB, board *models.Board, lane string, updates map[string]interface{}) error {
+	switch board.Swimlane {
+	case models.BoardSwimlanePriority:
+		switch priority := models.TaskPriority(lane); priority {
+		case models.TaskPriorityLow, models.TaskPriorityMedium, models.TaskPriorityHigh, models.TaskPriorityUrgent:
+			updates["priority"] = priority
+		default:
+			return fmt.Errorf("%w: unknown lane %q", ErrInvalidBoard, lane)
+		}
+	case models.BoardSwimlaneAssignee:
+		if lane == unassignedLane || lane == "" {
+			updates["assignee_id"] = nil
+			return nil
+		}
+		assigneeID, err := strconv.ParseUint(lane, 10, 32)
+		if err != nil {
+			return fmt.Errorf("%w: unknown lane %q", ErrInvalidBoard, lane)
+		}
+		// Team tasks go to team members; personal tasks to the owner
+		allowed := uint(assigneeID) == board.UserID
+		if board.TeamID != nil {
+			var count int64
+			if err := tx.Model(&models.TeamMember{}).Where("team_id = ? AND user_id = ?", *board.TeamID, assigneeID).Count(&count).Error; err != nil {
+				return err
+			}
+			allowed = count > 0
+		}
+		if !allowed {
+			return fmt.Errorf("%w: user %d cannot be assigned here", ErrInvalidBoard, assigneeID)
+		}
+		updates["assignee_id"] = uint(assigneeID)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+func TestBoardService(t *testing.T) {
+	db := newTestDB(t, &models.TaskPreference{}, &models.Team{}, &models.TeamMember{}, &models.TeamTask{}, &models.Board{},
+		&models.BoardColumn{}, &models.BoardCard{})
+
+	owner := models.User{Email: "lead@example.com", Username: "lead", Password: "x", GitHubID: 1}
+	dev := models.User{Email: "dev@example.com", Username: "dev", Password: "x", GitHubID: 2}
+	guest := models.User{Email: "guest@example.com", Username: "guest", Password: "x", GitHubID: 3}
+	db.Create(&owner)
+	db.Create(&dev)
+	db.Create(&guest)
+	team := models.Team{Name: "Platform", OwnerID: owner.ID}
+	db.Create(&team)
+	db.Create(&[]models.TeamMember{
+		{TeamID: team.ID, UserID: owner.ID, Role: "owner"},
+		{TeamID: team.ID, UserID: dev.ID, Role: "member"},
+		{TeamID: team.ID, UserID: guest.ID, Role: "viewer"},
+	})
+
+	tasks := []models.Task{
+		{UserID: owner.ID, Title: "Upgrade database"},
+		{UserID: owner.ID, Title: "Rotate keys"},
+		{UserID: dev.ID, Title: "Fix login", Priority: models.TaskPriorityHigh},
+		{UserID: owner.ID, Title: "Not shared"},
+	}
+	db.Create(&tasks)
+	for _, task := range tasks[:3] {
+		db.Create(&models.TeamTask{TeamID: team.ID, UserID: task.UserID, TaskID: task.ID})
+	}
+
+	service := NewBoardService(db)
+	name, swimlane := "Sprint", models.BoardSwimlaneAssignee
+	board, err := service.Create(owner.ID, BoardInput{Name: &name, TeamID: &team.ID, Swimlane: &swimlane})
+	if err != nil {
+		t.Fatalf("failed to create board: %v", err)
+	}
+	if len(board.Columns) != 3 {
+		t.Fatalf("expected the default columns, got %+v", board.Columns)
+	}
+	todo, doing, done := board.Columns[0], board.Columns[1], board.Columns[2]
+
+	// A review column that shares the in-progress status, limited to one card
+	reviewName, inProgress, limit, reject := "Review", models.TaskStatusInProgress, 1, models.WIPPolicyReject
+	review, err := service.AddColumn(owner.ID, board.ID, BoardColumnInput{Name: &reviewName, Status: &inProgress, WIPLimit: &limit, WIPPolicy: &reject})
+	if err != nil {
+		t.Fatalf("failed to add column: %v", err)
+	}
+	if _, err := service.AddColumn(dev.ID, board.ID, BoardColumnInput{Name: &reviewName, Status: &inProgress}); !errors.Is(err, ErrBoardForbidden) {
+		t.Fatalf("expected members to be unable to edit columns, got %v", err)
+	}
+
+	view, err := service.View(dev.ID, board.ID)
+	if err != nil {
+		t.Fatalf("failed to view board: %v", err)
+	}
+	if view.Columns[0].Count != 3 || len(view.Lanes) != 1 || view.Lanes[0].Key != unassignedLane {
+		t.Fatalf("unexpected board view: %+v", view)
+	}
+
+	now := time.Date(2026, 5, 4, 10, 0, 0, 0, time.UTC)
+	// Moving within a column reorders it
+	if _, err := service.Move(dev.ID, board.ID, tasks[2].ID, MoveCardInput{ColumnID: todo.ID, Position: 0}, now); err != nil {
+		t.Fatalf("failed to reorder: %v", err)
+	}
+	view, _ = service.View(dev.ID, board.ID)
+	if view.Columns[0].Cards[0].Task.ID != tasks[2].ID || view.Columns[0].Cards[2].Task.ID != tasks[1].ID {
+		t.Fatalf("unexpected order: %+v", view.Columns[0].Cards)
+	}
+
+	// Moving to review changes the status and assigns through the lane
+	lane := strconv.FormatUint(uint64(dev.ID), 10)
+	moved, err := service.Move(dev.ID, board.ID, tasks[0].ID, MoveCardInput{ColumnID: review.ID, Lane: &lane}, now)
+	if err != nil {
+		t.Fatalf("failed to move card: %v", err)
+	}
+	if moved.FromColumnID != todo.ID || moved.Status != models.TaskStatusInProgress || moved.Task.AssigneeID == nil ||
+		*moved.Task.AssigneeID != dev.ID || moved.Lane != lane {
+		t.Fatalf("unexpected move result: %+v", moved)
+	}
+
+	// The review column is full and rejects; the doing column only warns
+	if _, err := service.Move(dev.ID, board.ID, tasks[1].ID, MoveCardInput{ColumnID: review.ID}, now); !errors.Is(err, ErrWIPLimitReached) {
+		t.Fatalf("expected the WIP limit to reject the move, got %v", err)
+	}
+	var unchanged models.Task
+	db.First(&unchanged, tasks[1].ID)
+	if unchanged.Status != models.TaskStatusPending {
+		t.Fatalf("a rejected move changed the task: %+v", unchanged)
+	}
+	one, warn := 1, models.WIPPolicyWarn
+	if _, err := service.UpdateColumn(owner.ID, board.ID, doing.ID, BoardColumnInput{WIPLimit: &one, WIPPolicy: &warn}); err != nil {
+		t.Fatalf("failed to update column: %v", err)
+	}
+	if _, err := service.Move(owner.ID, board.ID, tasks[1].ID, MoveCardInput{ColumnID: doing.ID}, now); err != nil {
+		t.Fatalf("failed to move card: %v", err)
+	}
+	moved, err = service.Move(owner.ID, board.ID, tasks[2].ID, MoveCardInput{ColumnID: doing.ID, Position: 0}, now)
+	if err != nil || moved.Warning == "" {
+		t.Fatalf("expected a WIP warning, got %+v (err %v)", moved, err)
+	}
+	view, _ = service.View(owner.ID, board.ID)
+	if !view.Columns[1].OverLimit || view.Columns[1].Cards[0].Task.ID != tasks[2].ID || view.Columns[3].Count != 1 {
+		t.Fatalf("unexpected columns after moves: %+v", view.Columns)
+	}
+
+	// Done completes the task; viewers and tasks outside the team are refused
+	moved, err = service.Move(dev.ID, board.ID, tasks[2].ID, MoveCardInput{ColumnID: done.ID}, now)
+	if err != nil || moved.Task.Status != models.TaskStatusCompleted || moved.Task.CompletedAt == nil {
+		t.Fatalf("expected the task to be completed: %+v (err %v)", moved, err)
+	}
+	if _, err := service.Move(guest.ID, board.ID, tasks[0].ID, MoveCardInput{ColumnID: todo.ID}, now); !errors.Is(err, ErrBoardForbidden) {
+		t.Fatalf("expected viewers to be unable to move cards, got %v", err)
+	}
+	if _, err := service.Move(owner.ID, board.ID, tasks[3].ID, MoveCardInput{ColumnID: todo.ID}, now); !errors.Is(err, ErrTaskNotOnBoard) {
+		t.Fatalf("expected unshared tasks to be refused, got %v", err)
+	}
+	outsider := models.User{Email: "out@example.com", Username: "out", Password: "x", GitHubID: 4}
+	db.Create(&outsider)
+	if service.CanView(outsider.ID, board.ID) {
+		t.Fatalf("expected outsiders not to see the team board")
+	}
+
+	// Personal boards show the owner's tasks under the board's tag
+	home, _ := FindOrCreateTag(db, owner.ID, "home")
+	garden := models.Task{UserID: owner.ID, Title: "Plant tomatoes", Priority: models.TaskPriorityLow, Tags: []models.Tag{*home}}
+	db.Create(&garden)
+	personal, tag, priority := "Home", "home", models.BoardSwimlanePriority
+	homeBoard, err := service.Create(owner.ID, BoardInput{Name: &personal, Tag: &tag, Swimlane: &priority})
+	if err != nil {
+		t.Fatalf("failed to create personal board: %v", err)
+	}
+	view, _ = service.View(owner.ID, homeBoard.ID)
+	if view.Columns[0].Count != 1 || view.Columns[0].Cards[0].Lane != "low" || len(view.Lanes) != 4 {
+		t.Fatalf("unexpected personal board: %+v", view)
+	}
+	if _, err := service.View(dev.ID, homeBoard.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected personal boards to be private, got %v", err)
+	}
+}
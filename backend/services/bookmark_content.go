@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+// contentExtractionTimeout bounds fetching a page for reader-mode extraction
+const contentExtractionTimeout = 30 * time.Second
+
+// BookmarkContentService extracts the readable article of bookmarked pages
+type BookmarkContentService struct {
+	db *gorm.DB
+}
+
+// NewBookmarkContentService creates a new bookmark content service
+func NewBookmarkContentService(db *gorm.DB) *BookmarkContentService {
+	return &BookmarkContentService{db: db}
+}
+
+// FetchArticle downloads a page and extracts its main content
+func FetchArticle(ctx context.Context, pageURL string) (*Article, error) {
+	page, err := FetchPage(ctx, pageURL, true)
+	if err != nil {
+		return nil, err
+	}
+	if page.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP error: %d %s", page.StatusCode, page.Status)
+	}
+	if page.ContentType != "" && !strings.Contains(strings.ToLower(page.ContentType), "html") {
+		return nil, fmt.Errorf("unsupported content type: %s", page.ContentType)
+	}
+
+	return ExtractArticle(page.Body, page.ContentType, page.FinalURL)
+}
+
+// ExtractBookmarkContent fetches the bookmark's page and stores the article
+// text, sanitized HTML, word count and reading time. Author and publish date
+// are only filled in when the bookmark does not have them yet.
+func (s *BookmarkContentService) ExtractBookmarkContent(ctx context.Context, bookmark *models.Bookmark) (*Article, error) {
+	ctx, cancel := context.WithTimeout(ctx, contentExtractionTimeout)
+	defer cancel()
+
+	article, err := FetchArticle(ctx, bookmark.URL)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ApplyArticle(bookmark, article); err != nil {
+		return nil, err
+	}
+	return article, nil
+}
+
+// ApplyArticle stores an extracted article on the bookmark
+func (s *BookmarkContentService) ApplyArticle(bookmark *models.Bookmark, article *Article) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"content":              article.TextContent,
+		"content_html":         article.Content,
+		"word_count":           article.WordCount,
+		"reading_time":         article.ReadingTime,
+		"content_extracted_at": now,
+	}
+	if bookmark.Author == "" && article.Byline != "" {
+		updates["author"] = article.Byline
+	}
+	if bookmark.PublishedAt == nil && article.PublishedAt != nil {
+		updates["published_at"] = article.PublishedAt
+	}
+	if bookmark.Description == "" && article.Excerpt != "" {
+		updates["description"] = article.Excerpt
+	}
+
+	if err := s.db.Model(&models.Bookmark{}).Where("id = ?", bookmark.ID).UpdateColumns(updates).Error; err != nil {
+		return err
+	}
+	if _, err := NewBookmarkHighlightService(s.db).Reanchor(bookmark.ID, article.TextContent); err != nil {
+		return err
+	}
+
+	bookmark.Content = article.TextContent
+	bookmark.ContentHTML = article.Content
+	bookmark.WordCount = article.WordCount
+	bookmark.ReadingTime = article.ReadingTime
+	bookmark.ContentExtractedAt = &now
+	if author, ok := updates["author"].(string); ok {
+		bookmark.Author = author
+	}
+	if _, ok := updates["published_at"]; ok {
+		bookmark.PublishedAt = article.PublishedAt
+	}
+	if description, ok := updates["description"].(string); ok {
+		bookmark.Description = description
+	}
+	return nil
+}
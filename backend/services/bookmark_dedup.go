@@ -0,0 +1,355 @@
+package services
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+// DuplicateBookmarkGroup is a set of bookmarks sharing a canonical key
+type DuplicateBookmarkGroup struct {
+	CanonicalKey string            `json:"canonical_key"`
+	Bookmarks    []models.Bookmark `json:"bookmarks"`
+}
+
+// BookmarkDedupService computes canonical bookmark keys and merges duplicates
+type BookmarkDedupService struct {
+	db *gorm.DB
+}
+
+// NewBookmarkDedupService creates a new bookmark dedup service
+func NewBookmarkDedupService(db *gorm.DB) *BookmarkDedupService {
+	return &BookmarkDedupService{db: db}
+}
+
+// Rules returns the built-in canonicalization rules overlaid with the user's own
+func (s *BookmarkDedupService) Rules(userID uint) (map[string]CanonicalRule, error) {
+	rules := make(map[string]CanonicalRule, len(DefaultCanonicalRules))
+	for domain, rule := range DefaultCanonicalRules {
+		rules[domain] = rule
+	}
+
+	var userRules []models.CanonicalURLRule
+	if err := s.db.Where("user_id = ?", userID).Find(&userRules).Error; err != nil {
+		return nil, err
+	}
+	for _, rule := range userRules {
+		rules[rule.Domain] = CanonicalRule{
+			KeepFragment: rule.KeepFragment,
+			DropQuery:    rule.DropQuery,
+			KeepParams:   rule.KeepParams,
+			StripParams:  rule.StripParams,
+		}
+	}
+	return rules, nil
+}
+
+// CanonicalKey returns the canonical key for a URL using the user's rules.
+// URLs that cannot be parsed fall back to their trimmed form.
+func (s *BookmarkDedupService) CanonicalKey(userID uint, rawURL string) string {
+	rules, err := s.Rules(userID)
+	if err != nil {
+		rules = DefaultCanonicalRules
+	}
+	return canonicalKeyWithRules(rawURL, rules)
+}
+
+func canonicalKeyWithRules(rawURL string, rules map[string]CanonicalRule) string {
+	key, err := CanonicalizeURL(rawURL, rules)
+	if err != nil {
+		return strings.TrimSpace(rawURL)
+	}
+	return key
+}
+
+// FindDuplicates returns the user's bookmarks with the given canonical key,
+// excluding excludeID
+func (s *BookmarkDedupService) FindDuplicates(userID uint, canonicalKey string, excludeID uint) ([]models.Bookmark, error) {
+	var bookmarks []models.Bookmark
+	if canonicalKey == "" {
+		return bookmarks, nil
+	}
+	err := s.db.Where("user_id = ? AND canonical_key = ? AND id <> ?", userID, canonicalKey, excludeID).
+		Preload("Tags").Order("created_at ASC").Find(&bookmarks).Error
+	return bookmarks, err
+}
+
+// DuplicateGroups lists every canonical key the user has saved more than once
+func (s *BookmarkDedupService) DuplicateGroups(userID uint) ([]DuplicateBookmarkGroup, error) {
+	var keys []string
+	if err := s.db.Model(&models.Bookmark{}).
+		Where("user_id = ? AND canonical_key <> ''", userID).
+		Group("canonical_key").Having("COUNT(*) > 1").
+		Pluck("canonical_key", &keys).Error; err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return []DuplicateBookmarkGroup{}, nil
+	}
+
+	var bookmarks []models.Bookmark
+	if err := s.db.Where("user_id = ? AND canonical_key IN ?", userID, keys).
+		Preload("Tags").Order("created_at ASC").Find(&bookmarks).Error; err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string]*DuplicateBookmarkGroup, len(keys))
+	for _, bookmark := range bookmarks {
+		group, ok := groups[bookmark.CanonicalKey]
+		if !ok {
+			group = &DuplicateBookmarkGroup{CanonicalKey: bookmark.CanonicalKey}
+			groups[bookmark.CanonicalKey] = group
+		}
+		group.Bookmarks = append(group.Bookmarks, bookmark)
+	}
+
+	sort.Strings(keys)
+	result := make([]DuplicateBookmarkGroup, 0, len(keys))
+	for _, key := range keys {
+		if group, ok := groups[key]; ok {
+			result = append(result, *group)
+		}
+	}
+	return result, nil
+}
+
+// RecomputeKeys refreshes the canonical keys of the user's bookmarks, for
+// example after their rules changed. With onlyMissing set, bookmarks that
+// already have a key are left alone. It returns the number of updated rows.
+func (s *BookmarkDedupService) RecomputeKeys(userID uint, onlyMissing bool) (int, error) {
+	rules, err := s.Rules(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	query := s.db.Model(&models.Bookmark{}).Select("id", "url", "canonical_key").Where("user_id = ?", userID)
+	if onlyMissing {
+		query = query.Where("canonical_key = '' OR canonical_key IS NULL")
+	}
+
+	updated := 0
+	var batch []models.Bookmark
+	err = query.FindInBatches(&batch, 200, func(tx *gorm.DB, _ int) error {
+		for _, bookmark := range batch {
+			key := canonicalKeyWithRules(bookmark.URL, rules)
+			if key == bookmark.CanonicalKey {
+				continue
+			}
+			if err := s.db.Model(&models.Bookmark{}).Where("id = ?", bookmark.ID).
+				UpdateColumn("canonical_key", key).Error; err != nil {
+				return err
+			}
+			updated++
+		}
+		return nil
+	}).Error
+
+	return updated, err
+}
+
+// BackfillCanonicalKeys computes keys for bookmarks saved before canonical
+// keys existed
+func (s *BookmarkDedupService) BackfillCanonicalKeys() error {
+	var userIDs []uint
+	if err := s.db.Model(&models.Bookmark{}).
+		Where("canonical_key = '' OR canonical_key IS NULL").
+		Distinct().Pluck("user_id", &userIDs).Error; err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		if _, err := s.RecomputeKeys(userID, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Merge folds the duplicate bookmarks into the primary one: tags are
+// unioned, the earliest CreatedAt and ReadAt are kept, favorite and read
+// flags are kept if set on any copy, and empty details are filled in from the
+// duplicates. Records pointing at a duplicate are moved to the primary and
+// the duplicates are deleted.
+func (s *BookmarkDedupService) Merge(userID, primaryID uint, duplicateIDs []uint) (*models.Bookmark, error) {
+	ids := make([]uint, 0, len(duplicateIDs))
+	seen := map[uint]bool{primaryID: true}
+	for _, id := range duplicateIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	var primary models.Bookmark
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ?", primaryID, userID).Preload("Tags").First(&primary).Error; err != nil {
+			return err
+		}
+
+		var duplicates []models.Bookmark
+		if err := tx.Where("id IN ? AND user_id = ?", ids, userID).Preload("Tags").Order("created_at ASC").Find(&duplicates).Error; err != nil {
+			return err
+		}
+		if len(duplicates) != len(ids) {
+			return gorm.ErrRecordNotFound
+		}
+
+		tagIDs := make(map[uint]bool, len(primary.Tags))
+		for _, tag := range primary.Tags {
+			tagIDs[tag.ID] = true
+		}
+		var newTags []models.Tag
+
+		for _, duplicate := range duplicates {
+			for _, tag := range duplicate.Tags {
+				if !tagIDs[tag.ID] {
+					tagIDs[tag.ID] = true
+					newTags = append(newTags, tag)
+				}
+			}
+
+			if duplicate.CreatedAt.Before(primary.CreatedAt) {
+				primary.CreatedAt = duplicate.CreatedAt
+			}
+			primary.IsFavorite = primary.IsFavorite || duplicate.IsFavorite
+			primary.IsRead = primary.IsRead || duplicate.IsRead
+			if duplicate.ReadAt != nil && (primary.ReadAt == nil || duplicate.ReadAt.Before(*primary.ReadAt)) {
+				primary.ReadAt = duplicate.ReadAt
+			}
+
+			if primary.Description == "" {
+				primary.Description = duplicate.Description
+			}
+			if primary.Content == "" {
+				primary.Content = duplicate.Content
+			}
+			if primary.Author == "" {
+				primary.Author = duplicate.Author
+			}
+			if primary.Favicon == "" {
+				primary.Favicon = duplicate.Favicon
+			}
+			if primary.Screenshot == "" {
+				primary.Screenshot = duplicate.Screenshot
+			}
+			if primary.PublishedAt == nil {
+				primary.PublishedAt = duplicate.PublishedAt
+			}
+		}
+
+		if err := tx.Omit("Tags").Save(&primary).Error; err != nil {
+			return err
+		}
+		if len(newTags) > 0 {
+			if err := tx.Model(&primary).Association("Tags").Append(newTags); err != nil {
+				return err
+			}
+		}
+
+		if err := s.reassignBookmarkReferences(tx, primary.ID, ids); err != nil {
+			return err
+		}
+
+		return tx.Delete(&duplicates).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Preload("Tags").First(&primary, primary.ID).Error; err != nil {
+		return nil, err
+	}
+	return &primary, nil
+}
+
+// reassignBookmarkReferences points records linked to the duplicates at the primary bookmark
+func (s *BookmarkDedupService) reassignBookmarkReferences(tx *gorm.DB, primaryID uint, duplicateIDs []uint) error {
+	for _, model := range []interface{}{
+		&models.TeamBookmark{},
+		&models.BookmarkHighlight{},
+		&models.CalendarEvent{},
+		&models.TimeEntry{},
+		&models.ScrapedContent{},
+		&models.FeedItem{},
+		&models.BookmarkLinkCheck{},
+	} {
+		if err := tx.Model(model).Where("bookmark_id IN ?", duplicateIDs).Update("bookmark_id", primaryID).Error; err != nil {
+			return err
+		}
+	}
+
+	// Collection entries are keyed by bookmark, so drop the ones the primary already has
+	var primaryCollections []uint
+	if err := tx.Model(&models.CollectionBookmark{}).Where("bookmark_id = ?", primaryID).
+		Pluck("collection_id", &primaryCollections).Error; err != nil {
+		return err
+	}
+	var entries []models.CollectionBookmark
+	if err := tx.Where("bookmark_id IN ?", duplicateIDs).Order("position ASC").Find(&entries).Error; err != nil {
+		return err
+	}
+	inCollection := make(map[uint]bool, len(primaryCollections))
+	for _, collectionID := range primaryCollections {
+		inCollection[collectionID] = true
+	}
+	for _, entry := range entries {
+		query := tx.Model(&models.CollectionBookmark{}).Where("collection_id = ? AND bookmark_id = ?", entry.CollectionID, entry.BookmarkID)
+		if inCollection[entry.CollectionID] {
+			if err := query.Delete(&models.CollectionBookmark{}).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		inCollection[entry.CollectionID] = true
+		if err := query.UpdateColumn("bookmark_id", primaryID).Error; err != nil {
+			return err
+		}
+	}
+
+	// A bookmark is queued at most once, so keep the primary's queue entry
+	// if it has one and otherwise the first of the duplicates'
+	var queued int64
+	if err := tx.Model(&models.ReadingQueueItem{}).Where("bookmark_id = ?", primaryID).Count(&queued).Error; err != nil {
+		return err
+	}
+	var queueItems []models.ReadingQueueItem
+	if err := tx.Where("bookmark_id IN ?", duplicateIDs).Order("id ASC").Find(&queueItems).Error; err != nil {
+		return err
+	}
+	for _, item := range queueItems {
+		if queued > 0 {
+			if err := tx.Delete(&item).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		queued++
+		if err := tx.Model(&item).UpdateColumn("bookmark_id", primaryID).Error; err != nil {
+			return err
+		}
+	}
+
+	// Snapshots are versioned per bookmark, so append them after the primary's own
+	var latest int
+	if err := tx.Unscoped().Model(&models.BookmarkSnapshot{}).Where("bookmark_id = ?", primaryID).
+		Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+		return err
+	}
+
+	var snapshots []models.BookmarkSnapshot
+	if err := tx.Where("bookmark_id IN ?", duplicateIDs).Order("created_at ASC").Find(&snapshots).Error; err != nil {
+		return err
+	}
+	for _, snapshot := range snapshots {
+		latest++
+		if err := tx.Model(&models.BookmarkSnapshot{}).Where("id = ?", snapshot.ID).Updates(map[string]interface{}{
+			"bookmark_id": primaryID,
+			"version":     latest,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
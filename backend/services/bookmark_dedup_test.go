@@ -0,0 +1,140 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+func TestCanonicalizeURL(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"https://example.com/article", "https://example.com/article"},
+		{"http://Example.COM/article/", "https://example.com/article"},
+		{"https://www.example.com:443/article?utm_source=x&utm_medium=y", "https://example.com/article"},
+		{"http://example.com:80", "https://example.com/"},
+		{"https://example.com:8443/a", "https://example.com:8443/a"},
+		{"https://example.com/a?b=2&a=1&fbclid=abc", "https://example.com/a?a=1&b=2"},
+		{"https://example.com/a#section-2", "https://example.com/a"},
+		{"https://app.example.com/#/inbox/42", "https://app.example.com/#/inbox/42"},
+		{"example.com/a", "https://example.com/a"},
+		{"https://m.youtube.com/watch?v=abc&feature=share&t=42", "https://m.youtube.com/watch?v=abc"},
+		{"https://www.amazon.com/dp/B000123/?ref=abc&th=1", "https://amazon.com/dp/B000123"},
+		{"https://github.com/acme/tool/blob/main/README.md?ref=v2", "https://github.com/acme/tool/blob/main/README.md?ref=v2"},
+		{"https://www.producthunt.com/posts/tool?ref=hn", "https://producthunt.com/posts/tool"},
+		{"mailto:someone@example.com", "mailto:someone@example.com"},
+	}
+
+	for _, tt := range tests {
+		got, err := CanonicalizeURL(tt.input, DefaultCanonicalRules)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Fatalf("%s: expected %s, got %s", tt.input, tt.want, got)
+		}
+	}
+}
+
+func TestCanonicalizeURL_DomainRules(t *testing.T) {
+	rules := map[string]CanonicalRule{
+		"docs.example.com": {KeepFragment: true},
+		"example.org":      {StripParams: []string{"session"}},
+	}
+
+	got, _ := CanonicalizeURL("https://docs.example.com/guide#install", rules)
+	if got != "https://docs.example.com/guide#install" {
+		t.Fatalf("expected fragment to be kept, got %s", got)
+	}
+
+	got, _ = CanonicalizeURL("https://blog.example.org/post?session=1&page=2", rules)
+	if got != "https://blog.example.org/post?page=2" {
+		t.Fatalf("expected session param to be stripped, got %s", got)
+	}
+}
+
+func TestBookmarkDedupService_Merge(t *testing.T) {
+	db := newTestDB(t, &models.BookmarkSnapshot{}, &models.CanonicalURLRule{}, &models.CollectionBookmark{},
+		&models.BookmarkHighlight{}, &models.TeamBookmark{}, &models.CalendarEvent{}, &models.TimeEntry{},
+		&models.ScrapedContent{}, &models.FeedItem{}, &models.ReadingQueueItem{}, &models.BookmarkLinkCheck{})
+
+	goTag := models.Tag{Name: "go", UserID: 1}
+	webTag := models.Tag{Name: "web", UserID: 1}
+	db.Create(&goTag)
+	db.Create(&webTag)
+
+	service := NewBookmarkDedupService(db)
+	earliest := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	primary := models.Bookmark{UserID: 1, Title: "Article", URL: "https://example.com/a", Tags: []models.Tag{goTag}}
+	older := models.Bookmark{UserID: 1, Title: "Article", URL: "http://example.com/a/?utm_source=feed", IsFavorite: true, Tags: []models.Tag{webTag}}
+	other := models.Bookmark{UserID: 1, Title: "Article", URL: "https://www.example.com/a#top", IsRead: true, Description: "kept"}
+	for _, bookmark := range []*models.Bookmark{&primary, &older, &other} {
+		bookmark.CanonicalKey = service.CanonicalKey(1, bookmark.URL)
+		if err := db.Create(bookmark).Error; err != nil {
+			t.Fatalf("failed to seed bookmark: %v", err)
+		}
+	}
+	db.Model(&older).UpdateColumn("created_at", earliest)
+	db.Create(&models.BookmarkSnapshot{UserID: 1, BookmarkID: primary.ID, Version: 1, SourceURL: primary.URL})
+	db.Create(&models.BookmarkSnapshot{UserID: 1, BookmarkID: older.ID, Version: 1, SourceURL: older.URL})
+	feedItem := models.FeedItem{UserID: 1, FeedID: 1, GUID: "a", URL: older.URL, BookmarkID: &older.ID}
+	db.Create(&feedItem)
+	db.Create(&models.BookmarkLinkCheck{UserID: 1, BookmarkID: other.ID, Status: models.LinkStatusOK})
+	db.Create(&models.ReadingQueueItem{UserID: 1, BookmarkID: older.ID, SurfaceCount: 2})
+	db.Create(&models.ReadingQueueItem{UserID: 1, BookmarkID: other.ID, SurfaceCount: 1})
+
+	groups, err := service.DuplicateGroups(1)
+	if err != nil || len(groups) != 1 || len(groups[0].Bookmarks) != 3 {
+		t.Fatalf("expected one group of three duplicates, got %+v (err %v)", groups, err)
+	}
+
+	merged, err := service.Merge(1, primary.ID, []uint{older.ID, other.ID})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !merged.CreatedAt.Equal(earliest) {
+		t.Fatalf("expected earliest created_at, got %v", merged.CreatedAt)
+	}
+	if !merged.IsFavorite || !merged.IsRead || merged.Description != "kept" {
+		t.Fatalf("unexpected merged flags: %+v", merged)
+	}
+	if len(merged.Tags) != 2 {
+		t.Fatalf("expected union of tags, got %d", len(merged.Tags))
+	}
+
+	var remaining int64
+	db.Model(&models.Bookmark{}).Count(&remaining)
+	if remaining != 1 {
+		t.Fatalf("expected duplicates to be removed, %d bookmarks left", remaining)
+	}
+
+	var versions []int
+	db.Model(&models.BookmarkSnapshot{}).Where("bookmark_id = ?", primary.ID).Order("version").Pluck("version", &versions)
+	if len(versions) != 2 || versions[1] != 2 {
+		t.Fatalf("expected snapshots to be renumbered onto the primary, got %v", versions)
+	}
+
+	db.First(&feedItem, feedItem.ID)
+	if feedItem.BookmarkID == nil || *feedItem.BookmarkID != primary.ID {
+		t.Fatalf("expected the feed item to point at the primary, got %v", feedItem.BookmarkID)
+	}
+	var checks int64
+	db.Model(&models.BookmarkLinkCheck{}).Where("bookmark_id = ?", primary.ID).Count(&checks)
+	if checks != 1 {
+		t.Fatalf("expected the link check history to move to the primary, got %d", checks)
+	}
+	var queueItems []models.ReadingQueueItem
+	db.Find(&queueItems)
+	if len(queueItems) != 1 || queueItems[0].BookmarkID != primary.ID || queueItems[0].SurfaceCount != 2 {
+		t.Fatalf("expected a single queue entry on the primary, got %+v", queueItems)
+	}
+
+	if _, err := service.Merge(1, primary.ID, []uint{9999}); err != gorm.ErrRecordNotFound {
+		t.Fatalf("expected not found for unknown duplicate, got %v", err)
+	}
+}
@@ -0,0 +1,420 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+// ErrEmptyHighlight is returned when a highlight has no quoted text
+var ErrEmptyHighlight = errors.New("highlight text is required")
+
+// HighlightInput describes a highlight to create
+type HighlightInput struct {
+	Exact       string `json:"exact"`
+	Prefix      string `json:"prefix"`
+	Suffix      string `json:"suffix"`
+	StartOffset int    `json:"start_offset"`
+	EndOffset   int    `json:"end_offset"`
+	Note        string `json:"note"`
+	Color       string `json:"color"`
+	PageURL     string `json:"page_url"`
+	Source      string `json:"-"`
+}
+
+// BookmarkHighlightService manages highlights on bookmark content
+type BookmarkHighlightService struct {
+	db *gorm.DB
+}
+
+// NewBookmarkHighlightService creates a new highlight service
+func NewBookmarkHighlightService(db *gorm.DB) *BookmarkHighlightService {
+	return &BookmarkHighlightService{db: db}
+}
+
+// List returns the highlights of one of the user's bookmarks in reading order
+func (s *BookmarkHighlightService) List(userID, bookmarkID uint) ([]models.BookmarkHighlight, error) {
+	if err := s.db.Select("id").Where("id = ? AND user_id = ?", bookmarkID, userID).First(&models.Bookmark{}).Error; err != nil {
+		return nil, err
+	}
+
+	var highlights []models.BookmarkHighlight
+	err := s.db.Where("bookmark_id = ? AND user_id = ?", bookmarkID, userID).
+		Order("is_orphaned ASC, start_offset ASC, id ASC").
+		Find(&highlights).Error
+	return highlights, err
+}
+
+// Create anchors a new highlight in the bookmark's content and stores it.
+// Highlights on bookmarks without extracted content are kept as given and
+// anchored once the content arrives.
+func (s *BookmarkHighlightService) Create(userID, bookmarkID uint, input HighlightInput) (*models.BookmarkHighlight, error) {
+	var bookmark models.Bookmark
+	if err := s.db.Where("id = ? AND user_id = ?", bookmarkID, userID).First(&bookmark).Error; err != nil {
+		return nil, err
+	}
+	return s.create(s.db, &bookmark, input)
+}
+
+func (s *BookmarkHighlightService) create(tx *gorm.DB, bookmark *models.Bookmark, input HighlightInput) (*models.BookmarkHighlight, error) {
+	if strings.TrimSpace(input.Exact) == "" {
+		return nil, ErrEmptyHighlight
+	}
+
+	highlight := models.BookmarkHighlight{
+		UserID:      bookmark.UserID,
+		BookmarkID:  bookmark.ID,
+		Exact:       input.Exact,
+		Prefix:      input.Prefix,
+		Suffix:      input.Suffix,
+		StartOffset: input.StartOffset,
+		EndOffset:   input.EndOffset,
+		Note:        input.Note,
+		Color:       input.Color,
+		Source:      input.Source,
+		PageURL:     input.PageURL,
+	}
+	if highlight.Color == "" {
+		highlight.Color = "yellow"
+	}
+	if highlight.Source == "" {
+		highlight.Source = "app"
+	}
+	anchorHighlight(&highlight, bookmark.Content)
+
+	if err := tx.Create(&highlight).Error; err != nil {
+		return nil, err
+	}
+	return &highlight, nil
+}
+
+// PushPageHighlights stores highlights made on a live page, saving the page
+// as a bookmark first unless the user already has it. Highlights that were
+// already pushed are returned instead of being duplicated.
+func (s *BookmarkHighlightService) PushPageHighlights(userID uint, pageURL, title string, inputs []HighlightInput) (*models.Bookmark, []models.BookmarkHighlight, bool, error) {
+	if strings.TrimSpace(pageURL) == "" {
+		return nil, nil, false, fmt.Errorf("page URL is required")
+	}
+
+	dedupService := NewBookmarkDedupService(s.db)
+	key := dedupService.CanonicalKey(userID, pageURL)
+	duplicates, err := dedupService.FindDuplicates(userID, key, 0)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	var (
+		bookmark   models.Bookmark
+		highlights []models.BookmarkHighlight
+		created    bool
+	)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if len(duplicates) > 0 {
+			bookmark = duplicates[0]
+		} else {
+			bookmark = models.Bookmark{
+				UserID:       userID,
+				Title:        strings.TrimSpace(title),
+				URL:          pageURL,
+				CanonicalKey: key,
+			}
+			if bookmark.Title == "" {
+				bookmark.Title = pageURL
+			}
+			if err := tx.Omit("Tags").Create(&bookmark).Error; err != nil {
+				return err
+			}
+			created = true
+		}
+
+		var existing []models.BookmarkHighlight
+		if err := tx.Where("bookmark_id = ? AND user_id = ?", bookmark.ID, userID).Find(&existing).Error; err != nil {
+			return err
+		}
+
+		for _, input := range inputs {
+			if previous := findSameHighlight(existing, input); previous != nil {
+				highlights = append(highlights, *previous)
+				continue
+			}
+
+			input.Source = "extension"
+			if input.PageURL == "" {
+				input.PageURL = pageURL
+			}
+			highlight, err := s.create(tx, &bookmark, input)
+			if err != nil {
+				return err
+			}
+			existing = append(existing, *highlight)
+			highlights = append(highlights, *highlight)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return &bookmark, highlights, created, nil
+}
+
+// Reanchor locates the bookmark's highlights in freshly extracted content,
+// flagging the ones whose quote is gone. It returns how many are orphaned.
+func (s *BookmarkHighlightService) Reanchor(bookmarkID uint, content string) (int, error) {
+	var highlights []models.BookmarkHighlight
+	if err := s.db.Where("bookmark_id = ?", bookmarkID).Find(&highlights).Error; err != nil {
+		return 0, err
+	}
+
+	orphaned := 0
+	for i := range highlights {
+		highlight := &highlights[i]
+		anchorHighlight(highlight, content)
+		if highlight.IsOrphaned {
+			orphaned++
+		}
+		if err := s.db.Model(&models.BookmarkHighlight{}).Where("id = ?", highlight.ID).UpdateColumns(map[string]interface{}{
+			"start_offset": highlight.StartOffset,
+			"end_offset":   highlight.EndOffset,
+			"is_orphaned":  highlight.IsOrphaned,
+		}).Error; err != nil {
+			return orphaned, err
+		}
+	}
+	return orphaned, nil
+}
+
+// ExportBookmark renders the highlights of one bookmark as markdown
+func (s *BookmarkHighlightService) ExportBookmark(userID, bookmarkID uint) ([]byte, error) {
+	var bookmark models.Bookmark
+	if err := s.db.Where("id = ? AND user_id = ?", bookmarkID, userID).First(&bookmark).Error; err != nil {
+		return nil, err
+	}
+	highlights, err := s.List(userID, bookmarkID)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	writeHighlightsMarkdown(&buf, "#", &bookmark, highlights)
+	return buf.Bytes(), nil
+}
+
+// ExportTag renders the highlights of every bookmark carrying the tag as one
+// markdown document. Bookmarks without highlights are left out.
+func (s *BookmarkHighlightService) ExportTag(userID uint, tagName string) ([]byte, error) {
+	var tag models.Tag
+	if err := s.db.Where("user_id = ? AND LOWER(name) = ?", userID, strings.ToLower(strings.TrimSpace(tagName))).First(&tag).Error; err != nil {
+		return nil, err
+	}
+
+	var bookmarks []models.Bookmark
+	if err := s.db.Joins("JOIN bookmark_tags ON bookmark_tags.bookmark_id = bookmarks.id").
+		Where("bookmark_tags.tag_id = ? AND bookmarks.user_id = ?", tag.ID, userID).
+		Order("bookmarks.title ASC, bookmarks.id ASC").
+		Find(&bookmarks).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		ids = append(ids, bookmark.ID)
+	}
+	var highlights []models.BookmarkHighlight
+	if len(ids) > 0 {
+		if err := s.db.Where("bookmark_id IN ? AND user_id = ?", ids, userID).
+			Order("is_orphaned ASC, start_offset ASC, id ASC").
+			Find(&highlights).Error; err != nil {
+			return nil, err
+		}
+	}
+	byBookmark := make(map[uint][]models.BookmarkHighlight)
+	for _, highlight := range highlights {
+		byBookmark[highlight.BookmarkID] = append(byBookmark[highlight.BookmarkID], highlight)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Highlights tagged %s\n", tag.Name)
+	for i := range bookmarks {
+		if len(byBookmark[bookmarks[i].ID]) == 0 {
+			continue
+		}
+		buf.WriteString("\n")
+		writeHighlightsMarkdown(&buf, "##", &bookmarks[i], byBookmark[bookmarks[i].ID])
+	}
+	return buf.Bytes(), nil
+}
+
+// writeHighlightsMarkdown writes a bookmark heading followed by its
+// highlights as block quotes, each with its note underneath
+func writeHighlightsMarkdown(buf *bytes.Buffer, heading string, bookmark *models.Bookmark, highlights []models.BookmarkHighlight) {
+	fmt.Fprintf(buf, "%s %s\n\n", heading, collapseSpaces(bookmark.Title))
+	if bookmark.URL != "" {
+		fmt.Fprintf(buf, "<%s>\n\n", bookmark.URL)
+	}
+	if bookmark.Author != "" {
+		fmt.Fprintf(buf, "By %s\n\n", collapseSpaces(bookmark.Author))
+	}
+
+	for _, highlight := range highlights {
+		for _, line := range strings.Split(strings.TrimSpace(highlight.Exact), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				buf.WriteString(">\n")
+				continue
+			}
+			fmt.Fprintf(buf, "> %s\n", line)
+		}
+		buf.WriteString("\n")
+		if note := strings.TrimSpace(highlight.Note); note != "" {
+			fmt.Fprintf(buf, "%s\n\n", note)
+		}
+	}
+}
+
+// findSameHighlight returns the highlight with the same quote selector
+func findSameHighlight(highlights []models.BookmarkHighlight, input HighlightInput) *models.BookmarkHighlight {
+	exact := collapseSpaces(input.Exact)
+	prefix := collapseSpaces(input.Prefix)
+	suffix := collapseSpaces(input.Suffix)
+	for i := range highlights {
+		if collapseSpaces(highlights[i].Exact) == exact &&
+			collapseSpaces(highlights[i].Prefix) == prefix &&
+			collapseSpaces(highlights[i].Suffix) == suffix {
+			return &highlights[i]
+		}
+	}
+	return nil
+}
+
+// collapseSpaces joins the words of text with single spaces
+func collapseSpaces(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// anchorHighlight updates the highlight's offsets and orphaned flag against
+// content. Empty content leaves the highlight untouched.
+func anchorHighlight(highlight *models.BookmarkHighlight, content string) {
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+
+	start, end, ok := locateQuote(content, highlight.Exact, highlight.Prefix, highlight.Suffix, highlight.StartOffset)
+	if !ok {
+		highlight.IsOrphaned = true
+		return
+	}
+	highlight.StartOffset = start
+	highlight.EndOffset = end
+	highlight.IsOrphaned = false
+}
+
+// locateQuote finds the occurrence of exact in content that best matches
+// the surrounding prefix and suffix, using hint to break ties. Runs of
+// whitespace are compared as a single space and the search falls back to
+// ignoring case, since extracted text and the live page rarely agree on
+// either. Offsets are in characters of content.
+func locateQuote(content, exact, prefix, suffix string, hint int) (int, int, bool) {
+	for _, fold := range []bool{false, true} {
+		text, positions := normalizeForMatch(content, fold)
+		quote, _ := normalizeForMatch(strings.TrimSpace(exact), fold)
+		if len(quote) == 0 {
+			return 0, 0, false
+		}
+		before, _ := normalizeForMatch(strings.TrimSpace(prefix), fold)
+		after, _ := normalizeForMatch(strings.TrimSpace(suffix), fold)
+
+		best, bestScore, bestDistance := -1, -1, 0
+		for i := 0; i+len(quote) <= len(text); i++ {
+			if !runesEqualAt(text, i, quote) {
+				continue
+			}
+
+			score := commonSuffixLength(trimRightSpace(text[:i]), before) +
+				commonPrefixLength(trimLeftSpace(text[i+len(quote):]), after)
+			distance := positions[i] - hint
+			if distance < 0 {
+				distance = -distance
+			}
+			if score > bestScore || (score == bestScore && distance < bestDistance) {
+				best, bestScore, bestDistance = i, score, distance
+			}
+		}
+
+		if best >= 0 {
+			return positions[best], positions[best+len(quote)-1] + 1, true
+		}
+	}
+	return 0, 0, false
+}
+
+// normalizeForMatch collapses whitespace runs into single spaces and
+// optionally lowercases text. The second result maps each returned rune
+// back to its character offset in text.
+func normalizeForMatch(text string, fold bool) ([]rune, []int) {
+	runes := make([]rune, 0, len(text))
+	positions := make([]int, 0, len(text))
+	inSpace := false
+	offset := 0
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			if !inSpace {
+				runes = append(runes, ' ')
+				positions = append(positions, offset)
+			}
+			inSpace = true
+		} else {
+			if fold {
+				r = unicode.ToLower(r)
+			}
+			runes = append(runes, r)
+			positions = append(positions, offset)
+			inSpace = false
+		}
+		offset++
+	}
+	return runes, positions
+}
+
+func runesEqualAt(text []rune, at int, quote []rune) bool {
+	for j, r := range quote {
+		if text[at+j] != r {
+			return false
+		}
+	}
+	return true
+}
+
+func commonSuffixLength(a, b []rune) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[len(a)-1-n] == b[len(b)-1-n] {
+		n++
+	}
+	return n
+}
+
+func commonPrefixLength(a, b []rune) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+func trimRightSpace(runes []rune) []rune {
+	for len(runes) > 0 && runes[len(runes)-1] == ' ' {
+		runes = runes[:len(runes)-1]
+	}
+	return runes
+}
+
+func trimLeftSpace(runes []rune) []rune {
+	for len(runes) > 0 && runes[0] == ' ' {
+		runes = runes[1:]
+	}
+	return runes
+}
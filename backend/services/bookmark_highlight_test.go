@@ -0,0 +1,107 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+func TestLocateQuote(t *testing.T) {
+	content := "Go is fun. Testing in Go is fun too.\nReally,   Go is\nfun."
+
+	tests := []struct {
+		name           string
+		exact          string
+		prefix, suffix string
+		hint           int
+		start, end     int
+		ok             bool
+	}{
+		{name: "first by position", exact: "Go is fun", hint: 0, start: 0, end: 9, ok: true},
+		{name: "prefix wins", exact: "Go is fun", prefix: "Testing in", start: 22, end: 31, ok: true},
+		{name: "suffix wins over hint", exact: "Go is fun", suffix: "too.", hint: 0, start: 22, end: 31, ok: true},
+		{name: "whitespace differences", exact: "Go is fun.", prefix: "Really,", start: 47, end: 57, ok: true},
+		{name: "case fallback", exact: "testing IN go", start: 11, end: 24, ok: true},
+		{name: "missing", exact: "Rust is fun", ok: false},
+	}
+
+	for _, tt := range tests {
+		start, end, ok := locateQuote(content, tt.exact, tt.prefix, tt.suffix, tt.hint)
+		if ok != tt.ok || (ok && (start != tt.start || end != tt.end)) {
+			t.Fatalf("%s: got %d-%d ok=%v, want %d-%d ok=%v", tt.name, start, end, ok, tt.start, tt.end, tt.ok)
+		}
+	}
+}
+
+func TestBookmarkHighlightService(t *testing.T) {
+	db := newTestDB(t, &models.CanonicalURLRule{}, &models.BookmarkHighlight{})
+
+	service := NewBookmarkHighlightService(db)
+
+	tag := models.Tag{UserID: 1, Name: "reading"}
+	db.Create(&tag)
+	bookmark := models.Bookmark{UserID: 1, Title: "Essay", URL: "https://example.com/essay", CanonicalKey: "https://example.com/essay",
+		Content: "Intro text. The key idea is simple. Outro text.", Tags: []models.Tag{tag}}
+	db.Create(&bookmark)
+
+	highlight, err := service.Create(1, bookmark.ID, HighlightInput{Exact: "The key idea", Suffix: "is simple", Note: "Remember this"})
+	if err != nil {
+		t.Fatalf("failed to create highlight: %v", err)
+	}
+	if highlight.StartOffset != 12 || highlight.EndOffset != 24 || highlight.IsOrphaned || highlight.Color != "yellow" {
+		t.Fatalf("unexpected highlight: %+v", highlight)
+	}
+	if _, err := service.Create(2, bookmark.ID, HighlightInput{Exact: "Intro"}); err != gorm.ErrRecordNotFound {
+		t.Fatalf("expected other users to be rejected, got %v", err)
+	}
+	if _, err := service.Create(1, bookmark.ID, HighlightInput{Exact: "  "}); err != ErrEmptyHighlight {
+		t.Fatalf("expected empty highlight to fail, got %v", err)
+	}
+
+	// The extension pushes the same passage plus a new one from the live page
+	pushed, highlights, created, err := service.PushPageHighlights(1, "https://example.com/essay?utm_source=x", "Essay", []HighlightInput{
+		{Exact: "The  key idea", Suffix: "is simple"},
+		{Exact: "Outro text"},
+	})
+	if err != nil {
+		t.Fatalf("failed to push highlights: %v", err)
+	}
+	if created || pushed.ID != bookmark.ID || len(highlights) != 2 || highlights[0].ID != highlight.ID {
+		t.Fatalf("expected the existing bookmark and highlight to be reused, got created=%v %+v", created, highlights)
+	}
+	if highlights[1].Source != "extension" || highlights[1].PageURL != "https://example.com/essay?utm_source=x" {
+		t.Fatalf("unexpected pushed highlight: %+v", highlights[1])
+	}
+
+	// Unknown pages are saved first; their highlights wait for content
+	pushed, highlights, created, err = service.PushPageHighlights(1, "https://example.com/new", "", []HighlightInput{{Exact: "Fresh", StartOffset: 5, EndOffset: 10}})
+	if err != nil || !created || pushed.Title != "https://example.com/new" || highlights[0].IsOrphaned || highlights[0].StartOffset != 5 {
+		t.Fatalf("unexpected push to new page: created=%v %+v (err %v)", created, highlights, err)
+	}
+
+	// Refreshed content moves the surviving highlight and orphans the other
+	orphaned, err := service.Reanchor(bookmark.ID, "New intro. The key idea is simple, still.")
+	if err != nil || orphaned != 1 {
+		t.Fatalf("expected one orphaned highlight, got %d (err %v)", orphaned, err)
+	}
+	list, _ := service.List(1, bookmark.ID)
+	if len(list) != 2 || list[0].ID != highlight.ID || list[0].StartOffset != 11 || list[0].IsOrphaned || !list[1].IsOrphaned {
+		t.Fatalf("unexpected highlights after refresh: %+v", list)
+	}
+
+	markdown, err := service.ExportTag(1, "Reading")
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	expected := "# Highlights tagged reading\n\n## Essay\n\n<https://example.com/essay>\n\n> The key idea\n\nRemember this\n\n> Outro text\n\n"
+	if string(markdown) != expected {
+		t.Fatalf("unexpected markdown:\n%s", markdown)
+	}
+
+	markdown, err = service.ExportBookmark(1, bookmark.ID)
+	if err != nil || !strings.HasPrefix(string(markdown), "# Essay\n") {
+		t.Fatalf("unexpected bookmark export %q (err %v)", markdown, err)
+	}
+}
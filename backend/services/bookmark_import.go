@@ -0,0 +1,207 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// bookmarkImportProgressEvery controls how often progress is persisted
+	bookmarkImportProgressEvery = 25
+	// bookmarkImportDetailLimit caps the duplicate/failure lists stored on a job
+	bookmarkImportDetailLimit = 200
+)
+
+// BookmarkImportService stores imported bookmarks in the background
+type BookmarkImportService struct {
+	db *gorm.DB
+}
+
+// NewBookmarkImportService creates a new bookmark import service
+func NewBookmarkImportService(db *gorm.DB) *BookmarkImportService {
+	return &BookmarkImportService{db: db}
+}
+
+// StartImport records an import job and processes the items asynchronously
+func (s *BookmarkImportService) StartImport(userID uint, source, fileName string, items []ImportedBookmark) (*models.BookmarkImport, error) {
+	job := models.BookmarkImport{
+		UserID:     userID,
+		Source:     source,
+		FileName:   fileName,
+		Status:     models.BookmarkImportPending,
+		TotalItems: len(items),
+	}
+
+	if err := s.db.Create(&job).Error; err != nil {
+		return nil, fmt.Errorf("failed to create import job: %w", err)
+	}
+
+	go s.processImport(job.ID, items)
+
+	return &job, nil
+}
+
+// processImport creates bookmarks for an import job, skipping duplicates
+func (s *BookmarkImportService) processImport(jobID uint, items []ImportedBookmark) {
+	var job models.BookmarkImport
+	if err := s.db.First(&job, jobID).Error; err != nil {
+		return
+	}
+
+	now := time.Now()
+	job.Status = models.BookmarkImportProcessing
+	job.StartedAt = &now
+	s.db.Save(&job)
+
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Bookmark import %d panicked: %v", jobID, r)
+			job.Status = models.BookmarkImportFailed
+			job.ErrorMessage = fmt.Sprintf("import aborted: %v", r)
+			completedAt := time.Now()
+			job.CompletedAt = &completedAt
+			s.db.Save(&job)
+		}
+	}()
+
+	rules, err := NewBookmarkDedupService(s.db).Rules(job.UserID)
+	if err != nil {
+		rules = DefaultCanonicalRules
+	}
+
+	existing, err := s.existingKeys(job.UserID, rules)
+	if err != nil {
+		job.Status = models.BookmarkImportFailed
+		job.ErrorMessage = err.Error()
+		completedAt := time.Now()
+		job.CompletedAt = &completedAt
+		s.db.Save(&job)
+		return
+	}
+
+	tagCache := make(map[string]*models.Tag)
+
+	for i, item := range items {
+		rawURL := strings.TrimSpace(item.URL)
+		key := canonicalKeyWithRules(rawURL, rules)
+		switch {
+		case rawURL == "":
+			job.FailedCount++
+			job.Failures = appendCapped(job.Failures, fmt.Sprintf("entry %d: missing URL", i+1))
+		case existing[key]:
+			job.DuplicateCount++
+			job.Duplicates = appendCapped(job.Duplicates, rawURL)
+		default:
+			if err := s.createBookmark(job.UserID, item, key, tagCache); err != nil {
+				// Tags created inside the rolled back transaction are gone
+				tagCache = make(map[string]*models.Tag)
+				job.FailedCount++
+				job.Failures = appendCapped(job.Failures, fmt.Sprintf("%s: %v", rawURL, err))
+			} else {
+				job.CreatedCount++
+				existing[key] = true
+			}
+		}
+
+		job.ProcessedItems = i + 1
+		if job.ProcessedItems%bookmarkImportProgressEvery == 0 {
+			job.Progress = float64(job.ProcessedItems) / float64(len(items)) * 100
+			s.db.Save(&job)
+		}
+	}
+
+	job.Status = models.BookmarkImportCompleted
+	job.Progress = 100
+	completedAt := time.Now()
+	job.CompletedAt = &completedAt
+	s.db.Save(&job)
+}
+
+// existingKeys returns the canonical keys of the URLs already bookmarked by the user
+func (s *BookmarkImportService) existingKeys(userID uint, rules map[string]CanonicalRule) (map[string]bool, error) {
+	var bookmarks []models.Bookmark
+	if err := s.db.Select("url", "canonical_key").Where("user_id = ?", userID).Find(&bookmarks).Error; err != nil {
+		return nil, fmt.Errorf("failed to load existing bookmarks: %w", err)
+	}
+
+	existing := make(map[string]bool, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		key := bookmark.CanonicalKey
+		if key == "" {
+			key = canonicalKeyWithRules(bookmark.URL, rules)
+		}
+		existing[key] = true
+	}
+	return existing, nil
+}
+
+// createBookmark stores one imported bookmark together with its tags
+func (s *BookmarkImportService) createBookmark(userID uint, item ImportedBookmark, canonicalKey string, tagCache map[string]*models.Tag) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		bookmark := models.Bookmark{
+			UserID:       userID,
+			Title:        item.Title,
+			URL:          strings.TrimSpace(item.URL),
+			CanonicalKey: canonicalKey,
+			Description:  item.Description,
+			IsRead:       item.IsRead,
+			IsFavorite:   item.IsFavorite,
+			ReadAt:       item.ReadAt,
+		}
+		if bookmark.Title == "" {
+			bookmark.Title = bookmark.URL
+		}
+		if item.CreatedAt != nil {
+			bookmark.CreatedAt = *item.CreatedAt
+			bookmark.UpdatedAt = *item.CreatedAt
+		}
+		if bookmark.IsRead && bookmark.ReadAt == nil {
+			bookmark.ReadAt = item.CreatedAt
+		}
+
+		if err := tx.Omit("Tags").Create(&bookmark).Error; err != nil {
+			return err
+		}
+
+		for _, name := range item.Tags {
+			tag, err := findOrCreateImportTag(tx, userID, name, tagCache)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&bookmark).Association("Tags").Append(tag); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// findOrCreateImportTag resolves a tag by name or alias for the user,
+// creating it if needed
+func findOrCreateImportTag(tx *gorm.DB, userID uint, name string, cache map[string]*models.Tag) (*models.Tag, error) {
+	key := tagKey(name)
+	if tag, ok := cache[key]; ok {
+		return tag, nil
+	}
+
+	tag, err := FindOrCreateTag(tx, userID, name)
+	if err != nil {
+		return nil, err
+	}
+
+	cache[key] = tag
+	return tag, nil
+}
+
+func appendCapped(list []string, value string) []string {
+	if len(list) >= bookmarkImportDetailLimit {
+		return list
+	}
+	return append(list, value)
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Supported bookmark import formats
+const (
+	ImportFormatNetscape   = "netscape"
+	ImportFormatPocket     = "pocket"
+	ImportFormatPocketCSV  = "pocket_csv"
+	ImportFormatRaindrop   = "raindrop"
+	ImportFormatPinboard   = "pinboard"
+	ImportFormatWallabag   = "wallabag"
+	ImportFormatLinkwarden = "linkwarden"
+)
+
+// ParseBookmarkExport parses an export file in the given format
+func ParseBookmarkExport(format string, r io.Reader) ([]ImportedBookmark, error) {
+	switch format {
+	case ImportFormatNetscape:
+		return ParseNetscapeBookmarks(r)
+	case ImportFormatPocket:
+		return ParsePocketHTML(r)
+	case ImportFormatPocketCSV:
+		return ParsePocketCSV(r)
+	case ImportFormatRaindrop:
+		return ParseRaindropCSV(r)
+	case ImportFormatPinboard:
+		return ParsePinboardJSON(r)
+	case ImportFormatWallabag:
+		return ParseWallabagJSON(r)
+	case ImportFormatLinkwarden:
+		return ParseLinkwardenJSON(r)
+	default:
+		return nil, fmt.Errorf("unsupported import format: %s", format)
+	}
+}
+
+// ParsePocketHTML parses the ril_export.html file produced by Pocket. Items
+// listed under the "Read Archive" heading are imported as read.
+func ParsePocketHTML(r io.Reader) ([]ImportedBookmark, error) {
+	tokenizer := xhtml.NewTokenizer(r)
+
+	var (
+		bookmarks []ImportedBookmark
+		heading   strings.Builder
+		inHeading bool
+		isArchive bool
+		current   *ImportedBookmark
+		linkText  strings.Builder
+	)
+
+	for {
+		switch tokenizer.Next() {
+		case xhtml.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return nil, fmt.Errorf("failed to parse Pocket export: %w", err)
+			}
+			return bookmarks, nil
+
+		case xhtml.StartTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.H1:
+				inHeading = true
+				heading.Reset()
+			case atom.A:
+				current = &ImportedBookmark{IsRead: isArchive}
+				linkText.Reset()
+				for _, attr := range token.Attr {
+					switch strings.ToLower(attr.Key) {
+					case "href":
+						current.URL = strings.TrimSpace(attr.Val)
+					case "time_added":
+						current.CreatedAt = parseImportTime(attr.Val)
+					case "tags":
+						current.Tags = splitTagList(attr.Val)
+					}
+				}
+			}
+
+		case xhtml.EndTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.H1:
+				inHeading = false
+				isArchive = strings.Contains(strings.ToLower(heading.String()), "archive")
+			case atom.A:
+				if current != nil && current.URL != "" {
+					current.Title = strings.TrimSpace(linkText.String())
+					bookmarks = append(bookmarks, *current)
+				}
+				current = nil
+			}
+
+		case xhtml.TextToken:
+			if inHeading {
+				heading.Write(tokenizer.Text())
+			} else if current != nil {
+				linkText.Write(tokenizer.Text())
+			}
+		}
+	}
+}
+
+// ParsePocketCSV parses the CSV export Pocket provides (title, url,
+// time_added, tags, status) where tags are separated by "|".
+func ParsePocketCSV(r io.Reader) ([]ImportedBookmark, error) {
+	rows, err := readCSVRecords(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Pocket CSV: %w", err)
+	}
+
+	bookmarks := make([]ImportedBookmark, 0, len(rows))
+	for _, row := range rows {
+		bookmark := ImportedBookmark{
+			URL:       row["url"],
+			Title:     row["title"],
+			CreatedAt: parseImportTime(row["time_added"]),
+			IsRead:    strings.EqualFold(row["status"], "archive"),
+		}
+		for _, tag := range strings.Split(row["tags"], "|") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				bookmark.Tags = append(bookmark.Tags, tag)
+			}
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+	return bookmarks, nil
+}
+
+// ParseRaindropCSV parses a Raindrop.io CSV export. The folder becomes a tag
+// alongside the item's own tags.
+func ParseRaindropCSV(r io.Reader) ([]ImportedBookmark, error) {
+	rows, err := readCSVRecords(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Raindrop CSV: %w", err)
+	}
+
+	bookmarks := make([]ImportedBookmark, 0, len(rows))
+	for _, row := range rows {
+		description := row["note"]
+		if description == "" {
+			description = row["excerpt"]
+		}
+
+		bookmark := ImportedBookmark{
+			URL:         row["url"],
+			Title:       row["title"],
+			Description: description,
+			Tags:        splitTagList(row["tags"]),
+			CreatedAt:   parseImportTime(row["created"]),
+			IsFavorite:  parseImportBool(row["favorite"]),
+		}
+		if folder := strings.TrimSpace(row["folder"]); folder != "" && !strings.EqualFold(folder, "unsorted") {
+			bookmark.Tags = append(bookmark.Tags, folder)
+		}
+		bookmark.Tags = uniqueTags(bookmark.Tags)
+		bookmarks = append(bookmarks, bookmark)
+	}
+	return bookmarks, nil
+}
+
+type pinboardPost struct {
+	Href        string `json:"href"`
+	Description string `json:"description"`
+	Extended    string `json:"extended"`
+	Time        string `json:"time"`
+	ToRead      string `json:"toread"`
+	Tags        string `json:"tags"`
+}
+
+// ParsePinboardJSON parses the JSON export from Pinboard (posts/all)
+func ParsePinboardJSON(r io.Reader) ([]ImportedBookmark, error) {
+	var posts []pinboardPost
+	if err := json.NewDecoder(r).Decode(&posts); err != nil {
+		return nil, fmt.Errorf("failed to parse Pinboard JSON: %w", err)
+	}
+
+	bookmarks := make([]ImportedBookmark, 0, len(posts))
+	for _, post := range posts {
+		bookmarks = append(bookmarks, ImportedBookmark{
+			URL:         post.Href,
+			Title:       post.Description,
+			Description: post.Extended,
+			Tags:        uniqueTags(strings.Fields(post.Tags)),
+			CreatedAt:   parseImportTime(post.Time),
+			IsRead:      !parseImportBool(post.ToRead),
+		})
+	}
+	return bookmarks, nil
+}
+
+type wallabagEntry struct {
+	URL        string          `json:"url"`
+	Title      string          `json:"title"`
+	IsArchived json.RawMessage `json:"is_archived"` // 0/1 or boolean depending on version
+	IsStarred  json.RawMessage `json:"is_starred"`
+	Tags       []string        `json:"tags"`
+	CreatedAt  string          `json:"created_at"`
+	ArchivedAt string          `json:"archived_at"`
+}
+
+// ParseWallabagJSON parses a wallabag JSON export
+func ParseWallabagJSON(r io.Reader) ([]ImportedBookmark, error) {
+	var entries []wallabagEntry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("failed to parse wallabag JSON: %w", err)
+	}
+
+	bookmarks := make([]ImportedBookmark, 0, len(entries))
+	for _, entry := range entries {
+		bookmark := ImportedBookmark{
+			URL:        entry.URL,
+			Title:      entry.Title,
+			Tags:       uniqueTags(entry.Tags),
+			CreatedAt:  parseImportTime(entry.CreatedAt),
+			IsRead:     parseImportBool(strings.Trim(string(entry.IsArchived), `"`)),
+			IsFavorite: parseImportBool(strings.Trim(string(entry.IsStarred), `"`)),
+		}
+		if bookmark.IsRead {
+			bookmark.ReadAt = parseImportTime(entry.ArchivedAt)
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+	return bookmarks, nil
+}
+
+type linkwardenLink struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	Description string `json:"description"`
+	CreatedAt   string `json:"createdAt"`
+	Tags        []struct {
+		Name string `json:"name"`
+	} `json:"tags"`
+	PinnedBy []json.RawMessage `json:"pinnedBy"`
+}
+
+type linkwardenCollection struct {
+	Name  string           `json:"name"`
+	Links []linkwardenLink `json:"links"`
+}
+
+// ParseLinkwardenJSON parses a Linkwarden backup. Collections become tags and
+// pinned links are imported as favorites.
+func ParseLinkwardenJSON(r io.Reader) ([]ImportedBookmark, error) {
+	var backup struct {
+		Collections []linkwardenCollection `json:"collections"`
+	}
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return nil, fmt.Errorf("failed to parse Linkwarden JSON: %w", err)
+	}
+
+	var bookmarks []ImportedBookmark
+	for _, collection := range backup.Collections {
+		for _, link := range collection.Links {
+			bookmark := ImportedBookmark{
+				URL:         link.URL,
+				Title:       link.Name,
+				Description: link.Description,
+				CreatedAt:   parseImportTime(link.CreatedAt),
+				IsFavorite:  len(link.PinnedBy) > 0,
+			}
+			for _, tag := range link.Tags {
+				bookmark.Tags = append(bookmark.Tags, tag.Name)
+			}
+			if collection.Name != "" && !strings.EqualFold(collection.Name, "unorganized") {
+				bookmark.Tags = append(bookmark.Tags, collection.Name)
+			}
+			bookmark.Tags = uniqueTags(bookmark.Tags)
+			bookmarks = append(bookmarks, bookmark)
+		}
+	}
+	return bookmarks, nil
+}
+
+// readCSVRecords reads a CSV file with a header row into column-name keyed maps
+func readCSVRecords(r io.Reader) ([]map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i, column := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+	}
+
+	var rows []map[string]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]string, len(header))
+		for i, value := range record {
+			if i < len(header) {
+				row[header[i]] = strings.TrimSpace(value)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// importTimeLayouts are the textual timestamp layouts seen in export files
+var importTimeLayouts = []string{
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	time.RFC1123Z,
+	time.RFC1123,
+	"2006-01-02",
+}
+
+// parseImportTime parses Unix timestamps as well as common textual layouts
+func parseImportTime(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return parseNetscapeTimestamp(value)
+	}
+	for _, layout := range importTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+	return nil
+}
+
+func parseImportBool(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "yes", "y":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/trackeep/backend/models"
+)
+
+func TestParseBookmarkExport_Formats(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		input    string
+		wantURL  string
+		wantTags string
+		wantRead bool
+		wantFav  bool
+	}{
+		{
+			name:   "pocket html archive",
+			format: ImportFormatPocket,
+			input: `<html><body><h1>Unread</h1><ul></ul><h1>Read Archive</h1><ul>
+<li><a href="https://example.com/a" time_added="1600000000" tags="go,web">A</a></li></ul></body></html>`,
+			wantURL: "https://example.com/a", wantTags: "go,web", wantRead: true,
+		},
+		{
+			name:     "pocket csv",
+			format:   ImportFormatPocketCSV,
+			input:    "title,url,time_added,tags,status\nA,https://example.com/a,1600000000,go|web,unread\n",
+			wantURL:  "https://example.com/a",
+			wantTags: "go,web",
+		},
+		{
+			name:     "raindrop csv",
+			format:   ImportFormatRaindrop,
+			input:    "id,title,note,excerpt,url,folder,tags,created,cover,highlights,favorite\n1,A,,Excerpt,https://example.com/a,Reading,\"go, web\",2021-03-04T05:06:07.000Z,,,true\n",
+			wantURL:  "https://example.com/a",
+			wantTags: "go,web,Reading",
+			wantFav:  true,
+		},
+		{
+			name:     "pinboard json",
+			format:   ImportFormatPinboard,
+			input:    `[{"href":"https://example.com/a","description":"A","extended":"","time":"2021-03-04T05:06:07Z","toread":"no","tags":"go web"}]`,
+			wantURL:  "https://example.com/a",
+			wantTags: "go,web",
+			wantRead: true,
+		},
+		{
+			name:     "wallabag json",
+			format:   ImportFormatWallabag,
+			input:    `[{"url":"https://example.com/a","title":"A","is_archived":1,"is_starred":true,"tags":["go"],"created_at":"2021-03-04T05:06:07+02:00"}]`,
+			wantURL:  "https://example.com/a",
+			wantTags: "go",
+			wantRead: true,
+			wantFav:  true,
+		},
+		{
+			name:     "linkwarden json",
+			format:   ImportFormatLinkwarden,
+			input:    `{"collections":[{"name":"Reading","links":[{"name":"A","url":"https://example.com/a","createdAt":"2021-03-04T05:06:07.000Z","tags":[{"name":"go"}],"pinnedBy":[{"id":1}]}]}]}`,
+			wantURL:  "https://example.com/a",
+			wantTags: "go,Reading",
+			wantFav:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items, err := ParseBookmarkExport(tt.format, strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(items) != 1 {
+				t.Fatalf("expected 1 item, got %d", len(items))
+			}
+
+			item := items[0]
+			if item.URL != tt.wantURL {
+				t.Fatalf("unexpected url: %s", item.URL)
+			}
+			if got := strings.Join(item.Tags, ","); got != tt.wantTags {
+				t.Fatalf("unexpected tags: %s", got)
+			}
+			if item.IsRead != tt.wantRead || item.IsFavorite != tt.wantFav {
+				t.Fatalf("unexpected flags: read=%v favorite=%v", item.IsRead, item.IsFavorite)
+			}
+			if item.CreatedAt == nil {
+				t.Fatalf("expected original timestamp to be preserved")
+			}
+		})
+	}
+}
+
+func TestParseBookmarkExport_UnknownFormat(t *testing.T) {
+	if _, err := ParseBookmarkExport("delicious", strings.NewReader("")); err == nil {
+		t.Fatalf("expected error for unsupported format")
+	}
+}
+
+func TestBookmarkImportService_SkipsDuplicates(t *testing.T) {
+	db := newTestDB(t, &models.BookmarkImport{})
+
+	if err := db.Create(&models.Bookmark{UserID: 1, Title: "Existing", URL: "https://example.com/a"}).Error; err != nil {
+		t.Fatalf("failed to seed bookmark: %v", err)
+	}
+
+	items := []ImportedBookmark{
+		{URL: "https://example.com/a", Title: "A"},
+		{URL: "https://example.com/b", Title: "B", Tags: []string{"go"}, IsRead: true, IsFavorite: true},
+		{URL: "https://example.com/b", Title: "B again"},
+		{URL: "", Title: "Broken"},
+	}
+
+	job := models.BookmarkImport{UserID: 1, Source: ImportFormatPinboard, TotalItems: len(items)}
+	if err := db.Create(&job).Error; err != nil {
+		t.Fatalf("failed to create job: %v", err)
+	}
+
+	service := NewBookmarkImportService(db)
+	service.processImport(job.ID, items)
+
+	if err := db.First(&job, job.ID).Error; err != nil {
+		t.Fatalf("failed to reload job: %v", err)
+	}
+	if job.Status != models.BookmarkImportCompleted {
+		t.Fatalf("unexpected status: %s", job.Status)
+	}
+	if job.CreatedCount != 1 || job.DuplicateCount != 2 || job.FailedCount != 1 {
+		t.Fatalf("unexpected counts: created=%d duplicate=%d failed=%d", job.CreatedCount, job.DuplicateCount, job.FailedCount)
+	}
+
+	var imported models.Bookmark
+	if err := db.Preload("Tags").Where("url = ?", "https://example.com/b").First(&imported).Error; err != nil {
+		t.Fatalf("imported bookmark not found: %v", err)
+	}
+	if !imported.IsRead || !imported.IsFavorite || len(imported.Tags) != 1 {
+		t.Fatalf("unexpected imported bookmark: %+v", imported)
+	}
+}
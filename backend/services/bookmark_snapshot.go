@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+// snapshotTimeout bounds the time spent archiving one page and its resources
+const snapshotTimeout = 3 * time.Minute
+
+// BookmarkSnapshotService creates and stores versioned offline copies of bookmarked pages
+type BookmarkSnapshotService struct {
+	db         *gorm.DB
+	storageDir string
+}
+
+// NewBookmarkSnapshotService creates a snapshot service storing archives below storageDir
+func NewBookmarkSnapshotService(db *gorm.DB, storageDir string) *BookmarkSnapshotService {
+	return &BookmarkSnapshotService{db: db, storageDir: storageDir}
+}
+
+// StartSnapshot records a new snapshot version for the bookmark and archives
+// the page in the background
+func (s *BookmarkSnapshotService) StartSnapshot(bookmark *models.Bookmark, includeWARC bool) (*models.BookmarkSnapshot, error) {
+	snapshot := &models.BookmarkSnapshot{
+		UserID:      bookmark.UserID,
+		BookmarkID:  bookmark.ID,
+		SourceURL:   bookmark.URL,
+		IncludeWARC: includeWARC,
+		Status:      models.BookmarkSnapshotPending,
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Versions keep increasing even when older snapshots were deleted
+		var latest int
+		if err := tx.Unscoped().Model(&models.BookmarkSnapshot{}).
+			Where("bookmark_id = ?", bookmark.ID).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return err
+		}
+		snapshot.Version = latest + 1
+		return tx.Create(snapshot).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	go s.processSnapshot(snapshot.ID)
+
+	return snapshot, nil
+}
+
+// processSnapshot archives the page for a pending snapshot and stores the files
+func (s *BookmarkSnapshotService) processSnapshot(snapshotID uint) {
+	var snapshot models.BookmarkSnapshot
+	if err := s.db.First(&snapshot, snapshotID).Error; err != nil {
+		return
+	}
+
+	snapshot.Status = models.BookmarkSnapshotProcessing
+	s.db.Save(&snapshot)
+
+	if err := s.capture(&snapshot); err != nil {
+		log.Printf("Snapshot %d for bookmark %d failed: %v", snapshot.ID, snapshot.BookmarkID, err)
+		snapshot.Status = models.BookmarkSnapshotFailed
+		snapshot.ErrorMessage = err.Error()
+		s.db.Save(&snapshot)
+		return
+	}
+
+	snapshot.Status = models.BookmarkSnapshotCompleted
+	snapshot.ErrorMessage = ""
+	s.db.Save(&snapshot)
+}
+
+func (s *BookmarkSnapshotService) capture(snapshot *models.BookmarkSnapshot) error {
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotTimeout)
+	defer cancel()
+
+	archive, err := ArchivePage(ctx, snapshot.SourceURL)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Join(s.storageDir, fmt.Sprint(snapshot.UserID), fmt.Sprint(snapshot.BookmarkID))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	htmlPath := filepath.Join(dir, fmt.Sprintf("v%d.html", snapshot.Version))
+	if err := os.WriteFile(htmlPath, archive.HTML, 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+
+	hash := sha256.Sum256(archive.HTML)
+	snapshot.FilePath = htmlPath
+	snapshot.FileSize = int64(len(archive.HTML))
+	snapshot.ContentHash = hex.EncodeToString(hash[:])
+	snapshot.FinalURL = archive.FinalURL
+	snapshot.Title = archive.Title
+	snapshot.CapturedAt = &archive.CapturedAt
+	snapshot.ResourceCount = len(archive.Responses) - 1
+	snapshot.FailedResources = archive.FailedResources
+
+	if snapshot.IncludeWARC {
+		warcPath := filepath.Join(dir, fmt.Sprintf("v%d.warc.gz", snapshot.Version))
+		size, err := writeWARCFile(warcPath, archive)
+		if err != nil {
+			return fmt.Errorf("failed to write WARC: %w", err)
+		}
+		snapshot.WARCPath = warcPath
+		snapshot.WARCSize = size
+	}
+
+	return nil
+}
+
+func writeWARCFile(path string, archive *PageArchive) (int64, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	if err := WriteWARC(file, archive); err != nil {
+		return 0, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// DeleteSnapshot removes a snapshot and its stored files
+func (s *BookmarkSnapshotService) DeleteSnapshot(snapshot *models.BookmarkSnapshot) error {
+	if err := s.db.Delete(snapshot).Error; err != nil {
+		return err
+	}
+
+	for _, path := range []string{snapshot.FilePath, snapshot.WARCPath} {
+		if path == "" {
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove snapshot file %s: %v", path, err)
+		}
+	}
+	return nil
+}
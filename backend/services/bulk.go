@@ -0,0 +1,505 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Bulk actions
+const (
+	BulkActionAddTags     = "add_tags"
+	BulkActionRemoveTags  = "remove_tags"
+	BulkActionSetFavorite = "set_favorite"
+	BulkActionSetRead     = "set_read"
+	BulkActionSetPinned   = "set_pinned"
+	BulkActionSetStatus   = "set_status"
+	BulkActionSetPriority = "set_priority"
+	BulkActionMove        = "move"
+	BulkActionDelete      = "delete"
+	BulkActionRestore     = "restore"
+)
+
+// maxBulkItems limits how many items one bulk request may touch
+const maxBulkItems = 500
+
+var (
+	// ErrUnsupportedBulkAction is returned for actions the resource does not support
+	ErrUnsupportedBulkAction = errors.New("action is not supported for this resource")
+	// ErrInvalidBulkRequest wraps problems with the request's parameters
+	ErrInvalidBulkRequest = errors.New("invalid bulk request")
+)
+
+// BulkRequest describes one action applied to a list of items
+type BulkRequest struct {
+	Action string `json:"action"`
+	IDs    []uint `json:"ids"`
+
+	Tags     []string `json:"tags"`      // add_tags, remove_tags
+	Value    *bool    `json:"value"`     // set_favorite, set_read, set_pinned
+	Status   string   `json:"status"`    // set_status
+	Priority string   `json:"priority"`  // set_priority
+	ParentID *uint    `json:"parent_id"` // move; nil moves to the top level
+}
+
+// BulkItemResult is the outcome for one item of a bulk request
+type BulkItemResult struct {
+	ID      uint   `json:"id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// BulkResult summarizes a bulk request
+type BulkResult struct {
+	Resource  models.AuditResource `json:"resource"`
+	Action    string               `json:"action"`
+	Succeeded int                  `json:"succeeded"`
+	Failed    int                  `json:"failed"`
+	Results   []BulkItemResult     `json:"results"`
+}
+
+// bulkResource describes how bulk actions map onto one model
+type bulkResource struct {
+	audit        models.AuditResource
+	model        func() interface{}
+	table        string
+	tagJoinTable string
+	tagJoinKey   string
+	parentColumn string
+	actions      map[string]bool
+}
+
+var (
+	bulkBookmarks = bulkResource{
+		audit:        models.AuditResourceBookmark,
+		model:        func() interface{} { return &models.Bookmark{} },
+		table:        "bookmarks",
+		tagJoinTable: "bookmark_tags",
+		tagJoinKey:   "bookmark_id",
+		actions: map[string]bool{
+			BulkActionAddTags: true, BulkActionRemoveTags: true, BulkActionSetFavorite: true,
+			BulkActionSetRead: true, BulkActionDelete: true, BulkActionRestore: true,
+		},
+	}
+	bulkTasks = bulkResource{
+		audit:        models.AuditResourceTask,
+		model:        func() interface{} { return &models.Task{} },
+		table:        "tasks",
+		tagJoinTable: "task_tags",
+		tagJoinKey:   "task_id",
+		parentColumn: "parent_task_id",
+		actions: map[string]bool{
+			BulkActionAddTags: true, BulkActionRemoveTags: true, BulkActionSetStatus: true,
+			BulkActionSetPriority: true, BulkActionMove: true, BulkActionDelete: true, BulkActionRestore: true,
+		},
+	}
+	bulkNotes = bulkResource{
+		audit:        models.AuditResourceNote,
+		model:        func() interface{} { return &models.Note{} },
+		table:        "notes",
+		tagJoinTable: "note_tags",
+		tagJoinKey:   "note_id",
+		parentColumn: "parent_note_id",
+		actions: map[string]bool{
+			BulkActionAddTags: true, BulkActionRemoveTags: true, BulkActionSetPinned: true,
+			BulkActionMove: true, BulkActionDelete: true, BulkActionRestore: true,
+		},
+	}
+	bulkFiles = bulkResource{
+		audit:        models.AuditResourceFile,
+		model:        func() interface{} { return &models.File{} },
+		table:        "files",
+		tagJoinTable: "file_tags",
+		tagJoinKey:   "file_id",
+		actions: map[string]bool{
+			BulkActionAddTags: true, BulkActionRemoveTags: true, BulkActionDelete: true, BulkActionRestore: true,
+		},
+	}
+)
+
+// BulkService applies one action to many bookmarks, tasks, notes or files
+// in a single transaction
+type BulkService struct {
+	db *gorm.DB
+}
+
+// NewBulkService creates a new bulk service
+func NewBulkService(db *gorm.DB) *BulkService {
+	return &BulkService{db: db}
+}
+
+// Bookmarks applies a bulk action to the user's bookmarks
+func (s *BulkService) Bookmarks(userID uint, req BulkRequest, audit *models.AuditLog) (*BulkResult, error) {
+	return s.apply(bulkBookmarks, userID, req, audit)
+}
+
+// Tasks applies a bulk action to the user's tasks
+func (s *BulkService) Tasks(userID uint, req BulkRequest, audit *models.AuditLog) (*BulkResult, error) {
+	return s.apply(bulkTasks, userID, req, audit)
+}
+
+// Notes applies a bulk action to the user's notes
+func (s *BulkService) Notes(userID uint, req BulkRequest, audit *models.AuditLog) (*BulkResult, error) {
+	return s.apply(bulkNotes, userID, req, audit)
+}
+
+// Files applies a bulk action to the user's files. Deleting only hides the
+// records so they can be restored; stored content is left on disk.
+func (s *BulkService) Files(userID uint, req BulkRequest, audit *models.AuditLog) (*BulkResult, error) {
+	return s.apply(bulkFiles, userID, req, audit)
+}
+
+// apply validates the request, runs the action on the items the user owns
+// and records one audit entry for the batch. Items that are missing or
+// rejected are reported as failed without affecting the others; database
+// errors roll back the whole batch.
+func (s *BulkService) apply(resource bulkResource, userID uint, req BulkRequest, audit *models.AuditLog) (*BulkResult, error) {
+	if err := validateBulkRequest(resource, req); err != nil {
+		return nil, err
+	}
+
+	ids := uniqueIDs(req.IDs)
+	result := &BulkResult{Resource: resource.audit, Action: req.Action}
+	failures := make(map[uint]string)
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Same ownership rule as the single-item handlers: the item must
+		// belong to the user. Restore looks at deleted items only.
+		var owned []uint
+		query := tx.Model(resource.model()).Where("id IN ? AND user_id = ?", ids, userID)
+		if req.Action == BulkActionRestore {
+			query = query.Unscoped().Where("deleted_at IS NOT NULL")
+		}
+		if err := query.Pluck("id", &owned).Error; err != nil {
+			return err
+		}
+		ownedSet := make(map[uint]bool, len(owned))
+		for _, id := range owned {
+			ownedSet[id] = true
+		}
+		for _, id := range ids {
+			if !ownedSet[id] {
+				failures[id] = "not found"
+			}
+		}
+
+		applied, err := s.applyAction(tx, resource, userID, req, owned, failures)
+		if err != nil {
+			return err
+		}
+
+		if audit != nil {
+			fillBulkAuditLog(audit, resource, userID, req, applied, failures)
+			if err := tx.Create(audit).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		item := BulkItemResult{ID: id, Success: true}
+		if reason, failed := failures[id]; failed {
+			item.Success = false
+			item.Error = reason
+			result.Failed++
+		} else {
+			result.Succeeded++
+		}
+		result.Results = append(result.Results, item)
+	}
+	return result, nil
+}
+
+func validateBulkRequest(resource bulkResource, req BulkRequest) error {
+	if !resource.actions[req.Action] {
+		return ErrUnsupportedBulkAction
+	}
+	if len(req.IDs) == 0 {
+		return fmt.Errorf("%w: ids are required", ErrInvalidBulkRequest)
+	}
+	if len(req.IDs) > maxBulkItems {
+		return fmt.Errorf("%w: at most %d items can be changed at once", ErrInvalidBulkRequest, maxBulkItems)
+	}
+
+	switch req.Action {
+	case BulkActionAddTags, BulkActionRemoveTags:
+		for _, name := range req.Tags {
+			if strings.TrimSpace(name) != "" {
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: tags are required", ErrInvalidBulkRequest)
+	case BulkActionSetFavorite, BulkActionSetRead, BulkActionSetPinned:
+		if req.Value == nil {
+			return fmt.Errorf("%w: value is required", ErrInvalidBulkRequest)
+		}
+	case BulkActionSetStatus:
+		switch models.TaskStatus(req.Status) {
+		case models.TaskStatusPending, models.TaskStatusInProgress, models.TaskStatusCompleted, models.TaskStatusCancelled:
+		default:
+			return fmt.Errorf("%w: unknown status %q", ErrInvalidBulkRequest, req.Status)
+		}
+	case BulkActionSetPriority:
+		switch models.TaskPriority(req.Priority) {
+		case models.TaskPriorityLow, models.TaskPriorityMedium, models.TaskPriorityHigh, models.TaskPriorityUrgent:
+		default:
+			return fmt.Errorf("%w: unknown priority %q", ErrInvalidBulkRequest, req.Priority)
+		}
+	}
+	return nil
+}
+
+// applyAction runs the action on the owned items and returns the IDs it
+// changed. Per-item rejections are added to failures.
+func (s *BulkService) applyAction(tx *gorm.DB, resource bulkResource, userID uint, req BulkRequest, owned []uint, failures map[uint]string) ([]uint, error) {
+	if len(owned) == 0 {
+		return nil, nil
+	}
+
+	switch req.Action {
+	case BulkActionAddTags:
+		tagCache := make(map[string]*models.Tag)
+		var tagIDs []uint
+		var rows []map[string]interface{}
+		for _, name := range req.Tags {
+			if strings.TrimSpace(name) == "" {
+				continue
+			}
+			tag, err := findOrCreateImportTag(tx, userID, name, tagCache)
+			if err != nil {
+				return nil, err
+			}
+			tagIDs = append(tagIDs, tag.ID)
+			for _, id := range owned {
+				rows = append(rows, map[string]interface{}{resource.tagJoinKey: id, "tag_id": tag.ID})
+			}
+		}
+		if err := tx.Table(resource.tagJoinTable).Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+			return nil, err
+		}
+		if err := recalculateTagUsageFor(tx, tagIDs); err != nil {
+			return nil, err
+		}
+		return owned, s.touch(tx, resource, owned)
+
+	case BulkActionRemoveTags:
+		var tagIDs []uint
+		for _, name := range req.Tags {
+			tag, err := ResolveTag(tx, userID, name)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, ErrInvalidTagName) {
+					continue
+				}
+				return nil, err
+			}
+			tagIDs = append(tagIDs, tag.ID)
+		}
+		if len(tagIDs) > 0 {
+			if err := tx.Exec("DELETE FROM "+resource.tagJoinTable+" WHERE "+resource.tagJoinKey+" IN ? AND tag_id IN ?", owned, tagIDs).Error; err != nil {
+				return nil, err
+			}
+			if err := recalculateTagUsageFor(tx, tagIDs); err != nil {
+				return nil, err
+			}
+		}
+		return owned, s.touch(tx, resource, owned)
+
+	case BulkActionSetFavorite:
+		return owned, s.update(tx, resource, userID, owned, map[string]interface{}{"is_favorite": *req.Value})
+
+	case BulkActionSetPinned:
+		return owned, s.update(tx, resource, userID, owned, map[string]interface{}{"is_pinned": *req.Value})
+
+	case BulkActionSetRead:
+		updates := map[string]interface{}{"is_read": *req.Value, "read_at": nil}
+		if *req.Value {
+			now := time.Now()
+			updates["read_at"] = now
+			if resource.table == bulkBookmarks.table {
+				if err := recordBookmarksRead(tx, userID, owned, now); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return owned, s.update(tx, resource, userID, owned, updates)
+
+	case BulkActionSetStatus:
+		updates := map[string]interface{}{"status": req.Status, "completed_at": nil}
+		if models.TaskStatus(req.Status) != models.TaskStatusCompleted {
+			return owned, s.update(tx, resource, userID, owned, updates)
+		}
+
+		// Tasks still waiting on open prerequisites stay as they are.
+		// Completed recurring tasks are followed by their next occurrence.
+		completable, err := completableTasks(tx, userID, owned, failures)
+		if err != nil || len(completable) == 0 {
+			return nil, err
+		}
+		now := time.Now()
+		updates["completed_at"] = now
+		updates["progress"] = 100
+		if err := s.update(tx, resource, userID, completable, updates); err != nil {
+			return nil, err
+		}
+		return completable, generateNextOccurrences(tx, userID, completable, now)
+
+	case BulkActionSetPriority:
+		return owned, s.update(tx, resource, userID, owned, map[string]interface{}{"priority": req.Priority})
+
+	case BulkActionMove:
+		return s.move(tx, resource, userID, req.ParentID, owned, failures)
+
+	case BulkActionDelete:
+		return owned, tx.Where("id IN ?", owned).Delete(resource.model()).Error
+
+	case BulkActionRestore:
+		restorable := owned
+		if resource.table == bulkFiles.table {
+			restorable = restorableFiles(tx, owned, failures)
+		}
+		if len(restorable) == 0 {
+			return nil, nil
+		}
+		return restorable, tx.Unscoped().Model(resource.model()).Where("id IN ?", restorable).
+			Updates(map[string]interface{}{"deleted_at": nil, "updated_at": time.Now()}).Error
+	}
+
+	return nil, ErrUnsupportedBulkAction
+}
+
+func (s *BulkService) update(tx *gorm.DB, resource bulkResource, userID uint, ids []uint, updates map[string]interface{}) error {
+	apply := func() error {
+		return tx.Model(resource.model()).Where("id IN ?", ids).Updates(updates).Error
+	}
+	if resource.table == bulkTasks.table {
+		return TrackTaskChanges(tx, userID, ids, apply)
+	}
+	return apply()
+}
+
+// touch bumps updated_at on items whose tags changed
+func (s *BulkService) touch(tx *gorm.DB, resource bulkResource, ids []uint) error {
+	return tx.Model(resource.model()).Where("id IN ?", ids).UpdateColumn("updated_at", time.Now()).Error
+}
+
+// move re-parents items, rejecting parents the user does not own and moves
+// that would make an item its own ancestor
+func (s *BulkService) move(tx *gorm.DB, resource bulkResource, userID uint, parentID *uint, owned []uint, failures map[uint]string) ([]uint, error) {
+	movable := owned
+	if parentID != nil {
+		var parents []struct {
+			ID       uint
+			ParentID *uint
+		}
+		if err := tx.Model(resource.model()).Where("user_id = ?", userID).
+			Select("id, " + resource.parentColumn + " AS parent_id").Scan(&parents).Error; err != nil {
+			return nil, err
+		}
+		parentOf := make(map[uint]*uint, len(parents))
+		for _, p := range parents {
+			parentOf[p.ID] = p.ParentID
+		}
+		if _, ok := parentOf[*parentID]; !ok {
+			for _, id := range owned {
+				failures[id] = "parent not found"
+			}
+			return nil, nil
+		}
+
+		// The new parent's ancestors may not include a moved item
+		ancestors := map[uint]bool{*parentID: true}
+		for current := parentOf[*parentID]; current != nil && !ancestors[*current]; current = parentOf[*current] {
+			ancestors[*current] = true
+		}
+		movable = movable[:0:0]
+		for _, id := range owned {
+			if ancestors[id] {
+				failures[id] = "cannot move an item into itself or one of its descendants"
+				continue
+			}
+			movable = append(movable, id)
+		}
+	}
+
+	if len(movable) == 0 {
+		return nil, nil
+	}
+	return movable, s.update(tx, resource, userID, movable, map[string]interface{}{resource.parentColumn: parentID})
+}
+
+// restorableFiles drops files whose stored content is gone, which happens
+// when they were removed one at a time
+func restorableFiles(tx *gorm.DB, ids []uint, failures map[uint]string) []uint {
+	var files []models.File
+	tx.Unscoped().Select("id", "file_path").Where("id IN ?", ids).Find(&files)
+
+	restorable := make([]uint, 0, len(files))
+	for _, file := range files {
+		if _, err := os.Stat(file.FilePath); err != nil {
+			failures[file.ID] = "file content no longer exists"
+			continue
+		}
+		restorable = append(restorable, file.ID)
+	}
+	return restorable
+}
+
+// fillBulkAuditLog describes the batch on the caller's audit entry
+func fillBulkAuditLog(audit *models.AuditLog, resource bulkResource, userID uint, req BulkRequest, applied []uint, failures map[uint]string) {
+	audit.UserID = userID
+	audit.Resource = resource.audit
+	audit.Action = models.AuditActionUpdate
+	audit.RiskLevel = "low"
+	switch req.Action {
+	case BulkActionDelete:
+		audit.Action = models.AuditActionDelete
+		audit.RiskLevel = "medium"
+	}
+
+	failed := make([]uint, 0, len(failures))
+	for id := range failures {
+		failed = append(failed, id)
+	}
+	sort.Slice(failed, func(i, j int) bool { return failed[i] < failed[j] })
+
+	audit.Description = fmt.Sprintf("Bulk %s on %d %s(s)", req.Action, len(applied), resource.audit)
+	audit.Details = map[string]interface{}{
+		"bulk":       true,
+		"action":     req.Action,
+		"ids":        applied,
+		"failed_ids": failed,
+	}
+	audit.NewValues = bulkAuditValues(req)
+	audit.Success = true
+	if audit.CreatedAt.IsZero() {
+		audit.CreatedAt = time.Now()
+	}
+}
+
+func bulkAuditValues(req BulkRequest) map[string]interface{} {
+	values := map[string]interface{}{}
+	switch req.Action {
+	case BulkActionAddTags, BulkActionRemoveTags:
+		values["tags"] = req.Tags
+	case BulkActionSetFavorite, BulkActionSetRead, BulkActionSetPinned:
+		values["value"] = *req.Value
+	case BulkActionSetStatus:
+		values["status"] = req.Status
+	case BulkActionSetPriority:
+		values["priority"] = req.Priority
+	case BulkActionMove:
+		values["parent_id"] = req.ParentID
+	}
+	return values
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/trackeep/backend/models"
+)
+
+func TestBulkService(t *testing.T) {
+	db := newTestDB(t, &models.TaskPreference{}, &models.AuditLog{}, &models.ContentAnalytics{}, &models.ReadingQueueItem{})
+
+	service := NewBulkService(db)
+
+	mine := []models.Bookmark{{UserID: 1, Title: "A", URL: "https://a.example"}, {UserID: 1, Title: "B", URL: "https://b.example"}}
+	db.Create(&mine)
+	other := models.Bookmark{UserID: 2, Title: "C", URL: "https://c.example"}
+	db.Create(&other)
+
+	ids := []uint{mine[0].ID, mine[1].ID, other.ID, mine[0].ID}
+	result, err := service.Bookmarks(1, BulkRequest{Action: BulkActionAddTags, IDs: ids, Tags: []string{"go", "web"}}, &models.AuditLog{UserEmail: "a@example.com"})
+	if err != nil {
+		t.Fatalf("failed to add tags: %v", err)
+	}
+	if result.Succeeded != 2 || result.Failed != 1 || len(result.Results) != 3 || result.Results[2].Error != "not found" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	var tagged models.Bookmark
+	db.Preload("Tags").First(&tagged, mine[1].ID)
+	if len(tagged.Tags) != 2 {
+		t.Fatalf("expected two tags, got %+v", tagged.Tags)
+	}
+	var untouched models.Bookmark
+	db.Preload("Tags").First(&untouched, other.ID)
+	if len(untouched.Tags) != 0 {
+		t.Fatalf("another user's bookmark was changed")
+	}
+	var goTag models.Tag
+	db.Where("user_id = ? AND name = ?", 1, "go").First(&goTag)
+	if goTag.UsageCount != 2 {
+		t.Fatalf("expected the tag to be counted on both bookmarks, got %d", goTag.UsageCount)
+	}
+
+	// Adding again is a no-op; removing is case-insensitive
+	if _, err := service.Bookmarks(1, BulkRequest{Action: BulkActionAddTags, IDs: ids, Tags: []string{"go"}}, nil); err != nil {
+		t.Fatalf("failed to re-add tags: %v", err)
+	}
+	if _, err := service.Bookmarks(1, BulkRequest{Action: BulkActionRemoveTags, IDs: ids, Tags: []string{"GO"}}, nil); err != nil {
+		t.Fatalf("failed to remove tags: %v", err)
+	}
+	var first models.Bookmark
+	db.Preload("Tags").First(&first, mine[0].ID)
+	if len(first.Tags) != 1 || first.Tags[0].Name != "web" {
+		t.Fatalf("unexpected tags after removal: %+v", first.Tags)
+	}
+	var webTag models.Tag
+	db.First(&goTag, goTag.ID)
+	db.Where("user_id = ? AND name = ?", 1, "web").First(&webTag)
+	if goTag.UsageCount != 0 || webTag.UsageCount != 2 {
+		t.Fatalf("unexpected usage counts after removal: go %d, web %d", goTag.UsageCount, webTag.UsageCount)
+	}
+
+	read := true
+	if _, err := service.Bookmarks(1, BulkRequest{Action: BulkActionSetRead, IDs: ids, Value: &read}, nil); err != nil {
+		t.Fatalf("failed to mark read: %v", err)
+	}
+	var second models.Bookmark
+	db.First(&second, mine[1].ID)
+	if !second.IsRead || second.ReadAt == nil {
+		t.Fatalf("expected bookmark to be read: %+v", second)
+	}
+	var reads int64
+	db.Model(&models.ContentAnalytics{}).Where("content_type = ?", "bookmark").Count(&reads)
+	if reads != 2 {
+		t.Fatalf("expected reads to be recorded in analytics, got %d", reads)
+	}
+
+	// Delete and restore round trip
+	if result, _ := service.Bookmarks(1, BulkRequest{Action: BulkActionDelete, IDs: ids}, nil); result.Succeeded != 2 {
+		t.Fatalf("unexpected delete result: %+v", result)
+	}
+	var remaining int64
+	db.Model(&models.Bookmark{}).Where("user_id = ?", 1).Count(&remaining)
+	if remaining != 0 {
+		t.Fatalf("expected bookmarks to be deleted, %d left", remaining)
+	}
+	if result, _ := service.Bookmarks(1, BulkRequest{Action: BulkActionRestore, IDs: ids}, nil); result.Succeeded != 2 {
+		t.Fatalf("unexpected restore result: %+v", result)
+	}
+	db.Model(&models.Bookmark{}).Where("user_id = ?", 1).Count(&remaining)
+	if remaining != 2 {
+		t.Fatalf("expected bookmarks to be restored, %d found", remaining)
+	}
+
+	if _, err := service.Bookmarks(1, BulkRequest{Action: BulkActionSetStatus, IDs: ids, Status: "completed"}, nil); !errors.Is(err, ErrUnsupportedBulkAction) {
+		t.Fatalf("expected unsupported action, got %v", err)
+	}
+	if _, err := service.Tasks(1, BulkRequest{Action: BulkActionSetStatus, IDs: ids, Status: "done"}, nil); !errors.Is(err, ErrInvalidBulkRequest) {
+		t.Fatalf("expected invalid status, got %v", err)
+	}
+
+	// Tasks: status and moves that would create a cycle
+	parent := models.Task{UserID: 1, Title: "Parent"}
+	db.Create(&parent)
+	child := models.Task{UserID: 1, Title: "Child", ParentTaskID: &parent.ID}
+	db.Create(&child)
+	loose := models.Task{UserID: 1, Title: "Loose"}
+	db.Create(&loose)
+
+	result, err = service.Tasks(1, BulkRequest{Action: BulkActionSetStatus, IDs: []uint{child.ID, loose.ID}, Status: "completed"}, nil)
+	if err != nil || result.Succeeded != 2 {
+		t.Fatalf("unexpected status result %+v (err %v)", result, err)
+	}
+	var completed models.Task
+	db.First(&completed, loose.ID)
+	if completed.Status != models.TaskStatusCompleted || completed.CompletedAt == nil {
+		t.Fatalf("expected task to be completed: %+v", completed)
+	}
+
+	result, err = service.Tasks(1, BulkRequest{Action: BulkActionMove, IDs: []uint{parent.ID, loose.ID}, ParentID: &child.ID}, &models.AuditLog{UserEmail: "a@example.com"})
+	if err != nil {
+		t.Fatalf("failed to move tasks: %v", err)
+	}
+	if result.Results[0].Success || !result.Results[1].Success {
+		t.Fatalf("expected only the cycle to be rejected: %+v", result)
+	}
+	var moved models.Task
+	db.First(&moved, loose.ID)
+	if moved.ParentTaskID == nil || *moved.ParentTaskID != child.ID {
+		t.Fatalf("expected task to be moved: %+v", moved)
+	}
+
+	// One audit entry per audited batch, naming the changed items
+	var logs []models.AuditLog
+	db.Order("id ASC").Find(&logs)
+	if len(logs) != 2 {
+		t.Fatalf("expected two audit entries, got %d", len(logs))
+	}
+	if logs[1].Resource != models.AuditResourceTask || logs[1].Details["action"] != BulkActionMove {
+		t.Fatalf("unexpected audit entry: %+v", logs[1])
+	}
+	changed, _ := logs[1].Details["ids"].([]interface{})
+	failed, _ := logs[1].Details["failed_ids"].([]interface{})
+	if len(changed) != 1 || len(failed) != 1 || uint(changed[0].(float64)) != loose.ID {
+		t.Fatalf("unexpected audit details: %+v", logs[1].Details)
+	}
+}
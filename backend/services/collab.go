@@ -0,0 +1,790 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCollabDocument  = errors.New("unknown document type")
+	ErrCollabForbidden = errors.New("you cannot edit this document")
+	ErrCollabEncrypted = errors.New("encrypted notes cannot be edited together")
+	ErrCollabNotJoined = errors.New("join the document before editing it")
+	ErrCollabStale     = errors.New("the document has moved past this version")
+)
+
+// CollabOptions tunes collaborative editing sessions
+type CollabOptions struct {
+	// SnapshotInterval is how often edited documents are written back
+	SnapshotInterval time.Duration
+	// HistoryLimit is how many operations are kept per document for
+	// clients that reconnect
+	HistoryLimit int
+}
+
+// CollabCursor is a selection in a document in UTF-16 code units. Anchor
+// equals Head for a plain caret.
+type CollabCursor struct {
+	Anchor int `json:"anchor"`
+	Head   int `json:"head"`
+}
+
+// CollabParticipant is one connection in an editing session
+type CollabParticipant struct {
+	UserID   uint          `json:"user_id"`
+	Username string        `json:"username"`
+	ClientID string        `json:"client_id"`
+	CanEdit  bool          `json:"can_edit"`
+	Cursor   *CollabCursor `json:"cursor,omitempty"`
+}
+
+// CollabEdit is an applied operation as sent to clients
+type CollabEdit struct {
+	Version   int            `json:"version"`
+	Operation *TextOperation `json:"operation"`
+	UserID    uint           `json:"user_id"`
+	ClientID  string         `json:"client_id,omitempty"`
+}
+
+// CollabState is sent to a client joining a session. Content is left out
+// when the operations since the client's last version can be replayed.
+type CollabState struct {
+	Version      int                 `json:"version"`
+	Content      *string             `json:"content,omitempty"`
+	Operations   []CollabEdit        `json:"operations,omitempty"`
+	Participants []CollabParticipant `json:"participants"`
+	CanEdit      bool                `json:"can_edit"`
+}
+
+// collabSession is the live state of one document
+type collabSession struct {
+	mu      sync.Mutex
+	docType string
+	docID   uint
+	key     string
+
+	text    []uint16
+	version int
+	// saved is the version last written to the document and versioned the
+	// one last recorded as a wiki version
+	saved     int
+	versioned int
+	// history holds the latest operations, ending at version
+	history      []CollabEdit
+	participants map[*MessagesWSClient]*CollabParticipant
+	lastEditor   uint
+	// closed is set once the session is dropped; holders must look it up again
+	closed bool
+}
+
+// CollabService runs collaborative editing sessions for notes and wiki
+// pages. Clients send ot.js operations against the version they last saw;
+// the server transforms them over what happened since, applies them and
+// passes them on. Every operation is stored, so a client that reconnects
+// gets only what it missed and edits survive a restart until the next
+// snapshot writes the text back to the note or page.
+type CollabService struct {
+	db   *gorm.DB
+	hub  *MessagesHub
+	opts CollabOptions
+
+	mu       sync.Mutex
+	sessions map[string]*collabSession
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+var defaultCollabService *CollabService
+
+// GetCollabService returns the shared collaborative editing service, or nil
+// before one is set
+func GetCollabService() *CollabService {
+	return defaultCollabService
+}
+
+// SetCollabService makes s the shared collaborative editing service
+func SetCollabService(s *CollabService) {
+	defaultCollabService = s
+}
+
+// NewCollabService creates a collaborative editing service sending its
+// events through hub
+func NewCollabService(db *gorm.DB, hub *MessagesHub, opts CollabOptions) *CollabService {
+	if opts.SnapshotInterval <= 0 {
+		opts.SnapshotInterval = 10 * time.Second
+	}
+	if opts.HistoryLimit <= 0 {
+		opts.HistoryLimit = 500
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &CollabService{
+		db:       db,
+		hub:      hub,
+		opts:     opts,
+		sessions: make(map[string]*collabSession),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// CollabDocumentKey names a document's session and websocket room
+func CollabDocumentKey(docType string, docID uint) string {
+	return fmt.Sprintf("%s:%d", docType, docID)
+}
+
+// Start snapshots edited documents in the background until Stop is called
+func (s *CollabService) Start() {
+	go func() {
+		ticker := time.NewTicker(s.opts.SnapshotInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.SnapshotAll()
+			case <-s.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background loop and writes back unsaved edits
+func (s *CollabService) Stop() {
+	s.cancel()
+	s.SnapshotAll()
+}
+
+// SnapshotAll writes edited documents back and closes sessions nobody is
+// in any more
+func (s *CollabService) SnapshotAll() {
+	s.mu.Lock()
+	sessions := make([]*collabSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.mu.Unlock()
+
+	for _, session := range sessions {
+		session.mu.Lock()
+		if !session.closed {
+			idle := len(session.participants) == 0
+			if err := s.snapshot(session, idle); err != nil {
+				log.Printf("Failed to snapshot %s: %v", session.key, err)
+			} else if idle {
+				s.drop(session)
+			}
+		}
+		session.mu.Unlock()
+	}
+}
+
+// Join adds a connection to a document's session and sends it the
+// document's state. A client that was in the session before passes the
+// last version it saw to get only the operations it missed.
+func (s *CollabService) Join(client *MessagesWSClient, docType string, docID uint, clientID string, since *int) error {
+	canEdit, err := s.access(client.UserID, docType, docID)
+	if err != nil {
+		return err
+	}
+	var user models.User
+	if err := s.db.Select("id", "username").First(&user, client.UserID).Error; err != nil {
+		return err
+	}
+
+	session, err := s.acquire(docType, docID, true)
+	if err != nil {
+		return err
+	}
+	defer session.mu.Unlock()
+
+	participant := &CollabParticipant{
+		UserID:   client.UserID,
+		Username: user.Username,
+		ClientID: clientID,
+		CanEdit:  canEdit,
+	}
+	session.participants[client] = participant
+	s.hub.AddClientToDocument(client, session.key)
+
+	state := &CollabState{Version: session.version, CanEdit: canEdit}
+	if since != nil && *since <= session.version && *since >= session.version-len(session.history) {
+		state.Operations = append([]CollabEdit{}, session.history[len(session.history)-(session.version-*since):]...)
+	} else {
+		content := string(utf16.Decode(session.text))
+		state.Content = &content
+	}
+	for _, other := range session.participants {
+		state.Participants = append(state.Participants, *other)
+	}
+
+	s.hub.SendToClient(client, session.key, "collab.state", state)
+	s.hub.BroadcastDocument(session.key, "collab.joined", participant, client)
+	return nil
+}
+
+// Apply takes an operation a client made on the given version of the
+// document. The sender gets a collab.ack with the new version and everyone
+// else the transformed operation.
+func (s *CollabService) Apply(client *MessagesWSClient, docType string, docID uint, version int, op *TextOperation) error {
+	session, participant, err := s.participant(client, docType, docID)
+	if err != nil {
+		return err
+	}
+	defer session.mu.Unlock()
+
+	if !participant.CanEdit {
+		return ErrCollabForbidden
+	}
+	missed := session.version - version
+	if missed < 0 || missed > len(session.history) {
+		return fmt.Errorf("%w: join again to get version %d", ErrCollabStale, session.version)
+	}
+	for _, concurrent := range session.history[len(session.history)-missed:] {
+		if op, _, err = TransformOperations(op, concurrent.Operation); err != nil {
+			return err
+		}
+	}
+	text, err := op.Apply(session.text)
+	if err != nil {
+		return err
+	}
+
+	edit := CollabEdit{
+		Version:   session.version + 1,
+		Operation: op,
+		UserID:    client.UserID,
+		ClientID:  participant.ClientID,
+	}
+	if err := s.commit(session, edit, text); err != nil {
+		return err
+	}
+
+	s.hub.SendToClient(client, session.key, "collab.ack", map[string]int{"version": edit.Version})
+	s.hub.BroadcastDocument(session.key, "collab.operation", edit, client)
+	return nil
+}
+
+// Cursor moves a participant's cursor and shows it to the others
+func (s *CollabService) Cursor(client *MessagesWSClient, docType string, docID uint, cursor CollabCursor) error {
+	session, participant, err := s.participant(client, docType, docID)
+	if err != nil {
+		return err
+	}
+	defer session.mu.Unlock()
+
+	cursor.Anchor = max(0, min(cursor.Anchor, len(session.text)))
+	cursor.Head = max(0, min(cursor.Head, len(session.text)))
+	participant.Cursor = &cursor
+	s.hub.BroadcastDocument(session.key, "collab.cursor", participant, client)
+	return nil
+}
+
+// Leave removes a connection from a document's session. The last one out
+// writes the document back.
+func (s *CollabService) Leave(client *MessagesWSClient, docType string, docID uint) error {
+	session, err := s.acquire(docType, docID, false)
+	if err != nil || session == nil {
+		return err
+	}
+	defer session.mu.Unlock()
+	return s.leave(session, client)
+}
+
+// LeaveAll removes a connection from every session it is in, as when its
+// websocket closes. Sessions it has already left are skipped, so it may be
+// called more than once.
+func (s *CollabService) LeaveAll(client *MessagesWSClient) {
+	s.mu.Lock()
+	sessions := make([]*collabSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, session)
+	}
+	s.mu.Unlock()
+
+	for _, session := range sessions {
+		session.mu.Lock()
+		if _, ok := session.participants[client]; ok && !session.closed {
+			if err := s.leave(session, client); err != nil {
+				log.Printf("Failed to snapshot %s: %v", session.key, err)
+			}
+		}
+		session.mu.Unlock()
+	}
+}
+
+// ExternalEdit brings an open session in line with content saved some other
+// way, such as the REST API, so live editors do not overwrite it
+func (s *CollabService) ExternalEdit(docType string, docID uint, content string, userID uint) error {
+	session, err := s.acquire(docType, docID, false)
+	if err != nil || session == nil {
+		return err
+	}
+	defer session.mu.Unlock()
+
+	text := utf16.Encode([]rune(content))
+	op := textOperationBetween(session.text, text)
+	if op.IsNoop() {
+		return nil
+	}
+	edit := CollabEdit{Version: session.version + 1, Operation: op, UserID: userID}
+	if err := s.commit(session, edit, text); err != nil {
+		return err
+	}
+
+	// The save already wrote the document and its history
+	session.saved = session.version
+	session.versioned = session.version
+	if err := s.db.Model(&models.CollabDocument{}).
+		Where("doc_type = ? AND doc_id = ?", docType, docID).
+		Updates(map[string]interface{}{
+			"snapshot_version": session.version,
+			"snapshot_hash":    contentHash([]byte(content)),
+		}).Error; err != nil {
+		return err
+	}
+
+	s.hub.BroadcastDocument(session.key, "collab.operation", edit, nil)
+	return nil
+}
+
+// acquire returns the document's session locked, loading it if asked.
+// Without load, a document nobody is editing returns nil.
+func (s *CollabService) acquire(docType string, docID uint, load bool) (*collabSession, error) {
+	if docType != models.CollabDocNote && docType != models.CollabDocWiki {
+		return nil, ErrCollabDocument
+	}
+	key := CollabDocumentKey(docType, docID)
+	for {
+		s.mu.Lock()
+		session, ok := s.sessions[key]
+		if !ok {
+			if !load {
+				s.mu.Unlock()
+				return nil, nil
+			}
+			session = &collabSession{
+				docType:      docType,
+				docID:        docID,
+				key:          key,
+				participants: make(map[*MessagesWSClient]*CollabParticipant),
+			}
+			session.mu.Lock()
+			s.sessions[key] = session
+			s.mu.Unlock()
+
+			if err := s.load(session); err != nil {
+				s.drop(session)
+				session.mu.Unlock()
+				return nil, err
+			}
+			return session, nil
+		}
+		s.mu.Unlock()
+
+		session.mu.Lock()
+		if !session.closed {
+			return session, nil
+		}
+		session.mu.Unlock()
+	}
+}
+
+// participant returns the locked session of a document the client is in
+func (s *CollabService) participant(client *MessagesWSClient, docType string, docID uint) (*collabSession, *CollabParticipant, error) {
+	session, err := s.acquire(docType, docID, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session == nil {
+		return nil, nil, ErrCollabNotJoined
+	}
+	participant, ok := session.participants[client]
+	if !ok {
+		session.mu.Unlock()
+		return nil, nil, ErrCollabNotJoined
+	}
+	return session, participant, nil
+}
+
+func (s *CollabService) leave(session *collabSession, client *MessagesWSClient) error {
+	participant, ok := session.participants[client]
+	if !ok {
+		return nil
+	}
+	delete(session.participants, client)
+	s.hub.RemoveClientFromDocument(client, session.key)
+	s.hub.BroadcastDocument(session.key, "collab.left", participant, nil)
+
+	if len(session.participants) > 0 {
+		return nil
+	}
+	if err := s.snapshot(session, true); err != nil {
+		// The operations are stored; the next snapshot picks them up
+		return err
+	}
+	s.drop(session)
+	return nil
+}
+
+// drop forgets a locked session
+func (s *CollabService) drop(session *collabSession) {
+	session.closed = true
+	s.mu.Lock()
+	if s.sessions[session.key] == session {
+		delete(s.sessions, session.key)
+	}
+	s.mu.Unlock()
+}
+
+// access reports whether a user may open a document and whether they may
+// edit it. Notes are open to their owner and the members of teams they are
+// shared with, read-only for viewers. Wiki pages are open to their owner
+// and, while collaborative, their collaborators; public pages can be
+// followed read-only.
+func (s *CollabService) access(userID uint, docType string, docID uint) (bool, error) {
+	switch docType {
+	case models.CollabDocNote:
+		var note models.Note
+		if err := s.db.Select("id", "user_id", "is_encrypted").First(&note, docID).Error; err != nil {
+			return false, err
+		}
+		if note.IsEncrypted {
+			return false, ErrCollabEncrypted
+		}
+		if note.UserID == userID {
+			return true, nil
+		}
+
+		var roles []string
+		if err := s.db.Model(&models.TeamMember{}).
+			Joins("JOIN team_notes ON team_notes.team_id = team_members.team_id AND team_notes.deleted_at IS NULL").
+			Where("team_notes.note_id = ? AND team_members.user_id = ?", docID, userID).
+			Pluck("team_members.role", &roles).Error; err != nil {
+			return false, err
+		}
+		if len(roles) == 0 {
+			return false, gorm.ErrRecordNotFound
+		}
+		for _, role := range roles {
+			if role != "viewer" {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case models.CollabDocWiki:
+		var page models.WikiPage
+		if err := s.db.Select("id", "user_id", "is_public", "is_collaborative").First(&page, docID).Error; err != nil {
+			return false, err
+		}
+		if page.UserID == userID {
+			return true, nil
+		}
+
+		var collaborators int64
+		if err := s.db.Table("wiki_collaborators").
+			Where("wiki_page_id = ? AND user_id = ?", docID, userID).
+			Count(&collaborators).Error; err != nil {
+			return false, err
+		}
+		if collaborators > 0 && page.IsCollaborative {
+			return true, nil
+		}
+		if collaborators > 0 || page.IsPublic {
+			return false, nil
+		}
+		return false, gorm.ErrRecordNotFound
+	}
+	return false, ErrCollabDocument
+}
+
+// content reads the current text of a document
+func (s *CollabService) content(docType string, docID uint) (string, error) {
+	if docType == models.CollabDocNote {
+		var note models.Note
+		if err := s.db.Select("id", "content", "is_encrypted").First(&note, docID).Error; err != nil {
+			return "", err
+		}
+		if note.IsEncrypted {
+			return "", ErrCollabEncrypted
+		}
+		return note.Content, nil
+	}
+	var page models.WikiPage
+	if err := s.db.Select("id", "content").First(&page, docID).Error; err != nil {
+		return "", err
+	}
+	return page.Content, nil
+}
+
+// load reads a document into its session. Operations stored since the
+// last snapshot are replayed on top; if the document was changed outside
+// a session the history is dropped and the version moves on, so clients
+// holding an older version fetch the document again.
+func (s *CollabService) load(session *collabSession) error {
+	content, err := s.content(session.docType, session.docID)
+	if err != nil {
+		return err
+	}
+	var doc models.CollabDocument
+	if err := s.db.Where(models.CollabDocument{DocType: session.docType, DocID: session.docID}).
+		FirstOrCreate(&doc).Error; err != nil {
+		return err
+	}
+
+	session.text = utf16.Encode([]rune(content))
+	hash := contentHash([]byte(content))
+	if doc.SnapshotHash == hash && s.replay(session, &doc) {
+		return nil
+	}
+
+	session.version = doc.Version + 1
+	session.saved = session.version
+	session.versioned = session.version
+	session.history = nil
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("doc_type = ? AND doc_id = ?", session.docType, session.docID).
+			Delete(&models.CollabOperation{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&doc).Updates(map[string]interface{}{
+			"version":          session.version,
+			"snapshot_version": session.version,
+			"snapshot_hash":    hash,
+		}).Error
+	})
+}
+
+// replay loads the stored operations of a document whose text matches its
+// last snapshot, applying those that came after it. It reports false when
+// the stored history does not add up.
+func (s *CollabService) replay(session *collabSession, doc *models.CollabDocument) bool {
+	var stored []models.CollabOperation
+	if err := s.db.Where("doc_type = ? AND doc_id = ? AND version > ?", doc.DocType, doc.DocID, doc.Version-s.opts.HistoryLimit).
+		Order("version").Find(&stored).Error; err != nil {
+		return false
+	}
+	if len(stored) > 0 && (stored[len(stored)-1].Version != doc.Version || stored[0].Version > doc.SnapshotVersion+1) {
+		return false
+	}
+	if len(stored) == 0 && doc.Version != doc.SnapshotVersion {
+		return false
+	}
+
+	text := session.text
+	history := make([]CollabEdit, 0, len(stored))
+	for i, operation := range stored {
+		if operation.Version != stored[0].Version+i {
+			return false
+		}
+		op := &TextOperation{}
+		if err := json.Unmarshal([]byte(operation.Operation), op); err != nil {
+			return false
+		}
+		if operation.Version > doc.SnapshotVersion {
+			var err error
+			if text, err = op.Apply(text); err != nil {
+				return false
+			}
+			session.lastEditor = operation.UserID
+		}
+		history = append(history, CollabEdit{
+			Version:   operation.Version,
+			Operation: op,
+			UserID:    operation.UserID,
+			ClientID:  operation.ClientID,
+		})
+	}
+
+	session.text = text
+	session.version = doc.Version
+	session.saved = doc.SnapshotVersion
+	session.versioned = doc.SnapshotVersion
+	session.history = history
+	return true
+}
+
+// commit stores an operation and applies it to the session
+func (s *CollabService) commit(session *collabSession, edit CollabEdit, text []uint16) error {
+	data, err := json.Marshal(edit.Operation)
+	if err != nil {
+		return err
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&models.CollabOperation{
+			DocType:   session.docType,
+			DocID:     session.docID,
+			Version:   edit.Version,
+			UserID:    edit.UserID,
+			ClientID:  edit.ClientID,
+			Operation: string(data),
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.CollabDocument{}).
+			Where("doc_type = ? AND doc_id = ?", session.docType, session.docID).
+			Update("version", edit.Version).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	session.text = text
+	session.version = edit.Version
+	session.lastEditor = edit.UserID
+	session.history = append(session.history, edit)
+	if extra := len(session.history) - s.opts.HistoryLimit; extra > 0 {
+		session.history = append([]CollabEdit(nil), session.history[extra:]...)
+	}
+	for _, participant := range session.participants {
+		if participant.Cursor != nil {
+			participant.Cursor.Anchor = edit.Operation.TransformIndex(participant.Cursor.Anchor)
+			participant.Cursor.Head = edit.Operation.TransformIndex(participant.Cursor.Head)
+		}
+	}
+	return nil
+}
+
+// snapshot writes a session's text back to its document. Notes get a
+// revision and their links reindexed; a wiki page gets a new version when
+// its session closes.
+func (s *CollabService) snapshot(session *collabSession, closing bool) error {
+	writeBack := session.version > session.saved
+	newVersion := closing && session.docType == models.CollabDocWiki && session.version > session.versioned
+	if !writeBack && !newVersion {
+		return nil
+	}
+
+	content := string(utf16.Decode(session.text))
+	now := time.Now()
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if session.docType == models.CollabDocNote {
+			err = s.snapshotNote(tx, session, content, now)
+		} else {
+			err = s.snapshotWikiPage(tx, session, content, writeBack, newVersion)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.CollabDocument{}).
+			Where("doc_type = ? AND doc_id = ?", session.docType, session.docID).
+			Updates(map[string]interface{}{
+				"snapshot_version": session.version,
+				"snapshot_hash":    contentHash([]byte(content)),
+				"snapshot_at":      now,
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Where("doc_type = ? AND doc_id = ? AND version <= ?", session.docType, session.docID, session.version-s.opts.HistoryLimit).
+			Delete(&models.CollabOperation{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	session.saved = session.version
+	if closing {
+		session.versioned = session.version
+	}
+	if writeBack {
+		s.hub.BroadcastDocument(session.key, "collab.saved", map[string]int{"version": session.version}, nil)
+	}
+	return nil
+}
+
+func (s *CollabService) snapshotNote(tx *gorm.DB, session *collabSession, content string, now time.Time) error {
+	var note models.Note
+	if err := tx.First(&note, session.docID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Deleted while open; nothing to write back
+			return nil
+		}
+		return err
+	}
+	if note.IsEncrypted || note.Content == content {
+		return nil
+	}
+
+	revisions := NewNoteRevisionService(tx)
+	if err := revisions.Baseline(&note); err != nil {
+		return err
+	}
+	if err := tx.Model(&note).Update("content", content).Error; err != nil {
+		return err
+	}
+	author := session.lastEditor
+	if author == 0 {
+		author = note.UserID
+	}
+	if _, err := revisions.Record(&note, author, now); err != nil {
+		return err
+	}
+	return NewNoteLinkService(tx).Reindex(&note)
+}
+
+func (s *CollabService) snapshotWikiPage(tx *gorm.DB, session *collabSession, content string, writeBack, newVersion bool) error {
+	var page models.WikiPage
+	if err := tx.First(&page, session.docID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	author := session.lastEditor
+	if author == 0 {
+		author = page.UserID
+	}
+
+	if writeBack && page.Content != content {
+		// Reading time as the wiki handlers estimate it
+		words := len(strings.Fields(content))
+		if err := tx.Model(&page).Updates(map[string]interface{}{
+			"content":        content,
+			"word_count":     words,
+			"reading_time":   max(1, words/225),
+			"last_edited_by": author,
+			"edit_count":     gorm.Expr("edit_count + 1"),
+		}).Error; err != nil {
+			return err
+		}
+		page.Content = content
+		page.WordCount = words
+	}
+	if !newVersion {
+		return nil
+	}
+
+	var last models.WikiVersion
+	if err := tx.Where("wiki_page_id = ?", page.ID).Order("version_number DESC").
+		Limit(1).Find(&last).Error; err != nil {
+		return err
+	}
+	if last.ID != 0 && last.Content == page.Content {
+		return nil
+	}
+	return tx.Create(&models.WikiVersion{
+		WikiPageID:    page.ID,
+		VersionNumber: last.VersionNumber + 1,
+		Title:         page.Title,
+		Content:       page.Content,
+		Summary:       page.Summary,
+		ChangeLog:     "Collaborative editing session",
+		AuthorID:      author,
+		WordCount:     page.WordCount,
+	}).Error
+}
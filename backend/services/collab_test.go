@@ -0,0 +1,236 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+func TestTextOperation(t *testing.T) {
+	parse := func(raw string) *TextOperation {
+		t.Helper()
+		op := &TextOperation{}
+		if err := json.Unmarshal([]byte(raw), op); err != nil {
+			t.Fatalf("failed to parse %s: %v", raw, err)
+		}
+		return op
+	}
+	apply := func(op *TextOperation, text string) string {
+		t.Helper()
+		out, err := op.Apply(utf16.Encode([]rune(text)))
+		if err != nil {
+			t.Fatalf("failed to apply operation: %v", err)
+		}
+		return string(utf16.Decode(out))
+	}
+
+	doc := "Hello world"
+	cases := [][2]string{
+		{`[5, " there", 6]`, `[6, -5, "everyone"]`},
+		{`[-5, 6]`, `[2, -6, 3]`},
+		{`[5, "!", 6]`, `[5, "?", 6]`},
+		{`[11, "."]`, `[-11, "Bye"]`},
+	}
+	for _, c := range cases {
+		a, b := parse(c[0]), parse(c[1])
+		aPrime, bPrime, err := TransformOperations(a, b)
+		if err != nil {
+			t.Fatalf("failed to transform %s and %s: %v", c[0], c[1], err)
+		}
+		left, right := apply(bPrime, apply(a, doc)), apply(aPrime, apply(b, doc))
+		if left != right {
+			t.Fatalf("transforming %s and %s diverged: %q vs %q", c[0], c[1], left, right)
+		}
+	}
+	if _, _, err := TransformOperations(parse(`[3]`), parse(`[4]`)); !errors.Is(err, ErrInvalidOperation) {
+		t.Fatalf("expected operations on different texts to be refused, got %v", err)
+	}
+
+	// Lengths are in UTF-16 code units, as in the browser
+	if got := apply(parse(`[2, "x", -1]`), "😀a"); got != "😀x" {
+		t.Fatalf("unexpected result with a surrogate pair: %q", got)
+	}
+	if data, _ := json.Marshal(parse(`[2, -1, "a", "b", 3]`)); string(data) != `[2,"ab",-1,3]` {
+		t.Fatalf("expected a normalized operation, got %s", data)
+	}
+	if err := json.Unmarshal([]byte(`[1, 0]`), &TextOperation{}); !errors.Is(err, ErrInvalidOperation) {
+		t.Fatalf("expected a zero component to be refused, got %v", err)
+	}
+
+	op := parse(`[2, "abc", -3, 6]`)
+	for index, want := range map[int]int{0: 0, 2: 5, 4: 5, 8: 8} {
+		if got := op.TransformIndex(index); got != want {
+			t.Fatalf("expected index %d to move to %d, got %d", index, want, got)
+		}
+	}
+}
+
+func TestCollabService(t *testing.T) {
+	db := newTestDB(t, &models.NoteRevision{}, &models.NoteLink{}, &models.WikiPage{}, &models.WikiVersion{}, &models.Team{},
+		&models.TeamMember{}, &models.TeamNote{}, &models.CollabDocument{}, &models.CollabOperation{})
+
+	owner := models.User{Email: "owner@example.com", Username: "owner", Password: "x", GitHubID: 1}
+	member := models.User{Email: "member@example.com", Username: "member", Password: "x", GitHubID: 2}
+	viewer := models.User{Email: "viewer@example.com", Username: "viewer", Password: "x", GitHubID: 3}
+	stranger := models.User{Email: "stranger@example.com", Username: "stranger", Password: "x", GitHubID: 4}
+	for _, user := range []*models.User{&owner, &member, &viewer, &stranger} {
+		db.Create(user)
+	}
+	team := models.Team{Name: "Team", OwnerID: owner.ID}
+	db.Create(&team)
+	db.Create(&models.TeamMember{TeamID: team.ID, UserID: member.ID, Role: "member"})
+	db.Create(&models.TeamMember{TeamID: team.ID, UserID: viewer.ID, Role: "viewer"})
+	note := models.Note{UserID: owner.ID, Title: "Plan", Content: "Hello world"}
+	db.Create(&note)
+	db.Create(&models.TeamNote{TeamID: team.ID, UserID: owner.ID, NoteID: note.ID})
+
+	hub := NewMessagesHub()
+	service := NewCollabService(db, hub, CollabOptions{HistoryLimit: 100})
+	events := func(client *MessagesWSClient) []WsEvent {
+		var out []WsEvent
+		for {
+			select {
+			case raw := <-client.Send:
+				var event WsEvent
+				json.Unmarshal(raw, &event)
+				out = append(out, event)
+			default:
+				return out
+			}
+		}
+	}
+	operation := func(raw string) *TextOperation {
+		op := &TextOperation{}
+		json.Unmarshal([]byte(raw), op)
+		return op
+	}
+
+	alice, bob, carol := NewWSClient(owner.ID, nil), NewWSClient(member.ID, nil), NewWSClient(viewer.ID, nil)
+	if err := service.Join(NewWSClient(stranger.ID, nil), models.CollabDocNote, note.ID, "s", nil); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected a stranger to be refused, got %v", err)
+	}
+	for _, client := range []*MessagesWSClient{alice, bob, carol} {
+		if err := service.Join(client, models.CollabDocNote, note.ID, "", nil); err != nil {
+			t.Fatalf("failed to join: %v", err)
+		}
+	}
+	state := events(alice)[0].Data.(map[string]interface{})
+	if state["content"] != "Hello world" || state["version"] != float64(1) {
+		t.Fatalf("unexpected state: %+v", state)
+	}
+	events(bob)
+	events(carol)
+
+	// Both edit version 1 at once; the second is transformed over the first
+	if err := service.Apply(alice, models.CollabDocNote, note.ID, 1, operation(`[5, ",", 6]`)); err != nil {
+		t.Fatalf("failed to apply: %v", err)
+	}
+	if err := service.Apply(bob, models.CollabDocNote, note.ID, 1, operation(`[11, "!"]`)); err != nil {
+		t.Fatalf("failed to apply concurrent edit: %v", err)
+	}
+	if err := service.Apply(carol, models.CollabDocNote, note.ID, 3, operation(`[13, "?"]`)); !errors.Is(err, ErrCollabForbidden) {
+		t.Fatalf("expected a viewer to be read-only, got %v", err)
+	}
+	if err := service.Apply(bob, models.CollabDocNote, note.ID, 0, operation(`[11, "?"]`)); !errors.Is(err, ErrCollabStale) {
+		t.Fatalf("expected an unknown version to be stale, got %v", err)
+	}
+	received := events(carol)
+	if len(received) != 2 || received[1].Type != "collab.operation" {
+		t.Fatalf("unexpected events: %+v", received)
+	}
+	if got := received[1].Data.(map[string]interface{})["operation"]; len(got.([]interface{})) != 2 {
+		t.Fatalf("expected the transformed operation, got %v", got)
+	}
+	if acks := events(alice); acks[0].Type != "collab.ack" {
+		t.Fatalf("expected an ack, got %+v", acks)
+	}
+
+	// Cursors follow edits
+	service.Cursor(bob, models.CollabDocNote, note.ID, CollabCursor{Anchor: 13, Head: 13})
+	service.Apply(alice, models.CollabDocNote, note.ID, 3, operation(`["Oh, ", 13]`))
+	if cursor := service.sessions[CollabDocumentKey(models.CollabDocNote, note.ID)].participants[bob].Cursor; cursor.Head != 17 {
+		t.Fatalf("expected the cursor to move, got %+v", cursor)
+	}
+
+	// A client that reconnects gets the operations it missed
+	service.Leave(carol, models.CollabDocNote, note.ID)
+	since := 2
+	service.Join(carol, models.CollabDocNote, note.ID, "", &since)
+	rejoined := events(carol)
+	state = rejoined[len(rejoined)-1].Data.(map[string]interface{})
+	if state["content"] != nil || len(state["operations"].([]interface{})) != 2 {
+		t.Fatalf("expected a replay, got %+v", state)
+	}
+
+	// Edits are written to the note with a revision
+	service.SnapshotAll()
+	db.First(&note, note.ID)
+	if note.Content != "Oh, Hello, world!" {
+		t.Fatalf("unexpected snapshot %q", note.Content)
+	}
+	var revisions int64
+	db.Model(&models.NoteRevision{}).Where("note_id = ?", note.ID).Count(&revisions)
+	if revisions != 2 {
+		t.Fatalf("expected the original and the edited revision, got %d", revisions)
+	}
+
+	// Unsaved edits survive a restart; a save elsewhere resets the history
+	service.Apply(alice, models.CollabDocNote, note.ID, 4, operation(`[17, " Bye"]`))
+	restarted := NewCollabService(db, hub, CollabOptions{HistoryLimit: 100})
+	dave := NewWSClient(owner.ID, nil)
+	if err := restarted.Join(dave, models.CollabDocNote, note.ID, "", &since); err != nil {
+		t.Fatalf("failed to join after restart: %v", err)
+	}
+	if state := events(dave)[0].Data.(map[string]interface{}); len(state["operations"].([]interface{})) != 3 {
+		t.Fatalf("expected the stored operations to replay, got %+v", state)
+	}
+	if content := string(utf16.Decode(restarted.sessions[CollabDocumentKey(models.CollabDocNote, note.ID)].text)); content != "Oh, Hello, world! Bye" {
+		t.Fatalf("unexpected recovered text %q", content)
+	}
+	if err := restarted.ExternalEdit(models.CollabDocNote, note.ID, "Rewritten", owner.ID); err != nil {
+		t.Fatalf("failed to pass on an external edit: %v", err)
+	}
+	if edits := events(dave); edits[0].Type != "collab.operation" {
+		t.Fatalf("expected the external edit to reach the editor, got %+v", edits)
+	}
+	restarted.Leave(dave, models.CollabDocNote, note.ID)
+	db.Model(&note).Update("content", "Changed offline")
+	erin := NewWSClient(owner.ID, nil)
+	restarted.Join(erin, models.CollabDocNote, note.ID, "", &since)
+	if state := events(erin)[0].Data.(map[string]interface{}); state["content"] != "Changed offline" {
+		t.Fatalf("expected the changed note to be sent in full, got %+v", state)
+	}
+
+	// A connection the hub has dropped stays in the session until its
+	// websocket closes, and later edits must not be sent to it
+	hub.RemoveClient(erin)
+	if err := restarted.ExternalEdit(models.CollabDocNote, note.ID, "Rewritten again", owner.ID); err != nil {
+		t.Fatalf("failed to pass on an external edit: %v", err)
+	}
+	restarted.LeaveAll(erin)
+	restarted.LeaveAll(erin)
+	hub.RemoveClient(erin)
+
+	// Wiki pages are open to collaborators while collaborative
+	page := models.WikiPage{UserID: owner.ID, Title: "Guide", Slug: "guide", Content: "Start", Collaborators: []models.User{member}}
+	db.Create(&page)
+	if editable, err := service.access(member.ID, models.CollabDocWiki, page.ID); err != nil || editable {
+		t.Fatalf("expected read-only access before collaboration, got %v (err %v)", editable, err)
+	}
+	db.Model(&page).Update("is_collaborative", true)
+	if err := service.Join(bob, models.CollabDocWiki, page.ID, "", nil); err != nil {
+		t.Fatalf("failed to join the wiki page: %v", err)
+	}
+	service.Apply(bob, models.CollabDocWiki, page.ID, 1, operation(`[5, " here"]`))
+	service.LeaveAll(bob)
+	db.First(&page, page.ID)
+	var versions []models.WikiVersion
+	db.Where("wiki_page_id = ?", page.ID).Find(&versions)
+	if page.Content != "Start here" || page.EditCount != 1 || len(versions) != 1 || versions[0].AuthorID != member.ID {
+		t.Fatalf("expected the session to save a wiki version: %+v / %+v", page, versions)
+	}
+}
@@ -0,0 +1,532 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+// ErrCollectionCycle is returned when a collection would become its own ancestor
+var ErrCollectionCycle = errors.New("a collection cannot be moved into itself or one of its sub-collections")
+
+// SharedCollection is the public view of a published collection
+type SharedCollection struct {
+	ID          uint               `json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	CoverImage  string             `json:"cover_image"`
+	Color       string             `json:"color"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+	Bookmarks   []models.Bookmark  `json:"bookmarks"`
+	Children    []SharedCollection `json:"children"`
+}
+
+// CollectionService manages nested bookmark collections
+type CollectionService struct {
+	db *gorm.DB
+}
+
+// NewCollectionService creates a new collection service
+func NewCollectionService(db *gorm.DB) *CollectionService {
+	return &CollectionService{db: db}
+}
+
+// Tree returns the user's collections nested by parent, with bookmark counts
+func (s *CollectionService) Tree(userID uint) ([]models.Collection, error) {
+	var collections []models.Collection
+	if err := s.db.Where("user_id = ?", userID).Order("position ASC, name ASC").Find(&collections).Error; err != nil {
+		return nil, err
+	}
+
+	counts, err := s.bookmarkCounts(collectionIDs(collections))
+	if err != nil {
+		return nil, err
+	}
+	for i := range collections {
+		collections[i].BookmarkCount = counts[collections[i].ID]
+	}
+
+	return buildCollectionTree(collections), nil
+}
+
+// Get returns one of the user's collections with its direct children
+func (s *CollectionService) Get(userID, id uint) (*models.Collection, error) {
+	var collection models.Collection
+	if err := s.db.Where("id = ? AND user_id = ?", id, userID).First(&collection).Error; err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Where("user_id = ? AND parent_id = ?", userID, id).Order("position ASC, name ASC").
+		Find(&collection.Children).Error; err != nil {
+		return nil, err
+	}
+
+	ids := append(collectionIDs(collection.Children), collection.ID)
+	counts, err := s.bookmarkCounts(ids)
+	if err != nil {
+		return nil, err
+	}
+	collection.BookmarkCount = counts[collection.ID]
+	for i := range collection.Children {
+		collection.Children[i].BookmarkCount = counts[collection.Children[i].ID]
+	}
+	return &collection, nil
+}
+
+// Create adds a collection at the end of its parent's children
+func (s *CollectionService) Create(collection *models.Collection) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if collection.ParentID != nil {
+			if err := s.checkOwned(tx, collection.UserID, *collection.ParentID); err != nil {
+				return err
+			}
+		}
+
+		position, err := nextCollectionPosition(tx, collection.UserID, collection.ParentID)
+		if err != nil {
+			return err
+		}
+		collection.Position = position
+		return tx.Create(collection).Error
+	})
+}
+
+// Move places a collection under parentID (nil for the top level) at the
+// given position among its new siblings
+func (s *CollectionService) Move(userID, id uint, parentID *uint, position int) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var collection models.Collection
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&collection).Error; err != nil {
+			return err
+		}
+
+		if parentID != nil {
+			if err := s.checkOwned(tx, userID, *parentID); err != nil {
+				return err
+			}
+			descendants, err := s.descendantIDs(tx, userID, id)
+			if err != nil {
+				return err
+			}
+			if *parentID == id || descendants[*parentID] {
+				return ErrCollectionCycle
+			}
+		}
+
+		var siblings []models.Collection
+		query := tx.Where("user_id = ? AND id <> ?", userID, id)
+		if parentID == nil {
+			query = query.Where("parent_id IS NULL")
+		} else {
+			query = query.Where("parent_id = ?", *parentID)
+		}
+		if err := query.Order("position ASC, name ASC").Find(&siblings).Error; err != nil {
+			return err
+		}
+
+		if position < 0 || position > len(siblings) {
+			position = len(siblings)
+		}
+
+		ordered := make([]uint, 0, len(siblings)+1)
+		for _, sibling := range siblings[:position] {
+			ordered = append(ordered, sibling.ID)
+		}
+		ordered = append(ordered, id)
+		for _, sibling := range siblings[position:] {
+			ordered = append(ordered, sibling.ID)
+		}
+
+		if err := tx.Model(&models.Collection{}).Where("id = ?", id).Update("parent_id", parentID).Error; err != nil {
+			return err
+		}
+		for i, collectionID := range ordered {
+			if err := tx.Model(&models.Collection{}).Where("id = ?", collectionID).UpdateColumn("position", i).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Delete removes a collection. Sub-collections are moved up to the deleted
+// collection's parent unless recursive is set, in which case the whole
+// subtree is removed. Bookmarks are never deleted, only their placement.
+func (s *CollectionService) Delete(userID, id uint, recursive bool) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var collection models.Collection
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&collection).Error; err != nil {
+			return err
+		}
+
+		ids := []uint{id}
+		if recursive {
+			descendants, err := s.descendantIDs(tx, userID, id)
+			if err != nil {
+				return err
+			}
+			for descendantID := range descendants {
+				ids = append(ids, descendantID)
+			}
+		} else {
+			var children []models.Collection
+			if err := tx.Where("user_id = ? AND parent_id = ?", userID, id).Order("position ASC, name ASC").Find(&children).Error; err != nil {
+				return err
+			}
+			position, err := nextCollectionPosition(tx, userID, collection.ParentID)
+			if err != nil {
+				return err
+			}
+			for _, child := range children {
+				if err := tx.Model(&models.Collection{}).Where("id = ?", child.ID).Updates(map[string]interface{}{
+					"parent_id": collection.ParentID,
+					"position":  position,
+				}).Error; err != nil {
+					return err
+				}
+				position++
+			}
+		}
+
+		if err := tx.Where("collection_id IN ?", ids).Delete(&models.CollectionBookmark{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.ContentShare{}).
+			Where("owner_id = ? AND content_type = ? AND content_id IN ?", userID, "collection", ids).
+			Update("is_active", false).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&models.Collection{}).Error
+	})
+}
+
+// Bookmarks returns the collection's bookmarks in their manual order
+func (s *CollectionService) Bookmarks(collectionID uint) ([]models.CollectionBookmark, error) {
+	var entries []models.CollectionBookmark
+	err := s.db.Joins("JOIN bookmarks ON bookmarks.id = collection_bookmarks.bookmark_id AND bookmarks.deleted_at IS NULL").
+		Preload("Bookmark.Tags").
+		Where("collection_bookmarks.collection_id = ?", collectionID).
+		Order("collection_bookmarks.position ASC").Find(&entries).Error
+	return entries, err
+}
+
+// AddBookmarks appends the user's bookmarks to a collection, skipping ones
+// already in it. It returns the number of bookmarks added.
+func (s *CollectionService) AddBookmarks(userID, collectionID uint, bookmarkIDs []uint) (int, error) {
+	added := 0
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.checkOwned(tx, userID, collectionID); err != nil {
+			return err
+		}
+
+		var owned []uint
+		if err := tx.Model(&models.Bookmark{}).Where("id IN ? AND user_id = ?", bookmarkIDs, userID).Pluck("id", &owned).Error; err != nil {
+			return err
+		}
+		if len(owned) != len(uniqueIDs(bookmarkIDs)) {
+			return gorm.ErrRecordNotFound
+		}
+
+		var existing []uint
+		if err := tx.Model(&models.CollectionBookmark{}).Where("collection_id = ?", collectionID).Pluck("bookmark_id", &existing).Error; err != nil {
+			return err
+		}
+		present := make(map[uint]bool, len(existing))
+		for _, id := range existing {
+			present[id] = true
+		}
+
+		var position int
+		if err := tx.Model(&models.CollectionBookmark{}).Where("collection_id = ?", collectionID).
+			Select("COALESCE(MAX(position), -1) + 1").Scan(&position).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		for _, bookmarkID := range bookmarkIDs {
+			if present[bookmarkID] {
+				continue
+			}
+			present[bookmarkID] = true
+			if err := tx.Create(&models.CollectionBookmark{
+				CollectionID: collectionID,
+				BookmarkID:   bookmarkID,
+				Position:     position,
+				AddedAt:      now,
+			}).Error; err != nil {
+				return err
+			}
+			position++
+			added++
+		}
+		return nil
+	})
+	return added, err
+}
+
+// RemoveBookmark takes a bookmark out of a collection
+func (s *CollectionService) RemoveBookmark(userID, collectionID, bookmarkID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.checkOwned(tx, userID, collectionID); err != nil {
+			return err
+		}
+		result := tx.Where("collection_id = ? AND bookmark_id = ?", collectionID, bookmarkID).Delete(&models.CollectionBookmark{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// ReorderBookmarks puts the listed bookmarks first, in the given order;
+// bookmarks not listed keep their relative order after them
+func (s *CollectionService) ReorderBookmarks(userID, collectionID uint, bookmarkIDs []uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.checkOwned(tx, userID, collectionID); err != nil {
+			return err
+		}
+
+		var current []uint
+		if err := tx.Model(&models.CollectionBookmark{}).Where("collection_id = ?", collectionID).
+			Order("position ASC").Pluck("bookmark_id", &current).Error; err != nil {
+			return err
+		}
+		members := make(map[uint]bool, len(current))
+		for _, id := range current {
+			members[id] = true
+		}
+
+		ordered := make([]uint, 0, len(current))
+		placed := make(map[uint]bool, len(current))
+		for _, id := range bookmarkIDs {
+			if !members[id] {
+				return gorm.ErrRecordNotFound
+			}
+			if !placed[id] {
+				placed[id] = true
+				ordered = append(ordered, id)
+			}
+		}
+		for _, id := range current {
+			if !placed[id] {
+				ordered = append(ordered, id)
+			}
+		}
+
+		for position, id := range ordered {
+			if err := tx.Model(&models.CollectionBookmark{}).Where("collection_id = ? AND bookmark_id = ?", collectionID, id).
+				UpdateColumn("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CollectionsForBookmark lists the user's collections containing a bookmark
+func (s *CollectionService) CollectionsForBookmark(userID, bookmarkID uint) ([]models.Collection, error) {
+	var collections []models.Collection
+	err := s.db.Joins("JOIN collection_bookmarks ON collection_bookmarks.collection_id = collections.id").
+		Where("collections.user_id = ? AND collection_bookmarks.bookmark_id = ?", userID, bookmarkID).
+		Order("collections.name ASC").Find(&collections).Error
+	return collections, err
+}
+
+// SharedView builds the public view of a collection and its sub-collections
+func (s *CollectionService) SharedView(ownerID, id uint) (*SharedCollection, error) {
+	var collections []models.Collection
+	if err := s.db.Where("user_id = ?", ownerID).Order("position ASC, name ASC").Find(&collections).Error; err != nil {
+		return nil, err
+	}
+
+	byParent := make(map[uint][]models.Collection)
+	var root *models.Collection
+	for i := range collections {
+		if collections[i].ID == id {
+			root = &collections[i]
+		}
+		if collections[i].ParentID != nil {
+			byParent[*collections[i].ParentID] = append(byParent[*collections[i].ParentID], collections[i])
+		}
+	}
+	if root == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var build func(collection models.Collection, depth int) (SharedCollection, error)
+	build = func(collection models.Collection, depth int) (SharedCollection, error) {
+		view := SharedCollection{
+			ID:          collection.ID,
+			Name:        collection.Name,
+			Description: collection.Description,
+			CoverImage:  collection.CoverImage,
+			Color:       collection.Color,
+			UpdatedAt:   collection.UpdatedAt,
+			Bookmarks:   []models.Bookmark{},
+			Children:    []SharedCollection{},
+		}
+
+		entries, err := s.Bookmarks(collection.ID)
+		if err != nil {
+			return view, err
+		}
+		for _, entry := range entries {
+			view.Bookmarks = append(view.Bookmarks, entry.Bookmark)
+		}
+
+		// Guard against corrupted parent links forming a loop
+		if depth >= len(collections) {
+			return view, nil
+		}
+		for _, child := range byParent[collection.ID] {
+			childView, err := build(child, depth+1)
+			if err != nil {
+				return view, err
+			}
+			view.Children = append(view.Children, childView)
+		}
+		return view, nil
+	}
+
+	view, err := build(*root, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &view, nil
+}
+
+// descendantIDs returns the IDs of every collection below id
+func (s *CollectionService) descendantIDs(tx *gorm.DB, userID, id uint) (map[uint]bool, error) {
+	var collections []models.Collection
+	if err := tx.Select("id", "parent_id").Where("user_id = ? AND parent_id IS NOT NULL", userID).Find(&collections).Error; err != nil {
+		return nil, err
+	}
+
+	children := make(map[uint][]uint)
+	for _, collection := range collections {
+		children[*collection.ParentID] = append(children[*collection.ParentID], collection.ID)
+	}
+
+	descendants := make(map[uint]bool)
+	queue := []uint{id}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, child := range children[current] {
+			if !descendants[child] {
+				descendants[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	return descendants, nil
+}
+
+func (s *CollectionService) checkOwned(tx *gorm.DB, userID, collectionID uint) error {
+	var count int64
+	if err := tx.Model(&models.Collection{}).Where("id = ? AND user_id = ?", collectionID, userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// bookmarkCounts counts the live bookmarks in each collection
+func (s *CollectionService) bookmarkCounts(ids []uint) (map[uint]int64, error) {
+	counts := make(map[uint]int64, len(ids))
+	if len(ids) == 0 {
+		return counts, nil
+	}
+
+	var rows []struct {
+		CollectionID uint
+		Count        int64
+	}
+	if err := s.db.Model(&models.CollectionBookmark{}).
+		Select("collection_bookmarks.collection_id, COUNT(*) AS count").
+		Joins("JOIN bookmarks ON bookmarks.id = collection_bookmarks.bookmark_id AND bookmarks.deleted_at IS NULL").
+		Where("collection_bookmarks.collection_id IN ?", ids).
+		Group("collection_bookmarks.collection_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		counts[row.CollectionID] = row.Count
+	}
+	return counts, nil
+}
+
+func nextCollectionPosition(tx *gorm.DB, userID uint, parentID *uint) (int, error) {
+	query := tx.Model(&models.Collection{}).Where("user_id = ?", userID)
+	if parentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+	var position int
+	err := query.Select("COALESCE(MAX(position), -1) + 1").Scan(&position).Error
+	return position, err
+}
+
+// buildCollectionTree nests collections under their parents. Collections whose
+// parent is missing are treated as top-level.
+func buildCollectionTree(collections []models.Collection) []models.Collection {
+	known := make(map[uint]bool, len(collections))
+	for _, collection := range collections {
+		known[collection.ID] = true
+	}
+
+	byParent := make(map[uint][]models.Collection)
+	var roots []models.Collection
+	for _, collection := range collections {
+		if collection.ParentID == nil || !known[*collection.ParentID] {
+			roots = append(roots, collection)
+		} else {
+			byParent[*collection.ParentID] = append(byParent[*collection.ParentID], collection)
+		}
+	}
+
+	visited := make(map[uint]bool, len(collections))
+	var attach func(nodes []models.Collection) []models.Collection
+	attach = func(nodes []models.Collection) []models.Collection {
+		result := make([]models.Collection, 0, len(nodes))
+		for _, node := range nodes {
+			if visited[node.ID] {
+				continue
+			}
+			visited[node.ID] = true
+			node.Children = attach(byParent[node.ID])
+			result = append(result, node)
+		}
+		sort.SliceStable(result, func(i, j int) bool { return result[i].Position < result[j].Position })
+		return result
+	}
+	return attach(roots)
+}
+
+func collectionIDs(collections []models.Collection) []uint {
+	ids := make([]uint, 0, len(collections))
+	for _, collection := range collections {
+		ids = append(ids, collection.ID)
+	}
+	return ids
+}
+
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
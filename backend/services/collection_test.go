@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+func TestCollectionService(t *testing.T) {
+	db := newTestDB(t, &models.Collection{}, &models.CollectionBookmark{}, &models.ContentShare{})
+
+	service := NewCollectionService(db)
+
+	reading := models.Collection{UserID: 1, Name: "Reading"}
+	if err := service.Create(&reading); err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	golang := models.Collection{UserID: 1, Name: "Go", ParentID: &reading.ID}
+	rust := models.Collection{UserID: 1, Name: "Rust", ParentID: &reading.ID}
+	for _, collection := range []*models.Collection{&golang, &rust} {
+		if err := service.Create(collection); err != nil {
+			t.Fatalf("failed to create collection: %v", err)
+		}
+	}
+	if rust.Position != 1 {
+		t.Fatalf("expected new collections to be appended, got position %d", rust.Position)
+	}
+
+	// Another user's collection cannot be used as a parent
+	if err := service.Create(&models.Collection{UserID: 2, Name: "Sneaky", ParentID: &reading.ID}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected not found for foreign parent, got %v", err)
+	}
+
+	var bookmarks []models.Bookmark
+	for _, title := range []string{"a", "b", "c"} {
+		bookmark := models.Bookmark{UserID: 1, Title: title, URL: "https://example.com/" + title}
+		db.Create(&bookmark)
+		bookmarks = append(bookmarks, bookmark)
+	}
+
+	added, err := service.AddBookmarks(1, golang.ID, []uint{bookmarks[0].ID, bookmarks[1].ID, bookmarks[2].ID, bookmarks[0].ID})
+	if err != nil || added != 3 {
+		t.Fatalf("expected 3 bookmarks added, got %d (err %v)", added, err)
+	}
+	// Bookmarks can be in several collections
+	if _, err := service.AddBookmarks(1, rust.ID, []uint{bookmarks[0].ID}); err != nil {
+		t.Fatalf("failed to add bookmark to second collection: %v", err)
+	}
+
+	if err := service.ReorderBookmarks(1, golang.ID, []uint{bookmarks[2].ID, bookmarks[0].ID}); err != nil {
+		t.Fatalf("failed to reorder: %v", err)
+	}
+	entries, err := service.Bookmarks(golang.ID)
+	if err != nil {
+		t.Fatalf("failed to list bookmarks: %v", err)
+	}
+	var order []string
+	for _, entry := range entries {
+		order = append(order, entry.Bookmark.Title)
+	}
+	if len(order) != 3 || order[0] != "c" || order[1] != "a" || order[2] != "b" {
+		t.Fatalf("unexpected order: %v", order)
+	}
+
+	// Moving a collection below its own child is rejected
+	if err := service.Move(1, reading.ID, &golang.ID, 0); !errors.Is(err, ErrCollectionCycle) {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+	if err := service.Move(1, rust.ID, &reading.ID, 0); err != nil {
+		t.Fatalf("failed to move collection: %v", err)
+	}
+
+	tree, err := service.Tree(1)
+	if err != nil {
+		t.Fatalf("failed to load tree: %v", err)
+	}
+	if len(tree) != 1 || len(tree[0].Children) != 2 || tree[0].Children[0].Name != "Rust" {
+		t.Fatalf("unexpected tree: %+v", tree)
+	}
+	if tree[0].Children[1].BookmarkCount != 3 {
+		t.Fatalf("expected 3 bookmarks in Go, got %d", tree[0].Children[1].BookmarkCount)
+	}
+
+	shared, err := service.SharedView(1, reading.ID)
+	if err != nil {
+		t.Fatalf("failed to build shared view: %v", err)
+	}
+	if len(shared.Children) != 2 || len(shared.Children[1].Bookmarks) != 3 {
+		t.Fatalf("unexpected shared view: %+v", shared)
+	}
+
+	// Deleting keeps the bookmarks and moves sub-collections up a level
+	if err := service.Delete(1, reading.ID, false); err != nil {
+		t.Fatalf("failed to delete collection: %v", err)
+	}
+	var bookmarkCount int64
+	db.Model(&models.Bookmark{}).Count(&bookmarkCount)
+	if bookmarkCount != 3 {
+		t.Fatalf("expected bookmarks to survive, got %d", bookmarkCount)
+	}
+	tree, _ = service.Tree(1)
+	if len(tree) != 2 {
+		t.Fatalf("expected children to move to the top level, got %+v", tree)
+	}
+
+	if err := service.Delete(1, golang.ID, true); err != nil {
+		t.Fatalf("failed to delete collection: %v", err)
+	}
+	var entryCount int64
+	db.Model(&models.CollectionBookmark{}).Where("collection_id = ?", golang.ID).Count(&entryCount)
+	if entryCount != 0 {
+		t.Fatalf("expected collection entries to be removed, got %d", entryCount)
+	}
+	db.Model(&models.Bookmark{}).Count(&bookmarkCount)
+	if bookmarkCount != 3 {
+		t.Fatalf("expected bookmarks to survive, got %d", bookmarkCount)
+	}
+}
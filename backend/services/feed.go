@@ -0,0 +1,695 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultFeedRefreshInterval is how long a feed rests between polls
+	defaultFeedRefreshInterval = time.Hour
+
+	// maxFeedErrorBackoff caps the polling delay for failing feeds
+	maxFeedErrorBackoff = 24 * time.Hour
+
+	// maxItemsPerFetch bounds how many entries of one document are stored
+	maxItemsPerFetch = 200
+
+	feedAcceptHeader = "application/rss+xml, application/atom+xml, application/feed+json, application/json;q=0.9, application/xml;q=0.9, text/xml;q=0.9, */*;q=0.8"
+)
+
+var (
+	// ErrFeedExists is returned when subscribing to a feed twice
+	ErrFeedExists = errors.New("already subscribed to this feed")
+
+	// ErrNoFeedFound is returned when a URL is neither a feed nor a page linking to one
+	ErrNoFeedFound = errors.New("no feed found at this URL")
+)
+
+// FeedService manages feed subscriptions, their items and auto-save rules
+type FeedService struct {
+	db              *gorm.DB
+	refreshInterval time.Duration
+}
+
+// NewFeedService creates a new feed service
+func NewFeedService(db *gorm.DB) *FeedService {
+	return &FeedService{db: db, refreshInterval: defaultFeedRefreshInterval}
+}
+
+// Subscribe fetches a feed and stores it with its current items. When the
+// URL points at a web page, the feed it advertises is used instead.
+func (s *FeedService) Subscribe(ctx context.Context, userID uint, rawURL, category string) (*models.Feed, error) {
+	feedURL := normalizeFeedURL(rawURL)
+	if err := s.checkNotSubscribed(userID, feedURL); err != nil {
+		return nil, err
+	}
+
+	page, err := fetchFeedDocument(ctx, feedURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if page.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP error: %s", page.Status)
+	}
+
+	parsed, parseErr := ParseFeed(page.Body, page.FinalURL)
+	if parseErr != nil {
+		discovered := DiscoverFeedURL(page.Body, page.ContentType, page.FinalURL)
+		if discovered == "" {
+			return nil, ErrNoFeedFound
+		}
+		feedURL = discovered
+		if err := s.checkNotSubscribed(userID, feedURL); err != nil {
+			return nil, err
+		}
+		if page, err = fetchFeedDocument(ctx, feedURL, nil); err != nil {
+			return nil, err
+		}
+		if page.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("HTTP error: %s", page.Status)
+		}
+		if parsed, err = ParseFeed(page.Body, page.FinalURL); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	next := now.Add(s.refreshInterval)
+	feed := &models.Feed{
+		UserID:        userID,
+		URL:           feedURL,
+		SiteURL:       parsed.SiteURL,
+		Title:         parsed.Title,
+		Description:   parsed.Description,
+		Category:      strings.TrimSpace(category),
+		Format:        parsed.Format,
+		ETag:          page.Header.Get("ETag"),
+		LastModified:  page.Header.Get("Last-Modified"),
+		LastFetchedAt: &now,
+		NextFetchAt:   &next,
+	}
+	if feed.Title == "" {
+		feed.Title = feedURL
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(feed).Error; err != nil {
+			return err
+		}
+		// Existing entries are backlog, so rules only apply to later items
+		_, err := s.storeItems(tx, feed, parsed.Items)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return feed, nil
+}
+
+// Refresh polls a feed using its cached validators, stores new items and
+// applies the user's auto-save rules to them. It returns the number of new items.
+func (s *FeedService) Refresh(ctx context.Context, feed *models.Feed) (int, error) {
+	header := http.Header{}
+	if feed.ETag != "" {
+		header.Set("If-None-Match", feed.ETag)
+	}
+	if feed.LastModified != "" {
+		header.Set("If-Modified-Since", feed.LastModified)
+	}
+
+	page, err := fetchFeedDocument(ctx, feed.URL, header)
+	if err == nil && page.StatusCode != http.StatusOK && page.StatusCode != http.StatusNotModified {
+		err = fmt.Errorf("HTTP error: %s", page.Status)
+	}
+
+	var parsed *ParsedFeed
+	if err == nil && page.StatusCode == http.StatusOK {
+		parsed, err = ParseFeed(page.Body, page.FinalURL)
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			s.recordFeedError(feed, err)
+		}
+		return 0, err
+	}
+
+	now := time.Now()
+	next := now.Add(s.refreshInterval)
+	updates := map[string]interface{}{
+		"last_fetched_at": now,
+		"next_fetch_at":   next,
+		"last_error":      "",
+		"error_count":     0,
+	}
+
+	var created []models.FeedItem
+	if parsed != nil {
+		updates["etag"] = page.Header.Get("ETag")
+		updates["last_modified"] = page.Header.Get("Last-Modified")
+		updates["format"] = parsed.Format
+		if parsed.SiteURL != "" {
+			updates["site_url"] = parsed.SiteURL
+		}
+		if parsed.Description != "" {
+			updates["description"] = parsed.Description
+		}
+		if (feed.Title == "" || feed.Title == feed.URL) && parsed.Title != "" {
+			updates["title"] = parsed.Title
+		}
+
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			created, err = s.storeItems(tx, feed, parsed.Items)
+			return err
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := s.db.Model(&models.Feed{}).Where("id = ?", feed.ID).UpdateColumns(updates).Error; err != nil {
+		return len(created), err
+	}
+	feed.LastFetchedAt = &now
+	feed.NextFetchAt = &next
+	feed.LastError = ""
+	feed.ErrorCount = 0
+
+	if len(created) > 0 {
+		if err := s.applyRules(feed, created); err != nil {
+			return len(created), err
+		}
+	}
+	return len(created), nil
+}
+
+// recordFeedError stores a failed poll and backs off exponentially
+func (s *FeedService) recordFeedError(feed *models.Feed, fetchErr error) {
+	feed.ErrorCount++
+	backoff := s.refreshInterval
+	for i := 1; i < feed.ErrorCount && backoff < maxFeedErrorBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxFeedErrorBackoff {
+		backoff = maxFeedErrorBackoff
+	}
+
+	now := time.Now()
+	next := now.Add(backoff)
+	feed.LastFetchedAt = &now
+	feed.NextFetchAt = &next
+	feed.LastError = fetchErr.Error()
+
+	s.db.Model(&models.Feed{}).Where("id = ?", feed.ID).UpdateColumns(map[string]interface{}{
+		"last_fetched_at": now,
+		"next_fetch_at":   next,
+		"last_error":      feed.LastError,
+		"error_count":     feed.ErrorCount,
+	})
+}
+
+// storeItems inserts the entries the feed has not seen yet and returns them
+func (s *FeedService) storeItems(tx *gorm.DB, feed *models.Feed, items []ParsedFeedItem) ([]models.FeedItem, error) {
+	if len(items) > maxItemsPerFetch {
+		items = items[:maxItemsPerFetch]
+	}
+
+	guids := make([]string, 0, len(items))
+	for _, item := range items {
+		guids = append(guids, item.GUID)
+	}
+	var existing []string
+	if len(guids) > 0 {
+		if err := tx.Model(&models.FeedItem{}).Where("feed_id = ? AND guid IN ?", feed.ID, guids).
+			Pluck("guid", &existing).Error; err != nil {
+			return nil, err
+		}
+	}
+	seen := make(map[string]bool, len(existing))
+	for _, guid := range existing {
+		seen[guid] = true
+	}
+
+	var created []models.FeedItem
+	for _, item := range items {
+		if item.GUID == "" || seen[item.GUID] {
+			continue
+		}
+		seen[item.GUID] = true
+
+		feedItem := models.FeedItem{
+			UserID:      feed.UserID,
+			FeedID:      feed.ID,
+			GUID:        item.GUID,
+			URL:         item.URL,
+			Title:       item.Title,
+			Author:      item.Author,
+			Summary:     item.Summary,
+			Content:     item.Content,
+			Categories:  item.Categories,
+			PublishedAt: item.PublishedAt,
+		}
+		if err := tx.Create(&feedItem).Error; err != nil {
+			return nil, err
+		}
+		created = append(created, feedItem)
+	}
+	return created, nil
+}
+
+// applyRules saves new items matching one of the user's active rules
+func (s *FeedService) applyRules(feed *models.Feed, items []models.FeedItem) error {
+	var rules []models.FeedRule
+	if err := s.db.Where("user_id = ? AND is_active = ? AND (feed_id IS NULL OR feed_id = ?)", feed.UserID, true, feed.ID).
+		Order("id ASC").Find(&rules).Error; err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+
+	for i := range items {
+		var tags []string
+		var matched []uint
+		for _, rule := range rules {
+			if MatchFeedRule(&rule, &items[i]) {
+				matched = append(matched, rule.ID)
+				tags = append(tags, rule.AddTags...)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+		if _, _, err := s.SaveItem(feed.UserID, items[i].ID, tags); err != nil {
+			return err
+		}
+		if err := s.db.Model(&models.FeedRule{}).Where("id IN ?", matched).
+			UpdateColumn("match_count", gorm.Expr("match_count + 1")).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MatchFeedRule reports whether an item matches a rule's keywords or tags
+func MatchFeedRule(rule *models.FeedRule, item *models.FeedItem) bool {
+	if rule.FeedID != nil && *rule.FeedID != item.FeedID {
+		return false
+	}
+
+	if len(rule.Keywords) > 0 {
+		text := strings.ToLower(item.Title + "\n" + HTMLToText(item.Summary) + "\n" + HTMLToText(item.Content))
+		for _, keyword := range rule.Keywords {
+			if keyword = strings.ToLower(strings.TrimSpace(keyword)); keyword != "" && strings.Contains(text, keyword) {
+				return true
+			}
+		}
+	}
+
+	for _, tag := range rule.MatchTags {
+		for _, category := range item.Categories {
+			if strings.EqualFold(strings.TrimSpace(tag), strings.TrimSpace(category)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SaveItem turns a feed item into a bookmark, reusing an existing bookmark
+// of the same page. It returns the bookmark and whether it was created.
+func (s *FeedService) SaveItem(userID, itemID uint, tags []string) (*models.Bookmark, bool, error) {
+	var item models.FeedItem
+	if err := s.db.Where("id = ? AND user_id = ?", itemID, userID).First(&item).Error; err != nil {
+		return nil, false, err
+	}
+
+	var bookmark models.Bookmark
+	if item.BookmarkID != nil {
+		if err := s.db.Where("id = ? AND user_id = ?", *item.BookmarkID, userID).Preload("Tags").First(&bookmark).Error; err == nil {
+			return &bookmark, false, nil
+		}
+	}
+	if item.URL == "" {
+		return nil, false, fmt.Errorf("feed item has no link")
+	}
+
+	dedupService := NewBookmarkDedupService(s.db)
+	key := dedupService.CanonicalKey(userID, item.URL)
+	duplicates, err := dedupService.FindDuplicates(userID, key, 0)
+	if err != nil {
+		return nil, false, err
+	}
+
+	created := false
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if len(duplicates) > 0 {
+			bookmark = duplicates[0]
+		} else {
+			text := HTMLToText(item.Content)
+			if text == "" {
+				text = HTMLToText(item.Summary)
+			}
+			bookmark = models.Bookmark{
+				UserID:       userID,
+				Title:        item.Title,
+				URL:          item.URL,
+				CanonicalKey: key,
+				Description:  truncateWords(HTMLToText(item.Summary), 50),
+				Content:      text,
+				Author:       item.Author,
+				PublishedAt:  item.PublishedAt,
+			}
+			if bookmark.Title == "" {
+				bookmark.Title = item.URL
+			}
+			if err := tx.Omit("Tags").Create(&bookmark).Error; err != nil {
+				return err
+			}
+			created = true
+		}
+
+		tagCache := make(map[string]*models.Tag)
+		for _, name := range tags {
+			if strings.TrimSpace(name) == "" {
+				continue
+			}
+			tag, err := findOrCreateImportTag(tx, userID, name, tagCache)
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&bookmark).Association("Tags").Append(tag); err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.FeedItem{}).Where("id = ?", item.ID).UpdateColumn("bookmark_id", bookmark.ID).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	s.db.Preload("Tags").First(&bookmark, bookmark.ID)
+	return &bookmark, created, nil
+}
+
+// Feeds lists the user's subscriptions with their unread counts
+func (s *FeedService) Feeds(userID uint) ([]models.Feed, error) {
+	var feeds []models.Feed
+	if err := s.db.Where("user_id = ?", userID).Order("category ASC, title ASC").Find(&feeds).Error; err != nil {
+		return nil, err
+	}
+
+	counts, err := s.UnreadCounts(userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range feeds {
+		feeds[i].UnreadCount = counts[feeds[i].ID]
+	}
+	return feeds, nil
+}
+
+// UnreadCounts returns the number of unread items per feed
+func (s *FeedService) UnreadCounts(userID uint) (map[uint]int64, error) {
+	var rows []struct {
+		FeedID uint
+		Count  int64
+	}
+	if err := s.db.Model(&models.FeedItem{}).Select("feed_id, COUNT(*) AS count").
+		Where("user_id = ? AND is_read = ?", userID, false).
+		Group("feed_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.FeedID] = row.Count
+	}
+	return counts, nil
+}
+
+// MarkRead sets the read state of items. With no item IDs every item of
+// feedID (or of all feeds when feedID is 0) is updated. It returns the number
+// of items changed.
+func (s *FeedService) MarkRead(userID, feedID uint, itemIDs []uint, read bool) (int64, error) {
+	query := s.db.Model(&models.FeedItem{}).Where("user_id = ? AND is_read = ?", userID, !read)
+	if feedID != 0 {
+		query = query.Where("feed_id = ?", feedID)
+	}
+	if len(itemIDs) > 0 {
+		query = query.Where("id IN ?", itemIDs)
+	}
+
+	var readAt interface{}
+	if read {
+		readAt = time.Now()
+	}
+	result := query.UpdateColumns(map[string]interface{}{"is_read": read, "read_at": readAt})
+	return result.RowsAffected, result.Error
+}
+
+// Unsubscribe removes a feed and its items. Bookmarks saved from the feed are kept.
+func (s *FeedService) Unsubscribe(userID, feedID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var feed models.Feed
+		if err := tx.Where("id = ? AND user_id = ?", feedID, userID).First(&feed).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("feed_id = ?", feed.ID).Delete(&models.FeedItem{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("feed_id = ?", feed.ID).Delete(&models.FeedRule{}).Error; err != nil {
+			return err
+		}
+		// Hard delete so the same URL can be subscribed to again
+		return tx.Unscoped().Delete(&feed).Error
+	})
+}
+
+func (s *FeedService) checkNotSubscribed(userID uint, feedURL string) error {
+	var count int64
+	if err := s.db.Unscoped().Model(&models.Feed{}).Where("user_id = ? AND url = ?", userID, feedURL).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrFeedExists
+	}
+	return nil
+}
+
+type opmlOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Outlines []opmlOutline `xml:"outline"`
+}
+
+type opmlDocument struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    struct {
+		Title       string `xml:"title"`
+		DateCreated string `xml:"dateCreated,omitempty"`
+	} `xml:"head"`
+	Body struct {
+		Outlines []opmlOutline `xml:"outline"`
+	} `xml:"body"`
+}
+
+// ImportOPML subscribes to every feed listed in an OPML document. Feeds are
+// fetched by the poller rather than during the import; nested outlines
+// become categories. It returns how many feeds were added and skipped.
+func (s *FeedService) ImportOPML(userID uint, data []byte) (int, int, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charset.NewReaderLabel
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	var doc opmlDocument
+	if err := decoder.Decode(&doc); err != nil {
+		return 0, 0, fmt.Errorf("invalid OPML: %w", err)
+	}
+
+	var existing []string
+	if err := s.db.Unscoped().Model(&models.Feed{}).Where("user_id = ?", userID).Pluck("url", &existing).Error; err != nil {
+		return 0, 0, err
+	}
+	subscribed := make(map[string]bool, len(existing))
+	for _, feedURL := range existing {
+		subscribed[feedURL] = true
+	}
+
+	added, skipped := 0, 0
+	now := time.Now()
+
+	var walk func(outlines []opmlOutline, category string) error
+	walk = func(outlines []opmlOutline, category string) error {
+		for _, outline := range outlines {
+			if outline.XMLURL == "" {
+				folder := strings.TrimSpace(outline.Text)
+				if folder == "" {
+					folder = strings.TrimSpace(outline.Title)
+				}
+				if category != "" && folder != "" {
+					folder = category + "/" + folder
+				} else if folder == "" {
+					folder = category
+				}
+				if err := walk(outline.Outlines, folder); err != nil {
+					return err
+				}
+				continue
+			}
+
+			feedURL := normalizeFeedURL(outline.XMLURL)
+			if subscribed[feedURL] {
+				skipped++
+				continue
+			}
+			subscribed[feedURL] = true
+
+			title := strings.TrimSpace(outline.Title)
+			if title == "" {
+				title = strings.TrimSpace(outline.Text)
+			}
+			if title == "" {
+				title = feedURL
+			}
+			feed := models.Feed{
+				UserID:      userID,
+				URL:         feedURL,
+				SiteURL:     outline.HTMLURL,
+				Title:       title,
+				Category:    category,
+				NextFetchAt: &now,
+			}
+			if err := s.db.Create(&feed).Error; err != nil {
+				return err
+			}
+			added++
+		}
+		return nil
+	}
+
+	if err := walk(doc.Body.Outlines, ""); err != nil {
+		return added, skipped, err
+	}
+	return added, skipped, nil
+}
+
+// ExportOPML writes the user's subscriptions as an OPML 2.0 document,
+// grouping feeds into outlines by category
+func (s *FeedService) ExportOPML(userID uint) ([]byte, error) {
+	var feeds []models.Feed
+	if err := s.db.Where("user_id = ?", userID).Order("title ASC").Find(&feeds).Error; err != nil {
+		return nil, err
+	}
+
+	var doc opmlDocument
+	doc.Version = "2.0"
+	doc.Head.Title = "Trackeep feed subscriptions"
+	doc.Head.DateCreated = time.Now().UTC().Format(time.RFC1123Z)
+
+	folders := make(map[string][]opmlOutline)
+	var names []string
+	for _, feed := range feeds {
+		outline := opmlOutline{
+			Text:    feed.Title,
+			Title:   feed.Title,
+			Type:    "rss",
+			XMLURL:  feed.URL,
+			HTMLURL: feed.SiteURL,
+		}
+		if feed.Category == "" {
+			doc.Body.Outlines = append(doc.Body.Outlines, outline)
+			continue
+		}
+		if _, ok := folders[feed.Category]; !ok {
+			names = append(names, feed.Category)
+		}
+		folders[feed.Category] = append(folders[feed.Category], outline)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		doc.Body.Outlines = append(doc.Body.Outlines, opmlOutline{Text: name, Title: name, Outlines: folders[name]})
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
+
+// DiscoverFeedURL returns the first feed advertised by an HTML page through
+// <link rel="alternate">, or an empty string
+func DiscoverFeedURL(body []byte, contentType, pageURL string) string {
+	reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return ""
+	}
+	doc, err := xhtml.Parse(reader)
+	if err != nil {
+		return ""
+	}
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return ""
+	}
+
+	var found string
+	walkElements(doc, func(n *xhtml.Node) {
+		if found != "" || n.DataAtom != atom.Link {
+			return
+		}
+		if !strings.Contains(strings.ToLower(getAttr(n, "rel")), "alternate") {
+			return
+		}
+		switch strings.ToLower(strings.TrimSpace(getAttr(n, "type"))) {
+		case "application/rss+xml", "application/atom+xml", "application/feed+json", "application/json":
+		default:
+			return
+		}
+		if href := strings.TrimSpace(getAttr(n, "href")); href != "" {
+			if resolved, err := base.Parse(href); err == nil {
+				found = resolved.String()
+			}
+		}
+	})
+	return found
+}
+
+func fetchFeedDocument(ctx context.Context, feedURL string, header http.Header) (*PageResponse, error) {
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set("Accept", feedAcceptHeader)
+	return FetchPageWithHeaders(ctx, feedURL, true, header)
+}
+
+// normalizeFeedURL trims the URL and defaults to https when no scheme is given
+func normalizeFeedURL(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL != "" && !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+	return rawURL
+}
@@ -0,0 +1,396 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// Feed formats recognized by ParseFeed
+const (
+	FeedFormatRSS  = "rss"
+	FeedFormatAtom = "atom"
+	FeedFormatJSON = "json"
+)
+
+// ParsedFeed is a feed document normalized across RSS, Atom and JSON Feed
+type ParsedFeed struct {
+	Format      string
+	Title       string
+	Description string
+	SiteURL     string
+	Items       []ParsedFeedItem
+}
+
+// ParsedFeedItem is a normalized feed entry
+type ParsedFeedItem struct {
+	GUID        string
+	URL         string
+	Title       string
+	Author      string
+	Summary     string
+	Content     string
+	Categories  []string
+	PublishedAt *time.Time
+}
+
+// feedTimeLayouts covers the date formats found in the wild, RFC 822 variants first
+var feedTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 02 Jan 2006 15:04 -0700",
+	"02 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	"02 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339Nano,
+	time.RFC3339,
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ParseFeed parses an RSS 2.0 (or RSS 1.0/RDF), Atom or JSON Feed document.
+// Relative links are resolved against feedURL.
+func ParseFeed(body []byte, feedURL string) (*ParsedFeed, error) {
+	trimmed := bytes.TrimLeft(body, " \t\r\n\ufeff")
+	var (
+		feed *ParsedFeed
+		err  error
+	)
+	if bytes.HasPrefix(trimmed, []byte("{")) {
+		feed, err = parseJSONFeed(trimmed)
+	} else {
+		feed, err = parseXMLFeed(trimmed)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	base, _ := url.Parse(feedURL)
+	resolve := func(link string) string {
+		link = strings.TrimSpace(link)
+		if link == "" || base == nil {
+			return link
+		}
+		if resolved, err := base.Parse(link); err == nil {
+			return resolved.String()
+		}
+		return link
+	}
+
+	feed.Title = strings.TrimSpace(feed.Title)
+	feed.SiteURL = resolve(feed.SiteURL)
+	for i := range feed.Items {
+		item := &feed.Items[i]
+		item.URL = resolve(item.URL)
+		item.Title = strings.TrimSpace(item.Title)
+		item.GUID = strings.TrimSpace(item.GUID)
+		if item.GUID == "" {
+			item.GUID = item.URL
+		}
+		if item.GUID == "" {
+			item.GUID = item.Title
+		}
+		if item.Title == "" {
+			item.Title = truncateWords(HTMLToText(item.Summary), 12)
+		}
+	}
+	return feed, nil
+}
+
+func parseFeedTime(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	for _, layout := range feedTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			t = t.UTC()
+			return &t
+		}
+	}
+	return nil
+}
+
+type xmlFeedLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+	Text string `xml:",chardata"`
+}
+
+type xmlFeedPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email"`
+}
+
+type xmlFeedText struct {
+	Type  string `xml:"type,attr"`
+	Inner string `xml:",innerxml"`
+	Text  string `xml:",chardata"`
+}
+
+// value returns the text of an Atom text construct; XHTML content is kept as markup
+func (t xmlFeedText) value() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.Inner)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+type xmlFeedCategory struct {
+	Term string `xml:"term,attr"`
+	Text string `xml:",chardata"`
+}
+
+type xmlFeedItem struct {
+	// Atom authors are listed first so namespaced elements match them
+	// instead of the plain RSS author
+	ID      string          `xml:"id"`
+	Authors []xmlFeedPerson `xml:"http://www.w3.org/2005/Atom author"`
+
+	// RSS
+	GUID        string            `xml:"guid"`
+	Link        []xmlFeedLink     `xml:"link"`
+	Title       string            `xml:"title"`
+	Author      string            `xml:"author"`
+	Creator     string            `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Description string            `xml:"description"`
+	Encoded     string            `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate     string            `xml:"pubDate"`
+	Date        string            `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Categories  []xmlFeedCategory `xml:"category"`
+
+	// Atom
+	Summary   xmlFeedText `xml:"summary"`
+	Content   xmlFeedText `xml:"content"`
+	Published string      `xml:"published"`
+	Updated   string      `xml:"updated"`
+}
+
+type xmlFeedChannel struct {
+	Title       string        `xml:"title"`
+	Link        []xmlFeedLink `xml:"link"`
+	Description string        `xml:"description"`
+	Items       []xmlFeedItem `xml:"item"`
+}
+
+type xmlFeedDocument struct {
+	XMLName xml.Name
+
+	// RSS 2.0 nests items in the channel, RSS 1.0 places them next to it
+	Channel  xmlFeedChannel `xml:"channel"`
+	RDFItems []xmlFeedItem  `xml:"item"`
+
+	// Atom
+	Title    string        `xml:"title"`
+	Subtitle string        `xml:"subtitle"`
+	Link     []xmlFeedLink `xml:"link"`
+	Entries  []xmlFeedItem `xml:"entry"`
+}
+
+func parseXMLFeed(body []byte) (*ParsedFeed, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.CharsetReader = charset.NewReaderLabel
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+
+	var doc xmlFeedDocument
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid feed: %w", err)
+	}
+
+	switch strings.ToLower(doc.XMLName.Local) {
+	case "rss", "rdf":
+		feed := &ParsedFeed{
+			Format:      FeedFormatRSS,
+			Title:       doc.Channel.Title,
+			Description: strings.TrimSpace(doc.Channel.Description),
+			SiteURL:     rssLink(doc.Channel.Link),
+		}
+		for _, item := range append(doc.Channel.Items, doc.RDFItems...) {
+			feed.Items = append(feed.Items, rssItem(item))
+		}
+		return feed, nil
+
+	case "feed":
+		feed := &ParsedFeed{
+			Format:      FeedFormatAtom,
+			Title:       doc.Title,
+			Description: strings.TrimSpace(doc.Subtitle),
+			SiteURL:     atomLink(doc.Link),
+		}
+		for _, entry := range doc.Entries {
+			feed.Items = append(feed.Items, atomItem(entry))
+		}
+		return feed, nil
+	}
+
+	return nil, fmt.Errorf("unsupported feed format: <%s>", doc.XMLName.Local)
+}
+
+// rssLink picks the plain <link> element, skipping atom:link self references
+func rssLink(links []xmlFeedLink) string {
+	for _, link := range links {
+		if text := strings.TrimSpace(link.Text); text != "" {
+			return text
+		}
+	}
+	return atomLink(links)
+}
+
+// atomLink returns the alternate link of an Atom element
+func atomLink(links []xmlFeedLink) string {
+	for _, link := range links {
+		if link.Href != "" && (link.Rel == "" || link.Rel == "alternate") {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+func rssItem(item xmlFeedItem) ParsedFeedItem {
+	parsed := ParsedFeedItem{
+		GUID:    item.GUID,
+		URL:     rssLink(item.Link),
+		Title:   item.Title,
+		Author:  strings.TrimSpace(item.Creator),
+		Summary: strings.TrimSpace(item.Description),
+		Content: strings.TrimSpace(item.Encoded),
+	}
+	if parsed.Author == "" {
+		parsed.Author = strings.TrimSpace(item.Author)
+	}
+	parsed.PublishedAt = parseFeedTime(item.PubDate)
+	if parsed.PublishedAt == nil {
+		parsed.PublishedAt = parseFeedTime(item.Date)
+	}
+	for _, category := range item.Categories {
+		if name := strings.TrimSpace(category.Text); name != "" {
+			parsed.Categories = append(parsed.Categories, name)
+		}
+	}
+	return parsed
+}
+
+func atomItem(entry xmlFeedItem) ParsedFeedItem {
+	parsed := ParsedFeedItem{
+		GUID:    entry.ID,
+		URL:     atomLink(entry.Link),
+		Title:   entry.Title,
+		Summary: entry.Summary.value(),
+		Content: entry.Content.value(),
+	}
+	var authors []string
+	for _, author := range entry.Authors {
+		if name := strings.TrimSpace(author.Name); name != "" {
+			authors = append(authors, name)
+		}
+	}
+	parsed.Author = strings.Join(authors, ", ")
+	parsed.PublishedAt = parseFeedTime(entry.Published)
+	if parsed.PublishedAt == nil {
+		parsed.PublishedAt = parseFeedTime(entry.Updated)
+	}
+	for _, category := range entry.Categories {
+		name := strings.TrimSpace(category.Term)
+		if name == "" {
+			name = strings.TrimSpace(category.Text)
+		}
+		if name != "" {
+			parsed.Categories = append(parsed.Categories, name)
+		}
+	}
+	return parsed
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedDocument struct {
+	Version     string `json:"version"`
+	Title       string `json:"title"`
+	HomePageURL string `json:"home_page_url"`
+	Description string `json:"description"`
+	Items       []struct {
+		ID            json.RawMessage  `json:"id"`
+		URL           string           `json:"url"`
+		ExternalURL   string           `json:"external_url"`
+		Title         string           `json:"title"`
+		ContentHTML   string           `json:"content_html"`
+		ContentText   string           `json:"content_text"`
+		Summary       string           `json:"summary"`
+		DatePublished string           `json:"date_published"`
+		DateModified  string           `json:"date_modified"`
+		Tags          []string         `json:"tags"`
+		Author        *jsonFeedAuthor  `json:"author"`
+		Authors       []jsonFeedAuthor `json:"authors"`
+	} `json:"items"`
+}
+
+func parseJSONFeed(body []byte) (*ParsedFeed, error) {
+	var doc jsonFeedDocument
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON feed: %w", err)
+	}
+	if !strings.Contains(doc.Version, "jsonfeed.org") {
+		return nil, fmt.Errorf("unsupported JSON feed version: %q", doc.Version)
+	}
+
+	feed := &ParsedFeed{
+		Format:      FeedFormatJSON,
+		Title:       doc.Title,
+		Description: doc.Description,
+		SiteURL:     doc.HomePageURL,
+	}
+	for _, item := range doc.Items {
+		parsed := ParsedFeedItem{
+			URL:        item.URL,
+			Title:      item.Title,
+			Summary:    item.Summary,
+			Content:    item.ContentHTML,
+			Categories: item.Tags,
+		}
+		// IDs are strings in 1.1 but some publishers emit numbers
+		var id interface{}
+		if err := json.Unmarshal(item.ID, &id); err == nil && id != nil {
+			parsed.GUID = fmt.Sprint(id)
+		}
+		if parsed.URL == "" {
+			parsed.URL = item.ExternalURL
+		}
+		if parsed.Content == "" {
+			parsed.Content = item.ContentText
+		}
+
+		var authors []string
+		if item.Author != nil && item.Author.Name != "" {
+			authors = append(authors, item.Author.Name)
+		}
+		for _, author := range item.Authors {
+			if author.Name != "" {
+				authors = append(authors, author.Name)
+			}
+		}
+		parsed.Author = strings.Join(authors, ", ")
+
+		parsed.PublishedAt = parseFeedTime(item.DatePublished)
+		if parsed.PublishedAt == nil {
+			parsed.PublishedAt = parseFeedTime(item.DateModified)
+		}
+		feed.Items = append(feed.Items, parsed)
+	}
+	return feed, nil
+}
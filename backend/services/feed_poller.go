@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+// FeedPollerOptions configures the background feed poller
+type FeedPollerOptions struct {
+	Interval        time.Duration // how often the poller looks for due feeds
+	RefreshInterval time.Duration // time between two polls of the same feed
+	BatchSize       int           // feeds polled per sweep
+	Concurrency     int           // simultaneous feed requests
+}
+
+// FeedPoller periodically refreshes feeds whose next fetch time has passed
+type FeedPoller struct {
+	db      *gorm.DB
+	opts    FeedPollerOptions
+	service *FeedService
+
+	sweeping sync.Mutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewFeedPoller creates a feed poller, filling in defaults for unset options
+func NewFeedPoller(db *gorm.DB, opts FeedPollerOptions) *FeedPoller {
+	if opts.Interval <= 0 {
+		opts.Interval = 5 * time.Minute
+	}
+	if opts.RefreshInterval <= 0 {
+		opts.RefreshInterval = defaultFeedRefreshInterval
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 4
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &FeedPoller{
+		db:      db,
+		opts:    opts,
+		service: &FeedService{db: db, refreshInterval: opts.RefreshInterval},
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start polls feeds in the background until Stop is called
+func (fp *FeedPoller) Start() {
+	go func() {
+		ticker := time.NewTicker(fp.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := fp.RunOnce(fp.ctx); err != nil && fp.ctx.Err() == nil {
+					log.Printf("Feed poll failed: %v", err)
+				}
+			case <-fp.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background loop and cancels in-flight requests
+func (fp *FeedPoller) Stop() {
+	fp.cancel()
+}
+
+// RunOnce refreshes one batch of due feeds and returns how many were polled.
+// Overlapping sweeps are skipped.
+func (fp *FeedPoller) RunOnce(ctx context.Context) (int, error) {
+	if !fp.sweeping.TryLock() {
+		return 0, nil
+	}
+	defer fp.sweeping.Unlock()
+
+	var feeds []models.Feed
+	if err := fp.db.Where("next_fetch_at IS NULL OR next_fetch_at <= ?", time.Now()).
+		Order("next_fetch_at IS NOT NULL, next_fetch_at ASC").
+		Limit(fp.opts.BatchSize).
+		Find(&feeds).Error; err != nil {
+		return 0, err
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		polled int
+	)
+	sem := make(chan struct{}, fp.opts.Concurrency)
+
+	for i := range feeds {
+		if ctx.Err() != nil {
+			break
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(feed *models.Feed) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			requestCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+			defer cancel()
+
+			if _, err := fp.service.Refresh(requestCtx, feed); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to refresh feed %d (%s): %v", feed.ID, feed.URL, err)
+			}
+
+			mu.Lock()
+			polled++
+			mu.Unlock()
+		}(&feeds[i])
+	}
+
+	wg.Wait()
+	return polled, ctx.Err()
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/trackeep/backend/models"
+)
+
+func TestParseFeed(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		format string
+		title  string
+		item   ParsedFeedItem
+	}{
+		{
+			name:   "rss",
+			format: FeedFormatRSS,
+			title:  "Go Blog",
+			body: `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:content="http://purl.org/rss/1.0/modules/content/" xmlns:atom="http://www.w3.org/2005/Atom">
+<channel>
+  <title>Go Blog</title>
+  <atom:link href="https://blog.example.com/feed.xml" rel="self" type="application/rss+xml"/>
+  <link>https://blog.example.com/</link>
+  <item>
+    <title>Range over functions</title>
+    <link>/range-functions</link>
+    <guid isPermaLink="false">post-42</guid>
+    <dc:creator>Gopher</dc:creator>
+    <pubDate>Tue, 05 Mar 2024 10:00:00 +0000</pubDate>
+    <category>go</category>
+    <description>Short &amp; sweet</description>
+    <content:encoded><![CDATA[<p>Full text</p>]]></content:encoded>
+  </item>
+</channel>
+</rss>`,
+			item: ParsedFeedItem{GUID: "post-42", URL: "https://blog.example.com/range-functions", Title: "Range over functions", Author: "Gopher", Summary: "Short & sweet", Content: "<p>Full text</p>", Categories: []string{"go"}},
+		},
+		{
+			name:   "atom",
+			format: FeedFormatAtom,
+			title:  "Example Atom",
+			body: `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title>Example Atom</title>
+  <link href="https://atom.example.com/feed" rel="self"/>
+  <link href="https://atom.example.com/"/>
+  <entry>
+    <title>Hello Atom</title>
+    <link rel="alternate" href="https://atom.example.com/hello"/>
+    <id>urn:uuid:1225c695</id>
+    <published>2024-03-05T10:00:00Z</published>
+    <author><name>Ada</name><email>ada@example.com</email></author>
+    <category term="news"/>
+    <summary>Some text.</summary>
+  </entry>
+</feed>`,
+			item: ParsedFeedItem{GUID: "urn:uuid:1225c695", URL: "https://atom.example.com/hello", Title: "Hello Atom", Author: "Ada", Summary: "Some text.", Categories: []string{"news"}},
+		},
+		{
+			name:   "json",
+			format: FeedFormatJSON,
+			title:  "JSON Feed",
+			body: `{"version": "https://jsonfeed.org/version/1.1", "title": "JSON Feed", "home_page_url": "https://json.example.com/",
+				"items": [{"id": 7, "url": "https://json.example.com/7", "title": "Seven", "content_html": "<p>Hi</p>",
+				"date_published": "2024-03-05T10:00:00Z", "tags": ["misc"], "authors": [{"name": "Grace"}]}]}`,
+			item: ParsedFeedItem{GUID: "7", URL: "https://json.example.com/7", Title: "Seven", Author: "Grace", Content: "<p>Hi</p>", Categories: []string{"misc"}},
+		},
+	}
+
+	for _, tt := range tests {
+		feed, err := ParseFeed([]byte(tt.body), "https://blog.example.com/feed.xml")
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if feed.Format != tt.format || feed.Title != tt.title {
+			t.Fatalf("%s: unexpected feed %q (%s)", tt.name, feed.Title, feed.Format)
+		}
+		if len(feed.Items) != 1 {
+			t.Fatalf("%s: expected one item, got %d", tt.name, len(feed.Items))
+		}
+
+		got := feed.Items[0]
+		if got.PublishedAt == nil || got.PublishedAt.Format("2006-01-02T15:04") != "2024-03-05T10:00" {
+			t.Fatalf("%s: unexpected date %v", tt.name, got.PublishedAt)
+		}
+		if got.GUID != tt.item.GUID || got.URL != tt.item.URL || got.Title != tt.item.Title || got.Author != tt.item.Author ||
+			got.Summary != tt.item.Summary || got.Content != tt.item.Content || strings.Join(got.Categories, ",") != strings.Join(tt.item.Categories, ",") {
+			t.Fatalf("%s: unexpected item %+v", tt.name, got)
+		}
+	}
+}
+
+func TestFeedService(t *testing.T) {
+	var (
+		requests    int32
+		notModified int32
+		items       atomic.Value
+	)
+	items.Store(`<item><title>First</title><link>https://example.com/first</link><guid>1</guid></item>`)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html><head><link rel="alternate" type="application/rss+xml" href="/feed.xml"></head><body></body></html>`))
+	})
+	mux.HandleFunc("/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		body := `<rss version="2.0"><channel><title>Example</title><link>https://example.com/</link>` + items.Load().(string) + `</channel></rss>`
+		etag := `"` + strings.Repeat("x", len(body)%7+1) + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(body))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	db := newTestDB(t, &models.CanonicalURLRule{}, &models.Feed{}, &models.FeedItem{}, &models.FeedRule{})
+
+	service := NewFeedService(db)
+	ctx := context.Background()
+
+	// The site's home page advertises the feed
+	feed, err := service.Subscribe(ctx, 1, server.URL+"/", "News")
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	if feed.URL != server.URL+"/feed.xml" || feed.Title != "Example" || feed.ETag == "" {
+		t.Fatalf("unexpected feed: %+v", feed)
+	}
+	if _, err := service.Subscribe(ctx, 1, server.URL+"/feed.xml", ""); err != ErrFeedExists {
+		t.Fatalf("expected duplicate subscription to fail, got %v", err)
+	}
+
+	db.Create(&models.FeedRule{UserID: 1, Keywords: []string{"golang"}, AddTags: []string{"auto"}, IsActive: true})
+
+	// Unchanged feeds are answered with 304
+	if added, err := service.Refresh(ctx, feed); err != nil || added != 0 {
+		t.Fatalf("expected nothing new, got %d (err %v)", added, err)
+	}
+	if atomic.LoadInt32(&notModified) != 1 {
+		t.Fatalf("expected a conditional request")
+	}
+
+	items.Store(`<item><title>Golang 2</title><link>https://example.com/second</link><guid>2</guid></item>` +
+		`<item><title>First</title><link>https://example.com/first</link><guid>1</guid></item>`)
+	if added, err := service.Refresh(ctx, feed); err != nil || added != 1 {
+		t.Fatalf("expected one new item, got %d (err %v)", added, err)
+	}
+
+	var second models.FeedItem
+	if err := db.Where("guid = ?", "2").First(&second).Error; err != nil {
+		t.Fatalf("new item not stored: %v", err)
+	}
+	if second.BookmarkID == nil {
+		t.Fatalf("expected the rule to save the matching item")
+	}
+	var saved models.Bookmark
+	db.Preload("Tags").First(&saved, *second.BookmarkID)
+	if saved.URL != "https://example.com/second" || len(saved.Tags) != 1 || saved.Tags[0].Name != "auto" {
+		t.Fatalf("unexpected saved bookmark: %+v", saved)
+	}
+
+	// Saving manually reuses an existing bookmark for the same page
+	db.Create(&models.Bookmark{UserID: 1, Title: "First", URL: "https://example.com/first/?utm_source=rss", CanonicalKey: "https://example.com/first"})
+	var first models.FeedItem
+	db.Where("guid = ?", "1").First(&first)
+	bookmark, created, err := service.SaveItem(1, first.ID, nil)
+	if err != nil || created || bookmark.Title != "First" {
+		t.Fatalf("expected the existing bookmark, got %+v created=%v err=%v", bookmark, created, err)
+	}
+
+	counts, _ := service.UnreadCounts(1)
+	if counts[feed.ID] != 2 {
+		t.Fatalf("expected 2 unread items, got %d", counts[feed.ID])
+	}
+	if updated, _ := service.MarkRead(1, 0, []uint{first.ID}, true); updated != 1 {
+		t.Fatalf("expected one item marked read, got %d", updated)
+	}
+	counts, _ = service.UnreadCounts(1)
+	if counts[feed.ID] != 1 {
+		t.Fatalf("expected 1 unread item, got %d", counts[feed.ID])
+	}
+
+	// OPML round trip into another account keeps categories
+	opml, err := service.ExportOPML(1)
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	added, skipped, err := service.ImportOPML(2, opml)
+	if err != nil || added != 1 || skipped != 0 {
+		t.Fatalf("unexpected import result %d/%d (err %v)", added, skipped, err)
+	}
+	var imported models.Feed
+	db.Where("user_id = ?", 2).First(&imported)
+	if imported.URL != feed.URL || imported.Category != "News" || imported.NextFetchAt == nil {
+		t.Fatalf("unexpected imported feed: %+v", imported)
+	}
+	if _, skipped, _ := service.ImportOPML(2, opml); skipped != 1 {
+		t.Fatalf("expected re-import to skip existing feed")
+	}
+
+	// Unsubscribing keeps saved bookmarks
+	if err := service.Unsubscribe(1, feed.ID); err != nil {
+		t.Fatalf("failed to unsubscribe: %v", err)
+	}
+	var bookmarks int64
+	db.Model(&models.Bookmark{}).Where("user_id = ?", 1).Count(&bookmarks)
+	if bookmarks != 2 {
+		t.Fatalf("expected bookmarks to be kept, got %d", bookmarks)
+	}
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// brokenAfterFailures is the number of consecutive transient failures
+	// after which a link is reported as broken
+	brokenAfterFailures = 3
+
+	// maxLinkChecksPerBookmark caps the stored check history per bookmark
+	maxLinkChecksPerBookmark = 50
+)
+
+// LinkCheckerOptions configures the background link checker
+type LinkCheckerOptions struct {
+	Interval     time.Duration // how often a sweep runs
+	RecheckAfter time.Duration // minimum age of the last check before re-checking
+	BatchSize    int           // bookmarks checked per sweep
+	Concurrency  int           // simultaneous requests across all hosts
+	HostDelay    time.Duration // minimum spacing between requests to one host
+}
+
+// LinkChecker periodically re-requests bookmarked URLs and records their health
+type LinkChecker struct {
+	db    *gorm.DB
+	opts  LinkCheckerOptions
+	hosts *hostLimiter
+
+	sweeping sync.Mutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewLinkChecker creates a link checker, filling in defaults for unset options
+func NewLinkChecker(db *gorm.DB, opts LinkCheckerOptions) *LinkChecker {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+	if opts.RecheckAfter <= 0 {
+		opts.RecheckAfter = 7 * 24 * time.Hour
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 200
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 8
+	}
+	if opts.HostDelay < 0 {
+		opts.HostDelay = 0
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &LinkChecker{
+		db:     db,
+		opts:   opts,
+		hosts:  newHostLimiter(opts.HostDelay),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start runs sweeps in the background until Stop is called
+func (lc *LinkChecker) Start() {
+	go func() {
+		ticker := time.NewTicker(lc.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := lc.RunOnce(lc.ctx); err != nil && lc.ctx.Err() == nil {
+					log.Printf("Link check sweep failed: %v", err)
+				}
+			case <-lc.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background loop and cancels an in-flight sweep
+func (lc *LinkChecker) Stop() {
+	lc.cancel()
+}
+
+// RunOnce checks one batch of bookmarks that are due and returns how many
+// were checked. Overlapping sweeps are skipped.
+func (lc *LinkChecker) RunOnce(ctx context.Context) (int, error) {
+	if !lc.sweeping.TryLock() {
+		return 0, nil
+	}
+	defer lc.sweeping.Unlock()
+
+	cutoff := time.Now().Add(-lc.opts.RecheckAfter)
+
+	var bookmarks []models.Bookmark
+	if err := lc.db.Select("id", "user_id", "url", "link_failure_count").
+		Where("link_checked_at IS NULL OR link_checked_at < ?", cutoff).
+		Order("link_checked_at IS NOT NULL, link_checked_at ASC").
+		Limit(lc.opts.BatchSize).
+		Find(&bookmarks).Error; err != nil {
+		return 0, err
+	}
+
+	lc.hosts.prune()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		checked int
+	)
+	sem := make(chan struct{}, lc.opts.Concurrency)
+
+	for i := range bookmarks {
+		if ctx.Err() != nil {
+			break
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(bookmark *models.Bookmark) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			if _, err := lc.CheckBookmark(ctx, bookmark); err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Link check failed for bookmark %d: %v", bookmark.ID, err)
+				}
+				return
+			}
+			mu.Lock()
+			checked++
+			mu.Unlock()
+		}(&bookmarks[i])
+	}
+	wg.Wait()
+
+	return checked, ctx.Err()
+}
+
+// CheckBookmark requests a bookmark's URL, stores the result in its history
+// and updates the bookmark's link status
+func (lc *LinkChecker) CheckBookmark(ctx context.Context, bookmark *models.Bookmark) (*models.BookmarkLinkCheck, error) {
+	if err := lc.hosts.wait(ctx, linkHost(bookmark.URL)); err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	page, fetchErr := FetchPage(ctx, bookmark.URL, false)
+	if fetchErr != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	check := ClassifyLinkCheck(page, fetchErr, bookmark.LinkFailureCount)
+	check.UserID = bookmark.UserID
+	check.BookmarkID = bookmark.ID
+	check.CheckedAt = time.Now()
+	check.ResponseTimeMs = time.Since(started).Milliseconds()
+
+	failures := 0
+	if check.Status == models.LinkStatusError || check.Status == models.LinkStatusBroken {
+		failures = bookmark.LinkFailureCount + 1
+	}
+
+	redirectURL := ""
+	if check.Status == models.LinkStatusRedirected || check.Status == models.LinkStatusMoved {
+		redirectURL = check.FinalURL
+	}
+
+	err := lc.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(check).Error; err != nil {
+			return err
+		}
+
+		// UpdateColumns keeps UpdatedAt untouched; a health check is not a user edit
+		if err := tx.Model(&models.Bookmark{}).Where("id = ?", bookmark.ID).UpdateColumns(map[string]interface{}{
+			"link_status":        check.Status,
+			"link_status_code":   check.StatusCode,
+			"redirect_url":       redirectURL,
+			"link_failure_count": failures,
+			"link_checked_at":    check.CheckedAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		// Trim history beyond the newest maxLinkChecksPerBookmark entries
+		keep := tx.Model(&models.BookmarkLinkCheck{}).Select("id").
+			Where("bookmark_id = ?", bookmark.ID).
+			Order("checked_at DESC").
+			Limit(maxLinkChecksPerBookmark)
+		return tx.Unscoped().Where("bookmark_id = ? AND id NOT IN (?)", bookmark.ID, keep).
+			Delete(&models.BookmarkLinkCheck{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	bookmark.LinkStatus = check.Status
+	bookmark.LinkStatusCode = check.StatusCode
+	bookmark.RedirectURL = redirectURL
+	bookmark.LinkFailureCount = failures
+	bookmark.LinkCheckedAt = &check.CheckedAt
+
+	return check, nil
+}
+
+// ClassifyLinkCheck turns a fetch result into a link check record.
+// previousFailures is the bookmark's current count of consecutive failures,
+// used to escalate repeated transient errors to broken.
+func ClassifyLinkCheck(page *PageResponse, fetchErr error, previousFailures int) *models.BookmarkLinkCheck {
+	check := &models.BookmarkLinkCheck{}
+
+	if fetchErr != nil {
+		check.ErrorMessage = fetchErr.Error()
+		var dnsErr *net.DNSError
+		if errors.As(fetchErr, &dnsErr) && dnsErr.IsNotFound {
+			check.Status = models.LinkStatusBroken
+		} else {
+			check.Status = escalateFailure(previousFailures)
+		}
+		return check
+	}
+
+	check.StatusCode = page.StatusCode
+	check.FinalURL = page.FinalURL
+	check.RedirectCount = len(page.Redirects)
+
+	switch {
+	case page.StatusCode == http.StatusNotFound || page.StatusCode == http.StatusGone:
+		check.Status = models.LinkStatusBroken
+	case page.StatusCode >= 500 || page.StatusCode == http.StatusTooManyRequests:
+		check.Status = escalateFailure(previousFailures)
+	case page.StatusCode == http.StatusUnauthorized || page.StatusCode == http.StatusForbidden:
+		// Reachable but restricted, or refusing automated clients
+		check.Status = models.LinkStatusOK
+	case page.StatusCode >= 400:
+		check.Status = models.LinkStatusBroken
+	default:
+		check.Status = models.LinkStatusOK
+	}
+
+	if check.Status == models.LinkStatusOK && len(page.Redirects) > 0 {
+		check.Status = models.LinkStatusRedirected
+		for _, hop := range page.Redirects {
+			if hop.StatusCode == http.StatusMovedPermanently || hop.StatusCode == http.StatusPermanentRedirect {
+				check.Status = models.LinkStatusMoved
+				break
+			}
+		}
+	}
+
+	return check
+}
+
+func escalateFailure(previousFailures int) models.LinkStatus {
+	if previousFailures+1 >= brokenAfterFailures {
+		return models.LinkStatusBroken
+	}
+	return models.LinkStatusError
+}
+
+func linkHost(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(parsed.Hostname())
+}
+
+// hostLimiter spaces out requests to the same host
+type hostLimiter struct {
+	delay time.Duration
+	mu    sync.Mutex
+	next  map[string]time.Time
+}
+
+func newHostLimiter(delay time.Duration) *hostLimiter {
+	return &hostLimiter{delay: delay, next: make(map[string]time.Time)}
+}
+
+// wait reserves the next free slot for host and sleeps until it arrives
+func (h *hostLimiter) wait(ctx context.Context, host string) error {
+	if h.delay == 0 || host == "" {
+		return ctx.Err()
+	}
+
+	h.mu.Lock()
+	now := time.Now()
+	slot := h.next[host]
+	if slot.Before(now) {
+		slot = now
+	}
+	h.next[host] = slot.Add(h.delay)
+	h.mu.Unlock()
+
+	timer := time.NewTimer(time.Until(slot))
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// prune forgets hosts whose reserved slots have passed
+func (h *hostLimiter) prune() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := time.Now()
+	for host, slot := range h.next {
+		if slot.Before(now) {
+			delete(h.next, host)
+		}
+	}
+}
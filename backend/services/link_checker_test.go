@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/trackeep/backend/models"
+)
+
+func TestLinkChecker_RunOnce(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/temporary", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/ok", http.StatusFound)
+	})
+	mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	db := newTestDB(t, &models.BookmarkLinkCheck{})
+	// Shared-cache sqlite rejects concurrent writers instead of waiting
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to access sql database: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+
+	want := map[string]models.LinkStatus{
+		"/ok":        models.LinkStatusOK,
+		"/gone":      models.LinkStatusBroken,
+		"/moved":     models.LinkStatusMoved,
+		"/temporary": models.LinkStatusRedirected,
+		"/error":     models.LinkStatusError,
+	}
+	for path := range want {
+		if err := db.Create(&models.Bookmark{UserID: 1, Title: path, URL: server.URL + path}).Error; err != nil {
+			t.Fatalf("failed to seed bookmark: %v", err)
+		}
+	}
+	// A bookmark that has failed twice before is escalated to broken
+	if err := db.Create(&models.Bookmark{UserID: 1, Title: "flaky", URL: server.URL + "/error?flaky", LinkFailureCount: 2}).Error; err != nil {
+		t.Fatalf("failed to seed bookmark: %v", err)
+	}
+
+	checker := NewLinkChecker(db, LinkCheckerOptions{Concurrency: 2})
+	checked, err := checker.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if checked != len(want)+1 {
+		t.Fatalf("expected %d bookmarks checked, got %d", len(want)+1, checked)
+	}
+
+	var bookmarks []models.Bookmark
+	if err := db.Find(&bookmarks).Error; err != nil {
+		t.Fatalf("failed to reload bookmarks: %v", err)
+	}
+	for _, bookmark := range bookmarks {
+		expected, ok := want[bookmark.Title]
+		if !ok {
+			expected = models.LinkStatusBroken
+		}
+		if bookmark.LinkStatus != expected {
+			t.Fatalf("%s: expected status %q, got %q", bookmark.Title, expected, bookmark.LinkStatus)
+		}
+		if bookmark.LinkCheckedAt == nil {
+			t.Fatalf("%s: expected link_checked_at to be set", bookmark.Title)
+		}
+		if bookmark.LinkStatus == models.LinkStatusMoved && bookmark.RedirectURL != server.URL+"/ok" {
+			t.Fatalf("unexpected redirect target: %s", bookmark.RedirectURL)
+		}
+	}
+
+	var history int64
+	db.Model(&models.BookmarkLinkCheck{}).Count(&history)
+	if history != int64(len(bookmarks)) {
+		t.Fatalf("expected %d history rows, got %d", len(bookmarks), history)
+	}
+
+	// Everything was just checked, so a second sweep has nothing to do
+	if checked, _ := checker.RunOnce(context.Background()); checked != 0 {
+		t.Fatalf("expected no bookmarks due, got %d", checked)
+	}
+}
+
+func TestHostLimiter_SpacesRequests(t *testing.T) {
+	limiter := newHostLimiter(50 * time.Millisecond)
+	ctx := context.Background()
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := limiter.wait(ctx, "example.com"); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Fatalf("expected requests to be spaced out, took %v", elapsed)
+	}
+
+	// Other hosts are not delayed
+	start = time.Now()
+	if err := limiter.wait(ctx, "example.org"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 40*time.Millisecond {
+		t.Fatalf("unexpected delay for a different host: %v", elapsed)
+	}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/smtp"
+	"os"
+)
+
+// ErrSMTPNotConfigured is returned when the SMTP_* environment is incomplete
+var ErrSMTPNotConfigured = errors.New("SMTP configuration not complete")
+
+// SMTPConfig holds the outgoing mail settings
+type SMTPConfig struct {
+	Host      string
+	Port      string
+	Username  string
+	Password  string
+	FromEmail string
+	FromName  string
+}
+
+// SMTPConfigFromEnv reads the SMTP_* environment variables
+func SMTPConfigFromEnv() SMTPConfig {
+	return SMTPConfig{
+		Host:      os.Getenv("SMTP_HOST"),
+		Port:      os.Getenv("SMTP_PORT"),
+		Username:  os.Getenv("SMTP_USERNAME"),
+		Password:  os.Getenv("SMTP_PASSWORD"),
+		FromEmail: os.Getenv("SMTP_FROM_EMAIL"),
+		FromName:  os.Getenv("SMTP_FROM_NAME"),
+	}
+}
+
+// Complete reports whether enough is set to send mail
+func (c SMTPConfig) Complete() bool {
+	return c.Host != "" && c.Username != "" && c.Password != "" && c.FromEmail != ""
+}
+
+// Mailer sends a plain-text email
+type Mailer func(to, subject, body string) error
+
+// SendMail sends a plain-text email with the SMTP settings from the environment
+func SendMail(to, subject, body string) error {
+	cfg := SMTPConfigFromEnv()
+	if !cfg.Complete() {
+		return ErrSMTPNotConfigured
+	}
+
+	auth := smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	msg := fmt.Sprintf("From: %s <%s>\r\nTo: %s\r\nSubject: %s\r\n\r\n%s",
+		cfg.FromName, cfg.FromEmail, to, subject, body)
+
+	addr := fmt.Sprintf("%s:%s", cfg.Host, cfg.Port)
+	return smtp.SendMail(addr, auth, cfg.FromEmail, []string{to}, []byte(msg))
+}
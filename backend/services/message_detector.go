@@ -4,6 +4,7 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"time"
 )
 
 // DetectedSuggestion represents a suggestion detected from message text.
@@ -26,11 +27,19 @@ var (
 	taskIntentRegex   = regexp.MustCompile(`(?i)(todo|to do|task|need to|should|must|remember to|follow up)`)
 	eventIntentRegex  = regexp.MustCompile(`(?i)(meeting|calendar|event|schedule|tomorrow|next week|deadline|at [0-9]{1,2}(:[0-9]{2})?\s?(am|pm)?)`)
 	searchIntentRegex = regexp.MustCompile(`(?i)(search for|track query|alert me for|watch for|monitor query)`)
+	intentPrefixRegex = regexp.MustCompile(`(?i)^(todo|to do|task|reminder|remember to|remind me to)\s*[:\-]?\s+`)
 )
 
 // DetectMessageContent inspects a message and returns suggestions, inferred attachments,
 // and whether the message appears sensitive.
 func DetectMessageContent(body string) ([]DetectedSuggestion, []DetectedAttachment, bool) {
+	return DetectMessageContentAt(body, time.Now(), time.UTC)
+}
+
+// DetectMessageContentAt is DetectMessageContent with task and event
+// suggestions parsed by the quick-add parser, so dates are resolved from now
+// in the reader's timezone.
+func DetectMessageContentAt(body string, now time.Time, loc *time.Location) ([]DetectedSuggestion, []DetectedAttachment, bool) {
 	trimmed := strings.TrimSpace(body)
 	if trimmed == "" {
 		return nil, nil, false
@@ -84,21 +93,15 @@ func DetectMessageContent(body string) ([]DetectedSuggestion, []DetectedAttachme
 
 	if taskIntentRegex.MatchString(trimmed) {
 		suggestions = append(suggestions, DetectedSuggestion{
-			Type: "create_task",
-			Payload: map[string]interface{}{
-				"title":     buildCompactTitle(trimmed, 80),
-				"from_text": trimmed,
-			},
+			Type:    "create_task",
+			Payload: quickAddPayload(trimmed, QuickAddTask, now, loc),
 		})
 	}
 
 	if eventIntentRegex.MatchString(trimmed) {
 		suggestions = append(suggestions, DetectedSuggestion{
-			Type: "create_event",
-			Payload: map[string]interface{}{
-				"title":     buildCompactTitle(trimmed, 80),
-				"from_text": trimmed,
-			},
+			Type:    "create_event",
+			Payload: quickAddPayload(trimmed, QuickAddEvent, now, loc),
 		})
 	}
 
@@ -130,6 +133,39 @@ func DetectMessageContent(body string) ([]DetectedSuggestion, []DetectedAttachme
 	return suggestions, attachments, isSensitive
 }
 
+// quickAddPayload builds a create_task or create_event payload. Whatever the
+// quick-add parser understands is filled in; the title falls back to the text.
+func quickAddPayload(text, kind string, now time.Time, loc *time.Location) map[string]interface{} {
+	payload := map[string]interface{}{
+		"title":     buildCompactTitle(text, 80),
+		"from_text": text,
+	}
+
+	parsed, err := ParseQuickAdd(intentPrefixRegex.ReplaceAllString(text, ""), kind, now, loc)
+	if err != nil {
+		return payload
+	}
+	payload["title"] = buildCompactTitle(parsed.Title, 80)
+	if parsed.DueDate != nil {
+		payload["due_date"] = parsed.DueDate
+	}
+	if parsed.StartTime != nil {
+		payload["start_time"] = parsed.StartTime
+		payload["end_time"] = parsed.EndTime
+		payload["all_day"] = parsed.AllDay
+	}
+	if parsed.Priority != "" {
+		payload["priority"] = parsed.Priority
+	}
+	if len(parsed.Tags) > 0 {
+		payload["tags"] = parsed.Tags
+	}
+	if parsed.Recurrence != "" {
+		payload["recurrence"] = parsed.Recurrence
+	}
+	return payload
+}
+
 func buildCompactTitle(input string, limit int) string {
 	s := strings.TrimSpace(input)
 	if len(s) <= limit {
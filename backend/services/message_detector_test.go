@@ -1,6 +1,11 @@
 package services
 
-import "testing"
+import (
+	"testing"
+	"time"
+
+	"github.com/trackeep/backend/models"
+)
 
 func TestDetectMessageContent_URLsAndSuggestions(t *testing.T) {
 	body := "Check this out https://github.com/trackeep/backend and video https://youtu.be/dQw4w9WgXcQ"
@@ -81,3 +86,33 @@ func TestDetectMessageContent_PasswordWarning(t *testing.T) {
 		t.Fatalf("expected move_to_password_vault suggestion")
 	}
 }
+
+func TestDetectMessageContentAt_ParsesDates(t *testing.T) {
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	suggestions, _, _ := DetectMessageContentAt("TODO: schedule meeting tomorrow at 10am to review the release plan !high", now, time.UTC)
+
+	byType := map[string]bool{}
+	for _, s := range suggestions {
+		byType[s.Type] = true
+	}
+	if !byType["create_task"] || !byType["create_event"] {
+		t.Fatalf("expected create_task and create_event suggestions, got %+v", suggestions)
+	}
+
+	for _, s := range suggestions {
+		switch s.Type {
+		case "create_task":
+			due, ok := s.Payload["due_date"].(*time.Time)
+			if !ok || !due.Equal(time.Date(2026, 3, 5, 10, 0, 0, 0, time.UTC)) || s.Payload["priority"] != models.TaskPriorityHigh {
+				t.Fatalf("unexpected task payload: %+v", s.Payload)
+			}
+			if s.Payload["title"] != "schedule meeting to review the release plan" {
+				t.Fatalf("unexpected task title: %v", s.Payload["title"])
+			}
+		case "create_event":
+			if _, ok := s.Payload["start_time"].(*time.Time); !ok {
+				t.Fatalf("expected the event to have a start time: %+v", s.Payload)
+			}
+		}
+	}
+}
@@ -12,6 +12,8 @@ import (
 type WsEvent struct {
 	Type           string      `json:"type"`
 	ConversationID uint        `json:"conversation_id,omitempty"`
+	BoardID        uint        `json:"board_id,omitempty"`
+	Document       string      `json:"document,omitempty"`
 	Data           interface{} `json:"data,omitempty"`
 	Timestamp      time.Time   `json:"timestamp"`
 }
@@ -22,6 +24,11 @@ type MessagesWSClient struct {
 	Conn          *websocket.Conn
 	Send          chan []byte
 	Conversations map[uint]struct{}
+	Boards        map[uint]struct{}
+	Documents     map[string]struct{}
+
+	// closed is set, under the hub lock, once Send has been closed
+	closed bool
 }
 
 // MessagesHub coordinates room-based websocket fanout.
@@ -29,6 +36,9 @@ type MessagesHub struct {
 	mu                  sync.RWMutex
 	conversationClients map[uint]map[*MessagesWSClient]struct{}
 	clientConversations map[*MessagesWSClient]map[uint]struct{}
+	boardClients        map[uint]map[*MessagesWSClient]struct{}
+	documentClients     map[string]map[*MessagesWSClient]struct{}
+	userClients         map[uint]map[*MessagesWSClient]struct{}
 }
 
 var defaultMessagesHub = NewMessagesHub()
@@ -43,6 +53,9 @@ func NewMessagesHub() *MessagesHub {
 	return &MessagesHub{
 		conversationClients: make(map[uint]map[*MessagesWSClient]struct{}),
 		clientConversations: make(map[*MessagesWSClient]map[uint]struct{}),
+		boardClients:        make(map[uint]map[*MessagesWSClient]struct{}),
+		documentClients:     make(map[string]map[*MessagesWSClient]struct{}),
+		userClients:         make(map[uint]map[*MessagesWSClient]struct{}),
 	}
 }
 
@@ -53,6 +66,8 @@ func NewWSClient(userID uint, conn *websocket.Conn) *MessagesWSClient {
 		Conn:          conn,
 		Send:          make(chan []byte, 128),
 		Conversations: make(map[uint]struct{}),
+		Boards:        make(map[uint]struct{}),
+		Documents:     make(map[string]struct{}),
 	}
 }
 
@@ -94,11 +109,16 @@ func (h *MessagesHub) RemoveClientFromConversation(client *MessagesWSClient, con
 	delete(client.Conversations, conversationID)
 }
 
-// RemoveClient fully unregisters a client from all rooms.
+// RemoveClient fully unregisters a client from all rooms. It may be called
+// more than once for the same client.
 func (h *MessagesHub) RemoveClient(client *MessagesWSClient) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	if client.closed {
+		return
+	}
+
 	if convs, exists := h.clientConversations[client]; exists {
 		for convID := range convs {
 			if clients, ok := h.conversationClients[convID]; ok {
@@ -110,10 +130,214 @@ func (h *MessagesHub) RemoveClient(client *MessagesWSClient) {
 		}
 		delete(h.clientConversations, client)
 	}
+	for boardID := range client.Boards {
+		if clients, ok := h.boardClients[boardID]; ok {
+			delete(clients, client)
+			if len(clients) == 0 {
+				delete(h.boardClients, boardID)
+			}
+		}
+	}
+	client.Boards = make(map[uint]struct{})
+	for document := range client.Documents {
+		if clients, ok := h.documentClients[document]; ok {
+			delete(clients, client)
+			if len(clients) == 0 {
+				delete(h.documentClients, document)
+			}
+		}
+	}
+	client.Documents = make(map[string]struct{})
+	if clients, ok := h.userClients[client.UserID]; ok {
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(h.userClients, client.UserID)
+		}
+	}
 
+	client.closed = true
 	close(client.Send)
 }
 
+// send queues raw for one client. A client that cannot keep up is dropped,
+// and one that has already been removed is skipped.
+func (h *MessagesHub) send(client *MessagesWSClient, raw []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if client.closed {
+		return
+	}
+	select {
+	case client.Send <- raw:
+	default:
+		go h.RemoveClient(client)
+	}
+}
+
+// AddClient registers a connection for events addressed to its user, such
+// as notifications.
+func (h *MessagesHub) AddClient(client *MessagesWSClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, exists := h.userClients[client.UserID]; !exists {
+		h.userClients[client.UserID] = make(map[*MessagesWSClient]struct{})
+	}
+	h.userClients[client.UserID][client] = struct{}{}
+}
+
+// NotifyUser emits an event to every connection of one user.
+func (h *MessagesHub) NotifyUser(userID uint, eventType string, data interface{}) {
+	event := WsEvent{
+		Type:      eventType,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	h.mu.RLock()
+	clients := make([]*MessagesWSClient, 0, len(h.userClients[userID]))
+	for client := range h.userClients[userID] {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		h.send(client, raw)
+	}
+}
+
+// AddClientToBoard subscribes a client to live updates of a kanban board.
+func (h *MessagesHub) AddClientToBoard(client *MessagesWSClient, boardID uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, exists := h.boardClients[boardID]; !exists {
+		h.boardClients[boardID] = make(map[*MessagesWSClient]struct{})
+	}
+	h.boardClients[boardID][client] = struct{}{}
+	client.Boards[boardID] = struct{}{}
+}
+
+// RemoveClientFromBoard unsubscribes a client from one board.
+func (h *MessagesHub) RemoveClientFromBoard(client *MessagesWSClient, boardID uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if clients, exists := h.boardClients[boardID]; exists {
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(h.boardClients, boardID)
+		}
+	}
+	delete(client.Boards, boardID)
+}
+
+// BroadcastBoard emits an event to all clients viewing one board.
+func (h *MessagesHub) BroadcastBoard(boardID uint, eventType string, data interface{}) {
+	event := WsEvent{
+		Type:      eventType,
+		BoardID:   boardID,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	h.mu.RLock()
+	clients := make([]*MessagesWSClient, 0, len(h.boardClients[boardID]))
+	for client := range h.boardClients[boardID] {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		h.send(client, raw)
+	}
+}
+
+// AddClientToDocument subscribes a client to a collaborative editing
+// session, keyed like "note:12".
+func (h *MessagesHub) AddClientToDocument(client *MessagesWSClient, document string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, exists := h.documentClients[document]; !exists {
+		h.documentClients[document] = make(map[*MessagesWSClient]struct{})
+	}
+	h.documentClients[document][client] = struct{}{}
+	client.Documents[document] = struct{}{}
+}
+
+// RemoveClientFromDocument unsubscribes a client from one editing session.
+func (h *MessagesHub) RemoveClientFromDocument(client *MessagesWSClient, document string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if clients, exists := h.documentClients[document]; exists {
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(h.documentClients, document)
+		}
+	}
+	delete(client.Documents, document)
+}
+
+// BroadcastDocument emits an event to all clients editing one document,
+// except the one that caused it when except is set.
+func (h *MessagesHub) BroadcastDocument(document string, eventType string, data interface{}, except *MessagesWSClient) {
+	event := WsEvent{
+		Type:      eventType,
+		Document:  document,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	h.mu.RLock()
+	clients := make([]*MessagesWSClient, 0, len(h.documentClients[document]))
+	for client := range h.documentClients[document] {
+		if client != except {
+			clients = append(clients, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		h.send(client, raw)
+	}
+}
+
+// SendToClient emits a document event to one connection, unless it has
+// already been removed from the hub.
+func (h *MessagesHub) SendToClient(client *MessagesWSClient, document string, eventType string, data interface{}) {
+	event := WsEvent{
+		Type:      eventType,
+		Document:  document,
+		Data:      data,
+		Timestamp: time.Now(),
+	}
+
+	raw, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+
+	h.send(client, raw)
+}
+
 // Broadcast emits an event to all clients in one conversation room.
 func (h *MessagesHub) Broadcast(conversationID uint, eventType string, data interface{}) {
 	event := WsEvent{
@@ -129,15 +353,14 @@ func (h *MessagesHub) Broadcast(conversationID uint, eventType string, data inte
 	}
 
 	h.mu.RLock()
-	clients := h.conversationClients[conversationID]
+	clients := make([]*MessagesWSClient, 0, len(h.conversationClients[conversationID]))
+	for client := range h.conversationClients[conversationID] {
+		clients = append(clients, client)
+	}
 	h.mu.RUnlock()
 
-	for client := range clients {
-		select {
-		case client.Send <- raw:
-		default:
-			go h.RemoveClient(client)
-		}
+	for _, client := range clients {
+		h.send(client, raw)
 	}
 }
 
@@ -156,17 +379,15 @@ func (h *MessagesHub) SendToUser(conversationID, userID uint, eventType string,
 	}
 
 	h.mu.RLock()
-	clients := h.conversationClients[conversationID]
+	clients := make([]*MessagesWSClient, 0, len(h.conversationClients[conversationID]))
+	for client := range h.conversationClients[conversationID] {
+		if client.UserID == userID {
+			clients = append(clients, client)
+		}
+	}
 	h.mu.RUnlock()
 
-	for client := range clients {
-		if client.UserID != userID {
-			continue
-		}
-		select {
-		case client.Send <- raw:
-		default:
-			go h.RemoveClient(client)
-		}
+	for _, client := range clients {
+		h.send(client, raw)
 	}
 }
@@ -0,0 +1,46 @@
+package services
+
+import "testing"
+
+func TestMessagesHubDropsSlowClientOnce(t *testing.T) {
+	hub := NewMessagesHub()
+	client := NewWSClient(1, nil)
+	hub.AddClient(client)
+	hub.AddClientToBoard(client, 7)
+
+	// Fill the buffer so the next event finds it full
+	for i := 0; i < cap(client.Send); i++ {
+		client.Send <- []byte("{}")
+	}
+	hub.BroadcastBoard(7, "board.updated", nil)
+
+	// The connection's own cleanup can race the hub dropping it
+	hub.RemoveClient(client)
+	hub.RemoveClient(client)
+	hub.BroadcastBoard(7, "board.updated", nil)
+
+	drained := 0
+	for range client.Send {
+		drained++
+	}
+	if drained != cap(client.Send) {
+		t.Fatalf("expected %d queued events, got %d", cap(client.Send), drained)
+	}
+}
+
+func TestMessagesHubNotifyUserSkipsRemovedClient(t *testing.T) {
+	hub := NewMessagesHub()
+	client := NewWSClient(1, nil)
+	hub.AddClient(client)
+
+	hub.NotifyUser(1, "reminder.due", nil)
+	if len(client.Send) != 1 {
+		t.Fatalf("expected the notification to be queued, got %d events", len(client.Send))
+	}
+
+	// A scheduler holding the client from before it disconnected must not
+	// send on the closed channel
+	hub.RemoveClient(client)
+	hub.NotifyUser(1, "reminder.due", nil)
+	hub.send(client, []byte("{}"))
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"crypto/md5"
 	"fmt"
 	"io"
@@ -22,21 +23,69 @@ type WebsiteMetadata struct {
 	PublishedAt string `json:"published_at"`
 }
 
-// FetchWebsiteMetadata extracts metadata from a URL
-func FetchWebsiteMetadata(targetURL string) (*WebsiteMetadata, error) {
+// PageResponse is the result of fetching a page with FetchPage
+type PageResponse struct {
+	StatusCode  int
+	Status      string
+	Proto       string
+	FinalURL    string
+	Redirects   []Redirect
+	ContentType string
+	Header      http.Header
+	Body        []byte
+	FetchedAt   time.Time
+}
+
+// Redirect is one hop of a redirect chain followed by FetchPage
+type Redirect struct {
+	StatusCode int    `json:"status_code"`
+	From       string `json:"from"`
+	To         string `json:"to"`
+}
+
+const (
+	// maxRedirects limits the redirect chain followed by FetchPage
+	maxRedirects = 10
+
+	// maxPageBodySize limits how much of a response body FetchPage reads
+	maxPageBodySize = 25 << 20 // 25MB
+)
+
+// FetchPage requests a URL with the browser-like headers used for metadata
+// extraction, recording the redirect chain. The body is only read when
+// readBody is set.
+func FetchPage(ctx context.Context, targetURL string, readBody bool) (*PageResponse, error) {
+	return FetchPageWithHeaders(ctx, targetURL, readBody, nil)
+}
+
+// FetchPageWithHeaders is FetchPage with extra request headers, for example
+// for conditional requests. Headers given here replace the defaults.
+func FetchPageWithHeaders(ctx context.Context, targetURL string, readBody bool, header http.Header) (*PageResponse, error) {
 	// Parse URL to ensure it's valid
-	_, err := url.Parse(targetURL)
-	if err != nil {
+	if _, err := url.Parse(targetURL); err != nil {
 		return nil, fmt.Errorf("invalid URL: %w", err)
 	}
 
+	var redirects []Redirect
+
 	// Create HTTP client with timeout
 	client := &http.Client{
 		Timeout: 10 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			hop := Redirect{From: via[len(via)-1].URL.String(), To: req.URL.String()}
+			if req.Response != nil {
+				hop.StatusCode = req.Response.StatusCode
+			}
+			redirects = append(redirects, hop)
+			return nil
+		},
 	}
 
 	// Make request
-	req, err := http.NewRequest("GET", targetURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", targetURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -44,6 +93,9 @@ func FetchWebsiteMetadata(targetURL string) (*WebsiteMetadata, error) {
 	// Set user agent to avoid being blocked
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
 	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
+	for key, values := range header {
+		req.Header[http.CanonicalHeaderKey(key)] = values
+	}
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -51,16 +103,41 @@ func FetchWebsiteMetadata(targetURL string) (*WebsiteMetadata, error) {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, resp.Status)
+	page := &PageResponse{
+		StatusCode:  resp.StatusCode,
+		Status:      resp.Status,
+		Proto:       resp.Proto,
+		FinalURL:    resp.Request.URL.String(),
+		Redirects:   redirects,
+		ContentType: resp.Header.Get("Content-Type"),
+		Header:      resp.Header,
+		FetchedAt:   time.Now().UTC(),
 	}
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	if readBody {
+		// Read response body
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageBodySize))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response body: %w", err)
+		}
+		page.Body = body
+	}
+
+	return page, nil
+}
+
+// FetchWebsiteMetadata extracts metadata from a URL
+func FetchWebsiteMetadata(targetURL string) (*WebsiteMetadata, error) {
+	page, err := FetchPage(context.Background(), targetURL, true)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, err
+	}
+
+	if page.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", page.StatusCode, http.StatusText(page.StatusCode))
 	}
 
+	body := page.Body
 	content := string(body)
 	metadata := &WebsiteMetadata{}
 
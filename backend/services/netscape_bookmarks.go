@@ -0,0 +1,300 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ImportedBookmark is a bookmark read from an external export before it is stored
+type ImportedBookmark struct {
+	URL         string     `json:"url"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Tags        []string   `json:"tags"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	IsRead      bool       `json:"is_read"`
+	ReadAt      *time.Time `json:"read_at,omitempty"`
+	IsFavorite  bool       `json:"is_favorite"`
+}
+
+// netscapeRootFolders are browser container folders that carry no meaning as tags
+var netscapeRootFolders = map[string]bool{
+	"personal_toolbar_folder":  true,
+	"unfiled_bookmarks_folder": true,
+}
+
+// ParseNetscapeBookmarks parses a Netscape bookmark file (the bookmarks.html
+// format exported by Chrome, Firefox, Safari and Edge). Folders become tags and
+// ADD_DATE is preserved as the creation time.
+func ParseNetscapeBookmarks(r io.Reader) ([]ImportedBookmark, error) {
+	tokenizer := xhtml.NewTokenizer(r)
+
+	var (
+		bookmarks     []ImportedBookmark
+		folders       []string // folder stack; "" for ignored containers
+		pendingFolder *string  // folder header waiting for its <DL>
+		inFolderTitle bool
+		skipFolder    bool
+		folderTitle   strings.Builder
+		current       *ImportedBookmark
+		inAnchor      bool
+		anchorText    strings.Builder
+		descTarget    int = -1
+		description   strings.Builder
+	)
+
+	flushDescription := func() {
+		if descTarget >= 0 {
+			bookmarks[descTarget].Description = strings.TrimSpace(description.String())
+			descTarget = -1
+			description.Reset()
+		}
+	}
+
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case xhtml.ErrorToken:
+			if err := tokenizer.Err(); err != io.EOF {
+				return nil, fmt.Errorf("failed to parse bookmark file: %w", err)
+			}
+			flushDescription()
+			return bookmarks, nil
+
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.H3:
+				flushDescription()
+				inFolderTitle = true
+				skipFolder = false
+				folderTitle.Reset()
+				for _, attr := range token.Attr {
+					if netscapeRootFolders[strings.ToLower(attr.Key)] {
+						skipFolder = true
+					}
+				}
+			case atom.Dl:
+				flushDescription()
+				name := ""
+				if pendingFolder != nil {
+					name = *pendingFolder
+					pendingFolder = nil
+				}
+				folders = append(folders, name)
+			case atom.A:
+				flushDescription()
+				current = &ImportedBookmark{}
+				inAnchor = true
+				anchorText.Reset()
+				for _, attr := range token.Attr {
+					switch strings.ToLower(attr.Key) {
+					case "href":
+						current.URL = strings.TrimSpace(attr.Val)
+					case "add_date":
+						current.CreatedAt = parseNetscapeTimestamp(attr.Val)
+					case "tags":
+						current.Tags = append(current.Tags, splitTagList(attr.Val)...)
+					}
+				}
+			case atom.Dd:
+				if len(bookmarks) > 0 && current == nil {
+					flushDescription()
+					descTarget = len(bookmarks) - 1
+				}
+			case atom.Dt:
+				flushDescription()
+			}
+
+		case xhtml.EndTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.H3:
+				if inFolderTitle {
+					name := strings.TrimSpace(folderTitle.String())
+					if skipFolder {
+						name = ""
+					}
+					pendingFolder = &name
+					inFolderTitle = false
+				}
+			case atom.Dl:
+				flushDescription()
+				if len(folders) > 0 {
+					folders = folders[:len(folders)-1]
+				}
+			case atom.A:
+				if current != nil {
+					current.Title = strings.TrimSpace(anchorText.String())
+					if current.Title == "" {
+						current.Title = current.URL
+					}
+					for _, folder := range folders {
+						if folder != "" {
+							current.Tags = append(current.Tags, folder)
+						}
+					}
+					current.Tags = uniqueTags(current.Tags)
+					if current.URL != "" && !strings.HasPrefix(strings.ToLower(current.URL), "javascript:") {
+						bookmarks = append(bookmarks, *current)
+					}
+					current = nil
+					inAnchor = false
+				}
+			}
+
+		case xhtml.TextToken:
+			text := string(tokenizer.Text())
+			switch {
+			case inFolderTitle:
+				folderTitle.WriteString(text)
+			case inAnchor:
+				anchorText.WriteString(text)
+			case descTarget >= 0:
+				description.WriteString(text)
+			}
+		}
+	}
+}
+
+// WriteNetscapeBookmarks writes bookmarks as a Netscape bookmark file that any
+// browser can import. Each bookmark is filed under a folder named after its
+// first tag (alphabetically) and carries all of its tags in the TAGS attribute.
+func WriteNetscapeBookmarks(w io.Writer, bookmarks []models.Bookmark) error {
+	buf := bufio.NewWriter(w)
+
+	folders := make(map[string][]models.Bookmark)
+	var folderNames []string
+	var unfiled []models.Bookmark
+
+	for _, bookmark := range bookmarks {
+		tagNames := bookmarkTagNames(bookmark)
+		if len(tagNames) == 0 {
+			unfiled = append(unfiled, bookmark)
+			continue
+		}
+		if _, exists := folders[tagNames[0]]; !exists {
+			folderNames = append(folderNames, tagNames[0])
+		}
+		folders[tagNames[0]] = append(folders[tagNames[0]], bookmark)
+	}
+	sort.Strings(folderNames)
+
+	buf.WriteString("<!DOCTYPE NETSCAPE-Bookmark-file-1>\n")
+	buf.WriteString("<!-- This is an automatically generated file.\n     It will be read and overwritten.\n     DO NOT EDIT! -->\n")
+	buf.WriteString("<META HTTP-EQUIV=\"Content-Type\" CONTENT=\"text/html; charset=UTF-8\">\n")
+	buf.WriteString("<TITLE>Bookmarks</TITLE>\n<H1>Bookmarks</H1>\n<DL><p>\n")
+
+	for _, name := range folderNames {
+		entries := folders[name]
+		fmt.Fprintf(buf, "    <DT><H3 ADD_DATE=\"%d\">%s</H3>\n    <DL><p>\n", earliestCreatedAt(entries), html.EscapeString(name))
+		for _, bookmark := range entries {
+			writeNetscapeEntry(buf, bookmark, "        ")
+		}
+		buf.WriteString("    </DL><p>\n")
+	}
+	for _, bookmark := range unfiled {
+		writeNetscapeEntry(buf, bookmark, "    ")
+	}
+
+	buf.WriteString("</DL><p>\n")
+	return buf.Flush()
+}
+
+func writeNetscapeEntry(w *bufio.Writer, bookmark models.Bookmark, indent string) {
+	title := bookmark.Title
+	if title == "" {
+		title = bookmark.URL
+	}
+
+	fmt.Fprintf(w, "%s<DT><A HREF=\"%s\" ADD_DATE=\"%d\" LAST_MODIFIED=\"%d\"",
+		indent, html.EscapeString(bookmark.URL), bookmark.CreatedAt.Unix(), bookmark.UpdatedAt.Unix())
+	if tagNames := bookmarkTagNames(bookmark); len(tagNames) > 0 {
+		fmt.Fprintf(w, " TAGS=\"%s\"", html.EscapeString(strings.Join(tagNames, ",")))
+	}
+	if bookmark.Favicon != "" && strings.HasPrefix(bookmark.Favicon, "data:") {
+		fmt.Fprintf(w, " ICON=\"%s\"", html.EscapeString(bookmark.Favicon))
+	}
+	fmt.Fprintf(w, ">%s</A>\n", html.EscapeString(title))
+
+	if bookmark.Description != "" {
+		fmt.Fprintf(w, "%s<DD>%s\n", indent, html.EscapeString(bookmark.Description))
+	}
+}
+
+func bookmarkTagNames(bookmark models.Bookmark) []string {
+	names := make([]string, 0, len(bookmark.Tags))
+	for _, tag := range bookmark.Tags {
+		if tag.Name != "" {
+			names = append(names, tag.Name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func earliestCreatedAt(bookmarks []models.Bookmark) int64 {
+	var earliest int64
+	for _, bookmark := range bookmarks {
+		if ts := bookmark.CreatedAt.Unix(); earliest == 0 || ts < earliest {
+			earliest = ts
+		}
+	}
+	return earliest
+}
+
+// parseNetscapeTimestamp parses ADD_DATE values, which are seconds since the
+// epoch in most exports but milli- or microseconds in some browsers.
+func parseNetscapeTimestamp(value string) *time.Time {
+	raw, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || raw <= 0 {
+		return nil
+	}
+
+	var t time.Time
+	switch {
+	case raw > 1e14:
+		t = time.UnixMicro(raw)
+	case raw > 1e11:
+		t = time.UnixMilli(raw)
+	default:
+		t = time.Unix(raw, 0)
+	}
+	t = t.UTC()
+	return &t
+}
+
+func splitTagList(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func uniqueTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, tag)
+	}
+	return result
+}
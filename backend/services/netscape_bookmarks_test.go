@@ -0,0 +1,113 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trackeep/backend/models"
+)
+
+const sampleNetscapeExport = `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1700000000" PERSONAL_TOOLBAR_FOLDER="true">Bookmarks bar</H3>
+    <DL><p>
+        <DT><H3 ADD_DATE="1700000000">Dev</H3>
+        <DL><p>
+            <DT><A HREF="https://go.dev/" ADD_DATE="1609459200" TAGS="golang">The Go Programming Language</A>
+            <DD>Go home page
+            <DT><H3>Testing</H3>
+            <DL><p>
+                <DT><A HREF="https://pkg.go.dev/testing" ADD_DATE="1609459200000">testing package</A>
+            </DL><p>
+        </DL><p>
+        <DT><A HREF="javascript:alert(1)">Bookmarklet</A>
+    </DL><p>
+    <DT><A HREF="https://example.com/">Example &amp; Co</A>
+</DL><p>
+`
+
+func TestParseNetscapeBookmarks_FoldersAndDates(t *testing.T) {
+	items, err := ParseNetscapeBookmarks(strings.NewReader(sampleNetscapeExport))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(items) != 3 {
+		t.Fatalf("expected 3 bookmarks, got %d", len(items))
+	}
+
+	goDev := items[0]
+	if goDev.Title != "The Go Programming Language" || goDev.Description != "Go home page" {
+		t.Fatalf("unexpected first bookmark: %+v", goDev)
+	}
+	if strings.Join(goDev.Tags, ",") != "golang,Dev" {
+		t.Fatalf("unexpected tags: %v", goDev.Tags)
+	}
+	if goDev.CreatedAt == nil || !goDev.CreatedAt.Equal(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected created at: %v", goDev.CreatedAt)
+	}
+
+	nested := items[1]
+	if strings.Join(nested.Tags, ",") != "Dev,Testing" {
+		t.Fatalf("unexpected nested tags: %v", nested.Tags)
+	}
+	if nested.CreatedAt == nil || nested.CreatedAt.Year() != 2021 {
+		t.Fatalf("expected millisecond ADD_DATE to be parsed, got %v", nested.CreatedAt)
+	}
+
+	root := items[2]
+	if root.Title != "Example & Co" || len(root.Tags) != 0 {
+		t.Fatalf("unexpected root bookmark: %+v", root)
+	}
+}
+
+func TestWriteNetscapeBookmarks_RoundTrip(t *testing.T) {
+	created := time.Date(2020, 5, 17, 10, 0, 0, 0, time.UTC)
+	bookmarks := []models.Bookmark{
+		{
+			URL:         "https://go.dev/",
+			Title:       "Go <home>",
+			Description: "Official site",
+			CreatedAt:   created,
+			UpdatedAt:   created,
+			Tags:        []models.Tag{{Name: "golang"}, {Name: "dev"}},
+		},
+		{
+			URL:       "https://example.com/",
+			Title:     "Example",
+			CreatedAt: created,
+			UpdatedAt: created,
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteNetscapeBookmarks(&buf, bookmarks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	items, err := ParseNetscapeBookmarks(&buf)
+	if err != nil {
+		t.Fatalf("failed to parse exported file: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 bookmarks, got %d", len(items))
+	}
+
+	if items[0].Title != "Go <home>" || items[0].Description != "Official site" {
+		t.Fatalf("unexpected first bookmark: %+v", items[0])
+	}
+	if strings.Join(items[0].Tags, ",") != "dev,golang" {
+		t.Fatalf("unexpected tags after round trip: %v", items[0].Tags)
+	}
+	if items[0].CreatedAt == nil || !items[0].CreatedAt.Equal(created) {
+		t.Fatalf("expected created at to survive round trip, got %v", items[0].CreatedAt)
+	}
+	if len(items[1].Tags) != 0 {
+		t.Fatalf("expected untagged bookmark at root, got %v", items[1].Tags)
+	}
+}
@@ -0,0 +1,637 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/utils"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidLinkTarget = errors.New("invalid link target")
+
+// Knowledge graph edge types
+const (
+	GraphEdgeLink     = "link"
+	GraphEdgeParent   = "parent"
+	GraphEdgeWikiLink = "wiki_link"
+)
+
+const (
+	defaultGraphDepth = 2
+	maxGraphDepth     = 5
+	// minMentionLength keeps very short titles out of unlinked mentions,
+	// where they would match almost every note
+	minMentionLength = 3
+	// maxLinkContext bounds the context stored with a link, in bytes
+	maxLinkContext = 280
+)
+
+var (
+	wikilinkPattern     = regexp.MustCompile(`\[\[([^\[\]\n]+)\]\]`)
+	wikilinkTypePattern = regexp.MustCompile(`(?i)^(note|wiki|bookmark|task)\s*:\s*(.+)$`)
+)
+
+// linkTargetTypes are what wikilinks can point at, in the order an untyped
+// [[Title]] is looked up
+var linkTargetTypes = []string{models.LinkTargetNote, models.LinkTargetWiki, models.LinkTargetBookmark, models.LinkTargetTask}
+
+func linkTargetModel(targetType string) (interface{}, bool) {
+	switch targetType {
+	case models.LinkTargetNote:
+		return &models.Note{}, true
+	case models.LinkTargetWiki:
+		return &models.WikiPage{}, true
+	case models.LinkTargetBookmark:
+		return &models.Bookmark{}, true
+	case models.LinkTargetTask:
+		return &models.Task{}, true
+	}
+	return nil, false
+}
+
+// NoteLinkService maintains the wikilink index of notes and the knowledge
+// graph built from it
+type NoteLinkService struct {
+	db *gorm.DB
+}
+
+// NewNoteLinkService creates a new note link service
+func NewNoteLinkService(db *gorm.DB) *NoteLinkService {
+	return &NoteLinkService{db: db}
+}
+
+// wikilink is one [[link]] found in a note
+type wikilink struct {
+	target  string
+	alias   string
+	context string
+}
+
+// parseWikilinks finds [[Title]], [[Title|alias]], [[Title#heading]] and
+// [[type:ID]] links. Typed targets are normalized to "type:rest".
+func parseWikilinks(content string) []wikilink {
+	var links []wikilink
+	for _, match := range wikilinkPattern.FindAllStringSubmatchIndex(content, -1) {
+		target, alias, _ := strings.Cut(content[match[2]:match[3]], "|")
+		if i := strings.IndexAny(target, "#^"); i >= 0 {
+			target = target[:i]
+		}
+		target = strings.TrimSpace(target)
+		if parts := wikilinkTypePattern.FindStringSubmatch(target); parts != nil {
+			target = strings.ToLower(parts[1]) + ":" + strings.TrimSpace(parts[2])
+		}
+		if target == "" {
+			continue
+		}
+		links = append(links, wikilink{
+			target:  target,
+			alias:   strings.TrimSpace(alias),
+			context: linkContext(content, match[0], match[1]),
+		})
+	}
+	return links
+}
+
+// linkContext returns the line around content[start:end], cut down to
+// maxLinkContext bytes around the match
+func linkContext(content string, start, end int) string {
+	lineStart := strings.LastIndex(content[:start], "\n") + 1
+	lineEnd := len(content)
+	if i := strings.IndexByte(content[end:], '\n'); i >= 0 {
+		lineEnd = end + i
+	}
+	margin := max((maxLinkContext-(end-start))/2, 0)
+	from, to := max(lineStart, start-margin), min(lineEnd, end+margin)
+	for from < start && !utf8.RuneStart(content[from]) {
+		from++
+	}
+	for to > end && to < len(content) && !utf8.RuneStart(content[to]) {
+		to--
+	}
+	return strings.TrimSpace(content[from:to])
+}
+
+// Reindex rebuilds the links of a note from its content and resolves links
+// elsewhere that were waiting for a note with its title. The content of
+// encrypted notes is not read, so they have no outgoing links.
+func (s *NoteLinkService) Reindex(note *models.Note) error {
+	if err := s.db.Where("note_id = ?", note.ID).Delete(&models.NoteLink{}).Error; err != nil {
+		return err
+	}
+
+	if !note.IsEncrypted {
+		var links []*models.NoteLink
+		byTarget := make(map[string]*models.NoteLink)
+		for _, parsed := range parseWikilinks(note.Content) {
+			key := strings.ToLower(parsed.target)
+			if link, ok := byTarget[key]; ok {
+				link.Occurrences++
+				continue
+			}
+			targetType, targetID, err := s.resolve(note.UserID, parsed.target)
+			if err != nil {
+				return err
+			}
+			link := &models.NoteLink{
+				UserID:      note.UserID,
+				NoteID:      note.ID,
+				TargetType:  targetType,
+				TargetID:    targetID,
+				Target:      parsed.target,
+				Alias:       parsed.alias,
+				Context:     parsed.context,
+				Occurrences: 1,
+			}
+			byTarget[key] = link
+			links = append(links, link)
+		}
+		if len(links) > 0 {
+			if err := s.db.Create(&links).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	title := strings.ToLower(strings.TrimSpace(note.Title))
+	if title == "" || (note.IsEncrypted && utils.IsEncrypted(note.Title)) {
+		return nil
+	}
+	return s.db.Model(&models.NoteLink{}).
+		Where("user_id = ? AND target_id IS NULL AND target_type IN ?", note.UserID, []string{"", models.LinkTargetNote}).
+		Where("LOWER(target) IN ?", []string{title, models.LinkTargetNote + ":" + title}).
+		Updates(map[string]interface{}{"target_type": models.LinkTargetNote, "target_id": note.ID}).Error
+}
+
+// Forget drops the links written in a deleted note. Links to the note stay
+// behind unresolved, so they point at a new note with the same title.
+func (s *NoteLinkService) Forget(note *models.Note) error {
+	if err := s.db.Where("note_id = ?", note.ID).Delete(&models.NoteLink{}).Error; err != nil {
+		return err
+	}
+	return s.db.Model(&models.NoteLink{}).
+		Where("target_type = ? AND target_id = ?", models.LinkTargetNote, note.ID).
+		Update("target_id", nil).Error
+}
+
+// ReindexAll rebuilds the links of all the user's notes
+func (s *NoteLinkService) ReindexAll(userID uint) (int, error) {
+	var notes []models.Note
+	if err := s.db.Where("user_id = ?", userID).Order("id").Find(&notes).Error; err != nil {
+		return 0, err
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		service := NewNoteLinkService(tx)
+		for i := range notes {
+			if err := service.Reindex(&notes[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return len(notes), err
+}
+
+// resolve finds what a link points at. Links to something that does not
+// exist, or that belongs to someone else, stay unresolved.
+func (s *NoteLinkService) resolve(userID uint, target string) (string, *uint, error) {
+	types := linkTargetTypes
+	name := target
+	if parts := wikilinkTypePattern.FindStringSubmatch(target); parts != nil {
+		types = []string{strings.ToLower(parts[1])}
+		name = parts[2]
+		if id, err := strconv.ParseUint(name, 10, 32); err == nil {
+			titles, err := s.titles(types[0], userID, []uint{uint(id)})
+			if err != nil {
+				return "", nil, err
+			}
+			if _, ok := titles[uint(id)]; !ok {
+				return types[0], nil, nil
+			}
+			resolved := uint(id)
+			return types[0], &resolved, nil
+		}
+	}
+
+	for _, targetType := range types {
+		model, _ := linkTargetModel(targetType)
+		var ids []uint
+		if err := s.db.Model(model).Where("user_id = ? AND LOWER(title) = LOWER(?)", userID, name).
+			Order("id").Limit(1).Pluck("id", &ids).Error; err != nil {
+			return "", nil, err
+		}
+		if len(ids) > 0 {
+			return targetType, &ids[0], nil
+		}
+	}
+	if len(types) == 1 {
+		return types[0], nil, nil
+	}
+	return "", nil, nil
+}
+
+// titles returns the titles of the user's entities of one type by ID.
+// Encrypted note titles are not shown.
+func (s *NoteLinkService) titles(targetType string, userID uint, ids []uint) (map[uint]string, error) {
+	model, ok := linkTargetModel(targetType)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidLinkTarget, targetType)
+	}
+	titles := make(map[uint]string, len(ids))
+	if len(ids) == 0 {
+		return titles, nil
+	}
+	columns := []string{"id", "title"}
+	if targetType == models.LinkTargetNote {
+		columns = append(columns, "is_encrypted")
+	}
+	var rows []struct {
+		ID          uint
+		Title       string
+		IsEncrypted bool
+	}
+	if err := s.db.Model(model).Where("user_id = ? AND id IN ?", userID, ids).Select(columns).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if row.IsEncrypted && utils.IsEncrypted(row.Title) {
+			row.Title = "Encrypted note"
+		}
+		titles[row.ID] = row.Title
+	}
+	return titles, nil
+}
+
+// targetTitle returns the title of a link target the user owns
+func (s *NoteLinkService) targetTitle(userID uint, targetType string, targetID uint) (string, error) {
+	titles, err := s.titles(targetType, userID, []uint{targetID})
+	if err != nil {
+		return "", err
+	}
+	title, ok := titles[targetID]
+	if !ok {
+		return "", gorm.ErrRecordNotFound
+	}
+	return title, nil
+}
+
+// Links returns the links written in a note, with the titles of the
+// resolved targets
+func (s *NoteLinkService) Links(userID, noteID uint) ([]models.NoteLink, error) {
+	if _, err := s.targetTitle(userID, models.LinkTargetNote, noteID); err != nil {
+		return nil, err
+	}
+	var links []models.NoteLink
+	if err := s.db.Where("note_id = ?", noteID).Order("id").Find(&links).Error; err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string][]uint)
+	for _, link := range links {
+		if link.TargetID != nil {
+			ids[link.TargetType] = append(ids[link.TargetType], *link.TargetID)
+		}
+	}
+	titles := make(map[string]map[uint]string)
+	for targetType, targetIDs := range ids {
+		found, err := s.titles(targetType, userID, targetIDs)
+		if err != nil {
+			return nil, err
+		}
+		titles[targetType] = found
+	}
+	for i := range links {
+		if links[i].TargetID != nil {
+			links[i].TargetTitle = titles[links[i].TargetType][*links[i].TargetID]
+		}
+	}
+	return links, nil
+}
+
+// NoteBacklink is a note that links to something
+type NoteBacklink struct {
+	NoteID      uint      `json:"note_id"`
+	Title       string    `json:"title"`
+	Alias       string    `json:"alias,omitempty"`
+	Context     string    `json:"context"`
+	Occurrences int       `json:"occurrences"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Backlinks returns the user's notes that link to the target, most
+// recently updated first
+func (s *NoteLinkService) Backlinks(userID uint, targetType string, targetID uint) ([]NoteBacklink, error) {
+	if _, err := s.targetTitle(userID, targetType, targetID); err != nil {
+		return nil, err
+	}
+	backlinks := []NoteBacklink{}
+	err := s.db.Table("note_links").
+		Joins("JOIN notes ON notes.id = note_links.note_id AND notes.deleted_at IS NULL").
+		Where("note_links.user_id = ? AND note_links.target_type = ? AND note_links.target_id = ?", userID, targetType, targetID).
+		Select("note_links.note_id, notes.title, note_links.alias, note_links.context, note_links.occurrences, notes.updated_at").
+		Order("notes.updated_at DESC, note_links.note_id").Scan(&backlinks).Error
+	return backlinks, err
+}
+
+// UnlinkedMention is a note that mentions the target's title without
+// linking to it
+type UnlinkedMention struct {
+	NoteID      uint   `json:"note_id"`
+	Title       string `json:"title"`
+	Context     string `json:"context"`
+	Occurrences int    `json:"occurrences"`
+}
+
+// UnlinkedMentions finds the user's notes that contain the target's title
+// as a whole phrase, outside of links, and do not link to it yet
+func (s *NoteLinkService) UnlinkedMentions(userID uint, targetType string, targetID uint) ([]UnlinkedMention, error) {
+	title, err := s.targetTitle(userID, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	mentions := []UnlinkedMention{}
+	title = strings.TrimSpace(title)
+	if utf8.RuneCountInString(title) < minMentionLength {
+		return mentions, nil
+	}
+
+	query := s.db.Where("user_id = ? AND is_encrypted = ?", userID, false).
+		Where("LOWER(content) LIKE ?", "%"+strings.ToLower(title)+"%").
+		Where("id NOT IN (SELECT note_id FROM note_links WHERE target_type = ? AND target_id = ?)", targetType, targetID)
+	if targetType == models.LinkTargetNote {
+		query = query.Where("id <> ?", targetID)
+	}
+	var notes []models.Note
+	if err := query.Select("id", "title", "content").Order("updated_at DESC, id").Find(&notes).Error; err != nil {
+		return nil, err
+	}
+
+	pattern := regexp.MustCompile(`(?i)(?:^|[^\p{L}\p{N}_])(` + regexp.QuoteMeta(title) + `)(?:$|[^\p{L}\p{N}_])`)
+	for _, note := range notes {
+		// Blank out links so text inside them is not counted
+		content := wikilinkPattern.ReplaceAllStringFunc(note.Content, func(link string) string {
+			return strings.Repeat(" ", len(link))
+		})
+		matches := pattern.FindAllStringSubmatchIndex(content, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		mentions = append(mentions, UnlinkedMention{
+			NoteID:      note.ID,
+			Title:       note.Title,
+			Context:     linkContext(note.Content, matches[0][2], matches[0][3]),
+			Occurrences: len(matches),
+		})
+	}
+	return mentions, nil
+}
+
+// KnowledgeGraphQuery selects a knowledge graph. Without a root the graph
+// holds all of the user's notes and what they link to; with one it holds
+// what is within Depth links of the root, in either direction.
+type KnowledgeGraphQuery struct {
+	RootType string
+	RootID   uint
+	Depth    int
+}
+
+// KnowledgeGraphNode is a note, wiki page, bookmark or task. ID is
+// "type:entity_id".
+type KnowledgeGraphNode struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	EntityID uint   `json:"entity_id"`
+	Title    string `json:"title"`
+	Degree   int    `json:"degree"`
+	// Depth is the distance from the root, when there is one
+	Depth *int `json:"depth,omitempty"`
+}
+
+// KnowledgeGraphEdge connects two nodes: a wikilink in a note, a subnote
+// and its parent, or a link between wiki pages
+type KnowledgeGraphEdge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Type   string `json:"type"`
+}
+
+// KnowledgeGraph is a renderable graph of how the user's knowledge connects
+type KnowledgeGraph struct {
+	Nodes []KnowledgeGraphNode `json:"nodes"`
+	Edges []KnowledgeGraphEdge `json:"edges"`
+}
+
+func graphNodeID(targetType string, id uint) string {
+	return targetType + ":" + strconv.FormatUint(uint64(id), 10)
+}
+
+// Graph builds the knowledge graph of the user
+func (s *NoteLinkService) Graph(userID uint, query KnowledgeGraphQuery) (*KnowledgeGraph, error) {
+	if query.RootType != "" {
+		if _, err := s.targetTitle(userID, query.RootType, query.RootID); err != nil {
+			return nil, err
+		}
+	}
+	depth := query.Depth
+	if depth <= 0 {
+		depth = defaultGraphDepth
+	}
+	if depth > maxGraphDepth {
+		return nil, fmt.Errorf("%w: depth is at most %d", ErrInvalidLinkTarget, maxGraphDepth)
+	}
+
+	edges, err := s.graphEdges(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Candidate nodes: all notes and everything an edge touches
+	nodes := make(map[string]*KnowledgeGraphNode)
+	addNode := func(targetType string, id uint) {
+		key := graphNodeID(targetType, id)
+		if nodes[key] == nil {
+			nodes[key] = &KnowledgeGraphNode{ID: key, Type: targetType, EntityID: id}
+		}
+	}
+	var noteIDs []uint
+	if err := s.db.Model(&models.Note{}).Where("user_id = ?", userID).Pluck("id", &noteIDs).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range noteIDs {
+		addNode(models.LinkTargetNote, id)
+	}
+	for _, edge := range edges {
+		for _, key := range []string{edge.Source, edge.Target} {
+			targetType, raw, _ := strings.Cut(key, ":")
+			id, _ := strconv.ParseUint(raw, 10, 32)
+			addNode(targetType, uint(id))
+		}
+	}
+
+	// Titles double as the ownership check; nodes without one are dropped
+	ids := make(map[string][]uint)
+	for _, node := range nodes {
+		ids[node.Type] = append(ids[node.Type], node.EntityID)
+	}
+	for targetType, entityIDs := range ids {
+		titles, err := s.titles(targetType, userID, entityIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range entityIDs {
+			key := graphNodeID(targetType, id)
+			if title, ok := titles[id]; ok {
+				nodes[key].Title = title
+			} else {
+				delete(nodes, key)
+			}
+		}
+	}
+	kept := edges[:0]
+	for _, edge := range edges {
+		if nodes[edge.Source] != nil && nodes[edge.Target] != nil {
+			kept = append(kept, edge)
+		}
+	}
+	edges = kept
+
+	if query.RootType != "" {
+		neighbours := make(map[string][]string)
+		for _, edge := range edges {
+			neighbours[edge.Source] = append(neighbours[edge.Source], edge.Target)
+			neighbours[edge.Target] = append(neighbours[edge.Target], edge.Source)
+		}
+		root := graphNodeID(query.RootType, query.RootID)
+		distance := map[string]int{root: 0}
+		for frontier := []string{root}; len(frontier) > 0; {
+			var next []string
+			for _, key := range frontier {
+				if distance[key] == depth {
+					continue
+				}
+				for _, neighbour := range neighbours[key] {
+					if _, seen := distance[neighbour]; !seen {
+						distance[neighbour] = distance[key] + 1
+						next = append(next, neighbour)
+					}
+				}
+			}
+			frontier = next
+		}
+		for key, node := range nodes {
+			d, ok := distance[key]
+			if !ok {
+				delete(nodes, key)
+				continue
+			}
+			node.Depth = &d
+		}
+		kept := edges[:0]
+		for _, edge := range edges {
+			if nodes[edge.Source] != nil && nodes[edge.Target] != nil {
+				kept = append(kept, edge)
+			}
+		}
+		edges = kept
+	}
+
+	graph := &KnowledgeGraph{Nodes: []KnowledgeGraphNode{}, Edges: edges}
+	for _, edge := range edges {
+		nodes[edge.Source].Degree++
+		nodes[edge.Target].Degree++
+	}
+	for _, node := range nodes {
+		graph.Nodes = append(graph.Nodes, *node)
+	}
+	typeOrder := make(map[string]int, len(linkTargetTypes))
+	for i, targetType := range linkTargetTypes {
+		typeOrder[targetType] = i
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool {
+		a, b := graph.Nodes[i], graph.Nodes[j]
+		if a.Type != b.Type {
+			return typeOrder[a.Type] < typeOrder[b.Type]
+		}
+		return a.EntityID < b.EntityID
+	})
+	if graph.Edges == nil {
+		graph.Edges = []KnowledgeGraphEdge{}
+	}
+	return graph, nil
+}
+
+// graphEdges collects the user's note links, subnote relations and wiki
+// page links, without duplicates
+func (s *NoteLinkService) graphEdges(userID uint) ([]KnowledgeGraphEdge, error) {
+	var edges []KnowledgeGraphEdge
+	seen := make(map[KnowledgeGraphEdge]bool)
+	add := func(edge KnowledgeGraphEdge) {
+		if edge.Source != edge.Target && !seen[edge] {
+			seen[edge] = true
+			edges = append(edges, edge)
+		}
+	}
+
+	var links []struct {
+		NoteID     uint
+		TargetType string
+		TargetID   uint
+	}
+	if err := s.db.Table("note_links").
+		Joins("JOIN notes ON notes.id = note_links.note_id AND notes.deleted_at IS NULL").
+		Where("note_links.user_id = ? AND note_links.target_id IS NOT NULL", userID).
+		Select("note_links.note_id, note_links.target_type, note_links.target_id").
+		Order("note_links.note_id, note_links.id").Scan(&links).Error; err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		add(KnowledgeGraphEdge{
+			Source: graphNodeID(models.LinkTargetNote, link.NoteID),
+			Target: graphNodeID(link.TargetType, link.TargetID),
+			Type:   GraphEdgeLink,
+		})
+	}
+
+	var subnotes []struct {
+		ID           uint
+		ParentNoteID uint
+	}
+	if err := s.db.Model(&models.Note{}).Where("user_id = ? AND parent_note_id IS NOT NULL", userID).
+		Select("id", "parent_note_id").Order("id").Scan(&subnotes).Error; err != nil {
+		return nil, err
+	}
+	for _, subnote := range subnotes {
+		add(KnowledgeGraphEdge{
+			Source: graphNodeID(models.LinkTargetNote, subnote.ID),
+			Target: graphNodeID(models.LinkTargetNote, subnote.ParentNoteID),
+			Type:   GraphEdgeParent,
+		})
+	}
+
+	var wikiLinks []struct {
+		SourcePageID uint
+		TargetPageID uint
+	}
+	if err := s.db.Model(&models.WikiBacklink{}).
+		Joins("JOIN wiki_pages ON wiki_pages.id = wiki_backlinks.source_page_id AND wiki_pages.deleted_at IS NULL").
+		Where("wiki_pages.user_id = ?", userID).
+		Select("wiki_backlinks.source_page_id, wiki_backlinks.target_page_id").
+		Order("wiki_backlinks.id").Scan(&wikiLinks).Error; err != nil {
+		return nil, err
+	}
+	for _, link := range wikiLinks {
+		add(KnowledgeGraphEdge{
+			Source: graphNodeID(models.LinkTargetWiki, link.SourcePageID),
+			Target: graphNodeID(models.LinkTargetWiki, link.TargetPageID),
+			Type:   GraphEdgeWikiLink,
+		})
+	}
+	return edges, nil
+}
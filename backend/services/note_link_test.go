@@ -0,0 +1,132 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/utils"
+	"gorm.io/gorm"
+)
+
+func TestNoteLinkService(t *testing.T) {
+	t.Setenv("ENCRYPTION_KEY", "test-key")
+	db := newTestDB(t, &models.NoteLink{}, &models.Category{}, &models.WikiPage{}, &models.WikiBacklink{})
+
+	user := models.User{Email: "dev@example.com", Username: "dev", Password: "x", GitHubID: 1}
+	other := models.User{Email: "other@example.com", Username: "other", Password: "x", GitHubID: 2}
+	db.Create(&user)
+	db.Create(&other)
+	service := NewNoteLinkService(db)
+
+	save := func(note *models.Note) {
+		t.Helper()
+		if err := db.Save(note).Error; err != nil {
+			t.Fatalf("failed to save note: %v", err)
+		}
+		if err := service.Reindex(note); err != nil {
+			t.Fatalf("failed to reindex note: %v", err)
+		}
+	}
+
+	task := models.Task{UserID: user.ID, Title: "Ship release"}
+	db.Create(&task)
+	bookmark := models.Bookmark{UserID: user.ID, Title: "Go spec", URL: "https://go.dev/ref/spec"}
+	db.Create(&bookmark)
+	foreign := models.Task{UserID: other.ID, Title: "Not yours"}
+	db.Create(&foreign)
+
+	// Title, typed and aliased links; the weekly review does not exist yet
+	hub := models.Note{UserID: user.ID, Title: "Hub", Content: "See [[Weekly Review|the review]] and [[Go spec]].\n" +
+		"Then [[task:" + strconv.FormatUint(uint64(task.ID), 10) + "]], [[Weekly review#Goals]] and [[task:" + strconv.FormatUint(uint64(foreign.ID), 10) + "]]."}
+	save(&hub)
+	links, err := service.Links(user.ID, hub.ID)
+	if err != nil || len(links) != 4 {
+		t.Fatalf("unexpected links: %+v (err %v)", links, err)
+	}
+	if links[0].TargetID != nil || links[0].Alias != "the review" || links[0].Occurrences != 2 {
+		t.Fatalf("expected a dangling link with two occurrences: %+v", links[0])
+	}
+	if links[1].TargetType != models.LinkTargetBookmark || links[1].TargetTitle != "Go spec" {
+		t.Fatalf("expected a link to the bookmark: %+v", links[1])
+	}
+	if links[2].TargetType != models.LinkTargetTask || links[2].TargetTitle != "Ship release" {
+		t.Fatalf("expected a typed link to the task: %+v", links[2])
+	}
+	if links[3].TargetType != models.LinkTargetTask || links[3].TargetID != nil {
+		t.Fatalf("expected a link to another user's task to stay unresolved: %+v", links[3])
+	}
+
+	// Creating the note resolves the link waiting for it
+	review := models.Note{UserID: user.ID, Title: "weekly review", Content: "Went over the Hub notes."}
+	save(&review)
+	backlinks, err := service.Backlinks(user.ID, models.LinkTargetNote, review.ID)
+	if err != nil || len(backlinks) != 1 || backlinks[0].NoteID != hub.ID || backlinks[0].Context == "" {
+		t.Fatalf("unexpected backlinks: %+v (err %v)", backlinks, err)
+	}
+	if _, err := service.Backlinks(other.ID, models.LinkTargetNote, review.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected other users not to see backlinks, got %v", err)
+	}
+	if _, err := service.Backlinks(user.ID, "project", 1); !errors.Is(err, ErrInvalidLinkTarget) {
+		t.Fatalf("expected an invalid target type, got %v", err)
+	}
+
+	// Unlinked mentions skip notes that link already and text inside links
+	draft := models.Note{UserID: user.ID, Title: "Draft", Content: "Prepare to ship release notes; [[Ship release]] later."}
+	save(&draft)
+	idea := models.Note{UserID: user.ID, Title: "Idea", Content: "Before we ship release, and after we ship releases."}
+	save(&idea)
+	mentions, err := service.UnlinkedMentions(user.ID, models.LinkTargetTask, task.ID)
+	if err != nil || len(mentions) != 1 || mentions[0].NoteID != idea.ID || mentions[0].Occurrences != 1 {
+		t.Fatalf("unexpected unlinked mentions: %+v (err %v)", mentions, err)
+	}
+	mentions, err = service.UnlinkedMentions(user.ID, models.LinkTargetNote, hub.ID)
+	if err != nil || len(mentions) != 1 || mentions[0].NoteID != review.ID {
+		t.Fatalf("unexpected unlinked mentions of the hub: %+v (err %v)", mentions, err)
+	}
+
+	// The graph, whole and around a root
+	child := models.Note{UserID: user.ID, Title: "Child", ParentNoteID: &review.ID}
+	save(&child)
+	graph, err := service.Graph(user.ID, KnowledgeGraphQuery{})
+	if err != nil || len(graph.Nodes) != 7 || len(graph.Edges) != 5 {
+		t.Fatalf("unexpected graph: %+v (err %v)", graph, err)
+	}
+	for _, node := range graph.Nodes {
+		if node.ID == graphNodeID(models.LinkTargetNote, hub.ID) && node.Degree != 3 {
+			t.Fatalf("expected the hub to have three edges: %+v", node)
+		}
+	}
+	graph, err = service.Graph(user.ID, KnowledgeGraphQuery{RootType: models.LinkTargetNote, RootID: child.ID, Depth: 1})
+	if err != nil || len(graph.Nodes) != 2 || len(graph.Edges) != 1 || graph.Edges[0].Type != GraphEdgeParent {
+		t.Fatalf("unexpected graph around the child: %+v (err %v)", graph, err)
+	}
+	graph, err = service.Graph(user.ID, KnowledgeGraphQuery{RootType: models.LinkTargetNote, RootID: child.ID, Depth: 2})
+	if err != nil || len(graph.Nodes) != 3 || len(graph.Edges) != 2 || graph.Nodes[0].EntityID != hub.ID || *graph.Nodes[0].Depth != 2 {
+		t.Fatalf("unexpected graph two links from the child: %+v (err %v)", graph, err)
+	}
+	if _, err := service.Graph(user.ID, KnowledgeGraphQuery{Depth: maxGraphDepth + 1}); !errors.Is(err, ErrInvalidLinkTarget) {
+		t.Fatalf("expected the depth to be limited, got %v", err)
+	}
+
+	// Encrypted notes have no outgoing links
+	ciphertext, _ := utils.Encrypt("links to [[Hub]]")
+	secret := models.Note{UserID: user.ID, Title: "Secret", Content: ciphertext, IsEncrypted: true}
+	save(&secret)
+	if links, _ := service.Links(user.ID, secret.ID); len(links) != 0 {
+		t.Fatalf("expected no links from an encrypted note: %+v", links)
+	}
+
+	// Deleting a note leaves links to it waiting for a new one
+	db.Delete(&review)
+	if err := service.Forget(&review); err != nil {
+		t.Fatalf("failed to forget note: %v", err)
+	}
+	if links, _ := service.Links(user.ID, hub.ID); links[0].TargetID != nil {
+		t.Fatalf("expected the link to the deleted note to be unresolved: %+v", links[0])
+	}
+	if count, err := service.ReindexAll(user.ID); err != nil || count != 5 {
+		t.Fatalf("unexpected reindex: %d (err %v)", count, err)
+	}
+}
@@ -0,0 +1,361 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/utils"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidNoteDiff  = errors.New("invalid diff")
+	ErrNoteDecryption   = errors.New("failed to decrypt note revision")
+	ErrNoteRevisionSame = errors.New("note already matches this revision")
+)
+
+// Rapid saves by the same author are coalesced into one revision: a save
+// within noteRevisionCoalesceWindow of the last one updates that revision,
+// until it spans noteRevisionMaxSpan
+const (
+	noteRevisionCoalesceWindow = 2 * time.Minute
+	noteRevisionMaxSpan        = 30 * time.Minute
+)
+
+// Diff granularities
+const (
+	NoteDiffLines = "line"
+	NoteDiffWords = "word"
+)
+
+// NoteRevisionService keeps the revision history of notes
+type NoteRevisionService struct {
+	db *gorm.DB
+}
+
+// NewNoteRevisionService creates a new note revision service
+func NewNoteRevisionService(db *gorm.DB) *NoteRevisionService {
+	return &NoteRevisionService{db: db}
+}
+
+// NoteDiff compares two revisions of a note. Title and description changes
+// are reported as the old and new values; the content is diffed.
+type NoteDiff struct {
+	NoteID uint   `json:"note_id"`
+	From   int    `json:"from"`
+	To     int    `json:"to"`
+	Mode   string `json:"mode"`
+
+	TitleChanged       bool   `json:"title_changed"`
+	OldTitle           string `json:"old_title,omitempty"`
+	NewTitle           string `json:"new_title,omitempty"`
+	DescriptionChanged bool   `json:"description_changed"`
+
+	Ops     []DiffOp `json:"ops"`
+	Added   int      `json:"added"`
+	Removed int      `json:"removed"`
+}
+
+// noteFields are the revisioned fields of a note as they are stored
+type noteFields struct {
+	Title       string
+	Content     string
+	Description string
+	IsEncrypted bool
+}
+
+func fieldsOfNote(note *models.Note) noteFields {
+	return noteFields{Title: note.Title, Content: note.Content, Description: note.Description, IsEncrypted: note.IsEncrypted}
+}
+
+func fieldsOfRevision(revision *models.NoteRevision) noteFields {
+	return noteFields{Title: revision.Title, Content: revision.Content, Description: revision.Description, IsEncrypted: revision.IsEncrypted}
+}
+
+// plain returns the fields decrypted. Encrypted notes may keep a plain
+// title, so titles are only decrypted when they look encrypted.
+func (f noteFields) plain() (noteFields, error) {
+	if !f.IsEncrypted {
+		return f, nil
+	}
+	content, err := utils.Decrypt(f.Content)
+	if err != nil {
+		return f, fmt.Errorf("%w: %v", ErrNoteDecryption, err)
+	}
+	f.Content = content
+	if utils.IsEncrypted(f.Title) {
+		if title, err := utils.Decrypt(f.Title); err == nil {
+			f.Title = title
+		}
+	}
+	f.IsEncrypted = false
+	return f, nil
+}
+
+// sameAs reports whether two sets of fields hold the same text, which for
+// encrypted fields means comparing the plaintext
+func (f noteFields) sameAs(other noteFields) (bool, error) {
+	if f == other {
+		return true, nil
+	}
+	a, err := f.plain()
+	if err != nil {
+		return false, err
+	}
+	b, err := other.plain()
+	if err != nil {
+		return false, err
+	}
+	return a.Title == b.Title && a.Content == b.Content && a.Description == b.Description, nil
+}
+
+func (s *NoteRevisionService) latest(noteID uint) (*models.NoteRevision, error) {
+	var revisions []models.NoteRevision
+	if err := s.db.Where("note_id = ?", noteID).Order("number DESC").Limit(1).Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		return nil, nil
+	}
+	return &revisions[0], nil
+}
+
+// Baseline records the note as it is, if it has no revisions yet. It is
+// called before a save so notes from before revisions were kept can still
+// be restored to their original text.
+func (s *NoteRevisionService) Baseline(note *models.Note) error {
+	latest, err := s.latest(note.ID)
+	if err != nil || latest != nil {
+		return err
+	}
+	at := note.UpdatedAt
+	revision := s.newRevision(note, note.UserID, 1, models.NoteRevisionBaseline)
+	revision.CreatedAt, revision.UpdatedAt = at, at
+	return s.db.Create(revision).Error
+}
+
+// Record adds a revision for the note's current state. A save that follows
+// the author's previous one quickly enough updates that revision instead,
+// and saves that change nothing are ignored.
+func (s *NoteRevisionService) Record(note *models.Note, authorID uint, now time.Time) (*models.NoteRevision, error) {
+	if authorID == 0 {
+		authorID = note.UserID
+	}
+	latest, err := s.latest(note.ID)
+	if err != nil {
+		return nil, err
+	}
+	if latest != nil {
+		same, err := fieldsOfNote(note).sameAs(fieldsOfRevision(latest))
+		if err != nil {
+			return nil, err
+		}
+		if same {
+			return latest, nil
+		}
+		if latest.Kind == models.NoteRevisionEdit && latest.UserID == authorID &&
+			now.Sub(latest.UpdatedAt) <= noteRevisionCoalesceWindow && now.Sub(latest.CreatedAt) <= noteRevisionMaxSpan {
+			coalesced := s.newRevision(note, authorID, latest.Number, models.NoteRevisionEdit)
+			if err := s.db.Model(latest).Updates(map[string]interface{}{
+				"title":        coalesced.Title,
+				"content":      coalesced.Content,
+				"description":  coalesced.Description,
+				"is_encrypted": coalesced.IsEncrypted,
+				"word_count":   coalesced.WordCount,
+				"save_count":   gorm.Expr("save_count + 1"),
+				"updated_at":   now,
+			}).Error; err != nil {
+				return nil, err
+			}
+			return latest, s.db.First(latest, latest.ID).Error
+		}
+	}
+
+	number := 1
+	if latest != nil {
+		number = latest.Number + 1
+	}
+	revision := s.newRevision(note, authorID, number, models.NoteRevisionEdit)
+	revision.CreatedAt, revision.UpdatedAt = now, now
+	if err := s.db.Create(revision).Error; err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+func (s *NoteRevisionService) newRevision(note *models.Note, authorID uint, number int, kind models.NoteRevisionKind) *models.NoteRevision {
+	revision := &models.NoteRevision{
+		NoteID:      note.ID,
+		Number:      number,
+		UserID:      authorID,
+		Kind:        kind,
+		Title:       note.Title,
+		Content:     note.Content,
+		Description: note.Description,
+		IsEncrypted: note.IsEncrypted,
+		SaveCount:   1,
+	}
+	if !note.IsEncrypted {
+		revision.WordCount = len(strings.Fields(note.Content))
+	}
+	return revision
+}
+
+func (s *NoteRevisionService) loadNote(userID, noteID uint) (*models.Note, error) {
+	var note models.Note
+	if err := s.db.Where("id = ? AND user_id = ?", noteID, userID).First(&note).Error; err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+// List returns the note's revisions, newest first, without their content
+func (s *NoteRevisionService) List(userID, noteID uint) ([]models.NoteRevision, error) {
+	if _, err := s.loadNote(userID, noteID); err != nil {
+		return nil, err
+	}
+	var revisions []models.NoteRevision
+	err := s.db.Where("note_id = ?", noteID).Omit("content").Order("number DESC").Find(&revisions).Error
+	return revisions, err
+}
+
+// Get returns one revision. Encrypted revisions are decrypted for the owner,
+// as encrypted notes are.
+func (s *NoteRevisionService) Get(userID, noteID uint, number int) (*models.NoteRevision, error) {
+	if _, err := s.loadNote(userID, noteID); err != nil {
+		return nil, err
+	}
+	revision, err := s.revision(noteID, number)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := fieldsOfRevision(revision).plain()
+	if err != nil {
+		return nil, err
+	}
+	revision.Title, revision.Content = plain.Title, plain.Content
+	return revision, nil
+}
+
+func (s *NoteRevisionService) revision(noteID uint, number int) (*models.NoteRevision, error) {
+	var revision models.NoteRevision
+	if err := s.db.Where("note_id = ? AND number = ?", noteID, number).First(&revision).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// Diff compares two revisions of the note by line or by word. A to of zero
+// compares with the note as it is now.
+func (s *NoteRevisionService) Diff(userID, noteID uint, from, to int, mode string) (*NoteDiff, error) {
+	if mode == "" {
+		mode = NoteDiffLines
+	}
+	if mode != NoteDiffLines && mode != NoteDiffWords {
+		return nil, fmt.Errorf("%w: mode must be line or word", ErrInvalidNoteDiff)
+	}
+	note, err := s.loadNote(userID, noteID)
+	if err != nil {
+		return nil, err
+	}
+
+	oldRevision, err := s.revision(noteID, from)
+	if err != nil {
+		return nil, err
+	}
+	oldFields, err := fieldsOfRevision(oldRevision).plain()
+	if err != nil {
+		return nil, err
+	}
+	newFields := fieldsOfNote(note)
+	if to != 0 {
+		newRevision, err := s.revision(noteID, to)
+		if err != nil {
+			return nil, err
+		}
+		newFields = fieldsOfRevision(newRevision)
+	}
+	if newFields, err = newFields.plain(); err != nil {
+		return nil, err
+	}
+
+	diff := &NoteDiff{
+		NoteID:             noteID,
+		From:               from,
+		To:                 to,
+		Mode:               mode,
+		TitleChanged:       oldFields.Title != newFields.Title,
+		DescriptionChanged: oldFields.Description != newFields.Description,
+	}
+	if diff.TitleChanged {
+		diff.OldTitle, diff.NewTitle = oldFields.Title, newFields.Title
+	}
+	split := splitDiffLines
+	if mode == NoteDiffWords {
+		split = splitDiffWords
+	}
+	diff.Ops, diff.Added, diff.Removed = diffTokens(split(oldFields.Content), split(newFields.Content))
+	return diff, nil
+}
+
+// Restore brings the note back to a revision and records that as a new
+// revision. The restored text is encrypted or decrypted to match whether
+// the note is encrypted now.
+func (s *NoteRevisionService) Restore(userID, noteID uint, number int, now time.Time) (*models.Note, error) {
+	var note *models.Note
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		service := NewNoteRevisionService(tx)
+		var err error
+		if note, err = service.loadNote(userID, noteID); err != nil {
+			return err
+		}
+		revision, err := service.revision(noteID, number)
+		if err != nil {
+			return err
+		}
+		if same, err := fieldsOfNote(note).sameAs(fieldsOfRevision(revision)); err != nil {
+			return err
+		} else if same {
+			return fmt.Errorf("%w: %d", ErrNoteRevisionSame, number)
+		}
+		restored := fieldsOfRevision(revision)
+		if restored.IsEncrypted != note.IsEncrypted {
+			if restored, err = restored.plain(); err != nil {
+				return err
+			}
+			if note.IsEncrypted {
+				if restored.Content, err = utils.Encrypt(restored.Content); err != nil {
+					return err
+				}
+			}
+		}
+		note.Title, note.Content, note.Description = restored.Title, restored.Content, restored.Description
+		if err := tx.Model(note).Updates(map[string]interface{}{
+			"title":       note.Title,
+			"content":     note.Content,
+			"description": note.Description,
+			"updated_at":  now,
+		}).Error; err != nil {
+			return err
+		}
+
+		latest, err := service.latest(noteID)
+		if err != nil {
+			return err
+		}
+		record := service.newRevision(note, userID, latest.Number+1, models.NoteRevisionRestore)
+		record.RestoredFrom = &number
+		record.CreatedAt, record.UpdatedAt = now, now
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		return NewNoteLinkService(tx).Reindex(note)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return note, s.db.Preload("Tags").First(note, noteID).Error
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"github.com/trackeep/backend/utils"
+	"gorm.io/gorm"
+)
+
+// diffSides rebuilds the old and new text from diff operations
+func diffSides(ops []DiffOp) (string, string) {
+	var old, current strings.Builder
+	for _, op := range ops {
+		if op.Op != DiffInsert {
+			old.WriteString(op.Text)
+		}
+		if op.Op != DiffDelete {
+			current.WriteString(op.Text)
+		}
+	}
+	return old.String(), current.String()
+}
+
+func TestNoteRevisionService(t *testing.T) {
+	t.Setenv("ENCRYPTION_KEY", "test-key")
+	db := newTestDB(t, &models.NoteRevision{}, &models.NoteLink{})
+
+	user := models.User{Email: "dev@example.com", Username: "dev", Password: "x", GitHubID: 1}
+	other := models.User{Email: "other@example.com", Username: "other", Password: "x", GitHubID: 2}
+	db.Create(&user)
+	db.Create(&other)
+	service := NewNoteRevisionService(db)
+	start := time.Date(2026, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	save := func(note *models.Note, content string, at time.Time) *models.NoteRevision {
+		t.Helper()
+		note.Content = content
+		if err := db.Save(note).Error; err != nil {
+			t.Fatalf("failed to save note: %v", err)
+		}
+		revision, err := service.Record(note, user.ID, at)
+		if err != nil {
+			t.Fatalf("failed to record revision: %v", err)
+		}
+		return revision
+	}
+
+	// Autosaves in quick succession share a revision
+	note := models.Note{UserID: user.ID, Title: "Plan", Content: "first line\n"}
+	db.Create(&note)
+	first, err := service.Record(&note, user.ID, start)
+	if err != nil || first.Number != 1 {
+		t.Fatalf("failed to record the first revision: %+v (err %v)", first, err)
+	}
+	coalesced := save(&note, "first line\nsecond line\n", start.Add(90*time.Second))
+	if coalesced.Number != 1 || coalesced.SaveCount != 2 || coalesced.Content != "first line\nsecond line\n" {
+		t.Fatalf("expected the autosave to be coalesced: %+v", coalesced)
+	}
+	second := save(&note, "first line\nthe second line\nthird line\n", start.Add(10*time.Minute))
+	if second.Number != 2 {
+		t.Fatalf("expected a new revision after a pause, got %+v", second)
+	}
+	if same := save(&note, note.Content, start.Add(11*time.Minute)); same.ID != second.ID || same.SaveCount != 1 {
+		t.Fatalf("expected an unchanged save to be ignored: %+v", same)
+	}
+
+	// Line and word diffs
+	diff, err := service.Diff(user.ID, note.ID, 1, 2, NoteDiffLines)
+	if err != nil {
+		t.Fatalf("failed to diff: %v", err)
+	}
+	if old, current := diffSides(diff.Ops); old != "first line\nsecond line\n" || current != note.Content || diff.Added != 2 || diff.Removed != 1 {
+		t.Fatalf("unexpected line diff: %+v", diff)
+	}
+	diff, err = service.Diff(user.ID, note.ID, 1, 0, NoteDiffWords)
+	if err != nil {
+		t.Fatalf("failed to diff: %v", err)
+	}
+	if old, current := diffSides(diff.Ops); old != "first line\nsecond line\n" || current != note.Content || diff.Added != 3 || diff.Removed != 0 {
+		t.Fatalf("unexpected word diff: %+v", diff)
+	}
+	if _, err := service.Diff(user.ID, note.ID, 1, 2, "char"); !errors.Is(err, ErrInvalidNoteDiff) {
+		t.Fatalf("expected an invalid mode, got %v", err)
+	}
+	if _, err := service.List(other.ID, note.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected other users not to see the revisions, got %v", err)
+	}
+
+	// Restoring records a new revision
+	restored, err := service.Restore(user.ID, note.ID, 1, start.Add(time.Hour))
+	if err != nil || restored.Content != "first line\nsecond line\n" {
+		t.Fatalf("failed to restore: %+v (err %v)", restored, err)
+	}
+	revisions, err := service.List(user.ID, note.ID)
+	if err != nil || len(revisions) != 3 || revisions[0].Kind != models.NoteRevisionRestore ||
+		revisions[0].RestoredFrom == nil || *revisions[0].RestoredFrom != 1 || revisions[0].Content != "" {
+		t.Fatalf("unexpected revisions after restore: %+v (err %v)", revisions, err)
+	}
+	if _, err := service.Restore(user.ID, note.ID, 1, start.Add(time.Hour)); !errors.Is(err, ErrNoteRevisionSame) {
+		t.Fatalf("expected restoring the current text to be refused, got %v", err)
+	}
+
+	// Notes from before revisions keep their original text
+	legacy := models.Note{UserID: user.ID, Title: "Old", Content: "original"}
+	db.Create(&legacy)
+	if err := service.Baseline(&legacy); err != nil {
+		t.Fatalf("failed to record baseline: %v", err)
+	}
+	if edit := save(&legacy, "rewritten", time.Now()); edit.Number != 2 {
+		t.Fatalf("expected the baseline not to be coalesced into: %+v", edit)
+	}
+	if baseline, err := service.Get(user.ID, legacy.ID, 1); err != nil || baseline.Kind != models.NoteRevisionBaseline || baseline.Content != "original" {
+		t.Fatalf("unexpected baseline: %+v (err %v)", baseline, err)
+	}
+
+	// Encrypted notes keep encrypted revisions
+	ciphertext, _ := utils.Encrypt("secret plan")
+	secret := models.Note{UserID: user.ID, Title: "Secret", Content: ciphertext, IsEncrypted: true}
+	db.Create(&secret)
+	if _, err := service.Record(&secret, user.ID, start); err != nil {
+		t.Fatalf("failed to record encrypted revision: %v", err)
+	}
+	updated, _ := utils.Encrypt("secret plan, revised")
+	save(&secret, updated, start.Add(time.Hour))
+	var stored []models.NoteRevision
+	db.Where("note_id = ?", secret.ID).Order("number").Find(&stored)
+	if len(stored) != 2 || !stored[0].IsEncrypted || strings.Contains(stored[0].Content, "secret") || stored[0].WordCount != 0 {
+		t.Fatalf("expected encrypted revisions to be stored encrypted: %+v", stored)
+	}
+	plain, err := service.Get(user.ID, secret.ID, 1)
+	if err != nil || plain.Content != "secret plan" {
+		t.Fatalf("expected the owner to read the revision: %+v (err %v)", plain, err)
+	}
+	diff, err = service.Diff(user.ID, secret.ID, 1, 2, NoteDiffWords)
+	if err != nil || len(diff.Ops) != 2 || diff.Ops[1].Text != ", revised" {
+		t.Fatalf("unexpected encrypted diff: %+v (err %v)", diff, err)
+	}
+	restored, err = service.Restore(user.ID, secret.ID, 1, start.Add(2*time.Hour))
+	if err != nil || restored.Content == "secret plan" {
+		t.Fatalf("expected the restored note to stay encrypted: %+v (err %v)", restored, err)
+	}
+	if text, err := utils.Decrypt(restored.Content); err != nil || text != "secret plan" {
+		t.Fatalf("unexpected restored text %q (err %v)", text, err)
+	}
+}
+
+func TestDiffTokens(t *testing.T) {
+	cases := [][2]string{
+		{"", "a b c"},
+		{"a b c", ""},
+		{"the quick brown fox", "the slow brown dog jumps"},
+		{"one two three four five", "zero one three five six"},
+		{strings.Repeat("x ", 1500), strings.Repeat("y ", 1500)},
+	}
+	for _, tc := range cases {
+		ops, _, _ := diffTokens(splitDiffWords(tc[0]), splitDiffWords(tc[1]))
+		if old, current := diffSides(ops); old != tc[0] || current != tc[1] {
+			t.Fatalf("diff of %q and %q does not rebuild both sides: %+v", tc[0], tc[1], ops)
+		}
+	}
+
+	ops, added, removed := diffTokens(splitDiffWords("one two three"), splitDiffWords("one 2 three"))
+	if added != 1 || removed != 1 || len(ops) != 4 || ops[1].Op != DiffDelete || ops[1].Text != "two" {
+		t.Fatalf("unexpected minimal diff: %+v", ops)
+	}
+}
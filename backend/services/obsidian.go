@@ -0,0 +1,849 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/trackeep/backend/models"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidVault = errors.New("invalid vault")
+
+const (
+	maxVaultFiles       = 10000
+	maxVaultFileSize    = 20 << 20  // 20MB
+	maxVaultSize        = 512 << 20 // 512MB
+	obsidianDetailLimit = 50
+)
+
+var (
+	obsidianEmbedPattern = regexp.MustCompile(`!\[\[([^\[\]\n]+)\]\]`)
+	markdownImagePattern = regexp.MustCompile(`!\[([^\]\n]*)\]\(([^)\s]+)(?:\s+"[^"]*")?\)`)
+	fileDownloadPattern  = regexp.MustCompile(`!\[([^\]\n]*)\]\(/api/v1/files/(\d+)/download\)`)
+	vaultNameReplacer    = strings.NewReplacer("/", "-", "\\", "-", ":", "-", "*", "", "?", "", "\"", "", "<", "", ">", "", "|", "-", "#", "", "^", "", "[", "(", "]", ")")
+)
+
+// obsidianAttachmentTypes are the embeds imported as files, by extension
+var obsidianAttachmentTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".gif":  "image/gif",
+	".svg":  "image/svg+xml",
+	".webp": "image/webp",
+	".bmp":  "image/bmp",
+}
+
+// ObsidianResult counts what an import, export or sync did
+type ObsidianResult struct {
+	Processed   int      `json:"processed"`
+	Created     int      `json:"created"`
+	Updated     int      `json:"updated"`
+	Deleted     int      `json:"deleted"`
+	Skipped     int      `json:"skipped"`
+	Conflicts   int      `json:"conflicts"`
+	Attachments int      `json:"attachments"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+func (r *ObsidianResult) addError(format string, args ...interface{}) {
+	if len(r.Errors) < obsidianDetailLimit {
+		r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+	}
+}
+
+// ObsidianService moves notes between Trackeep and Obsidian vaults. Folders
+// become parent notes, front matter becomes tags and properties, and
+// embedded images become files stored under storageDir.
+type ObsidianService struct {
+	db         *gorm.DB
+	storageDir string
+}
+
+// NewObsidianService creates a new Obsidian service
+func NewObsidianService(db *gorm.DB, storageDir string) *ObsidianService {
+	return &ObsidianService{db: db, storageDir: storageDir}
+}
+
+// vaultFile is a note or attachment read from a vault
+type vaultFile struct {
+	path    string
+	data    []byte
+	modTime time.Time
+}
+
+// vaultContent is what a vault holds, by path. Hidden files and folders,
+// such as .obsidian and .trash, are left out.
+type vaultContent struct {
+	notes       map[string]*vaultFile
+	attachments map[string]*vaultFile
+	folders     map[string]bool
+}
+
+func readVault(fsys fs.FS) (*vaultContent, error) {
+	vault := &vaultContent{
+		notes:       map[string]*vaultFile{},
+		attachments: map[string]*vaultFile{},
+		folders:     map[string]bool{},
+	}
+	count, size := 0, int64(0)
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == "." {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			vault.folders[p] = true
+			return nil
+		}
+
+		ext := strings.ToLower(path.Ext(p))
+		_, isAttachment := obsidianAttachmentTypes[ext]
+		if ext != ".md" && !isAttachment {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if count++; count > maxVaultFiles {
+			return fmt.Errorf("%w: more than %d files", ErrInvalidVault, maxVaultFiles)
+		}
+		if info.Size() > maxVaultFileSize {
+			return nil
+		}
+		if size += info.Size(); size > maxVaultSize {
+			return fmt.Errorf("%w: larger than %d MB", ErrInvalidVault, maxVaultSize>>20)
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		// Databases keep microseconds, so mtimes are compared at that precision
+		file := &vaultFile{path: p, data: data, modTime: info.ModTime().Truncate(time.Microsecond)}
+		if isAttachment {
+			vault.attachments[p] = file
+		} else {
+			vault.notes[p] = file
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vault, nil
+}
+
+// OpenVaultZip opens a zipped vault. A zip holding a single folder, as
+// made by zipping the vault folder itself, is opened at that folder.
+func OpenVaultZip(r io.ReaderAt, size int64) (fs.FS, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidVault, err)
+	}
+	entries, err := fs.ReadDir(archive, ".")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidVault, err)
+	}
+	var visible []fs.DirEntry
+	for _, entry := range entries {
+		if !strings.HasPrefix(entry.Name(), ".") && entry.Name() != "__MACOSX" {
+			visible = append(visible, entry)
+		}
+	}
+	if len(visible) == 1 && visible[0].IsDir() {
+		return fs.Sub(archive, visible[0].Name())
+	}
+	return archive, nil
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// splitFrontMatter separates the YAML front matter from the body of a note.
+// Front matter that is not valid YAML is left in the body.
+func splitFrontMatter(text string) (map[string]interface{}, string) {
+	text = strings.TrimPrefix(text, "\ufeff")
+	first, rest, found := strings.Cut(text, "\n")
+	if !found || strings.TrimSpace(first) != "---" {
+		return nil, text
+	}
+	offset := 0
+	for offset <= len(rest) {
+		line, _, _ := strings.Cut(rest[offset:], "\n")
+		next := offset + len(line) + 1
+		if trimmed := strings.TrimSpace(line); trimmed == "---" || trimmed == "..." {
+			properties := map[string]interface{}{}
+			if err := yaml.Unmarshal([]byte(rest[:offset]), &properties); err != nil {
+				return nil, text
+			}
+			return properties, rest[min(next, len(rest)):]
+		}
+		offset = next
+	}
+	return nil, text
+}
+
+// takeFrontMatterFields removes the tags and description from front matter
+// properties and returns them
+func takeFrontMatterFields(properties map[string]interface{}) ([]string, string) {
+	var raw []string
+	for _, key := range []string{"tags", "tag"} {
+		switch value := properties[key].(type) {
+		case string:
+			raw = append(raw, strings.FieldsFunc(value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })...)
+		case []interface{}:
+			for _, item := range value {
+				if item != nil {
+					raw = append(raw, fmt.Sprint(item))
+				}
+			}
+		}
+		delete(properties, key)
+	}
+
+	var tags []string
+	seen := map[string]bool{}
+	for _, tag := range raw {
+		tag = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+		if tag != "" && !seen[strings.ToLower(tag)] {
+			seen[strings.ToLower(tag)] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	description, _ := properties["description"].(string)
+	if description != "" {
+		delete(properties, "description")
+	}
+	return tags, description
+}
+
+// renderObsidianNote writes a note as vault markdown. Embedded files found
+// in attachmentPaths become ![[path]] embeds.
+func renderObsidianNote(note *models.Note, attachmentPaths map[uint]string) (string, error) {
+	body := fileDownloadPattern.ReplaceAllStringFunc(note.Content, func(match string) string {
+		id, _ := strconv.ParseUint(fileDownloadPattern.FindStringSubmatch(match)[2], 10, 32)
+		if p, ok := attachmentPaths[uint(id)]; ok {
+			return "![[" + p + "]]"
+		}
+		return match
+	})
+
+	properties := map[string]interface{}{}
+	for key, value := range note.Properties {
+		properties[key] = value
+	}
+	if len(note.Tags) > 0 {
+		tags := make([]string, 0, len(note.Tags))
+		for _, tag := range note.Tags {
+			tags = append(tags, tag.Name)
+		}
+		sort.Strings(tags)
+		properties["tags"] = tags
+	}
+	if note.Description != "" {
+		properties["description"] = note.Description
+	}
+	if len(properties) == 0 {
+		return body, nil
+	}
+
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(properties); err != nil {
+		return "", err
+	}
+	encoder.Close()
+	return "---\n" + out.String() + "---\n" + body, nil
+}
+
+// vaultFileName turns a title into a file name Obsidian accepts
+func vaultFileName(title string) string {
+	name := strings.TrimSpace(vaultNameReplacer.Replace(title))
+	name = strings.TrimLeft(name, ".")
+	if name == "" {
+		name = "Untitled"
+	}
+	return name
+}
+
+// uniqueVaultPath returns dir/name+ext, numbering the name while taken
+// reports the path as used
+func uniqueVaultPath(dir, name, ext string, taken func(string) bool) string {
+	for i := 1; ; i++ {
+		candidate := name
+		if i > 1 {
+			candidate = fmt.Sprintf("%s %d", name, i)
+		}
+		p := path.Join(dir, candidate+ext)
+		if !taken(strings.ToLower(p)) {
+			return p
+		}
+	}
+}
+
+// vaultImport imports the notes of one vault in a transaction. Notes and
+// attachments are matched to earlier imports by path, so importing a vault
+// again updates the same notes.
+type vaultImport struct {
+	service   *ObsidianService
+	tx        *gorm.DB
+	userID    uint
+	vault     string
+	content   *vaultContent
+	result    *ObsidianResult
+	now       time.Time
+	revisions *NoteRevisionService
+
+	entries     map[string]*models.ObsidianFile
+	notes       map[string]uint
+	attachments map[string]uint
+	byName      map[string][]string
+	touched     map[uint]bool
+	// paths caches attachmentPaths until another attachment is stored
+	paths map[uint]string
+}
+
+func (s *ObsidianService) newVaultImport(tx *gorm.DB, userID uint, vault string, content *vaultContent, result *ObsidianResult, now time.Time) (*vaultImport, error) {
+	var entries []models.ObsidianFile
+	if err := tx.Where("user_id = ? AND vault = ?", userID, vault).Order("path").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	imp := &vaultImport{
+		service:     s,
+		tx:          tx,
+		userID:      userID,
+		vault:       vault,
+		content:     content,
+		result:      result,
+		now:         now,
+		revisions:   NewNoteRevisionService(tx),
+		entries:     make(map[string]*models.ObsidianFile, len(entries)),
+		notes:       map[string]uint{},
+		attachments: map[string]uint{},
+		byName:      map[string][]string{},
+		touched:     map[uint]bool{},
+	}
+	for i := range entries {
+		imp.entries[entries[i].Path] = &entries[i]
+	}
+	for p := range content.attachments {
+		name := strings.ToLower(path.Base(p))
+		imp.byName[name] = append(imp.byName[name], p)
+	}
+	for _, paths := range imp.byName {
+		sort.Slice(paths, func(i, j int) bool {
+			return len(paths[i]) < len(paths[j]) || (len(paths[i]) == len(paths[j]) && paths[i] < paths[j])
+		})
+	}
+	return imp, nil
+}
+
+// saveEntry creates or updates the tracking entry of a path
+func (imp *vaultImport) saveEntry(p string, update func(*models.ObsidianFile)) error {
+	entry := imp.entries[p]
+	if entry == nil {
+		entry = &models.ObsidianFile{UserID: imp.userID, Vault: imp.vault, Path: p}
+		imp.entries[p] = entry
+	}
+	update(entry)
+	return imp.tx.Save(entry).Error
+}
+
+// folder returns the note standing for a vault folder. A note next to the
+// folder with the same name, as in "Projects.md" and "Projects/", is used
+// when there is one; otherwise an empty note is created.
+func (imp *vaultImport) folder(dir string) (*uint, error) {
+	if dir == "." || dir == "" {
+		return nil, nil
+	}
+	key := dir + "/"
+	if id, ok := imp.notes[key]; ok {
+		return &id, nil
+	}
+
+	var id uint
+	if _, ok := imp.content.notes[dir+".md"]; ok {
+		noteID, err := imp.importNote(dir + ".md")
+		if err != nil {
+			return nil, err
+		}
+		id = noteID
+	} else {
+		if entry := imp.entries[key]; entry != nil && entry.NoteID != nil {
+			var count int64
+			if err := imp.tx.Model(&models.Note{}).Where("id = ? AND user_id = ?", *entry.NoteID, imp.userID).Count(&count).Error; err != nil {
+				return nil, err
+			}
+			if count > 0 {
+				id = *entry.NoteID
+			}
+		}
+		if id == 0 {
+			parentID, err := imp.folder(path.Dir(dir))
+			if err != nil {
+				return nil, err
+			}
+			note := models.Note{UserID: imp.userID, Title: path.Base(dir), ContentType: "markdown", ParentNoteID: parentID}
+			if err := imp.tx.Create(&note).Error; err != nil {
+				return nil, err
+			}
+			imp.result.Created++
+			id = note.ID
+		}
+	}
+
+	imp.notes[key] = id
+	if err := imp.saveEntry(key, func(entry *models.ObsidianFile) { entry.NoteID = &id }); err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+// importNote creates or updates the note of a vault file. Notes whose file
+// has not changed since the last import are left alone, as are notes that
+// were encrypted in Trackeep.
+func (imp *vaultImport) importNote(p string) (uint, error) {
+	if id, ok := imp.notes[p]; ok {
+		return id, nil
+	}
+	file := imp.content.notes[p]
+	hash := contentHash(file.data)
+	imp.result.Processed++
+
+	var note models.Note
+	found := false
+	if entry := imp.entries[p]; entry != nil && entry.NoteID != nil {
+		err := imp.tx.Unscoped().Where("id = ? AND user_id = ?", *entry.NoteID, imp.userID).First(&note).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, err
+		}
+		found = err == nil
+		if found && (note.IsEncrypted || (!note.DeletedAt.Valid && entry.Hash == hash)) {
+			if note.IsEncrypted {
+				imp.result.addError("%s: the note is encrypted", p)
+			}
+			imp.result.Skipped++
+			imp.notes[p] = note.ID
+			return note.ID, nil
+		}
+	}
+
+	parentID, err := imp.folder(path.Dir(p))
+	if err != nil {
+		return 0, err
+	}
+	properties, body := splitFrontMatter(string(file.data))
+	tagNames, description := takeFrontMatterFields(properties)
+	if len(properties) == 0 {
+		properties = nil
+	}
+	content, err := imp.embeds(p, body)
+	if err != nil {
+		return 0, err
+	}
+
+	title := strings.TrimSuffix(path.Base(p), path.Ext(p))
+	if found {
+		if err := imp.revisions.Baseline(&note); err != nil {
+			return 0, err
+		}
+		note.DeletedAt = gorm.DeletedAt{}
+		note.Title = title
+		note.Content = content
+		note.Description = description
+		note.Properties = properties
+		note.ParentNoteID = parentID
+		if err := imp.tx.Unscoped().Omit("Tags").Save(&note).Error; err != nil {
+			return 0, err
+		}
+		imp.result.Updated++
+	} else {
+		note = models.Note{
+			UserID:       imp.userID,
+			Title:        title,
+			Content:      content,
+			Description:  description,
+			Properties:   properties,
+			ContentType:  "markdown",
+			ParentNoteID: parentID,
+		}
+		if err := imp.tx.Create(&note).Error; err != nil {
+			return 0, err
+		}
+		imp.result.Created++
+	}
+	if _, err := imp.revisions.Record(&note, imp.userID, imp.now); err != nil {
+		return 0, err
+	}
+
+	tags := make([]models.Tag, 0, len(tagNames))
+	for _, name := range tagNames {
+		tag, err := FindOrCreateTag(imp.tx, imp.userID, name)
+		if err != nil {
+			return 0, err
+		}
+		tags = append(tags, *tag)
+	}
+	if err := imp.tx.Model(&note).Association("Tags").Replace(tags); err != nil {
+		return 0, err
+	}
+
+	noteID := note.ID
+	if err := imp.saveEntry(p, func(entry *models.ObsidianFile) {
+		entry.NoteID = &noteID
+		entry.Hash = hash
+		entry.ModTime = file.modTime
+	}); err != nil {
+		return 0, err
+	}
+	imp.notes[p] = noteID
+	imp.touched[noteID] = true
+	return noteID, nil
+}
+
+// embeds imports the images a note embeds and points the embeds at the
+// stored files
+func (imp *vaultImport) embeds(notePath, body string) (string, error) {
+	var firstErr error
+	replace := func(match, alt, target string) string {
+		file := imp.resolveAttachment(notePath, target)
+		if file == nil || firstErr != nil {
+			return match
+		}
+		id, err := imp.attachment(file)
+		if err != nil {
+			firstErr = err
+			return match
+		}
+		if alt == "" {
+			alt = path.Base(file.path)
+		}
+		return fmt.Sprintf("![%s](/api/v1/files/%d/download)", alt, id)
+	}
+
+	body = obsidianEmbedPattern.ReplaceAllStringFunc(body, func(match string) string {
+		target, _, _ := strings.Cut(match[3:len(match)-2], "|")
+		target, _, _ = strings.Cut(target, "#")
+		return replace(match, "", strings.TrimSpace(target))
+	})
+	body = markdownImagePattern.ReplaceAllStringFunc(body, func(match string) string {
+		parts := markdownImagePattern.FindStringSubmatch(match)
+		if strings.HasPrefix(parts[2], "/") || strings.Contains(parts[2], "://") {
+			return match
+		}
+		target, err := url.PathUnescape(parts[2])
+		if err != nil {
+			return match
+		}
+		return replace(match, parts[1], target)
+	})
+	return body, firstErr
+}
+
+// resolveAttachment finds an embedded file the way Obsidian does: by path
+// from the vault root, relative to the note, or by name alone
+func (imp *vaultImport) resolveAttachment(notePath, target string) *vaultFile {
+	if target == "" {
+		return nil
+	}
+	for _, candidate := range []string{path.Clean(target), path.Join(path.Dir(notePath), target)} {
+		if file, ok := imp.content.attachments[candidate]; ok {
+			return file
+		}
+	}
+	if paths := imp.byName[strings.ToLower(path.Base(target))]; len(paths) > 0 {
+		return imp.content.attachments[paths[0]]
+	}
+	return nil
+}
+
+// attachment stores an embedded file once and returns its file ID
+func (imp *vaultImport) attachment(file *vaultFile) (uint, error) {
+	if id, ok := imp.attachments[file.path]; ok {
+		return id, nil
+	}
+	hash := contentHash(file.data)
+	ext := strings.ToLower(path.Ext(file.path))
+	fileName := fmt.Sprintf("obsidian_%d_%s%s", imp.userID, hash[:16], ext)
+
+	var stored models.File
+	err := imp.tx.Where("user_id = ? AND file_name = ?", imp.userID, fileName).First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		dir := filepath.Join(imp.service.storageDir, "obsidian", fmt.Sprint(imp.userID))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return 0, fmt.Errorf("failed to create attachment directory: %w", err)
+		}
+		filePath := filepath.Join(dir, fileName)
+		if err := os.WriteFile(filePath, file.data, 0644); err != nil {
+			return 0, fmt.Errorf("failed to write attachment: %w", err)
+		}
+		stored = models.File{
+			UserID:       imp.userID,
+			OriginalName: path.Base(file.path),
+			FileName:     fileName,
+			FilePath:     filePath,
+			FileSize:     int64(len(file.data)),
+			MimeType:     obsidianAttachmentTypes[ext],
+			FileType:     models.FileTypeImage,
+			Description:  "Imported from the Obsidian vault " + imp.vault,
+		}
+		if err := imp.tx.Create(&stored).Error; err != nil {
+			return 0, err
+		}
+		imp.result.Attachments++
+	} else if err != nil {
+		return 0, err
+	}
+
+	fileID := stored.ID
+	if err := imp.saveEntry(file.path, func(entry *models.ObsidianFile) {
+		entry.FileID = &fileID
+		entry.Hash = hash
+		entry.ModTime = file.modTime
+	}); err != nil {
+		return 0, err
+	}
+	imp.attachments[file.path] = fileID
+	imp.paths = nil
+	return fileID, nil
+}
+
+// attachmentPaths returns where the vault keeps each imported file
+func (imp *vaultImport) attachmentPaths() map[uint]string {
+	if imp.paths != nil {
+		return imp.paths
+	}
+	paths := map[uint]string{}
+	for p, entry := range imp.entries {
+		if entry.FileID != nil {
+			if current, ok := paths[*entry.FileID]; !ok || p < current {
+				paths[*entry.FileID] = p
+			}
+		}
+	}
+	imp.paths = paths
+	return paths
+}
+
+// finish indexes the links of the imported notes, now that all of them
+// exist, and records how each one renders
+func (imp *vaultImport) finish() error {
+	links := NewNoteLinkService(imp.tx)
+	attachmentPaths := imp.attachmentPaths()
+	for p, entry := range imp.entries {
+		if entry.NoteID == nil || !imp.touched[*entry.NoteID] || strings.HasSuffix(p, "/") {
+			continue
+		}
+		var note models.Note
+		if err := imp.tx.Preload("Tags").First(&note, *entry.NoteID).Error; err != nil {
+			return err
+		}
+		if err := links.Reindex(&note); err != nil {
+			return err
+		}
+		rendered, err := renderObsidianNote(&note, attachmentPaths)
+		if err != nil {
+			return err
+		}
+		if err := imp.saveEntry(p, func(entry *models.ObsidianFile) { entry.NoteHash = contentHash([]byte(rendered)) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Import reads a vault into the user's notes
+func (s *ObsidianService) Import(userID uint, vault string, fsys fs.FS, now time.Time) (*ObsidianResult, error) {
+	content, err := readVault(fsys)
+	if err != nil {
+		return nil, err
+	}
+	if len(content.notes) == 0 {
+		return nil, fmt.Errorf("%w: no notes found", ErrInvalidVault)
+	}
+
+	result := &ObsidianResult{}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		imp, err := s.newVaultImport(tx, userID, vault, content, result, now)
+		if err != nil {
+			return err
+		}
+		for _, p := range sortedVaultPaths(content.notes) {
+			if _, err := imp.importNote(p); err != nil {
+				return err
+			}
+		}
+		return imp.finish()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func sortedVaultPaths(files map[string]*vaultFile) []string {
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Export writes the user's notes as a zipped vault. Subnotes go in a folder
+// named after their parent, embedded files go in attachments/ unless they
+// came from a vault, and encrypted notes are left out.
+func (s *ObsidianService) Export(userID uint) ([]byte, error) {
+	var notes []models.Note
+	if err := s.db.Preload("Tags").Where("user_id = ? AND is_encrypted = ?", userID, false).Order("id").Find(&notes).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]*models.Note, len(notes))
+	hasChildren := map[uint]bool{}
+	for i := range notes {
+		byID[notes[i].ID] = &notes[i]
+	}
+	for _, note := range notes {
+		if note.ParentNoteID != nil && byID[*note.ParentNoteID] != nil {
+			hasChildren[*note.ParentNoteID] = true
+		}
+	}
+
+	// Lay out the vault: a note's name is used for both its file and the
+	// folder of its subnotes
+	taken := map[string]bool{}
+	names := map[uint]string{}
+	var place func(note *models.Note) string
+	place = func(note *models.Note) string {
+		if name, ok := names[note.ID]; ok {
+			return name
+		}
+		dir := ""
+		if note.ParentNoteID != nil {
+			if parent := byID[*note.ParentNoteID]; parent != nil {
+				dir = place(parent)
+			}
+		}
+		name := strings.TrimSuffix(uniqueVaultPath(dir, vaultFileName(note.Title), ".md", func(p string) bool { return taken[p] }), ".md")
+		taken[strings.ToLower(name+".md")] = true
+		names[note.ID] = name
+		return name
+	}
+	for i := range notes {
+		place(&notes[i])
+	}
+
+	attachmentPaths, files, err := s.exportAttachments(userID, notes, taken)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	archive := zip.NewWriter(&out)
+	for i := range notes {
+		note := &notes[i]
+		if hasChildren[note.ID] && strings.TrimSpace(note.Content) == "" && note.Description == "" && len(note.Tags) == 0 && len(note.Properties) == 0 {
+			continue
+		}
+		rendered, err := renderObsidianNote(note, attachmentPaths)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeZipFile(archive, names[note.ID]+".md", []byte(rendered), note.UpdatedAt); err != nil {
+			return nil, err
+		}
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file.FilePath)
+		if err != nil {
+			continue
+		}
+		if err := writeZipFile(archive, attachmentPaths[file.ID], data, file.UpdatedAt); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// exportAttachments picks a vault path for every file the notes embed
+func (s *ObsidianService) exportAttachments(userID uint, notes []models.Note, taken map[string]bool) (map[uint]string, []models.File, error) {
+	var ids []uint
+	for _, note := range notes {
+		for _, match := range fileDownloadPattern.FindAllStringSubmatch(note.Content, -1) {
+			id, _ := strconv.ParseUint(match[2], 10, 32)
+			ids = append(ids, uint(id))
+		}
+	}
+	paths := map[uint]string{}
+	if len(ids) == 0 {
+		return paths, nil, nil
+	}
+
+	var files []models.File
+	if err := s.db.Where("user_id = ? AND id IN ? AND is_encrypted = ?", userID, uniqueIDs(ids), false).Order("id").Find(&files).Error; err != nil {
+		return nil, nil, err
+	}
+	var entries []models.ObsidianFile
+	if err := s.db.Where("user_id = ? AND file_id IN ?", userID, uniqueIDs(ids)).Order("vault, path").Find(&entries).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, entry := range entries {
+		if _, ok := paths[*entry.FileID]; !ok && !taken[strings.ToLower(entry.Path)] {
+			paths[*entry.FileID] = entry.Path
+			taken[strings.ToLower(entry.Path)] = true
+		}
+	}
+	for _, file := range files {
+		if _, ok := paths[file.ID]; ok {
+			continue
+		}
+		ext := path.Ext(file.OriginalName)
+		p := uniqueVaultPath("attachments", vaultFileName(strings.TrimSuffix(file.OriginalName, ext)), ext, func(p string) bool { return taken[p] })
+		taken[strings.ToLower(p)] = true
+		paths[file.ID] = p
+	}
+	return paths, files, nil
+}
+
+func writeZipFile(archive *zip.Writer, name string, data []byte, modified time.Time) error {
+	writer, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return err
+	}
+	_, err = writer.Write(data)
+	return err
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+// Sync brings a vault folder and the user's notes in line. Each side is
+// compared with the hashes recorded at the last sync; a file whose mtime has
+// not moved is taken as unchanged without hashing it.
+//
+//   - A change on one side is copied to the other.
+//   - A change on both sides keeps the vault version in the note and saves
+//     the Trackeep version as a conflict copy, both as a note and a file.
+//   - A file deleted in the vault deletes its unchanged note; a deleted note
+//     moves its unchanged file to the vault's .trash folder.
+//   - New files are imported and new notes are written to the vault.
+func (s *ObsidianService) Sync(userID uint, vault, dir string, now time.Time) (*ObsidianResult, error) {
+	content, err := readVault(os.DirFS(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ObsidianResult{}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		imp, err := s.newVaultImport(tx, userID, vault, content, result, now)
+		if err != nil {
+			return err
+		}
+		sync := &vaultSync{vaultImport: imp, dir: dir, used: map[string]bool{}}
+		for p := range content.notes {
+			sync.used[strings.ToLower(p)] = true
+		}
+		for p := range imp.entries {
+			sync.used[strings.ToLower(p)] = true
+		}
+
+		tracked := make([]string, 0, len(imp.entries))
+		for p, entry := range imp.entries {
+			if entry.NoteID != nil && !strings.HasSuffix(p, "/") {
+				tracked = append(tracked, p)
+			}
+		}
+		sort.Strings(tracked)
+		for _, p := range tracked {
+			if err := sync.tracked(p); err != nil {
+				return err
+			}
+		}
+
+		for _, p := range sortedVaultPaths(content.notes) {
+			if _, err := imp.importNote(p); err != nil {
+				return err
+			}
+		}
+		if err := sync.newNotes(); err != nil {
+			return err
+		}
+		return imp.finish()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// vaultSync is a vault import that also writes to the vault folder
+type vaultSync struct {
+	*vaultImport
+	dir string
+	// used holds the lower-cased paths of vault files and entries
+	used map[string]bool
+}
+
+// tracked syncs a note and the file it was last synced with
+func (s *vaultSync) tracked(p string) error {
+	entry := s.entries[p]
+	file := s.content.notes[p]
+
+	var note models.Note
+	err := s.tx.Unscoped().Preload("Tags").Where("id = ? AND user_id = ?", *entry.NoteID, s.userID).First(&note).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	noteGone := err != nil || note.DeletedAt.Valid
+	if !noteGone && note.IsEncrypted {
+		// Encrypted notes are never written to the vault; importNote skips them
+		return nil
+	}
+
+	var rendered string
+	noteChanged := false
+	if !noteGone {
+		if rendered, err = renderObsidianNote(&note, s.attachmentPaths()); err != nil {
+			return err
+		}
+		noteChanged = contentHash([]byte(rendered)) != entry.NoteHash
+	}
+	fileChanged := file != nil && !file.modTime.Equal(entry.ModTime) && contentHash(file.data) != entry.Hash
+
+	switch {
+	case file == nil && noteGone:
+		return s.dropEntry(p)
+	case file == nil && !noteChanged:
+		s.result.Processed++
+		s.result.Deleted++
+		if err := s.tx.Delete(&note).Error; err != nil {
+			return err
+		}
+		if err := NewNoteLinkService(s.tx).Forget(&note); err != nil {
+			return err
+		}
+		return s.dropEntry(p)
+	case file == nil:
+		s.result.Processed++
+		s.result.Updated++
+		return s.write(p, note.ID, rendered)
+	case noteGone && !fileChanged:
+		s.result.Processed++
+		s.result.Deleted++
+		delete(s.content.notes, p)
+		if err := s.trash(p); err != nil {
+			return err
+		}
+		return s.dropEntry(p)
+	case fileChanged && noteChanged:
+		if err := s.conflict(p, &note); err != nil {
+			return err
+		}
+		_, err := s.importNote(p)
+		return err
+	case noteChanged:
+		s.result.Processed++
+		s.result.Updated++
+		return s.write(p, note.ID, rendered)
+	}
+	// The file changed, was restored or is unchanged
+	_, err = s.importNote(p)
+	return err
+}
+
+// conflict saves the Trackeep version of a note that changed on both sides
+// as a new note and file next to the original
+func (s *vaultSync) conflict(p string, note *models.Note) error {
+	s.result.Conflicts++
+	copied := models.Note{
+		UserID:       s.userID,
+		Title:        fmt.Sprintf("%s (conflict %s)", note.Title, s.now.Format("2006-01-02 1504")),
+		Content:      note.Content,
+		Description:  note.Description,
+		Properties:   note.Properties,
+		ContentType:  note.ContentType,
+		ParentNoteID: note.ParentNoteID,
+	}
+	if err := s.tx.Omit("Tags").Create(&copied).Error; err != nil {
+		return err
+	}
+	if len(note.Tags) > 0 {
+		if err := s.tx.Model(&copied).Association("Tags").Replace(note.Tags); err != nil {
+			return err
+		}
+	}
+	copied.Tags = note.Tags
+	if _, err := s.revisions.Record(&copied, s.userID, s.now); err != nil {
+		return err
+	}
+
+	rendered, err := renderObsidianNote(&copied, s.attachmentPaths())
+	if err != nil {
+		return err
+	}
+	copyPath := uniqueVaultPath(path.Dir(p), vaultFileName(copied.Title), ".md", s.taken)
+	s.notes[copyPath] = copied.ID
+	s.touched[copied.ID] = true
+	return s.write(copyPath, copied.ID, rendered)
+}
+
+// newNotes writes the notes that are not in the vault yet
+func (s *vaultSync) newNotes() error {
+	var notes []models.Note
+	if err := s.tx.Preload("Tags").Where("user_id = ? AND is_encrypted = ?", s.userID, false).Order("id").Find(&notes).Error; err != nil {
+		return err
+	}
+	byID := make(map[uint]*models.Note, len(notes))
+	for i := range notes {
+		byID[notes[i].ID] = &notes[i]
+	}
+
+	// Where each note's subnotes go, from the folders and files already
+	// synced; a folder wins over a file with the same name
+	folders := map[uint]string{}
+	paths := make([]string, 0, len(s.entries))
+	for p := range s.entries {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		entry := s.entries[p]
+		if entry.NoteID == nil {
+			continue
+		}
+		if strings.HasSuffix(p, "/") {
+			folders[*entry.NoteID] = strings.TrimSuffix(p, "/")
+		} else if _, ok := folders[*entry.NoteID]; !ok {
+			folders[*entry.NoteID] = strings.TrimSuffix(p, path.Ext(p))
+		}
+	}
+
+	var place func(note *models.Note) (string, error)
+	place = func(note *models.Note) (string, error) {
+		if folder, ok := folders[note.ID]; ok {
+			return folder, nil
+		}
+		dir := ""
+		if note.ParentNoteID != nil {
+			if parent := byID[*note.ParentNoteID]; parent != nil {
+				var err error
+				if dir, err = place(parent); err != nil {
+					return "", err
+				}
+			}
+		}
+		rendered, err := renderObsidianNote(note, s.attachmentPaths())
+		if err != nil {
+			return "", err
+		}
+		p := uniqueVaultPath(dir, vaultFileName(note.Title), ".md", s.taken)
+		s.result.Processed++
+		s.result.Created++
+		if err := s.write(p, note.ID, rendered); err != nil {
+			return "", err
+		}
+		folders[note.ID] = strings.TrimSuffix(p, ".md")
+		return folders[note.ID], nil
+	}
+	for i := range notes {
+		if _, err := place(&notes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// taken reports whether a lower-cased vault path is in use
+func (s *vaultSync) taken(p string) bool {
+	return s.used[p]
+}
+
+// write saves a rendered note to the vault and records it as synced
+func (s *vaultSync) write(p string, noteID uint, rendered string) error {
+	target := filepath.Join(s.dir, filepath.FromSlash(p))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create vault folder: %w", err)
+	}
+	if err := os.WriteFile(target, []byte(rendered), 0644); err != nil {
+		return fmt.Errorf("failed to write vault file: %w", err)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	modTime := info.ModTime().Truncate(time.Microsecond)
+
+	hash := contentHash([]byte(rendered))
+	s.used[strings.ToLower(p)] = true
+	s.content.notes[p] = &vaultFile{path: p, data: []byte(rendered), modTime: modTime}
+	s.notes[p] = noteID
+	return s.saveEntry(p, func(entry *models.ObsidianFile) {
+		entry.NoteID = &noteID
+		entry.Hash = hash
+		entry.NoteHash = hash
+		entry.ModTime = modTime
+	})
+}
+
+// trash moves a vault file to the vault's .trash folder, as Obsidian does
+func (s *vaultSync) trash(p string) error {
+	trashDir := filepath.Join(s.dir, ".trash")
+	if err := os.MkdirAll(trashDir, 0755); err != nil {
+		return fmt.Errorf("failed to create trash folder: %w", err)
+	}
+	name := path.Base(p)
+	target := filepath.Join(trashDir, name)
+	if _, err := os.Stat(target); err == nil {
+		ext := path.Ext(name)
+		target = filepath.Join(trashDir, fmt.Sprintf("%s %d%s", strings.TrimSuffix(name, ext), s.now.Unix(), ext))
+	}
+	return os.Rename(filepath.Join(s.dir, filepath.FromSlash(p)), target)
+}
+
+func (s *vaultSync) dropEntry(p string) error {
+	entry := s.entries[p]
+	delete(s.entries, p)
+	return s.tx.Delete(entry).Error
+}
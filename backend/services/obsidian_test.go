@@ -0,0 +1,212 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/trackeep/backend/models"
+)
+
+func TestObsidianImportExport(t *testing.T) {
+	db := newTestDB(t, &models.NoteRevision{}, &models.NoteLink{}, &models.WikiPage{}, &models.File{}, &models.ObsidianFile{})
+
+	user := models.User{Email: "dev@example.com", Username: "dev", Password: "x", GitHubID: 1}
+	db.Create(&user)
+	service := NewObsidianService(db, t.TempDir())
+	now := time.Date(2026, 5, 4, 9, 0, 0, 0, time.UTC)
+
+	vault := fstest.MapFS{
+		"Projects.md": {Data: []byte("Everything in flight.\n")},
+		"Projects/Alpha.md": {Data: []byte("---\ntags: [work, \"#alpha\"]\ndescription: First project\naliases:\n  - A\n---\n" +
+			"Depends on [[Beta]].\n![[diagram.png|300]]\n")},
+		"Projects/Beta.md":    {Data: []byte("Back to [[Alpha]].\n![photo](../assets/my%20photo.jpg)\n")},
+		"Inbox/Loose.md":      {Data: []byte("No front matter ---\n")},
+		"images/diagram.png":  {Data: []byte("png")},
+		"assets/my photo.jpg": {Data: []byte("jpg")},
+		".obsidian/app.md":    {Data: []byte("settings")},
+		".trash/Old.md":       {Data: []byte("gone")},
+	}
+	result, err := service.Import(user.ID, "work", vault, now)
+	if err != nil {
+		t.Fatalf("failed to import vault: %v", err)
+	}
+	// Four notes and the Inbox folder
+	if result.Created != 5 || result.Attachments != 2 || result.Skipped != 0 {
+		t.Fatalf("unexpected import result: %+v", result)
+	}
+
+	var alpha, projects, inbox models.Note
+	db.Preload("Tags").Where("title = ?", "Alpha").First(&alpha)
+	db.Where("title = ?", "Projects").First(&projects)
+	db.Where("title = ?", "Inbox").First(&inbox)
+	if projects.Content != "Everything in flight.\n" || alpha.ParentNoteID == nil || *alpha.ParentNoteID != projects.ID {
+		t.Fatalf("expected the folder note to be the parent: %+v / %+v", projects, alpha)
+	}
+	if alpha.Description != "First project" || len(alpha.Tags) != 2 || alpha.Properties["aliases"] == nil {
+		t.Fatalf("unexpected front matter mapping: %+v", alpha)
+	}
+	if !strings.HasPrefix(alpha.Content, "Depends on [[Beta]].\n![diagram.png](/api/v1/files/") {
+		t.Fatalf("unexpected imported content %q", alpha.Content)
+	}
+	var links []models.NoteLink
+	db.Where("note_id = ? AND target_id IS NOT NULL", alpha.ID).Find(&links)
+	if len(links) != 1 || links[0].Target != "Beta" {
+		t.Fatalf("expected the wikilink to resolve: %+v", links)
+	}
+	var loose models.Note
+	db.Where("title = ?", "Loose").First(&loose)
+	if loose.ParentNoteID == nil || *loose.ParentNoteID != inbox.ID || inbox.Content != "" {
+		t.Fatalf("expected an empty note for the folder: %+v / %+v", inbox, loose)
+	}
+
+	// Importing again changes nothing; a changed file updates its note
+	if result, err := service.Import(user.ID, "work", vault, now); err != nil || result.Created != 0 || result.Skipped != 4 {
+		t.Fatalf("unexpected repeated import: %+v (err %v)", result, err)
+	}
+	vault["Inbox/Loose.md"] = &fstest.MapFile{Data: []byte("Edited")}
+	if result, err := service.Import(user.ID, "work", vault, now); err != nil || result.Updated != 1 {
+		t.Fatalf("unexpected import of a change: %+v (err %v)", result, err)
+	}
+
+	data, err := service.Export(user.ID)
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	exported := readZip(t, data)
+	for _, name := range []string{"Projects.md", "Projects/Alpha.md", "Projects/Beta.md", "Inbox/Loose.md", "images/diagram.png", "assets/my photo.jpg"} {
+		if _, ok := exported[name]; !ok {
+			t.Fatalf("expected %s in the export, got %v", name, zipNames(exported))
+		}
+	}
+	if _, ok := exported["Inbox.md"]; ok {
+		t.Fatalf("expected no file for an empty folder note")
+	}
+	if text := exported["Projects/Alpha.md"]; !strings.Contains(text, "  - alpha\n") || !strings.Contains(text, "description: First project") ||
+		!strings.Contains(text, "![[images/diagram.png]]") {
+		t.Fatalf("unexpected exported note:\n%s", text)
+	}
+}
+
+func TestObsidianSync(t *testing.T) {
+	db := newTestDB(t, &models.NoteRevision{}, &models.NoteLink{}, &models.WikiPage{}, &models.File{}, &models.ObsidianFile{})
+
+	user := models.User{Email: "dev@example.com", Username: "dev", Password: "x", GitHubID: 1}
+	db.Create(&user)
+	service := NewObsidianService(db, t.TempDir())
+	dir := t.TempDir()
+	now := time.Date(2026, 5, 4, 9, 0, 0, 0, time.UTC)
+
+	write := func(name, content string, modified time.Time) {
+		t.Helper()
+		target := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(target), 0755)
+		if err := os.WriteFile(target, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+		os.Chtimes(target, modified, modified)
+	}
+	read := func(name string) string {
+		data, _ := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		return string(data)
+	}
+	note := func(title string) models.Note {
+		var note models.Note
+		db.Where("title = ?", title).First(&note)
+		return note
+	}
+	edit := func(title, content string) {
+		db.Model(&models.Note{}).Where("title = ?", title).Update("content", content)
+	}
+	sync := func() *ObsidianResult {
+		t.Helper()
+		result, err := service.Sync(user.ID, "vault", dir, now)
+		if err != nil {
+			t.Fatalf("failed to sync: %v", err)
+		}
+		return result
+	}
+
+	earlier := now.Add(-time.Hour)
+	for _, name := range []string{"A", "B", "C", "D", "E"} {
+		write("Notes/"+name+".md", name+" text", earlier)
+	}
+	existing := models.Note{UserID: user.ID, Title: "From Trackeep", Content: "Written here"}
+	db.Create(&existing)
+	if result := sync(); result.Created != 7 {
+		t.Fatalf("unexpected first sync: %+v", result)
+	}
+	if read("From Trackeep.md") != "Written here" {
+		t.Fatalf("expected the note to be written to the vault")
+	}
+	if result := sync(); result.Skipped != 6 || result.Updated != 0 {
+		t.Fatalf("expected nothing to change: %+v", result)
+	}
+
+	write("Notes/A.md", "A edited in Obsidian", now)
+	edit("B", "B edited in Trackeep")
+	write("Notes/C.md", "C edited in Obsidian", now)
+	edit("C", "C edited in Trackeep")
+	os.Remove(filepath.Join(dir, "Notes", "D.md"))
+	db.Delete(&models.Note{}, note("E").ID)
+	write("Notes/F.md", "New in Obsidian", now)
+
+	result := sync()
+	if result.Conflicts != 1 || result.Deleted != 2 || result.Created != 1 {
+		t.Fatalf("unexpected sync result: %+v", result)
+	}
+	if note("A").Content != "A edited in Obsidian" {
+		t.Fatalf("expected the vault change to reach the note")
+	}
+	if read("Notes/B.md") != "B edited in Trackeep" {
+		t.Fatalf("expected the note change to reach the vault")
+	}
+	if note("C").Content != "C edited in Obsidian" || read("Notes/C (conflict 2026-05-04 0900).md") != "C edited in Trackeep" {
+		t.Fatalf("expected a conflict copy of the Trackeep version")
+	}
+	if copied := note("C (conflict 2026-05-04 0900)"); copied.ID == 0 {
+		t.Fatalf("expected the conflict copy to be a note")
+	}
+	if note("D").ID != 0 {
+		t.Fatalf("expected the note of the deleted file to be deleted")
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".trash", "E.md")); err != nil {
+		t.Fatalf("expected the file of the deleted note in the trash: %v", err)
+	}
+	if note("F").ParentNoteID == nil {
+		t.Fatalf("expected the new file to be imported under its folder")
+	}
+	if result := sync(); result.Updated != 0 || result.Created != 0 || result.Conflicts != 0 {
+		t.Fatalf("expected the sync to settle: %+v", result)
+	}
+}
+
+func readZip(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("failed to open zip: %v", err)
+	}
+	files := map[string]string{}
+	for _, file := range archive.File {
+		reader, _ := file.Open()
+		content, _ := io.ReadAll(reader)
+		reader.Close()
+		files[file.Name] = string(content)
+	}
+	return files
+}
+
+func zipNames(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,456 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	maxArchiveResources    = 500
+	maxArchiveResourceSize = 5 << 20  // 5MB
+	maxArchiveTotalSize    = 50 << 20 // 50MB
+	maxCSSImportDepth      = 3
+)
+
+var (
+	cssURLPattern    = regexp.MustCompile(`url\(\s*(?:"([^"]*)"|'([^']*)'|([^)'"\s]*))\s*\)`)
+	cssImportPattern = regexp.MustCompile(`@import\s+(?:url\(\s*)?["']?([^"')\s;]+)["']?\s*\)?\s*([^;]*);`)
+)
+
+// PageArchive is a self-contained copy of a web page
+type PageArchive struct {
+	SourceURL  string
+	FinalURL   string
+	Title      string
+	HTML       []byte
+	CapturedAt time.Time
+
+	// Responses holds the page and every resource fetched while archiving,
+	// in fetch order, for writing a WARC file
+	Responses []*PageResponse
+	// FailedResources lists resource URLs that could not be inlined
+	FailedResources []string
+}
+
+// pageArchiver builds single-file HTML archives: stylesheets, images, fonts
+// and icons are inlined as data URIs, scripts and embedded frames are removed.
+type pageArchiver struct {
+	resources map[string]*archivedResource
+	totalSize int64
+	archive   *PageArchive
+}
+
+type archivedResource struct {
+	dataURI string
+	text    string // decoded body for stylesheets
+	ok      bool
+}
+
+// ArchivePage fetches a page and returns a self-contained archive of it
+func ArchivePage(ctx context.Context, pageURL string) (*PageArchive, error) {
+	archiver := &pageArchiver{resources: make(map[string]*archivedResource)}
+	return archiver.archivePage(ctx, pageURL)
+}
+
+func (a *pageArchiver) archivePage(ctx context.Context, pageURL string) (*PageArchive, error) {
+	page, err := FetchPage(ctx, pageURL, true)
+	if err != nil {
+		return nil, err
+	}
+	if page.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d: %s", page.StatusCode, http.StatusText(page.StatusCode))
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(page.ContentType)
+	if mediaType != "" && mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, fmt.Errorf("unsupported content type for snapshot: %s", mediaType)
+	}
+
+	a.archive = &PageArchive{
+		SourceURL:  pageURL,
+		FinalURL:   page.FinalURL,
+		CapturedAt: page.FetchedAt,
+		Responses:  []*PageResponse{page},
+	}
+
+	reader, err := charset.NewReader(bytes.NewReader(page.Body), page.ContentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode page: %w", err)
+	}
+
+	// Parse with scripting disabled so <noscript> fallbacks become real nodes
+	doc, err := xhtml.ParseWithOptions(reader, xhtml.ParseOptionEnableScripting(false))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page: %w", err)
+	}
+
+	base, err := url.Parse(page.FinalURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page URL: %w", err)
+	}
+	if href := findBaseHref(doc); href != "" {
+		if resolved, err := base.Parse(href); err == nil {
+			base = resolved
+		}
+	}
+
+	a.rewriteNode(ctx, doc, base)
+	a.addSnapshotHeader(doc)
+
+	var out bytes.Buffer
+	if err := xhtml.Render(&out, doc); err != nil {
+		return nil, fmt.Errorf("failed to render archive: %w", err)
+	}
+	a.archive.HTML = out.Bytes()
+
+	return a.archive, nil
+}
+
+// rewriteNode walks the tree, inlining resources and removing active content
+func (a *pageArchiver) rewriteNode(ctx context.Context, n *xhtml.Node, base *url.URL) {
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == xhtml.ElementNode && a.rewriteElement(ctx, child, base) {
+			n.RemoveChild(child)
+		} else {
+			a.rewriteNode(ctx, child, base)
+		}
+		child = next
+	}
+}
+
+// rewriteElement rewrites a single element and reports whether it should be removed
+func (a *pageArchiver) rewriteElement(ctx context.Context, n *xhtml.Node, base *url.URL) bool {
+	switch n.DataAtom {
+	case atom.Script, atom.Iframe, atom.Frame, atom.Frameset, atom.Object, atom.Embed, atom.Applet, atom.Base:
+		return true
+
+	case atom.Meta:
+		equiv := strings.ToLower(getAttr(n, "http-equiv"))
+		if equiv == "refresh" || equiv == "content-security-policy" || equiv == "content-type" || hasAttr(n, "charset") {
+			return true
+		}
+
+	case atom.Link:
+		rel := strings.ToLower(getAttr(n, "rel"))
+		href := getAttr(n, "href")
+		switch {
+		case strings.Contains(rel, "stylesheet"):
+			if strings.Contains(rel, "alternate") || href == "" {
+				return true
+			}
+			cssURL, err := base.Parse(href)
+			if err != nil {
+				return true
+			}
+			css, ok := a.fetchStylesheet(ctx, cssURL, 0)
+			if !ok {
+				return true
+			}
+			// Replace the link with an inline <style> element
+			n.Data = "style"
+			n.DataAtom = atom.Style
+			media := getAttr(n, "media")
+			n.Attr = nil
+			if media != "" {
+				n.Attr = append(n.Attr, xhtml.Attribute{Key: "media", Val: media})
+			}
+			n.AppendChild(&xhtml.Node{Type: xhtml.TextNode, Data: css})
+			return false
+		case strings.Contains(rel, "icon"):
+			a.inlineAttr(ctx, n, "href", base)
+			return false
+		case strings.Contains(rel, "preload"), strings.Contains(rel, "prefetch"),
+			strings.Contains(rel, "preconnect"), strings.Contains(rel, "dns-prefetch"),
+			strings.Contains(rel, "manifest"):
+			return true
+		}
+		absolutizeAttr(n, "href", base)
+
+	case atom.Style:
+		if n.FirstChild != nil && n.FirstChild.Type == xhtml.TextNode {
+			n.FirstChild.Data = a.rewriteCSS(ctx, n.FirstChild.Data, base, 0)
+		}
+
+	case atom.Img:
+		// Lazy loaders keep the real source in data attributes until a script runs
+		for _, lazy := range []string{"data-src", "data-original", "data-lazy-src"} {
+			if src := getAttr(n, lazy); src != "" {
+				setAttr(n, "src", src)
+				break
+			}
+		}
+		if getAttr(n, "src") == "" {
+			if candidate := firstSrcsetURL(getAttr(n, "srcset")); candidate != "" {
+				setAttr(n, "src", candidate)
+			}
+		}
+		a.inlineAttr(ctx, n, "src", base)
+		removeAttr(n, "srcset")
+		removeAttr(n, "sizes")
+		removeAttr(n, "loading")
+
+	case atom.Source:
+		if n.Parent != nil && n.Parent.DataAtom == atom.Picture {
+			// The <img> fallback inside <picture> carries the inlined image
+			return true
+		}
+		absolutizeAttr(n, "src", base)
+
+	case atom.Input:
+		if strings.EqualFold(getAttr(n, "type"), "image") {
+			a.inlineAttr(ctx, n, "src", base)
+		}
+
+	case atom.Video:
+		a.inlineAttr(ctx, n, "poster", base)
+		absolutizeAttr(n, "src", base)
+
+	case atom.Audio, atom.Track:
+		absolutizeAttr(n, "src", base)
+
+	case atom.A, atom.Area:
+		href := strings.TrimSpace(getAttr(n, "href"))
+		if strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			removeAttr(n, "href")
+		} else if !strings.HasPrefix(href, "#") {
+			absolutizeAttr(n, "href", base)
+		}
+
+	case atom.Form:
+		absolutizeAttr(n, "action", base)
+	}
+
+	// Drop inline event handlers and inline any url() references in style attributes
+	attrs := n.Attr[:0]
+	for _, attr := range n.Attr {
+		key := strings.ToLower(attr.Key)
+		if strings.HasPrefix(key, "on") {
+			continue
+		}
+		if key == "style" {
+			attr.Val = a.rewriteCSS(ctx, attr.Val, base, maxCSSImportDepth)
+		}
+		attrs = append(attrs, attr)
+	}
+	n.Attr = attrs
+
+	return false
+}
+
+// addSnapshotHeader declares the archive's charset and records its origin
+func (a *pageArchiver) addSnapshotHeader(doc *xhtml.Node) {
+	head := findElement(doc, atom.Head)
+	if head == nil {
+		return
+	}
+
+	if title := findElement(head, atom.Title); title != nil && title.FirstChild != nil {
+		a.archive.Title = strings.TrimSpace(title.FirstChild.Data)
+	}
+
+	source := &xhtml.Node{Type: xhtml.ElementNode, Data: "meta", DataAtom: atom.Meta, Attr: []xhtml.Attribute{
+		{Key: "name", Val: "trackeep-snapshot-source"},
+		{Key: "content", Val: a.archive.FinalURL},
+	}}
+	captured := &xhtml.Node{Type: xhtml.ElementNode, Data: "meta", DataAtom: atom.Meta, Attr: []xhtml.Attribute{
+		{Key: "name", Val: "trackeep-snapshot-date"},
+		{Key: "content", Val: a.archive.CapturedAt.Format(time.RFC3339)},
+	}}
+	meta := &xhtml.Node{Type: xhtml.ElementNode, Data: "meta", DataAtom: atom.Meta, Attr: []xhtml.Attribute{
+		{Key: "charset", Val: "utf-8"},
+	}}
+
+	head.InsertBefore(captured, head.FirstChild)
+	head.InsertBefore(source, head.FirstChild)
+	head.InsertBefore(meta, head.FirstChild)
+}
+
+// inlineAttr replaces a resource URL attribute with a data URI
+func (a *pageArchiver) inlineAttr(ctx context.Context, n *xhtml.Node, key string, base *url.URL) {
+	value := strings.TrimSpace(getAttr(n, key))
+	if value == "" || strings.HasPrefix(value, "data:") {
+		return
+	}
+	resourceURL, err := base.Parse(value)
+	if err != nil {
+		return
+	}
+	if resource := a.fetchResource(ctx, resourceURL); resource.ok {
+		setAttr(n, key, resource.dataURI)
+	} else {
+		setAttr(n, key, resourceURL.String())
+	}
+}
+
+// fetchStylesheet downloads a stylesheet and inlines its own references
+func (a *pageArchiver) fetchStylesheet(ctx context.Context, cssURL *url.URL, depth int) (string, bool) {
+	resource := a.fetchResource(ctx, cssURL)
+	if !resource.ok {
+		return "", false
+	}
+	return a.rewriteCSS(ctx, resource.text, cssURL, depth), true
+}
+
+// rewriteCSS inlines @import rules and url() references relative to base
+func (a *pageArchiver) rewriteCSS(ctx context.Context, css string, base *url.URL, depth int) string {
+	if depth < maxCSSImportDepth {
+		css = cssImportPattern.ReplaceAllStringFunc(css, func(rule string) string {
+			match := cssImportPattern.FindStringSubmatch(rule)
+			importURL, err := base.Parse(match[1])
+			if err != nil {
+				return ""
+			}
+			imported, ok := a.fetchStylesheet(ctx, importURL, depth+1)
+			if !ok {
+				return ""
+			}
+			if media := strings.TrimSpace(match[2]); media != "" {
+				return "@media " + media + " {\n" + imported + "\n}"
+			}
+			return imported
+		})
+	}
+
+	return cssURLPattern.ReplaceAllStringFunc(css, func(ref string) string {
+		match := cssURLPattern.FindStringSubmatch(ref)
+		value := strings.TrimSpace(match[1] + match[2] + match[3])
+		if value == "" || strings.HasPrefix(value, "data:") || strings.HasPrefix(value, "#") {
+			return ref
+		}
+		resourceURL, err := base.Parse(value)
+		if err != nil {
+			return ref
+		}
+		if resource := a.fetchResource(ctx, resourceURL); resource.ok {
+			return `url("` + resource.dataURI + `")`
+		}
+		return `url("` + resourceURL.String() + `")`
+	})
+}
+
+// fetchResource downloads a sub-resource once, respecting the archive limits
+func (a *pageArchiver) fetchResource(ctx context.Context, resourceURL *url.URL) *archivedResource {
+	resourceURL.Fragment = ""
+	key := resourceURL.String()
+	if resource, ok := a.resources[key]; ok {
+		return resource
+	}
+
+	resource := &archivedResource{}
+	a.resources[key] = resource
+
+	if resourceURL.Scheme != "http" && resourceURL.Scheme != "https" {
+		return resource
+	}
+	if len(a.resources) > maxArchiveResources || a.totalSize >= maxArchiveTotalSize || ctx.Err() != nil {
+		a.archive.FailedResources = append(a.archive.FailedResources, key)
+		return resource
+	}
+
+	page, err := FetchPage(ctx, key, true)
+	if err != nil || page.StatusCode != http.StatusOK || len(page.Body) > maxArchiveResourceSize {
+		a.archive.FailedResources = append(a.archive.FailedResources, key)
+		return resource
+	}
+
+	a.totalSize += int64(len(page.Body))
+	a.archive.Responses = append(a.archive.Responses, page)
+
+	mediaType, _, _ := mime.ParseMediaType(page.ContentType)
+	if mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(page.Body))
+	}
+
+	resource.ok = true
+	resource.text = string(page.Body)
+	resource.dataURI = "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(page.Body)
+	return resource
+}
+
+func findBaseHref(n *xhtml.Node) string {
+	if base := findElement(n, atom.Base); base != nil {
+		return getAttr(base, "href")
+	}
+	return ""
+}
+
+func findElement(n *xhtml.Node, a atom.Atom) *xhtml.Node {
+	if n.Type == xhtml.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := findElement(child, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func firstSrcsetURL(srcset string) string {
+	candidate := strings.TrimSpace(strings.Split(srcset, ",")[0])
+	if fields := strings.Fields(candidate); len(fields) > 0 {
+		return fields[0]
+	}
+	return ""
+}
+
+func absolutizeAttr(n *xhtml.Node, key string, base *url.URL) {
+	value := strings.TrimSpace(getAttr(n, key))
+	if value == "" || strings.HasPrefix(value, "data:") {
+		return
+	}
+	if resolved, err := base.Parse(value); err == nil {
+		setAttr(n, key, resolved.String())
+	}
+}
+
+func getAttr(n *xhtml.Node, key string) string {
+	for _, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *xhtml.Node, key string) bool {
+	for _, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			return true
+		}
+	}
+	return false
+}
+
+func setAttr(n *xhtml.Node, key, value string) {
+	for i, attr := range n.Attr {
+		if strings.EqualFold(attr.Key, key) {
+			n.Attr[i].Val = value
+			return
+		}
+	}
+	n.Attr = append(n.Attr, xhtml.Attribute{Key: key, Val: value})
+}
+
+func removeAttr(n *xhtml.Node, key string) {
+	attrs := n.Attr[:0]
+	for _, attr := range n.Attr {
+		if !strings.EqualFold(attr.Key, key) {
+			attrs = append(attrs, attr)
+		}
+	}
+	n.Attr = attrs
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/trackeep/backend/models"
+)
+
+// pngPixel is a minimal 1x1 PNG image
+var pngPixel = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89\x00\x00\x00\rIDATx\x9cc\xf8\x0f\x00\x00\x01\x01\x00\x05\x18\xd8N\x00\x00\x00\x00IEND\xaeB`\x82")
+
+func newArchiveTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/article", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, `<!DOCTYPE html><html><head><title>Archived Article</title>
+<link rel="stylesheet" href="/static/site.css">
+<script src="/static/app.js"></script>
+</head><body onload="track()">
+<p>Hello <a href="/next">next</a> <a href="javascript:alert(1)">bad</a></p>
+<img src="/static/pixel.png" srcset="/static/pixel.png 2x">
+<iframe src="https://ads.example.com/"></iframe>
+</body></html>`)
+	})
+	mux.HandleFunc("/static/site.css", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		io.WriteString(w, `@import "theme.css"; body { background: url(pixel.png); }`)
+	})
+	mux.HandleFunc("/static/theme.css", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/css")
+		io.WriteString(w, `h1 { color: red; }`)
+	})
+	mux.HandleFunc("/static/pixel.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngPixel)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestArchivePage_InlinesResources(t *testing.T) {
+	server := newArchiveTestServer()
+	defer server.Close()
+
+	archive, err := ArchivePage(context.Background(), server.URL+"/article")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	html := string(archive.HTML)
+	if archive.Title != "Archived Article" {
+		t.Fatalf("unexpected title: %q", archive.Title)
+	}
+	for _, unwanted := range []string{"<script", "<iframe", "onload", "javascript:", "/static/site.css", "srcset"} {
+		if strings.Contains(html, unwanted) {
+			t.Fatalf("archive should not contain %q:\n%s", unwanted, html)
+		}
+	}
+	for _, wanted := range []string{
+		"h1 { color: red; }",
+		`background: url("data:image/png;base64,`,
+		`<img src="data:image/png;base64,`,
+		`href="` + server.URL + `/next"`,
+		`<meta charset="utf-8"/>`,
+	} {
+		if !strings.Contains(html, wanted) {
+			t.Fatalf("archive should contain %q:\n%s", wanted, html)
+		}
+	}
+
+	// Page, two stylesheets and the image (fetched once)
+	if len(archive.Responses) != 4 {
+		t.Fatalf("expected 4 responses, got %d", len(archive.Responses))
+	}
+	if len(archive.FailedResources) != 0 {
+		t.Fatalf("unexpected failed resources: %v", archive.FailedResources)
+	}
+}
+
+func TestWriteWARC(t *testing.T) {
+	server := newArchiveTestServer()
+	defer server.Close()
+
+	archive, err := ArchivePage(context.Background(), server.URL+"/article")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteWARC(&buf, archive); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// gzip.Reader reads concatenated members as one stream by default
+	reader, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("invalid gzip output: %v", err)
+	}
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("failed to read WARC: %v", err)
+	}
+
+	warc := string(content)
+	if got := strings.Count(warc, "WARC/1.1\r\n"); got != 1+len(archive.Responses) {
+		t.Fatalf("expected %d records, got %d", 1+len(archive.Responses), got)
+	}
+	if !strings.Contains(warc, "WARC-Target-URI: "+server.URL+"/article\r\n") {
+		t.Fatalf("missing response record for page")
+	}
+	if !strings.Contains(warc, "HTTP/1.1 200 OK\r\n") {
+		t.Fatalf("missing HTTP status line")
+	}
+}
+
+func TestBookmarkSnapshotService_Versions(t *testing.T) {
+	server := newArchiveTestServer()
+	defer server.Close()
+
+	db := newTestDB(t, &models.BookmarkSnapshot{})
+
+	bookmark := models.Bookmark{UserID: 1, Title: "Article", URL: server.URL + "/article"}
+	if err := db.Create(&bookmark).Error; err != nil {
+		t.Fatalf("failed to seed bookmark: %v", err)
+	}
+
+	service := NewBookmarkSnapshotService(db, t.TempDir())
+	for version := 1; version <= 2; version++ {
+		snapshot := models.BookmarkSnapshot{UserID: 1, BookmarkID: bookmark.ID, Version: version, SourceURL: bookmark.URL, IncludeWARC: true}
+		if err := db.Create(&snapshot).Error; err != nil {
+			t.Fatalf("failed to create snapshot: %v", err)
+		}
+		service.processSnapshot(snapshot.ID)
+
+		if err := db.First(&snapshot, snapshot.ID).Error; err != nil {
+			t.Fatalf("failed to reload snapshot: %v", err)
+		}
+		if snapshot.Status != models.BookmarkSnapshotCompleted {
+			t.Fatalf("unexpected status %s: %s", snapshot.Status, snapshot.ErrorMessage)
+		}
+		if _, err := os.Stat(snapshot.FilePath); err != nil {
+			t.Fatalf("snapshot file missing: %v", err)
+		}
+		if snapshot.WARCSize == 0 || snapshot.ContentHash == "" {
+			t.Fatalf("unexpected snapshot: %+v", snapshot)
+		}
+	}
+
+	// Older versions are kept when re-archiving
+	var count int64
+	db.Model(&models.BookmarkSnapshot{}).Where("bookmark_id = ?", bookmark.ID).Count(&count)
+	if count != 2 {
+		t.Fatalf("expected 2 snapshot versions, got %d", count)
+	}
+}
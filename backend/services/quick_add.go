@@ -0,0 +1,780 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidQuickAdd = errors.New("invalid quick-add text")
+
+// Kinds of item a quick-add line creates
+const (
+	QuickAddTask  = "task"
+	QuickAddEvent = "event"
+)
+
+// Tasks without a time are due at the end of the day; events without a
+// duration last an hour
+const (
+	quickAddEndOfDayHour   = 23
+	quickAddEndOfDayMinute = 59
+	defaultEventDuration   = time.Hour
+)
+
+// QuickAddToken is a fragment of the text that was understood, so a preview
+// can highlight it
+type QuickAddToken struct {
+	Text string `json:"text"`
+	Type string `json:"type"` // date, time, priority, tag, recurrence, duration
+}
+
+// QuickAddResult is the parsed form of a quick-add line. Tasks get DueDate,
+// events StartTime and EndTime; times are in the user's timezone.
+type QuickAddResult struct {
+	Kind             string              `json:"kind"`
+	Title            string              `json:"title"`
+	DueDate          *time.Time          `json:"due_date,omitempty"`
+	StartTime        *time.Time          `json:"start_time,omitempty"`
+	EndTime          *time.Time          `json:"end_time,omitempty"`
+	AllDay           bool                `json:"all_day"`
+	Priority         models.TaskPriority `json:"priority,omitempty"`
+	Tags             []string            `json:"tags"`
+	Recurrence       string              `json:"recurrence,omitempty"`
+	EstimatedMinutes int                 `json:"estimated_minutes,omitempty"`
+	Timezone         string              `json:"timezone"`
+	Tokens           []QuickAddToken     `json:"tokens"`
+}
+
+var (
+	quickAddTagRegex      = regexp.MustCompile(`^#(\pL[\pL\pN_/-]*)$`)
+	quickAddISODateRegex  = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+	quickAddSlashRegex    = regexp.MustCompile(`^(\d{1,2})/(\d{1,2})(?:/(\d{2}|\d{4}))?$`)
+	quickAddOrdinalRegex  = regexp.MustCompile(`^(\d{1,2})(st|nd|rd|th)?$`)
+	quickAddClockRegex    = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm|a|p)$`)
+	quickAdd24hRegex      = regexp.MustCompile(`^([01]?\d|2[0-3]):([0-5]\d)$`)
+	quickAddHourRegex     = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?$`)
+	quickAddDurationRegex = regexp.MustCompile(`^(?:(\d+)h(?:rs?|ours?)?)?(?:(\d+)m(?:ins?|inutes?)?)?$`)
+)
+
+var quickAddPriorities = map[string]models.TaskPriority{
+	"!low": models.TaskPriorityLow, "!medium": models.TaskPriorityMedium, "!med": models.TaskPriorityMedium,
+	"!high": models.TaskPriorityHigh, "!urgent": models.TaskPriorityUrgent,
+	"!1": models.TaskPriorityUrgent, "!2": models.TaskPriorityHigh, "!3": models.TaskPriorityMedium, "!4": models.TaskPriorityLow,
+	"p1": models.TaskPriorityUrgent, "p2": models.TaskPriorityHigh, "p3": models.TaskPriorityMedium, "p4": models.TaskPriorityLow,
+	"!!!": models.TaskPriorityUrgent, "!!": models.TaskPriorityHigh,
+}
+
+var quickAddWeekdays = map[string]time.Weekday{
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tues": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "weds": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+	"sun": time.Sunday, "sunday": time.Sunday,
+}
+
+var quickAddMonths = map[string]time.Month{
+	"jan": time.January, "january": time.January, "feb": time.February, "february": time.February,
+	"mar": time.March, "march": time.March, "apr": time.April, "april": time.April, "may": time.May,
+	"jun": time.June, "june": time.June, "jul": time.July, "july": time.July, "aug": time.August, "august": time.August,
+	"sep": time.September, "sept": time.September, "september": time.September, "oct": time.October, "october": time.October,
+	"nov": time.November, "november": time.November, "dec": time.December, "december": time.December,
+}
+
+var quickAddNumbers = map[string]int{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6,
+	"seven": 7, "eight": 8, "nine": 9, "ten": 10, "eleven": 11, "twelve": 12,
+}
+
+// Words that introduce a date or time and belong to it
+var quickAddConnectors = map[string]bool{"at": true, "on": true, "by": true, "due": true}
+
+type quickAddParser struct {
+	now   time.Time
+	loc   *time.Location
+	words []string
+	used  []bool
+
+	date     *time.Time // local midnight
+	clock    *[2]int
+	at       *time.Time // exact instant, from "in 2 hours"
+	duration time.Duration
+	priority models.TaskPriority
+	tags     []string
+	rule     *RRule
+	tokens   []QuickAddToken
+}
+
+// ParseQuickAdd turns a line like "Review PR #42 tomorrow 3pm !high #work
+// every friday" into a task or event. Relative dates are resolved from now
+// in loc. A bare weekday is the next such day (today included); "next mon"
+// is the Monday of next week.
+func ParseQuickAdd(text, kind string, now time.Time, loc *time.Location) (*QuickAddResult, error) {
+	if kind == "" {
+		kind = QuickAddTask
+	}
+	if kind != QuickAddTask && kind != QuickAddEvent {
+		return nil, fmt.Errorf("%w: kind must be task or event", ErrInvalidQuickAdd)
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	p := &quickAddParser{now: now.In(loc), loc: loc, words: strings.Fields(text)}
+	p.used = make([]bool, len(p.words))
+	p.scan()
+
+	var title []string
+	for i, word := range p.words {
+		if !p.used[i] {
+			title = append(title, word)
+		}
+	}
+	result := &QuickAddResult{
+		Kind:             kind,
+		Title:            strings.Trim(strings.Join(title, " "), " ,;:-–"),
+		Priority:         p.priority,
+		Tags:             p.tags,
+		EstimatedMinutes: int(p.duration / time.Minute),
+		Timezone:         loc.String(),
+		Tokens:           p.tokens,
+	}
+	if result.Title == "" {
+		return nil, fmt.Errorf("%w: a title is required", ErrInvalidQuickAdd)
+	}
+	if result.Tags == nil {
+		result.Tags = []string{}
+	}
+	if result.Tokens == nil {
+		result.Tokens = []QuickAddToken{}
+	}
+
+	if p.rule != nil {
+		result.Recurrence = p.rule.String()
+		if _, err := ParseRRule(result.Recurrence, loc); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidQuickAdd, err)
+		}
+	}
+
+	when, hasTime := p.resolve()
+	switch {
+	case when == nil:
+	case kind == QuickAddTask:
+		due := *when
+		if !hasTime {
+			due = due.Add(quickAddEndOfDayHour*time.Hour + quickAddEndOfDayMinute*time.Minute)
+		}
+		result.DueDate = &due
+	case hasTime:
+		duration := p.duration
+		if duration == 0 {
+			duration = defaultEventDuration
+		}
+		start, end := *when, when.Add(duration)
+		result.StartTime, result.EndTime = &start, &end
+	default:
+		start, end := *when, when.AddDate(0, 0, 1)
+		result.StartTime, result.EndTime, result.AllDay = &start, &end, true
+	}
+	if kind == QuickAddEvent {
+		result.EstimatedMinutes = 0
+	}
+	return result, nil
+}
+
+// ParseRecurrencePhrase returns the RRULE in a phrase such as "every other
+// week at 9am", or "" when there is none
+func ParseRecurrencePhrase(text string) string {
+	p := &quickAddParser{now: time.Now(), loc: time.UTC, words: strings.Fields(text)}
+	p.used = make([]bool, len(p.words))
+	p.scan()
+	if p.rule == nil {
+		return ""
+	}
+	return p.rule.String()
+}
+
+// scan marks every recognised fragment. A connector such as "at" or "due"
+// right before a date or time is taken with it.
+func (p *quickAddParser) scan() {
+	matchers := []struct {
+		kind  string
+		match func(int) int
+	}{
+		{"priority", p.matchPriority},
+		{"tag", p.matchTag},
+		{"recurrence", p.matchRecurrence},
+		{"duration", p.matchDuration},
+		{"date", p.matchRelative},
+		{"date", p.matchDate},
+		{"time", p.matchTime},
+	}
+
+	for i := 0; i < len(p.words); {
+		consumed, kind := 0, ""
+		for _, matcher := range matchers {
+			if consumed = matcher.match(i); consumed > 0 {
+				kind = matcher.kind
+				break
+			}
+		}
+		if consumed == 0 {
+			i++
+			continue
+		}
+
+		start := i
+		if (kind == "date" || kind == "time") && i > 0 && !p.used[i-1] && quickAddConnectors[p.word(i-1)] {
+			start = i - 1
+		}
+		for j := start; j < i+consumed; j++ {
+			p.used[j] = true
+		}
+		p.tokens = append(p.tokens, QuickAddToken{Text: strings.Join(p.words[start:i+consumed], " "), Type: kind})
+		i += consumed
+	}
+}
+
+// word returns the i-th word in lower case without trailing punctuation,
+// or "" past the end
+func (p *quickAddParser) word(i int) string {
+	if i < 0 || i >= len(p.words) {
+		return ""
+	}
+	return strings.TrimRight(strings.ToLower(p.words[i]), ",.;:?)")
+}
+
+func (p *quickAddParser) today() time.Time {
+	return time.Date(p.now.Year(), p.now.Month(), p.now.Day(), 0, 0, 0, 0, p.loc)
+}
+
+func (p *quickAddParser) setDate(date time.Time) {
+	p.date = &date
+}
+
+func (p *quickAddParser) matchPriority(i int) int {
+	if priority, ok := quickAddPriorities[p.word(i)]; ok {
+		p.priority = priority
+		return 1
+	}
+	return 0
+}
+
+func (p *quickAddParser) matchTag(i int) int {
+	match := quickAddTagRegex.FindStringSubmatch(strings.TrimRight(p.words[i], ",.;:?)"))
+	if match == nil {
+		return 0
+	}
+	p.tags = append(p.tags, match[1])
+	return 1
+}
+
+func (p *quickAddParser) matchRecurrence(i int) int {
+	switch p.word(i) {
+	case "daily":
+		p.rule = &RRule{Freq: "DAILY", Interval: 1}
+		return 1
+	case "weekly":
+		p.rule = &RRule{Freq: "WEEKLY", Interval: 1}
+		return 1
+	case "monthly":
+		p.rule = &RRule{Freq: "MONTHLY", Interval: 1}
+		return 1
+	case "yearly", "annually":
+		p.rule = &RRule{Freq: "YEARLY", Interval: 1}
+		return 1
+	case "every":
+	default:
+		return 0
+	}
+
+	j, interval := i+1, 1
+	if p.word(j) == "other" {
+		interval, j = 2, j+1
+	} else if n, ok := quickAddCount(p.word(j)); ok && p.word(j) != "a" && p.word(j) != "an" {
+		interval, j = n, j+1
+	}
+
+	rule := &RRule{Interval: interval}
+	switch strings.TrimSuffix(p.word(j), "s") {
+	case "day":
+		rule.Freq = "DAILY"
+	case "week":
+		rule.Freq = "WEEKLY"
+	case "month":
+		rule.Freq = "MONTHLY"
+	case "year":
+		rule.Freq = "YEARLY"
+	case "weekday":
+		rule.Freq = "WEEKLY"
+		rule.ByDay = rruleDays(time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday)
+	case "weekend":
+		rule.Freq = "WEEKLY"
+		rule.ByDay = rruleDays(time.Saturday, time.Sunday)
+	}
+	if rule.Freq != "" {
+		p.rule = rule
+		return j + 1 - i
+	}
+
+	// "every 15th" is monthly on that day
+	if match := quickAddOrdinalRegex.FindStringSubmatch(p.word(j)); match != nil && match[2] != "" && interval == 1 {
+		day, _ := strconv.Atoi(match[1])
+		if day >= 1 && day <= 31 {
+			p.rule = &RRule{Freq: "MONTHLY", Interval: 1, ByMonthDay: []int{day}}
+			return j + 1 - i
+		}
+	}
+
+	// "every mon, wed and fri"
+	end := j
+	for {
+		day, ok := quickAddWeekdays[p.word(end)]
+		if !ok {
+			break
+		}
+		rule.ByDay = append(rule.ByDay, RRuleDay{Weekday: day})
+		end++
+		if p.word(end) == "and" {
+			if _, next := quickAddWeekdays[p.word(end+1)]; next {
+				end++
+			}
+		}
+	}
+	if len(rule.ByDay) == 0 {
+		return 0
+	}
+	rule.Freq = "WEEKLY"
+	p.rule = rule
+	return end - i
+}
+
+func (p *quickAddParser) matchDuration(i int) int {
+	if p.word(i) != "for" {
+		return 0
+	}
+	duration, consumed := p.parseDuration(i + 1)
+	if consumed == 0 {
+		return 0
+	}
+	p.duration = duration
+	return consumed + 1
+}
+
+// parseDuration reads "90m", "1h30m" or "2 hours" starting at word i
+func (p *quickAddParser) parseDuration(i int) (time.Duration, int) {
+	word := p.word(i)
+	if match := quickAddDurationRegex.FindStringSubmatch(word); match != nil && word != "" && (match[1] != "" || match[2] != "") {
+		hours, _ := strconv.Atoi(match[1])
+		minutes, _ := strconv.Atoi(match[2])
+		return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, 1
+	}
+	n, ok := quickAddCount(word)
+	if !ok {
+		return 0, 0
+	}
+	switch strings.TrimSuffix(p.word(i+1), "s") {
+	case "minute", "min":
+		return time.Duration(n) * time.Minute, 2
+	case "hour", "hr":
+		return time.Duration(n) * time.Hour, 2
+	}
+	return 0, 0
+}
+
+// matchRelative handles "in 2 weeks", "in an hour" and "in 30m"
+func (p *quickAddParser) matchRelative(i int) int {
+	if p.word(i) != "in" {
+		return 0
+	}
+	if duration, consumed := p.parseDuration(i + 1); consumed > 0 {
+		at := p.now.Add(duration)
+		p.at = &at
+		return consumed + 1
+	}
+	n, ok := quickAddCount(p.word(i + 1))
+	if !ok {
+		return 0
+	}
+	today := p.today()
+	switch strings.TrimSuffix(p.word(i+2), "s") {
+	case "day":
+		p.setDate(today.AddDate(0, 0, n))
+	case "week":
+		p.setDate(today.AddDate(0, 0, 7*n))
+	case "month":
+		p.setDate(today.AddDate(0, n, 0))
+	case "year":
+		p.setDate(today.AddDate(n, 0, 0))
+	default:
+		return 0
+	}
+	return 3
+}
+
+func (p *quickAddParser) matchDate(i int) int {
+	today := p.today()
+	word := p.word(i)
+
+	switch word {
+	case "today", "tod", "eod":
+		p.setDate(today)
+		return 1
+	case "tonight":
+		p.setDate(today)
+		if p.clock == nil {
+			p.clock = &[2]int{20, 0}
+		}
+		return 1
+	case "tomorrow", "tmr", "tmrw":
+		p.setDate(today.AddDate(0, 0, 1))
+		return 1
+	case "eow":
+		p.setDate(today.AddDate(0, 0, (int(time.Friday)-int(today.Weekday())+7)%7))
+		return 1
+	case "eom":
+		p.setDate(time.Date(today.Year(), today.Month()+1, 0, 0, 0, 0, 0, p.loc))
+		return 1
+	case "eoy":
+		p.setDate(time.Date(today.Year(), time.December, 31, 0, 0, 0, 0, p.loc))
+		return 1
+	case "end":
+		if p.word(i+1) != "of" {
+			return 0
+		}
+		switch p.word(i + 2) {
+		case "day":
+			p.setDate(today)
+		case "week":
+			p.setDate(today.AddDate(0, 0, (int(time.Friday)-int(today.Weekday())+7)%7))
+		case "month":
+			p.setDate(time.Date(today.Year(), today.Month()+1, 0, 0, 0, 0, 0, p.loc))
+		case "year":
+			p.setDate(time.Date(today.Year(), time.December, 31, 0, 0, 0, 0, p.loc))
+		default:
+			return 0
+		}
+		return 3
+	case "next":
+		// Weeks start on Monday
+		nextMonday := today.AddDate(0, 0, 7-(int(today.Weekday())+6)%7)
+		switch next := p.word(i + 1); next {
+		case "week":
+			p.setDate(nextMonday)
+		case "month":
+			p.setDate(time.Date(today.Year(), today.Month()+1, 1, 0, 0, 0, 0, p.loc))
+		case "year":
+			p.setDate(time.Date(today.Year()+1, time.January, 1, 0, 0, 0, 0, p.loc))
+		default:
+			day, ok := quickAddWeekdays[next]
+			if !ok {
+				return 0
+			}
+			p.setDate(nextMonday.AddDate(0, 0, (int(day)+6)%7))
+		}
+		return 2
+	case "this":
+		day, ok := quickAddWeekdays[p.word(i+1)]
+		if !ok {
+			return 0
+		}
+		p.setDate(upcomingWeekday(today, day))
+		return 2
+	}
+
+	if day, ok := quickAddWeekdays[word]; ok {
+		p.setDate(upcomingWeekday(today, day))
+		return 1
+	}
+
+	if match := quickAddISODateRegex.FindStringSubmatch(word); match != nil {
+		year, _ := strconv.Atoi(match[1])
+		month, _ := strconv.Atoi(match[2])
+		day, _ := strconv.Atoi(match[3])
+		return p.setCalendarDate(year, time.Month(month), day, true, 1)
+	}
+	if match := quickAddSlashRegex.FindStringSubmatch(word); match != nil {
+		month, _ := strconv.Atoi(match[1])
+		day, _ := strconv.Atoi(match[2])
+		year, hasYear := 0, match[3] != ""
+		if hasYear {
+			year, _ = strconv.Atoi(match[3])
+			if year < 100 {
+				year += 2000
+			}
+		}
+		return p.setCalendarDate(year, time.Month(month), day, hasYear, 1)
+	}
+
+	// "jan 5", "january 5th 2027", "5 jan", "5th of january"
+	if month, ok := quickAddMonths[word]; ok {
+		if match := quickAddOrdinalRegex.FindStringSubmatch(p.word(i + 1)); match != nil {
+			day, _ := strconv.Atoi(match[1])
+			if year, err := strconv.Atoi(p.word(i + 2)); err == nil && year >= 1000 {
+				return p.setCalendarDate(year, month, day, true, 3)
+			}
+			return p.setCalendarDate(0, month, day, false, 2)
+		}
+		return 0
+	}
+	if match := quickAddOrdinalRegex.FindStringSubmatch(word); match != nil {
+		next := i + 1
+		if p.word(next) == "of" {
+			next++
+		}
+		month, ok := quickAddMonths[p.word(next)]
+		if !ok {
+			return 0
+		}
+		day, _ := strconv.Atoi(match[1])
+		if year, err := strconv.Atoi(p.word(next + 1)); err == nil && year >= 1000 {
+			return p.setCalendarDate(year, month, day, true, next+2-i)
+		}
+		return p.setCalendarDate(0, month, day, false, next+1-i)
+	}
+	return 0
+}
+
+// setCalendarDate sets an absolute date. Without a year the next such date
+// is meant, so dates already past roll over to next year.
+func (p *quickAddParser) setCalendarDate(year int, month time.Month, day int, hasYear bool, consumed int) int {
+	today := p.today()
+	if !hasYear {
+		year = today.Year()
+	}
+	if month < time.January || month > time.December || day < 1 || day > 31 {
+		return 0
+	}
+	date := time.Date(year, month, day, 0, 0, 0, 0, p.loc)
+	if date.Day() != day {
+		return 0
+	}
+	if !hasYear && date.Before(today) {
+		date = date.AddDate(1, 0, 0)
+	}
+	p.setDate(date)
+	return consumed
+}
+
+func (p *quickAddParser) matchTime(i int) int {
+	word := p.word(i)
+
+	switch word {
+	case "noon", "midday":
+		p.clock = &[2]int{12, 0}
+		return 1
+	case "morning", "afternoon", "evening":
+		// Only as part of a date: "tomorrow morning", "this evening"
+		if i == 0 || !p.used[i-1] || len(p.tokens) == 0 || p.tokens[len(p.tokens)-1].Type != "date" {
+			return 0
+		}
+		p.clock = &[2]int{map[string]int{"morning": 9, "afternoon": 14, "evening": 18}[word], 0}
+		return 1
+	}
+
+	if match := quickAddClockRegex.FindStringSubmatch(word); match != nil {
+		return p.setClock(match[1], match[2], match[3], 1)
+	}
+	if match := quickAdd24hRegex.FindStringSubmatch(word); match != nil {
+		return p.setClock(match[1], match[2], "", 1)
+	}
+	if match := quickAddHourRegex.FindStringSubmatch(word); match != nil {
+		switch suffix := p.word(i + 1); suffix {
+		case "am", "pm", "a.m", "p.m":
+			return p.setClock(match[1], match[2], suffix[:1], 2)
+		}
+	}
+	return 0
+}
+
+func (p *quickAddParser) setClock(hourText, minuteText, meridiem string, consumed int) int {
+	hour, _ := strconv.Atoi(hourText)
+	minute := 0
+	if minuteText != "" {
+		minute, _ = strconv.Atoi(minuteText)
+	}
+	if minute > 59 {
+		return 0
+	}
+	if meridiem != "" {
+		if hour < 1 || hour > 12 {
+			return 0
+		}
+		hour %= 12
+		if meridiem[0] == 'p' {
+			hour += 12
+		}
+	}
+	if hour > 23 {
+		return 0
+	}
+	p.clock = &[2]int{hour, minute}
+	return consumed
+}
+
+// resolve combines the parsed date, time and recurrence into one local time.
+// A time without a date is today, or tomorrow once it has passed; a
+// recurrence without a date starts on its first matching day.
+func (p *quickAddParser) resolve() (*time.Time, bool) {
+	if p.at != nil {
+		return p.at, true
+	}
+
+	date, explicit := p.today(), p.date != nil
+	if explicit {
+		date = *p.date
+	} else if p.rule != nil {
+		date = p.firstOccurrence(date)
+	} else if p.clock == nil {
+		return nil, false
+	}
+
+	if p.clock == nil {
+		return &date, false
+	}
+	when := time.Date(date.Year(), date.Month(), date.Day(), p.clock[0], p.clock[1], 0, 0, p.loc)
+	if !explicit && p.rule == nil && when.Before(p.now) {
+		when = when.AddDate(0, 0, 1)
+	}
+	return &when, true
+}
+
+func (p *quickAddParser) firstOccurrence(today time.Time) time.Time {
+	for offset := 0; offset < 366; offset++ {
+		day := today.AddDate(0, 0, offset)
+		if len(p.rule.ByMonthDay) > 0 && day.Day() != p.rule.ByMonthDay[0] {
+			continue
+		}
+		if len(p.rule.ByDay) > 0 && !p.rule.matchesWeekday(day.Weekday()) {
+			continue
+		}
+		return day
+	}
+	return today
+}
+
+func rruleDays(weekdays ...time.Weekday) []RRuleDay {
+	days := make([]RRuleDay, len(weekdays))
+	for i, weekday := range weekdays {
+		days[i] = RRuleDay{Weekday: weekday}
+	}
+	return days
+}
+
+// upcomingWeekday is the next given weekday, today included
+func upcomingWeekday(today time.Time, day time.Weekday) time.Time {
+	return today.AddDate(0, 0, (int(day)-int(today.Weekday())+7)%7)
+}
+
+func quickAddCount(word string) (int, bool) {
+	if n, ok := quickAddNumbers[word]; ok {
+		return n, true
+	}
+	n, err := strconv.Atoi(word)
+	return n, err == nil && n > 0 && n < 1000
+}
+
+// QuickAddInput is a quick-add request
+type QuickAddInput struct {
+	Text string `json:"text" binding:"required"`
+	Kind string `json:"kind"`
+}
+
+// QuickAddCreated is what a committed quick-add created
+type QuickAddCreated struct {
+	Parsed *QuickAddResult       `json:"parsed"`
+	Task   *models.Task          `json:"task,omitempty"`
+	Event  *models.CalendarEvent `json:"event,omitempty"`
+}
+
+// QuickAddService parses quick-add lines for a user and creates the result
+type QuickAddService struct {
+	db *gorm.DB
+}
+
+// NewQuickAddService creates a new quick-add service
+func NewQuickAddService(db *gorm.DB) *QuickAddService {
+	return &QuickAddService{db: db}
+}
+
+// Preview parses the text in the user's timezone without saving anything
+func (s *QuickAddService) Preview(userID uint, input QuickAddInput, now time.Time) (*QuickAddResult, error) {
+	return ParseQuickAdd(input.Text, input.Kind, now, UserLocation(s.db, userID))
+}
+
+// Create parses the text and saves the task or calendar event. Tags are
+// created as needed; calendar events have no tags, so they are ignored there.
+func (s *QuickAddService) Create(userID uint, input QuickAddInput, now time.Time) (*QuickAddCreated, error) {
+	parsed, err := s.Preview(userID, input, now)
+	if err != nil {
+		return nil, err
+	}
+	created := &QuickAddCreated{Parsed: parsed}
+
+	priority := parsed.Priority
+	if priority == "" {
+		priority = models.TaskPriorityMedium
+	}
+
+	if parsed.Kind == QuickAddEvent {
+		if parsed.StartTime == nil {
+			return nil, fmt.Errorf("%w: events need a date or time", ErrInvalidQuickAdd)
+		}
+		event := models.CalendarEvent{
+			UserID:    userID,
+			Title:     parsed.Title,
+			StartTime: *parsed.StartTime,
+			EndTime:   *parsed.EndTime,
+			IsAllDay:  parsed.AllDay,
+			Priority:  string(priority),
+			Recurring: parsed.Recurrence != "",
+			Rrule:     parsed.Recurrence,
+		}
+		if err := s.db.Create(&event).Error; err != nil {
+			return nil, err
+		}
+		created.Event = &event
+		return created, nil
+	}
+
+	task := models.Task{
+		UserID:           userID,
+		Title:            parsed.Title,
+		Priority:         priority,
+		DueDate:          parsed.DueDate,
+		EstimatedMinutes: parsed.EstimatedMinutes,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		for _, name := range parsed.Tags {
+			tag, err := FindOrCreateTag(tx, userID, name)
+			if err != nil {
+				return err
+			}
+			task.Tags = append(task.Tags, *tag)
+		}
+		return tx.Create(&task).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if parsed.Recurrence != "" {
+		if _, err := NewTaskRecurrenceService(s.db).SetRecurrence(userID, task.ID, TaskRecurrenceInput{Rule: parsed.Recurrence}, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.db.Preload("Tags").Preload("Recurrence").First(&task, task.ID).Error; err != nil {
+		return nil, err
+	}
+	created.Task = &task
+	return created, nil
+}
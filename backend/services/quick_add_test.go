@@ -0,0 +1,121 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/trackeep/backend/models"
+)
+
+func TestParseQuickAdd(t *testing.T) {
+	prague, err := time.LoadLocation("Europe/Prague")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+	// A Wednesday morning
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, prague)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, prague)
+	}
+
+	tests := []struct {
+		text       string
+		kind       string
+		title      string
+		when       time.Time
+		end        time.Time
+		allDay     bool
+		priority   models.TaskPriority
+		tags       []string
+		recurrence string
+	}{
+		{text: "Review PR #42 tomorrow 3pm !high #work every friday", title: "Review PR #42", when: at(3, 5, 15, 0),
+			priority: models.TaskPriorityHigh, tags: []string{"work"}, recurrence: "FREQ=WEEKLY;BYDAY=FR"},
+		{text: "Pay rent eom", title: "Pay rent", when: at(3, 31, 23, 59)},
+		{text: "Call the bank next mon", title: "Call the bank", when: at(3, 9, 23, 59)},
+		{text: "Renew passport in 2 weeks p1", title: "Renew passport", when: at(3, 18, 23, 59), priority: models.TaskPriorityUrgent},
+		{text: "Standup at 9:30", title: "Standup", when: at(3, 5, 9, 30)},
+		{text: "Water plants fri", title: "Water plants", when: at(3, 6, 23, 59)},
+		{text: "Gym every mon and thu at 7am", title: "Gym", when: at(3, 5, 7, 0), recurrence: "FREQ=WEEKLY;BYDAY=MO,TH"},
+		{text: "Invoice clients every 15th", title: "Invoice clients", when: at(3, 15, 23, 59), recurrence: "FREQ=MONTHLY;BYMONTHDAY=15"},
+		{text: "Offsite planning jan 5", title: "Offsite planning", when: time.Date(2027, 1, 5, 23, 59, 0, 0, prague)},
+		{text: "Dinner with Anna fri 7pm for 2h", kind: QuickAddEvent, title: "Dinner with Anna", when: at(3, 6, 19, 0), end: at(3, 6, 21, 0)},
+		{text: "Conference on march 20th", kind: QuickAddEvent, title: "Conference", when: at(3, 20, 0, 0), end: at(3, 21, 0, 0), allDay: true},
+	}
+
+	for _, tt := range tests {
+		parsed, err := ParseQuickAdd(tt.text, tt.kind, now, prague)
+		if err != nil {
+			t.Fatalf("%q: unexpected error %v", tt.text, err)
+		}
+		if parsed.Title != tt.title || parsed.Priority != tt.priority || parsed.Recurrence != tt.recurrence || parsed.AllDay != tt.allDay {
+			t.Fatalf("%q: unexpected result %+v", tt.text, parsed)
+		}
+		if len(parsed.Tags) != len(tt.tags) || (len(tt.tags) > 0 && parsed.Tags[0] != tt.tags[0]) {
+			t.Fatalf("%q: unexpected tags %v", tt.text, parsed.Tags)
+		}
+
+		when := parsed.DueDate
+		if tt.kind == QuickAddEvent {
+			when = parsed.StartTime
+			if parsed.EndTime == nil || !parsed.EndTime.Equal(tt.end) {
+				t.Fatalf("%q: expected end %v, got %v", tt.text, tt.end, parsed.EndTime)
+			}
+		}
+		if when == nil || !when.Equal(tt.when) {
+			t.Fatalf("%q: expected %v, got %v", tt.text, tt.when, when)
+		}
+	}
+
+	if _, err := ParseQuickAdd("!high #work tomorrow", "", now, prague); !errors.Is(err, ErrInvalidQuickAdd) {
+		t.Fatalf("expected a missing title to be rejected, got %v", err)
+	}
+}
+
+func TestQuickAddService(t *testing.T) {
+	db := newTestDB(t, &models.CalendarEvent{})
+
+	user := models.User{Email: "planner@example.com", Username: "planner", Password: "x", Timezone: "America/New_York"}
+	db.Create(&user)
+	newYork := UserLocation(db, user.ID)
+	now := time.Date(2026, 3, 4, 15, 0, 0, 0, time.UTC)
+
+	service := NewQuickAddService(db)
+	input := QuickAddInput{Text: "Review PR #42 tomorrow 3pm !high #work every friday"}
+	preview, err := service.Preview(user.ID, input, now)
+	if err != nil {
+		t.Fatalf("failed to preview: %v", err)
+	}
+	if preview.Timezone != "America/New_York" || !preview.DueDate.Equal(time.Date(2026, 3, 5, 15, 0, 0, 0, newYork)) || len(preview.Tokens) != 5 {
+		t.Fatalf("unexpected preview: %+v", preview)
+	}
+	var count int64
+	db.Model(&models.Task{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("preview should not save anything")
+	}
+
+	created, err := service.Create(user.ID, input, now)
+	if err != nil {
+		t.Fatalf("failed to create task: %v", err)
+	}
+	task := created.Task
+	if task == nil || task.Priority != models.TaskPriorityHigh || len(task.Tags) != 1 || task.Tags[0].Name != "work" ||
+		task.Recurrence == nil || task.Recurrence.Rule != "FREQ=WEEKLY;BYDAY=FR" {
+		t.Fatalf("unexpected task: %+v", task)
+	}
+
+	created, err = service.Create(user.ID, QuickAddInput{Text: "Team lunch tomorrow noon for 90m", Kind: QuickAddEvent}, now)
+	if err != nil {
+		t.Fatalf("failed to create event: %v", err)
+	}
+	event := created.Event
+	if event == nil || event.Title != "Team lunch" || !event.StartTime.Equal(time.Date(2026, 3, 5, 12, 0, 0, 0, newYork)) ||
+		event.EndTime.Sub(event.StartTime) != 90*time.Minute {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+	if _, err := service.Create(user.ID, QuickAddInput{Text: "Someday", Kind: QuickAddEvent}, now); !errors.Is(err, ErrInvalidQuickAdd) {
+		t.Fatalf("expected an event without a date to be rejected, got %v", err)
+	}
+}
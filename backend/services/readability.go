@@ -0,0 +1,804 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// readingWordsPerMinute matches the reading speed used for wiki pages and scraped content
+const readingWordsPerMinute = 225
+
+// Article is the readable main content of a page
+type Article struct {
+	Title       string     `json:"title"`
+	Byline      string     `json:"byline"`
+	SiteName    string     `json:"site_name"`
+	Excerpt     string     `json:"excerpt"`
+	Image       string     `json:"image"`
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+
+	// Content is sanitized HTML, TextContent the same content as plain text
+	Content     string `json:"content"`
+	TextContent string `json:"text_content"`
+	WordCount   int    `json:"word_count"`
+	ReadingTime int    `json:"reading_time"` // minutes
+}
+
+var (
+	unlikelyCandidatePattern = regexp.MustCompile(`(?i)-ad-|ai2html|banner|breadcrumbs|combx|comment|community|cookie|cover-wrap|disqus|extra|footer|gdpr|header|legends|menu|newsletter|related|remark|replies|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|subscribe|supplemental|ad-break|agegate|pagination|pager|popup|yom-remote`)
+	maybeCandidatePattern    = regexp.MustCompile(`(?i)and|article|body|column|content|main|shadow`)
+	positiveWeightPattern    = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|text|blog|story`)
+	negativeWeightPattern    = regexp.MustCompile(`(?i)-ad-|hidden|\bhid\b|banner|combx|comment|com-|contact|foot|footer|footnote|gdpr|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+	bylinePattern            = regexp.MustCompile(`(?i)byline|author|dateline|writtenby|p-author`)
+	whitespacePattern        = regexp.MustCompile(`\s+`)
+)
+
+// articleJSONLDTypes are schema.org types describing an article
+var articleJSONLDTypes = map[string]bool{
+	"Article": true, "NewsArticle": true, "BlogPosting": true, "TechArticle": true,
+	"ScholarlyArticle": true, "Report": true, "AnalysisNewsArticle": true,
+	"OpinionNewsArticle": true, "ReportageNewsArticle": true, "SocialMediaPosting": true,
+}
+
+// readerDroppedTags are removed together with their content
+var readerDroppedTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Iframe: true, atom.Object: true,
+	atom.Embed: true, atom.Form: true, atom.Input: true, atom.Button: true, atom.Select: true,
+	atom.Textarea: true, atom.Nav: true, atom.Aside: true, atom.Footer: true, atom.Svg: true,
+	atom.Canvas: true, atom.Link: true, atom.Meta: true, atom.Template: true, atom.Dialog: true,
+	atom.Head: true, atom.Title: true,
+}
+
+// readerAllowedTags are kept in the sanitized output; other elements are unwrapped
+var readerAllowedTags = map[atom.Atom]bool{
+	atom.A: true, atom.Abbr: true, atom.B: true, atom.Blockquote: true, atom.Br: true,
+	atom.Caption: true, atom.Code: true, atom.Dd: true, atom.Del: true, atom.Div: true,
+	atom.Dl: true, atom.Dt: true, atom.Em: true, atom.Figcaption: true, atom.Figure: true,
+	atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true, atom.Hr: true,
+	atom.I: true, atom.Img: true, atom.Ins: true, atom.Kbd: true, atom.Li: true, atom.Mark: true,
+	atom.Ol: true, atom.P: true, atom.Pre: true, atom.Q: true, atom.S: true, atom.Small: true,
+	atom.Strong: true, atom.Sub: true, atom.Sup: true, atom.Table: true, atom.Tbody: true,
+	atom.Td: true, atom.Tfoot: true, atom.Th: true, atom.Thead: true, atom.Tr: true,
+	atom.U: true, atom.Ul: true,
+}
+
+// readerBlockTags start a new paragraph in the plain text rendering
+var readerBlockTags = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true,
+	atom.H6: true, atom.Blockquote: true, atom.Pre: true, atom.Ul: true, atom.Ol: true,
+	atom.Li: true, atom.Table: true, atom.Tr: true, atom.Figure: true, atom.Figcaption: true,
+	atom.Dl: true, atom.Dt: true, atom.Dd: true, atom.Hr: true,
+}
+
+// ExtractArticle finds the main content of an HTML page, readability style:
+// boilerplate is stripped, paragraphs are scored to find the best content
+// container, and the result is sanitized. Title, byline, site name and
+// publish date come from JSON-LD and meta tags, falling back to the markup.
+func ExtractArticle(body []byte, contentType, pageURL string) (*Article, error) {
+	reader, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode page: %w", err)
+	}
+	doc, err := xhtml.Parse(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page: %w", err)
+	}
+
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page URL: %w", err)
+	}
+
+	article := &Article{}
+	extractArticleMetadata(doc, article)
+
+	bodyNode := findElement(doc, atom.Body)
+	if bodyNode == nil {
+		return nil, fmt.Errorf("page has no body")
+	}
+
+	byline := prepareReaderDocument(bodyNode)
+	if article.Byline == "" {
+		article.Byline = byline
+	}
+
+	content := selectArticleContent(bodyNode)
+	cleanArticleContent(content, article.Title)
+	sanitizeArticleNode(content, base)
+
+	var out bytes.Buffer
+	for child := content.FirstChild; child != nil; child = child.NextSibling {
+		if err := xhtml.Render(&out, child); err != nil {
+			return nil, fmt.Errorf("failed to render article: %w", err)
+		}
+	}
+
+	article.Content = strings.TrimSpace(out.String())
+	article.TextContent = articleText(content)
+	article.WordCount = len(strings.Fields(article.TextContent))
+	if article.WordCount > 0 {
+		article.ReadingTime = int(math.Ceil(float64(article.WordCount) / readingWordsPerMinute))
+	}
+	if article.Excerpt == "" {
+		article.Excerpt = truncateWords(article.TextContent, 50)
+	}
+
+	return article, nil
+}
+
+// HTMLToText returns the readable text of an HTML fragment or document,
+// with block elements separated by blank lines
+func HTMLToText(fragment string) string {
+	doc, err := xhtml.Parse(strings.NewReader(fragment))
+	if err != nil {
+		return ""
+	}
+	removeNodes(doc, func(n *xhtml.Node) bool { return readerDroppedTags[n.DataAtom] })
+	return articleText(doc)
+}
+
+// extractArticleMetadata fills title, byline, site name, excerpt, image and
+// publish date from JSON-LD, then meta tags, then the <title> element
+func extractArticleMetadata(doc *xhtml.Node, article *Article) {
+	var published string
+
+	walkElements(doc, func(n *xhtml.Node) {
+		if n.DataAtom != atom.Script || !strings.Contains(strings.ToLower(getAttr(n, "type")), "ld+json") || n.FirstChild == nil {
+			return
+		}
+		var data interface{}
+		if err := json.Unmarshal([]byte(n.FirstChild.Data), &data); err != nil {
+			return
+		}
+		ld := findJSONLDArticle(data)
+		if ld == nil {
+			return
+		}
+		setIfEmpty(&article.Title, jsonLDString(ld["headline"]))
+		setIfEmpty(&article.Byline, jsonLDName(ld["author"]))
+		setIfEmpty(&article.Excerpt, jsonLDString(ld["description"]))
+		setIfEmpty(&article.Image, jsonLDImage(ld["image"]))
+		setIfEmpty(&published, jsonLDString(ld["datePublished"]))
+		if publisher, ok := ld["publisher"].(map[string]interface{}); ok {
+			setIfEmpty(&article.SiteName, jsonLDString(publisher["name"]))
+		}
+	})
+
+	metas := map[string]string{}
+	walkElements(doc, func(n *xhtml.Node) {
+		if n.DataAtom != atom.Meta {
+			return
+		}
+		content := strings.TrimSpace(getAttr(n, "content"))
+		if content == "" {
+			return
+		}
+		for _, key := range []string{"property", "name", "itemprop"} {
+			if name := strings.ToLower(strings.TrimSpace(getAttr(n, key))); name != "" {
+				if _, exists := metas[name]; !exists {
+					metas[name] = content
+				}
+			}
+		}
+	})
+	firstMeta := func(names ...string) string {
+		for _, name := range names {
+			if value := metas[name]; value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	setIfEmpty(&article.Title, firstMeta("og:title", "twitter:title", "dc.title", "title"))
+	setIfEmpty(&article.Byline, firstMeta("author", "article:author", "parsely-author", "dc.creator", "citation_author", "sailthru.author"))
+	setIfEmpty(&article.SiteName, firstMeta("og:site_name", "application-name"))
+	setIfEmpty(&article.Excerpt, firstMeta("og:description", "description", "twitter:description", "dc.description"))
+	setIfEmpty(&article.Image, firstMeta("og:image", "twitter:image"))
+	setIfEmpty(&published, firstMeta("article:published_time", "datepublished", "date", "pubdate", "dc.date", "citation_publication_date", "parsely-pub-date", "sailthru.date"))
+
+	if article.Title == "" {
+		if title := findElement(doc, atom.Title); title != nil {
+			article.Title = innerText(title)
+		}
+	}
+	if published == "" {
+		if t := findElement(doc, atom.Time); t != nil {
+			published = getAttr(t, "datetime")
+		}
+	}
+	// Profile URLs are not bylines
+	if strings.HasPrefix(article.Byline, "http://") || strings.HasPrefix(article.Byline, "https://") {
+		article.Byline = ""
+	}
+
+	article.PublishedAt = parseImportTime(published)
+}
+
+func findJSONLDArticle(data interface{}) map[string]interface{} {
+	switch value := data.(type) {
+	case []interface{}:
+		for _, item := range value {
+			if found := findJSONLDArticle(item); found != nil {
+				return found
+			}
+		}
+	case map[string]interface{}:
+		if jsonLDHasArticleType(value["@type"]) {
+			return value
+		}
+		if graph, ok := value["@graph"]; ok {
+			return findJSONLDArticle(graph)
+		}
+	}
+	return nil
+}
+
+func jsonLDHasArticleType(value interface{}) bool {
+	switch t := value.(type) {
+	case string:
+		return articleJSONLDTypes[t]
+	case []interface{}:
+		for _, item := range t {
+			if s, ok := item.(string); ok && articleJSONLDTypes[s] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func jsonLDString(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return strings.TrimSpace(s)
+	}
+	return ""
+}
+
+// jsonLDName reads a person or organization, or a list of them
+func jsonLDName(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case map[string]interface{}:
+		return jsonLDString(v["name"])
+	case []interface{}:
+		var names []string
+		for _, item := range v {
+			if name := jsonLDName(item); name != "" {
+				names = append(names, name)
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+	return ""
+}
+
+func jsonLDImage(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		return jsonLDString(v["url"])
+	case []interface{}:
+		if len(v) > 0 {
+			return jsonLDImage(v[0])
+		}
+	}
+	return ""
+}
+
+// prepareReaderDocument removes scripts, hidden elements and unlikely
+// content candidates, returning a byline found along the way
+func prepareReaderDocument(body *xhtml.Node) string {
+	var byline string
+
+	removeNodes(body, func(n *xhtml.Node) bool {
+		if readerDroppedTags[n.DataAtom] || isHiddenNode(n) {
+			return true
+		}
+
+		matchString := getAttr(n, "class") + " " + getAttr(n, "id")
+
+		if byline == "" && (strings.EqualFold(getAttr(n, "rel"), "author") ||
+			strings.Contains(strings.ToLower(getAttr(n, "itemprop")), "author") ||
+			bylinePattern.MatchString(matchString)) {
+			if text := innerText(n); text != "" && len(text) < 100 {
+				byline = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(text, "By "), "by "))
+				return true
+			}
+		}
+
+		if n.DataAtom != atom.Body && n.DataAtom != atom.A && n.DataAtom != atom.Article && n.DataAtom != atom.Main &&
+			unlikelyCandidatePattern.MatchString(matchString) && !maybeCandidatePattern.MatchString(matchString) &&
+			!hasAncestor(n, atom.Table) && !hasAncestor(n, atom.Code) {
+			return true
+		}
+
+		return strings.EqualFold(getAttr(n, "role"), "navigation") ||
+			strings.EqualFold(getAttr(n, "role"), "complementary") ||
+			strings.EqualFold(getAttr(n, "role"), "dialog")
+	})
+
+	return byline
+}
+
+// selectArticleContent scores paragraphs and returns a container holding the
+// best candidate together with related siblings
+func selectArticleContent(body *xhtml.Node) *xhtml.Node {
+	scores := make(map[*xhtml.Node]float64)
+	var candidates []*xhtml.Node
+
+	initialize := func(n *xhtml.Node) {
+		if _, ok := scores[n]; ok {
+			return
+		}
+		score := float64(classWeight(n))
+		switch n.DataAtom {
+		case atom.Div, atom.Article, atom.Main, atom.Section:
+			score += 5
+		case atom.Pre, atom.Td, atom.Blockquote:
+			score += 3
+		case atom.Address, atom.Ol, atom.Ul, atom.Dl, atom.Dd, atom.Dt, atom.Li, atom.Form:
+			score -= 3
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Th:
+			score -= 5
+		}
+		scores[n] = score
+		candidates = append(candidates, n)
+	}
+
+	walkElements(body, func(n *xhtml.Node) {
+		switch n.DataAtom {
+		case atom.P, atom.Pre, atom.Td:
+		case atom.Div:
+			if hasBlockChildren(n) {
+				return
+			}
+		default:
+			return
+		}
+
+		text := innerText(n)
+		if len(text) < 25 {
+			return
+		}
+
+		contentScore := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text)/100), 3)
+		level := 0
+		for ancestor := n.Parent; ancestor != nil && ancestor.Type == xhtml.ElementNode && level < 3; ancestor = ancestor.Parent {
+			initialize(ancestor)
+			divider := 1.0
+			if level == 1 {
+				divider = 2
+			} else if level > 1 {
+				divider = float64(level * 3)
+			}
+			scores[ancestor] += contentScore / divider
+			level++
+		}
+	})
+
+	var top *xhtml.Node
+	for _, candidate := range candidates {
+		scores[candidate] *= 1 - linkDensity(candidate)
+		if top == nil || scores[candidate] > scores[top] {
+			top = candidate
+		}
+	}
+
+	container := &xhtml.Node{Type: xhtml.ElementNode, Data: "div", DataAtom: atom.Div}
+	if top == nil || top.DataAtom == atom.Body || top.Parent == nil {
+		moveChildren(body, container)
+		return container
+	}
+
+	// Include siblings that look like part of the same article
+	topScore := scores[top]
+	threshold := math.Max(10, topScore*0.2)
+	topClass := getAttr(top, "class")
+
+	var siblings []*xhtml.Node
+	for sibling := top.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+		siblings = append(siblings, sibling)
+	}
+	for _, sibling := range siblings {
+		include := sibling == top
+		if !include && sibling.Type == xhtml.ElementNode {
+			bonus := 0.0
+			if topClass != "" && getAttr(sibling, "class") == topClass {
+				bonus = topScore * 0.2
+			}
+			if score, ok := scores[sibling]; ok && score+bonus >= threshold {
+				include = true
+			} else if sibling.DataAtom == atom.P {
+				text := innerText(sibling)
+				density := linkDensity(sibling)
+				if (len(text) > 80 && density < 0.25) || (len(text) > 0 && density == 0 && strings.Contains(text, ". ")) {
+					include = true
+				}
+			}
+		}
+		if include {
+			top.Parent.RemoveChild(sibling)
+			container.AppendChild(sibling)
+		}
+	}
+
+	return container
+}
+
+// cleanArticleContent removes leftover boilerplate from the selected content
+func cleanArticleContent(content *xhtml.Node, title string) {
+	normalizedTitle := strings.ToLower(strings.TrimSpace(title))
+
+	removeNodes(content, func(n *xhtml.Node) bool {
+		switch n.DataAtom {
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			text := strings.ToLower(innerText(n))
+			// The title is shown separately
+			if text != "" && text == normalizedTitle {
+				return true
+			}
+			return classWeight(n) < 0 || linkDensity(n) > 0.33
+
+		case atom.Div, atom.Section, atom.Table, atom.Ul, atom.Ol, atom.Header:
+			return shouldCleanConditionally(n)
+		}
+		return false
+	})
+}
+
+// shouldCleanConditionally applies readability's heuristics for containers
+// that are often navigation, link lists or widgets
+func shouldCleanConditionally(n *xhtml.Node) bool {
+	if hasAncestor(n, atom.Pre) || hasAncestor(n, atom.Code) {
+		return false
+	}
+
+	text := innerText(n)
+	weight := classWeight(n)
+	if weight < 0 {
+		return true
+	}
+	if strings.Count(text, ",") >= 10 {
+		return false
+	}
+
+	var paragraphs, images, items, embeds int
+	walkElements(n, func(child *xhtml.Node) {
+		switch child.DataAtom {
+		case atom.P:
+			paragraphs++
+		case atom.Img:
+			images++
+		case atom.Li:
+			items++
+		case atom.Video, atom.Audio:
+			embeds++
+		}
+	})
+	items -= 100 // lists are judged on their link density below
+
+	density := linkDensity(n)
+	isList := n.DataAtom == atom.Ul || n.DataAtom == atom.Ol
+
+	switch {
+	case images > 1 && float64(paragraphs)/float64(images) < 0.5 && !hasAncestor(n, atom.Figure):
+		return true
+	case !isList && items > paragraphs:
+		return true
+	case len(text) < 25 && (images == 0 || images > 2) && embeds == 0:
+		return true
+	case weight < 25 && density > 0.2:
+		return true
+	case weight >= 25 && density > 0.5:
+		return true
+	}
+	return false
+}
+
+// sanitizeArticleNode keeps only allowlisted elements and attributes and
+// resolves links and images against the page URL
+func sanitizeArticleNode(n *xhtml.Node, base *url.URL) {
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+
+		switch child.Type {
+		case xhtml.CommentNode:
+			n.RemoveChild(child)
+			child = next
+			continue
+		case xhtml.ElementNode:
+		default:
+			child = next
+			continue
+		}
+
+		if readerDroppedTags[child.DataAtom] {
+			n.RemoveChild(child)
+			child = next
+			continue
+		}
+
+		sanitizeArticleNode(child, base)
+
+		switch child.DataAtom {
+		case atom.H1:
+			child.Data, child.DataAtom = "h2", atom.H2
+		case atom.Article, atom.Section, atom.Main, atom.Header:
+			child.Data, child.DataAtom = "div", atom.Div
+		}
+
+		if !readerAllowedTags[child.DataAtom] {
+			// Unwrap: keep the children in place of the element
+			for grandchild := child.FirstChild; grandchild != nil; {
+				following := grandchild.NextSibling
+				child.RemoveChild(grandchild)
+				n.InsertBefore(grandchild, child)
+				grandchild = following
+			}
+			n.RemoveChild(child)
+			child = next
+			continue
+		}
+
+		child.Attr = sanitizeArticleAttrs(child, base)
+
+		if child.DataAtom == atom.Img && getAttr(child, "src") == "" {
+			n.RemoveChild(child)
+		} else if (child.DataAtom == atom.P || child.DataAtom == atom.Div) && innerText(child) == "" &&
+			findElement(child, atom.Img) == nil {
+			n.RemoveChild(child)
+		}
+		child = next
+	}
+}
+
+func sanitizeArticleAttrs(n *xhtml.Node, base *url.URL) []xhtml.Attribute {
+	resolve := func(value string) string {
+		resolved, err := base.Parse(strings.TrimSpace(value))
+		if err != nil {
+			return ""
+		}
+		switch resolved.Scheme {
+		case "http", "https", "mailto":
+			return resolved.String()
+		}
+		return ""
+	}
+
+	var attrs []xhtml.Attribute
+	switch n.DataAtom {
+	case atom.A:
+		if href := getAttr(n, "href"); href != "" {
+			if strings.HasPrefix(href, "#") {
+				attrs = append(attrs, xhtml.Attribute{Key: "href", Val: href})
+			} else if resolved := resolve(href); resolved != "" {
+				attrs = append(attrs, xhtml.Attribute{Key: "href", Val: resolved})
+			}
+		}
+	case atom.Img:
+		src := getAttr(n, "src")
+		for _, lazy := range []string{"data-src", "data-original", "data-lazy-src"} {
+			if value := getAttr(n, lazy); value != "" {
+				src = value
+				break
+			}
+		}
+		if src == "" || strings.HasPrefix(src, "data:") {
+			src = firstSrcsetURL(getAttr(n, "srcset"))
+		}
+		if resolved := resolve(src); resolved != "" && !strings.HasPrefix(resolved, "mailto:") {
+			attrs = append(attrs, xhtml.Attribute{Key: "src", Val: resolved})
+		}
+		if alt := getAttr(n, "alt"); alt != "" {
+			attrs = append(attrs, xhtml.Attribute{Key: "alt", Val: alt})
+		}
+	case atom.Td, atom.Th:
+		for _, key := range []string{"colspan", "rowspan"} {
+			if value := getAttr(n, key); value != "" {
+				attrs = append(attrs, xhtml.Attribute{Key: key, Val: value})
+			}
+		}
+	}
+	if title := getAttr(n, "title"); title != "" {
+		attrs = append(attrs, xhtml.Attribute{Key: "title", Val: title})
+	}
+	return attrs
+}
+
+// articleText renders a node as plain text with paragraphs separated by blank lines
+func articleText(n *xhtml.Node) string {
+	var (
+		blocks  []string
+		current strings.Builder
+		pre     int
+	)
+	flush := func() {
+		text := current.String()
+		if pre == 0 {
+			text = strings.TrimSpace(whitespacePattern.ReplaceAllString(text, " "))
+		} else {
+			text = strings.Trim(text, "\n")
+		}
+		if text != "" {
+			blocks = append(blocks, text)
+		}
+		current.Reset()
+	}
+
+	var walk func(*xhtml.Node)
+	walk = func(node *xhtml.Node) {
+		switch node.Type {
+		case xhtml.TextNode:
+			current.WriteString(node.Data)
+			return
+		case xhtml.ElementNode:
+			if readerDroppedTags[node.DataAtom] {
+				return
+			}
+			if node.DataAtom == atom.Br {
+				current.WriteString("\n")
+				return
+			}
+		}
+
+		block := node.Type == xhtml.ElementNode && readerBlockTags[node.DataAtom]
+		if block {
+			flush()
+		}
+		if node.DataAtom == atom.Pre {
+			pre++
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if block {
+			flush()
+		}
+		if node.DataAtom == atom.Pre {
+			pre--
+		}
+	}
+	walk(n)
+	flush()
+
+	return strings.Join(blocks, "\n\n")
+}
+
+// innerText returns the whitespace-collapsed text of a node
+func innerText(n *xhtml.Node) string {
+	var b strings.Builder
+	var walk func(*xhtml.Node)
+	walk = func(node *xhtml.Node) {
+		if node.Type == xhtml.TextNode {
+			b.WriteString(node.Data)
+			b.WriteString(" ")
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+	return strings.TrimSpace(whitespacePattern.ReplaceAllString(b.String(), " "))
+}
+
+// linkDensity is the share of a node's text that sits inside links
+func linkDensity(n *xhtml.Node) float64 {
+	textLength := len(innerText(n))
+	if textLength == 0 {
+		return 0
+	}
+	linkLength := 0
+	walkElements(n, func(child *xhtml.Node) {
+		if child.DataAtom == atom.A {
+			linkLength += len(innerText(child))
+		}
+	})
+	return float64(linkLength) / float64(textLength)
+}
+
+// classWeight scores class and id names that hint at content (+25) or boilerplate (-25)
+func classWeight(n *xhtml.Node) int {
+	weight := 0
+	for _, value := range []string{getAttr(n, "class"), getAttr(n, "id")} {
+		if value == "" {
+			continue
+		}
+		if negativeWeightPattern.MatchString(value) {
+			weight -= 25
+		}
+		if positiveWeightPattern.MatchString(value) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+func hasBlockChildren(n *xhtml.Node) bool {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != xhtml.ElementNode {
+			continue
+		}
+		switch child.DataAtom {
+		case atom.Div, atom.P, atom.Table, atom.Ul, atom.Ol, atom.Pre, atom.Blockquote, atom.Section,
+			atom.Article, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Figure, atom.Dl:
+			return true
+		}
+	}
+	return false
+}
+
+func isHiddenNode(n *xhtml.Node) bool {
+	if hasAttr(n, "hidden") || strings.EqualFold(getAttr(n, "aria-hidden"), "true") {
+		return true
+	}
+	style := strings.ReplaceAll(strings.ToLower(getAttr(n, "style")), " ", "")
+	return strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden")
+}
+
+func hasAncestor(n *xhtml.Node, a atom.Atom) bool {
+	for parent := n.Parent; parent != nil; parent = parent.Parent {
+		if parent.DataAtom == a {
+			return true
+		}
+	}
+	return false
+}
+
+// walkElements calls fn for every element below n in document order
+func walkElements(n *xhtml.Node, fn func(*xhtml.Node)) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == xhtml.ElementNode {
+			fn(child)
+		}
+		walkElements(child, fn)
+	}
+}
+
+// removeNodes removes every element below n for which remove returns true
+func removeNodes(n *xhtml.Node, remove func(*xhtml.Node) bool) {
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == xhtml.ElementNode && remove(child) {
+			n.RemoveChild(child)
+		} else {
+			removeNodes(child, remove)
+		}
+		child = next
+	}
+}
+
+func moveChildren(from, to *xhtml.Node) {
+	for child := from.FirstChild; child != nil; {
+		next := child.NextSibling
+		from.RemoveChild(child)
+		to.AppendChild(child)
+		child = next
+	}
+}
+
+func setIfEmpty(target *string, value string) {
+	if *target == "" {
+		*target = strings.TrimSpace(value)
+	}
+}
+
+func truncateWords(text string, limit int) string {
+	words := strings.Fields(text)
+	if len(words) <= limit {
+		return strings.Join(words, " ")
+	}
+	return strings.Join(words[:limit], " ") + "…"
+}
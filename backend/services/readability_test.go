@@ -0,0 +1,112 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const readabilityTestPage = `<!DOCTYPE html>
+<html>
+<head>
+  <title>Why Go Interfaces Are Small | Example Blog</title>
+  <meta property="og:site_name" content="Example Blog">
+  <meta name="description" content="A short essay on interface design.">
+  <script type="application/ld+json">
+  {"@context": "https://schema.org", "@graph": [
+    {"@type": "WebSite", "name": "Example Blog"},
+    {"@type": "BlogPosting", "headline": "Why Go Interfaces Are Small",
+     "author": [{"@type": "Person", "name": "Ada Lovelace"}, {"@type": "Person", "name": "Alan Turing"}],
+     "datePublished": "2024-03-05T10:00:00Z"}
+  ]}
+  </script>
+  <script>window.tracking = true;</script>
+</head>
+<body>
+  <header class="site-header"><nav><a href="/">Home</a> <a href="/about">About</a></nav></header>
+  <div class="sidebar"><ul><li><a href="/a">Popular post one</a></li><li><a href="/b">Popular post two</a></li></ul></div>
+  <div id="main-content" class="post">
+    <h1>Why Go Interfaces Are Small</h1>
+    <p>Interfaces in Go are satisfied implicitly, which means that a type does not need to declare which interfaces it implements, and small interfaces compose well.</p>
+    <p onclick="steal()">The io.Reader interface has a single method, yet it is implemented by files, network connections, buffers, compressors and many more types across the standard library.</p>
+    <p>Keeping interfaces small makes them easy to implement, easy to mock in tests, and easy to combine into larger ones when a caller really needs more behaviour. See <a href="/posts/embedding">embedding</a> or <a href="javascript:alert(1)">this</a>.</p>
+    <img src="/images/diagram.png" alt="Diagram">
+    <div style="display:none">Hidden tracking text that should never appear in the article.</div>
+    <pre><code>type Reader interface {
+    Read(p []byte) (n int, err error)
+}</code></pre>
+  </div>
+  <div class="comments"><p>First! This comment section, with its opinions, is not part of the article at all.</p></div>
+  <footer><p>Copyright Example Blog, all rights reserved, no part may be reproduced.</p></footer>
+</body>
+</html>`
+
+func TestExtractArticle(t *testing.T) {
+	article, err := ExtractArticle([]byte(readabilityTestPage), "text/html; charset=utf-8", "https://blog.example.com/posts/interfaces")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if article.Title != "Why Go Interfaces Are Small" {
+		t.Fatalf("unexpected title: %q", article.Title)
+	}
+	if article.Byline != "Ada Lovelace, Alan Turing" {
+		t.Fatalf("unexpected byline: %q", article.Byline)
+	}
+	if article.SiteName != "Example Blog" {
+		t.Fatalf("unexpected site name: %q", article.SiteName)
+	}
+	if article.PublishedAt == nil || !article.PublishedAt.Equal(time.Date(2024, 3, 5, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected published date: %v", article.PublishedAt)
+	}
+
+	for _, want := range []string{"satisfied implicitly", "io.Reader interface", "Read(p []byte)"} {
+		if !strings.Contains(article.TextContent, want) {
+			t.Fatalf("expected text to contain %q, got:\n%s", want, article.TextContent)
+		}
+	}
+	for _, unwanted := range []string{"Popular post", "First!", "Copyright", "Hidden tracking", "window.tracking", "Home"} {
+		if strings.Contains(article.TextContent, unwanted) || strings.Contains(article.Content, unwanted) {
+			t.Fatalf("expected %q to be removed, got:\n%s", unwanted, article.Content)
+		}
+	}
+
+	for _, want := range []string{`href="https://blog.example.com/posts/embedding"`, `src="https://blog.example.com/images/diagram.png"`, "<pre>"} {
+		if !strings.Contains(article.Content, want) {
+			t.Fatalf("expected content to contain %s, got:\n%s", want, article.Content)
+		}
+	}
+	for _, unsafe := range []string{"onclick", "javascript:", "<script", "<h1"} {
+		if strings.Contains(article.Content, unsafe) {
+			t.Fatalf("expected %s to be sanitized, got:\n%s", unsafe, article.Content)
+		}
+	}
+
+	if article.WordCount != len(strings.Fields(article.TextContent)) || article.ReadingTime != 1 {
+		t.Fatalf("unexpected word count %d or reading time %d", article.WordCount, article.ReadingTime)
+	}
+}
+
+func TestExtractArticle_MetaFallbacks(t *testing.T) {
+	page := `<html><head>
+		<meta name="author" content="Grace Hopper">
+		<meta property="article:published_time" content="2023-11-02">
+		</head><body><article>
+		<p class="byline">By Someone Else</p>
+		<p>Compilers translate programs written in a high level language into machine code, and the first ones were met with a great deal of scepticism.</p>
+		</article></body></html>`
+
+	article, err := ExtractArticle([]byte(page), "text/html", "https://example.com/compilers")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if article.Byline != "Grace Hopper" {
+		t.Fatalf("expected meta author to win over the DOM byline, got %q", article.Byline)
+	}
+	if article.PublishedAt == nil || article.PublishedAt.Format("2006-01-02") != "2023-11-02" {
+		t.Fatalf("unexpected published date: %v", article.PublishedAt)
+	}
+	if strings.Contains(article.TextContent, "Someone Else") {
+		t.Fatalf("expected byline to be removed from the content, got %q", article.TextContent)
+	}
+}
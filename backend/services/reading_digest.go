@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+// ReadingDigestOptions configures the reading digest scheduler
+type ReadingDigestOptions struct {
+	Interval time.Duration // how often the scheduler looks for due digests
+	Mailer   Mailer        // defaults to SendMail
+}
+
+// ReadingDigestScheduler emails users who opted in their daily reading
+// queue once a day, at the hour they picked in their own timezone
+type ReadingDigestScheduler struct {
+	db      *gorm.DB
+	opts    ReadingDigestOptions
+	service *ReadingQueueService
+
+	sweeping sync.Mutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewReadingDigestScheduler creates a digest scheduler, filling in defaults for unset options
+func NewReadingDigestScheduler(db *gorm.DB, opts ReadingDigestOptions) *ReadingDigestScheduler {
+	if opts.Interval <= 0 {
+		opts.Interval = 15 * time.Minute
+	}
+	if opts.Mailer == nil {
+		opts.Mailer = SendMail
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ReadingDigestScheduler{
+		db:      db,
+		opts:    opts,
+		service: NewReadingQueueService(db),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+// Start sends digests in the background until Stop is called
+func (rd *ReadingDigestScheduler) Start() {
+	go func() {
+		ticker := time.NewTicker(rd.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := rd.RunOnce(time.Now()); err != nil {
+					log.Printf("Reading digest run failed: %v", err)
+				}
+			case <-rd.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background loop
+func (rd *ReadingDigestScheduler) Stop() {
+	rd.cancel()
+}
+
+// RunOnce sends every digest that is due and returns how many were sent.
+// Overlapping runs are skipped; a failed send is retried on the next run.
+func (rd *ReadingDigestScheduler) RunOnce(now time.Time) (int, error) {
+	if !rd.sweeping.TryLock() {
+		return 0, nil
+	}
+	defer rd.sweeping.Unlock()
+
+	var prefs []models.ReadingQueuePreference
+	if err := rd.db.Where("digest_enabled = ?", true).Find(&prefs).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, pref := range prefs {
+		if rd.ctx.Err() != nil {
+			break
+		}
+
+		var user models.User
+		if err := rd.db.Select("id", "email", "timezone").First(&user, pref.UserID).Error; err != nil {
+			continue
+		}
+		loc := loadLocation(user.Timezone)
+		if now.In(loc).Hour() < pref.DigestHour {
+			continue
+		}
+		if pref.LastDigestSentAt != nil && !pref.LastDigestSentAt.Before(startOfLocalDay(now, loc)) {
+			continue
+		}
+
+		entries, err := rd.service.MarkQueueShown(pref.UserID, now)
+		if err != nil {
+			log.Printf("Failed to build reading digest for user %d: %v", pref.UserID, err)
+			continue
+		}
+		if len(entries) > 0 {
+			subject := "Your reading queue for " + now.In(loc).Format("Mon, Jan 2")
+			if err := rd.opts.Mailer(user.Email, subject, formatReadingDigest(entries)); err != nil {
+				log.Printf("Failed to send reading digest to user %d: %v", pref.UserID, err)
+				continue
+			}
+			sent++
+		}
+
+		if err := rd.db.Model(&models.ReadingQueuePreference{}).Where("id = ?", pref.ID).
+			Update("last_digest_sent_at", now).Error; err != nil {
+			return sent, err
+		}
+	}
+
+	return sent, nil
+}
+
+// formatReadingDigest renders the queue as a plain-text email body
+func formatReadingDigest(entries []ReadingQueueEntry) string {
+	var b strings.Builder
+	b.WriteString("Hello,\n\nHere is what is waiting in your reading queue today:\n\n")
+	for i, entry := range entries {
+		fmt.Fprintf(&b, "%d. %s\n   %s\n", i+1, entry.Bookmark.Title, entry.Bookmark.URL)
+		if entry.Bookmark.ReadingTime > 0 {
+			fmt.Fprintf(&b, "   %d min read\n", entry.Bookmark.ReadingTime)
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("Happy reading,\nTrackeep\n")
+	return b.String()
+}
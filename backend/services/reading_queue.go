@@ -0,0 +1,522 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidSnooze is returned when a snooze does not end in the future
+	ErrInvalidSnooze = errors.New("snooze must end in the future")
+	// ErrInvalidReadingQueuePreference is returned for out-of-range settings
+	ErrInvalidReadingQueuePreference = errors.New("invalid reading queue preference")
+)
+
+const (
+	defaultDailyQueueSize = 5
+	maxDailyQueueSize     = 50
+
+	// forgottenAfter is how old an unread, never surfaced bookmark must be
+	// before it is treated as forgotten
+	forgottenAfter = 14 * 24 * time.Hour
+)
+
+// resurfaceIntervals is the wait before a bookmark that was shown but not
+// read comes back, indexed by how often it has been shown
+var resurfaceIntervals = []time.Duration{
+	24 * time.Hour,
+	3 * 24 * time.Hour,
+	7 * 24 * time.Hour,
+	14 * 24 * time.Hour,
+	30 * 24 * time.Hour,
+	60 * 24 * time.Hour,
+}
+
+// Reasons a bookmark is in the queue
+const (
+	ReadingReasonPriority  = "priority_tag"
+	ReadingReasonSnoozed   = "back_from_snooze"
+	ReadingReasonResurface = "resurfaced"
+	ReadingReasonForgotten = "forgotten"
+	ReadingReasonQuickRead = "quick_read"
+	ReadingReasonNew       = "new"
+)
+
+// ReadingQueueEntry is one bookmark of the daily queue
+type ReadingQueueEntry struct {
+	Bookmark     models.Bookmark `json:"bookmark"`
+	Score        float64         `json:"score"`
+	Reason       string          `json:"reason"`
+	SurfaceCount int             `json:"surface_count"`
+}
+
+// ReadingStats summarizes a user's reading
+type ReadingStats struct {
+	Unread       int64   `json:"unread"`
+	Snoozed      int64   `json:"snoozed"`
+	ReadToday    int64   `json:"read_today"`
+	ReadThisWeek int64   `json:"read_this_week"`
+	TotalMinutes float64 `json:"total_minutes"` // time spent reading bookmarks, from content analytics
+	StreakDays   int     `json:"streak_days"`   // consecutive days with at least one bookmark read
+}
+
+// ReadingQueuePreferenceInput updates reading-queue settings; nil fields are kept
+type ReadingQueuePreferenceInput struct {
+	DailyCount    *int           `json:"daily_count"`
+	TagPriorities map[string]int `json:"tag_priorities"`
+	DigestEnabled *bool          `json:"digest_enabled"`
+	DigestHour    *int           `json:"digest_hour"`
+}
+
+// ReadingQueueService orders unread bookmarks into a daily reading queue
+type ReadingQueueService struct {
+	db *gorm.DB
+}
+
+// NewReadingQueueService creates a new reading queue service
+func NewReadingQueueService(db *gorm.DB) *ReadingQueueService {
+	return &ReadingQueueService{db: db}
+}
+
+// Preferences returns the user's settings, or the defaults if none are saved
+func (s *ReadingQueueService) Preferences(userID uint) (*models.ReadingQueuePreference, error) {
+	var pref models.ReadingQueuePreference
+	err := s.db.Where("user_id = ?", userID).First(&pref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.ReadingQueuePreference{UserID: userID, DailyCount: defaultDailyQueueSize, DigestHour: 8}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+// UpdatePreferences saves the user's settings
+func (s *ReadingQueueService) UpdatePreferences(userID uint, input ReadingQueuePreferenceInput) (*models.ReadingQueuePreference, error) {
+	if input.DailyCount != nil && (*input.DailyCount < 1 || *input.DailyCount > maxDailyQueueSize) {
+		return nil, fmt.Errorf("%w: daily_count must be between 1 and %d", ErrInvalidReadingQueuePreference, maxDailyQueueSize)
+	}
+	if input.DigestHour != nil && (*input.DigestHour < 0 || *input.DigestHour > 23) {
+		return nil, fmt.Errorf("%w: digest_hour must be between 0 and 23", ErrInvalidReadingQueuePreference)
+	}
+
+	pref, err := s.Preferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	if input.DailyCount != nil {
+		pref.DailyCount = *input.DailyCount
+	}
+	if input.DigestEnabled != nil {
+		pref.DigestEnabled = *input.DigestEnabled
+	}
+	if input.DigestHour != nil {
+		pref.DigestHour = *input.DigestHour
+	}
+	if input.TagPriorities != nil {
+		pref.TagPriorities = make(map[string]int, len(input.TagPriorities))
+		for name, priority := range input.TagPriorities {
+			if key := tagKey(name); key != "" && priority != 0 {
+				pref.TagPriorities[key] = priority
+			}
+		}
+	}
+
+	if err := s.db.Save(pref).Error; err != nil {
+		return nil, err
+	}
+	return pref, nil
+}
+
+// DailyQueue returns today's reading queue without changing it. Bookmarks
+// already shown today keep their place; the rest is filled with the
+// best-scoring bookmarks that are due. Scores are taken at the start of the
+// user's day, so the queue is the same on every request through the day.
+func (s *ReadingQueueService) DailyQueue(userID uint, now time.Time) ([]ReadingQueueEntry, error) {
+	today, picked, _, err := s.dailyQueue(userID, now)
+	if err != nil {
+		return nil, err
+	}
+	return mergeQueueEntries(today, picked), nil
+}
+
+// MarkQueueShown returns today's queue like DailyQueue and records the
+// bookmarks shown in it for the first time today, scheduling them to
+// resurface later if they stay unread
+func (s *ReadingQueueService) MarkQueueShown(userID uint, now time.Time) ([]ReadingQueueEntry, error) {
+	today, picked, items, err := s.dailyQueue(userID, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.recordSurfaced(userID, picked, items, now); err != nil {
+		return nil, err
+	}
+	for i := range picked {
+		picked[i].SurfaceCount++
+	}
+	return mergeQueueEntries(today, picked), nil
+}
+
+// dailyQueue splits today's queue into the bookmarks already shown today
+// and the ones picked to fill it up
+func (s *ReadingQueueService) dailyQueue(userID uint, now time.Time) ([]ReadingQueueEntry, []ReadingQueueEntry, map[uint]*models.ReadingQueueItem, error) {
+	pref, err := s.Preferences(userID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	startOfDay := startOfLocalDay(now, UserLocation(s.db, userID))
+
+	var bookmarks []models.Bookmark
+	if err := s.db.Where("user_id = ? AND is_read = ?", userID, false).Preload("Tags").Find(&bookmarks).Error; err != nil {
+		return nil, nil, nil, err
+	}
+	items, err := s.queueItems(userID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var today, candidates []ReadingQueueEntry
+	for _, bookmark := range bookmarks {
+		item := items[bookmark.ID]
+		if item != nil && item.SnoozedUntil != nil && item.SnoozedUntil.After(now) {
+			continue
+		}
+
+		entry := scoreBookmark(bookmark, item, pref.TagPriorities, startOfDay)
+		switch {
+		case item != nil && item.LastSurfacedAt != nil && !item.LastSurfacedAt.Before(startOfDay):
+			today = append(today, entry)
+		case item != nil && item.NextSurfaceAt != nil && item.NextSurfaceAt.After(now) && item.SnoozedUntil == nil:
+			// Shown recently and not due again yet
+		default:
+			candidates = append(candidates, entry)
+		}
+	}
+
+	sortQueueEntries(today)
+	sortQueueEntries(candidates)
+
+	limit := pref.DailyCount
+	if limit <= 0 {
+		limit = defaultDailyQueueSize
+	}
+	if len(today) > limit {
+		today = today[:limit]
+	}
+	picked := candidates
+	if free := limit - len(today); len(picked) > free {
+		picked = picked[:free]
+	}
+	return today, picked, items, nil
+}
+
+// Snooze hides a bookmark from the queue until the given time
+func (s *ReadingQueueService) Snooze(userID, bookmarkID uint, until, now time.Time) (*models.ReadingQueueItem, error) {
+	if !until.After(now) {
+		return nil, ErrInvalidSnooze
+	}
+
+	var item models.ReadingQueueItem
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var bookmark models.Bookmark
+		if err := tx.Select("id").Where("id = ? AND user_id = ?", bookmarkID, userID).First(&bookmark).Error; err != nil {
+			return err
+		}
+
+		err := tx.Where("user_id = ? AND bookmark_id = ?", userID, bookmarkID).First(&item).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			item = models.ReadingQueueItem{UserID: userID, BookmarkID: bookmarkID, SnoozedUntil: &until}
+			return tx.Create(&item).Error
+		}
+		if err != nil {
+			return err
+		}
+		item.SnoozedUntil = &until
+		return tx.Model(&item).Update("snoozed_until", until).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// MarkRead marks a bookmark as read, removes it from the queue and records
+// the visit in the user's content analytics. minutes is the time spent
+// reading; zero falls back to the bookmark's estimated reading time.
+func (s *ReadingQueueService) MarkRead(userID, bookmarkID uint, minutes float64, now time.Time) (*models.Bookmark, error) {
+	var bookmark models.Bookmark
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ?", bookmarkID, userID).Preload("Tags").First(&bookmark).Error; err != nil {
+			return err
+		}
+
+		if !bookmark.IsRead || bookmark.ReadAt == nil {
+			bookmark.IsRead = true
+			bookmark.ReadAt = &now
+			if err := tx.Model(&models.Bookmark{}).Where("id = ?", bookmark.ID).
+				Updates(map[string]interface{}{"is_read": true, "read_at": now}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("user_id = ? AND bookmark_id = ?", userID, bookmark.ID).Delete(&models.ReadingQueueItem{}).Error; err != nil {
+			return err
+		}
+
+		if minutes <= 0 {
+			minutes = float64(bookmark.ReadingTime)
+		}
+		return recordBookmarkRead(tx, &bookmark, minutes, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &bookmark, nil
+}
+
+// Stats summarizes the user's reading
+func (s *ReadingQueueService) Stats(userID uint, now time.Time) (*ReadingStats, error) {
+	var stats ReadingStats
+	startOfDay := startOfLocalDay(now, UserLocation(s.db, userID))
+
+	bookmarks := func() *gorm.DB { return s.db.Model(&models.Bookmark{}).Where("user_id = ?", userID) }
+	if err := bookmarks().Where("is_read = ?", false).Count(&stats.Unread).Error; err != nil {
+		return nil, err
+	}
+	if err := bookmarks().Where("is_read = ? AND read_at >= ?", true, startOfDay).Count(&stats.ReadToday).Error; err != nil {
+		return nil, err
+	}
+	if err := bookmarks().Where("is_read = ? AND read_at >= ?", true, startOfDay.AddDate(0, 0, -6)).Count(&stats.ReadThisWeek).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&models.ReadingQueueItem{}).Where("user_id = ? AND snoozed_until > ?", userID, now).Count(&stats.Snoozed).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Model(&models.ContentAnalytics{}).Where("user_id = ? AND content_type = ?", userID, "bookmark").
+		Select("COALESCE(SUM(time_spent), 0)").Scan(&stats.TotalMinutes).Error; err != nil {
+		return nil, err
+	}
+
+	// Walk back from today (or yesterday, if nothing was read yet today)
+	// over the days with reads
+	var readTimes []time.Time
+	if err := bookmarks().Where("is_read = ? AND read_at >= ?", true, startOfDay.AddDate(0, 0, -365)).
+		Pluck("read_at", &readTimes).Error; err != nil {
+		return nil, err
+	}
+	days := make(map[time.Time]bool, len(readTimes))
+	for _, readAt := range readTimes {
+		days[startOfLocalDay(readAt, startOfDay.Location())] = true
+	}
+	day := startOfDay
+	if !days[day] {
+		day = day.AddDate(0, 0, -1)
+	}
+	for days[day] {
+		stats.StreakDays++
+		day = day.AddDate(0, 0, -1)
+	}
+
+	return &stats, nil
+}
+
+// queueItems loads the user's queue state keyed by bookmark
+func (s *ReadingQueueService) queueItems(userID uint) (map[uint]*models.ReadingQueueItem, error) {
+	var items []models.ReadingQueueItem
+	if err := s.db.Where("user_id = ?", userID).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	byBookmark := make(map[uint]*models.ReadingQueueItem, len(items))
+	for i := range items {
+		byBookmark[items[i].BookmarkID] = &items[i]
+	}
+	return byBookmark, nil
+}
+
+// recordSurfaced schedules when the picked bookmarks come back if unread
+func (s *ReadingQueueService) recordSurfaced(userID uint, picked []ReadingQueueEntry, items map[uint]*models.ReadingQueueItem, now time.Time) error {
+	if len(picked) == 0 {
+		return nil
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for _, entry := range picked {
+			count := entry.SurfaceCount + 1
+			next := now.Add(resurfaceIntervals[min(count, len(resurfaceIntervals))-1])
+
+			item := items[entry.Bookmark.ID]
+			if item == nil {
+				created := models.ReadingQueueItem{UserID: userID, BookmarkID: entry.Bookmark.ID,
+					SurfaceCount: count, LastSurfacedAt: &now, NextSurfaceAt: &next}
+				if err := tx.Create(&created).Error; err != nil {
+					return err
+				}
+				continue
+			}
+			if err := tx.Model(&models.ReadingQueueItem{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+				"surface_count":    count,
+				"last_surfaced_at": now,
+				"next_surface_at":  next,
+				"snoozed_until":    nil,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UserLocation returns the user's timezone, falling back to UTC
+func UserLocation(db *gorm.DB, userID uint) *time.Location {
+	var timezone string
+	db.Model(&models.User{}).Where("id = ?", userID).Select("timezone").Scan(&timezone)
+	return loadLocation(timezone)
+}
+
+// recordBookmarkRead adds a read of the bookmark to its content analytics
+func recordBookmarkRead(tx *gorm.DB, bookmark *models.Bookmark, minutes float64, now time.Time) error {
+	var analytics models.ContentAnalytics
+	err := tx.Where("user_id = ? AND content_type = ? AND content_id = ?", bookmark.UserID, "bookmark", bookmark.ID).First(&analytics).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		analytics = models.ContentAnalytics{
+			UserID:        bookmark.UserID,
+			ContentType:   "bookmark",
+			ContentID:     bookmark.ID,
+			FirstAccessed: now,
+			LastAccessed:  now,
+			AccessCount:   1,
+			TimeSpent:     minutes,
+			Category:      "reading",
+			Tags:          bookmark.Tags,
+		}
+		return tx.Create(&analytics).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Model(&models.ContentAnalytics{}).Where("id = ?", analytics.ID).Updates(map[string]interface{}{
+		"last_accessed": now,
+		"access_count":  gorm.Expr("access_count + 1"),
+		"time_spent":    gorm.Expr("time_spent + ?", minutes),
+	}).Error
+}
+
+// recordBookmarksRead records reads of the listed bookmarks that are still
+// unread and takes them out of the reading queue
+func recordBookmarksRead(tx *gorm.DB, userID uint, ids []uint, now time.Time) error {
+	var bookmarks []models.Bookmark
+	if err := tx.Where("id IN ? AND user_id = ? AND is_read = ?", ids, userID, false).Preload("Tags").Find(&bookmarks).Error; err != nil {
+		return err
+	}
+	for i := range bookmarks {
+		if err := recordBookmarkRead(tx, &bookmarks[i], float64(bookmarks[i].ReadingTime), now); err != nil {
+			return err
+		}
+	}
+	return tx.Where("user_id = ? AND bookmark_id IN ?", userID, ids).Delete(&models.ReadingQueueItem{}).Error
+}
+
+// scoreBookmark ranks an unread bookmark: older and quicker reads score
+// higher, tag priorities add their weight, forgotten bookmarks get a boost
+// and every unread showing costs a little
+func scoreBookmark(bookmark models.Bookmark, item *models.ReadingQueueItem, priorities map[string]int, now time.Time) ReadingQueueEntry {
+	entry := ReadingQueueEntry{Bookmark: bookmark, Reason: ReadingReasonNew}
+	if item != nil {
+		entry.SurfaceCount = item.SurfaceCount
+	}
+
+	age := now.Sub(bookmark.CreatedAt)
+	if age < 0 {
+		age = 0
+	}
+	score := math.Log1p(age.Hours() / 24)
+
+	if bookmark.ReadingTime > 0 {
+		score += 2 / (1 + float64(bookmark.ReadingTime)/10)
+	} else {
+		score += 0.5
+	}
+
+	priority := bookmarkTagPriority(bookmark.Tags, priorities)
+	score += float64(priority)
+	score -= 0.5 * float64(entry.SurfaceCount)
+
+	switch {
+	case priority > 0:
+		entry.Reason = ReadingReasonPriority
+	case item != nil && item.SnoozedUntil != nil:
+		score++
+		entry.Reason = ReadingReasonSnoozed
+	case entry.SurfaceCount > 0:
+		entry.Reason = ReadingReasonResurface
+	case age >= forgottenAfter:
+		score += 1.5
+		entry.Reason = ReadingReasonForgotten
+	case bookmark.ReadingTime > 0 && bookmark.ReadingTime <= 5:
+		entry.Reason = ReadingReasonQuickRead
+	}
+
+	entry.Score = math.Round(score*100) / 100
+	return entry
+}
+
+// bookmarkTagPriority sums the priorities of the bookmark's tags, each tag
+// taking the priority of its closest configured ancestor
+func bookmarkTagPriority(tags []models.Tag, priorities map[string]int) int {
+	if len(priorities) == 0 {
+		return 0
+	}
+
+	total := 0
+	for _, tag := range tags {
+		best, bestLen := 0, -1
+		for name, priority := range priorities {
+			if (strings.EqualFold(tag.Name, name) || isTagDescendant(tag.Name, name)) && len(name) > bestLen {
+				best, bestLen = priority, len(name)
+			}
+		}
+		total += best
+	}
+	return total
+}
+
+func mergeQueueEntries(today, picked []ReadingQueueEntry) []ReadingQueueEntry {
+	queue := append(append([]ReadingQueueEntry{}, today...), picked...)
+	sortQueueEntries(queue)
+	return queue
+}
+
+func sortQueueEntries(entries []ReadingQueueEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		return entries[i].Bookmark.ID < entries[j].Bookmark.ID
+	})
+}
+
+// loadLocation resolves an IANA timezone name, falling back to UTC
+func loadLocation(name string) *time.Location {
+	if name == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// startOfLocalDay returns midnight of t's day in loc
+func startOfLocalDay(t time.Time, loc *time.Location) time.Time {
+	local := t.In(loc)
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+func TestReadingQueueService(t *testing.T) {
+	db := newTestDB(t, &models.ContentAnalytics{}, &models.ReadingQueueItem{}, &models.ReadingQueuePreference{})
+
+	user := models.User{Email: "reader@example.com", Username: "reader", Password: "x", Timezone: "Europe/Prague"}
+	db.Create(&user)
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+
+	golang, _ := FindOrCreateTag(db, user.ID, "dev/go")
+	bookmarks := []models.Bookmark{
+		{UserID: user.ID, Title: "Fresh long read", URL: "https://a.example", ReadingTime: 40},
+		{UserID: user.ID, Title: "Old quick read", URL: "https://b.example", ReadingTime: 3},
+		{UserID: user.ID, Title: "Go internals", URL: "https://c.example", ReadingTime: 20, Tags: []models.Tag{*golang}},
+		{UserID: user.ID, Title: "Already read", URL: "https://d.example", IsRead: true},
+	}
+	db.Create(&bookmarks)
+	db.Model(&bookmarks[0]).UpdateColumn("created_at", now.Add(-2*time.Hour))
+	db.Model(&bookmarks[1]).UpdateColumn("created_at", now.AddDate(0, 0, -40))
+	db.Model(&bookmarks[2]).UpdateColumn("created_at", now.AddDate(0, 0, -3))
+
+	service := NewReadingQueueService(db)
+	two := 2
+	if _, err := service.UpdatePreferences(user.ID, ReadingQueuePreferenceInput{DailyCount: &two, TagPriorities: map[string]int{" Dev ": 5}}); err != nil {
+		t.Fatalf("failed to save preferences: %v", err)
+	}
+	bad := 99
+	if _, err := service.UpdatePreferences(user.ID, ReadingQueuePreferenceInput{DailyCount: &bad}); !errors.Is(err, ErrInvalidReadingQueuePreference) {
+		t.Fatalf("expected invalid preference, got %v", err)
+	}
+
+	// The parent tag's priority applies to dev/go; the old bookmark is forgotten
+	queue, err := service.DailyQueue(user.ID, now)
+	if err != nil {
+		t.Fatalf("failed to build queue: %v", err)
+	}
+	if len(queue) != 2 || queue[0].Bookmark.ID != bookmarks[2].ID || queue[0].Reason != ReadingReasonPriority ||
+		queue[1].Bookmark.ID != bookmarks[1].ID || queue[1].Reason != ReadingReasonForgotten {
+		t.Fatalf("unexpected queue: %+v", queue)
+	}
+	var stored int64
+	db.Model(&models.ReadingQueueItem{}).Count(&stored)
+	if stored != 0 {
+		t.Fatalf("expected looking at the queue to change nothing, got %d items", stored)
+	}
+
+	// The queue is stable through the day, before and after it is shown
+	again, _ := service.DailyQueue(user.ID, now.Add(3*time.Hour))
+	if len(again) != 2 || again[0].Bookmark.ID != bookmarks[2].ID || again[1].Bookmark.ID != bookmarks[1].ID {
+		t.Fatalf("expected the same queue later in the day, got %+v", again)
+	}
+	shown, err := service.MarkQueueShown(user.ID, now.Add(3*time.Hour))
+	if err != nil || len(shown) != 2 || shown[0].Bookmark.ID != bookmarks[2].ID || shown[0].SurfaceCount != 1 {
+		t.Fatalf("unexpected shown queue: %+v (err %v)", shown, err)
+	}
+	again, _ = service.DailyQueue(user.ID, now.Add(5*time.Hour))
+	if len(again) != 2 || again[0].Bookmark.ID != bookmarks[2].ID || again[1].Bookmark.ID != bookmarks[1].ID {
+		t.Fatalf("expected the same queue after it was shown, got %+v", again)
+	}
+
+	// Tomorrow the shown bookmarks are not due yet; the remaining one is
+	tomorrow := now.Add(20 * time.Hour)
+	queue, _ = service.DailyQueue(user.ID, tomorrow)
+	if len(queue) != 1 || queue[0].Bookmark.ID != bookmarks[0].ID {
+		t.Fatalf("unexpected queue for tomorrow: %+v", queue)
+	}
+	var item models.ReadingQueueItem
+	db.Where("bookmark_id = ?", bookmarks[1].ID).First(&item)
+	if item.SurfaceCount != 1 || item.NextSurfaceAt == nil || !item.NextSurfaceAt.Equal(now.Add(27*time.Hour)) {
+		t.Fatalf("unexpected resurfacing schedule: %+v", item)
+	}
+
+	// Two days later it resurfaces, and the next wait is longer
+	later := now.AddDate(0, 0, 2)
+	if _, err := service.Snooze(user.ID, bookmarks[2].ID, later.AddDate(0, 0, 7), later); err != nil {
+		t.Fatalf("failed to snooze: %v", err)
+	}
+	if _, err := service.Snooze(user.ID, bookmarks[2].ID, later, later); !errors.Is(err, ErrInvalidSnooze) {
+		t.Fatalf("expected past snooze to fail, got %v", err)
+	}
+	queue, _ = service.MarkQueueShown(user.ID, later)
+	for _, entry := range queue {
+		if entry.Bookmark.ID == bookmarks[2].ID {
+			t.Fatalf("snoozed bookmark is in the queue: %+v", queue)
+		}
+	}
+	if len(queue) != 2 || queue[0].Bookmark.ID != bookmarks[1].ID || queue[0].Reason != ReadingReasonResurface || queue[0].SurfaceCount != 2 {
+		t.Fatalf("expected the forgotten bookmark to resurface, got %+v", queue)
+	}
+	var resurfaced models.ReadingQueueItem
+	db.Where("bookmark_id = ?", bookmarks[1].ID).First(&resurfaced)
+	if !resurfaced.NextSurfaceAt.Equal(later.Add(3 * 24 * time.Hour)) {
+		t.Fatalf("expected a longer wait, got %v", resurfaced.NextSurfaceAt)
+	}
+
+	// Reading records analytics and leaves the queue
+	read, err := service.MarkRead(user.ID, bookmarks[1].ID, 0, later)
+	if err != nil || !read.IsRead || read.ReadAt == nil {
+		t.Fatalf("failed to mark read: %+v (err %v)", read, err)
+	}
+	if _, err := service.MarkRead(user.ID, bookmarks[1].ID, 2, later.Add(time.Hour)); err != nil {
+		t.Fatalf("failed to record a second read: %v", err)
+	}
+	var analytics models.ContentAnalytics
+	db.Where("content_type = ? AND content_id = ?", "bookmark", bookmarks[1].ID).First(&analytics)
+	if analytics.AccessCount != 2 || analytics.TimeSpent != 5 {
+		t.Fatalf("unexpected analytics: %+v", analytics)
+	}
+	var remaining int64
+	db.Model(&models.ReadingQueueItem{}).Where("bookmark_id = ?", bookmarks[1].ID).Count(&remaining)
+	if remaining != 0 {
+		t.Fatalf("expected read bookmark to leave the queue")
+	}
+	if _, err := service.MarkRead(user.ID+1, bookmarks[0].ID, 0, later); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected other users to be rejected, got %v", err)
+	}
+
+	stats, err := service.Stats(user.ID, later.Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("failed to fetch stats: %v", err)
+	}
+	if stats.Unread != 2 || stats.Snoozed != 1 || stats.ReadToday != 1 || stats.TotalMinutes != 5 || stats.StreakDays != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	// The digest goes out once, after the chosen local hour
+	enabled, hour := true, 11
+	service.UpdatePreferences(user.ID, ReadingQueuePreferenceInput{DigestEnabled: &enabled, DigestHour: &hour})
+	var sent []string
+	scheduler := NewReadingDigestScheduler(db, ReadingDigestOptions{Mailer: func(to, subject, body string) error {
+		sent = append(sent, to+"|"+body)
+		return nil
+	}})
+	day := now.AddDate(0, 0, 6)
+	if n, _ := scheduler.RunOnce(day); n != 0 { // 10:00 in Prague
+		t.Fatalf("digest sent before the chosen hour")
+	}
+	if n, err := scheduler.RunOnce(day.Add(2 * time.Hour)); n != 1 || err != nil {
+		t.Fatalf("expected one digest, sent %d (err %v)", n, err)
+	}
+	if n, _ := scheduler.RunOnce(day.Add(3 * time.Hour)); n != 0 {
+		t.Fatalf("digest sent twice in one day")
+	}
+	if !strings.HasPrefix(sent[0], "reader@example.com|") || !strings.Contains(sent[0], "https://a.example") {
+		t.Fatalf("unexpected digest: %q", sent[0])
+	}
+}
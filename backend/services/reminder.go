@@ -0,0 +1,656 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInvalidPushSubscription is returned for a subscription without an
+// endpoint or keys
+var ErrInvalidPushSubscription = errors.New("invalid push subscription")
+
+const (
+	defaultReminderLead = 15 * time.Minute
+	// maxReminderLead bounds how far before an occurrence a reminder can be
+	// set, which bounds how far ahead the planner has to look
+	maxReminderLead   = 7 * 24 * time.Hour
+	maxReminderSnooze = 7 * 24 * time.Hour
+	reminderFireBatch = 200
+	// reminderOccurrenceLimit caps the occurrences of one recurring event
+	// planned per run
+	reminderOccurrenceLimit = 100
+)
+
+// habitReminderHours is the local hour a habit is reminded at for each time of day
+var habitReminderHours = map[string]int{
+	"morning":   8,
+	"afternoon": 13,
+	"evening":   18,
+	"night":     21,
+}
+
+// ReminderChannel delivers a reminder to its user. A channel the user has
+// turned off, or that is not configured, reports false without an error.
+type ReminderChannel interface {
+	Name() string
+	Deliver(ctx context.Context, user *models.User, reminder *models.Reminder) (bool, error)
+}
+
+// ReminderSchedulerOptions configures the reminder scheduler
+type ReminderSchedulerOptions struct {
+	Interval time.Duration     // how often reminders are planned and sent
+	Horizon  time.Duration     // how far ahead reminders are planned
+	Grace    time.Duration     // how late a reminder is still sent, e.g. after downtime
+	Channels []ReminderChannel // defaults to in-app, email and Web Push
+}
+
+// ReminderScheduler plans reminders for upcoming events, task due dates and
+// habits and delivers them when they are due. Reminders are stored, one per
+// occurrence, so a restart picks up where the last run stopped and nothing
+// is reminded twice.
+type ReminderScheduler struct {
+	db   *gorm.DB
+	opts ReminderSchedulerOptions
+
+	sweeping sync.Mutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewReminderScheduler creates a reminder scheduler, filling in defaults for unset options
+func NewReminderScheduler(db *gorm.DB, opts ReminderSchedulerOptions) *ReminderScheduler {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute
+	}
+	if opts.Horizon <= 0 {
+		opts.Horizon = 24 * time.Hour
+	}
+	if opts.Grace <= 0 {
+		opts.Grace = time.Hour
+	}
+	if opts.Channels == nil {
+		opts.Channels = DefaultReminderChannels(db)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ReminderScheduler{
+		db:     db,
+		opts:   opts,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start plans and sends reminders in the background until Stop is called
+func (rs *ReminderScheduler) Start() {
+	go func() {
+		if _, err := rs.RunOnce(time.Now()); err != nil {
+			log.Printf("Reminder run failed: %v", err)
+		}
+
+		ticker := time.NewTicker(rs.opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if _, err := rs.RunOnce(time.Now()); err != nil {
+					log.Printf("Reminder run failed: %v", err)
+				}
+			case <-rs.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends the background loop
+func (rs *ReminderScheduler) Stop() {
+	rs.cancel()
+}
+
+// RunOnce plans the reminders within the horizon and sends those that are
+// due, returning how many were sent. Overlapping runs are skipped.
+func (rs *ReminderScheduler) RunOnce(now time.Time) (int, error) {
+	if !rs.sweeping.TryLock() {
+		return 0, nil
+	}
+	defer rs.sweeping.Unlock()
+
+	if err := rs.Plan(now); err != nil {
+		return 0, err
+	}
+	return rs.Fire(now)
+}
+
+// reminderWindow is the range of reminder times a run plans
+type reminderWindow struct {
+	from, to time.Time
+}
+
+func (w reminderWindow) contains(t time.Time) bool {
+	return t.After(w.from) && !t.After(w.to)
+}
+
+// reminderUsers caches the per-user settings a run needs
+type reminderUsers struct {
+	db        *gorm.DB
+	leads     map[uint]time.Duration
+	locations map[uint]*time.Location
+}
+
+func (u *reminderUsers) lead(userID uint) time.Duration {
+	if lead, ok := u.leads[userID]; ok {
+		return lead
+	}
+	lead := defaultReminderLead
+	var settings models.CalendarSettings
+	if err := u.db.Where("user_id = ?", userID).First(&settings).Error; err == nil {
+		lead = time.Duration(settings.DefaultReminderMinutes) * time.Minute
+	}
+	u.leads[userID] = lead
+	return lead
+}
+
+func (u *reminderUsers) location(userID uint) *time.Location {
+	if loc, ok := u.locations[userID]; ok {
+		return loc
+	}
+	loc := UserLocation(u.db, userID)
+	u.locations[userID] = loc
+	return loc
+}
+
+// Plan stores a reminder for every occurrence whose reminder time falls
+// between the grace period before now and the horizon after it. Planning
+// the same occurrence again only refreshes a reminder that has not fired or
+// been snoozed, so a changed lead time moves it.
+func (rs *ReminderScheduler) Plan(now time.Time) error {
+	window := reminderWindow{from: now.Add(-rs.opts.Grace), to: now.Add(rs.opts.Horizon)}
+	users := &reminderUsers{db: rs.db, leads: map[uint]time.Duration{}, locations: map[uint]*time.Location{}}
+
+	if err := rs.planEvents(window, users); err != nil {
+		return err
+	}
+	if err := rs.planTasks(window, users); err != nil {
+		return err
+	}
+	// Habits are optional, so a broken habit table does not stop the others
+	if err := rs.planHabits(window, users); err != nil {
+		log.Printf("Failed to plan habit reminders: %v", err)
+	}
+	return nil
+}
+
+func (rs *ReminderScheduler) planEvents(window reminderWindow, users *reminderUsers) error {
+	var events []models.CalendarEvent
+	err := rs.db.Where("is_completed = ? AND reminder_minutes >= 0", false).
+		Where("(recurring = ? AND start_time > ? AND start_time <= ?) OR (recurring = ? AND rrule <> '' AND start_time <= ?)",
+			false, window.from, window.to.Add(maxReminderLead), true, window.to.Add(maxReminderLead)).
+		Find(&events).Error
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		lead := eventReminderLead(event, users)
+		for _, occurs := range eventOccurrences(event, window.from, window.to.Add(lead), users.location(event.UserID)) {
+			remindAt := occurs.Add(-lead)
+			if !window.contains(remindAt) {
+				continue
+			}
+			if err := rs.upsertReminder(models.Reminder{
+				UserID:     event.UserID,
+				SourceType: models.ReminderSourceEvent,
+				SourceID:   event.ID,
+				OccursAt:   occurs,
+				RemindAt:   remindAt,
+				Title:      event.Title,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (rs *ReminderScheduler) planTasks(window reminderWindow, users *reminderUsers) error {
+	var tasks []models.Task
+	err := rs.db.Where("status NOT IN ? AND due_date IS NOT NULL AND due_date > ? AND due_date <= ?",
+		closedTaskStatuses, window.from, window.to.Add(maxReminderLead)).
+		Find(&tasks).Error
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		remindAt := task.DueDate.Add(-users.lead(task.UserID))
+		if !window.contains(remindAt) {
+			continue
+		}
+		if err := rs.upsertReminder(models.Reminder{
+			UserID:     task.UserID,
+			SourceType: models.ReminderSourceTask,
+			SourceID:   task.ID,
+			OccursAt:   *task.DueDate,
+			RemindAt:   remindAt,
+			Title:      task.Title,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rs *ReminderScheduler) planHabits(window reminderWindow, users *reminderUsers) error {
+	var habits []models.Habit
+	if err := rs.db.Where("is_active = ? AND time_of_day IN ?", true, habitTimesOfDay()).Find(&habits).Error; err != nil {
+		return err
+	}
+
+	for _, habit := range habits {
+		loc := users.location(habit.UserID)
+		for day := startOfLocalDay(window.from, loc); !day.After(window.to); day = day.AddDate(0, 0, 1) {
+			occurs, ok := habitOccurrence(habit, day)
+			if !ok || !window.contains(occurs) {
+				continue
+			}
+			if err := rs.upsertReminder(models.Reminder{
+				UserID:     habit.UserID,
+				SourceType: models.ReminderSourceHabit,
+				SourceID:   habit.ID,
+				OccursAt:   occurs,
+				RemindAt:   occurs,
+				Title:      habit.Name,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// upsertReminder inserts the reminder for an occurrence, or moves a pending
+// one that was never snoozed to the newly computed time
+func (rs *ReminderScheduler) upsertReminder(reminder models.Reminder) error {
+	reminder.OccursAt = reminder.OccursAt.UTC()
+	reminder.RemindAt = reminder.RemindAt.UTC()
+	reminder.Status = models.ReminderStatusPending
+	return rs.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "source_type"}, {Name: "source_id"}, {Name: "occurs_at"}},
+		DoUpdates: clause.AssignmentColumns([]string{"remind_at", "title", "updated_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Eq{Column: clause.Column{Table: "reminders", Name: "status"}, Value: models.ReminderStatusPending},
+			clause.Eq{Column: clause.Column{Table: "reminders", Name: "snooze_count"}, Value: 0},
+		}},
+	}).Create(&reminder).Error
+}
+
+// Fire sends the pending reminders that are due. Each is claimed before it
+// is delivered, so two servers or a crash mid-run never send one twice; a
+// reminder missed by more than the grace period expires instead.
+func (rs *ReminderScheduler) Fire(now time.Time) (int, error) {
+	var due []models.Reminder
+	if err := rs.db.Where("status = ? AND remind_at <= ?", models.ReminderStatusPending, now).
+		Order("remind_at").Limit(reminderFireBatch).Find(&due).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range due {
+		if rs.ctx.Err() != nil {
+			break
+		}
+		reminder := &due[i]
+
+		status := models.ReminderStatusSent
+		switch {
+		case !reminderStillDue(rs.db, reminder):
+			status = models.ReminderStatusCancelled
+		case now.Sub(reminder.RemindAt) > rs.opts.Grace:
+			status = models.ReminderStatusExpired
+		}
+		claim := rs.db.Model(&models.Reminder{}).
+			Where("id = ? AND status = ? AND remind_at = ?", reminder.ID, models.ReminderStatusPending, reminder.RemindAt).
+			Updates(map[string]interface{}{"status": status, "sent_at": now})
+		if claim.Error != nil {
+			return sent, claim.Error
+		}
+		if claim.RowsAffected != 1 || status != models.ReminderStatusSent {
+			continue
+		}
+
+		var user models.User
+		if err := rs.db.First(&user, reminder.UserID).Error; err != nil {
+			continue
+		}
+		reminder.Status, reminder.SentAt = status, &now
+
+		var delivered, failures []string
+		for _, channel := range rs.opts.Channels {
+			ok, err := channel.Deliver(rs.ctx, &user, reminder)
+			if err != nil {
+				log.Printf("Failed to deliver reminder %d via %s: %v", reminder.ID, channel.Name(), err)
+				failures = append(failures, channel.Name()+": "+err.Error())
+			}
+			if ok {
+				delivered = append(delivered, channel.Name())
+			}
+		}
+		if err := rs.db.Model(&models.Reminder{}).Where("id = ?", reminder.ID).Updates(map[string]interface{}{
+			"channels":   strings.Join(delivered, ","),
+			"last_error": strings.Join(failures, "; "),
+		}).Error; err != nil {
+			return sent, err
+		}
+		sent++
+	}
+	return sent, nil
+}
+
+// reminderStillDue checks that the reminder's source still has the
+// occurrence it was planned for: the event was not moved or completed, the
+// task is open with the same due date, the habit is active and not done yet
+func reminderStillDue(db *gorm.DB, reminder *models.Reminder) bool {
+	switch reminder.SourceType {
+	case models.ReminderSourceEvent:
+		var event models.CalendarEvent
+		if err := db.Where("id = ? AND user_id = ?", reminder.SourceID, reminder.UserID).First(&event).Error; err != nil {
+			return false
+		}
+		if event.IsCompleted || event.ReminderMinutes < 0 {
+			return false
+		}
+		if !event.Recurring || event.Rrule == "" {
+			return event.StartTime.Equal(reminder.OccursAt)
+		}
+		loc := UserLocation(db, reminder.UserID)
+		for _, occurs := range eventOccurrences(event, reminder.OccursAt.Add(-time.Second), reminder.OccursAt, loc) {
+			if occurs.Equal(reminder.OccursAt) {
+				return true
+			}
+		}
+		return false
+	case models.ReminderSourceTask:
+		var task models.Task
+		if err := db.Where("id = ? AND user_id = ?", reminder.SourceID, reminder.UserID).First(&task).Error; err != nil {
+			return false
+		}
+		return task.Status != models.TaskStatusCompleted && task.Status != models.TaskStatusCancelled && task.DueDate != nil && task.DueDate.Equal(reminder.OccursAt)
+	case models.ReminderSourceHabit:
+		var habit models.Habit
+		if err := db.Where("id = ? AND user_id = ?", reminder.SourceID, reminder.UserID).First(&habit).Error; err != nil {
+			return false
+		}
+		if !habit.IsActive {
+			return false
+		}
+		day := startOfLocalDay(reminder.OccursAt, UserLocation(db, reminder.UserID))
+		var done int64
+		db.Model(&models.HabitEntry{}).
+			Where("habit_id = ? AND is_completed = ? AND entry_date >= ? AND entry_date < ?", habit.ID, true, day, day.AddDate(0, 0, 1)).
+			Count(&done)
+		return done == 0
+	}
+	return false
+}
+
+// eventReminderLead is the event's own lead time, or the user's default
+// when the event has none
+func eventReminderLead(event models.CalendarEvent, users *reminderUsers) time.Duration {
+	lead := time.Duration(event.ReminderMinutes) * time.Minute
+	if event.ReminderMinutes == 0 {
+		lead = users.lead(event.UserID)
+	}
+	if lead > maxReminderLead {
+		lead = maxReminderLead
+	}
+	return lead
+}
+
+// eventOccurrences lists the start times of the event in (from, to],
+// expanding recurring events in the user's timezone
+func eventOccurrences(event models.CalendarEvent, from, to time.Time, loc *time.Location) []time.Time {
+	if !event.Recurring || event.Rrule == "" {
+		if event.StartTime.After(from) && !event.StartTime.After(to) {
+			return []time.Time{event.StartTime}
+		}
+		return nil
+	}
+
+	rule, err := ParseRRule(event.Rrule, loc)
+	if err != nil {
+		return nil
+	}
+	var occurrences []time.Time
+	rule.Iterate(event.StartTime.In(loc), func(_ int, at time.Time) bool {
+		if at.After(to) {
+			return false
+		}
+		if at.After(from) {
+			occurrences = append(occurrences, at)
+		}
+		return len(occurrences) < reminderOccurrenceLimit
+	})
+	return occurrences
+}
+
+// habitOccurrence is when the habit is due on the local day starting at
+// midnight, if it is scheduled that day. Habits without days run daily.
+func habitOccurrence(habit models.Habit, midnight time.Time) (time.Time, bool) {
+	hour, ok := habitReminderHours[strings.ToLower(habit.TimeOfDay)]
+	if !ok {
+		return time.Time{}, false
+	}
+	if len(habit.DaysOfWeek) > 0 {
+		weekday := strings.ToLower(midnight.Weekday().String())
+		scheduled := false
+		for _, day := range habit.DaysOfWeek {
+			day = strings.ToLower(strings.TrimSpace(day))
+			if day == weekday || (len(day) == 3 && strings.HasPrefix(weekday, day)) {
+				scheduled = true
+				break
+			}
+		}
+		if !scheduled {
+			return time.Time{}, false
+		}
+	}
+	return time.Date(midnight.Year(), midnight.Month(), midnight.Day(), hour, 0, 0, 0, midnight.Location()), true
+}
+
+func habitTimesOfDay() []string {
+	times := make([]string, 0, len(habitReminderHours))
+	for name := range habitReminderHours {
+		times = append(times, name)
+	}
+	return times
+}
+
+// ReminderService lets users see, snooze and dismiss their reminders and
+// manages the notification center and push subscriptions
+type ReminderService struct {
+	db *gorm.DB
+}
+
+// NewReminderService creates a new reminder service
+func NewReminderService(db *gorm.DB) *ReminderService {
+	return &ReminderService{db: db}
+}
+
+// ListReminders returns the user's reminders, optionally only those with a
+// status, soonest first
+func (s *ReminderService) ListReminders(userID uint, status string, limit int) ([]models.Reminder, error) {
+	query := s.db.Where("user_id = ?", userID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	var reminders []models.Reminder
+	err := query.Order("remind_at").Limit(limit).Find(&reminders).Error
+	return reminders, err
+}
+
+// Snooze reminds again at until. Reminders that were sent or are still
+// pending can be snoozed, for up to a week.
+func (s *ReminderService) Snooze(userID, reminderID uint, until, now time.Time) (*models.Reminder, error) {
+	if !until.After(now) {
+		return nil, ErrInvalidSnooze
+	}
+	if until.Sub(now) > maxReminderSnooze {
+		return nil, fmt.Errorf("%w: at most a week ahead", ErrInvalidSnooze)
+	}
+
+	var reminder models.Reminder
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ?", reminderID, userID).First(&reminder).Error; err != nil {
+			return err
+		}
+		if reminder.Status != models.ReminderStatusPending && reminder.Status != models.ReminderStatusSent {
+			return fmt.Errorf("%w: reminder is %s", ErrInvalidSnooze, reminder.Status)
+		}
+		if err := tx.Model(&reminder).Updates(map[string]interface{}{
+			"status":       models.ReminderStatusPending,
+			"remind_at":    until.UTC(),
+			"snooze_count": reminder.SnoozeCount + 1,
+		}).Error; err != nil {
+			return err
+		}
+		return markReminderNotificationsRead(tx, reminder.ID, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &reminder, nil
+}
+
+// Dismiss stops a reminder for good and marks its notifications read
+func (s *ReminderService) Dismiss(userID, reminderID uint, now time.Time) (*models.Reminder, error) {
+	var reminder models.Reminder
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ?", reminderID, userID).First(&reminder).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&reminder).Updates(map[string]interface{}{
+			"status":       models.ReminderStatusDismissed,
+			"dismissed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return markReminderNotificationsRead(tx, reminder.ID, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &reminder, nil
+}
+
+func markReminderNotificationsRead(tx *gorm.DB, reminderID uint, now time.Time) error {
+	return tx.Model(&models.Notification{}).Where("reminder_id = ? AND read_at IS NULL", reminderID).Update("read_at", now).Error
+}
+
+// ListNotifications returns the newest notifications and how many are unread
+func (s *ReminderService) ListNotifications(userID uint, unreadOnly bool, limit int) ([]models.Notification, int64, error) {
+	query := s.db.Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	var notifications []models.Notification
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Find(&notifications).Error; err != nil {
+		return nil, 0, err
+	}
+	var unread int64
+	if err := s.db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread).Error; err != nil {
+		return nil, 0, err
+	}
+	return notifications, unread, nil
+}
+
+// MarkNotificationRead marks one notification read
+func (s *ReminderService) MarkNotificationRead(userID, notificationID uint, now time.Time) error {
+	update := s.db.Model(&models.Notification{}).Where("id = ? AND user_id = ?", notificationID, userID).
+		Where("read_at IS NULL").Update("read_at", now)
+	if update.Error != nil {
+		return update.Error
+	}
+	if update.RowsAffected == 0 {
+		var count int64
+		s.db.Model(&models.Notification{}).Where("id = ? AND user_id = ?", notificationID, userID).Count(&count)
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+	}
+	return nil
+}
+
+// MarkAllNotificationsRead marks every notification read and returns how many changed
+func (s *ReminderService) MarkAllNotificationsRead(userID uint, now time.Time) (int64, error) {
+	update := s.db.Model(&models.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Update("read_at", now)
+	return update.RowsAffected, update.Error
+}
+
+// PushSubscriptionInput is the JSON of a browser PushSubscription
+type PushSubscriptionInput struct {
+	Endpoint string `json:"endpoint" binding:"required"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+// Subscribe stores a browser's push subscription. An endpoint is unique, so
+// subscribing again, also as another user on the same browser, replaces it.
+func (s *ReminderService) Subscribe(userID uint, input PushSubscriptionInput, userAgent string) (*models.PushSubscription, error) {
+	endpoint := strings.TrimSpace(input.Endpoint)
+	if !strings.HasPrefix(endpoint, "https://") || input.Keys.P256dh == "" || input.Keys.Auth == "" {
+		return nil, fmt.Errorf("%w: endpoint and keys are required", ErrInvalidPushSubscription)
+	}
+	if key, err := decodeBase64URL(input.Keys.P256dh); err != nil || len(key) != 65 {
+		return nil, fmt.Errorf("%w: p256dh is not a P-256 public key", ErrInvalidPushSubscription)
+	}
+
+	subscription := models.PushSubscription{
+		UserID:    userID,
+		Endpoint:  endpoint,
+		P256dh:    input.Keys.P256dh,
+		Auth:      input.Keys.Auth,
+		UserAgent: userAgent,
+	}
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "endpoint"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "p256dh", "auth", "user_agent", "updated_at"}),
+	}).Create(&subscription).Error
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("endpoint = ?", endpoint).First(&subscription).Error; err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+// Unsubscribe removes the user's subscription for an endpoint
+func (s *ReminderService) Unsubscribe(userID uint, endpoint string) error {
+	deleted := s.db.Where("user_id = ? AND endpoint = ?", userID, endpoint).Delete(&models.PushSubscription{})
+	if deleted.Error != nil {
+		return deleted.Error
+	}
+	if deleted.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
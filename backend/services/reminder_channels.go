@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+// Names of the built-in reminder channels, recorded in Reminder.Channels
+const (
+	ReminderChannelInApp = "in_app"
+	ReminderChannelEmail = "email"
+	ReminderChannelPush  = "push"
+)
+
+// DefaultReminderChannels returns the in-app, email and Web Push channels.
+// Email needs the SMTP_* settings and Web Push the WEBPUSH_* ones; without
+// them those channels skip quietly.
+func DefaultReminderChannels(db *gorm.DB) []ReminderChannel {
+	channels := []ReminderChannel{
+		NewInAppReminderChannel(db, GetMessagesHub()),
+		NewEmailReminderChannel(db, SendMail),
+	}
+	if sender, err := NewWebPushSender(WebPushConfigFromEnv()); err == nil {
+		channels = append(channels, NewPushReminderChannel(db, sender))
+	} else if !errors.Is(err, ErrWebPushNotConfigured) {
+		log.Printf("Web Push reminders disabled: %v", err)
+	}
+	return channels
+}
+
+// reminderMessage is the text shown for a reminder, in the user's timezone
+func reminderMessage(reminder *models.Reminder, loc *time.Location) string {
+	at := reminder.OccursAt.In(loc).Format("Mon, Jan 2 at 15:04")
+	switch reminder.SourceType {
+	case models.ReminderSourceEvent:
+		return "Starts " + at
+	case models.ReminderSourceTask:
+		return "Due " + at
+	case models.ReminderSourceHabit:
+		return "Time for your habit"
+	}
+	return at
+}
+
+// reminderLink is the app page of the reminder's source
+func reminderLink(reminder *models.Reminder) string {
+	switch reminder.SourceType {
+	case models.ReminderSourceEvent:
+		return "/app/calendar"
+	case models.ReminderSourceTask:
+		return "/app/tasks"
+	}
+	return ""
+}
+
+// reminderSettings returns whether the user allows email and push
+// reminders. Without calendar settings both are on.
+func reminderSettings(db *gorm.DB, userID uint) (email, push bool) {
+	var settings models.CalendarSettings
+	if err := db.Where("user_id = ?", userID).First(&settings).Error; err != nil {
+		return true, true
+	}
+	return settings.EmailRemindersEnabled, settings.PushRemindersEnabled
+}
+
+// InAppReminderChannel adds reminders to the notification center and pushes
+// them to the user's open WebSocket connections
+type InAppReminderChannel struct {
+	db  *gorm.DB
+	hub *MessagesHub
+}
+
+// NewInAppReminderChannel creates the notification center channel. hub may be nil.
+func NewInAppReminderChannel(db *gorm.DB, hub *MessagesHub) *InAppReminderChannel {
+	return &InAppReminderChannel{db: db, hub: hub}
+}
+
+func (c *InAppReminderChannel) Name() string { return ReminderChannelInApp }
+
+func (c *InAppReminderChannel) Deliver(_ context.Context, user *models.User, reminder *models.Reminder) (bool, error) {
+	reminderID := reminder.ID
+	notification := models.Notification{
+		UserID:     user.ID,
+		Type:       "reminder",
+		Title:      reminder.Title,
+		Body:       reminderMessage(reminder, loadLocation(user.Timezone)),
+		Link:       reminderLink(reminder),
+		ReminderID: &reminderID,
+	}
+	if err := c.db.Create(&notification).Error; err != nil {
+		return false, err
+	}
+	if c.hub != nil {
+		c.hub.NotifyUser(user.ID, "notification.created", notification)
+	}
+	return true, nil
+}
+
+// EmailReminderChannel emails reminders to users who have email
+// notifications and email reminders on
+type EmailReminderChannel struct {
+	db     *gorm.DB
+	mailer Mailer
+}
+
+// NewEmailReminderChannel creates the email channel
+func NewEmailReminderChannel(db *gorm.DB, mailer Mailer) *EmailReminderChannel {
+	return &EmailReminderChannel{db: db, mailer: mailer}
+}
+
+func (c *EmailReminderChannel) Name() string { return ReminderChannelEmail }
+
+func (c *EmailReminderChannel) Deliver(_ context.Context, user *models.User, reminder *models.Reminder) (bool, error) {
+	if !user.EmailNotifications || user.Email == "" {
+		return false, nil
+	}
+	if enabled, _ := reminderSettings(c.db, user.ID); !enabled {
+		return false, nil
+	}
+
+	body := fmt.Sprintf("Hello,\n\nThis is your reminder for \"%s\".\n%s.\n\nTrackeep\n",
+		reminder.Title, reminderMessage(reminder, loadLocation(user.Timezone)))
+	if err := c.mailer(user.Email, "Reminder: "+reminder.Title, body); err != nil {
+		if errors.Is(err, ErrSMTPNotConfigured) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// PushReminderChannel sends reminders to the user's browsers through Web
+// Push. Subscriptions the push service reports as gone are removed.
+type PushReminderChannel struct {
+	db     *gorm.DB
+	sender *WebPushSender
+}
+
+// NewPushReminderChannel creates the Web Push channel
+func NewPushReminderChannel(db *gorm.DB, sender *WebPushSender) *PushReminderChannel {
+	return &PushReminderChannel{db: db, sender: sender}
+}
+
+func (c *PushReminderChannel) Name() string { return ReminderChannelPush }
+
+func (c *PushReminderChannel) Deliver(ctx context.Context, user *models.User, reminder *models.Reminder) (bool, error) {
+	if !user.PushNotifications {
+		return false, nil
+	}
+	if _, enabled := reminderSettings(c.db, user.ID); !enabled {
+		return false, nil
+	}
+
+	var subscriptions []models.PushSubscription
+	if err := c.db.Where("user_id = ?", user.ID).Find(&subscriptions).Error; err != nil {
+		return false, err
+	}
+	if len(subscriptions) == 0 {
+		return false, nil
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"title":       reminder.Title,
+		"body":        reminderMessage(reminder, loadLocation(user.Timezone)),
+		"url":         reminderLink(reminder),
+		"tag":         fmt.Sprintf("reminder-%d", reminder.ID),
+		"reminder_id": reminder.ID,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	delivered := false
+	var lastErr error
+	now := time.Now()
+	for _, subscription := range subscriptions {
+		err := c.sender.Send(ctx, WebPushTarget{
+			Endpoint: subscription.Endpoint,
+			P256dh:   subscription.P256dh,
+			Auth:     subscription.Auth,
+		}, payload, now)
+		switch {
+		case errors.Is(err, ErrPushSubscriptionGone):
+			c.db.Delete(&models.PushSubscription{}, subscription.ID)
+		case err != nil:
+			lastErr = err
+		default:
+			delivered = true
+			c.db.Model(&models.PushSubscription{}).Where("id = ?", subscription.ID).Update("last_used_at", now)
+		}
+	}
+	if delivered {
+		return true, nil
+	}
+	return false, lastErr
+}
@@ -0,0 +1,295 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+type recordingChannel struct {
+	delivered []models.Reminder
+}
+
+func (c *recordingChannel) Name() string { return "test" }
+
+func (c *recordingChannel) Deliver(_ context.Context, _ *models.User, reminder *models.Reminder) (bool, error) {
+	c.delivered = append(c.delivered, *reminder)
+	return true, nil
+}
+
+func (c *recordingChannel) count(source models.ReminderSource, id uint) int {
+	n := 0
+	for _, reminder := range c.delivered {
+		if reminder.SourceType == source && reminder.SourceID == id {
+			n++
+		}
+	}
+	return n
+}
+
+func openReminderDB(t *testing.T) *gorm.DB {
+	db := newTestDB(t, &models.CalendarEvent{}, &models.CalendarSettings{}, &models.Habit{}, &models.HabitEntry{},
+		&models.Reminder{}, &models.Notification{}, &models.PushSubscription{})
+	return db
+}
+
+func TestReminderScheduler(t *testing.T) {
+	db := openReminderDB(t)
+	user := models.User{Email: "remind@example.com", Username: "remind", Password: "x", Timezone: "UTC"}
+	db.Create(&user)
+	now := time.Date(2026, 6, 1, 8, 0, 30, 0, time.UTC)
+
+	meeting := models.CalendarEvent{UserID: user.ID, Title: "Standup", StartTime: now.Add(20 * time.Minute),
+		EndTime: now.Add(35 * time.Minute), ReminderMinutes: 30}
+	daily := models.CalendarEvent{UserID: user.ID, Title: "Lunch", StartTime: time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC),
+		EndTime: time.Date(2026, 5, 1, 13, 0, 0, 0, time.UTC), Recurring: true, Rrule: "FREQ=DAILY"}
+	muted := models.CalendarEvent{UserID: user.ID, Title: "Focus", StartTime: now.Add(5 * time.Minute),
+		EndTime: now.Add(time.Hour), ReminderMinutes: -1}
+	db.Create(&meeting)
+	db.Create(&daily)
+	db.Create(&muted)
+
+	dueSoon := now.Add(10 * time.Minute)
+	dueLater := now.Add(40 * time.Minute)
+	report := models.Task{UserID: user.ID, Title: "Send report", DueDate: &dueSoon}
+	invoice := models.Task{UserID: user.ID, Title: "Pay invoice", DueDate: &dueLater}
+	db.Create(&report)
+	db.Create(&invoice)
+
+	stretch := models.Habit{UserID: user.ID, Name: "Stretch", TimeOfDay: "morning", IsActive: true}
+	db.Create(&stretch)
+
+	channel := &recordingChannel{}
+	scheduler := NewReminderScheduler(db, ReminderSchedulerOptions{Channels: []ReminderChannel{channel}})
+	sent, err := scheduler.RunOnce(now)
+	if err != nil {
+		t.Fatalf("failed to run reminders: %v", err)
+	}
+	if sent != 3 || channel.count(models.ReminderSourceEvent, meeting.ID) != 1 ||
+		channel.count(models.ReminderSourceTask, report.ID) != 1 || channel.count(models.ReminderSourceHabit, stretch.ID) != 1 {
+		t.Fatalf("expected the meeting, report and habit reminders, got %d: %+v", sent, channel.delivered)
+	}
+
+	var lunch models.Reminder
+	if err := db.Where("source_type = ? AND source_id = ?", models.ReminderSourceEvent, daily.ID).First(&lunch).Error; err != nil {
+		t.Fatalf("expected today's lunch occurrence to be planned: %v", err)
+	}
+	if !lunch.RemindAt.Equal(time.Date(2026, 6, 1, 11, 45, 0, 0, time.UTC)) || lunch.Status != models.ReminderStatusPending {
+		t.Fatalf("unexpected lunch reminder: %+v", lunch)
+	}
+	var mutedCount int64
+	db.Model(&models.Reminder{}).Where("source_type = ? AND source_id = ?", models.ReminderSourceEvent, muted.ID).Count(&mutedCount)
+	if mutedCount != 0 {
+		t.Fatalf("expected no reminder for an event with reminders off")
+	}
+
+	// A new scheduler, as after a restart, sends nothing twice
+	var before int64
+	db.Model(&models.Reminder{}).Count(&before)
+	restarted := NewReminderScheduler(db, ReminderSchedulerOptions{Channels: []ReminderChannel{channel}})
+	if sent, err := restarted.RunOnce(now.Add(time.Minute)); err != nil || sent != 0 {
+		t.Fatalf("expected nothing to resend after a restart, sent %d (err %v)", sent, err)
+	}
+	var after int64
+	db.Model(&models.Reminder{}).Count(&after)
+	if before != after {
+		t.Fatalf("expected planning again to add no reminders, %d became %d", before, after)
+	}
+
+	// Snoozing sends the reminder again later; dismissing stops it
+	service := NewReminderService(db)
+	var reportReminder models.Reminder
+	db.Where("source_type = ? AND source_id = ?", models.ReminderSourceTask, report.ID).First(&reportReminder)
+	if _, err := service.Snooze(user.ID, reportReminder.ID, now.Add(5*time.Minute), now); err != nil {
+		t.Fatalf("failed to snooze: %v", err)
+	}
+	if _, err := service.Snooze(user.ID, reportReminder.ID, now.Add(-time.Minute), now); err == nil {
+		t.Fatalf("expected a snooze into the past to fail")
+	}
+	if _, err := service.Snooze(user.ID+1, reportReminder.ID, now.Add(5*time.Minute), now); err == nil {
+		t.Fatalf("expected another user's reminder to be off limits")
+	}
+	if sent, _ := restarted.RunOnce(now.Add(6 * time.Minute)); sent != 1 || channel.count(models.ReminderSourceTask, report.ID) != 2 {
+		t.Fatalf("expected the snoozed reminder to fire again, sent %d", sent)
+	}
+	if _, err := service.Dismiss(user.ID, lunch.ID, now); err != nil {
+		t.Fatalf("failed to dismiss: %v", err)
+	}
+
+	// A task moved after its reminder was planned gets a new reminder instead
+	var invoiceReminder models.Reminder
+	db.Where("source_type = ? AND source_id = ?", models.ReminderSourceTask, invoice.ID).First(&invoiceReminder)
+	moved := dueLater.Add(2 * time.Hour)
+	db.Model(&invoice).Update("due_date", moved)
+
+	for _, at := range []time.Duration{30 * time.Minute, 2*time.Hour + 30*time.Minute} {
+		if _, err := restarted.RunOnce(now.Add(at)); err != nil {
+			t.Fatalf("failed to run reminders: %v", err)
+		}
+	}
+	db.First(&invoiceReminder, invoiceReminder.ID)
+	db.First(&lunch, lunch.ID)
+	if invoiceReminder.Status != models.ReminderStatusCancelled || lunch.Status != models.ReminderStatusDismissed {
+		t.Fatalf("unexpected statuses: invoice %s, lunch %s", invoiceReminder.Status, lunch.Status)
+	}
+	if channel.count(models.ReminderSourceTask, invoice.ID) != 1 || !channel.delivered[len(channel.delivered)-1].OccursAt.Equal(moved) {
+		t.Fatalf("expected one reminder for the moved due date: %+v", channel.delivered)
+	}
+}
+
+func TestReminderSchedulerExpiresMissedReminders(t *testing.T) {
+	db := openReminderDB(t)
+	user := models.User{Email: "late@example.com", Username: "late", Password: "x"}
+	db.Create(&user)
+	now := time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC)
+
+	due := now.Add(-3 * time.Hour)
+	task := models.Task{UserID: user.ID, Title: "Overdue", DueDate: &due}
+	db.Create(&task)
+	missed := models.Reminder{UserID: user.ID, SourceType: models.ReminderSourceTask, SourceID: task.ID,
+		OccursAt: due, RemindAt: due.Add(-15 * time.Minute), Title: task.Title}
+	db.Create(&missed)
+
+	channel := &recordingChannel{}
+	scheduler := NewReminderScheduler(db, ReminderSchedulerOptions{Channels: []ReminderChannel{channel}})
+	if sent, err := scheduler.RunOnce(now); err != nil || sent != 0 {
+		t.Fatalf("expected a missed reminder not to be sent, sent %d (err %v)", sent, err)
+	}
+	db.First(&missed, missed.ID)
+	if missed.Status != models.ReminderStatusExpired {
+		t.Fatalf("expected the reminder to expire, got %s", missed.Status)
+	}
+}
+
+func TestReminderChannels(t *testing.T) {
+	db := openReminderDB(t)
+	user := models.User{Email: "inbox@example.com", Username: "inbox", Password: "x", EmailNotifications: true, PushNotifications: true}
+	db.Create(&user)
+	reminder := models.Reminder{UserID: user.ID, SourceType: models.ReminderSourceEvent, SourceID: 1, Title: "Dentist",
+		OccursAt: time.Date(2026, 6, 1, 9, 0, 0, 0, time.UTC), RemindAt: time.Date(2026, 6, 1, 8, 45, 0, 0, time.UTC)}
+	db.Create(&reminder)
+
+	delivered, err := NewInAppReminderChannel(db, nil).Deliver(context.Background(), &user, &reminder)
+	if err != nil || !delivered {
+		t.Fatalf("failed to deliver in-app: %v", err)
+	}
+	notifications, unread, err := NewReminderService(db).ListNotifications(user.ID, false, 0)
+	if err != nil || unread != 1 || len(notifications) != 1 || notifications[0].Link != "/app/calendar" {
+		t.Fatalf("unexpected notifications: %+v (unread %d, err %v)", notifications, unread, err)
+	}
+
+	var mailedTo, subject string
+	mailer := func(to, s, _ string) error {
+		mailedTo, subject = to, s
+		return nil
+	}
+	if delivered, err := NewEmailReminderChannel(db, mailer).Deliver(context.Background(), &user, &reminder); err != nil || !delivered {
+		t.Fatalf("failed to deliver email: %v", err)
+	}
+	if mailedTo != user.Email || subject != "Reminder: Dentist" {
+		t.Fatalf("unexpected email to %q: %q", mailedTo, subject)
+	}
+	// The column defaults to true, so it is turned off after the insert
+	db.Create(&models.CalendarSettings{UserID: user.ID})
+	db.Model(&models.CalendarSettings{}).Where("user_id = ?", user.ID).Update("email_reminders_enabled", false)
+	mailedTo = ""
+	if delivered, _ := NewEmailReminderChannel(db, mailer).Deliver(context.Background(), &user, &reminder); delivered || mailedTo != "" {
+		t.Fatalf("expected no email when email reminders are off")
+	}
+
+	// Web Push: the push service receives a payload only the browser can decrypt
+	vapidKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	vapidPrivate, _ := vapidKey.Bytes()
+	vapidPublic, _ := vapidKey.PublicKey.Bytes()
+	browserKey, _ := ecdh.P256().GenerateKey(rand.Reader)
+	authSecret := make([]byte, 16)
+	rand.Read(authSecret)
+
+	var payload map[string]interface{}
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gone" {
+			w.WriteHeader(http.StatusGone)
+			return
+		}
+		authorization = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		plain, err := decryptWebPush(browserKey, authSecret, body)
+		if err != nil || r.Header.Get("Content-Encoding") != "aes128gcm" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.Unmarshal(plain, &payload)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	sender, err := NewWebPushSender(WebPushConfig{
+		PublicKey:  base64.RawURLEncoding.EncodeToString(vapidPublic),
+		PrivateKey: base64.RawURLEncoding.EncodeToString(vapidPrivate),
+		Subject:    "mailto:ops@example.com",
+	})
+	if err != nil {
+		t.Fatalf("failed to create sender: %v", err)
+	}
+	p256dh := base64.RawURLEncoding.EncodeToString(browserKey.PublicKey().Bytes())
+	auth := base64.RawURLEncoding.EncodeToString(authSecret)
+	db.Create(&models.PushSubscription{UserID: user.ID, Endpoint: server.URL + "/push", P256dh: p256dh, Auth: auth})
+	db.Create(&models.PushSubscription{UserID: user.ID, Endpoint: server.URL + "/gone", P256dh: p256dh, Auth: auth})
+
+	delivered, err = NewPushReminderChannel(db, sender).Deliver(context.Background(), &user, &reminder)
+	if err != nil || !delivered {
+		t.Fatalf("failed to deliver push: %v", err)
+	}
+	if payload["title"] != "Dentist" || !strings.HasPrefix(authorization, "vapid t=") {
+		t.Fatalf("unexpected push: %v (authorization %q)", payload, authorization)
+	}
+	var subscriptions int64
+	db.Model(&models.PushSubscription{}).Where("user_id = ?", user.ID).Count(&subscriptions)
+	if subscriptions != 1 {
+		t.Fatalf("expected the gone subscription to be removed, %d left", subscriptions)
+	}
+}
+
+// decryptWebPush does what the browser does with an aes128gcm push message
+func decryptWebPush(key *ecdh.PrivateKey, authSecret, body []byte) ([]byte, error) {
+	salt, idLen := body[:16], int(body[20])
+	serverPublic, record := body[21:21+idLen], body[21+idLen:]
+	peer, err := ecdh.P256().NewPublicKey(serverPublic)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := key.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+	keyInfo := append(append([]byte("WebPush: info\x00"), key.PublicKey().Bytes()...), serverPublic...)
+	ikm := hmacSHA256(hmacSHA256(authSecret, shared), append(keyInfo, 0x01))
+	prk, _ := hkdf.Extract(sha256.New, ikm, salt)
+	cek, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	nonce, _ := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	block, _ := aes.NewCipher(cek)
+	gcm, _ := cipher.NewGCM(block)
+	plain, err := gcm.Open(nil, nonce, record, nil)
+	if err != nil {
+		return nil, err
+	}
+	return plain[:len(plain)-1], nil
+}
@@ -0,0 +1,469 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidRRule is returned for recurrence rules that cannot be parsed or
+// use parts that are not supported
+var ErrInvalidRRule = errors.New("invalid recurrence rule")
+
+// Recurrence frequencies
+const (
+	FreqDaily   = "DAILY"
+	FreqWeekly  = "WEEKLY"
+	FreqMonthly = "MONTHLY"
+	FreqYearly  = "YEARLY"
+)
+
+// maxRRulePeriods bounds how many periods are scanned for the next
+// occurrence, so rules that can never match do not loop forever
+const maxRRulePeriods = 10000
+
+// RRuleDay is a BYDAY entry such as MO, 2TU or -1FR. Ordinal is zero when
+// the rule means every such weekday of the period. It counts within the
+// month, or within the year for yearly rules without BYMONTH.
+type RRuleDay struct {
+	Ordinal int
+	Weekday time.Weekday
+}
+
+// RRule is a parsed RFC 5545 recurrence rule. Supported parts are FREQ,
+// INTERVAL, COUNT, UNTIL, BYDAY, BYMONTHDAY, BYMONTH and WKST.
+type RRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      *time.Time
+	ByDay      []RRuleDay
+	ByMonthDay []int
+	ByMonth    []time.Month
+}
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// ParseRRule parses an RRULE value such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10".
+// A leading "RRULE:" is accepted. UNTIL in the floating form (no trailing
+// Z) is read in loc.
+func ParseRRule(value string, loc *time.Location) (*RRule, error) {
+	value = strings.TrimSpace(value)
+	value = strings.TrimPrefix(strings.TrimPrefix(value, "RRULE:"), "rrule:")
+	if value == "" {
+		return nil, fmt.Errorf("%w: rule is empty", ErrInvalidRRule)
+	}
+
+	rule := &RRule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		name, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("%w: malformed part %q", ErrInvalidRRule, part)
+		}
+		name = strings.ToUpper(strings.TrimSpace(name))
+		val = strings.ToUpper(strings.TrimSpace(val))
+
+		switch name {
+		case "FREQ":
+			switch val {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				rule.Freq = val
+			default:
+				return nil, fmt.Errorf("%w: unsupported frequency %q", ErrInvalidRRule, val)
+			}
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%w: INTERVAL must be a positive number", ErrInvalidRRule)
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("%w: COUNT must be a positive number", ErrInvalidRRule)
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseRRuleTime(val, loc)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, item := range strings.Split(val, ",") {
+				day, err := parseRRuleDay(item)
+				if err != nil {
+					return nil, err
+				}
+				rule.ByDay = append(rule.ByDay, day)
+			}
+		case "BYMONTHDAY":
+			for _, item := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(item)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("%w: invalid BYMONTHDAY %q", ErrInvalidRRule, item)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, item := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(item)
+				if err != nil || n < 1 || n > 12 {
+					return nil, fmt.Errorf("%w: invalid BYMONTH %q", ErrInvalidRRule, item)
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			// Weeks always start on Monday here, which is the RFC default
+			if _, ok := rruleWeekdays[val]; !ok {
+				return nil, fmt.Errorf("%w: invalid WKST %q", ErrInvalidRRule, val)
+			}
+		default:
+			return nil, fmt.Errorf("%w: unsupported part %s", ErrInvalidRRule, name)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalidRRule)
+	}
+	if rule.Count > 0 && rule.Until != nil {
+		return nil, fmt.Errorf("%w: COUNT and UNTIL cannot be combined", ErrInvalidRRule)
+	}
+	yearScope := rule.Freq == FreqYearly && len(rule.ByMonth) == 0
+	for _, day := range rule.ByDay {
+		if day.Ordinal != 0 && rule.Freq != FreqMonthly && rule.Freq != FreqYearly {
+			return nil, fmt.Errorf("%w: numbered BYDAY needs a monthly or yearly rule", ErrInvalidRRule)
+		}
+		if (day.Ordinal > 5 || day.Ordinal < -5) && !yearScope {
+			return nil, fmt.Errorf("%w: BYDAY beyond the fifth weekday needs a yearly rule without BYMONTH", ErrInvalidRRule)
+		}
+	}
+	if len(rule.ByMonthDay) > 0 && rule.Freq == FreqWeekly {
+		return nil, fmt.Errorf("%w: BYMONTHDAY cannot be used with a weekly rule", ErrInvalidRRule)
+	}
+	return rule, nil
+}
+
+// String formats the rule back into RRULE syntax
+func (r *RRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, len(r.ByMonth))
+		for i, month := range r.ByMonth {
+			months[i] = strconv.Itoa(int(month))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = strings.ToUpper(day.Weekday.String()[:2])
+			if day.Ordinal != 0 {
+				days[i] = strconv.Itoa(day.Ordinal) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Iterate calls fn with each occurrence in order, starting with dtstart,
+// until fn returns false or the rule ends. The time of day and location of
+// dtstart are kept, so occurrences follow daylight saving changes.
+func (r *RRule) Iterate(dtstart time.Time, fn func(index int, at time.Time) bool) {
+	index := 1
+	if !fn(index, dtstart) {
+		return
+	}
+
+	last := dtstart
+	for period := 0; period < maxRRulePeriods; period++ {
+		for _, at := range r.periodCandidates(dtstart, period) {
+			if !at.After(last) {
+				continue
+			}
+			if r.Until != nil && at.After(*r.Until) {
+				return
+			}
+			if r.Count > 0 && index >= r.Count {
+				return
+			}
+			index++
+			last = at
+			if !fn(index, at) {
+				return
+			}
+		}
+	}
+}
+
+// Nth returns the n-th occurrence (1-based) counted from dtstart
+func (r *RRule) Nth(dtstart time.Time, n int) (time.Time, bool) {
+	var found time.Time
+	ok := false
+	r.Iterate(dtstart, func(index int, at time.Time) bool {
+		if index == n {
+			found, ok = at, true
+			return false
+		}
+		return true
+	})
+	return found, ok
+}
+
+// Between returns up to limit occurrences after from, counted from dtstart
+func (r *RRule) Between(dtstart, from time.Time, limit int) []time.Time {
+	var occurrences []time.Time
+	r.Iterate(dtstart, func(_ int, at time.Time) bool {
+		if at.After(from) {
+			occurrences = append(occurrences, at)
+		}
+		return len(occurrences) < limit
+	})
+	return occurrences
+}
+
+// periodCandidates expands one period (day, week, month or year) of the
+// rule into sorted candidate times
+func (r *RRule) periodCandidates(dtstart time.Time, period int) []time.Time {
+	loc := dtstart.Location()
+	hour, minute, second := dtstart.Clock()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, 0, loc)
+	}
+
+	var days []time.Time
+	step := period * r.Interval
+	switch r.Freq {
+	case FreqDaily:
+		day := at(dtstart.Year(), dtstart.Month(), dtstart.Day()+step)
+		if r.matchesDay(day) {
+			days = append(days, day)
+		}
+
+	case FreqWeekly:
+		offset := (int(dtstart.Weekday()) + 6) % 7 // days since Monday
+		monday := at(dtstart.Year(), dtstart.Month(), dtstart.Day()-offset+7*step)
+		weekdays := r.ByDay
+		if len(weekdays) == 0 {
+			weekdays = []RRuleDay{{Weekday: dtstart.Weekday()}}
+		}
+		for _, weekday := range weekdays {
+			day := at(monday.Year(), monday.Month(), monday.Day()+(int(weekday.Weekday)+6)%7)
+			if r.matchesMonth(day.Month()) {
+				days = append(days, day)
+			}
+		}
+
+	case FreqMonthly:
+		first := at(dtstart.Year(), dtstart.Month()+time.Month(step), 1)
+		if r.matchesMonth(first.Month()) {
+			days = r.monthCandidates(first, dtstart.Day())
+		}
+
+	case FreqYearly:
+		year := dtstart.Year() + step
+		if len(r.ByMonth) == 0 && len(r.ByMonthDay) == 0 && len(r.ByDay) > 0 {
+			days = r.yearCandidates(at(year, time.January, 1))
+			break
+		}
+		// Without BYMONTH, BYMONTHDAY applies to every month; otherwise the
+		// rule repeats in the month of dtstart
+		months := r.ByMonth
+		if len(months) == 0 && len(r.ByMonthDay) > 0 {
+			months = []time.Month{time.January, time.February, time.March, time.April, time.May, time.June,
+				time.July, time.August, time.September, time.October, time.November, time.December}
+		} else if len(months) == 0 {
+			months = []time.Month{dtstart.Month()}
+		}
+		for _, month := range months {
+			days = append(days, r.monthCandidates(at(year, month, 1), dtstart.Day())...)
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// monthCandidates lists the days of the month starting at first that the
+// BYMONTHDAY and BYDAY parts select, defaulting to the day of dtstart.
+// Months without that day are skipped, as RFC 5545 requires.
+func (r *RRule) monthCandidates(first time.Time, defaultDay int) []time.Time {
+	daysInMonth := first.AddDate(0, 1, -1).Day()
+	dayAt := func(day int) time.Time { return first.AddDate(0, 0, day-1) }
+
+	var days []time.Time
+	switch {
+	case len(r.ByMonthDay) > 0:
+		for _, n := range r.ByMonthDay {
+			day := n
+			if n < 0 {
+				day = daysInMonth + n + 1
+			}
+			if day < 1 || day > daysInMonth {
+				continue
+			}
+			candidate := dayAt(day)
+			if len(r.ByDay) == 0 || r.matchesWeekday(candidate.Weekday()) {
+				days = append(days, candidate)
+			}
+		}
+
+	case len(r.ByDay) > 0:
+		for _, weekday := range r.ByDay {
+			for _, offset := range weekdayOffsets(weekday, first.Weekday(), daysInMonth) {
+				days = append(days, dayAt(offset+1))
+			}
+		}
+
+	default:
+		if defaultDay <= daysInMonth {
+			days = append(days, dayAt(defaultDay))
+		}
+	}
+	return days
+}
+
+// yearCandidates lists the days of the year starting at first that the
+// BYDAY part selects, for yearly rules without BYMONTH or BYMONTHDAY.
+// Numbered entries such as 20MO count weeks of the whole year.
+func (r *RRule) yearCandidates(first time.Time) []time.Time {
+	daysInYear := first.AddDate(1, 0, -1).YearDay()
+
+	var days []time.Time
+	for _, weekday := range r.ByDay {
+		for _, offset := range weekdayOffsets(weekday, first.Weekday(), daysInYear) {
+			days = append(days, first.AddDate(0, 0, offset))
+		}
+	}
+	return days
+}
+
+// weekdayOffsets returns the offsets from the start of a span of length
+// days, beginning on weekday start, that a BYDAY entry selects
+func weekdayOffsets(day RRuleDay, start time.Weekday, length int) []int {
+	var matches []int
+	for offset := (int(day.Weekday) - int(start) + 7) % 7; offset < length; offset += 7 {
+		matches = append(matches, offset)
+	}
+	switch {
+	case day.Ordinal == 0:
+		return matches
+	case day.Ordinal > 0 && day.Ordinal <= len(matches):
+		return matches[day.Ordinal-1 : day.Ordinal]
+	case day.Ordinal < 0 && -day.Ordinal <= len(matches):
+		return matches[len(matches)+day.Ordinal : len(matches)+day.Ordinal+1]
+	}
+	return nil
+}
+
+func (r *RRule) matchesDay(day time.Time) bool {
+	if !r.matchesMonth(day.Month()) {
+		return false
+	}
+	if len(r.ByDay) > 0 && !r.matchesWeekday(day.Weekday()) {
+		return false
+	}
+	if len(r.ByMonthDay) > 0 {
+		daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+		for _, n := range r.ByMonthDay {
+			if n == day.Day() || (n < 0 && daysInMonth+n+1 == day.Day()) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func (r *RRule) matchesWeekday(weekday time.Weekday) bool {
+	for _, day := range r.ByDay {
+		if day.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RRule) matchesMonth(month time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+func parseRRuleDay(value string) (RRuleDay, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 {
+		return RRuleDay{}, fmt.Errorf("%w: invalid BYDAY %q", ErrInvalidRRule, value)
+	}
+	weekday, ok := rruleWeekdays[value[len(value)-2:]]
+	if !ok {
+		return RRuleDay{}, fmt.Errorf("%w: invalid BYDAY %q", ErrInvalidRRule, value)
+	}
+
+	day := RRuleDay{Weekday: weekday}
+	if prefix := value[:len(value)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -53 || n > 53 {
+			return RRuleDay{}, fmt.Errorf("%w: invalid BYDAY %q", ErrInvalidRRule, value)
+		}
+		day.Ordinal = n
+	}
+	return day, nil
+}
+
+func parseRRuleTime(value string, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		var (
+			t   time.Time
+			err error
+		)
+		if strings.HasSuffix(layout, "Z") {
+			t, err = time.Parse(layout, value)
+		} else {
+			t, err = time.ParseInLocation(layout, value, loc)
+		}
+		if err == nil {
+			if layout == "20060102" {
+				// A date-only UNTIL includes the whole day
+				t = t.AddDate(0, 0, 1).Add(-time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: invalid UNTIL %q", ErrInvalidRRule, value)
+}
@@ -0,0 +1,655 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrTagExists is returned when a tag name or alias is already taken
+	ErrTagExists = errors.New("a tag or alias with this name already exists")
+	// ErrInvalidTagName is returned for names that are empty once normalized
+	ErrInvalidTagName = errors.New("tag name is required")
+	// ErrTagCycle is returned when a tag would end up inside its own subtree
+	ErrTagCycle = errors.New("a tag cannot be moved or merged into itself or one of its descendants")
+)
+
+// tagJoinTable links one kind of item to tags. Counted tables hold the
+// user's own content and make up Tag.UsageCount.
+type tagJoinTable struct {
+	table   string
+	key     string
+	items   string
+	counted bool
+}
+
+var tagJoinTables = []tagJoinTable{
+	{table: "bookmark_tags", key: "bookmark_id", items: "bookmarks", counted: true},
+	{table: "task_tags", key: "task_id", items: "tasks", counted: true},
+	{table: "note_tags", key: "note_id", items: "notes", counted: true},
+	{table: "file_tags", key: "file_id", items: "files", counted: true},
+	{table: "time_entry_tags", key: "time_entry_id", items: "time_entries", counted: true},
+	{table: "task_recurrence_tags", key: "task_recurrence_id", items: "task_recurrences"},
+	{table: "wiki_page_tags", key: "wiki_page_id", items: "wiki_pages"},
+	{table: "learning_path_tags", key: "learning_path_id", items: "learning_paths"},
+	{table: "scraped_content_tags", key: "scraped_content_id", items: "scraped_contents"},
+	{table: "content_analytics_tags", key: "content_analytics_id", items: "content_analytics"},
+}
+
+// existingTagJoinTables skips join tables whose models were not migrated
+func existingTagJoinTables(tx *gorm.DB) []tagJoinTable {
+	tables := make([]tagJoinTable, 0, len(tagJoinTables))
+	for _, join := range tagJoinTables {
+		if tx.Migrator().HasTable(join.table) {
+			tables = append(tables, join)
+		}
+	}
+	return tables
+}
+
+// NormalizeTagName trims each level of a tag path and drops empty levels,
+// so " dev / go/" becomes "dev/go"
+func NormalizeTagName(name string) string {
+	parts := strings.Split(name, "/")
+	levels := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part = strings.Join(strings.Fields(part), " "); part != "" {
+			levels = append(levels, part)
+		}
+	}
+	return strings.Join(levels, "/")
+}
+
+// tagKey is the case-insensitive lookup form of a tag name or alias
+func tagKey(name string) string {
+	return strings.ToLower(NormalizeTagName(name))
+}
+
+// isTagDescendant reports whether name lies below ancestor in the hierarchy
+func isTagDescendant(name, ancestor string) bool {
+	return strings.HasPrefix(strings.ToLower(name), strings.ToLower(ancestor)+"/")
+}
+
+// tagLeaf returns the last level of a tag path
+func tagLeaf(name string) string {
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// ResolveTag finds the user's tag by name or alias, case-insensitively
+func ResolveTag(tx *gorm.DB, userID uint, name string) (*models.Tag, error) {
+	key := tagKey(name)
+	if key == "" {
+		return nil, ErrInvalidTagName
+	}
+
+	var tag models.Tag
+	err := tx.Where("user_id = ? AND LOWER(name) = ?", userID, key).First(&tag).Error
+	if err == nil {
+		return &tag, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var alias models.TagAlias
+	if err := tx.Where("user_id = ? AND alias = ?", userID, key).First(&alias).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("id = ? AND user_id = ?", alias.TagID, userID).First(&tag).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// FindOrCreateTag resolves a tag for the user, creating it and any missing
+// parent levels. Aliases apply to every level, so with "golang" aliased to
+// "go", "golang/testing" resolves to or creates "go/testing".
+func FindOrCreateTag(tx *gorm.DB, userID uint, name string) (*models.Tag, error) {
+	tag, err := ResolveTag(tx, userID, name)
+	if err == nil || !errors.Is(err, gorm.ErrRecordNotFound) {
+		return tag, err
+	}
+
+	normalized := NormalizeTagName(name)
+	tag = &models.Tag{UserID: userID, Name: normalized}
+	if i := strings.LastIndex(normalized, "/"); i >= 0 {
+		parent, err := FindOrCreateTag(tx, userID, normalized[:i])
+		if err != nil {
+			return nil, err
+		}
+		tag.ParentID = &parent.ID
+		tag.Name = parent.Name + "/" + normalized[i+1:]
+
+		// The parent may have resolved through an alias to a path that
+		// already has this child
+		if existing, err := ResolveTag(tx, userID, tag.Name); err == nil {
+			return existing, nil
+		}
+	}
+
+	if err := tx.Create(tag).Error; err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// TagService manages per-user hierarchical tags and their aliases
+type TagService struct {
+	db *gorm.DB
+}
+
+// NewTagService creates a new tag service
+func NewTagService(db *gorm.DB) *TagService {
+	return &TagService{db: db}
+}
+
+// Tree returns the user's tags nested by parent, with their aliases
+func (s *TagService) Tree(userID uint) ([]models.Tag, error) {
+	var tags []models.Tag
+	if err := s.db.Where("user_id = ?", userID).Preload("Aliases").Order("name ASC").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return buildTagTree(tags), nil
+}
+
+// Get returns one of the user's tags with its aliases and direct children
+func (s *TagService) Get(userID, id uint) (*models.Tag, error) {
+	var tag models.Tag
+	if err := s.db.Where("id = ? AND user_id = ?", id, userID).Preload("Aliases").First(&tag).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("user_id = ? AND parent_id = ?", userID, id).Order("name ASC").Find(&tag.Children).Error; err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// Create adds a tag, creating missing parent levels
+func (s *TagService) Create(userID uint, name, description, color string) (*models.Tag, error) {
+	if tagKey(name) == "" {
+		return nil, ErrInvalidTagName
+	}
+
+	var tag *models.Tag
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := ResolveTag(tx, userID, name); err == nil {
+			return ErrTagExists
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		created, err := FindOrCreateTag(tx, userID, name)
+		if err != nil {
+			return err
+		}
+		updates := map[string]interface{}{}
+		if description != "" {
+			updates["description"] = description
+		}
+		if color != "" {
+			updates["color"] = color
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(created).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		tag = created
+		return nil
+	})
+	return tag, err
+}
+
+// Rename gives a tag a new path, moving its descendants along with it.
+// Renaming "dev/go" to "lang/go" also renames "dev/go/testing" to
+// "lang/go/testing" and creates "lang" if needed.
+func (s *TagService) Rename(userID, id uint, name string) (*models.Tag, error) {
+	normalized := NormalizeTagName(name)
+	if normalized == "" {
+		return nil, ErrInvalidTagName
+	}
+
+	var tag models.Tag
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&tag).Error; err != nil {
+			return err
+		}
+		if tag.Name == normalized {
+			return nil
+		}
+		if isTagDescendant(normalized, tag.Name) {
+			return ErrTagCycle
+		}
+		if existing, err := ResolveTag(tx, userID, normalized); err == nil && existing.ID != tag.ID {
+			return ErrTagExists
+		} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		var parentID *uint
+		if i := strings.LastIndex(normalized, "/"); i >= 0 {
+			parent, err := FindOrCreateTag(tx, userID, normalized[:i])
+			if err != nil {
+				return err
+			}
+			if parent.ID == tag.ID || isTagDescendant(parent.Name, tag.Name) {
+				return ErrTagCycle
+			}
+			parentID = &parent.ID
+			normalized = parent.Name + "/" + normalized[i+1:]
+		}
+
+		return renameTagSubtree(tx, &tag, normalized, parentID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+// Delete removes a tag and its descendants along with their aliases and
+// links to items. The items themselves are kept.
+func (s *TagService) Delete(userID, id uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var tag models.Tag
+		if err := tx.Where("id = ? AND user_id = ?", id, userID).First(&tag).Error; err != nil {
+			return err
+		}
+
+		ids, err := tagSubtreeIDs(tx, &tag)
+		if err != nil {
+			return err
+		}
+		for _, join := range existingTagJoinTables(tx) {
+			if err := tx.Exec("DELETE FROM "+join.table+" WHERE tag_id IN ?", ids).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("tag_id IN ?", ids).Delete(&models.TagAlias{}).Error; err != nil {
+			return err
+		}
+		// Hard delete so the names can be reused under the per-user unique index
+		return tx.Unscoped().Where("id IN ?", ids).Delete(&models.Tag{}).Error
+	})
+}
+
+// AddAlias makes alias resolve to the tag
+func (s *TagService) AddAlias(userID, tagID uint, alias string) (*models.TagAlias, error) {
+	key := tagKey(alias)
+	if key == "" {
+		return nil, ErrInvalidTagName
+	}
+
+	var created models.TagAlias
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var tag models.Tag
+		if err := tx.Where("id = ? AND user_id = ?", tagID, userID).First(&tag).Error; err != nil {
+			return err
+		}
+		if _, err := ResolveTag(tx, userID, key); err == nil {
+			return ErrTagExists
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		created = models.TagAlias{UserID: userID, TagID: tag.ID, Alias: key}
+		return tx.Create(&created).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// RemoveAlias deletes one of the tag's aliases
+func (s *TagService) RemoveAlias(userID, tagID, aliasID uint) error {
+	result := s.db.Where("id = ? AND tag_id = ? AND user_id = ?", aliasID, tagID, userID).Delete(&models.TagAlias{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Merge folds the source tags into the target: their items are re-tagged,
+// their aliases move over and their names become aliases of the target.
+// Children are moved below the target, merging with children of the same
+// name. Usage counts are recalculated afterwards.
+func (s *TagService) Merge(userID, targetID uint, sourceIDs []uint) (*models.Tag, error) {
+	var target models.Tag
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ?", targetID, userID).First(&target).Error; err != nil {
+			return err
+		}
+
+		for _, sourceID := range uniqueIDs(sourceIDs) {
+			if sourceID == target.ID {
+				continue
+			}
+			var source models.Tag
+			if err := tx.Where("id = ? AND user_id = ?", sourceID, userID).First(&source).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					// Already merged as a descendant of an earlier source
+					continue
+				}
+				return err
+			}
+			if isTagDescendant(target.Name, source.Name) {
+				return ErrTagCycle
+			}
+			if err := mergeTagInto(tx, &target, &source); err != nil {
+				return err
+			}
+		}
+
+		return RecalculateTagUsage(tx, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Preload("Aliases").First(&target, target.ID).Error; err != nil {
+		return nil, err
+	}
+	return &target, nil
+}
+
+// FilterIDs resolves tag names for filtering: each name (or alias) matches
+// the tag and all of its descendants. Unknown names match nothing.
+func (s *TagService) FilterIDs(userID uint, names []string) ([]uint, error) {
+	var ids []uint
+	for _, name := range names {
+		tag, err := ResolveTag(s.db, userID, name)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, ErrInvalidTagName) {
+				continue
+			}
+			return nil, err
+		}
+		subtree, err := tagSubtreeIDs(s.db, tag)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, subtree...)
+	}
+	return uniqueIDs(ids), nil
+}
+
+// RecalculateTagUsage recounts how many live items carry each of the user's
+// tags. A zero user ID recounts every tag.
+func RecalculateTagUsage(tx *gorm.DB, userID uint) error {
+	query := tx.Model(&models.Tag{})
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	} else {
+		query = query.Where("1 = 1")
+	}
+	return recountTagUsage(tx, query)
+}
+
+// recalculateTagUsageFor recounts the given tags only, as after items are
+// tagged or untagged in bulk
+func recalculateTagUsageFor(tx *gorm.DB, tagIDs []uint) error {
+	if len(tagIDs) == 0 {
+		return nil
+	}
+	return recountTagUsage(tx, tx.Model(&models.Tag{}).Where("id IN ?", uniqueIDs(tagIDs)))
+}
+
+func recountTagUsage(tx *gorm.DB, query *gorm.DB) error {
+	var counts []string
+	for _, join := range existingTagJoinTables(tx) {
+		if !join.counted {
+			continue
+		}
+		counts = append(counts, fmt.Sprintf(
+			"(SELECT COUNT(*) FROM %[1]s JOIN %[3]s ON %[3]s.id = %[1]s.%[2]s WHERE %[1]s.tag_id = tags.id AND %[3]s.deleted_at IS NULL)",
+			join.table, join.key, join.items))
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+	return query.UpdateColumn("usage_count", gorm.Expr(strings.Join(counts, " + "))).Error
+}
+
+// MigrateLegacyTags moves data from the old global tag namespace to
+// per-user tags: items linked to another user's tag are re-linked to a tag
+// of the same name owned by the item's user, unused ownerless tags are
+// removed, parents are created for path-style names and usage counts are
+// recalculated. It is safe to run on every start.
+func (s *TagService) MigrateLegacyTags() error {
+	for _, join := range existingTagJoinTables(s.db) {
+		if !join.counted {
+			continue
+		}
+		if err := s.migrateJoinOwnership(join); err != nil {
+			return fmt.Errorf("%s: %w", join.table, err)
+		}
+	}
+
+	var used []string
+	for _, join := range existingTagJoinTables(s.db) {
+		used = append(used, "SELECT tag_id FROM "+join.table)
+	}
+	orphans := s.db.Unscoped().Where("user_id = 0")
+	if len(used) > 0 {
+		orphans = orphans.Where("id NOT IN (" + strings.Join(used, " UNION ") + ")")
+	}
+	if err := orphans.Delete(&models.Tag{}).Error; err != nil {
+		return err
+	}
+
+	var unparented []models.Tag
+	if err := s.db.Where("parent_id IS NULL AND name LIKE ?", "%/%").Find(&unparented).Error; err != nil {
+		return err
+	}
+	for _, tag := range unparented {
+		normalized := NormalizeTagName(tag.Name)
+		i := strings.LastIndex(normalized, "/")
+		if i < 0 {
+			continue
+		}
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			parent, err := FindOrCreateTag(tx, tag.UserID, normalized[:i])
+			if err != nil {
+				return err
+			}
+			return tx.Model(&models.Tag{}).Where("id = ?", tag.ID).UpdateColumn("parent_id", parent.ID).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return RecalculateTagUsage(s.db, 0)
+}
+
+// migrateJoinOwnership re-links items tagged with another user's tag
+func (s *TagService) migrateJoinOwnership(join tagJoinTable) error {
+	var rows []struct {
+		ItemID uint
+		TagID  uint
+		UserID uint
+		Name   string
+	}
+	if err := s.db.Raw(fmt.Sprintf(
+		`SELECT %[1]s.%[2]s AS item_id, %[1]s.tag_id AS tag_id, %[3]s.user_id AS user_id, tags.name AS name
+		FROM %[1]s
+		JOIN %[3]s ON %[3]s.id = %[1]s.%[2]s
+		JOIN tags ON tags.id = %[1]s.tag_id
+		WHERE %[3]s.user_id <> tags.user_id`, join.table, join.key, join.items)).Scan(&rows).Error; err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		owned := make(map[string]*models.Tag)
+		for _, row := range rows {
+			cacheKey := fmt.Sprintf("%d:%s", row.UserID, strings.ToLower(row.Name))
+			tag, ok := owned[cacheKey]
+			if !ok {
+				var err error
+				if tag, err = FindOrCreateTag(tx, row.UserID, row.Name); err != nil {
+					return err
+				}
+				owned[cacheKey] = tag
+			}
+
+			if err := tx.Table(join.table).Clauses(clause.OnConflict{DoNothing: true}).
+				Create(map[string]interface{}{join.key: row.ItemID, "tag_id": tag.ID}).Error; err != nil {
+				return err
+			}
+			if err := tx.Exec("DELETE FROM "+join.table+" WHERE "+join.key+" = ? AND tag_id = ?", row.ItemID, row.TagID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// mergeTagInto moves everything attached to source onto target and deletes source
+func mergeTagInto(tx *gorm.DB, target, source *models.Tag) error {
+	var children []models.Tag
+	if err := tx.Where("parent_id = ?", source.ID).Find(&children).Error; err != nil {
+		return err
+	}
+	for i := range children {
+		child := &children[i]
+		name := target.Name + "/" + tagLeaf(child.Name)
+		var existing models.Tag
+		err := tx.Where("user_id = ? AND LOWER(name) = ? AND id <> ?", target.UserID, strings.ToLower(name), child.ID).First(&existing).Error
+		switch {
+		case err == nil:
+			if err := mergeTagInto(tx, &existing, child); err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := renameTagSubtree(tx, child, name, &target.ID); err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+	}
+
+	for _, join := range existingTagJoinTables(tx) {
+		// Items carrying both tags keep the target link only
+		if err := tx.Exec(fmt.Sprintf("DELETE FROM %[1]s WHERE tag_id = ? AND %[2]s IN (SELECT %[2]s FROM %[1]s WHERE tag_id = ?)",
+			join.table, join.key), source.ID, target.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("UPDATE "+join.table+" SET tag_id = ? WHERE tag_id = ?", target.ID, source.ID).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Model(&models.TagAlias{}).Where("tag_id = ?", source.ID).Update("tag_id", target.ID).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Delete(&models.Tag{}, source.ID).Error; err != nil {
+		return err
+	}
+
+	// The old name keeps working as an alias
+	alias := models.TagAlias{UserID: target.UserID, TagID: target.ID, Alias: tagKey(source.Name)}
+	if alias.Alias != tagKey(target.Name) {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&alias).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renameTagSubtree renames a tag and rewrites the paths of its descendants
+func renameTagSubtree(tx *gorm.DB, tag *models.Tag, name string, parentID *uint) error {
+	descendants, err := tagDescendants(tx, tag)
+	if err != nil {
+		return err
+	}
+
+	oldName := tag.Name
+	if err := tx.Model(&models.Tag{}).Where("id = ?", tag.ID).Updates(map[string]interface{}{
+		"name":      name,
+		"parent_id": parentID,
+	}).Error; err != nil {
+		return err
+	}
+	for _, descendant := range descendants {
+		if err := tx.Model(&models.Tag{}).Where("id = ?", descendant.ID).
+			Update("name", name+descendant.Name[len(oldName):]).Error; err != nil {
+			return err
+		}
+	}
+
+	tag.Name = name
+	tag.ParentID = parentID
+	return nil
+}
+
+// tagDescendants returns every tag below tag in the hierarchy
+func tagDescendants(tx *gorm.DB, tag *models.Tag) ([]models.Tag, error) {
+	var tags []models.Tag
+	if err := tx.Select("id", "name", "parent_id").Where("user_id = ?", tag.UserID).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	var descendants []models.Tag
+	for _, candidate := range tags {
+		if isTagDescendant(candidate.Name, tag.Name) {
+			descendants = append(descendants, candidate)
+		}
+	}
+	return descendants, nil
+}
+
+// tagSubtreeIDs returns the tag's ID followed by its descendants' IDs
+func tagSubtreeIDs(tx *gorm.DB, tag *models.Tag) ([]uint, error) {
+	descendants, err := tagDescendants(tx, tag)
+	if err != nil {
+		return nil, err
+	}
+	ids := []uint{tag.ID}
+	for _, descendant := range descendants {
+		ids = append(ids, descendant.ID)
+	}
+	return ids, nil
+}
+
+// buildTagTree nests tags under their parents; tags whose parent is
+// missing are treated as roots
+func buildTagTree(tags []models.Tag) []models.Tag {
+	byParent := make(map[uint][]models.Tag)
+	known := make(map[uint]bool, len(tags))
+	for _, tag := range tags {
+		known[tag.ID] = true
+	}
+
+	var roots []models.Tag
+	for _, tag := range tags {
+		if tag.ParentID != nil && known[*tag.ParentID] {
+			byParent[*tag.ParentID] = append(byParent[*tag.ParentID], tag)
+			continue
+		}
+		roots = append(roots, tag)
+	}
+
+	var attach func(list []models.Tag) []models.Tag
+	attach = func(list []models.Tag) []models.Tag {
+		sort.SliceStable(list, func(i, j int) bool { return strings.ToLower(list[i].Name) < strings.ToLower(list[j].Name) })
+		for i := range list {
+			list[i].Children = attach(byParent[list[i].ID])
+		}
+		return list
+	}
+	return attach(roots)
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+func openTagTestDB(t *testing.T) *gorm.DB {
+	db := newTestDB(t, &models.TimeEntry{})
+	return db
+}
+
+func TestTagService(t *testing.T) {
+	db := openTagTestDB(t)
+	service := NewTagService(db)
+
+	// Names are unique per user, not globally
+	mine, err := service.Create(1, " dev / go/testing ", "", "")
+	if err != nil {
+		t.Fatalf("failed to create tag: %v", err)
+	}
+	if mine.Name != "dev/go/testing" || mine.ParentID == nil {
+		t.Fatalf("unexpected tag: %+v", mine)
+	}
+	if _, err := service.Create(2, "dev/go/testing", "", ""); err != nil {
+		t.Fatalf("expected another user to reuse the name, got %v", err)
+	}
+	if _, err := service.Create(1, "Dev/Go", "", ""); !errors.Is(err, ErrTagExists) {
+		t.Fatalf("expected duplicate to be rejected, got %v", err)
+	}
+
+	tree, _ := service.Tree(1)
+	if len(tree) != 1 || tree[0].Name != "dev" || len(tree[0].Children) != 1 || len(tree[0].Children[0].Children) != 1 {
+		t.Fatalf("unexpected tree: %+v", tree)
+	}
+	goTag := tree[0].Children[0]
+
+	// Aliases resolve at any level of a path
+	if _, err := service.AddAlias(1, goTag.ID, "Golang"); err != nil {
+		t.Fatalf("failed to add alias: %v", err)
+	}
+	resolved, err := FindOrCreateTag(db, 1, "golang/testing")
+	if err != nil || resolved.ID != mine.ID {
+		t.Fatalf("expected alias to resolve to %d, got %+v (err %v)", mine.ID, resolved, err)
+	}
+	bench, _ := FindOrCreateTag(db, 1, "golang/bench")
+	if bench.Name != "dev/go/bench" || bench.ParentID == nil || *bench.ParentID != goTag.ID {
+		t.Fatalf("unexpected child created through alias: %+v", bench)
+	}
+
+	// Filtering by a parent includes its descendants
+	ids, err := service.FilterIDs(1, []string{"dev/go"})
+	if err != nil || len(ids) != 3 {
+		t.Fatalf("expected go and its two children, got %v (err %v)", ids, err)
+	}
+
+	// Merge "testing" and a loose "tests" tag into "qa"
+	qa, _ := service.Create(1, "qa", "", "")
+	tests, _ := service.Create(1, "tests", "", "")
+	both := models.Bookmark{UserID: 1, Title: "Both", URL: "https://both.example", Tags: []models.Tag{*mine, *tests, *qa}}
+	db.Create(&both)
+	task := models.Task{UserID: 1, Title: "Write tests", Tags: []models.Tag{*mine}}
+	db.Create(&task)
+	entry := models.TimeEntry{UserID: 1, Description: "Testing", Tags: []models.Tag{*tests}}
+	db.Create(&entry)
+
+	merged, err := service.Merge(1, qa.ID, []uint{mine.ID, tests.ID})
+	if err != nil {
+		t.Fatalf("failed to merge: %v", err)
+	}
+	if merged.UsageCount != 3 || len(merged.Aliases) != 2 {
+		t.Fatalf("unexpected merged tag: %+v", merged)
+	}
+	var bookmark models.Bookmark
+	db.Preload("Tags").First(&bookmark, both.ID)
+	if len(bookmark.Tags) != 1 || bookmark.Tags[0].ID != qa.ID {
+		t.Fatalf("expected one qa tag on the bookmark, got %+v", bookmark.Tags)
+	}
+	var remaining int64
+	db.Model(&models.Tag{}).Where("id IN ?", []uint{mine.ID, tests.ID}).Count(&remaining)
+	if remaining != 0 {
+		t.Fatalf("expected merged tags to be removed")
+	}
+	if tag, err := ResolveTag(db, 1, "dev/go/testing"); err != nil || tag.ID != qa.ID {
+		t.Fatalf("expected old name to resolve to the merged tag, got %+v (err %v)", tag, err)
+	}
+
+	// Merging a parent into a tag below it is rejected
+	dev, _ := ResolveTag(db, 1, "dev")
+	if _, err := service.Merge(1, bench.ID, []uint{dev.ID}); !errors.Is(err, ErrTagCycle) {
+		t.Fatalf("expected cycle to be rejected, got %v", err)
+	}
+
+	// Renaming moves descendants
+	if _, err := service.Rename(1, goTag.ID, "lang/go"); err != nil {
+		t.Fatalf("failed to rename: %v", err)
+	}
+	var moved models.Tag
+	db.First(&moved, bench.ID)
+	if moved.Name != "lang/go/bench" {
+		t.Fatalf("expected descendant to move, got %q", moved.Name)
+	}
+}
+
+func TestMigrateLegacyTags(t *testing.T) {
+	db := openTagTestDB(t)
+
+	// Before tags were per user, every user shared the same rows
+	shared := models.Tag{Name: "Dev/Go"}
+	db.Create(&shared)
+	unused := models.Tag{Name: "unused"}
+	db.Create(&unused)
+	first := models.Bookmark{UserID: 1, Title: "A", URL: "https://a.example", Tags: []models.Tag{shared}}
+	db.Create(&first)
+	second := models.Note{UserID: 2, Title: "B", Tags: []models.Tag{shared}}
+	db.Create(&second)
+
+	if err := NewTagService(db).MigrateLegacyTags(); err != nil {
+		t.Fatalf("failed to migrate tags: %v", err)
+	}
+	// Running again changes nothing
+	if err := NewTagService(db).MigrateLegacyTags(); err != nil {
+		t.Fatalf("failed to rerun migration: %v", err)
+	}
+
+	var bookmark models.Bookmark
+	db.Preload("Tags").First(&bookmark, first.ID)
+	var note models.Note
+	db.Preload("Tags").First(&note, second.ID)
+	if len(bookmark.Tags) != 1 || bookmark.Tags[0].UserID != 1 || bookmark.Tags[0].Name != "Dev/Go" || bookmark.Tags[0].ParentID == nil {
+		t.Fatalf("unexpected bookmark tags: %+v", bookmark.Tags)
+	}
+	if len(note.Tags) != 1 || note.Tags[0].UserID != 2 || note.Tags[0].UsageCount != 1 {
+		t.Fatalf("unexpected note tags: %+v", note.Tags)
+	}
+
+	var ownerless int64
+	db.Model(&models.Tag{}).Where("user_id = 0").Count(&ownerless)
+	if ownerless != 0 {
+		t.Fatalf("expected ownerless tags to be removed, %d left", ownerless)
+	}
+	var total int64
+	db.Model(&models.Tag{}).Count(&total)
+	if total != 4 {
+		t.Fatalf("expected a Dev and Dev/Go tag per user, got %d tags", total)
+	}
+}
@@ -0,0 +1,571 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTaskForbidden      = errors.New("not allowed to change this task")
+	ErrInvalidTaskComment = errors.New("invalid comment")
+	ErrInvalidAssignee    = errors.New("invalid assignee")
+)
+
+const maxTaskCommentLength = 20000
+
+// mentionPattern finds @username mentions that are not part of an email address
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9_][A-Za-z0-9_.-]*)`)
+
+// Kinds of timeline entries
+const (
+	TaskTimelineComment    = "comment"
+	TaskTimelineAttachment = "attachment"
+	TaskTimelineChange     = "change"
+)
+
+// TaskTimelineEntry is one item on a task's timeline: a comment thread, an
+// attachment or a field change
+type TaskTimelineEntry struct {
+	Type       string                 `json:"type"`
+	At         time.Time              `json:"at"`
+	Comment    *models.TaskComment    `json:"comment,omitempty"`
+	Attachment *models.TaskAttachment `json:"attachment,omitempty"`
+	Change     *models.TaskChange     `json:"change,omitempty"`
+}
+
+// TaskCommentInput creates or edits a comment. ParentID replies to a comment.
+type TaskCommentInput struct {
+	Body     string `json:"body" binding:"required"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+// TaskActivityService manages comments, attachments and the change history
+// of tasks. The owner and assignee of a task, and the members of teams it is
+// shared with, all see the same timeline; team viewers cannot add to it.
+type TaskActivityService struct {
+	db *gorm.DB
+}
+
+// NewTaskActivityService creates a new task activity service
+func NewTaskActivityService(db *gorm.DB) *TaskActivityService {
+	return &TaskActivityService{db: db}
+}
+
+// taskAccess is a task and what the user may do with it
+type taskAccess struct {
+	task  *models.Task
+	write bool
+	owner bool
+}
+
+// access loads the task for a user who can see it. Tasks the user cannot
+// see are reported as not found.
+func (s *TaskActivityService) access(tx *gorm.DB, userID, taskID uint) (*taskAccess, error) {
+	var task models.Task
+	if err := tx.First(&task, taskID).Error; err != nil {
+		return nil, err
+	}
+	if task.UserID == userID {
+		return &taskAccess{task: &task, write: true, owner: true}, nil
+	}
+	if task.AssigneeID != nil && *task.AssigneeID == userID {
+		return &taskAccess{task: &task, write: true}, nil
+	}
+
+	var roles []string
+	if err := tx.Model(&models.TeamMember{}).
+		Joins("JOIN team_tasks ON team_tasks.team_id = team_members.team_id AND team_tasks.deleted_at IS NULL").
+		Where("team_tasks.task_id = ? AND team_members.user_id = ?", taskID, userID).
+		Pluck("team_members.role", &roles).Error; err != nil {
+		return nil, err
+	}
+	if len(roles) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	access := &taskAccess{task: &task}
+	for _, role := range roles {
+		if role != "viewer" {
+			access.write = true
+		}
+	}
+	return access, nil
+}
+
+// ValidateAssignee checks that the user may assign a task to assigneeID:
+// themselves, or someone they share a team with
+func (s *TaskActivityService) ValidateAssignee(userID uint, assigneeID *uint) error {
+	if assigneeID == nil || *assigneeID == userID {
+		return nil
+	}
+	var shared int64
+	if err := s.db.Model(&models.TeamMember{}).
+		Where("user_id = ? AND team_id IN (?)", *assigneeID,
+			s.db.Model(&models.TeamMember{}).Select("team_id").Where("user_id = ?", userID)).
+		Count(&shared).Error; err != nil {
+		return err
+	}
+	if shared == 0 {
+		return fmt.Errorf("%w: user %d is not in any of your teams", ErrInvalidAssignee, *assigneeID)
+	}
+	return nil
+}
+
+// taskAudience returns everyone who can see the task
+func taskAudience(tx *gorm.DB, task *models.Task) ([]uint, error) {
+	var members []uint
+	if err := tx.Model(&models.TeamMember{}).
+		Joins("JOIN team_tasks ON team_tasks.team_id = team_members.team_id AND team_tasks.deleted_at IS NULL").
+		Where("team_tasks.task_id = ?", task.ID).
+		Pluck("team_members.user_id", &members).Error; err != nil {
+		return nil, err
+	}
+	audience := append([]uint{task.UserID}, members...)
+	if task.AssigneeID != nil {
+		audience = append(audience, *task.AssigneeID)
+	}
+	return uniqueIDs(audience), nil
+}
+
+// Timeline returns the task's comment threads, attachments and changes,
+// oldest first
+func (s *TaskActivityService) Timeline(userID, taskID uint) ([]TaskTimelineEntry, error) {
+	if _, err := s.access(s.db, userID, taskID); err != nil {
+		return nil, err
+	}
+
+	threads, err := s.comments(taskID)
+	if err != nil {
+		return nil, err
+	}
+	attachments, err := s.attachments(taskID)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := s.history(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]TaskTimelineEntry, 0, len(threads)+len(attachments)+len(changes))
+	for i := range threads {
+		entries = append(entries, TaskTimelineEntry{Type: TaskTimelineComment, At: threads[i].CreatedAt, Comment: &threads[i]})
+	}
+	for i := range attachments {
+		entries = append(entries, TaskTimelineEntry{Type: TaskTimelineAttachment, At: attachments[i].CreatedAt, Attachment: &attachments[i]})
+	}
+	for i := range changes {
+		entries = append(entries, TaskTimelineEntry{Type: TaskTimelineChange, At: changes[i].CreatedAt, Change: &changes[i]})
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].At.Before(entries[j].At) })
+	return entries, nil
+}
+
+// Comments returns the task's comment threads, oldest first
+func (s *TaskActivityService) Comments(userID, taskID uint) ([]models.TaskComment, error) {
+	if _, err := s.access(s.db, userID, taskID); err != nil {
+		return nil, err
+	}
+	return s.comments(taskID)
+}
+
+func (s *TaskActivityService) comments(taskID uint) ([]models.TaskComment, error) {
+	var comments []models.TaskComment
+	if err := s.db.Where("task_id = ?", taskID).Preload("User").Order("created_at, id").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+
+	threads := make([]models.TaskComment, 0, len(comments))
+	index := map[uint]int{}
+	for _, comment := range comments {
+		if comment.ParentID == nil {
+			index[comment.ID] = len(threads)
+			threads = append(threads, comment)
+		}
+	}
+	for _, comment := range comments {
+		if comment.ParentID == nil {
+			continue
+		}
+		if i, ok := index[*comment.ParentID]; ok {
+			threads[i].Replies = append(threads[i].Replies, comment)
+		}
+	}
+	return threads, nil
+}
+
+// AddComment posts a comment or a reply. Users @mentioned by username who
+// can see the task are notified.
+func (s *TaskActivityService) AddComment(userID, taskID uint, input TaskCommentInput) (*models.TaskComment, error) {
+	body := strings.TrimSpace(input.Body)
+	if err := validateTaskComment(body); err != nil {
+		return nil, err
+	}
+
+	var comment models.TaskComment
+	var mentioned []uint
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		access, err := s.access(tx, userID, taskID)
+		if err != nil {
+			return err
+		}
+		if !access.write {
+			return ErrTaskForbidden
+		}
+
+		comment = models.TaskComment{TaskID: taskID, UserID: userID, Body: body}
+		if input.ParentID != nil {
+			var parent models.TaskComment
+			if err := tx.Where("id = ? AND task_id = ?", *input.ParentID, taskID).First(&parent).Error; err != nil {
+				return fmt.Errorf("%w: parent comment not found", ErrInvalidTaskComment)
+			}
+			// A reply to a reply joins the thread of its parent
+			root := parent.ID
+			if parent.ParentID != nil {
+				root = *parent.ParentID
+			}
+			comment.ParentID = &root
+		}
+
+		if comment.MentionIDs, err = resolveMentions(tx, access.task, body, userID); err != nil {
+			return err
+		}
+		mentioned = comment.MentionIDs
+		return tx.Create(&comment).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyMentions(userID, taskID, &comment, mentioned)
+	return s.loadComment(comment.ID)
+}
+
+// UpdateComment edits the user's own comment. Only users newly mentioned by
+// the edit are notified.
+func (s *TaskActivityService) UpdateComment(userID, taskID, commentID uint, input TaskCommentInput, now time.Time) (*models.TaskComment, error) {
+	body := strings.TrimSpace(input.Body)
+	if err := validateTaskComment(body); err != nil {
+		return nil, err
+	}
+
+	var comment models.TaskComment
+	var added []uint
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		access, err := s.access(tx, userID, taskID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("id = ? AND task_id = ?", commentID, taskID).First(&comment).Error; err != nil {
+			return err
+		}
+		if comment.UserID != userID {
+			return ErrTaskForbidden
+		}
+
+		mentions, err := resolveMentions(tx, access.task, body, userID)
+		if err != nil {
+			return err
+		}
+		previous := make(map[uint]bool, len(comment.MentionIDs))
+		for _, id := range comment.MentionIDs {
+			previous[id] = true
+		}
+		for _, id := range mentions {
+			if !previous[id] {
+				added = append(added, id)
+			}
+		}
+
+		comment.Body, comment.MentionIDs, comment.EditedAt = body, mentions, &now
+		return tx.Model(&comment).Select("body", "mention_ids", "edited_at").Updates(&comment).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyMentions(userID, taskID, &comment, added)
+	return s.loadComment(comment.ID)
+}
+
+// DeleteComment removes a comment and its replies. Authors can delete their
+// own comments and task owners any comment on the task.
+func (s *TaskActivityService) DeleteComment(userID, taskID, commentID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		access, err := s.access(tx, userID, taskID)
+		if err != nil {
+			return err
+		}
+		var comment models.TaskComment
+		if err := tx.Where("id = ? AND task_id = ?", commentID, taskID).First(&comment).Error; err != nil {
+			return err
+		}
+		if comment.UserID != userID && !access.owner {
+			return ErrTaskForbidden
+		}
+		return tx.Where("id = ? OR parent_id = ?", comment.ID, comment.ID).Delete(&models.TaskComment{}).Error
+	})
+}
+
+func (s *TaskActivityService) loadComment(commentID uint) (*models.TaskComment, error) {
+	var comment models.TaskComment
+	if err := s.db.Preload("User").First(&comment, commentID).Error; err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func validateTaskComment(body string) error {
+	if body == "" {
+		return fmt.Errorf("%w: body is required", ErrInvalidTaskComment)
+	}
+	if len(body) > maxTaskCommentLength {
+		return fmt.Errorf("%w: body is longer than %d characters", ErrInvalidTaskComment, maxTaskCommentLength)
+	}
+	return nil
+}
+
+// resolveMentions returns the IDs of users mentioned in body who can see
+// the task, leaving out the author
+func resolveMentions(tx *gorm.DB, task *models.Task, body string, authorID uint) ([]uint, error) {
+	var usernames []string
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		// A trailing dot ends the sentence, not the username
+		name := strings.ToLower(strings.TrimRight(match[1], ".-"))
+		if name != "" && !seen[name] {
+			seen[name] = true
+			usernames = append(usernames, name)
+		}
+	}
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	audience, err := taskAudience(tx, task)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint
+	if err := tx.Model(&models.User{}).
+		Where("LOWER(username) IN ? AND id IN ? AND id <> ?", usernames, audience, authorID).
+		Order("id").Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// notifyMentions adds a notification for each mentioned user. Failures are
+// not fatal; the comment is already saved.
+func (s *TaskActivityService) notifyMentions(authorID, taskID uint, comment *models.TaskComment, userIDs []uint) {
+	if len(userIDs) == 0 {
+		return
+	}
+	var author models.User
+	s.db.Select("id", "username").First(&author, authorID)
+	var task models.Task
+	s.db.Select("id", "title").First(&task, taskID)
+
+	body := truncateWords(comment.Body, 40)
+	for _, userID := range userIDs {
+		notification := models.Notification{
+			UserID: userID,
+			Type:   "mention",
+			Title:  fmt.Sprintf("%s mentioned you on %q", author.Username, task.Title),
+			Body:   body,
+			Link:   "/app/tasks",
+		}
+		if err := s.db.Create(&notification).Error; err == nil {
+			GetMessagesHub().NotifyUser(userID, "notification.created", notification)
+		}
+	}
+}
+
+// Attachments returns the files attached to the task
+func (s *TaskActivityService) Attachments(userID, taskID uint) ([]models.TaskAttachment, error) {
+	if _, err := s.access(s.db, userID, taskID); err != nil {
+		return nil, err
+	}
+	return s.attachments(taskID)
+}
+
+func (s *TaskActivityService) attachments(taskID uint) ([]models.TaskAttachment, error) {
+	var attachments []models.TaskAttachment
+	err := s.db.Where("task_id = ?", taskID).Preload("File").Preload("User").Order("created_at, id").Find(&attachments).Error
+	return attachments, err
+}
+
+// Attach links one of the user's files, or a file shared with a team the
+// task is shared with, to the task. Attaching a file twice is a no-op.
+func (s *TaskActivityService) Attach(userID, taskID, fileID uint) (*models.TaskAttachment, error) {
+	var attachment models.TaskAttachment
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		access, err := s.access(tx, userID, taskID)
+		if err != nil {
+			return err
+		}
+		if !access.write {
+			return ErrTaskForbidden
+		}
+
+		var count int64
+		if err := tx.Model(&models.File{}).Where("id = ?", fileID).
+			Where("user_id = ? OR id IN (SELECT team_files.file_id FROM team_files JOIN team_tasks ON team_tasks.team_id = team_files.team_id "+
+				"WHERE team_tasks.task_id = ? AND team_tasks.deleted_at IS NULL AND team_files.deleted_at IS NULL)", userID, taskID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("%w: file not found", ErrTaskForbidden)
+		}
+
+		attachment = models.TaskAttachment{TaskID: taskID, FileID: fileID, UserID: userID}
+		return tx.Where("task_id = ? AND file_id = ?", taskID, fileID).FirstOrCreate(&attachment).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Preload("File").Preload("User").First(&attachment, attachment.ID).Error; err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// Detach removes an attachment; the file itself is kept. The user who
+// attached it and the task owner can detach.
+func (s *TaskActivityService) Detach(userID, taskID, attachmentID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		access, err := s.access(tx, userID, taskID)
+		if err != nil {
+			return err
+		}
+		var attachment models.TaskAttachment
+		if err := tx.Where("id = ? AND task_id = ?", attachmentID, taskID).First(&attachment).Error; err != nil {
+			return err
+		}
+		if attachment.UserID != userID && !access.owner {
+			return ErrTaskForbidden
+		}
+		return tx.Delete(&attachment).Error
+	})
+}
+
+// History returns the recorded field changes of the task, oldest first
+func (s *TaskActivityService) History(userID, taskID uint) ([]models.TaskChange, error) {
+	if _, err := s.access(s.db, userID, taskID); err != nil {
+		return nil, err
+	}
+	return s.history(taskID)
+}
+
+func (s *TaskActivityService) history(taskID uint) ([]models.TaskChange, error) {
+	var changes []models.TaskChange
+	err := s.db.Where("task_id = ?", taskID).Preload("Actor").Order("created_at, id").Find(&changes).Error
+	return changes, err
+}
+
+// taskHistoryRow holds the task fields whose changes are recorded
+type taskHistoryRow struct {
+	ID         uint
+	Status     string
+	Priority   string
+	DueDate    *time.Time
+	AssigneeID *uint
+	Progress   int
+
+	EstimatedMinutes int
+}
+
+func (r taskHistoryRow) values() map[string]string {
+	values := map[string]string{
+		"status":      r.Status,
+		"priority":    r.Priority,
+		"due_date":    "",
+		"assignee_id": "",
+		"progress":    strconv.Itoa(r.Progress),
+
+		"estimated_minutes": strconv.Itoa(r.EstimatedMinutes),
+	}
+	if r.DueDate != nil {
+		values["due_date"] = r.DueDate.UTC().Format(time.RFC3339)
+	}
+	if r.AssigneeID != nil {
+		values["assignee_id"] = strconv.FormatUint(uint64(*r.AssigneeID), 10)
+	}
+	return values
+}
+
+// taskHistoryFields is the order changes of one update are recorded in
+var taskHistoryFields = []string{"status", "priority", "due_date", "assignee_id", "progress", "estimated_minutes"}
+
+func loadTaskHistoryRows(tx *gorm.DB, taskIDs []uint) (map[uint]taskHistoryRow, error) {
+	var rows []taskHistoryRow
+	if err := tx.Model(&models.Task{}).Where("id IN ?", taskIDs).
+		Select("id", "status", "priority", "due_date", "assignee_id", "progress", "estimated_minutes").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]taskHistoryRow, len(rows))
+	for _, row := range rows {
+		byID[row.ID] = row
+	}
+	return byID, nil
+}
+
+// TrackTaskChanges runs update and records every change it makes to the
+// status, priority, due date, assignee, progress or estimate of the given
+// tasks.
+// actorID 0 records the change as made by the system, e.g. a sync.
+func TrackTaskChanges(tx *gorm.DB, actorID uint, taskIDs []uint, update func() error) error {
+	if len(taskIDs) == 0 {
+		return update()
+	}
+	before, err := loadTaskHistoryRows(tx, taskIDs)
+	if err != nil {
+		return err
+	}
+	if err := update(); err != nil {
+		return err
+	}
+	after, err := loadTaskHistoryRows(tx, taskIDs)
+	if err != nil {
+		return err
+	}
+
+	var actor *uint
+	if actorID != 0 {
+		actor = &actorID
+	}
+	var changes []models.TaskChange
+	for _, id := range uniqueIDs(taskIDs) {
+		old, ok := before[id]
+		current, still := after[id]
+		if !ok || !still {
+			continue
+		}
+		oldValues, newValues := old.values(), current.values()
+		for _, field := range taskHistoryFields {
+			if oldValues[field] != newValues[field] {
+				changes = append(changes, models.TaskChange{
+					TaskID:   id,
+					ActorID:  actor,
+					Field:    field,
+					OldValue: oldValues[field],
+					NewValue: newValues[field],
+				})
+			}
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return tx.Create(&changes).Error
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+func TestTaskActivityService(t *testing.T) {
+	db := newTestDB(t, &models.TaskPreference{}, &models.TaskComment{}, &models.TaskAttachment{}, &models.File{},
+		&models.Notification{}, &models.AuditLog{}, &models.Team{}, &models.TeamMember{}, &models.TeamTask{},
+		&models.TeamFile{})
+
+	owner := models.User{Email: "lead@example.com", Username: "lead", Password: "x", GitHubID: 1}
+	dev := models.User{Email: "dev@example.com", Username: "dev", Password: "x", GitHubID: 2}
+	guest := models.User{Email: "guest@example.com", Username: "guest", Password: "x", GitHubID: 3}
+	outsider := models.User{Email: "out@example.com", Username: "outsider", Password: "x", GitHubID: 4}
+	for _, user := range []*models.User{&owner, &dev, &guest, &outsider} {
+		db.Create(user)
+	}
+	team := models.Team{Name: "Platform", OwnerID: owner.ID}
+	db.Create(&team)
+	db.Create(&[]models.TeamMember{
+		{TeamID: team.ID, UserID: owner.ID, Role: "owner"},
+		{TeamID: team.ID, UserID: dev.ID, Role: "member"},
+		{TeamID: team.ID, UserID: guest.ID, Role: "viewer"},
+	})
+	task := models.Task{UserID: owner.ID, Title: "Upgrade database"}
+	db.Create(&task)
+	db.Create(&models.TeamTask{TeamID: team.ID, UserID: owner.ID, TaskID: task.ID})
+
+	service := NewTaskActivityService(db)
+
+	// Tasks can be assigned to yourself and to teammates only
+	for _, assignee := range []uint{owner.ID, dev.ID, guest.ID} {
+		if err := service.ValidateAssignee(owner.ID, &assignee); err != nil {
+			t.Fatalf("expected user %d to be assignable, got %v", assignee, err)
+		}
+	}
+	if err := service.ValidateAssignee(owner.ID, &outsider.ID); !errors.Is(err, ErrInvalidAssignee) {
+		t.Fatalf("expected an outsider to be refused, got %v", err)
+	}
+
+	// Comments, threads and mentions
+	root, err := service.AddComment(owner.ID, task.ID, TaskCommentInput{Body: "Plan is in the doc, @dev and @outsider. Mail me at lead@example.com"})
+	if err != nil {
+		t.Fatalf("failed to comment: %v", err)
+	}
+	if len(root.MentionIDs) != 1 || root.MentionIDs[0] != dev.ID {
+		t.Fatalf("expected only the team member to be mentioned, got %v", root.MentionIDs)
+	}
+	reply, err := service.AddComment(dev.ID, task.ID, TaskCommentInput{Body: "On it, @lead.", ParentID: &root.ID})
+	if err != nil {
+		t.Fatalf("failed to reply: %v", err)
+	}
+	nested, err := service.AddComment(owner.ID, task.ID, TaskCommentInput{Body: "Thanks", ParentID: &reply.ID})
+	if err != nil || nested.ParentID == nil || *nested.ParentID != root.ID {
+		t.Fatalf("expected a reply to a reply to join the thread: %+v (err %v)", nested, err)
+	}
+	if _, err := service.AddComment(guest.ID, task.ID, TaskCommentInput{Body: "Can I help?"}); !errors.Is(err, ErrTaskForbidden) {
+		t.Fatalf("expected viewers not to comment, got %v", err)
+	}
+	if _, err := service.Comments(outsider.ID, task.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected the task to be hidden from outsiders, got %v", err)
+	}
+	var mentions int64
+	db.Model(&models.Notification{}).Where("type = ?", "mention").Count(&mentions)
+	if mentions != 2 {
+		t.Fatalf("expected notifications for dev and lead, got %d", mentions)
+	}
+
+	if _, err := service.UpdateComment(dev.ID, task.ID, root.ID, TaskCommentInput{Body: "hijack"}, time.Now()); !errors.Is(err, ErrTaskForbidden) {
+		t.Fatalf("expected only the author to edit, got %v", err)
+	}
+	edited, err := service.UpdateComment(dev.ID, task.ID, reply.ID, TaskCommentInput{Body: "On it, @lead. cc @guest"}, time.Now())
+	if err != nil || edited.EditedAt == nil || len(edited.MentionIDs) != 2 {
+		t.Fatalf("unexpected edit: %+v (err %v)", edited, err)
+	}
+	db.Model(&models.Notification{}).Where("type = ?", "mention").Count(&mentions)
+	if mentions != 3 {
+		t.Fatalf("expected only the newly mentioned user to be notified, got %d notifications", mentions)
+	}
+
+	threads, err := service.Comments(guest.ID, task.ID)
+	if err != nil || len(threads) != 1 || len(threads[0].Replies) != 2 {
+		t.Fatalf("unexpected threads: %+v (err %v)", threads, err)
+	}
+
+	// Attachments reference files the user owns or the team shares
+	own := models.File{UserID: dev.ID, OriginalName: "plan.pdf", FileName: "a.pdf", FilePath: "/a", FileSize: 1, MimeType: "application/pdf", FileType: models.FileTypeDocument}
+	private := models.File{UserID: outsider.ID, OriginalName: "secret.pdf", FileName: "b.pdf", FilePath: "/b", FileSize: 1, MimeType: "application/pdf", FileType: models.FileTypeDocument}
+	db.Create(&own)
+	db.Create(&private)
+	attachment, err := service.Attach(dev.ID, task.ID, own.ID)
+	if err != nil || attachment.File.OriginalName != "plan.pdf" {
+		t.Fatalf("failed to attach: %+v (err %v)", attachment, err)
+	}
+	if again, err := service.Attach(dev.ID, task.ID, own.ID); err != nil || again.ID != attachment.ID {
+		t.Fatalf("expected attaching twice to be a no-op: %+v (err %v)", again, err)
+	}
+	if _, err := service.Attach(dev.ID, task.ID, private.ID); !errors.Is(err, ErrTaskForbidden) {
+		t.Fatalf("expected someone else's file to be rejected, got %v", err)
+	}
+
+	// Changes from any write path land in the history
+	due := time.Date(2026, 7, 1, 17, 0, 0, 0, time.UTC)
+	if err := TrackTaskChanges(db, owner.ID, []uint{task.ID}, func() error {
+		return db.Model(&task).Updates(models.Task{Priority: models.TaskPriorityHigh, DueDate: &due, AssigneeID: &dev.ID, Title: "Upgrade db"}).Error
+	}); err != nil {
+		t.Fatalf("failed to update task: %v", err)
+	}
+	status := string(models.TaskStatusCompleted)
+	if _, err := NewBulkService(db).Tasks(owner.ID, BulkRequest{Action: BulkActionSetStatus, IDs: []uint{task.ID}, Status: status}, nil); err != nil {
+		t.Fatalf("failed to complete in bulk: %v", err)
+	}
+
+	history, err := service.History(dev.ID, task.ID)
+	if err != nil {
+		t.Fatalf("failed to fetch history: %v", err)
+	}
+	got := map[string]models.TaskChange{}
+	for _, change := range history {
+		got[change.Field] = change
+	}
+	if len(history) != 5 || got["priority"].OldValue != "medium" || got["priority"].NewValue != "high" ||
+		got["due_date"].NewValue != "2026-07-01T17:00:00Z" || got["status"].NewValue != "completed" ||
+		got["progress"].NewValue != "100" || got["assignee_id"].Actor == nil || got["assignee_id"].Actor.ID != owner.ID {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+
+	// The whole team sees one timeline
+	timeline, err := service.Timeline(guest.ID, task.ID)
+	if err != nil {
+		t.Fatalf("failed to fetch timeline: %v", err)
+	}
+	kinds := map[string]int{}
+	for _, entry := range timeline {
+		kinds[entry.Type]++
+	}
+	if kinds[TaskTimelineComment] != 1 || kinds[TaskTimelineAttachment] != 1 || kinds[TaskTimelineChange] != 5 {
+		t.Fatalf("unexpected timeline: %v", kinds)
+	}
+
+	if err := service.DeleteComment(dev.ID, task.ID, root.ID); !errors.Is(err, ErrTaskForbidden) {
+		t.Fatalf("expected members not to delete others' comments, got %v", err)
+	}
+	if err := service.DeleteComment(owner.ID, task.ID, root.ID); err != nil {
+		t.Fatalf("failed to delete comment: %v", err)
+	}
+	if threads, _ := service.Comments(owner.ID, task.ID); len(threads) != 0 {
+		t.Fatalf("expected the thread to be gone, got %+v", threads)
+	}
+}
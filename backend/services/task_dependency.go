@@ -0,0 +1,675 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrSelfDependency    = errors.New("a task cannot depend on itself")
+	ErrDependencyCycle   = errors.New("dependency would create a cycle")
+	ErrInvalidDependency = errors.New("invalid dependency")
+	ErrTaskBlocked       = errors.New("task has open prerequisites")
+)
+
+// closedTaskStatuses are the statuses that no longer block dependent tasks.
+// A cancelled prerequisite will never be done, so it does not hold anything up.
+var closedTaskStatuses = []models.TaskStatus{models.TaskStatusCompleted, models.TaskStatusCancelled}
+
+// TaskDependencyService manages the prerequisite graph between tasks. An
+// edge task -> dependency means the task cannot start until the dependency
+// is done.
+type TaskDependencyService struct {
+	db *gorm.DB
+}
+
+// NewTaskDependencyService creates a new task dependency service
+func NewTaskDependencyService(db *gorm.DB) *TaskDependencyService {
+	return &TaskDependencyService{db: db}
+}
+
+// TaskPreferenceInput is a partial update of a user's task settings
+type TaskPreferenceInput struct {
+	AllowCompletingBlocked *bool `json:"allow_completing_blocked"`
+}
+
+// Preferences returns the user's task settings, or the defaults
+func (s *TaskDependencyService) Preferences(userID uint) (*models.TaskPreference, error) {
+	var pref models.TaskPreference
+	err := s.db.Where("user_id = ?", userID).First(&pref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.TaskPreference{UserID: userID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+// UpdatePreferences saves the user's task settings
+func (s *TaskDependencyService) UpdatePreferences(userID uint, input TaskPreferenceInput) (*models.TaskPreference, error) {
+	pref, err := s.Preferences(userID)
+	if err != nil {
+		return nil, err
+	}
+	if input.AllowCompletingBlocked != nil {
+		pref.AllowCompletingBlocked = *input.AllowCompletingBlocked
+	}
+	if err := s.db.Save(pref).Error; err != nil {
+		return nil, err
+	}
+	return pref, nil
+}
+
+// TaskDependencies describes one task's place in the graph
+type TaskDependencies struct {
+	Dependencies []models.Task `json:"dependencies"`
+	Dependents   []models.Task `json:"dependents"`
+	Blockers     []models.Task `json:"blockers"`
+}
+
+// Dependencies returns the task's prerequisites, the tasks waiting on it,
+// and the prerequisites that are still open
+func (s *TaskDependencyService) Dependencies(userID, taskID uint) (*TaskDependencies, error) {
+	if err := s.db.Where("id = ? AND user_id = ?", taskID, userID).First(&models.Task{}).Error; err != nil {
+		return nil, err
+	}
+
+	result := &TaskDependencies{Dependencies: []models.Task{}, Dependents: []models.Task{}, Blockers: []models.Task{}}
+	if err := s.db.Where("user_id = ? AND id IN (SELECT dependency_id FROM task_dependencies WHERE task_id = ?)", userID, taskID).
+		Order("id").Find(&result.Dependencies).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("user_id = ? AND id IN (SELECT task_id FROM task_dependencies WHERE dependency_id = ?)", userID, taskID).
+		Order("id").Find(&result.Dependents).Error; err != nil {
+		return nil, err
+	}
+	for _, dependency := range result.Dependencies {
+		if isOpenTask(dependency.Status) {
+			result.Blockers = append(result.Blockers, dependency)
+		}
+	}
+	if err := s.MarkBlocked(result.Dependencies); err != nil {
+		return nil, err
+	}
+	if err := s.MarkBlocked(result.Dependents); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// SetDependencies replaces the task's prerequisites. Prerequisites must
+// belong to the user, and the change is rejected if it would close a cycle.
+func (s *TaskDependencyService) SetDependencies(userID, taskID uint, dependencyIDs []uint) (*models.Task, error) {
+	dependencyIDs = uniqueIDs(dependencyIDs)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ?", taskID, userID).First(&models.Task{}).Error; err != nil {
+			return err
+		}
+		return setTaskDependencies(tx, userID, taskID, dependencyIDs)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.loadTask(userID, taskID)
+}
+
+// ValidateDependencies checks prerequisites for a task that does not exist
+// yet. Nothing depends on a new task, so it cannot close a cycle.
+func (s *TaskDependencyService) ValidateDependencies(userID uint, dependencyIDs []uint) error {
+	dependencyIDs = uniqueIDs(dependencyIDs)
+	if len(dependencyIDs) == 0 {
+		return nil
+	}
+	var owned int64
+	if err := s.db.Model(&models.Task{}).Where("id IN ? AND user_id = ?", dependencyIDs, userID).Count(&owned).Error; err != nil {
+		return err
+	}
+	if int(owned) != len(dependencyIDs) {
+		return fmt.Errorf("%w: prerequisite not found", ErrInvalidDependency)
+	}
+	return nil
+}
+
+// AddDependency makes dependencyID a prerequisite of taskID
+func (s *TaskDependencyService) AddDependency(userID, taskID, dependencyID uint) (*models.Task, error) {
+	current, err := s.dependencyIDs(userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	return s.SetDependencies(userID, taskID, append(current, dependencyID))
+}
+
+// RemoveDependency drops dependencyID from the task's prerequisites
+func (s *TaskDependencyService) RemoveDependency(userID, taskID, dependencyID uint) (*models.Task, error) {
+	current, err := s.dependencyIDs(userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	remaining := make([]uint, 0, len(current))
+	for _, id := range current {
+		if id != dependencyID {
+			remaining = append(remaining, id)
+		}
+	}
+	return s.SetDependencies(userID, taskID, remaining)
+}
+
+// CheckCompletion returns ErrTaskBlocked when the task still has open
+// prerequisites, unless the user allows completing blocked tasks
+func (s *TaskDependencyService) CheckCompletion(userID, taskID uint) error {
+	pref, err := s.Preferences(userID)
+	if err != nil || pref.AllowCompletingBlocked {
+		return err
+	}
+
+	var blockers []models.Task
+	if err := s.db.Where("user_id = ? AND status NOT IN ? AND id IN (SELECT dependency_id FROM task_dependencies WHERE task_id = ?)",
+		userID, closedTaskStatuses, taskID).Order("id").Find(&blockers).Error; err != nil {
+		return err
+	}
+	if len(blockers) == 0 {
+		return nil
+	}
+	titles := make([]string, len(blockers))
+	for i, blocker := range blockers {
+		titles[i] = fmt.Sprintf("%q", blocker.Title)
+	}
+	return fmt.Errorf("%w: waiting on %s", ErrTaskBlocked, strings.Join(titles, ", "))
+}
+
+// MarkBlocked sets the derived Blocked flag on open tasks that have open
+// prerequisites
+func (s *TaskDependencyService) MarkBlocked(tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	blocked, err := blockedTaskIDs(s.db, ids)
+	if err != nil {
+		return err
+	}
+	for i := range tasks {
+		tasks[i].Blocked = blocked[tasks[i].ID] && isOpenTask(tasks[i].Status)
+	}
+	return nil
+}
+
+// TaskGraphScope selects the tasks in a graph. Tag matches the tag and the
+// tags below it; ParentID takes a task and all of its subtasks, which is how
+// tasks are grouped into projects.
+type TaskGraphScope struct {
+	Tag              string
+	ParentID         *uint
+	IncludeCompleted bool
+}
+
+// TaskGraphNode is a task in the graph with its schedule. Times are in
+// minutes of estimated work from now; tasks without an estimate take none.
+type TaskGraphNode struct {
+	ID               uint                `json:"id"`
+	Title            string              `json:"title"`
+	Status           models.TaskStatus   `json:"status"`
+	Priority         models.TaskPriority `json:"priority"`
+	DueDate          *time.Time          `json:"due_date"`
+	ParentTaskID     *uint               `json:"parent_task_id,omitempty"`
+	EstimatedMinutes int                 `json:"estimated_minutes"`
+	Blocked          bool                `json:"blocked"`
+
+	EarliestStart   int       `json:"earliest_start"`
+	EarliestFinish  int       `json:"earliest_finish"`
+	SlackMinutes    int       `json:"slack_minutes"`
+	ProjectedFinish time.Time `json:"projected_finish"`
+	// AtRisk is set when the projected finish is after the due date
+	AtRisk   bool `json:"at_risk"`
+	Critical bool `json:"critical"`
+}
+
+// TaskGraphEdge points from a prerequisite to the task that depends on it
+type TaskGraphEdge struct {
+	From uint `json:"from"`
+	To   uint `json:"to"`
+}
+
+// TaskGraph is a renderable dependency graph. Order lists the tasks so that
+// prerequisites come first; CriticalPath is the longest chain of estimated
+// work, which decides when the whole set can be done.
+type TaskGraph struct {
+	Nodes        []TaskGraphNode `json:"nodes"`
+	Edges        []TaskGraphEdge `json:"edges"`
+	Order        []uint          `json:"order"`
+	CriticalPath []uint          `json:"critical_path"`
+	TotalMinutes int             `json:"total_minutes"`
+}
+
+// Graph builds the dependency graph for the tasks in scope. Only edges
+// between tasks in scope are included, but Blocked reflects all of a task's
+// prerequisites.
+func (s *TaskDependencyService) Graph(userID uint, scope TaskGraphScope, now time.Time) (*TaskGraph, error) {
+	tasks, err := s.scopedTasks(userID, scope)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.MarkBlocked(tasks); err != nil {
+		return nil, err
+	}
+
+	graph := &TaskGraph{Nodes: []TaskGraphNode{}, Edges: []TaskGraphEdge{}, Order: []uint{}, CriticalPath: []uint{}}
+	if len(tasks) == 0 {
+		return graph, nil
+	}
+
+	byID := make(map[uint]*models.Task, len(tasks))
+	ids := make([]uint, len(tasks))
+	for i := range tasks {
+		byID[tasks[i].ID] = &tasks[i]
+		ids[i] = tasks[i].ID
+	}
+
+	var rows []struct {
+		TaskID       uint
+		DependencyID uint
+	}
+	if err := s.db.Table("task_dependencies").Where("task_id IN ? AND dependency_id IN ?", ids, ids).
+		Order("dependency_id, task_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	predecessors := make(map[uint][]uint)
+	successors := make(map[uint][]uint)
+	for _, row := range rows {
+		predecessors[row.TaskID] = append(predecessors[row.TaskID], row.DependencyID)
+		successors[row.DependencyID] = append(successors[row.DependencyID], row.TaskID)
+		graph.Edges = append(graph.Edges, TaskGraphEdge{From: row.DependencyID, To: row.TaskID})
+	}
+
+	order, err := topologicalOrder(tasks, byID, predecessors, successors)
+	if err != nil {
+		return nil, err
+	}
+	graph.Order = order
+
+	// Forward pass: a task starts when its last prerequisite finishes.
+	// Done tasks have no work left.
+	duration := func(task *models.Task) int {
+		if !isOpenTask(task.Status) || task.EstimatedMinutes < 0 {
+			return 0
+		}
+		return task.EstimatedMinutes
+	}
+	start := make(map[uint]int, len(order))
+	finish := make(map[uint]int, len(order))
+	for _, id := range order {
+		for _, pred := range predecessors[id] {
+			if finish[pred] > start[id] {
+				start[id] = finish[pred]
+			}
+		}
+		finish[id] = start[id] + duration(byID[id])
+		if finish[id] > graph.TotalMinutes {
+			graph.TotalMinutes = finish[id]
+		}
+	}
+
+	// Backward pass: the latest a task can finish without delaying the end
+	latest := make(map[uint]int, len(order))
+	for i := len(order) - 1; i >= 0; i-- {
+		id := order[i]
+		latest[id] = graph.TotalMinutes
+		for _, succ := range successors[id] {
+			if ls := latest[succ] - duration(byID[succ]); ls < latest[id] {
+				latest[id] = ls
+			}
+		}
+	}
+
+	// The critical path ends at the task that finishes last and follows
+	// the prerequisite that finishes last; ties go to the earlier due date
+	if graph.TotalMinutes > 0 {
+		var end uint
+		for _, id := range order {
+			if finish[id] == graph.TotalMinutes && (end == 0 || dueBefore(byID[id], byID[end])) {
+				end = id
+			}
+		}
+		for id := end; id != 0; {
+			graph.CriticalPath = append([]uint{id}, graph.CriticalPath...)
+			var next uint
+			for _, pred := range predecessors[id] {
+				if finish[pred] == start[id] && duration(byID[pred]) > 0 && (next == 0 || dueBefore(byID[pred], byID[next])) {
+					next = pred
+				}
+			}
+			id = next
+		}
+	}
+	critical := make(map[uint]bool, len(graph.CriticalPath))
+	for _, id := range graph.CriticalPath {
+		critical[id] = true
+	}
+
+	for _, id := range order {
+		task := byID[id]
+		node := TaskGraphNode{
+			ID:               task.ID,
+			Title:            task.Title,
+			Status:           task.Status,
+			Priority:         task.Priority,
+			DueDate:          task.DueDate,
+			ParentTaskID:     task.ParentTaskID,
+			EstimatedMinutes: task.EstimatedMinutes,
+			Blocked:          task.Blocked,
+			EarliestStart:    start[id],
+			EarliestFinish:   finish[id],
+			SlackMinutes:     latest[id] - finish[id],
+			ProjectedFinish:  now.Add(time.Duration(finish[id]) * time.Minute),
+			Critical:         critical[id],
+		}
+		node.AtRisk = isOpenTask(task.Status) && task.DueDate != nil && node.ProjectedFinish.After(*task.DueDate)
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	return graph, nil
+}
+
+func (s *TaskDependencyService) scopedTasks(userID uint, scope TaskGraphScope) ([]models.Task, error) {
+	query := s.db.Where("user_id = ?", userID)
+	if !scope.IncludeCompleted {
+		query = query.Where("status NOT IN ?", closedTaskStatuses)
+	}
+
+	if scope.Tag != "" {
+		tagIDs, err := NewTagService(s.db).FilterIDs(userID, []string{scope.Tag})
+		if err != nil {
+			return nil, err
+		}
+		if len(tagIDs) == 0 {
+			return nil, nil
+		}
+		query = query.Where("id IN (SELECT task_id FROM task_tags WHERE tag_id IN ?)", tagIDs)
+	}
+
+	if scope.ParentID != nil {
+		var parent models.Task
+		if err := s.db.Where("id = ? AND user_id = ?", *scope.ParentID, userID).First(&parent).Error; err != nil {
+			return nil, err
+		}
+		subtree := []uint{parent.ID}
+		for frontier := subtree; len(frontier) > 0; {
+			var children []uint
+			if err := s.db.Model(&models.Task{}).Where("user_id = ? AND parent_task_id IN ?", userID, frontier).
+				Pluck("id", &children).Error; err != nil {
+				return nil, err
+			}
+			frontier = children
+			subtree = append(subtree, children...)
+		}
+		query = query.Where("id IN ?", subtree)
+	}
+
+	var tasks []models.Task
+	if err := query.Order("id").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	return tasks, nil
+}
+
+func (s *TaskDependencyService) dependencyIDs(userID, taskID uint) ([]uint, error) {
+	if err := s.db.Where("id = ? AND user_id = ?", taskID, userID).First(&models.Task{}).Error; err != nil {
+		return nil, err
+	}
+	var ids []uint
+	err := s.db.Table("task_dependencies").Where("task_id = ?", taskID).Order("dependency_id").Pluck("dependency_id", &ids).Error
+	return ids, err
+}
+
+func (s *TaskDependencyService) loadTask(userID, taskID uint) (*models.Task, error) {
+	var task models.Task
+	if err := s.db.Where("id = ? AND user_id = ?", taskID, userID).Preload("Tags").Preload("Dependencies").First(&task).Error; err != nil {
+		return nil, err
+	}
+	tasks := []models.Task{task}
+	if err := s.MarkBlocked(tasks); err != nil {
+		return nil, err
+	}
+	if err := s.MarkBlocked(tasks[0].Dependencies); err != nil {
+		return nil, err
+	}
+	return &tasks[0], nil
+}
+
+// setTaskDependencies validates and stores a task's prerequisites. It walks
+// the user's existing edges to make sure none of the new prerequisites
+// already depends on the task.
+func setTaskDependencies(tx *gorm.DB, userID, taskID uint, dependencyIDs []uint) error {
+	for _, id := range dependencyIDs {
+		if id == taskID {
+			return ErrSelfDependency
+		}
+	}
+	if len(dependencyIDs) > 0 {
+		if err := NewTaskDependencyService(tx).ValidateDependencies(userID, dependencyIDs); err != nil {
+			return err
+		}
+
+		edges, err := dependencyEdges(tx, userID)
+		if err != nil {
+			return err
+		}
+		edges[taskID] = dependencyIDs
+		if path := dependencyPath(edges, dependencyIDs, taskID); path != nil {
+			titles, err := taskTitles(tx, append([]uint{taskID}, path...))
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(titles, " -> "))
+		}
+	}
+
+	if err := tx.Exec("DELETE FROM task_dependencies WHERE task_id = ?", taskID).Error; err != nil {
+		return err
+	}
+	for _, id := range dependencyIDs {
+		if err := tx.Exec("INSERT INTO task_dependencies (task_id, dependency_id) VALUES (?, ?)", taskID, id).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Model(&models.Task{}).Where("id = ?", taskID).UpdateColumn("updated_at", time.Now()).Error
+}
+
+// dependencyEdges loads the user's graph as task -> prerequisites
+func dependencyEdges(tx *gorm.DB, userID uint) (map[uint][]uint, error) {
+	var rows []struct {
+		TaskID       uint
+		DependencyID uint
+	}
+	if err := tx.Table("task_dependencies").
+		Joins("JOIN tasks ON tasks.id = task_dependencies.task_id").
+		Where("tasks.user_id = ? AND tasks.deleted_at IS NULL", userID).
+		Select("task_dependencies.task_id, task_dependencies.dependency_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	edges := make(map[uint][]uint)
+	for _, row := range rows {
+		edges[row.TaskID] = append(edges[row.TaskID], row.DependencyID)
+	}
+	return edges, nil
+}
+
+// dependencyPath returns a chain of prerequisites leading from one of the
+// start tasks to target, or nil when there is none
+func dependencyPath(edges map[uint][]uint, start []uint, target uint) []uint {
+	visited := make(map[uint]bool)
+	var walk func(id uint) []uint
+	walk = func(id uint) []uint {
+		if id == target {
+			return []uint{id}
+		}
+		if visited[id] {
+			return nil
+		}
+		visited[id] = true
+		for _, next := range edges[id] {
+			if path := walk(next); path != nil {
+				return append([]uint{id}, path...)
+			}
+		}
+		return nil
+	}
+	for _, id := range start {
+		if path := walk(id); path != nil {
+			return path
+		}
+	}
+	return nil
+}
+
+// topologicalOrder sorts tasks so prerequisites come first. Among tasks that
+// are ready at the same time, earlier due dates and higher priorities lead.
+func topologicalOrder(tasks []models.Task, byID map[uint]*models.Task, predecessors, successors map[uint][]uint) ([]uint, error) {
+	waiting := make(map[uint]int, len(tasks))
+	var ready []uint
+	for _, task := range tasks {
+		waiting[task.ID] = len(predecessors[task.ID])
+		if waiting[task.ID] == 0 {
+			ready = append(ready, task.ID)
+		}
+	}
+
+	order := make([]uint, 0, len(tasks))
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return dueBefore(byID[ready[i]], byID[ready[j]]) })
+		id := ready[0]
+		ready = ready[1:]
+		order = append(order, id)
+		for _, succ := range successors[id] {
+			waiting[succ]--
+			if waiting[succ] == 0 {
+				ready = append(ready, succ)
+			}
+		}
+	}
+
+	// Graphs saved before dependencies were validated may contain cycles
+	if len(order) != len(tasks) {
+		return nil, fmt.Errorf("%w: the existing dependencies contain a cycle", ErrDependencyCycle)
+	}
+	return order, nil
+}
+
+// dueBefore orders tasks by due date (undated last), then priority, then ID
+func dueBefore(a, b *models.Task) bool {
+	switch {
+	case a.DueDate != nil && b.DueDate == nil:
+		return true
+	case a.DueDate == nil && b.DueDate != nil:
+		return false
+	case a.DueDate != nil && !a.DueDate.Equal(*b.DueDate):
+		return a.DueDate.Before(*b.DueDate)
+	}
+	if pa, pb := taskPriorityRank(a.Priority), taskPriorityRank(b.Priority); pa != pb {
+		return pa > pb
+	}
+	return a.ID < b.ID
+}
+
+func taskPriorityRank(priority models.TaskPriority) int {
+	switch priority {
+	case models.TaskPriorityUrgent:
+		return 3
+	case models.TaskPriorityHigh:
+		return 2
+	case models.TaskPriorityLow:
+		return 0
+	default:
+		return 1
+	}
+}
+
+func isOpenTask(status models.TaskStatus) bool {
+	return status != models.TaskStatusCompleted && status != models.TaskStatusCancelled
+}
+
+// blockedTaskIDs returns which of the tasks have an open prerequisite
+func blockedTaskIDs(tx *gorm.DB, ids []uint) (map[uint]bool, error) {
+	var blocked []uint
+	if err := tx.Table("task_dependencies").
+		Joins("JOIN tasks ON tasks.id = task_dependencies.dependency_id").
+		Where("task_dependencies.task_id IN ? AND tasks.status NOT IN ? AND tasks.deleted_at IS NULL", ids, closedTaskStatuses).
+		Distinct("task_dependencies.task_id").Pluck("task_dependencies.task_id", &blocked).Error; err != nil {
+		return nil, err
+	}
+	result := make(map[uint]bool, len(blocked))
+	for _, id := range blocked {
+		result[id] = true
+	}
+	return result, nil
+}
+
+// completableTasks drops tasks whose prerequisites stay open, recording
+// them as failures. Prerequisites completed in the same batch count as done.
+func completableTasks(tx *gorm.DB, userID uint, ids []uint, failures map[uint]string) ([]uint, error) {
+	pref, err := NewTaskDependencyService(tx).Preferences(userID)
+	if err != nil || pref.AllowCompletingBlocked || len(ids) == 0 {
+		return ids, err
+	}
+
+	var rows []struct {
+		TaskID       uint
+		DependencyID uint
+	}
+	if err := tx.Table("task_dependencies").
+		Joins("JOIN tasks ON tasks.id = task_dependencies.dependency_id").
+		Where("task_dependencies.task_id IN ? AND tasks.status NOT IN ? AND tasks.deleted_at IS NULL", ids, closedTaskStatuses).
+		Select("task_dependencies.task_id, task_dependencies.dependency_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	completing := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		completing[id] = true
+	}
+	// Dropping one task can block another that waited on it, so repeat
+	// until nothing changes
+	for changed := true; changed; {
+		changed = false
+		for _, row := range rows {
+			if completing[row.TaskID] && !completing[row.DependencyID] {
+				completing[row.TaskID] = false
+				failures[row.TaskID] = ErrTaskBlocked.Error()
+				changed = true
+			}
+		}
+	}
+
+	result := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if completing[id] {
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+func taskTitles(tx *gorm.DB, ids []uint) ([]string, error) {
+	var tasks []models.Task
+	if err := tx.Select("id, title").Where("id IN ?", ids).Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+	titles := make(map[uint]string, len(tasks))
+	for _, task := range tasks {
+		titles[task.ID] = task.Title
+	}
+	result := make([]string, len(ids))
+	for i, id := range ids {
+		result[i] = fmt.Sprintf("%q", titles[id])
+	}
+	return result, nil
+}
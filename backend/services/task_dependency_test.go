@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+func TestTaskDependencyService(t *testing.T) {
+	db := newTestDB(t, &models.TaskPreference{}, &models.AuditLog{})
+
+	user := models.User{Email: "builder@example.com", Username: "builder", Password: "x"}
+	db.Create(&user)
+	now := time.Date(2026, 4, 1, 9, 0, 0, 0, time.UTC)
+	launch := now.Add(8 * time.Hour)
+	release, _ := FindOrCreateTag(db, user.ID, "release")
+
+	// design -> build -> test -> ship, with docs alongside build
+	tasks := []models.Task{
+		{UserID: user.ID, Title: "Design", EstimatedMinutes: 120, Tags: []models.Tag{*release}},
+		{UserID: user.ID, Title: "Build", EstimatedMinutes: 240, Tags: []models.Tag{*release}},
+		{UserID: user.ID, Title: "Docs", EstimatedMinutes: 60, Tags: []models.Tag{*release}},
+		{UserID: user.ID, Title: "Test", EstimatedMinutes: 120, Tags: []models.Tag{*release}},
+		{UserID: user.ID, Title: "Ship", EstimatedMinutes: 30, DueDate: &launch, Tags: []models.Tag{*release}},
+	}
+	db.Create(&tasks)
+	design, build, docs, test, ship := tasks[0].ID, tasks[1].ID, tasks[2].ID, tasks[3].ID, tasks[4].ID
+
+	service := NewTaskDependencyService(db)
+	for task, deps := range map[uint][]uint{build: {design}, docs: {design}, test: {build}, ship: {test, docs}} {
+		if _, err := service.SetDependencies(user.ID, task, deps); err != nil {
+			t.Fatalf("failed to set dependencies: %v", err)
+		}
+	}
+
+	if _, err := service.AddDependency(user.ID, design, ship); !errors.Is(err, ErrDependencyCycle) {
+		t.Fatalf("expected a cycle to be rejected, got %v", err)
+	}
+	if _, err := service.AddDependency(user.ID, design, design); !errors.Is(err, ErrSelfDependency) {
+		t.Fatalf("expected a self dependency to be rejected, got %v", err)
+	}
+	other := models.Task{UserID: user.ID + 1, Title: "Someone else's"}
+	db.Create(&other)
+	if _, err := service.AddDependency(user.ID, design, other.ID); !errors.Is(err, ErrInvalidDependency) {
+		t.Fatalf("expected another user's task to be rejected, got %v", err)
+	}
+
+	// Build waits on design, so it cannot be completed yet
+	loaded, err := service.SetDependencies(user.ID, build, []uint{design})
+	if err != nil || !loaded.Blocked || len(loaded.Dependencies) != 1 {
+		t.Fatalf("expected build to be blocked: %+v (err %v)", loaded, err)
+	}
+	if err := service.CheckCompletion(user.ID, build); !errors.Is(err, ErrTaskBlocked) {
+		t.Fatalf("expected completion to be blocked, got %v", err)
+	}
+
+	graph, err := service.Graph(user.ID, TaskGraphScope{Tag: "release"}, now)
+	if err != nil {
+		t.Fatalf("failed to build graph: %v", err)
+	}
+	if len(graph.Nodes) != 5 || len(graph.Edges) != 5 || graph.TotalMinutes != 510 {
+		t.Fatalf("unexpected graph: %+v", graph)
+	}
+	wantOrder := []uint{design, build, docs, test, ship}
+	wantPath := []uint{design, build, test, ship}
+	for i := range wantOrder {
+		if graph.Order[i] != wantOrder[i] {
+			t.Fatalf("unexpected order %v, want %v", graph.Order, wantOrder)
+		}
+	}
+	if len(graph.CriticalPath) != len(wantPath) {
+		t.Fatalf("unexpected critical path %v, want %v", graph.CriticalPath, wantPath)
+	}
+	for i := range wantPath {
+		if graph.CriticalPath[i] != wantPath[i] {
+			t.Fatalf("unexpected critical path %v, want %v", graph.CriticalPath, wantPath)
+		}
+	}
+	for _, node := range graph.Nodes {
+		switch node.ID {
+		case docs:
+			if node.Critical || node.SlackMinutes != 300 || node.EarliestStart != 120 {
+				t.Fatalf("unexpected docs schedule: %+v", node)
+			}
+		case ship:
+			if !node.AtRisk || !node.Blocked || node.SlackMinutes != 0 {
+				t.Fatalf("expected ship to be late and blocked: %+v", node)
+			}
+		}
+	}
+
+	// Bulk completion skips tasks whose prerequisites stay open, but a
+	// prerequisite completed in the same batch counts
+	bulk := NewBulkService(db)
+	result, err := bulk.Tasks(user.ID, BulkRequest{Action: BulkActionSetStatus, IDs: []uint{design, build, ship}, Status: "completed"}, nil)
+	if err != nil {
+		t.Fatalf("failed to complete in bulk: %v", err)
+	}
+	if result.Succeeded != 2 || result.Failed != 1 || result.Results[2].Success {
+		t.Fatalf("unexpected bulk result: %+v", result)
+	}
+	if err := service.CheckCompletion(user.ID, test); err != nil {
+		t.Fatalf("expected test to be unblocked, got %v", err)
+	}
+
+	// Completed tasks drop out of the remaining work
+	graph, _ = service.Graph(user.ID, TaskGraphScope{Tag: "release"}, now)
+	if len(graph.Nodes) != 3 || graph.TotalMinutes != 150 {
+		t.Fatalf("unexpected remaining graph: %+v", graph)
+	}
+
+	allow := true
+	if _, err := service.UpdatePreferences(user.ID, TaskPreferenceInput{AllowCompletingBlocked: &allow}); err != nil {
+		t.Fatalf("failed to update preferences: %v", err)
+	}
+	if err := service.CheckCompletion(user.ID, ship); err != nil {
+		t.Fatalf("expected the preference to allow completion, got %v", err)
+	}
+
+	// A parent task and its subtasks form a project
+	project := models.Task{UserID: user.ID, Title: "Website"}
+	db.Create(&project)
+	child := models.Task{UserID: user.ID, Title: "Landing page", ParentTaskID: &project.ID, EstimatedMinutes: 45}
+	db.Create(&child)
+	graph, err = service.Graph(user.ID, TaskGraphScope{ParentID: &project.ID}, now)
+	if err != nil || len(graph.Nodes) != 2 || len(graph.CriticalPath) != 1 || graph.CriticalPath[0] != child.ID {
+		t.Fatalf("unexpected project graph: %+v (err %v)", graph, err)
+	}
+	if _, err := service.Graph(user.ID+1, TaskGraphScope{ParentID: &project.ID}, now); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected other users to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,585 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidEstimateRange = errors.New("invalid date range")
+	ErrInvalidVarianceGroup = errors.New("invalid variance grouping")
+)
+
+// maxBurndownDays bounds the number of points in one series
+const maxBurndownDays = 366
+
+// defaultBurndownDays is the range used when no dates are given
+const defaultBurndownDays = 30
+
+// Variance report groupings
+const (
+	VarianceByTag      = "tag"
+	VarianceByPriority = "priority"
+)
+
+// untaggedVarianceKey groups tasks without tags in a tag report
+const untaggedVarianceKey = "untagged"
+
+// TaskEstimateService compares estimated effort with the time tracked on
+// tasks
+type TaskEstimateService struct {
+	db *gorm.DB
+}
+
+// NewTaskEstimateService creates a new task estimate service
+func NewTaskEstimateService(db *gorm.DB) *TaskEstimateService {
+	return &TaskEstimateService{db: db}
+}
+
+// TaskEstimateScope selects the tasks of a report. Tag matches the tag and
+// the tags below it, ParentID a task and all of its subtasks and TeamID the
+// tasks shared with a team. Without a team only the user's own tasks are
+// included.
+type TaskEstimateScope struct {
+	Tag      string
+	ParentID *uint
+	TeamID   *uint
+}
+
+// TaskEstimateRange is an inclusive range of days in the user's timezone.
+// Only the dates of From and To are used; zero values default to the last
+// 30 days.
+type TaskEstimateRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// TaskActuals compares a task's estimate with the time logged on it. The
+// totals add up the task and all of its subtasks; cancelled tasks keep their
+// logged time but their estimate no longer counts.
+type TaskActuals struct {
+	TaskID           uint              `json:"task_id"`
+	Title            string            `json:"title"`
+	Status           models.TaskStatus `json:"status"`
+	EstimatedMinutes int               `json:"estimated_minutes"`
+	ActualMinutes    int               `json:"actual_minutes"`
+
+	TotalEstimatedMinutes int `json:"total_estimated_minutes"`
+	TotalActualMinutes    int `json:"total_actual_minutes"`
+	// VarianceMinutes is the total actual minus the total estimate; positive
+	// means the work took longer than planned
+	VarianceMinutes int `json:"variance_minutes"`
+
+	Subtasks []TaskActuals `json:"subtasks,omitempty"`
+}
+
+// BurndownPoint is the state of the scope at the end of one day. Minutes are
+// estimated work; LoggedMinutes is the time tracked since the first day.
+type BurndownPoint struct {
+	Date             string `json:"date"`
+	ScopeMinutes     int    `json:"scope_minutes"`
+	CompletedMinutes int    `json:"completed_minutes"`
+	RemainingMinutes int    `json:"remaining_minutes"`
+	// IdealMinutes falls in a straight line from the first day's remaining
+	// work to zero on the last day
+	IdealMinutes  int `json:"ideal_minutes"`
+	LoggedMinutes int `json:"logged_minutes"`
+}
+
+// TaskBurndown holds the burndown and burnup series of a scope. Cancelled
+// tasks are left out, and tasks without an estimate only show up in
+// UnestimatedTasks.
+type TaskBurndown struct {
+	From             string          `json:"from"`
+	To               string          `json:"to"`
+	Tasks            int             `json:"tasks"`
+	UnestimatedTasks int             `json:"unestimated_tasks"`
+	Points           []BurndownPoint `json:"points"`
+}
+
+// EstimateVarianceRow sums up the estimated tasks of one group. Ratio is
+// actual over estimated time, so values above one mean the group is
+// routinely underestimated.
+type EstimateVarianceRow struct {
+	Key              string  `json:"key"`
+	Tasks            int     `json:"tasks"`
+	EstimatedMinutes int     `json:"estimated_minutes"`
+	ActualMinutes    int     `json:"actual_minutes"`
+	VarianceMinutes  int     `json:"variance_minutes"`
+	Ratio            float64 `json:"ratio"`
+	Underestimated   int     `json:"underestimated"`
+	Overestimated    int     `json:"overestimated"`
+}
+
+// EstimateVarianceReport compares estimates with actuals for the tasks
+// completed in a date range, worst underestimated group first
+type EstimateVarianceReport struct {
+	GroupBy string                `json:"group_by"`
+	From    string                `json:"from"`
+	To      string                `json:"to"`
+	Rows    []EstimateVarianceRow `json:"rows"`
+	Total   EstimateVarianceRow   `json:"total"`
+}
+
+// estimateTask holds the task fields the reports use
+type estimateTask struct {
+	ID               uint
+	ParentTaskID     *uint
+	Title            string
+	Status           models.TaskStatus
+	Priority         models.TaskPriority
+	EstimatedMinutes int
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+	CompletedAt      *time.Time
+}
+
+// completedAt is when a completed task was done; older tasks may be missing
+// the timestamp, in which case the last update stands in for it
+func (t estimateTask) completedAt() *time.Time {
+	if t.Status != models.TaskStatusCompleted {
+		return nil
+	}
+	if t.CompletedAt != nil {
+		return t.CompletedAt
+	}
+	return &t.UpdatedAt
+}
+
+// estimateEntry is a time entry on a task
+type estimateEntry struct {
+	TaskID    uint
+	StartTime time.Time
+	EndTime   *time.Time
+	Duration  *int
+	IsRunning bool
+}
+
+// seconds is the tracked time of the entry; running timers count up to now
+func (e estimateEntry) seconds(now time.Time) int {
+	switch {
+	case e.Duration != nil:
+		return *e.Duration
+	case e.EndTime != nil:
+		return int(e.EndTime.Sub(e.StartTime).Seconds())
+	case e.IsRunning && now.After(e.StartTime):
+		return int(now.Sub(e.StartTime).Seconds())
+	}
+	return 0
+}
+
+func secondsToMinutes(seconds int) int {
+	return (seconds + 30) / 60
+}
+
+// Actuals returns the estimate and tracked time of a task and its subtasks
+func (s *TaskEstimateService) Actuals(userID, taskID uint, now time.Time) (*TaskActuals, error) {
+	if _, err := NewTaskActivityService(s.db).access(s.db, userID, taskID); err != nil {
+		return nil, err
+	}
+	tree, err := s.loadTree([]uint{taskID})
+	if err != nil {
+		return nil, err
+	}
+	logged, err := s.loggedSeconds(tree.ids(), now)
+	if err != nil {
+		return nil, err
+	}
+
+	visited := make(map[uint]bool)
+	var build func(id uint) (TaskActuals, int)
+	build = func(id uint) (TaskActuals, int) {
+		visited[id] = true
+		task := tree.tasks[id]
+		actuals := TaskActuals{
+			TaskID:           task.ID,
+			Title:            task.Title,
+			Status:           task.Status,
+			EstimatedMinutes: task.EstimatedMinutes,
+			ActualMinutes:    secondsToMinutes(logged[id]),
+		}
+		if task.Status != models.TaskStatusCancelled {
+			actuals.TotalEstimatedMinutes = max(task.EstimatedMinutes, 0)
+		}
+		totalSeconds := logged[id]
+		for _, childID := range tree.children[id] {
+			if visited[childID] {
+				continue
+			}
+			child, childSeconds := build(childID)
+			actuals.TotalEstimatedMinutes += child.TotalEstimatedMinutes
+			totalSeconds += childSeconds
+			actuals.Subtasks = append(actuals.Subtasks, child)
+		}
+		actuals.TotalActualMinutes = secondsToMinutes(totalSeconds)
+		actuals.VarianceMinutes = actuals.TotalActualMinutes - actuals.TotalEstimatedMinutes
+		return actuals, totalSeconds
+	}
+	actuals, _ := build(taskID)
+	return &actuals, nil
+}
+
+// Burndown returns the daily burndown and burnup series of the scope. Each
+// task joins the scope on the day it was created and burns down on the day
+// it was completed.
+func (s *TaskEstimateService) Burndown(userID uint, scope TaskEstimateScope, dates TaskEstimateRange, now time.Time) (*TaskBurndown, error) {
+	loc := UserLocation(s.db, userID)
+	days, err := estimateDays(dates, loc, now)
+	if err != nil {
+		return nil, err
+	}
+	query, err := s.scopedTasks(userID, scope)
+	if err != nil {
+		return nil, err
+	}
+	var tasks []estimateTask
+	if err := query.Model(&models.Task{}).Where("status <> ?", models.TaskStatusCancelled).
+		Select("id", "parent_task_id", "title", "status", "priority", "estimated_minutes", "created_at", "updated_at", "completed_at").
+		Order("id").Scan(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	burndown := &TaskBurndown{
+		From:   days[0].Format("2006-01-02"),
+		To:     days[len(days)-1].Format("2006-01-02"),
+		Points: make([]BurndownPoint, len(days)),
+	}
+	ids := make([]uint, len(tasks))
+	for i, task := range tasks {
+		ids[i] = task.ID
+	}
+	entries, err := s.entries(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, day := range days {
+		end := day.AddDate(0, 0, 1)
+		point := BurndownPoint{Date: day.Format("2006-01-02")}
+		for _, task := range tasks {
+			if task.EstimatedMinutes <= 0 || !task.CreatedAt.Before(end) {
+				continue
+			}
+			point.ScopeMinutes += task.EstimatedMinutes
+			if done := task.completedAt(); done != nil && done.Before(end) {
+				point.CompletedMinutes += task.EstimatedMinutes
+			}
+		}
+		point.RemainingMinutes = point.ScopeMinutes - point.CompletedMinutes
+
+		loggedSeconds := 0
+		for _, entry := range entries {
+			if !entry.StartTime.Before(days[0]) && entry.StartTime.Before(end) {
+				loggedSeconds += entry.seconds(now)
+			}
+		}
+		point.LoggedMinutes = secondsToMinutes(loggedSeconds)
+		burndown.Points[i] = point
+	}
+
+	if last := len(days) - 1; last > 0 {
+		start := burndown.Points[0].RemainingMinutes
+		for i := range burndown.Points {
+			burndown.Points[i].IdealMinutes = start * (last - i) / last
+		}
+	}
+
+	for _, task := range tasks {
+		if !task.CreatedAt.Before(days[len(days)-1].AddDate(0, 0, 1)) {
+			continue
+		}
+		burndown.Tasks++
+		if task.EstimatedMinutes <= 0 {
+			burndown.UnestimatedTasks++
+		}
+	}
+	return burndown, nil
+}
+
+// Variance compares estimates with actuals for the estimated tasks in scope
+// that were completed in the date range. A task's actual time includes the
+// time logged on subtasks that have no estimate of their own, so work
+// split into unestimated steps still counts against the parent's estimate.
+func (s *TaskEstimateService) Variance(userID uint, scope TaskEstimateScope, groupBy string, dates TaskEstimateRange, now time.Time) (*EstimateVarianceReport, error) {
+	if groupBy == "" {
+		groupBy = VarianceByTag
+	}
+	if groupBy != VarianceByTag && groupBy != VarianceByPriority {
+		return nil, fmt.Errorf("%w: %q, use tag or priority", ErrInvalidVarianceGroup, groupBy)
+	}
+	loc := UserLocation(s.db, userID)
+	days, err := estimateDays(dates, loc, now)
+	if err != nil {
+		return nil, err
+	}
+	start, end := days[0], days[len(days)-1].AddDate(0, 0, 1)
+
+	query, err := s.scopedTasks(userID, scope)
+	if err != nil {
+		return nil, err
+	}
+	var completed []uint
+	if err := query.Model(&models.Task{}).
+		Where("status = ? AND estimated_minutes > 0", models.TaskStatusCompleted).
+		Where("COALESCE(completed_at, updated_at) >= ? AND COALESCE(completed_at, updated_at) < ?", start, end).
+		Order("id").Pluck("id", &completed).Error; err != nil {
+		return nil, err
+	}
+
+	report := &EstimateVarianceReport{
+		GroupBy: groupBy,
+		From:    start.Format("2006-01-02"),
+		To:      days[len(days)-1].Format("2006-01-02"),
+		Rows:    []EstimateVarianceRow{},
+		Total:   EstimateVarianceRow{Key: "total"},
+	}
+	if len(completed) == 0 {
+		return report, nil
+	}
+
+	tree, err := s.loadTree(completed)
+	if err != nil {
+		return nil, err
+	}
+	logged, err := s.loggedSeconds(tree.ids(), now)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := s.varianceKeys(completed, tree, groupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	// actualSeconds follows subtasks until one carries its own estimate
+	var actualSeconds func(id uint, visited map[uint]bool) int
+	actualSeconds = func(id uint, visited map[uint]bool) int {
+		visited[id] = true
+		total := logged[id]
+		for _, childID := range tree.children[id] {
+			if !visited[childID] && tree.tasks[childID].EstimatedMinutes <= 0 {
+				total += actualSeconds(childID, visited)
+			}
+		}
+		return total
+	}
+
+	rows := make(map[string]*EstimateVarianceRow)
+	for _, id := range completed {
+		estimated := tree.tasks[id].EstimatedMinutes
+		actual := secondsToMinutes(actualSeconds(id, make(map[uint]bool)))
+		addVariance(&report.Total, estimated, actual)
+		for _, key := range keys[id] {
+			row := rows[key]
+			if row == nil {
+				row = &EstimateVarianceRow{Key: key}
+				rows[key] = row
+			}
+			addVariance(row, estimated, actual)
+		}
+	}
+
+	for _, row := range rows {
+		report.Rows = append(report.Rows, *row)
+	}
+	sort.Slice(report.Rows, func(i, j int) bool {
+		a, b := report.Rows[i], report.Rows[j]
+		if a.Ratio != b.Ratio {
+			return a.Ratio > b.Ratio
+		}
+		return a.Key < b.Key
+	})
+	return report, nil
+}
+
+func addVariance(row *EstimateVarianceRow, estimated, actual int) {
+	row.Tasks++
+	row.EstimatedMinutes += estimated
+	row.ActualMinutes += actual
+	row.VarianceMinutes = row.ActualMinutes - row.EstimatedMinutes
+	if row.EstimatedMinutes > 0 {
+		row.Ratio = float64(row.ActualMinutes) / float64(row.EstimatedMinutes)
+	}
+	switch {
+	case actual > estimated:
+		row.Underestimated++
+	case actual < estimated:
+		row.Overestimated++
+	}
+}
+
+// varianceKeys returns the groups each task is reported under. Tasks with
+// several tags count towards each of them.
+func (s *TaskEstimateService) varianceKeys(ids []uint, tree *estimateTree, groupBy string) (map[uint][]string, error) {
+	keys := make(map[uint][]string, len(ids))
+	if groupBy == VarianceByPriority {
+		for _, id := range ids {
+			priority := tree.tasks[id].Priority
+			if priority == "" {
+				priority = models.TaskPriorityMedium
+			}
+			keys[id] = []string{string(priority)}
+		}
+		return keys, nil
+	}
+
+	var rows []struct {
+		TaskID uint
+		Name   string
+	}
+	if err := s.db.Table("task_tags").
+		Joins("JOIN tags ON tags.id = task_tags.tag_id AND tags.deleted_at IS NULL").
+		Where("task_tags.task_id IN ?", ids).
+		Select("task_tags.task_id, tags.name").Order("tags.name").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		keys[row.TaskID] = append(keys[row.TaskID], row.Name)
+	}
+	for _, id := range ids {
+		if len(keys[id]) == 0 {
+			keys[id] = []string{untaggedVarianceKey}
+		}
+	}
+	return keys, nil
+}
+
+// scopedTasks returns a query for the tasks in scope
+func (s *TaskEstimateService) scopedTasks(userID uint, scope TaskEstimateScope) (*gorm.DB, error) {
+	query := s.db.Where("user_id = ?", userID)
+	if scope.TeamID != nil {
+		var count int64
+		if err := s.db.Model(&models.TeamMember{}).Where("team_id = ? AND user_id = ?", *scope.TeamID, userID).
+			Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, gorm.ErrRecordNotFound
+		}
+		query = s.db.Where("id IN (SELECT task_id FROM team_tasks WHERE team_id = ? AND deleted_at IS NULL)", *scope.TeamID)
+	}
+
+	if scope.Tag != "" {
+		tagIDs, err := NewTagService(s.db).FilterIDs(userID, []string{scope.Tag})
+		if err != nil {
+			return nil, err
+		}
+		if len(tagIDs) == 0 {
+			tagIDs = []uint{0}
+		}
+		query = query.Where("id IN (SELECT task_id FROM task_tags WHERE tag_id IN ?)", tagIDs)
+	}
+
+	if scope.ParentID != nil {
+		var parent models.Task
+		if err := query.Session(&gorm.Session{}).Where("id = ?", *scope.ParentID).First(&parent).Error; err != nil {
+			return nil, err
+		}
+		tree, err := s.loadTree([]uint{parent.ID})
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("id IN ?", tree.ids())
+	}
+	return query, nil
+}
+
+// estimateTree is a set of tasks and all of their subtasks
+type estimateTree struct {
+	tasks    map[uint]estimateTask
+	children map[uint][]uint
+}
+
+func (t *estimateTree) ids() []uint {
+	ids := make([]uint, 0, len(t.tasks))
+	for id := range t.tasks {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// loadTree loads the given tasks and their subtasks at any depth
+func (s *TaskEstimateService) loadTree(rootIDs []uint) (*estimateTree, error) {
+	tree := &estimateTree{tasks: make(map[uint]estimateTask), children: make(map[uint][]uint)}
+	columns := []string{"id", "parent_task_id", "title", "status", "priority", "estimated_minutes", "created_at", "updated_at", "completed_at"}
+
+	var roots []estimateTask
+	if err := s.db.Model(&models.Task{}).Where("id IN ?", rootIDs).Select(columns).Scan(&roots).Error; err != nil {
+		return nil, err
+	}
+	frontier := make([]uint, 0, len(roots))
+	for _, task := range roots {
+		tree.tasks[task.ID] = task
+		frontier = append(frontier, task.ID)
+	}
+	for len(frontier) > 0 {
+		var children []estimateTask
+		if err := s.db.Model(&models.Task{}).Where("parent_task_id IN ?", frontier).Select(columns).
+			Order("id").Scan(&children).Error; err != nil {
+			return nil, err
+		}
+		frontier = nil
+		for _, child := range children {
+			tree.children[*child.ParentTaskID] = append(tree.children[*child.ParentTaskID], child.ID)
+			if _, seen := tree.tasks[child.ID]; seen {
+				continue
+			}
+			tree.tasks[child.ID] = child
+			frontier = append(frontier, child.ID)
+		}
+	}
+	return tree, nil
+}
+
+func (s *TaskEstimateService) entries(taskIDs []uint) ([]estimateEntry, error) {
+	if len(taskIDs) == 0 {
+		return nil, nil
+	}
+	var entries []estimateEntry
+	err := s.db.Model(&models.TimeEntry{}).Where("task_id IN ?", taskIDs).
+		Select("task_id", "start_time", "end_time", "duration", "is_running").
+		Order("start_time").Scan(&entries).Error
+	return entries, err
+}
+
+// loggedSeconds sums the time logged on each task by anyone
+func (s *TaskEstimateService) loggedSeconds(taskIDs []uint, now time.Time) (map[uint]int, error) {
+	entries, err := s.entries(taskIDs)
+	if err != nil {
+		return nil, err
+	}
+	logged := make(map[uint]int, len(taskIDs))
+	for _, entry := range entries {
+		logged[entry.TaskID] += entry.seconds(now)
+	}
+	return logged, nil
+}
+
+// estimateDays returns midnight of each day in the range in loc
+func estimateDays(dates TaskEstimateRange, loc *time.Location, now time.Time) ([]time.Time, error) {
+	to := startOfLocalDay(now, loc)
+	if !dates.To.IsZero() {
+		to = time.Date(dates.To.Year(), dates.To.Month(), dates.To.Day(), 0, 0, 0, 0, loc)
+	}
+	from := to.AddDate(0, 0, -(defaultBurndownDays - 1))
+	if !dates.From.IsZero() {
+		from = time.Date(dates.From.Year(), dates.From.Month(), dates.From.Day(), 0, 0, 0, 0, loc)
+	}
+	if to.Before(from) {
+		return nil, fmt.Errorf("%w: end date is before start date", ErrInvalidEstimateRange)
+	}
+
+	var days []time.Time
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		if len(days) == maxBurndownDays {
+			return nil, fmt.Errorf("%w: at most %d days", ErrInvalidEstimateRange, maxBurndownDays)
+		}
+		days = append(days, day)
+	}
+	return days, nil
+}
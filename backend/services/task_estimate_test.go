@@ -0,0 +1,122 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+func TestTaskEstimateService(t *testing.T) {
+	db := newTestDB(t, &models.TimeEntry{}, &models.Team{}, &models.TeamMember{}, &models.TeamTask{})
+
+	user := models.User{Email: "dev@example.com", Username: "dev", Password: "x", GitHubID: 1}
+	outsider := models.User{Email: "out@example.com", Username: "out", Password: "x", GitHubID: 2}
+	db.Create(&user)
+	db.Create(&outsider)
+	backend, _ := FindOrCreateTag(db, user.ID, "backend")
+	frontend, _ := FindOrCreateTag(db, user.ID, "frontend")
+
+	day := func(n int, hour int) time.Time {
+		return time.Date(2026, 6, n, hour, 0, 0, 0, time.UTC)
+	}
+	apiDone, uiDone := day(3, 15), day(5, 11)
+	project := models.Task{UserID: user.ID, Title: "Launch", CreatedAt: day(1, 9)}
+	db.Create(&project)
+	api := models.Task{UserID: user.ID, Title: "API", ParentTaskID: &project.ID, EstimatedMinutes: 120, CreatedAt: day(1, 9),
+		Status: models.TaskStatusCompleted, CompletedAt: &apiDone, Tags: []models.Tag{*backend}}
+	ui := models.Task{UserID: user.ID, Title: "UI", ParentTaskID: &project.ID, EstimatedMinutes: 60, CreatedAt: day(1, 9),
+		Status: models.TaskStatusCompleted, CompletedAt: &uiDone, Tags: []models.Tag{*frontend}}
+	db.Create(&api)
+	db.Create(&ui)
+	migrations := models.Task{UserID: user.ID, Title: "Migrations", ParentTaskID: &api.ID, CreatedAt: day(2, 9)}
+	dropped := models.Task{UserID: user.ID, Title: "Dropped", ParentTaskID: &project.ID, EstimatedMinutes: 500, CreatedAt: day(1, 9), Status: models.TaskStatusCancelled}
+	db.Create(&migrations)
+	db.Create(&dropped)
+
+	log := func(task models.Task, start time.Time, minutes int) {
+		seconds := minutes * 60
+		end := start.Add(time.Duration(minutes) * time.Minute)
+		db.Create(&models.TimeEntry{UserID: user.ID, TaskID: &task.ID, StartTime: start, EndTime: &end, Duration: &seconds})
+	}
+	log(api, day(2, 10), 90)
+	log(migrations, day(3, 10), 60)
+	log(ui, day(4, 10), 30)
+	log(project, day(1, 10), 10)
+
+	now := day(6, 12)
+	service := NewTaskEstimateService(db)
+
+	// Actuals roll up through subtasks
+	actuals, err := service.Actuals(user.ID, project.ID, now)
+	if err != nil {
+		t.Fatalf("failed to fetch actuals: %v", err)
+	}
+	if actuals.ActualMinutes != 10 || actuals.TotalEstimatedMinutes != 180 || actuals.TotalActualMinutes != 190 || len(actuals.Subtasks) != 3 {
+		t.Fatalf("unexpected actuals: %+v", actuals)
+	}
+	if sub := actuals.Subtasks[0]; sub.TaskID != api.ID || sub.TotalActualMinutes != 150 || sub.VarianceMinutes != 30 {
+		t.Fatalf("unexpected subtask actuals: %+v", sub)
+	}
+	if _, err := service.Actuals(outsider.ID, project.ID, now); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected the task to be hidden from others, got %v", err)
+	}
+
+	// Burndown of the project over five days; cancelled work is left out
+	burndown, err := service.Burndown(user.ID, TaskEstimateScope{ParentID: &project.ID}, TaskEstimateRange{From: day(1, 0), To: day(5, 0)}, now)
+	if err != nil {
+		t.Fatalf("failed to build burndown: %v", err)
+	}
+	remaining := []int{180, 180, 60, 60, 0}
+	ideal := []int{180, 135, 90, 45, 0}
+	logged := []int{10, 100, 160, 190, 190}
+	if len(burndown.Points) != 5 || burndown.Tasks != 4 || burndown.UnestimatedTasks != 2 {
+		t.Fatalf("unexpected burndown: %+v", burndown)
+	}
+	for i, point := range burndown.Points {
+		if point.ScopeMinutes != 180 || point.RemainingMinutes != remaining[i] || point.IdealMinutes != ideal[i] ||
+			point.LoggedMinutes != logged[i] || point.CompletedMinutes != 180-remaining[i] {
+			t.Fatalf("unexpected point %d: %+v", i, point)
+		}
+	}
+	if _, err := service.Burndown(user.ID, TaskEstimateScope{}, TaskEstimateRange{From: day(5, 0), To: day(1, 0)}, now); !errors.Is(err, ErrInvalidEstimateRange) {
+		t.Fatalf("expected an invalid range, got %v", err)
+	}
+	team := models.Team{Name: "Platform", OwnerID: user.ID}
+	db.Create(&team)
+	if _, err := service.Burndown(outsider.ID, TaskEstimateScope{TeamID: &team.ID}, TaskEstimateRange{}, now); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected non-members to be refused, got %v", err)
+	}
+
+	// Unestimated subtasks count against the parent's estimate
+	report, err := service.Variance(user.ID, TaskEstimateScope{}, VarianceByTag, TaskEstimateRange{From: day(1, 0), To: day(6, 0)}, now)
+	if err != nil {
+		t.Fatalf("failed to build variance report: %v", err)
+	}
+	if len(report.Rows) != 2 || report.Rows[0].Key != "backend" || report.Rows[0].ActualMinutes != 150 || report.Rows[0].Ratio != 1.25 ||
+		report.Rows[0].Underestimated != 1 || report.Rows[1].Key != "frontend" || report.Rows[1].Overestimated != 1 ||
+		report.Total.EstimatedMinutes != 180 || report.Total.ActualMinutes != 180 {
+		t.Fatalf("unexpected variance report: %+v", report)
+	}
+	report, err = service.Variance(user.ID, TaskEstimateScope{Tag: "frontend"}, VarianceByPriority, TaskEstimateRange{From: day(4, 0), To: day(6, 0)}, now)
+	if err != nil || len(report.Rows) != 1 || report.Rows[0].Key != "medium" || report.Rows[0].Tasks != 1 {
+		t.Fatalf("unexpected priority report: %+v (err %v)", report, err)
+	}
+	if _, err := service.Variance(user.ID, TaskEstimateScope{}, "colour", TaskEstimateRange{}, now); !errors.Is(err, ErrInvalidVarianceGroup) {
+		t.Fatalf("expected an invalid grouping, got %v", err)
+	}
+
+	// Estimate changes show up in the task history
+	if err := TrackTaskChanges(db, user.ID, []uint{ui.ID}, func() error {
+		return db.Model(&ui).Update("estimated_minutes", 90).Error
+	}); err != nil {
+		t.Fatalf("failed to update estimate: %v", err)
+	}
+	var change models.TaskChange
+	if err := db.Where("task_id = ? AND field = ?", ui.ID, "estimated_minutes").First(&change).Error; err != nil ||
+		change.OldValue != "60" || change.NewValue != "90" {
+		t.Fatalf("expected the estimate change to be recorded: %+v (err %v)", change, err)
+	}
+}
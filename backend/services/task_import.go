@@ -0,0 +1,315 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+// Sources of imported tasks, stored in Task.ExternalSource
+const (
+	TaskSourceTodoist     = "todoist"
+	TaskSourceTaskwarrior = "taskwarrior"
+)
+
+const taskImportDetailLimit = 50
+
+// ImportedTask is a task read from another tool, before it is matched to a
+// Trackeep task. Parents and prerequisites refer to other items by their
+// external IDs.
+type ImportedTask struct {
+	ExternalID       string
+	ParentExternalID string
+	DependsOn        []string
+	// TaskID is the Trackeep task the item was exported from, if any
+	TaskID uint
+
+	Title            string
+	Description      string
+	Status           models.TaskStatus
+	Priority         models.TaskPriority
+	DueDate          *time.Time
+	CompletedAt      *time.Time
+	EstimatedMinutes int
+	Tags             []string
+	// Recurrence is an RRULE, applied when the task is first imported
+	Recurrence string
+}
+
+// TaskImportResult counts what an import or sync did
+type TaskImportResult struct {
+	Processed int      `json:"processed"`
+	Created   int      `json:"created"`
+	Updated   int      `json:"updated"`
+	Skipped   int      `json:"skipped"`
+	Closed    int      `json:"closed"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+func (r *TaskImportResult) addError(format string, args ...interface{}) {
+	if len(r.Errors) < taskImportDetailLimit {
+		r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+	}
+}
+
+// TaskImportService moves tasks between Trackeep and other tools. Imported
+// items are matched by source and external ID, so running the same import
+// twice changes nothing.
+type TaskImportService struct {
+	db *gorm.DB
+}
+
+// NewTaskImportService creates a new task import service
+func NewTaskImportService(db *gorm.DB) *TaskImportService {
+	return &TaskImportService{db: db}
+}
+
+// Import creates or updates a task for every item. Subtasks and
+// prerequisites are linked once all items exist; ones that point outside the
+// import are left alone.
+func (s *TaskImportService) Import(userID uint, source string, items []ImportedTask, now time.Time) (*TaskImportResult, error) {
+	result := &TaskImportResult{}
+	taskIDs := map[string]uint{}
+	var recurring []ImportedTask
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var existing []models.Task
+		if err := tx.Where("user_id = ? AND external_source = ?", userID, source).Preload("Tags").Find(&existing).Error; err != nil {
+			return err
+		}
+		byExternalID := make(map[string]*models.Task, len(existing))
+		for i := range existing {
+			byExternalID[existing[i].ExternalID] = &existing[i]
+		}
+
+		tagCache := map[string]*models.Tag{}
+		for _, item := range items {
+			result.Processed++
+			if item.ExternalID == "" || strings.TrimSpace(item.Title) == "" {
+				result.Skipped++
+				continue
+			}
+
+			tags := make([]models.Tag, 0, len(item.Tags))
+			for _, name := range item.Tags {
+				if NormalizeTagName(name) == "" {
+					continue
+				}
+				tag, err := findOrCreateImportTag(tx, userID, name, tagCache)
+				if err != nil {
+					return err
+				}
+				tags = append(tags, *tag)
+			}
+
+			task := byExternalID[item.ExternalID]
+			if task == nil && item.TaskID != 0 {
+				var exported models.Task
+				if err := tx.Where("id = ? AND user_id = ?", item.TaskID, userID).Preload("Tags").First(&exported).Error; err == nil {
+					task = &exported
+				}
+			}
+
+			if task == nil {
+				created := models.Task{
+					UserID:           userID,
+					Title:            item.Title,
+					Description:      item.Description,
+					Status:           importStatus(item.Status),
+					Priority:         importPriority(item.Priority),
+					DueDate:          item.DueDate,
+					CompletedAt:      item.CompletedAt,
+					EstimatedMinutes: item.EstimatedMinutes,
+					Tags:             tags,
+					ExternalSource:   source,
+					ExternalID:       item.ExternalID,
+				}
+				if err := tx.Create(&created).Error; err != nil {
+					return err
+				}
+				taskIDs[item.ExternalID] = created.ID
+				result.Created++
+				if item.Recurrence != "" {
+					recurring = append(recurring, item)
+				}
+				continue
+			}
+
+			taskIDs[item.ExternalID] = task.ID
+			changed, err := updateImportedTask(tx, task, item, tags)
+			if err != nil {
+				return err
+			}
+			if changed {
+				result.Updated++
+			} else {
+				result.Skipped++
+			}
+		}
+
+		for _, item := range items {
+			taskID, ok := taskIDs[item.ExternalID]
+			if !ok {
+				continue
+			}
+			if parentID, ok := taskIDs[item.ParentExternalID]; ok && parentID != taskID {
+				if err := tx.Model(&models.Task{}).Where("id = ? AND (parent_task_id IS NULL OR parent_task_id <> ?)", taskID, parentID).
+					Update("parent_task_id", parentID).Error; err != nil {
+					return err
+				}
+			}
+			if len(item.DependsOn) == 0 {
+				continue
+			}
+			var dependencyIDs []uint
+			for _, externalID := range item.DependsOn {
+				if id, ok := taskIDs[externalID]; ok {
+					dependencyIDs = append(dependencyIDs, id)
+				}
+			}
+			if err := setTaskDependencies(tx, userID, taskID, uniqueIDs(dependencyIDs)); err != nil {
+				if isDependencyError(err) {
+					result.addError("%s: %v", item.Title, err)
+					continue
+				}
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	recurrences := NewTaskRecurrenceService(s.db)
+	for _, item := range recurring {
+		if _, err := recurrences.SetRecurrence(userID, taskIDs[item.ExternalID], TaskRecurrenceInput{Rule: item.Recurrence}, now); err != nil {
+			result.addError("%s: %v", item.Title, err)
+		}
+	}
+	return result, nil
+}
+
+// CloseMissing completes open tasks from source that were not seen in the
+// latest full sync, because they were completed or deleted upstream
+func (s *TaskImportService) CloseMissing(userID uint, source string, seen []string, now time.Time) (int, error) {
+	query := s.db.Model(&models.Task{}).
+		Where("user_id = ? AND external_source = ? AND status NOT IN ?", userID, source, closedTaskStatuses)
+	if len(seen) > 0 {
+		query = query.Where("external_id NOT IN ?", seen)
+	}
+	var ids []uint
+	if err := query.Pluck("id", &ids).Error; err != nil || len(ids) == 0 {
+		return 0, err
+	}
+
+	closed := 0
+	err := TrackTaskChanges(s.db, 0, ids, func() error {
+		update := s.db.Model(&models.Task{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"status":       models.TaskStatusCompleted,
+			"completed_at": now,
+			"progress":     100,
+		})
+		closed = int(update.RowsAffected)
+		return update.Error
+	})
+	return closed, err
+}
+
+// updateImportedTask copies the item's fields onto the task and reports
+// whether anything changed
+func updateImportedTask(tx *gorm.DB, task *models.Task, item ImportedTask, tags []models.Tag) (bool, error) {
+	updates := map[string]interface{}{}
+	if task.Title != item.Title {
+		updates["title"] = item.Title
+	}
+	if task.Description != item.Description {
+		updates["description"] = item.Description
+	}
+	// Todoist has no in-progress state, so a task started here stays started
+	// while it is open upstream
+	status := importStatus(item.Status)
+	if task.Status != status && !(status == models.TaskStatusPending && task.Status == models.TaskStatusInProgress) {
+		updates["status"] = status
+	}
+	if priority := importPriority(item.Priority); task.Priority != priority {
+		updates["priority"] = priority
+	}
+	if !sameTime(task.DueDate, item.DueDate) {
+		updates["due_date"] = item.DueDate
+	}
+	if !sameTime(task.CompletedAt, item.CompletedAt) {
+		updates["completed_at"] = item.CompletedAt
+	}
+	if item.EstimatedMinutes > 0 && task.EstimatedMinutes != item.EstimatedMinutes {
+		updates["estimated_minutes"] = item.EstimatedMinutes
+	}
+
+	if len(updates) > 0 {
+		if err := TrackTaskChanges(tx, 0, []uint{task.ID}, func() error {
+			return tx.Model(task).Updates(updates).Error
+		}); err != nil {
+			return false, err
+		}
+	}
+
+	tagsChanged := !sameTagSet(task.Tags, tags)
+	if tagsChanged {
+		if err := tx.Model(task).Association("Tags").Replace(tags); err != nil {
+			return false, err
+		}
+	}
+	return len(updates) > 0 || tagsChanged, nil
+}
+
+func isDependencyError(err error) bool {
+	return errors.Is(err, ErrDependencyCycle) || errors.Is(err, ErrSelfDependency) || errors.Is(err, ErrInvalidDependency)
+}
+
+func importStatus(status models.TaskStatus) models.TaskStatus {
+	if status == "" {
+		return models.TaskStatusPending
+	}
+	return status
+}
+
+func importPriority(priority models.TaskPriority) models.TaskPriority {
+	if priority == "" {
+		return models.TaskPriorityMedium
+	}
+	return priority
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(*b)
+}
+
+func sameTagSet(current, next []models.Tag) bool {
+	ids := func(tags []models.Tag) []uint {
+		out := make([]uint, 0, len(tags))
+		for _, tag := range tags {
+			out = append(out, tag.ID)
+		}
+		out = uniqueIDs(out)
+		sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+		return out
+	}
+	a, b := ids(current), ids(next)
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+func openTaskImportDB(t *testing.T) *gorm.DB {
+	db := newTestDB(t)
+	return db
+}
+
+func TestSyncTodoist(t *testing.T) {
+	db := openTaskImportDB(t)
+	user := models.User{Email: "todo@example.com", Username: "todo", Password: "x"}
+	db.Create(&user)
+	now := time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC)
+
+	tasks := []map[string]interface{}{
+		{"id": "101", "project_id": "2", "section_id": "20", "content": "Ship release", "priority": 4, "labels": []string{"deep work"},
+			"due": map[string]interface{}{"date": "2026-06-05", "datetime": "2026-06-05T14:00:00Z", "string": "jun 5 2pm", "is_recurring": false}},
+		{"id": "102", "project_id": "2", "parent_id": "101", "content": "Write changelog", "priority": 1,
+			"due": map[string]interface{}{"date": "2026-06-04", "string": "jun 4"}, "duration": map[string]interface{}{"amount": 30, "unit": "minute"}},
+		{"id": "103", "project_id": "1", "content": "Water plants", "priority": 2,
+			"due": map[string]interface{}{"date": "2026-06-05", "string": "every friday", "is_recurring": true}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/projects":
+			json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"id": "1", "name": "Inbox", "is_inbox_project": true},
+				{"id": "2", "name": "Clients", "parent_id": "3"},
+				{"id": "3", "name": "Work"},
+			})
+		case "/sections":
+			json.NewEncoder(w).Encode([]map[string]interface{}{{"id": "20", "project_id": "2", "name": "Backlog"}})
+		case "/tasks":
+			json.NewEncoder(w).Encode(tasks)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewTodoistClient("secret")
+	client.BaseURL = server.URL
+	service := NewTaskImportService(db)
+
+	result, err := service.SyncTodoist(context.Background(), user.ID, client, nil, now)
+	if err != nil {
+		t.Fatalf("failed to sync: %v", err)
+	}
+	if result.Created != 3 || len(result.Errors) != 0 {
+		t.Fatalf("unexpected first sync: %+v", result)
+	}
+
+	var release, changelog, plants models.Task
+	db.Preload("Tags").Where("external_id = ?", "101").First(&release)
+	db.Where("external_id = ?", "102").First(&changelog)
+	db.Preload("Recurrence").Where("external_id = ?", "103").First(&plants)
+	if release.Priority != models.TaskPriorityUrgent || len(release.Tags) != 2 || !release.DueDate.Equal(time.Date(2026, 6, 5, 14, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected release task: %+v", release)
+	}
+	tagNames := map[string]bool{}
+	for _, tag := range release.Tags {
+		tagNames[tag.Name] = true
+	}
+	if !tagNames["Work/Clients/Backlog"] || !tagNames["deep work"] {
+		t.Fatalf("expected project, section and label tags, got %v", tagNames)
+	}
+	if changelog.ParentTaskID == nil || *changelog.ParentTaskID != release.ID || changelog.EstimatedMinutes != 30 ||
+		changelog.Priority != models.TaskPriorityLow || changelog.DueDate.Hour() != 23 {
+		t.Fatalf("unexpected subtask: %+v", changelog)
+	}
+	if plants.Recurrence == nil || plants.Recurrence.Rule != "FREQ=WEEKLY;BYDAY=FR" {
+		t.Fatalf("expected the recurring task to get a rule: %+v", plants.Recurrence)
+	}
+
+	// Syncing again changes nothing
+	result, err = service.SyncTodoist(context.Background(), user.ID, client, nil, now)
+	if err != nil || result.Created != 0 || result.Updated != 0 || result.Skipped != 3 {
+		t.Fatalf("expected an idempotent sync: %+v (err %v)", result, err)
+	}
+
+	// A renamed task is updated and a task gone upstream is completed
+	tasks[0]["content"] = "Ship release 2.0"
+	tasks = tasks[:2]
+	result, err = service.SyncTodoist(context.Background(), user.ID, client, nil, now)
+	if err != nil || result.Updated != 1 || result.Closed != 1 {
+		t.Fatalf("unexpected third sync: %+v (err %v)", result, err)
+	}
+	db.First(&plants, plants.ID)
+	if plants.Status != models.TaskStatusCompleted {
+		t.Fatalf("expected the missing task to be completed, got %s", plants.Status)
+	}
+	var count int64
+	db.Model(&models.Task{}).Where("user_id = ?", user.ID).Count(&count)
+	if count != 3 {
+		t.Fatalf("expected no duplicates, got %d tasks", count)
+	}
+
+	client.Token = "wrong"
+	if _, err := service.SyncTodoist(context.Background(), user.ID, client, nil, now); err == nil {
+		t.Fatalf("expected a rejected token to fail the sync")
+	}
+}
+
+func TestTaskwarriorImportExport(t *testing.T) {
+	db := openTaskImportDB(t)
+	user := models.User{Email: "tw@example.com", Username: "tw", Password: "x"}
+	db.Create(&user)
+	now := time.Date(2026, 6, 1, 8, 0, 0, 0, time.UTC)
+
+	export := `[
+		{"id":1,"uuid":"a0000000-0000-4000-8000-000000000001","description":"Buy paint","status":"completed","entry":"20260501T090000Z",
+		 "end":"20260502T100000Z","project":"Home.Garden","priority":"L"},
+		{"id":2,"uuid":"a0000000-0000-4000-8000-000000000002","description":"Paint fence","status":"pending","entry":"20260501T090000Z",
+		 "due":"20260610T160000Z","project":"Home.Garden","tags":["weekend"],"priority":"H",
+		 "depends":"a0000000-0000-4000-8000-000000000001","annotations":[{"entry":"20260501T090000Z","description":"Two coats"}]},
+		{"id":3,"uuid":"a0000000-0000-4000-8000-000000000003","description":"Invite neighbours","status":"pending","entry":"20260501T090000Z",
+		 "start":"20260520T090000Z","depends":["a0000000-0000-4000-8000-000000000002"]},
+		{"uuid":"a0000000-0000-4000-8000-000000000004","description":"Weekly review","status":"recurring","recur":"weekly"}
+	]`
+	items, err := ParseTaskwarriorExport(strings.NewReader(export))
+	if err != nil || len(items) != 3 {
+		t.Fatalf("unexpected parse: %d items (err %v)", len(items), err)
+	}
+
+	service := NewTaskImportService(db)
+	result, err := service.Import(user.ID, TaskSourceTaskwarrior, items, now)
+	if err != nil || result.Created != 3 || len(result.Errors) != 0 {
+		t.Fatalf("unexpected import: %+v (err %v)", result, err)
+	}
+
+	var fence models.Task
+	db.Preload("Tags").Preload("Dependencies").Where("external_id = ?", "a0000000-0000-4000-8000-000000000002").First(&fence)
+	if fence.Priority != models.TaskPriorityHigh || fence.Description != "Two coats" || len(fence.Tags) != 2 ||
+		len(fence.Dependencies) != 1 || fence.Dependencies[0].Title != "Buy paint" {
+		t.Fatalf("unexpected imported task: %+v", fence)
+	}
+	var invite models.Task
+	db.Where("external_id = ?", "a0000000-0000-4000-8000-000000000003").First(&invite)
+	if invite.Status != models.TaskStatusInProgress {
+		t.Fatalf("expected a started task to be in progress, got %s", invite.Status)
+	}
+
+	// A native task exported alongside and imported back is matched by its
+	// trackeep_id, not duplicated
+	native := models.Task{UserID: user.ID, Title: "Call plumber", Priority: models.TaskPriorityLow}
+	db.Create(&native)
+	data, err := service.ExportTaskwarrior(user.ID)
+	if err != nil {
+		t.Fatalf("failed to export: %v", err)
+	}
+	var exported []taskwarriorTask
+	if err := json.Unmarshal(data, &exported); err != nil || len(exported) != 4 {
+		t.Fatalf("unexpected export: %s (err %v)", data, err)
+	}
+	for _, task := range exported {
+		if task.Description == "Paint fence" && (len(task.Depends) != 1 || task.Depends[0] != "a0000000-0000-4000-8000-000000000001" || task.Due != "20260610T160000Z") {
+			t.Fatalf("unexpected exported task: %+v", task)
+		}
+		if task.Description == "Buy paint" && (task.Status != "completed" || task.End != "20260502T100000Z") {
+			t.Fatalf("unexpected exported completed task: %+v", task)
+		}
+	}
+
+	items, err = ParseTaskwarriorExport(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to parse own export: %v", err)
+	}
+	result, err = service.Import(user.ID, TaskSourceTaskwarrior, items, now)
+	if err != nil || result.Created != 0 || result.Updated != 0 || result.Skipped != 4 {
+		t.Fatalf("expected re-importing the export to change nothing: %+v (err %v)", result, err)
+	}
+}
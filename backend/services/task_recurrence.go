@@ -0,0 +1,394 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidRecurrenceMode is returned for unknown recurrence modes
+	ErrInvalidRecurrenceMode = errors.New("recurrence mode must be due_date or completion_date")
+	// ErrTaskNotRecurring is returned for recurrence operations on one-off tasks
+	ErrTaskNotRecurring = errors.New("task is not recurring")
+)
+
+// maxUpcomingOccurrences caps the preview of future occurrences
+const maxUpcomingOccurrences = 50
+
+// TaskRecurrenceInput makes a task recurring or changes its rule
+type TaskRecurrenceInput struct {
+	Rule    string     `json:"rule"`
+	Mode    string     `json:"mode"`     // due_date (default) or completion_date
+	StartAt *time.Time `json:"start_at"` // defaults to the task's due date
+}
+
+// TaskOccurrences is the history and preview of a recurring task
+type TaskOccurrences struct {
+	Recurrence models.TaskRecurrence `json:"recurrence"`
+	History    []models.Task         `json:"history"`
+	Upcoming   []time.Time           `json:"upcoming"`
+}
+
+// TaskRecurrenceService manages recurring tasks
+type TaskRecurrenceService struct {
+	db *gorm.DB
+}
+
+// NewTaskRecurrenceService creates a new task recurrence service
+func NewTaskRecurrenceService(db *gorm.DB) *TaskRecurrenceService {
+	return &TaskRecurrenceService{db: db}
+}
+
+// ValidateRecurrence checks a rule and mode without saving anything
+func (s *TaskRecurrenceService) ValidateRecurrence(userID uint, input TaskRecurrenceInput) error {
+	if _, err := ParseRRule(input.Rule, UserLocation(s.db, userID)); err != nil {
+		return err
+	}
+	_, err := parseRecurrenceMode(input.Mode)
+	return err
+}
+
+// SetRecurrence makes the task recurring, or replaces the rule of its
+// series. Replacing restarts the schedule from this occurrence, so earlier
+// occurrences keep the rule they were created under.
+func (s *TaskRecurrenceService) SetRecurrence(userID, taskID uint, input TaskRecurrenceInput, now time.Time) (*models.Task, error) {
+	loc := UserLocation(s.db, userID)
+	rule, err := ParseRRule(input.Rule, loc)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := parseRecurrenceMode(input.Mode)
+	if err != nil {
+		return nil, err
+	}
+
+	var task models.Task
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND user_id = ?", taskID, userID).Preload("Tags").First(&task).Error; err != nil {
+			return err
+		}
+
+		start := now
+		switch {
+		case input.StartAt != nil:
+			start = *input.StartAt
+		case task.DueDate != nil:
+			start = *task.DueDate
+		}
+		if task.DueDate == nil || input.StartAt != nil {
+			if err := tx.Model(&task).Update("due_date", start).Error; err != nil {
+				return err
+			}
+		}
+
+		if task.RecurrenceID != nil {
+			var recurrence models.TaskRecurrence
+			if err := tx.First(&recurrence, *task.RecurrenceID).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&recurrence).Updates(map[string]interface{}{
+				"rule":      rule.String(),
+				"mode":      mode,
+				"start_at":  start,
+				"generated": 1,
+				"ended_at":  nil,
+			}).Error; err != nil {
+				return err
+			}
+			return copyTaskTemplate(tx, &recurrence, &task)
+		}
+
+		recurrence := models.TaskRecurrence{
+			UserID:    userID,
+			Rule:      rule.String(),
+			Mode:      mode,
+			StartAt:   start,
+			Generated: 1,
+		}
+		if err := tx.Create(&recurrence).Error; err != nil {
+			return err
+		}
+		if err := copyTaskTemplate(tx, &recurrence, &task); err != nil {
+			return err
+		}
+		return tx.Model(&task).Updates(map[string]interface{}{"recurrence_id": recurrence.ID, "occurrence_index": 1}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.loadTask(userID, taskID)
+}
+
+// StopRecurrence ends the task's series; the task itself is kept
+func (s *TaskRecurrenceService) StopRecurrence(userID, taskID uint, now time.Time) error {
+	var task models.Task
+	if err := s.db.Where("id = ? AND user_id = ?", taskID, userID).First(&task).Error; err != nil {
+		return err
+	}
+	if task.RecurrenceID == nil {
+		return ErrTaskNotRecurring
+	}
+	return s.db.Model(&models.TaskRecurrence{}).Where("id = ?", *task.RecurrenceID).Update("ended_at", now).Error
+}
+
+// Complete marks an occurrence done and creates the next one, returning
+// both. next is nil for one-off tasks and series that have ended. Completing
+// again does not create a second follow-up.
+func (s *TaskRecurrenceService) Complete(userID, taskID uint, now time.Time) (*models.Task, *models.Task, error) {
+	var next *models.Task
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var task models.Task
+		if err := tx.Where("id = ? AND user_id = ?", taskID, userID).First(&task).Error; err != nil {
+			return err
+		}
+
+		if task.Status != models.TaskStatusCompleted || task.CompletedAt == nil {
+			task.Status = models.TaskStatusCompleted
+			task.CompletedAt = &now
+			if err := TrackTaskChanges(tx, userID, []uint{task.ID}, func() error {
+				return tx.Model(&models.Task{}).Where("id = ?", task.ID).Updates(map[string]interface{}{
+					"status":       models.TaskStatusCompleted,
+					"completed_at": now,
+					"progress":     100,
+				}).Error
+			}); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		next, err = createNextOccurrence(tx, &task, *task.CompletedAt)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	completed, err := s.loadTask(userID, taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if next != nil {
+		if next, err = s.loadTask(userID, next.ID); err != nil {
+			return nil, nil, err
+		}
+	}
+	return completed, next, nil
+}
+
+// ApplyToFuture copies the task's current fields into its series template
+// so later occurrences inherit the edit. If the occurrence was moved off
+// its scheduled date the schedule moves with it.
+func (s *TaskRecurrenceService) ApplyToFuture(userID, taskID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var task models.Task
+		if err := tx.Where("id = ? AND user_id = ?", taskID, userID).Preload("Tags").First(&task).Error; err != nil {
+			return err
+		}
+		if task.RecurrenceID == nil {
+			return ErrTaskNotRecurring
+		}
+
+		var recurrence models.TaskRecurrence
+		if err := tx.First(&recurrence, *task.RecurrenceID).Error; err != nil {
+			return err
+		}
+		if err := copyTaskTemplate(tx, &recurrence, &task); err != nil {
+			return err
+		}
+
+		if task.DueDate == nil || recurrence.Mode != models.RecurrenceFromDueDate {
+			return nil
+		}
+		rule, err := ParseRRule(recurrence.Rule, UserLocation(tx, userID))
+		if err != nil {
+			return err
+		}
+		scheduled, ok := rule.Nth(recurrence.StartAt.In(UserLocation(tx, userID)), recurrence.Generated)
+		if ok && scheduled.Equal(*task.DueDate) {
+			return nil
+		}
+		return tx.Model(&recurrence).Updates(map[string]interface{}{"start_at": *task.DueDate, "generated": 1}).Error
+	})
+}
+
+// Occurrences returns every occurrence of the task's series so far and a
+// preview of the next due dates
+func (s *TaskRecurrenceService) Occurrences(userID, taskID uint, upcoming int) (*TaskOccurrences, error) {
+	var task models.Task
+	if err := s.db.Where("id = ? AND user_id = ?", taskID, userID).First(&task).Error; err != nil {
+		return nil, err
+	}
+	if task.RecurrenceID == nil {
+		return nil, ErrTaskNotRecurring
+	}
+
+	var result TaskOccurrences
+	if err := s.db.Preload("Tags").First(&result.Recurrence, *task.RecurrenceID).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("recurrence_id = ? AND user_id = ?", *task.RecurrenceID, userID).
+		Order("occurrence_index ASC").Find(&result.History).Error; err != nil {
+		return nil, err
+	}
+
+	if upcoming <= 0 || result.Recurrence.EndedAt != nil {
+		return &result, nil
+	}
+	upcoming = min(upcoming, maxUpcomingOccurrences)
+
+	loc := UserLocation(s.db, userID)
+	rule, err := ParseRRule(result.Recurrence.Rule, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	// The latest open occurrence is where the schedule currently stands
+	from := result.Recurrence.StartAt
+	for _, occurrence := range result.History {
+		if occurrence.DueDate != nil && occurrence.DueDate.After(from) {
+			from = *occurrence.DueDate
+		}
+	}
+	start := result.Recurrence.StartAt.In(loc)
+	if result.Recurrence.Mode == models.RecurrenceFromCompletion {
+		start = from.In(loc)
+		if rule.Count > 0 {
+			// The open occurrence is the Generated-th of COUNT
+			rule.Count = max(rule.Count-result.Recurrence.Generated+1, 1)
+		}
+	}
+	result.Upcoming = rule.Between(start, from, upcoming)
+	return &result, nil
+}
+
+func (s *TaskRecurrenceService) loadTask(userID, taskID uint) (*models.Task, error) {
+	var task models.Task
+	if err := s.db.Where("id = ? AND user_id = ?", taskID, userID).Preload("Tags").Preload("Recurrence").First(&task).Error; err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// generateNextOccurrences creates follow-ups for recurring tasks that were
+// just completed in bulk
+func generateNextOccurrences(tx *gorm.DB, userID uint, taskIDs []uint, completedAt time.Time) error {
+	var tasks []models.Task
+	if err := tx.Where("id IN ? AND user_id = ? AND recurrence_id IS NOT NULL", taskIDs, userID).Find(&tasks).Error; err != nil {
+		return err
+	}
+	for i := range tasks {
+		if _, err := createNextOccurrence(tx, &tasks[i], completedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createNextOccurrence creates the occurrence that follows a completed one,
+// or ends the series when its rule has no further dates
+func createNextOccurrence(tx *gorm.DB, task *models.Task, completedAt time.Time) (*models.Task, error) {
+	if task.RecurrenceID == nil {
+		return nil, nil
+	}
+
+	var recurrence models.TaskRecurrence
+	if err := tx.Preload("Tags").First(&recurrence, *task.RecurrenceID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if recurrence.EndedAt != nil {
+		return nil, nil
+	}
+
+	var later int64
+	if err := tx.Model(&models.Task{}).Where("recurrence_id = ? AND occurrence_index > ?", recurrence.ID, task.OccurrenceIndex).
+		Count(&later).Error; err != nil {
+		return nil, err
+	}
+	if later > 0 {
+		return nil, nil
+	}
+
+	loc := UserLocation(tx, task.UserID)
+	rule, err := ParseRRule(recurrence.Rule, loc)
+	if err != nil {
+		return nil, fmt.Errorf("recurrence %d: %w", recurrence.ID, err)
+	}
+
+	start := recurrence.StartAt.In(loc)
+	var (
+		due time.Time
+		ok  bool
+	)
+	switch recurrence.Mode {
+	case models.RecurrenceFromCompletion:
+		if rule.Count == 0 || recurrence.Generated < rule.Count {
+			// Restart the rule on the completion day, at the usual time of day
+			done := completedAt.In(loc)
+			hour, minute, second := start.Clock()
+			anchor := time.Date(done.Year(), done.Month(), done.Day(), hour, minute, second, 0, loc)
+			rule.Count = 0
+			if dates := rule.Between(anchor, anchor, 1); len(dates) > 0 {
+				due, ok = dates[0], true
+			}
+		}
+	default:
+		due, ok = rule.Nth(start, recurrence.Generated+1)
+	}
+
+	if !ok {
+		return nil, tx.Model(&recurrence).Update("ended_at", completedAt).Error
+	}
+
+	next := models.Task{
+		UserID:          task.UserID,
+		Title:           recurrence.Title,
+		Description:     recurrence.Description,
+		Priority:        recurrence.Priority,
+		Status:          models.TaskStatusPending,
+		DueDate:         &due,
+		ParentTaskID:    recurrence.ParentTaskID,
+		RecurrenceID:    &recurrence.ID,
+		OccurrenceIndex: task.OccurrenceIndex + 1,
+		Tags:            recurrence.Tags,
+	}
+	if err := tx.Create(&next).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&recurrence).UpdateColumn("generated", gorm.Expr("generated + 1")).Error; err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// copyTaskTemplate stores the task's fields as the template for new occurrences
+func copyTaskTemplate(tx *gorm.DB, recurrence *models.TaskRecurrence, task *models.Task) error {
+	if err := tx.Model(recurrence).Updates(map[string]interface{}{
+		"title":          task.Title,
+		"description":    task.Description,
+		"priority":       task.Priority,
+		"parent_task_id": task.ParentTaskID,
+	}).Error; err != nil {
+		return err
+	}
+	return tx.Model(recurrence).Association("Tags").Replace(task.Tags)
+}
+
+func parseRecurrenceMode(mode string) (models.RecurrenceMode, error) {
+	switch models.RecurrenceMode(mode) {
+	case "", models.RecurrenceFromDueDate:
+		return models.RecurrenceFromDueDate, nil
+	case models.RecurrenceFromCompletion:
+		return models.RecurrenceFromCompletion, nil
+	default:
+		return "", ErrInvalidRecurrenceMode
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+func TestRRule(t *testing.T) {
+	prague, err := time.LoadLocation("Europe/Prague")
+	if err != nil {
+		t.Fatalf("failed to load timezone: %v", err)
+	}
+	// Thursday 2026-01-01 09:00
+	start := time.Date(2026, 1, 1, 9, 0, 0, 0, prague)
+
+	tests := []struct {
+		rule string
+		want []string
+	}{
+		{"FREQ=DAILY;INTERVAL=2;COUNT=3", []string{"2026-01-01", "2026-01-03", "2026-01-05"}},
+		{"RRULE:FREQ=WEEKLY;BYDAY=MO,TH;COUNT=4", []string{"2026-01-01", "2026-01-05", "2026-01-08", "2026-01-12"}},
+		{"FREQ=WEEKLY;INTERVAL=2;UNTIL=20260201", []string{"2026-01-01", "2026-01-15", "2026-01-29"}},
+		{"FREQ=MONTHLY;BYDAY=-1FR;COUNT=3", []string{"2026-01-01", "2026-01-30", "2026-02-27"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=31;COUNT=3", []string{"2026-01-01", "2026-01-31", "2026-03-31"}},
+		{"FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR;COUNT=3", []string{"2026-01-01", "2026-01-02", "2026-01-05"}},
+		{"FREQ=YEARLY;BYMONTH=3;BYMONTHDAY=29;COUNT=2", []string{"2026-01-01", "2026-03-29"}},
+		{"FREQ=YEARLY;BYDAY=MO;COUNT=3", []string{"2026-01-01", "2026-01-05", "2026-01-12"}},
+		{"FREQ=YEARLY;BYDAY=20MO;COUNT=3", []string{"2026-01-01", "2026-05-18", "2027-05-17"}},
+		{"FREQ=YEARLY;BYDAY=-1FR;COUNT=2", []string{"2026-01-01", "2026-12-25"}},
+		{"FREQ=YEARLY;BYMONTHDAY=15;COUNT=3", []string{"2026-01-01", "2026-01-15", "2026-02-15"}},
+	}
+	for _, tt := range tests {
+		rule, err := ParseRRule(tt.rule, prague)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.rule, err)
+		}
+		var got []string
+		rule.Iterate(start, func(_ int, at time.Time) bool {
+			got = append(got, at.Format("2006-01-02"))
+			if at.Hour() != 9 {
+				t.Fatalf("%s: occurrence %v lost the time of day", tt.rule, at)
+			}
+			return len(got) < 10
+		})
+		if len(got) != len(tt.want) {
+			t.Fatalf("%s: got %v, want %v", tt.rule, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Fatalf("%s: got %v, want %v", tt.rule, got, tt.want)
+			}
+		}
+	}
+
+	for _, invalid := range []string{"", "FREQ=HOURLY", "FREQ=DAILY;COUNT=0", "FREQ=WEEKLY;BYDAY=2MO", "FREQ=DAILY;COUNT=2;UNTIL=20260101", "FREQ=DAILY;BYSETPOS=1",
+		"FREQ=MONTHLY;BYDAY=6MO", "FREQ=YEARLY;BYMONTH=3;BYDAY=20MO", "FREQ=YEARLY;BYDAY=54MO"} {
+		if _, err := ParseRRule(invalid, prague); !errors.Is(err, ErrInvalidRRule) {
+			t.Fatalf("expected %q to be rejected, got %v", invalid, err)
+		}
+	}
+
+	rule, _ := ParseRRule("freq=monthly;byday=1mo,-1fr;interval=2;count=4", time.UTC)
+	if rule.String() != "FREQ=MONTHLY;INTERVAL=2;BYDAY=1MO,-1FR;COUNT=4" {
+		t.Fatalf("unexpected formatted rule %q", rule.String())
+	}
+}
+
+func TestTaskRecurrenceService(t *testing.T) {
+	db := newTestDB(t, &models.TaskPreference{}, &models.AuditLog{})
+
+	user := models.User{Email: "planner@example.com", Username: "planner", Password: "x", Timezone: "UTC"}
+	db.Create(&user)
+	tag, _ := FindOrCreateTag(db, user.ID, "chores")
+	due := time.Date(2026, 1, 5, 18, 0, 0, 0, time.UTC) // Monday
+	task := models.Task{UserID: user.ID, Title: "Take out trash", DueDate: &due, Tags: []models.Tag{*tag}}
+	db.Create(&task)
+
+	service := NewTaskRecurrenceService(db)
+	if _, err := service.SetRecurrence(user.ID, task.ID, TaskRecurrenceInput{Rule: "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=3"}, due); err != nil {
+		t.Fatalf("failed to set recurrence: %v", err)
+	}
+	if _, err := service.SetRecurrence(user.ID, task.ID, TaskRecurrenceInput{Rule: "FREQ=WEEKLY", Mode: "whenever"}, due); !errors.Is(err, ErrInvalidRecurrenceMode) {
+		t.Fatalf("expected invalid mode, got %v", err)
+	}
+
+	// Completing late still follows the schedule in due_date mode
+	completed, next, err := service.Complete(user.ID, task.ID, due.AddDate(0, 0, 5))
+	if err != nil {
+		t.Fatalf("failed to complete: %v", err)
+	}
+	if completed.Status != models.TaskStatusCompleted || next == nil || next.OccurrenceIndex != 2 ||
+		!next.DueDate.Equal(time.Date(2026, 1, 8, 18, 0, 0, 0, time.UTC)) || len(next.Tags) != 1 {
+		t.Fatalf("unexpected next occurrence: %+v", next)
+	}
+	if _, again, _ := service.Complete(user.ID, task.ID, due.AddDate(0, 0, 6)); again != nil {
+		t.Fatalf("completing twice created another occurrence: %+v", again)
+	}
+
+	// Editing "all future" changes the template; "this" does not
+	db.Model(&models.Task{}).Where("id = ?", next.ID).Update("title", "Take out recycling")
+	if err := service.ApplyToFuture(user.ID, next.ID); err != nil {
+		t.Fatalf("failed to apply to future: %v", err)
+	}
+	occurrences, err := service.Occurrences(user.ID, next.ID, 5)
+	if err != nil {
+		t.Fatalf("failed to list occurrences: %v", err)
+	}
+	if len(occurrences.History) != 2 || occurrences.Recurrence.Title != "Take out recycling" || len(occurrences.Upcoming) != 1 ||
+		!occurrences.Upcoming[0].Equal(time.Date(2026, 1, 12, 18, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected occurrences: %+v", occurrences)
+	}
+
+	// Bulk completion also moves the series on, and COUNT ends it
+	bulk := NewBulkService(db)
+	if _, err := bulk.Tasks(user.ID, BulkRequest{Action: BulkActionSetStatus, IDs: []uint{next.ID}, Status: "completed"}, nil); err != nil {
+		t.Fatalf("failed to complete in bulk: %v", err)
+	}
+	var third models.Task
+	if err := db.Where("recurrence_id = ? AND occurrence_index = ?", *next.RecurrenceID, 3).Preload("Tags").First(&third).Error; err != nil {
+		t.Fatalf("expected a third occurrence: %v", err)
+	}
+	if third.Title != "Take out recycling" {
+		t.Fatalf("expected the future edit to carry over, got %q", third.Title)
+	}
+	if _, last, _ := service.Complete(user.ID, third.ID, time.Date(2026, 1, 12, 19, 0, 0, 0, time.UTC)); last != nil {
+		t.Fatalf("expected COUNT to end the series, got %+v", last)
+	}
+	var recurrence models.TaskRecurrence
+	db.First(&recurrence, *next.RecurrenceID)
+	if recurrence.EndedAt == nil {
+		t.Fatalf("expected the series to be marked ended")
+	}
+
+	// Completion-date mode restarts the rule when the task is done
+	water := models.Task{UserID: user.ID, Title: "Water plants", DueDate: &due}
+	db.Create(&water)
+	service.SetRecurrence(user.ID, water.ID, TaskRecurrenceInput{Rule: "FREQ=DAILY;INTERVAL=3", Mode: "completion_date"}, due)
+	_, next, err = service.Complete(user.ID, water.ID, time.Date(2026, 1, 10, 8, 0, 0, 0, time.UTC))
+	if err != nil || next == nil || !next.DueDate.Equal(time.Date(2026, 1, 13, 18, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected next occurrence from completion date: %+v (err %v)", next, err)
+	}
+
+	if _, _, err := service.Complete(user.ID+1, water.ID, due); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected other users to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/trackeep/backend/models"
+)
+
+const taskwarriorTimeLayout = "20060102T150405Z"
+
+var taskwarriorPriorities = map[models.TaskPriority]string{
+	models.TaskPriorityUrgent: "H",
+	models.TaskPriorityHigh:   "H",
+	models.TaskPriorityMedium: "M",
+	models.TaskPriorityLow:    "L",
+}
+
+// taskwarriorTask is one task in the format of `task export`. TrackeepID is
+// a user-defined attribute that Taskwarrior carries through import and
+// export, so a file exported from here imports back onto the same tasks.
+type taskwarriorTask struct {
+	UUID        string                  `json:"uuid"`
+	Description string                  `json:"description"`
+	Status      string                  `json:"status"`
+	Entry       string                  `json:"entry,omitempty"`
+	Modified    string                  `json:"modified,omitempty"`
+	Start       string                  `json:"start,omitempty"`
+	End         string                  `json:"end,omitempty"`
+	Due         string                  `json:"due,omitempty"`
+	Project     string                  `json:"project,omitempty"`
+	Priority    string                  `json:"priority,omitempty"`
+	Tags        []string                `json:"tags,omitempty"`
+	Depends     taskwarriorDepends      `json:"depends,omitempty"`
+	Annotations []taskwarriorAnnotation `json:"annotations,omitempty"`
+	TrackeepID  uint                    `json:"trackeep_id,omitempty"`
+}
+
+type taskwarriorAnnotation struct {
+	Entry       string `json:"entry"`
+	Description string `json:"description"`
+}
+
+// taskwarriorDepends reads both the comma-separated string of Taskwarrior
+// 2.5 and the array written since 2.6
+type taskwarriorDepends []string
+
+func (d *taskwarriorDepends) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*d = list
+		return nil
+	}
+	var joined string
+	if err := json.Unmarshal(data, &joined); err != nil {
+		return err
+	}
+	*d = nil
+	for _, uuid := range strings.Split(joined, ",") {
+		if uuid = strings.TrimSpace(uuid); uuid != "" {
+			*d = append(*d, uuid)
+		}
+	}
+	return nil
+}
+
+// ParseTaskwarriorExport reads the JSON array written by `task export`.
+// Recurring templates are left out; their pending instances are imported as
+// ordinary tasks.
+func ParseTaskwarriorExport(r io.Reader) ([]ImportedTask, error) {
+	var tasks []taskwarriorTask
+	if err := json.NewDecoder(r).Decode(&tasks); err != nil {
+		return nil, fmt.Errorf("failed to parse Taskwarrior export: %w", err)
+	}
+
+	items := make([]ImportedTask, 0, len(tasks))
+	for _, task := range tasks {
+		item := ImportedTask{
+			ExternalID: task.UUID,
+			DependsOn:  task.Depends,
+			TaskID:     task.TrackeepID,
+			Title:      task.Description,
+			Priority:   taskwarriorPriority(task.Priority),
+			DueDate:    parseTaskwarriorTime(task.Due),
+			Tags:       task.Tags,
+		}
+		switch task.Status {
+		case "recurring":
+			continue
+		case "completed":
+			item.Status = models.TaskStatusCompleted
+			item.CompletedAt = parseTaskwarriorTime(task.End)
+		case "deleted":
+			item.Status = models.TaskStatusCancelled
+		default:
+			item.Status = models.TaskStatusPending
+			if task.Start != "" {
+				item.Status = models.TaskStatusInProgress
+			}
+		}
+		if task.Project != "" {
+			item.Tags = append([]string{strings.ReplaceAll(task.Project, ".", "/")}, item.Tags...)
+		}
+		notes := make([]string, 0, len(task.Annotations))
+		for _, annotation := range task.Annotations {
+			notes = append(notes, annotation.Description)
+		}
+		item.Description = strings.Join(notes, "\n")
+
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// ExportTaskwarrior writes the user's tasks in the format `task import`
+// reads. Tasks imported from Taskwarrior keep their UUID; others get a
+// stable one derived from their ID. Taskwarrior has no urgent priority, so
+// urgent tasks export as H.
+func (s *TaskImportService) ExportTaskwarrior(userID uint) ([]byte, error) {
+	var tasks []models.Task
+	if err := s.db.Where("user_id = ?", userID).Preload("Tags").Preload("Dependencies").Order("id").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	uuids := make(map[uint]string, len(tasks))
+	for _, task := range tasks {
+		uuids[task.ID] = taskwarriorUUID(task)
+	}
+
+	export := make([]taskwarriorTask, 0, len(tasks))
+	for _, task := range tasks {
+		entry := formatTaskwarriorTime(&task.CreatedAt)
+		item := taskwarriorTask{
+			UUID:        uuids[task.ID],
+			Description: task.Title,
+			Status:      "pending",
+			Entry:       entry,
+			Modified:    formatTaskwarriorTime(&task.UpdatedAt),
+			Due:         formatTaskwarriorTime(task.DueDate),
+			Priority:    taskwarriorPriorities[task.Priority],
+			TrackeepID:  task.ID,
+		}
+		switch task.Status {
+		case models.TaskStatusInProgress:
+			item.Start = item.Modified
+		case models.TaskStatusCompleted:
+			item.Status = "completed"
+			item.End = item.Modified
+			if task.CompletedAt != nil {
+				item.End = formatTaskwarriorTime(task.CompletedAt)
+			}
+		case models.TaskStatusCancelled:
+			item.Status, item.End = "deleted", item.Modified
+		}
+		for _, tag := range task.Tags {
+			// Taskwarrior tags are single words
+			item.Tags = append(item.Tags, strings.ReplaceAll(tag.Name, " ", "_"))
+		}
+		for _, dependency := range task.Dependencies {
+			if uuid, ok := uuids[dependency.ID]; ok {
+				item.Depends = append(item.Depends, uuid)
+			}
+		}
+		if task.Description != "" {
+			item.Annotations = []taskwarriorAnnotation{{Entry: entry, Description: task.Description}}
+		}
+		export = append(export, item)
+	}
+	return json.MarshalIndent(export, "", "  ")
+}
+
+func taskwarriorUUID(task models.Task) string {
+	if task.ExternalSource == TaskSourceTaskwarrior && task.ExternalID != "" {
+		return task.ExternalID
+	}
+	// Name-based (version 5) UUID, so exporting twice gives the same IDs
+	sum := sha1.Sum([]byte(fmt.Sprintf("trackeep:task:%d", task.ID)))
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+func taskwarriorPriority(priority string) models.TaskPriority {
+	switch priority {
+	case "H":
+		return models.TaskPriorityHigh
+	case "L":
+		return models.TaskPriorityLow
+	default:
+		return models.TaskPriorityMedium
+	}
+}
+
+func parseTaskwarriorTime(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	t, err := time.Parse(taskwarriorTimeLayout, value)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+func formatTaskwarriorTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(taskwarriorTimeLayout)
+}
@@ -0,0 +1,623 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidTemplate   = errors.New("invalid template")
+	ErrTemplateVariables = errors.New("invalid template variables")
+	ErrNotInstallable    = errors.New("only note templates can be installed")
+	ErrPurchaseRequired  = errors.New("this item has to be bought first")
+)
+
+// Template variable types
+const (
+	TemplateVarText   = "text"
+	TemplateVarNumber = "number"
+	TemplateVarDate   = "date"
+	TemplateVarSelect = "select"
+)
+
+// Where a template can be instantiated
+const (
+	TemplateTargetNote = "note"
+	TemplateTargetWiki = "wiki"
+)
+
+// MarketplaceNoteTemplate is the marketplace content type of templates
+const MarketplaceNoteTemplate = "note_template"
+
+// templateDateLayout is how date variables are given and rendered
+const templateDateLayout = "2006-01-02"
+
+var (
+	templatePlaceholder  = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.]*)\s*\}\}`)
+	templateVariableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	slugInvalid          = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// templateBuiltins are filled in by the server and cannot be declared as
+// variables. Dotted names such as user.name are reserved by the syntax.
+var templateBuiltins = []string{"today", "yesterday", "tomorrow", "now", "time", "weekday", "year", "month", "title"}
+
+// TemplateInput is the editable part of a template. Variables replace the
+// template's variables when set.
+type TemplateInput struct {
+	Name        string                    `json:"name" binding:"required"`
+	Description string                    `json:"description"`
+	Content     string                    `json:"content"`
+	Category    string                    `json:"category"`
+	IsPublic    bool                      `json:"is_public"`
+	Variables   []models.TemplateVariable `json:"variables"`
+}
+
+// TemplateInstantiation asks for a note or wiki page made from a template.
+// Title and content may both use variables; the title defaults to the
+// template's name.
+type TemplateInstantiation struct {
+	Target     string                 `json:"target"`
+	Title      string                 `json:"title"`
+	Values     map[string]interface{} `json:"values"`
+	ParentID   *uint                  `json:"parent_id"`
+	CategoryID *uint                  `json:"category_id"`
+}
+
+// TemplateInstance is what a template was instantiated as
+type TemplateInstance struct {
+	Target   string           `json:"target"`
+	Note     *models.Note     `json:"note,omitempty"`
+	WikiPage *models.WikiPage `json:"wiki_page,omitempty"`
+}
+
+// TemplateService manages note and wiki page templates
+type TemplateService struct {
+	db *gorm.DB
+}
+
+// NewTemplateService creates a template service
+func NewTemplateService(db *gorm.DB) *TemplateService {
+	return &TemplateService{db: db}
+}
+
+// List returns the user's templates, and public ones if asked, most used
+// first
+func (s *TemplateService) List(userID uint, includePublic bool, category string) ([]models.Template, error) {
+	query := s.db.Preload("Variables").Where("user_id = ?", userID)
+	if includePublic {
+		query = s.db.Preload("Variables").Where(s.visibleTemplates(userID))
+	}
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+
+	var templates []models.Template
+	if err := query.Order("usage_count DESC, name").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// Get returns a template the user owns or that is public
+func (s *TemplateService) Get(userID, templateID uint) (*models.Template, error) {
+	var template models.Template
+	if err := s.db.Preload("Variables").Where("id = ?", templateID).Where(s.visibleTemplates(userID)).
+		First(&template).Error; err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// visibleTemplates matches the user's own templates and public ones. A
+// public template sold as a paid marketplace item is only available to
+// buyers, through Install.
+func (s *TemplateService) visibleTemplates(userID uint) *gorm.DB {
+	paid := s.db.Model(&models.MarketplaceItem{}).Select("content_id").
+		Where("content_type = ? AND content_id IS NOT NULL AND is_free = ? AND price > 0", MarketplaceNoteTemplate, false)
+	return s.db.Where("user_id = ?", userID).Or("is_public = ? AND id NOT IN (?)", true, paid)
+}
+
+// Create adds a template
+func (s *TemplateService) Create(userID uint, input *TemplateInput) (*models.Template, error) {
+	variables, err := checkTemplateInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	template := models.Template{
+		UserID:      userID,
+		Name:        strings.TrimSpace(input.Name),
+		Description: input.Description,
+		Content:     input.Content,
+		Category:    input.Category,
+		IsPublic:    input.IsPublic,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if template.Slug, err = uniqueSlug(tx, &models.Template{}, template.Name, 0); err != nil {
+			return err
+		}
+		if err := tx.Omit("Variables").Create(&template).Error; err != nil {
+			return err
+		}
+		return replaceTemplateVariables(tx, &template, variables)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// Update changes a template the user owns
+func (s *TemplateService) Update(userID, templateID uint, input *TemplateInput) (*models.Template, error) {
+	variables, err := checkTemplateInput(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var template models.Template
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Variables").Where("id = ? AND user_id = ?", templateID, userID).First(&template).Error; err != nil {
+			return err
+		}
+		name := strings.TrimSpace(input.Name)
+		if name != template.Name {
+			if template.Slug, err = uniqueSlug(tx, &models.Template{}, name, template.ID); err != nil {
+				return err
+			}
+		}
+		template.Name = name
+		template.Description = input.Description
+		template.Content = input.Content
+		template.Category = input.Category
+		template.IsPublic = input.IsPublic
+		if err := tx.Omit("Variables").Save(&template).Error; err != nil {
+			return err
+		}
+		if input.Variables == nil {
+			return nil
+		}
+		return replaceTemplateVariables(tx, &template, variables)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// Delete removes a template the user owns
+func (s *TemplateService) Delete(userID, templateID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", templateID, userID).Delete(&models.Template{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("template_id = ?", templateID).Delete(&models.TemplateVariable{}).Error
+	})
+}
+
+// Instantiate creates a note or wiki page from a template with the given
+// variable values and counts the use
+func (s *TemplateService) Instantiate(userID, templateID uint, req *TemplateInstantiation, now time.Time) (*TemplateInstance, error) {
+	template, err := s.Get(userID, templateID)
+	if err != nil {
+		return nil, err
+	}
+	target := req.Target
+	if target == "" {
+		target = TemplateTargetNote
+	}
+	if target != TemplateTargetNote && target != TemplateTargetWiki {
+		return nil, fmt.Errorf("%w: target must be %q or %q", ErrTemplateVariables, TemplateTargetNote, TemplateTargetWiki)
+	}
+
+	var user models.User
+	if err := s.db.First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	values, err := templateValues(template.Variables, req.Values)
+	if err != nil {
+		return nil, err
+	}
+	addTemplateBuiltins(values, &user, now)
+
+	titleSource := req.Title
+	if strings.TrimSpace(titleSource) == "" {
+		titleSource = template.Name
+	}
+	title := strings.TrimSpace(renderTemplate(titleSource, values))
+	values["title"] = title
+	content := renderTemplate(template.Content, values)
+
+	instance := &TemplateInstance{Target: target}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if target == TemplateTargetNote {
+			note, err := s.createNote(tx, userID, title, content, req.ParentID, now)
+			if err != nil {
+				return err
+			}
+			instance.Note = note
+		} else {
+			page, err := s.createWikiPage(tx, userID, title, content, req.CategoryID, req.ParentID)
+			if err != nil {
+				return err
+			}
+			instance.WikiPage = page
+		}
+		return tx.Model(&models.Template{}).Where("id = ?", template.ID).
+			UpdateColumn("usage_count", gorm.Expr("usage_count + 1")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return instance, nil
+}
+
+func (s *TemplateService) createNote(tx *gorm.DB, userID uint, title, content string, parentID *uint, now time.Time) (*models.Note, error) {
+	if parentID != nil {
+		var parent models.Note
+		if err := tx.Select("id").Where("id = ? AND user_id = ?", *parentID, userID).First(&parent).Error; err != nil {
+			return nil, err
+		}
+	}
+	note := models.Note{UserID: userID, Title: title, Content: content, ParentNoteID: parentID}
+	if err := tx.Create(&note).Error; err != nil {
+		return nil, err
+	}
+	if _, err := NewNoteRevisionService(tx).Record(&note, userID, now); err != nil {
+		return nil, err
+	}
+	if err := NewNoteLinkService(tx).Reindex(&note); err != nil {
+		return nil, err
+	}
+	return &note, nil
+}
+
+func (s *TemplateService) createWikiPage(tx *gorm.DB, userID uint, title, content string, categoryID, parentID *uint) (*models.WikiPage, error) {
+	if parentID != nil {
+		var parent models.WikiPage
+		if err := tx.Select("id").Where("id = ? AND user_id = ?", *parentID, userID).First(&parent).Error; err != nil {
+			return nil, err
+		}
+	}
+	slug, err := uniqueSlug(tx, &models.WikiPage{}, title, 0)
+	if err != nil {
+		return nil, err
+	}
+	words := len(strings.Fields(content))
+	page := models.WikiPage{
+		UserID:      userID,
+		Title:       title,
+		Slug:        slug,
+		Content:     content,
+		CategoryID:  categoryID,
+		ParentID:    parentID,
+		Status:      "draft",
+		WordCount:   words,
+		ReadingTime: max(1, words/225),
+	}
+	if err := tx.Create(&page).Error; err != nil {
+		return nil, err
+	}
+	// Pages start with a first version, as the wiki handlers create them
+	if err := tx.Create(&models.WikiVersion{
+		WikiPageID:    page.ID,
+		VersionNumber: 1,
+		Title:         page.Title,
+		Content:       page.Content,
+		ChangeLog:     "Initial version",
+		AuthorID:      userID,
+		WordCount:     page.WordCount,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Publish lists a template the user owns in the marketplace. Like other
+// items it starts as a draft awaiting approval.
+func (s *TemplateService) Publish(userID, templateID uint, price float64) (*models.MarketplaceItem, error) {
+	var template models.Template
+	if err := s.db.Where("id = ? AND user_id = ?", templateID, userID).First(&template).Error; err != nil {
+		return nil, err
+	}
+	if price < 0 {
+		return nil, fmt.Errorf("%w: price cannot be negative", ErrInvalidTemplate)
+	}
+
+	item := models.MarketplaceItem{
+		SellerID:    userID,
+		Title:       template.Name,
+		Description: template.Description,
+		Category:    "template",
+		ContentType: MarketplaceNoteTemplate,
+		ContentID:   &template.ID,
+		Price:       price,
+		IsFree:      price == 0,
+		Status:      "draft",
+	}
+	if err := s.db.Create(&item).Error; err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// Install copies the template of a marketplace item into the user's
+// templates. Paid items need a completed purchase; installing again
+// returns the copy made before.
+func (s *TemplateService) Install(userID, itemID uint) (*models.Template, error) {
+	var item models.MarketplaceItem
+	if err := s.db.First(&item, itemID).Error; err != nil {
+		return nil, err
+	}
+	seller := item.SellerID == userID
+	if !seller && (item.Status != "published" || !item.IsApproved) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if item.ContentType != MarketplaceNoteTemplate || item.ContentID == nil {
+		return nil, ErrNotInstallable
+	}
+	if !seller && !item.IsFree && item.Price > 0 {
+		var purchases int64
+		if err := s.db.Model(&models.MarketplacePurchase{}).
+			Where("item_id = ? AND buyer_id = ? AND status = ? AND access_granted = ?", item.ID, userID, "completed", true).
+			Count(&purchases).Error; err != nil {
+			return nil, err
+		}
+		if purchases == 0 {
+			return nil, ErrPurchaseRequired
+		}
+	}
+
+	var installed models.Template
+	err := s.db.Preload("Variables").Where("user_id = ? AND source_item_id = ?", userID, item.ID).First(&installed).Error
+	if err == nil {
+		return &installed, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var source models.Template
+	if err := s.db.Preload("Variables").First(&source, *item.ContentID).Error; err != nil {
+		return nil, err
+	}
+	installed = models.Template{
+		UserID:       userID,
+		Name:         source.Name,
+		Description:  source.Description,
+		Content:      source.Content,
+		Category:     source.Category,
+		SourceItemID: &item.ID,
+	}
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if installed.Slug, err = uniqueSlug(tx, &models.Template{}, installed.Name, 0); err != nil {
+			return err
+		}
+		if err := tx.Omit("Variables").Create(&installed).Error; err != nil {
+			return err
+		}
+		if err := replaceTemplateVariables(tx, &installed, source.Variables); err != nil {
+			return err
+		}
+		return tx.Model(&item).UpdateColumn("download_count", gorm.Expr("download_count + 1")).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &installed, nil
+}
+
+// checkTemplateInput validates a template's name and variables and returns
+// the variables cleaned up
+func checkTemplateInput(input *TemplateInput) ([]models.TemplateVariable, error) {
+	if strings.TrimSpace(input.Name) == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidTemplate)
+	}
+
+	variables := make([]models.TemplateVariable, 0, len(input.Variables))
+	seen := map[string]bool{}
+	for _, variable := range input.Variables {
+		name := strings.TrimSpace(variable.Name)
+		switch {
+		case !templateVariableName.MatchString(name):
+			return nil, fmt.Errorf("%w: %q is not a valid variable name", ErrInvalidTemplate, name)
+		case slices.Contains(templateBuiltins, name):
+			return nil, fmt.Errorf("%w: %q is a built-in variable", ErrInvalidTemplate, name)
+		case seen[name]:
+			return nil, fmt.Errorf("%w: variable %q is declared twice", ErrInvalidTemplate, name)
+		}
+		seen[name] = true
+
+		if variable.Type == "" {
+			variable.Type = TemplateVarText
+		}
+		if variable.Type == TemplateVarSelect && len(variable.Options) == 0 {
+			return nil, fmt.Errorf("%w: select variable %q needs options", ErrInvalidTemplate, name)
+		}
+		if variable.Type != TemplateVarSelect {
+			variable.Options = nil
+		}
+		if variable.DefaultValue != "" {
+			value, err := checkTemplateValue(&variable, variable.DefaultValue)
+			if err != nil {
+				return nil, fmt.Errorf("%w: default of %q: %v", ErrInvalidTemplate, name, err)
+			}
+			variable.DefaultValue = value
+		}
+
+		variables = append(variables, models.TemplateVariable{
+			Name:         name,
+			Type:         variable.Type,
+			DefaultValue: variable.DefaultValue,
+			Required:     variable.Required,
+			Description:  variable.Description,
+			Options:      variable.Options,
+		})
+	}
+	return variables, nil
+}
+
+// checkTemplateValue validates a value for a variable and returns it as it
+// is rendered
+func checkTemplateValue(variable *models.TemplateVariable, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch variable.Type {
+	case TemplateVarText:
+		return value, nil
+	case TemplateVarNumber:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return "", fmt.Errorf("%q is not a number", value)
+		}
+		return strconv.FormatFloat(number, 'f', -1, 64), nil
+	case TemplateVarDate:
+		if _, err := time.Parse(templateDateLayout, value); err != nil {
+			return "", fmt.Errorf("%q is not a date like 2006-01-02", value)
+		}
+		return value, nil
+	case TemplateVarSelect:
+		if !slices.Contains(variable.Options, value) {
+			return "", fmt.Errorf("%q is not one of %s", value, strings.Join(variable.Options, ", "))
+		}
+		return value, nil
+	}
+	return "", fmt.Errorf("unknown type %q", variable.Type)
+}
+
+// templateValues checks supplied values against a template's variables,
+// filling in defaults. Every problem is reported at once.
+func templateValues(variables []models.TemplateVariable, supplied map[string]interface{}) (map[string]string, error) {
+	values := map[string]string{}
+	var problems []string
+	declared := map[string]bool{}
+	for i := range variables {
+		variable := &variables[i]
+		declared[variable.Name] = true
+
+		raw, ok := supplied[variable.Name]
+		value := ""
+		if ok && raw != nil {
+			switch v := raw.(type) {
+			case string:
+				value = v
+			case float64:
+				value = strconv.FormatFloat(v, 'f', -1, 64)
+			default:
+				value = fmt.Sprint(v)
+			}
+		}
+		if strings.TrimSpace(value) == "" {
+			value = variable.DefaultValue
+		}
+		if strings.TrimSpace(value) == "" {
+			if variable.Required {
+				problems = append(problems, fmt.Sprintf("%s is required", variable.Name))
+			}
+			values[variable.Name] = ""
+			continue
+		}
+
+		checked, err := checkTemplateValue(variable, value)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", variable.Name, err))
+			continue
+		}
+		values[variable.Name] = checked
+	}
+	for name := range supplied {
+		if !declared[name] {
+			problems = append(problems, fmt.Sprintf("%s is not a variable of this template", name))
+		}
+	}
+
+	if len(problems) > 0 {
+		slices.Sort(problems)
+		return nil, fmt.Errorf("%w: %s", ErrTemplateVariables, strings.Join(problems, "; "))
+	}
+	return values, nil
+}
+
+// addTemplateBuiltins adds the server-filled variables, with dates in the
+// user's time zone
+func addTemplateBuiltins(values map[string]string, user *models.User, now time.Time) {
+	if location, err := time.LoadLocation(user.Timezone); err == nil && user.Timezone != "" {
+		now = now.In(location)
+	}
+	values["today"] = now.Format(templateDateLayout)
+	values["yesterday"] = now.AddDate(0, 0, -1).Format(templateDateLayout)
+	values["tomorrow"] = now.AddDate(0, 0, 1).Format(templateDateLayout)
+	values["now"] = now.Format("2006-01-02 15:04")
+	values["time"] = now.Format("15:04")
+	values["weekday"] = now.Weekday().String()
+	values["year"] = now.Format("2006")
+	values["month"] = now.Format("January")
+
+	name := user.FullName
+	if name == "" {
+		name = user.Username
+	}
+	values["user.name"] = name
+	values["user.username"] = user.Username
+	values["user.email"] = user.Email
+}
+
+// renderTemplate fills in {{name}} placeholders. Unknown names are left as
+// they are, so text that merely looks like a placeholder survives.
+func renderTemplate(text string, values map[string]string) string {
+	return templatePlaceholder.ReplaceAllStringFunc(text, func(match string) string {
+		name := templatePlaceholder.FindStringSubmatch(match)[1]
+		if value, ok := values[name]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// replaceTemplateVariables swaps a template's variables for new ones
+func replaceTemplateVariables(tx *gorm.DB, template *models.Template, variables []models.TemplateVariable) error {
+	if err := tx.Where("template_id = ?", template.ID).Delete(&models.TemplateVariable{}).Error; err != nil {
+		return err
+	}
+	template.Variables = make([]models.TemplateVariable, 0, len(variables))
+	for _, variable := range variables {
+		variable.ID = 0
+		variable.TemplateID = template.ID
+		if err := tx.Create(&variable).Error; err != nil {
+			return err
+		}
+		template.Variables = append(template.Variables, variable)
+	}
+	return nil
+}
+
+// uniqueSlug makes a slug from a name that no other row of the model uses,
+// counting up from "name-2". Soft-deleted rows still hold their slugs.
+func uniqueSlug(tx *gorm.DB, model interface{}, name string, excludeID uint) (string, error) {
+	base := strings.Trim(slugInvalid.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if base == "" {
+		base = "untitled"
+	}
+	slug := base
+	for n := 2; ; n++ {
+		var count int64
+		if err := tx.Unscoped().Model(model).Where("slug = ? AND id <> ?", slug, excludeID).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, n)
+	}
+}
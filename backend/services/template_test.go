@@ -0,0 +1,141 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/gorm"
+)
+
+func TestTemplateService(t *testing.T) {
+	db := newTestDB(t, &models.NoteRevision{}, &models.NoteLink{}, &models.WikiPage{}, &models.WikiVersion{},
+		&models.Template{}, &models.TemplateVariable{}, &models.MarketplaceItem{}, &models.MarketplacePurchase{})
+
+	author := models.User{Email: "author@example.com", Username: "author", FullName: "Ada Author", Password: "x", GitHubID: 1, Timezone: "Europe/Prague"}
+	buyer := models.User{Email: "buyer@example.com", Username: "buyer", Password: "x", GitHubID: 2}
+	db.Create(&author)
+	db.Create(&buyer)
+	service := NewTemplateService(db)
+	now := time.Date(2026, 5, 4, 23, 30, 0, 0, time.UTC)
+
+	invalid := []TemplateInput{
+		{Name: "Bad", Variables: []models.TemplateVariable{{Name: "today"}}},
+		{Name: "Bad", Variables: []models.TemplateVariable{{Name: "a b"}}},
+		{Name: "Bad", Variables: []models.TemplateVariable{{Name: "x"}, {Name: "x"}}},
+		{Name: "Bad", Variables: []models.TemplateVariable{{Name: "kind", Type: TemplateVarSelect}}},
+		{Name: "Bad", Variables: []models.TemplateVariable{{Name: "n", Type: TemplateVarNumber, DefaultValue: "many"}}},
+	}
+	for _, input := range invalid {
+		if _, err := service.Create(author.ID, &input); !errors.Is(err, ErrInvalidTemplate) {
+			t.Fatalf("expected %+v to be refused, got %v", input.Variables, err)
+		}
+	}
+
+	template, err := service.Create(author.ID, &TemplateInput{
+		Name:    "Meeting notes",
+		Content: "# {{ title }}\nDate: {{date}} ({{today}})\nBy {{user.name}}\nAttendees: {{attendees}}\nKind: {{kind}}\n{{unknown}}",
+		Variables: []models.TemplateVariable{
+			{Name: "date", Type: TemplateVarDate, Required: true},
+			{Name: "attendees", Type: TemplateVarNumber, DefaultValue: "3"},
+			{Name: "kind", Type: TemplateVarSelect, Options: []string{"standup", "review"}, DefaultValue: "standup"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("failed to create template: %v", err)
+	}
+	if other, _ := service.Create(author.ID, &TemplateInput{Name: "Meeting notes"}); other.Slug != "meeting-notes-2" {
+		t.Fatalf("expected a unique slug, got %q", other.Slug)
+	}
+
+	// Values are checked all at once
+	_, err = service.Instantiate(author.ID, template.ID, &TemplateInstantiation{
+		Values: map[string]interface{}{"attendees": "lots", "kind": "retro", "extra": "x"},
+	}, now)
+	if !errors.Is(err, ErrTemplateVariables) || strings.Count(err.Error(), ";") != 3 {
+		t.Fatalf("expected every problem to be reported, got %v", err)
+	}
+
+	instance, err := service.Instantiate(author.ID, template.ID, &TemplateInstantiation{
+		Title:  "Sync {{date}}",
+		Values: map[string]interface{}{"date": "2026-05-06", "attendees": float64(5)},
+	}, now)
+	if err != nil {
+		t.Fatalf("failed to instantiate: %v", err)
+	}
+	want := "# Sync 2026-05-06\nDate: 2026-05-06 (2026-05-05)\nBy Ada Author\nAttendees: 5\nKind: standup\n{{unknown}}"
+	if instance.Note == nil || instance.Note.Title != "Sync 2026-05-06" || instance.Note.Content != want {
+		t.Fatalf("unexpected note: %+v", instance.Note)
+	}
+	var revisions int64
+	db.Model(&models.NoteRevision{}).Where("note_id = ?", instance.Note.ID).Count(&revisions)
+	if revisions != 1 {
+		t.Fatalf("expected the note to get its first revision, got %d", revisions)
+	}
+
+	instance, err = service.Instantiate(author.ID, template.ID, &TemplateInstantiation{
+		Target: TemplateTargetWiki,
+		Values: map[string]interface{}{"date": "2026-05-07"},
+	}, now)
+	if err != nil || instance.WikiPage == nil || instance.WikiPage.Title != "Meeting notes" {
+		t.Fatalf("unexpected wiki page: %+v (err %v)", instance, err)
+	}
+	var versions int64
+	db.Model(&models.WikiVersion{}).Where("wiki_page_id = ?", instance.WikiPage.ID).Count(&versions)
+	db.First(template, template.ID)
+	if versions != 1 || template.UsageCount != 2 {
+		t.Fatalf("expected a first version and two uses, got %d / %d", versions, template.UsageCount)
+	}
+
+	// Options saved as a single string before they became a list still load
+	db.Exec("UPDATE template_variables SET options = ? WHERE template_id = ? AND name = ?",
+		`"standup, review\nretro"`, template.ID, "kind")
+	var kind models.TemplateVariable
+	if err := db.Where("template_id = ? AND name = ?", template.ID, "kind").First(&kind).Error; err != nil {
+		t.Fatalf("failed to load a legacy select variable: %v", err)
+	}
+	if strings.Join(kind.Options, "|") != "standup|review|retro" {
+		t.Fatalf("unexpected legacy options: %q", kind.Options)
+	}
+
+	// Private templates stay private until shared through the marketplace
+	if _, err := service.Instantiate(buyer.ID, template.ID, &TemplateInstantiation{}, now); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected a private template to be hidden, got %v", err)
+	}
+	item, err := service.Publish(author.ID, template.ID, 4.99)
+	if err != nil || item.ContentType != MarketplaceNoteTemplate || item.IsFree {
+		t.Fatalf("unexpected marketplace item: %+v (err %v)", item, err)
+	}
+	// Making a paid template public does not get around the purchase
+	db.Model(template).Update("is_public", true)
+	if _, err := service.Instantiate(buyer.ID, template.ID, &TemplateInstantiation{}, now); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected a paid public template to be hidden, got %v", err)
+	}
+	service.Create(author.ID, &TemplateInput{Name: "Free for all", IsPublic: true})
+	if listed, err := service.List(buyer.ID, true, ""); err != nil || len(listed) != 1 || listed[0].Name != "Free for all" {
+		t.Fatalf("expected only the free public template for the buyer, got %+v (err %v)", listed, err)
+	}
+	if _, err := service.Get(author.ID, template.ID); err != nil {
+		t.Fatalf("expected the author to keep access, got %v", err)
+	}
+	if _, err := service.Install(buyer.ID, item.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expected an unapproved item to be hidden, got %v", err)
+	}
+	db.Model(item).Updates(map[string]interface{}{"status": "published", "is_approved": true})
+	if _, err := service.Install(buyer.ID, item.ID); !errors.Is(err, ErrPurchaseRequired) {
+		t.Fatalf("expected a paid item to need a purchase, got %v", err)
+	}
+	db.Create(&models.MarketplacePurchase{ItemID: item.ID, BuyerID: buyer.ID, Price: 4.99, TransactionID: "tx1", Status: "completed", AccessGranted: true})
+	installed, err := service.Install(buyer.ID, item.ID)
+	if err != nil || installed.UserID != buyer.ID || len(installed.Variables) != 3 || installed.Slug != "meeting-notes-3" {
+		t.Fatalf("unexpected installed template: %+v (err %v)", installed, err)
+	}
+	if again, err := service.Install(buyer.ID, item.ID); err != nil || again.ID != installed.ID {
+		t.Fatalf("expected installing again to return the copy: %+v (err %v)", again, err)
+	}
+	if _, err := service.Instantiate(buyer.ID, installed.ID, &TemplateInstantiation{Values: map[string]interface{}{"date": "2026-05-08"}}, now); err != nil {
+		t.Fatalf("failed to use the installed template: %v", err)
+	}
+}
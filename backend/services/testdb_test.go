@@ -0,0 +1,30 @@
+package services
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/trackeep/backend/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// newTestDB opens an in-memory sqlite database private to the test. The
+// core content models are always migrated, followed by the given ones.
+func newTestDB(t *testing.T, migrate ...interface{}) *gorm.DB {
+	t.Helper()
+
+	dsn := "file:" + url.PathEscape(t.Name()) + "?mode=memory&cache=shared"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open sqlite database: %v", err)
+	}
+
+	core := []interface{}{&models.User{}, &models.Tag{}, &models.TagAlias{}, &models.TaskRecurrence{}, &models.Task{},
+		&models.TaskChange{}, &models.Bookmark{}, &models.Note{}}
+	if err := db.AutoMigrate(append(core, migrate...)...); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+
+	return db
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Diff operations
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// maxDiffEdits bounds the work of one diff. Texts that differ by more edits
+// are shown as the old text removed and the new text added.
+const maxDiffEdits = 1000
+
+var diffWordPattern = regexp.MustCompile(`\s+|[\p{L}\p{N}_]+|[^\s\p{L}\p{N}_]+`)
+
+// DiffOp is a run of text that is the same in both versions, only in the
+// new one (insert) or only in the old one (delete). Joining the equal and
+// delete runs gives the old text; equal and insert runs give the new one.
+type DiffOp struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// splitDiffLines splits text into lines, keeping the line breaks
+func splitDiffLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.SplitAfter(text, "\n")
+}
+
+// splitDiffWords splits text into words, punctuation and the whitespace
+// between them
+func splitDiffWords(text string) []string {
+	return diffWordPattern.FindAllString(text, -1)
+}
+
+// diffBuilder collects diff operations, merging runs of the same kind
+type diffBuilder struct {
+	ops     []DiffOp
+	added   int
+	removed int
+}
+
+func (b *diffBuilder) add(op string, tokens ...string) {
+	if len(tokens) == 0 {
+		return
+	}
+	for _, token := range tokens {
+		if strings.TrimSpace(token) == "" {
+			continue
+		}
+		switch op {
+		case DiffInsert:
+			b.added++
+		case DiffDelete:
+			b.removed++
+		}
+	}
+	text := strings.Join(tokens, "")
+	if last := len(b.ops) - 1; last >= 0 && b.ops[last].Op == op {
+		b.ops[last].Text += text
+		return
+	}
+	b.ops = append(b.ops, DiffOp{Op: op, Text: text})
+}
+
+// diffTokens compares two token lists with Myers' algorithm. The counts are
+// the inserted and deleted tokens that are not just whitespace.
+func diffTokens(a, b []string) (ops []DiffOp, added, removed int) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var out diffBuilder
+	out.add(DiffEqual, a[:prefix]...)
+	myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], &out)
+	out.add(DiffEqual, a[len(a)-suffix:]...)
+	if out.ops == nil {
+		out.ops = []DiffOp{}
+	}
+	return out.ops, out.added, out.removed
+}
+
+// myersDiff finds the shortest edit script from a to b. trace keeps the
+// furthest reaching x of every diagonal after each step, which is walked
+// back to recover the edits.
+func myersDiff(a, b []string, out *diffBuilder) {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		out.add(DiffDelete, a...)
+		out.add(DiffInsert, b...)
+		return
+	}
+
+	limit := min(n+m, maxDiffEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				myersBacktrack(a, b, trace, d, out)
+				return
+			}
+		}
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+	}
+
+	out.add(DiffDelete, a...)
+	out.add(DiffInsert, b...)
+}
+
+func myersBacktrack(a, b []string, trace [][]int, d int, out *diffBuilder) {
+	type edit struct {
+		op    string
+		token string
+	}
+	var edits []edit
+	x, y := len(a), len(b)
+	for ; d > 0; d-- {
+		previous := trace[d-1]
+		at := func(k int) int { return previous[k+d-1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, edit{DiffEqual, a[x-1]})
+			x--
+			y--
+		}
+		if x == prevX {
+			edits = append(edits, edit{DiffInsert, b[y-1]})
+		} else {
+			edits = append(edits, edit{DiffDelete, a[x-1]})
+		}
+		x, y = prevX, prevY
+	}
+	for x > 0 && y > 0 {
+		edits = append(edits, edit{DiffEqual, a[x-1]})
+		x--
+		y--
+	}
+
+	for i := len(edits) - 1; i >= 0; i-- {
+		out.add(edits[i].op, edits[i].token)
+	}
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+var ErrInvalidOperation = errors.New("invalid operation")
+
+const (
+	otRetain = iota
+	otInsert
+	otDelete
+)
+
+// otComponent is one step of a text operation
+type otComponent struct {
+	kind int
+	n    int
+	text []uint16
+}
+
+// TextOperation is an edit of a whole text in the format used by ot.js: a
+// list where a positive number keeps that many characters, a negative
+// number deletes them and a string is inserted. Lengths count UTF-16 code
+// units, as JavaScript strings do.
+type TextOperation struct {
+	components   []otComponent
+	BaseLength   int
+	TargetLength int
+}
+
+// Retain keeps n characters
+func (o *TextOperation) Retain(n int) *TextOperation {
+	if n <= 0 {
+		return o
+	}
+	o.BaseLength += n
+	o.TargetLength += n
+	if last := len(o.components) - 1; last >= 0 && o.components[last].kind == otRetain {
+		o.components[last].n += n
+		return o
+	}
+	o.components = append(o.components, otComponent{kind: otRetain, n: n})
+	return o
+}
+
+// Insert adds text. Inserts always come before deletes at the same
+// position, so equal edits have one representation.
+func (o *TextOperation) Insert(text []uint16) *TextOperation {
+	if len(text) == 0 {
+		return o
+	}
+	o.TargetLength += len(text)
+	last := len(o.components) - 1
+	if last >= 0 && o.components[last].kind == otInsert {
+		o.components[last].text = append(o.components[last].text, text...)
+		return o
+	}
+	if last >= 0 && o.components[last].kind == otDelete {
+		if last > 0 && o.components[last-1].kind == otInsert {
+			o.components[last-1].text = append(o.components[last-1].text, text...)
+			return o
+		}
+		deleted := o.components[last]
+		o.components[last] = otComponent{kind: otInsert, text: append([]uint16(nil), text...)}
+		o.components = append(o.components, deleted)
+		return o
+	}
+	o.components = append(o.components, otComponent{kind: otInsert, text: append([]uint16(nil), text...)})
+	return o
+}
+
+// Delete removes n characters
+func (o *TextOperation) Delete(n int) *TextOperation {
+	if n <= 0 {
+		return o
+	}
+	o.BaseLength += n
+	if last := len(o.components) - 1; last >= 0 && o.components[last].kind == otDelete {
+		o.components[last].n += n
+		return o
+	}
+	o.components = append(o.components, otComponent{kind: otDelete, n: n})
+	return o
+}
+
+// IsNoop reports whether the operation leaves the text as it is
+func (o *TextOperation) IsNoop() bool {
+	return len(o.components) == 0 || (len(o.components) == 1 && o.components[0].kind == otRetain)
+}
+
+// MarshalJSON writes the operation as an ot.js list
+func (o *TextOperation) MarshalJSON() ([]byte, error) {
+	out := make([]interface{}, 0, len(o.components))
+	for _, c := range o.components {
+		switch c.kind {
+		case otRetain:
+			out = append(out, c.n)
+		case otInsert:
+			out = append(out, string(utf16.Decode(c.text)))
+		case otDelete:
+			out = append(out, -c.n)
+		}
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON reads an ot.js list
+func (o *TextOperation) UnmarshalJSON(data []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidOperation, err)
+	}
+	*o = TextOperation{}
+	for _, item := range raw {
+		var text string
+		if err := json.Unmarshal(item, &text); err == nil {
+			o.Insert(utf16.Encode([]rune(text)))
+			continue
+		}
+		var n int
+		if err := json.Unmarshal(item, &n); err != nil || n == 0 {
+			return fmt.Errorf("%w: %s is not a retain, insert or delete", ErrInvalidOperation, item)
+		}
+		if n > 0 {
+			o.Retain(n)
+		} else {
+			o.Delete(-n)
+		}
+	}
+	return nil
+}
+
+// Apply runs the operation on a text
+func (o *TextOperation) Apply(text []uint16) ([]uint16, error) {
+	if len(text) != o.BaseLength {
+		return nil, fmt.Errorf("%w: expected a text of length %d, got %d", ErrInvalidOperation, o.BaseLength, len(text))
+	}
+	out := make([]uint16, 0, o.TargetLength)
+	pos := 0
+	for _, c := range o.components {
+		switch c.kind {
+		case otRetain:
+			out = append(out, text[pos:pos+c.n]...)
+			pos += c.n
+		case otInsert:
+			out = append(out, c.text...)
+		case otDelete:
+			pos += c.n
+		}
+	}
+	return out, nil
+}
+
+// TransformIndex moves a position in the text the operation applies to,
+// such as a cursor, to where it ends up afterwards
+func (o *TextOperation) TransformIndex(index int) int {
+	moved := index
+	for _, c := range o.components {
+		switch c.kind {
+		case otRetain:
+			index -= c.n
+		case otInsert:
+			moved += len(c.text)
+		case otDelete:
+			moved -= min(index, c.n)
+			index -= c.n
+		}
+		if index < 0 {
+			break
+		}
+	}
+	return moved
+}
+
+// TransformOperations takes two operations made on the same text and
+// returns a' and b' such that applying a then b' gives the same text as b
+// then a'. Inserts of a at the same position come first.
+func TransformOperations(a, b *TextOperation) (*TextOperation, *TextOperation, error) {
+	if a.BaseLength != b.BaseLength {
+		return nil, nil, fmt.Errorf("%w: operations apply to texts of different lengths", ErrInvalidOperation)
+	}
+	aPrime, bPrime := &TextOperation{}, &TextOperation{}
+	ac, bc := append([]otComponent(nil), a.components...), append([]otComponent(nil), b.components...)
+	i, j := 0, 0
+	for i < len(ac) || j < len(bc) {
+		if i < len(ac) && ac[i].kind == otInsert {
+			aPrime.Insert(ac[i].text)
+			bPrime.Retain(len(ac[i].text))
+			i++
+			continue
+		}
+		if j < len(bc) && bc[j].kind == otInsert {
+			aPrime.Retain(len(bc[j].text))
+			bPrime.Insert(bc[j].text)
+			j++
+			continue
+		}
+		if i == len(ac) || j == len(bc) {
+			return nil, nil, fmt.Errorf("%w: operations do not cover the same text", ErrInvalidOperation)
+		}
+
+		n := min(ac[i].n, bc[j].n)
+		switch {
+		case ac[i].kind == otRetain && bc[j].kind == otRetain:
+			aPrime.Retain(n)
+			bPrime.Retain(n)
+		case ac[i].kind == otDelete && bc[j].kind == otRetain:
+			aPrime.Delete(n)
+		case ac[i].kind == otRetain && bc[j].kind == otDelete:
+			bPrime.Delete(n)
+		}
+		// Text deleted by both is already gone
+		if ac[i].n -= n; ac[i].n == 0 {
+			i++
+		}
+		if bc[j].n -= n; bc[j].n == 0 {
+			j++
+		}
+	}
+	return aPrime, bPrime, nil
+}
+
+// textOperationBetween returns an operation turning one text into another,
+// replacing what lies between their common prefix and suffix
+func textOperationBetween(from, to []uint16) *TextOperation {
+	prefix := 0
+	for prefix < len(from) && prefix < len(to) && from[prefix] == to[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(from)-prefix && suffix < len(to)-prefix && from[len(from)-1-suffix] == to[len(to)-1-suffix] {
+		suffix++
+	}
+	op := &TextOperation{}
+	op.Retain(prefix)
+	op.Insert(to[prefix : len(to)-suffix])
+	op.Delete(len(from) - prefix - suffix)
+	op.Retain(suffix)
+	return op
+}
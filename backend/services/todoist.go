@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/trackeep/backend/models"
+)
+
+const defaultTodoistAPIURL = "https://api.todoist.com/rest/v2"
+
+// TodoistClient reads projects, sections and tasks from the Todoist REST API
+type TodoistClient struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewTodoistClient creates a client for the public Todoist API
+func NewTodoistClient(token string) *TodoistClient {
+	return &TodoistClient{
+		BaseURL:    defaultTodoistAPIURL,
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type todoistProject struct {
+	ID             string  `json:"id"`
+	ParentID       *string `json:"parent_id"`
+	Name           string  `json:"name"`
+	IsInboxProject bool    `json:"is_inbox_project"`
+}
+
+type todoistSection struct {
+	ID        string `json:"id"`
+	ProjectID string `json:"project_id"`
+	Name      string `json:"name"`
+}
+
+type todoistDue struct {
+	Date        string  `json:"date"`
+	Datetime    string  `json:"datetime"`
+	String      string  `json:"string"`
+	Timezone    *string `json:"timezone"`
+	IsRecurring bool    `json:"is_recurring"`
+}
+
+type todoistDuration struct {
+	Amount int    `json:"amount"`
+	Unit   string `json:"unit"`
+}
+
+type todoistTask struct {
+	ID          string           `json:"id"`
+	ProjectID   string           `json:"project_id"`
+	SectionID   *string          `json:"section_id"`
+	ParentID    *string          `json:"parent_id"`
+	Content     string           `json:"content"`
+	Description string           `json:"description"`
+	IsCompleted bool             `json:"is_completed"`
+	Labels      []string         `json:"labels"`
+	Priority    int              `json:"priority"`
+	Due         *todoistDue      `json:"due"`
+	Duration    *todoistDuration `json:"duration"`
+}
+
+func (c *TodoistClient) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	endpoint := strings.TrimRight(c.BaseURL, "/") + path
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach Todoist: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Todoist returned status %d for %s", resp.StatusCode, path)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode Todoist %s: %w", path, err)
+	}
+	return nil
+}
+
+// SyncTodoist imports the user's open Todoist tasks. Projects and sections
+// become nested tags ("Work/Clients/Backlog") and labels plain tags, unless
+// the config turns them off. Without a project filter the sync covers the
+// whole account, so imported tasks that are gone upstream are completed.
+func (s *TaskImportService) SyncTodoist(ctx context.Context, userID uint, client *TodoistClient, cfg *models.TodoistConfig, now time.Time) (*TaskImportResult, error) {
+	syncProjects, syncLabels, projectID := true, true, ""
+	if cfg != nil {
+		syncProjects, syncLabels, projectID = cfg.SyncProjects, cfg.SyncLabels, cfg.ProjectID
+	}
+
+	var projects []todoistProject
+	if err := client.get(ctx, "/projects", nil, &projects); err != nil {
+		return nil, err
+	}
+	query := url.Values{}
+	if projectID != "" {
+		query.Set("project_id", projectID)
+	}
+	var sections []todoistSection
+	if err := client.get(ctx, "/sections", query, &sections); err != nil {
+		return nil, err
+	}
+	var tasks []todoistTask
+	if err := client.get(ctx, "/tasks", query, &tasks); err != nil {
+		return nil, err
+	}
+
+	projectTags := todoistProjectTags(projects)
+	sectionNames := make(map[string]string, len(sections))
+	for _, section := range sections {
+		sectionNames[section.ID] = section.Name
+	}
+
+	loc := UserLocation(s.db, userID)
+	items := make([]ImportedTask, 0, len(tasks))
+	seen := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		item := ImportedTask{
+			ExternalID:  task.ID,
+			Title:       task.Content,
+			Description: task.Description,
+			Priority:    todoistPriority(task.Priority),
+		}
+		if task.ParentID != nil {
+			item.ParentExternalID = *task.ParentID
+		}
+		if task.IsCompleted {
+			item.Status = models.TaskStatusCompleted
+			item.CompletedAt = &now
+		}
+		if task.Duration != nil && task.Duration.Unit == "minute" {
+			item.EstimatedMinutes = task.Duration.Amount
+		}
+		if task.Due != nil {
+			item.DueDate = todoistDueDate(task.Due, loc)
+			if task.Due.IsRecurring {
+				item.Recurrence = ParseRecurrencePhrase(task.Due.String)
+			}
+		}
+
+		if tag, ok := projectTags[task.ProjectID]; ok && syncProjects {
+			if task.SectionID != nil && sectionNames[*task.SectionID] != "" {
+				tag += "/" + todoistTagLevel(sectionNames[*task.SectionID])
+			}
+			item.Tags = append(item.Tags, tag)
+		}
+		if syncLabels {
+			item.Tags = append(item.Tags, task.Labels...)
+		}
+
+		items = append(items, item)
+		seen = append(seen, task.ID)
+	}
+
+	result, err := s.Import(userID, TaskSourceTodoist, items, now)
+	if err != nil {
+		return nil, err
+	}
+	if projectID == "" {
+		closed, err := s.CloseMissing(userID, TaskSourceTodoist, seen, now)
+		if err != nil {
+			return nil, err
+		}
+		result.Closed = closed
+	}
+	return result, nil
+}
+
+// todoistProjectTags maps project IDs to tag paths following the project
+// hierarchy. The inbox gets no tag.
+func todoistProjectTags(projects []todoistProject) map[string]string {
+	byID := make(map[string]todoistProject, len(projects))
+	for _, project := range projects {
+		byID[project.ID] = project
+	}
+
+	tags := make(map[string]string, len(projects))
+	for _, project := range projects {
+		if project.IsInboxProject {
+			continue
+		}
+		levels := []string{todoistTagLevel(project.Name)}
+		seen := map[string]bool{project.ID: true}
+		for parent := project.ParentID; parent != nil && !seen[*parent]; {
+			next, ok := byID[*parent]
+			if !ok {
+				break
+			}
+			seen[next.ID] = true
+			levels = append([]string{todoistTagLevel(next.Name)}, levels...)
+			parent = next.ParentID
+		}
+		tags[project.ID] = strings.Join(levels, "/")
+	}
+	return tags
+}
+
+// todoistTagLevel keeps a slash in a project name from adding a tag level
+func todoistTagLevel(name string) string {
+	return strings.ReplaceAll(name, "/", "-")
+}
+
+// todoistPriority maps Todoist's 4 (p1, urgent) to 1 (p4, normal)
+func todoistPriority(priority int) models.TaskPriority {
+	switch priority {
+	case 4:
+		return models.TaskPriorityUrgent
+	case 3:
+		return models.TaskPriorityHigh
+	case 2:
+		return models.TaskPriorityMedium
+	default:
+		return models.TaskPriorityLow
+	}
+}
+
+// todoistDueDate reads a due date. Floating times and all-day dates are in
+// the user's timezone, and all-day tasks are due at the end of the day.
+func todoistDueDate(due *todoistDue, loc *time.Location) *time.Time {
+	if due.Datetime != "" {
+		if t, err := time.Parse(time.RFC3339Nano, due.Datetime); err == nil {
+			return &t
+		}
+		dueLoc := loc
+		if due.Timezone != nil {
+			if tz, err := time.LoadLocation(*due.Timezone); err == nil {
+				dueLoc = tz
+			}
+		}
+		if t, err := time.ParseInLocation("2006-01-02T15:04:05", due.Datetime, dueLoc); err == nil {
+			return &t
+		}
+	}
+	date, err := time.ParseInLocation("2006-01-02", due.Date, loc)
+	if err != nil {
+		return nil
+	}
+	date = date.Add(quickAddEndOfDayHour*time.Hour + quickAddEndOfDayMinute*time.Minute)
+	return &date
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// CanonicalRule describes how URLs on a domain are normalized
+type CanonicalRule struct {
+	KeepFragment bool     `json:"keep_fragment"`
+	DropQuery    bool     `json:"drop_query"`
+	KeepParams   []string `json:"keep_params"`
+	StripParams  []string `json:"strip_params"`
+}
+
+// trackingParams are query parameters that only identify campaigns or clicks
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "gclsrc": true, "dclid": true, "msclkid": true,
+	"yclid": true, "igshid": true, "mc_cid": true, "mc_eid": true, "mkt_tok": true,
+	"_ga": true, "_gl": true, "_hsenc": true, "_hsmi": true, "oly_anon_id": true,
+	"oly_enc_id": true, "vero_id": true, "wickedid": true, "ref_src": true, "ref_url": true,
+	"share_source": true, "spm": true,
+}
+
+// DefaultCanonicalRules are built-in rules for sites whose URLs carry noise
+// beyond the generic tracking parameters. User rules take precedence. "ref"
+// is only stripped where it names a referrer; elsewhere, such as GitHub, it
+// can select a branch.
+var DefaultCanonicalRules = map[string]CanonicalRule{
+	"youtube.com":     {KeepParams: []string{"v", "list"}},
+	"youtu.be":        {KeepParams: []string{"list"}},
+	"amazon.com":      {DropQuery: true},
+	"medium.com":      {DropQuery: true},
+	"producthunt.com": {StripParams: []string{"ref"}},
+	"twitter.com":     {StripParams: []string{"ref"}},
+	"x.com":           {StripParams: []string{"ref"}},
+}
+
+// CanonicalizeURL normalizes a URL into a key that is equal for URLs that
+// point at the same resource: the scheme is folded to https, the host is
+// lowercased without "www." or a default port, trailing slashes, tracking
+// parameters and fragments are removed and the query is sorted. Rules are
+// matched against the host and its parent domains.
+func CanonicalizeURL(rawURL string, rules map[string]CanonicalRule) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return "", fmt.Errorf("empty URL")
+	}
+	if !strings.Contains(rawURL, "://") && !strings.Contains(rawURL, ":") {
+		rawURL = "https://" + rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		// Only web URLs are normalized; others are compared verbatim
+		return rawURL, nil
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid URL: missing host")
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	host = strings.TrimPrefix(host, "www.")
+	rule := lookupCanonicalRule(host, rules)
+	if port := u.Port(); port != "" && port != "80" && port != "443" {
+		host = net.JoinHostPort(host, port)
+	}
+
+	path := strings.TrimRight(u.EscapedPath(), "/")
+	if path == "" {
+		path = "/"
+	}
+
+	var key strings.Builder
+	key.WriteString("https://")
+	key.WriteString(host)
+	key.WriteString(path)
+
+	if query := canonicalQuery(u.Query(), rule); query != "" {
+		key.WriteString("?")
+		key.WriteString(query)
+	}
+
+	// Hash-bang and hash routes identify pages in single-page apps
+	fragment := u.EscapedFragment()
+	if fragment != "" && (rule.KeepFragment || strings.HasPrefix(fragment, "!") || strings.HasPrefix(fragment, "/")) {
+		key.WriteString("#")
+		key.WriteString(fragment)
+	}
+
+	return key.String(), nil
+}
+
+func canonicalQuery(values url.Values, rule CanonicalRule) string {
+	if rule.DropQuery {
+		return ""
+	}
+
+	keep := make(map[string]bool, len(rule.KeepParams))
+	for _, param := range rule.KeepParams {
+		keep[strings.ToLower(param)] = true
+	}
+	strip := make(map[string]bool, len(rule.StripParams))
+	for _, param := range rule.StripParams {
+		strip[strings.ToLower(param)] = true
+	}
+
+	for param := range values {
+		name := strings.ToLower(param)
+		switch {
+		case len(keep) > 0 && !keep[name]:
+			values.Del(param)
+		case len(keep) == 0 && (strings.HasPrefix(name, "utm_") || trackingParams[name] || strip[name]):
+			values.Del(param)
+		}
+	}
+
+	// Encode sorts by key
+	return values.Encode()
+}
+
+func lookupCanonicalRule(host string, rules map[string]CanonicalRule) CanonicalRule {
+	for domain := host; domain != ""; {
+		if rule, ok := rules[domain]; ok {
+			return rule
+		}
+		dot := strings.Index(domain, ".")
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+	return CanonicalRule{}
+}
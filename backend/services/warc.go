@@ -0,0 +1,108 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WriteWARC writes the archive's responses as a gzip-compressed WARC 1.1
+// file: one warcinfo record followed by a response record per fetched URL.
+// Each record is its own gzip member so standard tools can seek between them.
+func WriteWARC(w io.Writer, archive *PageArchive) error {
+	info := fmt.Sprintf("software: Trackeep\r\nformat: WARC File Format 1.1\r\nisPartOf: %s\r\n", archive.SourceURL)
+	if err := writeWARCRecord(w, "warcinfo", "", archive.CapturedAt, "application/warc-fields", []byte(info)); err != nil {
+		return err
+	}
+
+	for _, response := range archive.Responses {
+		if err := writeWARCRecord(w, "response", response.FinalURL, response.FetchedAt, "application/http;msgtype=response", httpResponseBlock(response)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeWARCRecord(w io.Writer, recordType, targetURI string, date time.Time, contentType string, block []byte) error {
+	var header strings.Builder
+	header.WriteString("WARC/1.1\r\n")
+	fmt.Fprintf(&header, "WARC-Type: %s\r\n", recordType)
+	fmt.Fprintf(&header, "WARC-Record-ID: <urn:uuid:%s>\r\n", newRecordID())
+	fmt.Fprintf(&header, "WARC-Date: %s\r\n", date.UTC().Format(time.RFC3339))
+	if targetURI != "" {
+		fmt.Fprintf(&header, "WARC-Target-URI: %s\r\n", targetURI)
+	}
+	fmt.Fprintf(&header, "WARC-Block-Digest: sha1:%s\r\n", warcDigest(block))
+	fmt.Fprintf(&header, "Content-Type: %s\r\n", contentType)
+	fmt.Fprintf(&header, "Content-Length: %d\r\n", len(block))
+	header.WriteString("\r\n")
+
+	gz := gzip.NewWriter(w)
+	if _, err := io.WriteString(gz, header.String()); err != nil {
+		return err
+	}
+	if _, err := gz.Write(block); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(gz, "\r\n\r\n"); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// httpResponseBlock reconstructs the HTTP response message for a WARC record.
+// The body was decoded by the client, so transfer headers are rewritten to match.
+func httpResponseBlock(response *PageResponse) []byte {
+	var block bytes.Buffer
+
+	proto := response.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	status := response.Status
+	if status == "" {
+		status = fmt.Sprintf("%d %s", response.StatusCode, http.StatusText(response.StatusCode))
+	}
+	fmt.Fprintf(&block, "%s %s\r\n", proto, status)
+
+	keys := make([]string, 0, len(response.Header))
+	for key := range response.Header {
+		switch http.CanonicalHeaderKey(key) {
+		case "Content-Length", "Content-Encoding", "Transfer-Encoding":
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range response.Header[key] {
+			fmt.Fprintf(&block, "%s: %s\r\n", key, value)
+		}
+	}
+	fmt.Fprintf(&block, "Content-Length: %d\r\n\r\n", len(response.Body))
+	block.Write(response.Body)
+
+	return block.Bytes()
+}
+
+func warcDigest(data []byte) string {
+	sum := sha1.Sum(data)
+	return base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// newRecordID returns a random (version 4) UUID
+func newRecordID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrWebPushNotConfigured is returned when the VAPID keys are not set
+var ErrWebPushNotConfigured = errors.New("Web Push is not configured")
+
+// ErrPushSubscriptionGone is returned when the push service no longer knows
+// the subscription, which should then be removed
+var ErrPushSubscriptionGone = errors.New("push subscription is gone")
+
+const (
+	webPushRecordSize = 4096
+	webPushTTL        = 12 * time.Hour
+)
+
+// WebPushConfig holds the VAPID application server keys (RFC 8292). The
+// keys are base64url: the public key as an uncompressed P-256 point, the
+// private key as the raw 32-byte scalar. Generate them once, e.g. with
+// `npx web-push generate-vapid-keys`; browsers subscribe against the public
+// key, so changing it invalidates every subscription.
+type WebPushConfig struct {
+	PublicKey  string
+	PrivateKey string
+	Subject    string // mailto: or https: contact for the push service operator
+}
+
+// WebPushConfigFromEnv reads the WEBPUSH_* environment variables
+func WebPushConfigFromEnv() WebPushConfig {
+	return WebPushConfig{
+		PublicKey:  os.Getenv("WEBPUSH_VAPID_PUBLIC_KEY"),
+		PrivateKey: os.Getenv("WEBPUSH_VAPID_PRIVATE_KEY"),
+		Subject:    os.Getenv("WEBPUSH_SUBJECT"),
+	}
+}
+
+// Complete reports whether enough is set to send pushes
+func (c WebPushConfig) Complete() bool {
+	return c.PublicKey != "" && c.PrivateKey != ""
+}
+
+// WebPushTarget is a browser subscription as returned by
+// PushManager.subscribe(): the endpoint and the base64url p256dh and auth keys
+type WebPushTarget struct {
+	Endpoint string
+	P256dh   string
+	Auth     string
+}
+
+// WebPushSender delivers encrypted messages to push services
+type WebPushSender struct {
+	config     WebPushConfig
+	signingKey *ecdsa.PrivateKey
+	HTTPClient *http.Client
+}
+
+// NewWebPushSender creates a sender from the VAPID keys
+func NewWebPushSender(config WebPushConfig) (*WebPushSender, error) {
+	if !config.Complete() {
+		return nil, ErrWebPushNotConfigured
+	}
+	raw, err := decodeBase64URL(config.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	key, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	public, err := key.PublicKey.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	if base64.RawURLEncoding.EncodeToString(public) != strings.TrimRight(config.PublicKey, "=") {
+		return nil, errors.New("VAPID public key does not match the private key")
+	}
+	if config.Subject == "" {
+		config.Subject = "mailto:admin@trackeep.local"
+	}
+	return &WebPushSender{
+		config:     config,
+		signingKey: key,
+		HTTPClient: &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+// Send encrypts payload for the subscription and posts it to its push
+// service. ErrPushSubscriptionGone means the subscription has expired.
+func (s *WebPushSender) Send(ctx context.Context, target WebPushTarget, payload []byte, now time.Time) error {
+	body, err := encryptWebPush(target, payload)
+	if err != nil {
+		return err
+	}
+	token, err := s.vapidToken(target.Endpoint, now)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", fmt.Sprintf("%d", int(webPushTTL.Seconds())))
+	req.Header.Set("Urgency", "high")
+	req.Header.Set("Authorization", "vapid t="+token+", k="+strings.TrimRight(s.config.PublicKey, "="))
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach push service: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return ErrPushSubscriptionGone
+	case resp.StatusCode >= 300:
+		return fmt.Errorf("push service returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// vapidToken signs the ES256 JWT that identifies this server to the push
+// service of the endpoint
+func (s *WebPushSender) vapidToken(endpoint string, now time.Time) (string, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Host == "" {
+		return "", fmt.Errorf("invalid push endpoint %q", endpoint)
+	}
+	header, _ := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	claims, _ := json.Marshal(map[string]interface{}{
+		"aud": parsed.Scheme + "://" + parsed.Host,
+		"exp": now.Add(webPushTTL).Unix(),
+		"sub": s.config.Subject,
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.signingKey, digest[:])
+	if err != nil {
+		return "", err
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// encryptWebPush encrypts payload for one subscription as a single
+// aes128gcm record (RFC 8291 and RFC 8188)
+func encryptWebPush(target WebPushTarget, payload []byte) ([]byte, error) {
+	userPublic, err := decodeBase64URL(target.P256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	authSecret, err := decodeBase64URL(target.Auth)
+	if err != nil || len(authSecret) == 0 {
+		return nil, errors.New("invalid auth secret")
+	}
+	if len(payload) > webPushRecordSize-103 {
+		return nil, errors.New("push payload is too large")
+	}
+
+	curve := ecdh.P256()
+	uaKey, err := curve.NewPublicKey(userPublic)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	asKey, err := curve.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := asKey.ECDH(uaKey)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := asKey.PublicKey().Bytes()
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	// The input keying material mixes the ECDH secret with the subscription's
+	// auth secret and both public keys
+	keyInfo := append(append([]byte("WebPush: info\x00"), userPublic...), asPublic...)
+	prkKey := hmacSHA256(authSecret, sharedSecret)
+	ikm := hmacSHA256(prkKey, append(keyInfo, 0x01))
+
+	prk, err := hkdf.Extract(sha256.New, ikm, salt)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Expand(sha256.New, prk, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	// 0x02 marks the last (and only) record
+	record := gcm.Seal(nil, nonce, append(append([]byte{}, payload...), 0x02), nil)
+
+	header := make([]byte, 0, 21+len(asPublic))
+	header = append(header, salt...)
+	header = binary.BigEndian.AppendUint32(header, webPushRecordSize)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+	return append(header, record...), nil
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// decodeBase64URL accepts base64url with or without padding, as browsers
+// and key generators differ
+func decodeBase64URL(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}